@@ -6,7 +6,11 @@ import (
 	"colorLex/internal/app/api/middleware"
 	"colorLex/internal/app/api/redis"
 	"colorLex/internal/app/config"
+	"colorLex/internal/app/hyperspectral"
+	"colorLex/internal/app/refindex"
+	"colorLex/internal/app/report"
 	"colorLex/internal/app/repository"
+	"colorLex/internal/app/storage"
 	"context"
 	"fmt"
 	"log"
@@ -64,11 +68,38 @@ func main() {
 	// Инициализируем middleware
 	authMW := middleware.NewAuthMiddleware(repo, cfg.JWTSecret)
 
+	// Индекс ближайших эталонов отражения собирается из признаков в БД
+	referenceIndex := refindex.New()
+	if err := referenceIndex.Load(ctx, repo); err != nil {
+		log.Fatal("Failed to load reference index:", err)
+	}
+	log.Printf("Reference index: %d spectra", referenceIndex.Len())
+	go referenceIndex.Run(ctx, repo, refindex.RefreshInterval)
+
+	// Объектное хранилище изображений: фотографии произведений и карты долей пигментов
+	images, err := storage.MinIOFromEnv()
+	if err != nil {
+		log.Fatal("Failed to configure object storage:", err)
+	}
+
 	// Инициализируем handlers
 	usersHandler := handlers.NewUsersHandler(repo, authMW, redisClient)
-	pigmentHandler := handlers.NewPigmentHandler(repo)
-	spectrumAnalysisHandler := handlers.NewSpectrumAnalysisHandler(repo)
+	pigmentHandler := handlers.NewPigmentHandler(repo, repo, referenceIndex)
+	spectrumAnalysisHandler := handlers.NewSpectrumAnalysisHandler(repo, repo, repo, repo, referenceIndex)
+	spectrumAnalysisHandler.RequireCalibration = cfg.RequireCalibration
 	spectrumAnalysisPigmentHandler := handlers.NewSpectrumAnalysisPigmentsHandler(repo)
+	artworkHandler := handlers.NewArtworkHandler(repo, images)
+	instrumentHandler := handlers.NewInstrumentHandler(repo)
+
+	// Фоновая обработка гиперспектральных кубов
+	cubeWorker := hyperspectral.NewWorker(repo, repo, repo, images, cfg.CubeDir)
+	go cubeWorker.Run(ctx)
+	cubeHandler := handlers.NewCubeHandler(repo, repo, cubeWorker)
+
+	// Отчёт о палитрах пересчитывается в фоне и хранится в Redis
+	reportJob := report.NewJob(repo, redisClient)
+	go reportJob.Run(ctx)
+	reportHandler := handlers.NewReportHandler(reportJob)
 
 	// Настраиваем Gin
 	if os.Getenv("GIN_MODE") == "release" {
@@ -95,7 +126,7 @@ func main() {
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	// Настраиваем API роуты
-	api.SetupAPIRouter(router, repo, authMW, usersHandler, pigmentHandler, spectrumAnalysisHandler, spectrumAnalysisPigmentHandler)
+	api.SetupAPIRouter(router, authMW, usersHandler, pigmentHandler, spectrumAnalysisHandler, spectrumAnalysisPigmentHandler, artworkHandler, instrumentHandler, cubeHandler, reportHandler)
 
 	// Запускаем сервер
 	port := os.Getenv("PORT")
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+
+	"colorLex/internal/app/dsn"
+	"colorLex/internal/app/library"
+	"colorLex/internal/app/refindex"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/spectra"
+
+	"github.com/joho/godotenv"
+)
+
+const usage = `usage: import-library [flags] <file>...
+
+Reads public spectral libraries and prints what would change in the pigment
+catalog. Nothing is written unless -apply is given.
+
+formats:
+  rruff   RRUFF text with ##KEY=VALUE headers or a two-column CSV
+  usgs    USGS spectral library ASCII (reflectance, µm or nm)
+  json    manifest with pigment fields, references and raman bands
+  auto    pick by extension and content (default)
+
+flags:`
+
+func main() {
+	_ = godotenv.Load()
+
+	format := flag.String("format", library.FormatAuto, "file format: auto, rruff, usgs or json")
+	technique := flag.String("technique", spectra.TechniqueRaman, "technique of rruff/csv spectra: raman, reflectance or xrf")
+	overwrite := flag.Bool("overwrite", false, "replace catalog fields that differ from the library")
+	apply := flag.Bool("apply", false, "write the changes to the catalog")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 || !slices.Contains(library.Formats, *format) || !slices.Contains(spectra.Techniques, *technique) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var entries []library.Entry
+	for _, path := range flag.Args() {
+		read, err := library.ReadFile(path, *format, *technique)
+		if err != nil {
+			log.Fatal("cant read library: ", err)
+		}
+		entries = append(entries, read...)
+	}
+
+	repo, err := repository.New(dsn.FromEnv())
+	if err != nil {
+		log.Fatal("failed to connect database:", err)
+	}
+
+	ctx := context.Background()
+	catalog, err := library.LoadCatalog(ctx, repo)
+	if err != nil {
+		log.Fatal("cant read catalog: ", err)
+	}
+
+	plan := library.NewPlan(catalog, entries, *overwrite)
+	if err := plan.WriteReport(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+
+	if !*apply {
+		log.Println("Dry run, rerun with -apply to write the changes")
+		return
+	}
+	if err := library.Apply(ctx, repo, plan); err != nil {
+		log.Fatal("cant import library: ", err)
+	}
+	log.Println("Import completed successfully!")
+	log.Printf("Running servers pick up the new reference spectra within %v", refindex.RefreshInterval)
+}
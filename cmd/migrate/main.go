@@ -1,26 +1,112 @@
 package main
 
 import (
-    "log"
-    "github.com/joho/godotenv"
-    "gorm.io/driver/postgres"
-    "gorm.io/gorm"
-    "colorLex/internal/app/ds"
-    "colorLex/internal/app/dsn"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"colorLex/internal/app/dsn"
+	"colorLex/internal/app/migrations"
+
+	"github.com/joho/godotenv"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
 )
 
+const usage = `usage: migrate <command>
+
+commands:
+  up          apply all pending migrations (default)
+  down [N]    roll back the last N migrations (default 1)
+  status      list migrations and whether they are applied
+  redo        roll back and re-apply the last migration`
+
 func main() {
-    _ = godotenv.Load()
-    
-    db, err := gorm.Open(postgres.Open(dsn.FromEnv()), &gorm.Config{})
-    if err != nil {
-        log.Fatal("failed to connect database:", err)
-    }
-
-    err = db.AutoMigrate(&ds.User{}, &ds.Pigment{}, &ds.SpectrumAnalysis{}, &ds.SpectrumAnalysisPigment{})
-    if err != nil {
-        log.Fatal("cant migrate db:", err)
-    }
-
-    log.Println("Migration completed successfully!")
-}
\ No newline at end of file
+	_ = godotenv.Load()
+
+	command := "up"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+	switch command {
+	case "up", "down", "status", "redo":
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn.FromEnv()), &gorm.Config{})
+	if err != nil {
+		log.Fatal("failed to connect database:", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatal("failed to get sql.DB:", err)
+	}
+	defer sqlDB.Close()
+
+	migrator, err := migrations.New(sqlDB)
+	if err != nil {
+		log.Fatal("failed to load migrations:", err)
+	}
+
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			log.Printf("applied %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal("cant migrate db:", err)
+		}
+		if len(applied) == 0 {
+			log.Println("Database is up to date")
+		} else {
+			log.Println("Migration completed successfully!")
+		}
+
+	case "down":
+		steps := 1
+		if len(os.Args) > 2 {
+			steps, err = strconv.Atoi(os.Args[2])
+			if err != nil || steps < 1 {
+				log.Fatalf("invalid number of steps %q", os.Args[2])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			log.Printf("rolled back %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal("cant roll back db:", err)
+		}
+		if len(reverted) == 0 {
+			log.Println("Nothing to roll back")
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal("cant read migration status:", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+
+	case "redo":
+		redone, err := migrator.Redo(ctx)
+		if err != nil {
+			log.Fatal("cant redo migration:", err)
+		}
+		log.Printf("redone %04d_%s", redone.Version, redone.Name)
+	}
+}
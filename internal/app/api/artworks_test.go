@@ -0,0 +1,451 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/testenv"
+)
+
+type artworkResponse struct {
+	Artwork types.ArtworkDetailResponse `json:"artwork"`
+}
+
+type artworksResponse struct {
+	Artworks []types.ArtworkResponse `json:"artworks"`
+	Count    int                     `json:"count"`
+}
+
+func artworkPath(suffix string) func(*testenv.Fixtures) string {
+	return func(f *testenv.Fixtures) string { return fmt.Sprintf("/api/artworks/%d%s", f.Artwork.ID, suffix) }
+}
+
+func expectArtworkCount(want int) func(*testing.T, *testenv.Env, *testenv.Fixtures, *httptest.ResponseRecorder) {
+	return func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+		var response artworksResponse
+		testenv.Decode(t, rec, &response)
+		if response.Count != want {
+			t.Fatalf("got %d artworks, want %d: %+v", response.Count, want, response.Artworks)
+		}
+	}
+}
+
+// completeForArtwork привязывает заявку к произведению и завершает её с указанными процентами
+func completeForArtwork(t *testing.T, env *testenv.Env, f *testenv.Fixtures, analysis *ds.SpectrumAnalysis, percents map[uint]float64) {
+	t.Helper()
+	now := time.Now()
+	analysis.ArtworkID = &f.Artwork.ID
+	analysis.Status = ds.StatusCompleted
+	analysis.CompletedAt = &now
+	analysis.ModeratorID = &f.Moderator.ID
+	results := make(map[uint]repository.PigmentResult, len(percents))
+	for pigmentID, percent := range percents {
+		results[pigmentID] = repository.PigmentResult{Percent: percent}
+	}
+	if err := env.Analyses.CompleteAnalysis(context.Background(), analysis, results); err != nil {
+		t.Fatalf("complete analysis: %v", err)
+	}
+}
+
+func TestArtworkRoutes(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "list requires token",
+			method: http.MethodGet,
+			path:   path("/api/artworks"),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "list",
+			method: http.MethodGet,
+			path:   path("/api/artworks"),
+			as:     asStranger,
+			status: http.StatusOK,
+			check:  expectArtworkCount(1),
+		},
+		{
+			name:   "list by artist",
+			method: http.MethodGet,
+			path:   path("/api/artworks?search=%D0%BD%D0%B5%D0%B8%D0%B7%D0%B2%D0%B5%D1%81%D1%82%D0%BD%D1%8B%D0%B9"),
+			as:     asCreator,
+			status: http.StatusOK,
+			check:  expectArtworkCount(1),
+		},
+		{
+			name:   "list by collection",
+			method: http.MethodGet,
+			path:   path("/api/artworks?collection=louvre"),
+			as:     asCreator,
+			status: http.StatusOK,
+			check:  expectArtworkCount(0),
+		},
+		{
+			name:   "get aggregates completed analyses",
+			method: http.MethodGet,
+			path:   artworkPath(""),
+			as:     asStranger,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response artworkResponse
+				testenv.Decode(t, rec, &response)
+				artwork := response.Artwork
+				if artwork.Title != f.Artwork.Title || artwork.CompletedAnalyses != 1 {
+					t.Fatalf("unexpected artwork %+v", artwork)
+				}
+				if len(artwork.Pigments) != 2 || artwork.Pigments[0].PigmentID != f.Ochre.ID {
+					t.Fatalf("unexpected pigments %+v", artwork.Pigments)
+				}
+				if lead := artwork.Pigments[1]; !lead.Archived || lead.MeanPercent != 25.5 {
+					t.Fatalf("unexpected lead white stats %+v", lead)
+				}
+			},
+		},
+		{
+			name:   "get missing",
+			method: http.MethodGet,
+			path:   path("/api/artworks/999"),
+			as:     asCreator,
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "create",
+			method: http.MethodPost,
+			path:   path("/api/artworks"),
+			as:     asStranger,
+			body:   body(types.CreateArtworkRequest{Title: "Троица", Artist: "Андрей Рублёв", Date: "1420-е", Collection: "ГТГ"}),
+			status: http.StatusCreated,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					Artwork types.ArtworkResponse `json:"artwork"`
+				}
+				testenv.Decode(t, rec, &response)
+				if response.Artwork.ID == 0 || response.Artwork.CreatorID != f.Stranger.ID {
+					t.Fatalf("unexpected artwork %+v", response.Artwork)
+				}
+			},
+		},
+		{
+			name:   "create without title",
+			method: http.MethodPost,
+			path:   path("/api/artworks"),
+			as:     asCreator,
+			body:   body(map[string]string{"title": " "}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "create duplicate inventory number",
+			method: http.MethodPost,
+			path:   path("/api/artworks"),
+			as:     asCreator,
+			body:   body(types.CreateArtworkRequest{Title: "Другой портрет", InventoryNumber: "Ж-1024", Collection: "Государственный музей"}),
+			status: http.StatusConflict,
+		},
+		{
+			name:   "create same inventory number in other collection",
+			method: http.MethodPost,
+			path:   path("/api/artworks"),
+			as:     asCreator,
+			body:   body(types.CreateArtworkRequest{Title: "Другой портрет", InventoryNumber: "Ж-1024", Collection: "Частное собрание"}),
+			status: http.StatusCreated,
+		},
+		{
+			name:   "update",
+			method: http.MethodPut,
+			path:   artworkPath(""),
+			as:     asCreator,
+			body:   body(types.UpdateArtworkRequest{Artist: "Д. Г. Левицкий"}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				artwork, err := env.Artworks.GetArtwork(context.Background(), f.Artwork.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if artwork.Artist != "Д. Г. Левицкий" || artwork.Title != f.Artwork.Title {
+					t.Fatalf("unexpected artwork %+v", artwork)
+				}
+			},
+		},
+		{
+			name:   "update by moderator",
+			method: http.MethodPut,
+			path:   artworkPath(""),
+			as:     asModerator,
+			body:   body(types.UpdateArtworkRequest{Collection: "Частное собрание"}),
+			status: http.StatusOK,
+		},
+		{
+			name:   "update foreign",
+			method: http.MethodPut,
+			path:   artworkPath(""),
+			as:     asStranger,
+			body:   body(types.UpdateArtworkRequest{Title: "Чужое"}),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "update without data",
+			method: http.MethodPut,
+			path:   artworkPath(""),
+			as:     asCreator,
+			body:   body(types.UpdateArtworkRequest{}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "delete unlinks analyses",
+			method: http.MethodDelete,
+			path:   artworkPath(""),
+			as:     asCreator,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				analysis, err := env.Analyses.GetAnalysis(context.Background(), f.Completed.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if analysis.ArtworkID != nil {
+					t.Fatalf("analysis is still linked to artwork %d", *analysis.ArtworkID)
+				}
+			},
+		},
+		{
+			name:   "delete foreign",
+			method: http.MethodDelete,
+			path:   artworkPath(""),
+			as:     asStranger,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "upload image",
+			method: http.MethodPost,
+			path:   artworkPath("/image"),
+			as:     asCreator,
+			body:   imageForm("portrait.jpg"),
+			header: multipartHeader,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					ImageKey string `json:"image_key"`
+				}
+				testenv.Decode(t, rec, &response)
+				if data, ok := env.Storage.Get(response.ImageKey); !ok || string(data) != "fake image" {
+					t.Fatalf("image %q is not stored", response.ImageKey)
+				}
+			},
+		},
+		{
+			name:   "upload image of another type",
+			method: http.MethodPost,
+			path:   artworkPath("/image"),
+			as:     asCreator,
+			body:   imageForm("portrait.tiff"),
+			header: multipartHeader,
+			status: http.StatusBadRequest,
+		},
+	})
+}
+
+func TestArtworkPigmentStats(t *testing.T) {
+	env := testenv.New(t)
+	f := env.Seed(t)
+
+	// Вторая проба того же произведения: охра в меньшей доле и ультрамарин
+	completeForArtwork(t, env, f, f.Created, map[uint]float64{f.Ultramarine.ID: 70})
+	completeForArtwork(t, env, f, f.Foreign, map[uint]float64{f.Ochre.ID: 40})
+
+	rec := env.Do(t, http.MethodGet, artworkPath("")(f), nil, testenv.WithToken(env.Token(t, f.Creator)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var response artworkResponse
+	testenv.Decode(t, rec, &response)
+
+	if response.Artwork.CompletedAnalyses != 3 {
+		t.Fatalf("completed analyses %d, want 3", response.Artwork.CompletedAnalyses)
+	}
+	byID := make(map[uint]types.ArtworkPigmentStats)
+	for _, stats := range response.Artwork.Pigments {
+		byID[stats.PigmentID] = stats
+	}
+	ochre := byID[f.Ochre.ID]
+	if ochre.Analyses != 2 || ochre.MinPercent != 40 || ochre.MaxPercent != 60 || ochre.MeanPercent != 50 {
+		t.Fatalf("unexpected ochre stats %+v", ochre)
+	}
+	if response.Artwork.Pigments[0].PigmentID != f.Ultramarine.ID {
+		t.Fatalf("pigments are not ordered by mean percent: %+v", response.Artwork.Pigments)
+	}
+
+	// Фильтр заявок по произведению
+	rec = env.Do(t, http.MethodGet, fmt.Sprintf("/api/spectrum-analysis?artwork_id=%d", f.Artwork.ID), nil,
+		testenv.WithToken(env.Token(t, f.Moderator)))
+	var analyses analysesResponse
+	testenv.Decode(t, rec, &analyses)
+	if analyses.Count != 3 {
+		t.Fatalf("got %d analyses for artwork, want 3", analyses.Count)
+	}
+}
+
+func TestMeasurementPointRoutes(t *testing.T) {
+	linkDraft := func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+		t.Helper()
+		f.Draft.ArtworkID = &f.Artwork.ID
+		if err := env.Analyses.UpdateAnalysis(context.Background(), f.Draft); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "set point",
+			method: http.MethodPut,
+			path:   analysisPath("/point", draft),
+			as:     asCreator,
+			setup:  linkDraft,
+			body:   body(map[string]any{"x": 0.7, "y": 0.1, "label": "P2", "layer": "лессировка"}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				rec = env.Do(t, http.MethodGet, analysisPath("", draft)(f), nil, testenv.WithToken(env.Token(t, f.Creator)))
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				if point := response.Analysis.Point; point == nil || point.X != 0.7 || point.Label != "P2" {
+					t.Fatalf("unexpected point %+v", point)
+				}
+			},
+		},
+		{
+			name:   "set point without artwork",
+			method: http.MethodPut,
+			path:   analysisPath("/point", draft),
+			as:     asCreator,
+			body:   body(map[string]any{"x": 0.5, "y": 0.5}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "set point out of image",
+			method: http.MethodPut,
+			path:   analysisPath("/point", draft),
+			as:     asCreator,
+			setup:  linkDraft,
+			body:   body(map[string]any{"x": 1.5, "y": 0.5}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "set point without coordinates",
+			method: http.MethodPut,
+			path:   analysisPath("/point", draft),
+			as:     asCreator,
+			setup:  linkDraft,
+			body:   body(map[string]any{"label": "P2"}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "set point on foreign analysis",
+			method: http.MethodPut,
+			path:   analysisPath("/point", draft),
+			as:     asStranger,
+			setup:  linkDraft,
+			body:   body(map[string]any{"x": 0.5, "y": 0.5}),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "delete missing point",
+			method: http.MethodDelete,
+			path:   analysisPath("/point", draft),
+			as:     asCreator,
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "completed analysis shows point",
+			method: http.MethodGet,
+			path:   analysisPath("", completed),
+			as:     asCreator,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				if point := response.Analysis.Point; point == nil || point.Label != "P1" {
+					t.Fatalf("unexpected point %+v", point)
+				}
+			},
+		},
+		{
+			name:   "artwork points",
+			method: http.MethodGet,
+			path:   artworkPath("/points"),
+			as:     asStranger,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					Points []types.ArtworkPointResponse `json:"points"`
+				}
+				testenv.Decode(t, rec, &response)
+				if len(response.Points) != 1 {
+					t.Fatalf("got %d points, want 1: %+v", len(response.Points), response.Points)
+				}
+				point := response.Points[0]
+				if point.AnalysisID != f.Completed.ID.String() || point.X != 0.25 || point.Layer != "красочный слой" {
+					t.Fatalf("unexpected point %+v", point)
+				}
+				if point.DominantPigment == nil || point.DominantPigment.PigmentID != f.Ochre.ID || point.DominantPigment.Percent != 60 {
+					t.Fatalf("unexpected dominant pigment %+v", point.DominantPigment)
+				}
+			},
+		},
+		{
+			name:   "missing artwork points",
+			method: http.MethodGet,
+			path:   path("/api/artworks/999/points"),
+			as:     asCreator,
+			status: http.StatusNotFound,
+		},
+	})
+}
+
+func TestMeasurementPointVisibility(t *testing.T) {
+	env := testenv.New(t)
+	f := env.Seed(t)
+	ctx := context.Background()
+
+	f.Draft.ArtworkID = &f.Artwork.ID
+	if err := env.Analyses.UpdateAnalysis(ctx, f.Draft); err != nil {
+		t.Fatal(err)
+	}
+	point := ds.MeasurementPoint{ArtworkID: f.Artwork.ID, X: 0.9, Y: 0.9, Label: "P2"}
+	if err := env.Analyses.SaveMeasurementPoint(ctx, f.Draft, &point); err != nil {
+		t.Fatal(err)
+	}
+
+	countPoints := func(user ds.User) int {
+		rec := env.Do(t, http.MethodGet, artworkPath("/points")(f), nil, testenv.WithToken(env.Token(t, user)))
+		var response struct {
+			Count int `json:"count"`
+		}
+		testenv.Decode(t, rec, &response)
+		return response.Count
+	}
+
+	// Черновик виден только автору
+	if got := countPoints(f.Creator); got != 2 {
+		t.Fatalf("creator sees %d points, want 2", got)
+	}
+	if got := countPoints(f.Moderator); got != 1 {
+		t.Fatalf("moderator sees %d points, want 1", got)
+	}
+
+	// После перепривязки к другому произведению точка на карте не показывается
+	other := ds.Artwork{Title: "Эскиз", CreatorID: f.Creator.ID}
+	if err := env.Artworks.CreateArtwork(ctx, &other); err != nil {
+		t.Fatal(err)
+	}
+	f.Draft.ArtworkID = &other.ID
+	if err := env.Analyses.UpdateAnalysis(ctx, f.Draft); err != nil {
+		t.Fatal(err)
+	}
+	if got := countPoints(f.Creator); got != 1 {
+		t.Fatalf("creator sees %d points after relink, want 1", got)
+	}
+}
@@ -0,0 +1,146 @@
+package api_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/testenv"
+)
+
+func TestAuthRoutes(t *testing.T) {
+	refreshToken := func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) string {
+		t.Helper()
+		token, err := env.AuthMW.GenerateRefreshToken(&f.Creator)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "register",
+			method: http.MethodPost,
+			path:   path("/api/auth/register"),
+			body:   body(types.RegisterRequest{Login: "restorer", Password: "secret"}),
+			status: http.StatusCreated,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response types.AuthResponse
+				testenv.Decode(t, rec, &response)
+				if response.User.Login != "restorer" || response.AccessToken == "" {
+					t.Fatalf("unexpected response %+v", response)
+				}
+				if len(env.Redis.Keys()) == 0 {
+					t.Fatal("session was not stored in redis")
+				}
+			},
+		},
+		{
+			name:   "register duplicate login",
+			method: http.MethodPost,
+			path:   path("/api/auth/register"),
+			body:   body(types.RegisterRequest{Login: "creator", Password: "secret"}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "register without password",
+			method: http.MethodPost,
+			path:   path("/api/auth/register"),
+			body:   body(map[string]string{"login": "restorer"}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "login",
+			method: http.MethodPost,
+			path:   path("/api/auth/login"),
+			body:   body(types.LoginRequest{Login: "moderator", Password: testenv.Password}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response types.AuthResponse
+				testenv.Decode(t, rec, &response)
+				if response.User.ID != f.Moderator.ID || !response.User.IsModerator {
+					t.Fatalf("unexpected user %+v", response.User)
+				}
+				refresh, err := env.Redis.Get(fmt.Sprintf("refresh_token:%d", f.Moderator.ID))
+				if err != nil || refresh != response.RefreshToken {
+					t.Fatalf("refresh token in redis %q (%v), want %q", refresh, err, response.RefreshToken)
+				}
+			},
+		},
+		{
+			name:   "login wrong password",
+			method: http.MethodPost,
+			path:   path("/api/auth/login"),
+			body:   body(types.LoginRequest{Login: "creator", Password: "wrong"}),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "login unknown user",
+			method: http.MethodPost,
+			path:   path("/api/auth/login"),
+			body:   body(types.LoginRequest{Login: "nobody", Password: testenv.Password}),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "logout blacklists refresh token",
+			method: http.MethodPost,
+			path:   path("/api/auth/logout"),
+			body:   body(types.LogoutRequest{RefreshToken: "some-token"}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				if !env.Redis.Exists("blacklist:refresh_some-token") {
+					t.Fatal("refresh token was not blacklisted")
+				}
+			},
+		},
+		{
+			name:   "logout bad body",
+			method: http.MethodPost,
+			path:   path("/api/auth/logout"),
+			body:   func(*testenv.Fixtures) any { return strings.NewReader("{") },
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "refresh invalid token",
+			method: http.MethodPost,
+			path:   path("/api/auth/refresh"),
+			body:   body(types.RefreshTokenRequest{RefreshToken: "garbage"}),
+			status: http.StatusUnauthorized,
+		},
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		env := testenv.New(t)
+		f := env.Seed(t)
+		token := refreshToken(t, env, f)
+
+		rec := env.Do(t, http.MethodPost, "/api/auth/refresh", types.RefreshTokenRequest{RefreshToken: token})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+		}
+		var response types.AuthResponse
+		testenv.Decode(t, rec, &response)
+		if response.User.ID != f.Creator.ID || response.AccessToken == "" {
+			t.Fatalf("unexpected response %+v", response)
+		}
+	})
+
+	t.Run("refresh after logout", func(t *testing.T) {
+		env := testenv.New(t)
+		f := env.Seed(t)
+		token := refreshToken(t, env, f)
+
+		rec := env.Do(t, http.MethodPost, "/api/auth/logout", types.LogoutRequest{RefreshToken: token})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("logout status %d", rec.Code)
+		}
+		rec = env.Do(t, http.MethodPost, "/api/auth/refresh", types.RefreshTokenRequest{RefreshToken: token})
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("status %d, want 401", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,210 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/png"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/testenv"
+)
+
+var (
+	// эталоны отражения из фикстур на каналах куба
+	cubeUltramarine = []float64{0.45, 0.50, 0.30, 0.12, 0.08, 0.10, 0.25}
+	cubeOchre       = []float64{0.08, 0.10, 0.18, 0.38, 0.50, 0.55, 0.58}
+)
+
+// cubeHeader - заголовок куба 4×2 float32 BIP; wavelengths в нанометрах
+func cubeHeader(wavelengths string) string {
+	return fmt.Sprintf(`ENVI
+samples = 4
+lines = 2
+bands = 7
+header offset = 0
+data type = 4
+interleave = bip
+byte order = 0
+wavelength units = Nanometers
+wavelength = {%s}
+`, wavelengths)
+}
+
+const visibleBands = "400, 450, 500, 550, 600, 650, 700"
+
+// cubeData - пиксели куба 4×2: доля охры растёт слева направо от 0 до 1, остальное - ультрамарин
+func cubeData() []byte {
+	var buf bytes.Buffer
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			share := float64(x) / 3
+			for b := range cubeOchre {
+				binary.Write(&buf, binary.LittleEndian, float32(share*cubeOchre[b]+(1-share)*cubeUltramarine[b]))
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// cubeForm собирает multipart-форму с файлами header и data
+func cubeForm(header string, data []byte) func(*testenv.Fixtures) any {
+	return func(*testenv.Fixtures) any {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		writer.SetBoundary(imageFormBoundary)
+		part, _ := writer.CreateFormFile("header", "panel.hdr")
+		part.Write([]byte(header))
+		part, _ = writer.CreateFormFile("data", "panel.raw")
+		part.Write(data)
+		writer.Close()
+		return &buf
+	}
+}
+
+// waitCube опрашивает куб, пока воркер не закончит его обработку
+func waitCube(t *testing.T, env *testenv.Env, f *testenv.Fixtures, analysisID string, id uint) types.HyperspectralCube {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		rec := env.Do(t, http.MethodGet, fmt.Sprintf("/api/spectrum-analysis/%s/cubes/%d", analysisID, id), nil,
+			testenv.WithToken(env.Token(t, f.Creator)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("get cube: status %d, body %s", rec.Code, rec.Body.String())
+		}
+		var cube types.HyperspectralCube
+		testenv.Decode(t, rec, &cube)
+		if cube.Status == "done" || cube.Status == "failed" {
+			return cube
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("cube still %s at %v%%", cube.Status, cube.Progress)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHyperspectralCubes(t *testing.T) {
+	draftCubes := func(f *testenv.Fixtures) string { return "/api/spectrum-analysis/" + f.Draft.ID.String() + "/cubes" }
+	runRouteCases(t, []routeCase{
+		{
+			name:   "abundance maps of a draft",
+			method: http.MethodPost,
+			path:   draftCubes,
+			as:     asCreator,
+			header: multipartHeader,
+			body:   cubeForm(cubeHeader(visibleBands), cubeData()),
+			status: http.StatusAccepted,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var accepted types.HyperspectralCube
+				testenv.Decode(t, rec, &accepted)
+				if accepted.Status != "queued" || accepted.Samples != 4 || accepted.Lines != 2 || accepted.Bands != 7 || accepted.Name != "panel.hdr" {
+					t.Fatalf("unexpected cube %+v", accepted)
+				}
+
+				cube := waitCube(t, env, f, f.Draft.ID.String(), accepted.ID)
+				if cube.Status != "done" || cube.Progress != 100 || cube.FinishedAt == nil || len(cube.Maps) != 2 {
+					t.Fatalf("unexpected result %+v", cube)
+				}
+				for _, m := range cube.Maps {
+					// доля каждого пигмента в среднем по кубу - половина, максимум - весь пиксель
+					if math.Abs(m.MeanPercent-50) > 0.5 || math.Abs(m.MaxPercent-100) > 0.5 || m.ImageURL != "/api/images/"+m.ImageKey {
+						t.Fatalf("unexpected map %+v", m)
+					}
+					image, ok := env.Storage.Get(m.ImageKey)
+					if !ok {
+						t.Fatalf("map %s was not stored", m.ImageKey)
+					}
+					decoded, err := png.Decode(bytes.NewReader(image))
+					if err != nil || decoded.Bounds().Dx() != 4 || decoded.Bounds().Dy() != 2 {
+						t.Fatalf("map %s: %v %v", m.ImageKey, err, decoded)
+					}
+					// у охры правый столбец белый, у ультрамарина - левый
+					x := 3
+					if m.PigmentID == f.Ultramarine.ID {
+						x = 0
+					}
+					if r, _, _, _ := decoded.At(x, 1).RGBA(); r>>8 < 250 {
+						t.Fatalf("%s at (%d, 1): level %d", m.PigmentName, x, r>>8)
+					}
+				}
+
+				rec = env.Do(t, http.MethodGet, draftCubes(f), nil, testenv.WithToken(env.Token(t, f.Creator)))
+				var list struct {
+					Cubes []types.HyperspectralCube `json:"cubes"`
+				}
+				testenv.Decode(t, rec, &list)
+				if len(list.Cubes) != 1 || len(list.Cubes[0].Maps) != 2 || list.Cubes[0].Maps[0].PigmentName == "" {
+					t.Fatalf("unexpected list %+v", list.Cubes)
+				}
+			},
+		},
+		{
+			name:   "bands outside references",
+			method: http.MethodPost,
+			path:   func(f *testenv.Fixtures) string { return "/api/spectrum-analysis/" + f.Created.ID.String() + "/cubes" },
+			as:     asModerator,
+			header: multipartHeader,
+			body:   cubeForm(cubeHeader("900, 950, 1000, 1050, 1100, 1150, 1200"), cubeData()),
+			status: http.StatusAccepted,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var accepted types.HyperspectralCube
+				testenv.Decode(t, rec, &accepted)
+				cube := waitCube(t, env, f, f.Created.ID.String(), accepted.ID)
+				if cube.Status != "failed" || !strings.Contains(cube.Error, "эталон") || len(cube.Maps) != 0 {
+					t.Fatalf("unexpected result %+v", cube)
+				}
+			},
+		},
+		{
+			name:   "invalid header",
+			method: http.MethodPost,
+			path:   draftCubes,
+			as:     asCreator,
+			header: multipartHeader,
+			body:   cubeForm(strings.Replace(cubeHeader(visibleBands), "data type = 4", "data type = 6", 1), cubeData()),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "data shorter than header",
+			method: http.MethodPost,
+			path:   draftCubes,
+			as:     asCreator,
+			header: multipartHeader,
+			body:   cubeForm(cubeHeader(visibleBands), cubeData()[:100]),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "foreign analysis",
+			method: http.MethodPost,
+			path:   draftCubes,
+			as:     asStranger,
+			header: multipartHeader,
+			body:   cubeForm(cubeHeader(visibleBands), cubeData()),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "cube of another analysis",
+			method: http.MethodGet,
+			path: func(f *testenv.Fixtures) string {
+				return "/api/spectrum-analysis/" + f.Created.ID.String() + "/cubes/1"
+			},
+			as: asCreator,
+			setup: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+				rec := env.Do(t, http.MethodPost, draftCubes(f), cubeForm(cubeHeader(visibleBands), cubeData())(f),
+					testenv.WithToken(env.Token(t, f.Creator)), testenv.WithHeader("Content-Type", "multipart/form-data; boundary="+imageFormBoundary))
+				if rec.Code != http.StatusAccepted {
+					t.Fatalf("upload: status %d", rec.Code)
+				}
+			},
+			status: http.StatusNotFound,
+		},
+	})
+}
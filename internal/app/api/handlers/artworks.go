@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ArtworkHandler struct {
+	Artworks repository.ArtworkStore
+	Images   storage.Storage // фотографии произведений, отдаются через GET /api/images/:key
+}
+
+func NewArtworkHandler(artworks repository.ArtworkStore, images storage.Storage) *ArtworkHandler {
+	return &ArtworkHandler{Artworks: artworks, Images: images}
+}
+
+// maxArtworkImage - предельный размер фотографии произведения
+const maxArtworkImage = 20 << 20
+
+// GET /api/artworks - список произведений
+func (h *ArtworkHandler) GetArtworks(c *gin.Context) {
+	var filter types.ArtworkFilter
+	if err := c.BindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверные параметры фильтрации"))
+		return
+	}
+
+	artworks, err := h.Artworks.ListArtworks(c.Request.Context(), repository.ArtworkQuery{
+		Search:     filter.Search,
+		Collection: filter.Collection,
+		Limit:      filter.Limit,
+		Offset:     filter.Offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения произведений"))
+		return
+	}
+
+	response := make([]types.ArtworkResponse, len(artworks))
+	for i, artwork := range artworks {
+		response[i] = newArtworkResponse(artwork)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"artworks": response,
+		"count":    len(response),
+	})
+}
+
+// GET /api/artworks/:id - произведение и сводка пигментов по его завершённым анализам
+func (h *ArtworkHandler) GetArtwork(c *gin.Context) {
+	id, ok := parseArtworkID(c)
+	if !ok {
+		return
+	}
+
+	artwork, err := h.Artworks.GetArtwork(c.Request.Context(), id)
+	if err != nil {
+		respondArtworkError(c, err, "Ошибка получения произведения")
+		return
+	}
+
+	stats, err := h.Artworks.ArtworkPigmentStats(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения произведения"))
+		return
+	}
+	completed, err := h.Artworks.CountArtworkAnalyses(c.Request.Context(), id, ds.StatusCompleted)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения произведения"))
+		return
+	}
+
+	pigments := make([]types.ArtworkPigmentStats, len(stats))
+	for i, s := range stats {
+		pigments[i] = types.ArtworkPigmentStats{
+			PigmentID:   s.Pigment.ID,
+			Name:        s.Pigment.Name,
+			CIName:      s.Pigment.CIName,
+			Archived:    s.Pigment.DeletedAt.Valid,
+			Analyses:    s.Analyses,
+			MinPercent:  s.MinPercent,
+			MaxPercent:  s.MaxPercent,
+			MeanPercent: s.MeanPercent,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"artwork": types.ArtworkDetailResponse{
+			ArtworkResponse:   newArtworkResponse(*artwork),
+			CompletedAnalyses: completed,
+			Pigments:          pigments,
+		},
+	})
+}
+
+// POST /api/artworks - создание произведения
+func (h *ArtworkHandler) CreateArtwork(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, types.Fail("Пользователь не аутентифицирован"))
+		return
+	}
+
+	var request types.CreateArtworkRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный формат данных: "+err.Error()))
+		return
+	}
+
+	title := strings.TrimSpace(request.Title)
+	if title == "" {
+		c.JSON(http.StatusBadRequest, types.Fail("Название произведения обязательно"))
+		return
+	}
+	if request.Year != nil && *request.Year > time.Now().Year() {
+		c.JSON(http.StatusBadRequest, types.Fail("Год создания не может быть в будущем"))
+		return
+	}
+
+	artwork := ds.Artwork{
+		Title:           title,
+		Artist:          request.Artist,
+		Date:            request.Date,
+		Year:            request.Year,
+		InventoryNumber: strings.TrimSpace(request.InventoryNumber),
+		Collection:      strings.TrimSpace(request.Collection),
+		CreatorID:       userID.(uint),
+	}
+	if err := h.Artworks.CreateArtwork(c.Request.Context(), &artwork); err != nil {
+		respondArtworkError(c, err, "Ошибка создания произведения")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"artwork": newArtworkResponse(artwork),
+	})
+}
+
+// PUT /api/artworks/:id - обновление произведения
+func (h *ArtworkHandler) UpdateArtwork(c *gin.Context) {
+	id, ok := parseArtworkID(c)
+	if !ok {
+		return
+	}
+
+	var request types.UpdateArtworkRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный формат данных"))
+		return
+	}
+
+	artwork, ok := h.loadOwnArtwork(c, id, "Ошибка обновления произведения")
+	if !ok {
+		return
+	}
+
+	// Обновляем только переданные поля
+	updated := false
+	if title := strings.TrimSpace(request.Title); title != "" {
+		artwork.Title = title
+		updated = true
+	}
+	if request.Artist != "" {
+		artwork.Artist = request.Artist
+		updated = true
+	}
+	if request.Date != "" {
+		artwork.Date = request.Date
+		updated = true
+	}
+	if request.Year != nil {
+		if *request.Year > time.Now().Year() {
+			c.JSON(http.StatusBadRequest, types.Fail("Год создания не может быть в будущем"))
+			return
+		}
+		year := *request.Year
+		artwork.Year = &year
+		updated = true
+	}
+	if request.InventoryNumber != "" {
+		artwork.InventoryNumber = strings.TrimSpace(request.InventoryNumber)
+		updated = true
+	}
+	if request.Collection != "" {
+		artwork.Collection = strings.TrimSpace(request.Collection)
+		updated = true
+	}
+
+	if !updated {
+		c.JSON(http.StatusBadRequest, types.Fail("Нет данных для обновления"))
+		return
+	}
+
+	if err := h.Artworks.UpdateArtwork(c.Request.Context(), artwork); err != nil {
+		respondArtworkError(c, err, "Ошибка обновления произведения")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"artwork": newArtworkResponse(*artwork),
+	})
+}
+
+// DELETE /api/artworks/:id - удаление произведения (анализы остаются, но отвязываются)
+func (h *ArtworkHandler) DeleteArtwork(c *gin.Context) {
+	id, ok := parseArtworkID(c)
+	if !ok {
+		return
+	}
+
+	if _, ok := h.loadOwnArtwork(c, id, "Ошибка удаления произведения"); !ok {
+		return
+	}
+
+	if err := h.Artworks.DeleteArtwork(c.Request.Context(), id); err != nil {
+		respondArtworkError(c, err, "Ошибка удаления произведения")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Произведение удалено",
+	})
+}
+
+// POST /api/artworks/:id/image - загрузка фотографии произведения
+func (h *ArtworkHandler) UploadImage(c *gin.Context) {
+	id, ok := parseArtworkID(c)
+	if !ok {
+		return
+	}
+
+	artwork, ok := h.loadOwnArtwork(c, id, "Ошибка загрузки изображения")
+	if !ok {
+		return
+	}
+
+	file, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Файл изображения обязателен"))
+		return
+	}
+
+	fileExt := strings.ToLower(filepath.Ext(file.Filename))
+	contentType := "image/jpeg"
+	switch fileExt {
+	case ".jpg", ".jpeg":
+	case ".png":
+		contentType = "image/png"
+	default:
+		c.JSON(http.StatusBadRequest, types.Fail("Поддерживаются только JPG, JPEG и PNG файлы"))
+		return
+	}
+	if file.Size > maxArtworkImage {
+		c.JSON(http.StatusRequestEntityTooLarge, types.Fail("Изображение больше 20 МБ"))
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Не удалось прочитать файл изображения"))
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(src, maxArtworkImage+1))
+	src.Close()
+	if err != nil || len(data) > maxArtworkImage {
+		c.JSON(http.StatusBadRequest, types.Fail("Не удалось прочитать файл изображения"))
+		return
+	}
+
+	// Сначала сохраняем файл: запись не должна ссылаться на отсутствующий объект
+	key := fmt.Sprintf("artwork_%d_%d%s", artwork.ID, time.Now().Unix(), fileExt)
+	if err := h.Images.Put(c.Request.Context(), key, contentType, data); err != nil {
+		c.JSON(http.StatusBadGateway, types.Fail("Ошибка сохранения изображения"))
+		return
+	}
+
+	artwork.ImageKey = key
+	if err := h.Artworks.UpdateArtwork(c.Request.Context(), artwork); err != nil {
+		respondArtworkError(c, err, "Ошибка сохранения информации об изображении")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Изображение успешно загружено",
+		"image_key":  artwork.ImageKey,
+		"artwork_id": artwork.ID,
+	})
+}
+
+// loadOwnArtwork загружает произведение для изменения: менять его может автор записи или модератор.
+// При ошибке сам отвечает клиенту
+func (h *ArtworkHandler) loadOwnArtwork(c *gin.Context, id uint, message string) (*ds.Artwork, bool) {
+	artwork, err := h.Artworks.GetArtwork(c.Request.Context(), id)
+	if err != nil {
+		respondArtworkError(c, err, message)
+		return nil, false
+	}
+	if artwork.CreatorID != c.GetUint("user_id") && !c.GetBool("is_moderator") {
+		c.JSON(http.StatusForbidden, types.Fail("Недостаточно прав"))
+		return nil, false
+	}
+	return artwork, true
+}
+
+// parseArtworkID разбирает :id из пути, при ошибке сам отвечает 400
+func parseArtworkID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный ID произведения"))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondArtworkError отвечает 404 для ненайденного произведения, 409 для занятого
+// инвентарного номера и 500 с message для остального
+func respondArtworkError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, types.Fail("Произведение не найдено"))
+	case errors.Is(err, repository.ErrAlreadyExists):
+		c.JSON(http.StatusConflict, types.Fail("Инвентарный номер уже занят в этом собрании"))
+	default:
+		c.JSON(http.StatusInternalServerError, types.Fail(message))
+	}
+}
+
+func newArtworkResponse(artwork ds.Artwork) types.ArtworkResponse {
+	return types.ArtworkResponse{
+		ID:              artwork.ID,
+		Title:           artwork.Title,
+		Artist:          artwork.Artist,
+		Date:            artwork.Date,
+		Year:            artwork.Year,
+		InventoryNumber: artwork.InventoryNumber,
+		Collection:      artwork.Collection,
+		ImageKey:        artwork.ImageKey,
+		CreatorID:       artwork.CreatorID,
+		CreatedAt:       artwork.CreatedAt,
+		UpdatedAt:       artwork.UpdatedAt,
+	}
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/hyperspectral"
+	"colorLex/internal/app/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxCubeHeader - наибольший размер заголовка ENVI
+const maxCubeHeader = 4 << 20
+
+type CubeHandler struct {
+	Analyses repository.AnalysisStore
+	Cubes    repository.CubeStore
+	Worker   *hyperspectral.Worker
+}
+
+func NewCubeHandler(analyses repository.AnalysisStore, cubes repository.CubeStore, worker *hyperspectral.Worker) *CubeHandler {
+	return &CubeHandler{Analyses: analyses, Cubes: cubes, Worker: worker}
+}
+
+// POST /api/spectrum-analysis/:id/cubes - загрузка гиперспектрального куба ENVI
+// (поля формы header - .hdr, data - двоичные данные). Куб раскладывается по эталонам
+// пигментов заявки в фоне, ход обработки - в GET /api/spectrum-analysis/:id/cubes/:cube_id
+func (h *CubeHandler) UploadCube(c *gin.Context) {
+	analysis, ok := h.loadAnalysis(c, "Ошибка загрузки куба")
+	if !ok {
+		return
+	}
+	if analysis.CreatorID != c.GetUint("user_id") && !c.GetBool("is_moderator") {
+		c.JSON(http.StatusForbidden, types.Fail("Недостаточно прав"))
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, hyperspectral.MaxDataSize+maxCubeHeader)
+	headerFile, err := c.FormFile("header")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, types.Fail("Куб слишком большой"))
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Файл заголовка ENVI (header) обязателен"))
+		return
+	}
+	dataFile, err := c.FormFile("data")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Файл данных куба (data) обязателен"))
+		return
+	}
+	if headerFile.Size > maxCubeHeader {
+		c.JSON(http.StatusBadRequest, types.Fail("Заголовок ENVI слишком большой"))
+		return
+	}
+
+	headerReader, err := headerFile.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка загрузки куба"))
+		return
+	}
+	defer headerReader.Close()
+	headerText, err := io.ReadAll(io.LimitReader(headerReader, maxCubeHeader))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка загрузки куба"))
+		return
+	}
+	header, err := hyperspectral.ParseHeader(bytes.NewReader(headerText))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный заголовок ENVI: "+err.Error()))
+		return
+	}
+	if dataFile.Size < header.DataSize() {
+		c.JSON(http.StatusBadRequest, types.Fail("Файл данных меньше, чем описано в заголовке"))
+		return
+	}
+
+	data, err := dataFile.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка загрузки куба"))
+		return
+	}
+	defer data.Close()
+	cube := ds.HyperspectralCube{
+		SpectrumAnalysisID: analysis.ID,
+		CreatorID:          c.GetUint("user_id"),
+		Name:               filepath.Base(headerFile.Filename),
+		Header:             string(headerText),
+		Samples:            header.Samples,
+		Lines:              header.Lines,
+		Bands:              header.Bands,
+	}
+	err = h.Worker.Submit(c.Request.Context(), &cube, data)
+	if errors.Is(err, hyperspectral.ErrQueueFull) {
+		c.JSON(http.StatusServiceUnavailable, types.Fail("Очередь обработки кубов заполнена, повторите позже"))
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка загрузки куба"))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, newCubeResponse(cube, nil, nil))
+}
+
+// GET /api/spectrum-analysis/:id/cubes - кубы заявки с картами долей
+func (h *CubeHandler) GetCubes(c *gin.Context) {
+	analysis, ok := h.loadAnalysis(c, "Ошибка получения кубов")
+	if !ok {
+		return
+	}
+	cubes, err := h.Cubes.ListCubes(c.Request.Context(), analysis.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения кубов"))
+		return
+	}
+	names, ok := h.pigmentNames(c, analysis)
+	if !ok {
+		return
+	}
+
+	response := make([]types.HyperspectralCube, 0, len(cubes))
+	for _, cube := range cubes {
+		maps, err := h.Cubes.ListAbundanceMaps(c.Request.Context(), cube.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения кубов"))
+			return
+		}
+		response = append(response, newCubeResponse(cube, maps, names))
+	}
+	c.JSON(http.StatusOK, gin.H{"cubes": response})
+}
+
+// GET /api/spectrum-analysis/:id/cubes/:cube_id - ход обработки куба и карты долей
+func (h *CubeHandler) GetCube(c *gin.Context) {
+	analysis, ok := h.loadAnalysis(c, "Ошибка получения куба")
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("cube_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный ID куба"))
+		return
+	}
+	cube, err := h.Cubes.GetCube(c.Request.Context(), uint(id))
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && cube.SpectrumAnalysisID != analysis.ID) {
+		c.JSON(http.StatusNotFound, types.Fail("Куб не найден"))
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения куба"))
+		return
+	}
+	maps, err := h.Cubes.ListAbundanceMaps(c.Request.Context(), cube.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения куба"))
+		return
+	}
+	names, ok := h.pigmentNames(c, analysis)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, newCubeResponse(*cube, maps, names))
+}
+
+// loadAnalysis находит неудалённую заявку по :id из пути. При ошибке сам пишет ответ
+func (h *CubeHandler) loadAnalysis(c *gin.Context, failMessage string) (*ds.SpectrumAnalysis, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный ID заявки"))
+		return nil, false
+	}
+	analysis, err := h.Analyses.GetAnalysis(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && analysis.Status == ds.StatusDeleted) {
+		c.JSON(http.StatusNotFound, types.Fail("Заявка не найдена"))
+		return nil, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail(failMessage))
+		return nil, false
+	}
+	return analysis, true
+}
+
+// pigmentNames - названия пигментов заявки по ID, включая архивные
+func (h *CubeHandler) pigmentNames(c *gin.Context, analysis *ds.SpectrumAnalysis) (map[uint]string, bool) {
+	pigments, err := h.Analyses.ListAnalysisPigments(c.Request.Context(), analysis.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения кубов"))
+		return nil, false
+	}
+	names := make(map[uint]string, len(pigments))
+	for _, ap := range pigments {
+		names[ap.Pigment.ID] = ap.Pigment.Name
+	}
+	return names, true
+}
+
+func newCubeResponse(cube ds.HyperspectralCube, maps []ds.AbundanceMap, names map[uint]string) types.HyperspectralCube {
+	response := types.HyperspectralCube{
+		ID:         cube.ID,
+		Name:       cube.Name,
+		Samples:    cube.Samples,
+		Lines:      cube.Lines,
+		Bands:      cube.Bands,
+		Status:     cube.Status,
+		Progress:   roundTo(100*cube.Progress, 1),
+		Error:      cube.Error,
+		CreatedAt:  cube.CreatedAt,
+		StartedAt:  cube.StartedAt,
+		FinishedAt: cube.FinishedAt,
+	}
+	for _, m := range maps {
+		response.Maps = append(response.Maps, types.AbundanceMap{
+			PigmentID:   m.PigmentID,
+			PigmentName: names[m.PigmentID],
+			ImageKey:    m.ImageKey,
+			ImageURL:    "/api/images/" + m.ImageKey,
+			MeanPercent: roundTo(100*m.MeanFraction, 1),
+			MaxPercent:  roundTo(100*m.MaxFraction, 1),
+		})
+	}
+	return response
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+
+	"github.com/gin-gonic/gin"
+)
+
+// analysisETag - ETag заявки, построенный по её версии
+func analysisETag(analysis *ds.SpectrumAnalysis) string {
+	return `"` + strconv.FormatUint(uint64(analysis.Version), 10) + `"`
+}
+
+// setAnalysisETag отдаёт клиенту текущую версию заявки
+func setAnalysisETag(c *gin.Context, analysis *ds.SpectrumAnalysis) {
+	c.Header("ETag", analysisETag(analysis))
+}
+
+// checkIfMatch сверяет заголовок If-Match с версией заявки. Без заголовка запрос
+// проходит как раньше; при несовпадении сам отвечает 412 и возвращает false
+func checkIfMatch(c *gin.Context, analysis *ds.SpectrumAnalysis) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return true
+	}
+
+	current := analysisETag(analysis)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+
+	respondVersionConflict(c)
+	return false
+}
+
+// respondVersionConflict сообщает, что заявку изменили параллельно
+func respondVersionConflict(c *gin.Context) {
+	c.JSON(http.StatusPreconditionFailed, types.Fail("Заявка была изменена другим запросом, обновите данные"))
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/identify"
+	"colorLex/internal/app/refindex"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/spectra"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /api/spectrum-analysis/:id/identification - рейтинг пигментов по измерениям всех методик
+func (h *SpectrumAnalysisHandler) GetIdentification(c *gin.Context) {
+	analysis, ok := h.loadAnalysis(c, "Ошибка идентификации пигментов")
+	if !ok {
+		return
+	}
+	if analysis.Status == ds.StatusDeleted {
+		c.JSON(http.StatusNotFound, types.Fail("Заявка была удалена"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	readings, err := h.Analyses.ListReadings(ctx, analysis.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка идентификации пигментов"))
+		return
+	}
+	aggregates := combineAllTechniques(analysis, readings)
+	if len(aggregates) == 0 {
+		c.JSON(http.StatusBadRequest, types.Fail("В заявке нет спектров для идентификации"))
+		return
+	}
+
+	// Рамановские полосы и формулы для XRF сравниваются со всем каталогом неархивных
+	// пигментов; для отражения кандидатов отбирает индекс, и каталог не нужен
+	var pigments []ds.Pigment
+	if aggregates[spectra.TechniqueRaman] != nil || aggregates[spectra.TechniqueXRF] != nil {
+		pigments, err = h.Pigments.ListPigments(ctx, repository.PigmentQuery{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка идентификации пигментов"))
+			return
+		}
+	}
+
+	results := make(map[string][]identify.Candidate)
+	var techniques []types.TechniqueIdentification
+	for _, technique := range spectra.Techniques {
+		aggregate := aggregates[technique]
+		if aggregate == nil {
+			continue
+		}
+		axis, _ := spectra.TechniqueAxis(technique)
+		item := types.TechniqueIdentification{Technique: technique, Unit: axis.Unit, Readings: aggregate.Used}
+
+		switch technique {
+		case spectra.TechniqueReflectance:
+			pipeline := analysisPipeline(analysis)
+			references, err := h.nearestReflectance(ctx, aggregate.Mean, pipeline)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, types.Fail("Ошибка идентификации пигментов"))
+				return
+			}
+			sample, references := preprocessForMatching(pipeline, aggregate.Mean, references)
+			results[technique] = identify.MatchReflectance(sample, references)
+		case spectra.TechniqueRaman:
+			sets, _, err := h.ramanBandSets(ctx, pigments)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, types.Fail("Ошибка идентификации пигментов"))
+				return
+			}
+			for _, peak := range identify.DetectPeaks(aggregate.Mean) {
+				item.Peaks = append(item.Peaks, peak.Position)
+			}
+			results[technique] = identify.MatchRaman(aggregate.Mean, sets)
+		case spectra.TechniqueXRF:
+			formulas := make([]identify.PigmentFormula, len(pigments))
+			for i, pigment := range pigments {
+				formulas[i] = identify.PigmentFormula{PigmentID: pigment.ID, Name: pigment.Name, Formula: pigment.Formula}
+			}
+			item.Elements = identify.DetectElements(aggregate.Mean)
+			results[technique] = identify.MatchXRF(aggregate.Mean, formulas)
+		}
+
+		item.Candidates = make([]types.IdentificationCandidate, len(results[technique]))
+		for i, candidate := range results[technique] {
+			item.Candidates[i] = types.IdentificationCandidate{
+				PigmentID: candidate.PigmentID,
+				Name:      candidate.Name,
+				Score:     roundScore(candidate.Score),
+				Matched:   candidate.Matched,
+				Missing:   candidate.Missing,
+			}
+		}
+		techniques = append(techniques, item)
+	}
+
+	analysisPigments, err := h.Analyses.ListAnalysisPigments(ctx, analysis.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка идентификации пигментов"))
+		return
+	}
+	inAnalysis := make(map[uint]bool, len(analysisPigments))
+	for _, ap := range analysisPigments {
+		inAnalysis[ap.Pigment.ID] = true
+	}
+
+	ranked := []types.RankedPigment{}
+	for _, r := range identify.Rank(results) {
+		scores := make(map[string]float64, len(r.Scores))
+		for technique, score := range r.Scores {
+			scores[technique] = roundScore(score)
+		}
+		ranked = append(ranked, types.RankedPigment{
+			PigmentID:  r.PigmentID,
+			Name:       r.Name,
+			Score:      roundScore(r.Score),
+			Scores:     scores,
+			InAnalysis: inAnalysis[r.PigmentID],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"techniques": techniques,
+		"pigments":   ranked,
+	})
+}
+
+// nearestReferences - сколько ближайших по индексу эталонов точно сравнивается с образцом
+const nearestReferences = 100
+
+// preprocessedWidening - во сколько раз больше эталонов берётся из индекса для заявки
+// с предобработкой: индекс построен по исходным кривым, а после предобработки
+// ближайшие эталоны могут оказаться чуть дальше в его выдаче
+const preprocessedWidening = 5
+
+// referenceSpectra загружает эталоны методики для пигментов-кандидатов
+func (h *SpectrumAnalysisHandler) referenceSpectra(ctx context.Context, technique string, pigments []ds.Pigment) ([]identify.Reference, error) {
+	stored, err := h.Pigments.ListReferenceSpectra(ctx, 0, technique)
+	if err != nil {
+		return nil, err
+	}
+	return candidateReferences(stored, pigments), nil
+}
+
+// nearestReflectance загружает только эталоны отражения, ближайшие к образцу по индексу
+// refindex, чтобы не перебирать весь каталог. С предобработкой из индекса берётся более
+// широкий набор, который затем точно ранжируется уже после неё. Все эталоны загружаются
+// только для образца без признаков (вне сетки индекса)
+func (h *SpectrumAnalysisHandler) nearestReflectance(ctx context.Context, sample spectra.Spectrum, pipeline spectra.Pipeline) ([]identify.Reference, error) {
+	var stored []ds.ReferenceSpectrum
+	if features, err := refindex.Features(sample); err == nil {
+		k := nearestReferences
+		if len(pipeline) > 0 {
+			k *= preprocessedWidening
+		}
+		stored, err = h.Pigments.GetReferenceSpectra(ctx, h.References.Search(features, k, nil))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		stored, err = h.Pigments.ListReferenceSpectra(ctx, 0, spectra.TechniqueReflectance)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Пигменты загружаются только для найденных эталонов; архивные не возвращаются
+	// и отсеиваются candidateReferences
+	var ids []uint
+	seen := make(map[uint]bool)
+	for _, reference := range stored {
+		if !seen[reference.PigmentID] {
+			seen[reference.PigmentID] = true
+			ids = append(ids, reference.PigmentID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	pigments, err := h.Pigments.ListPigments(ctx, repository.PigmentQuery{IDs: ids})
+	if err != nil {
+		return nil, err
+	}
+	return candidateReferences(stored, pigments), nil
+}
+
+// candidateReferences оставляет эталоны пигментов-кандидатов и разбирает их спектры.
+// Испорченные записи пропускаются: они не должны ломать идентификацию
+func candidateReferences(stored []ds.ReferenceSpectrum, pigments []ds.Pigment) []identify.Reference {
+	names := make(map[uint]string, len(pigments))
+	for _, pigment := range pigments {
+		names[pigment.ID] = pigment.Name
+	}
+
+	var references []identify.Reference
+	for _, reference := range stored {
+		name, ok := names[reference.PigmentID]
+		if !ok {
+			continue
+		}
+		spectrum, err := spectra.Parse(reference.Spectrum)
+		if err != nil {
+			continue
+		}
+		references = append(references, identify.Reference{PigmentID: reference.PigmentID, Name: name, Spectrum: spectrum})
+	}
+	return references
+}
+
+// preprocessForMatching применяет предобработку заявки к образцу и эталонам, чтобы
+// сравнивать кривые в одинаковом виде. Если образец обработать не удалось,
+// сравниваются исходные кривые; эталоны, к которым шаги не подходят, пропускаются
+func preprocessForMatching(pipeline spectra.Pipeline, sample spectra.Spectrum, references []identify.Reference) (spectra.Spectrum, []identify.Reference) {
+	if len(pipeline) == 0 {
+		return sample, references
+	}
+	processedSample, err := pipeline.Apply(sample)
+	if err != nil {
+		return sample, references
+	}
+
+	var processed []identify.Reference
+	for _, reference := range references {
+		spectrum, err := pipeline.Apply(reference.Spectrum)
+		if err != nil {
+			continue
+		}
+		reference.Spectrum = spectrum
+		processed = append(processed, reference)
+	}
+	return processedSample, processed
+}
+
+func roundScore(score float64) float64 {
+	return math.Round(score*1000) / 1000
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/spectra"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InstrumentHandler struct {
+	Instruments repository.InstrumentStore
+}
+
+func NewInstrumentHandler(instruments repository.InstrumentStore) *InstrumentHandler {
+	return &InstrumentHandler{Instruments: instruments}
+}
+
+// GET /api/instruments - реестр приборов
+func (h *InstrumentHandler) GetInstruments(c *gin.Context) {
+	instruments, err := h.Instruments.ListInstruments(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения приборов"))
+		return
+	}
+
+	response := make([]types.InstrumentResponse, len(instruments))
+	for i, instrument := range instruments {
+		response[i] = newInstrumentResponse(instrument)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"instruments": response,
+		"count":       len(response),
+	})
+}
+
+// GET /api/instruments/:id - прибор с историей калибровок
+func (h *InstrumentHandler) GetInstrument(c *gin.Context) {
+	id, ok := parseInstrumentID(c)
+	if !ok {
+		return
+	}
+
+	instrument, err := h.Instruments.GetInstrument(c.Request.Context(), id)
+	if err != nil {
+		respondInstrumentError(c, err, "Ошибка получения прибора")
+		return
+	}
+	calibrations, err := h.Instruments.ListCalibrations(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения прибора"))
+		return
+	}
+
+	response := newInstrumentResponse(*instrument)
+	for _, calibration := range calibrations {
+		response.Calibrations = append(response.Calibrations, newCalibrationResponse(calibration))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"instrument": response,
+	})
+}
+
+// POST /api/instruments - регистрация прибора (модератор)
+func (h *InstrumentHandler) CreateInstrument(c *gin.Context) {
+	var request types.CreateInstrumentRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Название, серийный номер и методика обязательны"))
+		return
+	}
+
+	instrument := ds.Instrument{
+		Name:       strings.TrimSpace(request.Name),
+		Serial:     strings.TrimSpace(request.Serial),
+		Technique:  request.Technique,
+		RangeFrom:  request.RangeFrom,
+		RangeTo:    request.RangeTo,
+		Resolution: request.Resolution,
+		Note:       strings.TrimSpace(request.Note),
+	}
+	if err := validateInstrument(instrument); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail(err.Error()))
+		return
+	}
+	if err := h.Instruments.CreateInstrument(c.Request.Context(), &instrument); err != nil {
+		respondInstrumentError(c, err, "Ошибка регистрации прибора")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"instrument": newInstrumentResponse(instrument),
+	})
+}
+
+// PUT /api/instruments/:id - обновление прибора (модератор)
+func (h *InstrumentHandler) UpdateInstrument(c *gin.Context) {
+	id, ok := parseInstrumentID(c)
+	if !ok {
+		return
+	}
+
+	var request types.UpdateInstrumentRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный формат данных"))
+		return
+	}
+
+	instrument, err := h.Instruments.GetInstrument(c.Request.Context(), id)
+	if err != nil {
+		respondInstrumentError(c, err, "Ошибка обновления прибора")
+		return
+	}
+
+	// Обновляем только переданные поля
+	if name := strings.TrimSpace(request.Name); name != "" {
+		instrument.Name = name
+	}
+	if serial := strings.TrimSpace(request.Serial); serial != "" {
+		instrument.Serial = serial
+	}
+	if request.RangeFrom != nil {
+		instrument.RangeFrom = *request.RangeFrom
+	}
+	if request.RangeTo != nil {
+		instrument.RangeTo = *request.RangeTo
+	}
+	if request.Resolution != nil {
+		instrument.Resolution = *request.Resolution
+	}
+	if request.Note != nil {
+		instrument.Note = strings.TrimSpace(*request.Note)
+	}
+	if err := validateInstrument(*instrument); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail(err.Error()))
+		return
+	}
+
+	if err := h.Instruments.UpdateInstrument(c.Request.Context(), instrument); err != nil {
+		respondInstrumentError(c, err, "Ошибка обновления прибора")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"instrument": newInstrumentResponse(*instrument),
+	})
+}
+
+// POST /api/instruments/:id/calibrations - добавить калибровку (модератор).
+// Калибровки не удаляются: по ним исправлены уже загруженные измерения
+func (h *InstrumentHandler) AddCalibration(c *gin.Context) {
+	id, ok := parseInstrumentID(c)
+	if !ok {
+		return
+	}
+
+	var request types.AddCalibrationRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Начало действия калибровки обязательно"))
+		return
+	}
+
+	instrument, err := h.Instruments.GetInstrument(c.Request.Context(), id)
+	if err != nil {
+		respondInstrumentError(c, err, "Ошибка добавления калибровки")
+		return
+	}
+
+	calibration := ds.InstrumentCalibration{
+		InstrumentID:     id,
+		ValidFrom:        request.ValidFrom,
+		ValidTo:          request.ValidTo,
+		WhiteReflectance: request.WhiteReflectance,
+		Note:             strings.TrimSpace(request.Note),
+	}
+	if calibration.ValidTo != nil && !calibration.ValidTo.After(calibration.ValidFrom) {
+		c.JSON(http.StatusBadRequest, types.Fail("Окончание действия калибровки должно быть позже начала"))
+		return
+	}
+	for _, field := range []struct {
+		name   string
+		text   string
+		target *string
+	}{
+		{"темновой спектр", request.Dark, &calibration.Dark},
+		{"спектр белого эталона", request.White, &calibration.White},
+		{"эталонные линии", request.Wavelengths, &calibration.Wavelengths},
+	} {
+		if strings.TrimSpace(field.text) == "" {
+			continue
+		}
+		spectrum, err := spectra.Parse(field.text)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, types.Fail(fmt.Sprintf("Неверный %s: %v", field.name, err)))
+			return
+		}
+		*field.target = spectrum.String()
+	}
+
+	if err := validateCalibration(*instrument, calibration); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail(err.Error()))
+		return
+	}
+
+	if err := h.Instruments.AddCalibration(c.Request.Context(), &calibration); err != nil {
+		respondInstrumentError(c, err, "Ошибка добавления калибровки")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"calibration": newCalibrationResponse(calibration),
+	})
+}
+
+// validateInstrument проверяет методику и рабочий диапазон прибора
+func validateInstrument(instrument ds.Instrument) error {
+	axis, ok := spectra.TechniqueAxis(instrument.Technique)
+	switch {
+	case instrument.Name == "" || instrument.Serial == "":
+		return errors.New("Название и серийный номер обязательны")
+	case !ok:
+		return errors.New("Неизвестная методика: ожидается reflectance, raman или xrf")
+	case instrument.RangeFrom >= instrument.RangeTo:
+		return errors.New("Начало рабочего диапазона должно быть меньше конца")
+	case instrument.RangeFrom < axis.Min || instrument.RangeTo > axis.Max:
+		return fmt.Errorf("Рабочий диапазон должен лежать в пределах %g-%g %s", axis.Min, axis.Max, axis.Unit)
+	case instrument.Resolution < 0 || instrument.Resolution > instrument.RangeTo-instrument.RangeFrom:
+		return errors.New("Неверное спектральное разрешение")
+	}
+	return nil
+}
+
+// validateCalibration проверяет, что калибровкой можно исправить любое измерение
+// в рабочем диапазоне прибора
+func validateCalibration(instrument ds.Instrument, stored ds.InstrumentCalibration) error {
+	if stored.Dark == "" && stored.White == "" && stored.Wavelengths == "" {
+		return errors.New("Калибровка должна содержать темновой спектр, спектр белого эталона или эталонные линии")
+	}
+	if instrument.Technique == spectra.TechniqueReflectance && stored.White == "" {
+		return errors.New("Для спектров отражения нужен спектр белого эталона")
+	}
+
+	calibration, err := toCalibration(stored)
+	if err != nil {
+		return errors.New("Неверный спектр калибровки")
+	}
+	for _, reference := range []struct {
+		name     string
+		spectrum spectra.Spectrum
+	}{
+		{"Темновой спектр", calibration.Dark},
+		{"Спектр белого эталона", calibration.White},
+	} {
+		if reference.spectrum == nil {
+			continue
+		}
+		if from, to := reference.spectrum.Range(); from > instrument.RangeFrom || to < instrument.RangeTo {
+			return fmt.Errorf("%s должен покрывать рабочий диапазон прибора %g-%g", reference.name, instrument.RangeFrom, instrument.RangeTo)
+		}
+	}
+	if err := calibration.Validate(); err != nil {
+		return errors.New("Неверная калибровка: " + err.Error())
+	}
+	return nil
+}
+
+// toCalibration разбирает сохранённую калибровку
+func toCalibration(stored ds.InstrumentCalibration) (spectra.Calibration, error) {
+	calibration := spectra.Calibration{WhiteReflectance: stored.WhiteReflectance}
+	for _, field := range []struct {
+		text   string
+		target *spectra.Spectrum
+	}{
+		{stored.Dark, &calibration.Dark},
+		{stored.White, &calibration.White},
+		{stored.Wavelengths, &calibration.Wavelengths},
+	} {
+		if field.text == "" {
+			continue
+		}
+		spectrum, err := spectra.Parse(field.text)
+		if err != nil {
+			return spectra.Calibration{}, err
+		}
+		*field.target = spectrum
+	}
+	return calibration, nil
+}
+
+func parseInstrumentID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный ID прибора"))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondInstrumentError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, types.Fail("Прибор не найден"))
+	case errors.Is(err, repository.ErrAlreadyExists):
+		c.JSON(http.StatusConflict, types.Fail("Прибор с таким серийным номером уже зарегистрирован"))
+	default:
+		c.JSON(http.StatusInternalServerError, types.Fail(message))
+	}
+}
+
+func newInstrumentResponse(instrument ds.Instrument) types.InstrumentResponse {
+	axis, _ := spectra.TechniqueAxis(instrument.Technique)
+	return types.InstrumentResponse{
+		ID:         instrument.ID,
+		Name:       instrument.Name,
+		Serial:     instrument.Serial,
+		Technique:  instrument.Technique,
+		Unit:       axis.Unit,
+		RangeFrom:  instrument.RangeFrom,
+		RangeTo:    instrument.RangeTo,
+		Resolution: instrument.Resolution,
+		Note:       instrument.Note,
+		CreatedAt:  instrument.CreatedAt,
+		UpdatedAt:  instrument.UpdatedAt,
+	}
+}
+
+func newCalibrationResponse(calibration ds.InstrumentCalibration) types.CalibrationResponse {
+	return types.CalibrationResponse{
+		ID:               calibration.ID,
+		InstrumentID:     calibration.InstrumentID,
+		ValidFrom:        calibration.ValidFrom,
+		ValidTo:          calibration.ValidTo,
+		Dark:             calibration.Dark,
+		White:            calibration.White,
+		WhiteReflectance: calibration.WhiteReflectance,
+		Wavelengths:      calibration.Wavelengths,
+		Note:             calibration.Note,
+		CreatedAt:        calibration.CreatedAt,
+	}
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/interactions"
+	"colorLex/internal/app/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Ограничения правила взаимодействия
+const (
+	maxInteractionProducts = 20
+	maxProductLength       = 200
+)
+
+// GET /api/pigments/interactions - справочник взаимодействий пигментов
+// (pigment_id - только правила с участием пигмента)
+func (h *PigmentHandler) GetInteractions(c *gin.Context) {
+	var pigmentID uint
+	if raw := c.Query("pigment_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, types.Fail("Неверный ID пигмента"))
+			return
+		}
+		pigmentID = uint(id)
+	}
+
+	rules, err := h.Pigments.ListInteractions(c.Request.Context(), pigmentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения взаимодействий"))
+		return
+	}
+
+	response := make([]types.PigmentInteractionResponse, len(rules))
+	for i, rule := range rules {
+		response[i] = newInteractionResponse(rule)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"interactions": response,
+		"count":        len(response),
+	})
+}
+
+// POST /api/pigments/interactions - добавить правило взаимодействия (модератор)
+func (h *PigmentHandler) CreateInteraction(c *gin.Context) {
+	var request types.CreatePigmentInteractionRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Пигмент, серьёзность и объяснение обязательны"))
+		return
+	}
+
+	rule := ds.PigmentInteraction{
+		PigmentID:      request.PigmentID,
+		OtherPigmentID: request.OtherPigmentID,
+		Severity:       request.Severity,
+		Explanation:    strings.TrimSpace(request.Explanation),
+		Products:       interactions.JoinProducts(request.Products),
+	}
+	if rule.OtherPigmentID != nil {
+		if *rule.OtherPigmentID == rule.PigmentID {
+			c.JSON(http.StatusBadRequest, types.Fail("Пигменты пары должны различаться"))
+			return
+		}
+		// Пара хранится один раз: меньший ID первым
+		if *rule.OtherPigmentID < rule.PigmentID {
+			other := rule.PigmentID
+			rule.PigmentID, rule.OtherPigmentID = *rule.OtherPigmentID, &other
+		}
+	}
+	if err := validateInteraction(rule); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail(err.Error()))
+		return
+	}
+
+	// Новые правила - только для пигментов каталога
+	response := repository.PigmentInteraction{Rule: rule}
+	pigment, err := h.Pigments.GetPigment(c.Request.Context(), rule.PigmentID, false)
+	if err != nil {
+		respondPigmentError(c, err, "Ошибка добавления взаимодействия")
+		return
+	}
+	response.Pigment = *pigment
+	if rule.OtherPigmentID != nil {
+		if response.Other, err = h.Pigments.GetPigment(c.Request.Context(), *rule.OtherPigmentID, false); err != nil {
+			respondPigmentError(c, err, "Ошибка добавления взаимодействия")
+			return
+		}
+	}
+
+	if err := h.Pigments.CreateInteraction(c.Request.Context(), &rule); err != nil {
+		respondInteractionError(c, err, "Ошибка добавления взаимодействия")
+		return
+	}
+	response.Rule = rule
+
+	c.JSON(http.StatusCreated, gin.H{
+		"interaction": newInteractionResponse(response),
+	})
+}
+
+// PUT /api/pigments/interactions/:id - изменить правило взаимодействия (модератор)
+func (h *PigmentHandler) UpdateInteraction(c *gin.Context) {
+	id, ok := parseInteractionID(c)
+	if !ok {
+		return
+	}
+
+	var request types.UpdatePigmentInteractionRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный формат данных"))
+		return
+	}
+
+	interaction, err := h.Pigments.GetInteraction(c.Request.Context(), id)
+	if err != nil {
+		respondInteractionError(c, err, "Ошибка обновления взаимодействия")
+		return
+	}
+
+	// Обновляем только переданные поля
+	rule := &interaction.Rule
+	if request.Severity != "" {
+		rule.Severity = request.Severity
+	}
+	if explanation := strings.TrimSpace(request.Explanation); explanation != "" {
+		rule.Explanation = explanation
+	}
+	if request.Products != nil {
+		rule.Products = interactions.JoinProducts(*request.Products)
+	}
+	if err := validateInteraction(*rule); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail(err.Error()))
+		return
+	}
+
+	if err := h.Pigments.UpdateInteraction(c.Request.Context(), rule); err != nil {
+		respondInteractionError(c, err, "Ошибка обновления взаимодействия")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"interaction": newInteractionResponse(*interaction),
+	})
+}
+
+// DELETE /api/pigments/interactions/:id - удалить правило взаимодействия (модератор)
+func (h *PigmentHandler) DeleteInteraction(c *gin.Context) {
+	id, ok := parseInteractionID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Pigments.DeleteInteraction(c.Request.Context(), id); err != nil {
+		respondInteractionError(c, err, "Ошибка удаления взаимодействия")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Взаимодействие удалено",
+	})
+}
+
+// validateInteraction проверяет серьёзность, объяснение и продукты деградации правила
+func validateInteraction(rule ds.PigmentInteraction) error {
+	products := interactions.Products(rule)
+	switch {
+	case !slices.Contains(ds.InteractionSeverities, rule.Severity):
+		return errors.New("Неизвестная серьёзность: ожидается info, warning или danger")
+	case rule.Explanation == "":
+		return errors.New("Объяснение обязательно")
+	case len(products) > maxInteractionProducts:
+		return errors.New("Слишком много продуктов деградации")
+	}
+	for _, product := range products {
+		if len([]rune(product)) > maxProductLength {
+			return errors.New("Слишком длинное название продукта деградации")
+		}
+	}
+	return nil
+}
+
+// interactionWarnings находит правила, которые срабатывают на пигментах заявки, и собирает
+// продукты деградации, которые стоит поискать в пробе. Пигменты, отклонённые модератором,
+// и пигменты завершённой заявки без доли не учитываются
+func (h *SpectrumAnalysisHandler) interactionWarnings(ctx context.Context, analysis *ds.SpectrumAnalysis, analysisPigments []repository.AnalysisPigment) ([]types.InteractionWarning, []string, error) {
+	present := make(map[uint]bool)
+	for _, ap := range analysisPigments {
+		if ap.Link.Verdict == ds.VerdictRejected || (analysis.Status == ds.StatusCompleted && ap.Link.Percent <= 0) {
+			continue
+		}
+		present[ap.Pigment.ID] = true
+	}
+	if len(present) == 0 {
+		return nil, nil, nil
+	}
+
+	rules, err := h.Pigments.ListInteractions(ctx, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	byID := make(map[uint]repository.PigmentInteraction, len(rules))
+	plain := make([]ds.PigmentInteraction, len(rules))
+	for i, rule := range rules {
+		byID[rule.Rule.ID] = rule
+		plain[i] = rule.Rule
+	}
+
+	matched := interactions.Match(plain, present)
+	warnings := make([]types.InteractionWarning, len(matched))
+	for i, rule := range matched {
+		response := newInteractionResponse(byID[rule.ID])
+		warnings[i] = types.InteractionWarning{
+			InteractionID: rule.ID,
+			Severity:      rule.Severity,
+			Pigments:      response.Pigments,
+			Explanation:   rule.Explanation,
+			Products:      response.Products,
+		}
+	}
+	return warnings, interactions.CheckFor(matched), nil
+}
+
+func parseInteractionID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный ID взаимодействия"))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondInteractionError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, types.Fail("Взаимодействие не найдено"))
+	case errors.Is(err, repository.ErrAlreadyExists):
+		c.JSON(http.StatusConflict, types.Fail("Правило для этих пигментов уже есть"))
+	default:
+		c.JSON(http.StatusInternalServerError, types.Fail(message))
+	}
+}
+
+func newInteractionResponse(interaction repository.PigmentInteraction) types.PigmentInteractionResponse {
+	response := types.PigmentInteractionResponse{
+		ID:          interaction.Rule.ID,
+		Pigments:    []types.InteractionPigment{newInteractionPigment(interaction.Pigment)},
+		Severity:    interaction.Rule.Severity,
+		Explanation: interaction.Rule.Explanation,
+		Products:    interactions.Products(interaction.Rule),
+		CreatedAt:   interaction.Rule.CreatedAt,
+		UpdatedAt:   interaction.Rule.UpdatedAt,
+	}
+	if interaction.Other != nil {
+		response.Pigments = append(response.Pigments, newInteractionPigment(*interaction.Other))
+	}
+	return response
+}
+
+func newInteractionPigment(pigment ds.Pigment) types.InteractionPigment {
+	return types.InteractionPigment{
+		ID:       pigment.ID,
+		Name:     pigment.Name,
+		Archived: pigment.DeletedAt.Valid,
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PUT /api/spectrum-analysis/:id/point - отметить место отбора пробы на фотографии произведения
+func (h *SpectrumAnalysisHandler) SetMeasurementPoint(c *gin.Context) {
+	var request types.SetMeasurementPointRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Координаты x и y обязательны"))
+		return
+	}
+	if *request.X < 0 || *request.X > 1 || *request.Y < 0 || *request.Y > 1 {
+		c.JSON(http.StatusBadRequest, types.Fail("Координаты задаются долями размера изображения, от 0 до 1"))
+		return
+	}
+
+	analysis, ok := h.loadEditableDraft(c, "Ошибка сохранения точки отбора пробы")
+	if !ok {
+		return
+	}
+	if analysis.ArtworkID == nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Сначала привяжите заявку к произведению"))
+		return
+	}
+
+	point := ds.MeasurementPoint{
+		ArtworkID: *analysis.ArtworkID,
+		X:         *request.X,
+		Y:         *request.Y,
+		Label:     strings.TrimSpace(request.Label),
+		Layer:     strings.TrimSpace(request.Layer),
+	}
+	if err := h.Analyses.SaveMeasurementPoint(c.Request.Context(), analysis, &point); err != nil {
+		respondAnalysisWriteError(c, err, "Ошибка сохранения точки отбора пробы")
+		return
+	}
+
+	setAnalysisETag(c, analysis)
+	c.JSON(http.StatusOK, gin.H{
+		"point": newMeasurementPoint(point),
+	})
+}
+
+// DELETE /api/spectrum-analysis/:id/point - убрать точку отбора пробы
+func (h *SpectrumAnalysisHandler) DeleteMeasurementPoint(c *gin.Context) {
+	analysis, ok := h.loadEditableDraft(c, "Ошибка удаления точки отбора пробы")
+	if !ok {
+		return
+	}
+
+	if _, err := h.Analyses.GetMeasurementPoint(c.Request.Context(), analysis.ID); errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, types.Fail("Точка отбора пробы не задана"))
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка удаления точки отбора пробы"))
+		return
+	}
+
+	if err := h.Analyses.DeleteMeasurementPoint(c.Request.Context(), analysis); err != nil {
+		respondAnalysisWriteError(c, err, "Ошибка удаления точки отбора пробы")
+		return
+	}
+
+	setAnalysisETag(c, analysis)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Точка отбора пробы удалена",
+	})
+}
+
+// loadEditableDraft загружает черновик текущего пользователя и проверяет If-Match.
+// При ошибке сам отвечает клиенту
+func (h *SpectrumAnalysisHandler) loadEditableDraft(c *gin.Context, failMessage string) (*ds.SpectrumAnalysis, bool) {
+	analysis, ok := h.loadAnalysis(c, failMessage)
+	if !ok {
+		return nil, false
+	}
+	if analysis.CreatorID != c.GetUint("user_id") {
+		c.JSON(http.StatusForbidden, types.Fail("Недостаточно прав"))
+		return nil, false
+	}
+	if !checkIfMatch(c, analysis) {
+		return nil, false
+	}
+	if analysis.Status != ds.StatusDraft {
+		c.JSON(http.StatusBadRequest, types.Fail("Можно изменять только заявки в статусе черновика"))
+		return nil, false
+	}
+	return analysis, true
+}
+
+// loadMeasurementPoint возвращает точку заявки, если она отмечена на текущем произведении заявки
+func (h *SpectrumAnalysisHandler) loadMeasurementPoint(c *gin.Context, analysis *ds.SpectrumAnalysis) (*types.MeasurementPoint, error) {
+	if analysis.ArtworkID == nil {
+		return nil, nil
+	}
+	point, err := h.Analyses.GetMeasurementPoint(c.Request.Context(), analysis.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if point.ArtworkID != *analysis.ArtworkID {
+		return nil, nil
+	}
+	response := newMeasurementPoint(*point)
+	return &response, nil
+}
+
+// GET /api/artworks/:id/points - точки отбора проб произведения с преобладающим пигментом для карты
+func (h *ArtworkHandler) GetArtworkPoints(c *gin.Context) {
+	id, ok := parseArtworkID(c)
+	if !ok {
+		return
+	}
+
+	if _, err := h.Artworks.GetArtwork(c.Request.Context(), id); err != nil {
+		respondArtworkError(c, err, "Ошибка получения точек отбора проб")
+		return
+	}
+
+	points, err := h.Artworks.ListArtworkPoints(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения точек отбора проб"))
+		return
+	}
+
+	response := make([]types.ArtworkPointResponse, 0, len(points))
+	for _, p := range points {
+		analysis := p.Analysis
+		if !analysisVisible(c, &analysis) {
+			continue
+		}
+
+		item := types.ArtworkPointResponse{
+			AnalysisID:       analysis.ID.String(),
+			AnalysisName:     analysis.Name,
+			Status:           analysis.Status,
+			MeasurementPoint: newMeasurementPoint(p.Point),
+		}
+		if p.Dominant != nil {
+			item.DominantPigment = &types.DominantPigment{
+				PigmentID: p.Dominant.Pigment.ID,
+				Name:      p.Dominant.Pigment.Name,
+				Color:     p.Dominant.Pigment.Color,
+				Percent:   p.Dominant.Link.Percent,
+			}
+		}
+		response = append(response, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"points": response,
+		"count":  len(response),
+	})
+}
+
+func newMeasurementPoint(point ds.MeasurementPoint) types.MeasurementPoint {
+	return types.MeasurementPoint{
+		X:     point.X,
+		Y:     point.Y,
+		Label: point.Label,
+		Layer: point.Layer,
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/colorimetry"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/spectra"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// POST /api/spectra/metamerism - различие цвета двух образцов под D65, A и F11
+// и индекс метамерии: совпадёт ли подобранная смесь с оригиналом при другом освещении
+func (h *SpectrumAnalysisHandler) CompareMetamerism(c *gin.Context) {
+	var request types.MetamerismRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный формат данных"))
+		return
+	}
+
+	reference := colorimetry.D65
+	if request.Reference != "" {
+		var ok bool
+		if reference, ok = colorimetry.IlluminantByName(request.Reference); !ok {
+			if reason, known := colorimetry.Unsupported[strings.ToUpper(request.Reference)]; known {
+				c.JSON(http.StatusBadRequest, types.Fail("Источник "+strings.ToUpper(request.Reference)+" не поддерживается: "+reason))
+				return
+			}
+			c.JSON(http.StatusBadRequest, types.Fail("Неизвестный источник: ожидается D65, A или F11"))
+			return
+		}
+	}
+
+	first, ok := h.metamerismSample(c, request.First, "первого")
+	if !ok {
+		return
+	}
+	second, ok := h.metamerismSample(c, request.Second, "второго")
+	if !ok {
+		return
+	}
+
+	result, err := colorimetry.Compare(first, second, reference, colorimetry.Illuminants)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Спектр образца не попадает в видимый диапазон"))
+		return
+	}
+	firstColor, _ := colorimetry.FromReflectance(first)
+	secondColor, _ := colorimetry.FromReflectance(second)
+
+	response := types.MetamerismResponse{
+		Reference:       reference.Name,
+		MetamerismIndex: roundTo(result.Index, 2),
+		FirstColor:      newSpectrumColor(firstColor),
+		SecondColor:     newSpectrumColor(secondColor),
+	}
+	if result.Worst != nil {
+		response.Worst = result.Worst.Name
+	}
+	for _, d := range result.Differences {
+		response.Illuminants = append(response.Illuminants, types.IlluminantDifference{
+			Illuminant:      d.Illuminant.Name,
+			Description:     d.Illuminant.Description,
+			FirstLab:        newLab(d.First),
+			SecondLab:       newLab(d.Second),
+			DeltaE:          roundTo(d.DeltaE, 2),
+			MetamerismIndex: roundTo(d.Index, 2),
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// metamerismSample получает спектр отражения образца из спектра, заявки или смеси.
+// ok = false - ответ с ошибкой уже отправлен
+func (h *SpectrumAnalysisHandler) metamerismSample(c *gin.Context, sample types.MetamerismSample, which string) (spectra.Spectrum, bool) {
+	given := 0
+	for _, set := range []bool{strings.TrimSpace(sample.Spectrum) != "", sample.AnalysisID != "", len(sample.Mixture) > 0} {
+		if set {
+			given++
+		}
+	}
+	if given != 1 {
+		c.JSON(http.StatusBadRequest, types.Fail("Для "+which+" образца укажите ровно одно: спектр, заявку или смесь"))
+		return nil, false
+	}
+
+	switch {
+	case sample.AnalysisID != "":
+		return h.analysisReflectance(c, sample.AnalysisID)
+	case len(sample.Mixture) > 0:
+		return predictMixture(c, h.Pigments, sample.Mixture, "Ошибка сравнения образцов")
+	}
+	spectrum, err := spectra.ParseTechnique(sample.Spectrum, spectra.TechniqueReflectance)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный формат спектра "+which+" образца: "+err.Error()))
+		return nil, false
+	}
+	return spectrum, true
+}
+
+// analysisReflectance - усреднённый спектр отражения заявки без отбракованных измерений
+func (h *SpectrumAnalysisHandler) analysisReflectance(c *gin.Context, rawID string) (spectra.Spectrum, bool) {
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный ID заявки"))
+		return nil, false
+	}
+	analysis, err := h.Analyses.GetAnalysis(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && analysis.Status == ds.StatusDeleted) {
+		c.JSON(http.StatusNotFound, types.Fail("Заявка не найдена"))
+		return nil, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка сравнения образцов"))
+		return nil, false
+	}
+
+	readings, err := h.Analyses.ListReadings(c.Request.Context(), analysis.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка сравнения образцов"))
+		return nil, false
+	}
+	aggregate := combineAnalysisSpectra(analysis, readings)
+	if aggregate == nil {
+		c.JSON(http.StatusBadRequest, types.Fail("В заявке нет спектра отражения"))
+		return nil, false
+	}
+	return aggregate.Mean, true
+}
+
+func newLab(lab colorimetry.Lab) [3]float64 {
+	return [3]float64{roundTo(lab.L, 2), roundTo(lab.A, 2), roundTo(lab.B, 2)}
+}
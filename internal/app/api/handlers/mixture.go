@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/colorimetry"
+	"colorLex/internal/app/mixing"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/spectra"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxMixtureComponents - сколько пигментов можно смешать в одном прогнозе
+const maxMixtureComponents = 10
+
+// POST /api/spectra/mixture - прогноз спектра и цвета смеси пигментов по модели Кубелки-Мунка
+func (h *PigmentHandler) PredictMixture(c *gin.Context) {
+	var request types.MixtureRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Укажите пигменты смеси и их доли"))
+		return
+	}
+	var measured spectra.Spectrum
+	if request.Measured != "" {
+		var err error
+		measured, err = spectra.Parse(request.Measured)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, types.Fail("Неверный формат измеренного спектра: "+err.Error()))
+			return
+		}
+	}
+
+	predicted, ok := predictMixture(c, h.Pigments, request.Components, "Ошибка расчёта смеси")
+	if !ok {
+		return
+	}
+	color, err := colorimetry.FromReflectance(predicted)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Эталонные спектры не покрывают видимый диапазон"))
+		return
+	}
+
+	response := gin.H{
+		"predicted": newProcessedSpectrum(predicted),
+		"spectrum":  predicted.String(),
+		"color":     newSpectrumColor(color),
+	}
+	if measured != nil {
+		if measuredColor, err := colorimetry.FromReflectance(measured); err == nil {
+			response["measured_color"] = newSpectrumColor(measuredColor)
+		}
+		if rmse, ok := spectrumRMSE(predicted, measured); ok {
+			response["rmse"] = rmse
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// predictMixture проверяет состав смеси и прогнозирует её спектр отражения.
+// ok = false - ответ с ошибкой уже отправлен
+func predictMixture(c *gin.Context, pigments repository.PigmentStore, request []types.MixtureComponent, failMessage string) (spectra.Spectrum, bool) {
+	if len(request) == 0 || len(request) > maxMixtureComponents {
+		c.JSON(http.StatusBadRequest, types.Fail("В смеси должно быть от 1 до 10 пигментов"))
+		return nil, false
+	}
+
+	ctx := c.Request.Context()
+	seen := make(map[uint]bool, len(request))
+	components := make([]mixing.Component, 0, len(request))
+	for _, component := range request {
+		if component.Percent <= 0 || component.Percent > 100 {
+			c.JSON(http.StatusBadRequest, types.Fail("Доля пигмента должна быть от 0 до 100%"))
+			return nil, false
+		}
+		if seen[component.PigmentID] {
+			c.JSON(http.StatusBadRequest, types.Fail("Пигмент указан в смеси дважды"))
+			return nil, false
+		}
+		seen[component.PigmentID] = true
+
+		// Архивные пигменты тоже можно смешивать: они остаются в завершённых заявках
+		pigment, err := pigments.GetPigment(ctx, component.PigmentID, true)
+		if err != nil {
+			respondPigmentError(c, err, failMessage)
+			return nil, false
+		}
+		reference, err := reflectanceReference(ctx, pigments, pigment.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, types.Fail(failMessage))
+			return nil, false
+		}
+		if reference == nil {
+			c.JSON(http.StatusBadRequest, types.Fail("У пигмента «"+pigment.Name+"» нет эталонного спектра отражения"))
+			return nil, false
+		}
+		components = append(components, mixing.Component{PigmentID: pigment.ID, Amount: component.Percent, Spectrum: reference})
+	}
+
+	predicted, err := mixing.Predict(components)
+	if errors.Is(err, mixing.ErrNoOverlap) {
+		c.JSON(http.StatusBadRequest, types.Fail("Эталонные спектры пигментов не пересекаются по длинам волн"))
+		return nil, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail(failMessage))
+		return nil, false
+	}
+	return predicted, true
+}
+
+// reflectanceReference - первый читаемый эталон отражения пигмента; nil, если эталона нет
+func reflectanceReference(ctx context.Context, pigments repository.PigmentStore, pigmentID uint) (spectra.Spectrum, error) {
+	references, err := pigments.ListReferenceSpectra(ctx, pigmentID, spectra.TechniqueReflectance)
+	if err != nil {
+		return nil, err
+	}
+	for _, reference := range references {
+		if spectrum, err := spectra.Parse(reference.Spectrum); err == nil {
+			return spectrum, nil
+		}
+	}
+	return nil, nil
+}
+
+// spectrumRMSE - среднеквадратичное расхождение прогноза и измерения на точках прогноза,
+// попадающих в диапазон измерения
+func spectrumRMSE(predicted, measured spectra.Spectrum) (float64, bool) {
+	var squares float64
+	var count int
+	for _, p := range predicted {
+		if value, ok := measured.At(p.Wavelength); ok {
+			squares += (p.Value - value) * (p.Value - value)
+			count++
+		}
+	}
+	if count < 3 {
+		return 0, false
+	}
+	return roundTo(math.Sqrt(squares/float64(count)), 4), true
+}
+
+func newSpectrumColor(color colorimetry.Color) types.SpectrumColor {
+	return types.SpectrumColor{
+		Hex:        color.Hex,
+		XYZ:        [3]float64{color.XYZ.X, color.XYZ.Y, color.XYZ.Z},
+		Lab:        [3]float64{color.Lab.L, color.Lab.A, color.Lab.B},
+		OutOfGamut: color.OutOfGamut,
+	}
+}
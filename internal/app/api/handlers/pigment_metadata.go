@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// PB29, PY43, PBr7:1, PBk 11
+	ciNamePattern   = regexp.MustCompile(`^P(BK|BR|B|R|Y|G|O|V|W|M)(\d{1,3})(:\d+)?$`)
+	ciNumberPattern = regexp.MustCompile(`^\d{5}(:\d+)?$`)
+	casPattern      = regexp.MustCompile(`^(\d{2,7})-(\d{2})-(\d)$`)
+)
+
+// GET /api/pigments/vocabulary - допустимые значения структурированных полей
+func (h *PigmentHandler) GetPigmentVocabulary(c *gin.Context) {
+	c.JSON(http.StatusOK, types.PigmentVocabularyResponse{
+		Classes:       ds.PigmentClasses,
+		Toxicity:      ds.ToxicityLevels,
+		Lightfastness: []int{1, 2, 3, 4, 5},
+	})
+}
+
+// applyPigmentMetadata переносит в пигмент переданные поля метаданных; пустая строка
+// очищает текстовое поле, 0 - числовое. Возвращает, было ли что-то изменено, и ошибку
+// валидации с текстом для клиента
+func applyPigmentMetadata(pigment *ds.Pigment, meta types.PigmentMetadataRequest) (bool, error) {
+	updated := false
+
+	if meta.CIName != nil {
+		pigment.CIName = ""
+		if *meta.CIName != "" {
+			name, ok := normalizeCIName(*meta.CIName)
+			if !ok {
+				return false, errors.New("Неверное обозначение Colour Index, ожидается вида PB29")
+			}
+			pigment.CIName = name
+		}
+		updated = true
+	}
+	if meta.CINumber != nil {
+		if *meta.CINumber != "" && !ciNumberPattern.MatchString(*meta.CINumber) {
+			return false, errors.New("Неверный номер Colour Index, ожидается пять цифр")
+		}
+		pigment.CINumber = *meta.CINumber
+		updated = true
+	}
+	if meta.Formula != nil {
+		pigment.Formula = strings.TrimSpace(*meta.Formula)
+		updated = true
+	}
+	if meta.CASNumber != nil {
+		if *meta.CASNumber != "" && !validCASNumber(*meta.CASNumber) {
+			return false, errors.New("Неверный номер CAS")
+		}
+		pigment.CASNumber = *meta.CASNumber
+		updated = true
+	}
+	if meta.Class != nil {
+		if *meta.Class != "" && !slices.Contains(ds.PigmentClasses, *meta.Class) {
+			return false, errors.New("Неизвестный класс пигмента")
+		}
+		pigment.Class = *meta.Class
+		updated = true
+	}
+	if meta.Lightfastness != nil {
+		if *meta.Lightfastness < 0 || *meta.Lightfastness > 5 {
+			return false, errors.New("Светостойкость задаётся по шкале ASTM от 1 до 5")
+		}
+		pigment.Lightfastness = optionalInt(*meta.Lightfastness)
+		updated = true
+	}
+	if meta.Toxicity != nil {
+		if *meta.Toxicity != "" && !slices.Contains(ds.ToxicityLevels, *meta.Toxicity) {
+			return false, errors.New("Неизвестный уровень токсичности")
+		}
+		pigment.Toxicity = *meta.Toxicity
+		updated = true
+	}
+	if meta.ParticleSizeMin != nil {
+		pigment.ParticleSizeMin = optionalFloat(*meta.ParticleSizeMin)
+		updated = true
+	}
+	if meta.ParticleSizeMax != nil {
+		pigment.ParticleSizeMax = optionalFloat(*meta.ParticleSizeMax)
+		updated = true
+	}
+	if meta.ParticleShape != nil {
+		pigment.ParticleShape = *meta.ParticleShape
+		updated = true
+	}
+	if meta.AvailableFrom != nil {
+		pigment.AvailableFrom = optionalInt(*meta.AvailableFrom)
+		updated = true
+	}
+	if meta.AvailableTo != nil {
+		pigment.AvailableTo = optionalInt(*meta.AvailableTo)
+		updated = true
+	}
+	if pigment.AvailableFrom != nil && pigment.AvailableTo != nil && *pigment.AvailableFrom > *pigment.AvailableTo {
+		return false, errors.New("Год появления пигмента позже года выхода из употребления")
+	}
+
+	// Размер частиц проверяем после слияния: клиент может прислать только одну границу
+	if minSize := pigment.ParticleSizeMin; minSize != nil {
+		if *minSize <= 0 {
+			return false, errors.New("Размер частиц должен быть положительным")
+		}
+		if maxSize := pigment.ParticleSizeMax; maxSize != nil && *maxSize < *minSize {
+			return false, errors.New("Минимальный размер частиц больше максимального")
+		}
+	}
+	if maxSize := pigment.ParticleSizeMax; maxSize != nil && *maxSize <= 0 {
+		return false, errors.New("Размер частиц должен быть положительным")
+	}
+
+	return updated, nil
+}
+
+// optionalInt - значение числового поля метаданных: 0 очищает поле
+func optionalInt(value int) *int {
+	if value == 0 {
+		return nil
+	}
+	return &value
+}
+
+// optionalFloat - то же для дробных полей
+func optionalFloat(value float64) *float64 {
+	if value == 0 {
+		return nil
+	}
+	return &value
+}
+
+// normalizeCIName приводит обозначение к виду PB29 / PBk11 / PBr7:1
+func normalizeCIName(value string) (string, bool) {
+	compact := strings.ToUpper(strings.Join(strings.Fields(value), ""))
+	match := ciNamePattern.FindStringSubmatch(compact)
+	if match == nil {
+		return "", false
+	}
+	hue := match[1]
+	if len(hue) == 2 {
+		hue = hue[:1] + strings.ToLower(hue[1:])
+	}
+	return "P" + hue + match[2] + match[3], true
+}
+
+// validCASNumber проверяет формат и контрольную цифру номера CAS
+func validCASNumber(value string) bool {
+	match := casPattern.FindStringSubmatch(value)
+	if match == nil {
+		return false
+	}
+	digits := match[1] + match[2]
+	sum := 0
+	for i := range digits {
+		weight := len(digits) - i
+		sum += weight * int(digits[i]-'0')
+	}
+	return sum%10 == int(match[3][0]-'0')
+}
+
+// newPigmentMetadata сериализует структурированные поля пигмента
+func newPigmentMetadata(pigment ds.Pigment) types.PigmentMetadata {
+	return types.PigmentMetadata{
+		CIName:          pigment.CIName,
+		CINumber:        pigment.CINumber,
+		Formula:         pigment.Formula,
+		CASNumber:       pigment.CASNumber,
+		Class:           pigment.Class,
+		Lightfastness:   pigment.Lightfastness,
+		Toxicity:        pigment.Toxicity,
+		ParticleSizeMin: pigment.ParticleSizeMin,
+		ParticleSizeMax: pigment.ParticleSizeMax,
+		ParticleShape:   pigment.ParticleShape,
+		AvailableFrom:   pigment.AvailableFrom,
+		AvailableTo:     pigment.AvailableTo,
+	}
+}
@@ -1,27 +1,31 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"colorLex/internal/app/api/types"
 	"colorLex/internal/app/ds"
+	"colorLex/internal/app/refindex"
 	"colorLex/internal/app/repository"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 type PigmentHandler struct {
-	Repository *repository.Repository
+	Pigments   repository.PigmentStore
+	Analyses   repository.AnalysisStore
+	References *refindex.Index // обновляется при добавлении и удалении эталонов
 }
 
-func NewPigmentHandler(repo *repository.Repository) *PigmentHandler {
-	return &PigmentHandler{Repository: repo}
+func NewPigmentHandler(pigments repository.PigmentStore, analyses repository.AnalysisStore, references *refindex.Index) *PigmentHandler {
+	return &PigmentHandler{Pigments: pigments, Analyses: analyses, References: references}
 }
 
 // GET /api/pigments - список пигментов с фильтрацией
@@ -32,23 +36,58 @@ func (h *PigmentHandler) GetPigments(c *gin.Context) {
 		return
 	}
 
-	var pigments []ds.Pigment
-	db := h.Repository.GetDB()
+	query := repository.PigmentQuery{
+		Search:           filter.Search,
+		Color:            filter.Color,
+		Formula:          filter.Formula,
+		CASNumber:        filter.CASNumber,
+		MaxLightfastness: filter.MaxLightfastness,
+		AvailableIn:      filter.AvailableIn,
+		Limit:            filter.Limit,
+		Offset:           filter.Offset,
+	}
 
-	// Применяем фильтры
-	if filter.Search != "" {
-		db = db.Where("name ILIKE ?", "%"+filter.Search+"%")
+	// Фильтры по структурированным полям проверяем так же, как при записи
+	if filter.CIName != "" {
+		name, ok := normalizeCIName(filter.CIName)
+		if !ok {
+			c.JSON(http.StatusBadRequest, types.Fail("Неверный формат параметра ci_name"))
+			return
+		}
+		query.CIName = name
+	}
+	if filter.Class != "" && !slices.Contains(ds.PigmentClasses, filter.Class) {
+		c.JSON(http.StatusBadRequest, types.Fail("Неизвестный класс пигмента"))
+		return
+	}
+	query.Class = filter.Class
+	if filter.Toxicity != "" && !slices.Contains(ds.ToxicityLevels, filter.Toxicity) {
+		c.JSON(http.StatusBadRequest, types.Fail("Неизвестный уровень токсичности"))
+		return
 	}
-	if filter.Color != "" {
-		db = db.Where("color ILIKE ?", "%"+filter.Color+"%")
+	query.Toxicity = filter.Toxicity
+	if filter.MaxLightfastness < 0 || filter.MaxLightfastness > 5 {
+		c.JSON(http.StatusBadRequest, types.Fail("Параметр lightfastness_max задаётся от 1 до 5"))
+		return
+	}
+
+	// Архивные пигменты видны только модераторам
+	if filter.IncludeArchived {
+		if !c.GetBool("is_moderator") {
+			c.JSON(http.StatusForbidden, types.Fail("Недостаточно прав. Требуется роль модератора"))
+			return
+		}
+		query.IncludeArchived = true
 	}
+
+	// Применяем фильтры
 	if filter.DateFrom != "" {
 		from, err := time.Parse("2006-01-02", filter.DateFrom)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, types.Fail("Неверный формат параметра date_from"))
 			return
 		}
-		db = db.Where("created_at >= ?", from)
+		query.CreatedFrom = &from
 	}
 	if filter.DateTo != "" {
 		to, err := time.Parse("2006-01-02", filter.DateTo)
@@ -56,13 +95,12 @@ func (h *PigmentHandler) GetPigments(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, types.Fail("Неверный формат параметра date_to"))
 			return
 		}
-		db = db.Where("created_at < ?", to.AddDate(0, 0, 1))
+		to = to.AddDate(0, 0, 1)
+		query.CreatedTo = &to
 	}
 
-	// Пагинация
-	db = db.Limit(filter.Limit).Offset(filter.Offset)
-
-	if err := db.Find(&pigments).Error; err != nil {
+	pigments, err := h.Pigments.ListPigments(c.Request.Context(), query)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения пигментов"))
 		return
 	}
@@ -70,15 +108,7 @@ func (h *PigmentHandler) GetPigments(c *gin.Context) {
 	// Сериализация ответа
 	response := make([]types.PigmentResponse, len(pigments))
 	for i, pigment := range pigments {
-		response[i] = types.PigmentResponse{
-			ID:          pigment.ID,
-			Name:        pigment.Name,
-			Brief:       pigment.Brief,
-			Description: pigment.Description,
-			Color:       pigment.Color,
-			Specs:       pigment.Specs,
-			ImageKey:    pigment.ImageKey,
-		}
+		response[i] = newPigmentResponse(pigment)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -89,35 +119,20 @@ func (h *PigmentHandler) GetPigments(c *gin.Context) {
 
 // GET /api/pigments/:id - детали пигмента
 func (h *PigmentHandler) GetPigment(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, types.Fail("Неверный ID пигмента"))
+	id, ok := parsePigmentID(c)
+	if !ok {
 		return
 	}
 
-	var pigment ds.Pigment
-	if err := h.Repository.GetDB().Unscoped().First(&pigment, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, types.Fail("Пигмент не найден"))
-		} else {
-			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения пигмента"))
-		}
+	// Архивный пигмент тоже отдаём: на него могут ссылаться старые анализы
+	pigment, err := h.Pigments.GetPigment(c.Request.Context(), id, true)
+	if err != nil {
+		respondPigmentError(c, err, "Ошибка получения пигмента")
 		return
 	}
 
-	response := types.PigmentResponse{
-		ID:          pigment.ID,
-		Name:        pigment.Name,
-		Brief:       pigment.Brief,
-		Description: pigment.Description,
-		Color:       pigment.Color,
-		Specs:       pigment.Specs,
-		ImageKey:    pigment.ImageKey,
-	}
-
 	c.JSON(http.StatusOK, gin.H{
-		"pigment": response,
+		"pigment": newPigmentResponse(*pigment),
 	})
 }
 
@@ -145,33 +160,26 @@ func (h *PigmentHandler) CreatePigment(c *gin.Context) {
 		Color:       request.Color,
 		Specs:       request.Specs,
 	}
+	if _, err := applyPigmentMetadata(&pigment, request.PigmentMetadataRequest); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail(err.Error()))
+		return
+	}
 
-	if err := h.Repository.GetDB().Create(&pigment).Error; err != nil {
+	if err := h.Pigments.CreatePigment(c.Request.Context(), &pigment); err != nil {
 		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка создания пигмента"))
 		return
 	}
 
 	// СЕРИАЛИЗАЦИЯ Go структура → JSON
-	response := types.PigmentResponse{
-		ID:          pigment.ID,
-		Name:        pigment.Name,
-		Brief:       request.Brief,
-		Description: request.Description,
-		Color:       request.Color,
-		Specs:       request.Specs,
-	}
-
 	c.JSON(http.StatusCreated, gin.H{
-		"pigment": response,
+		"pigment": newPigmentResponse(pigment),
 	})
 }
 
 // PUT /api/pigments/:id - обновление пигмента
 func (h *PigmentHandler) UpdatePigment(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, types.Fail("Неверный ID пигмента"))
+	id, ok := parsePigmentID(c)
+	if !ok {
 		return
 	}
 
@@ -181,110 +189,110 @@ func (h *PigmentHandler) UpdatePigment(c *gin.Context) {
 		return
 	}
 
-	// Проверяем существование пигмента
-	var pigment ds.Pigment
-	if err := h.Repository.GetDB().Unscoped().First(&pigment, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, types.Fail("Пигмент не найден"))
-		} else {
-			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка обновления пигмента"))
-		}
+	// Проверяем существование пигмента (архивный сначала нужно восстановить)
+	pigment, err := h.Pigments.GetPigment(c.Request.Context(), id, false)
+	if err != nil {
+		respondPigmentError(c, err, "Ошибка обновления пигмента")
 		return
 	}
 
 	// Обновляем только переданные поля
-	updates := make(map[string]interface{})
+	updated := false
 	if request.Name != "" {
-		updates["name"] = request.Name
+		pigment.Name = request.Name
+		updated = true
 	}
 	if request.Brief != "" {
-		updates["brief"] = request.Brief
+		pigment.Brief = request.Brief
+		updated = true
 	}
 	if request.Description != "" {
-		updates["description"] = request.Description
+		pigment.Description = request.Description
+		updated = true
 	}
 	if request.Color != "" {
-		updates["color"] = request.Color
+		pigment.Color = request.Color
+		updated = true
 	}
 	if request.Specs != "" {
-		updates["specs"] = request.Specs
+		pigment.Specs = request.Specs
+		updated = true
 	}
-
-	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, types.Fail("Нет данных для обновления"))
+	metaUpdated, err := applyPigmentMetadata(pigment, request.PigmentMetadataRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail(err.Error()))
 		return
 	}
+	updated = updated || metaUpdated
 
-	if err := h.Repository.GetDB().Model(&pigment).Updates(updates).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка обновления пигмента"))
+	if !updated {
+		c.JSON(http.StatusBadRequest, types.Fail("Нет данных для обновления"))
 		return
 	}
 
-	// Получаем обновленный пигмент
-	h.Repository.GetDB().First(&pigment, id)
-
-	response := types.PigmentResponse{
-		ID:          pigment.ID,
-		Name:        pigment.Name,
-		Brief:       pigment.Brief,
-		Description: pigment.Description,
-		Color:       pigment.Color,
-		Specs:       pigment.Specs,
-		ImageKey:    pigment.ImageKey,
+	if err := h.Pigments.UpdatePigment(c.Request.Context(), pigment); err != nil {
+		respondPigmentError(c, err, "Ошибка обновления пигмента")
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"pigment": response,
+		"pigment": newPigmentResponse(*pigment),
 	})
 }
 
-// DELETE /api/pigments/:id - удаление пигмента
+// DELETE /api/pigments/:id - перенос пигмента в архив
 func (h *PigmentHandler) DeletePigment(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, types.Fail("Неверный ID пигмента"))
+	id, ok := parsePigmentID(c)
+	if !ok {
 		return
 	}
 
-	var pigment ds.Pigment
-	if err := h.Repository.GetDB().Unscoped().First(&pigment, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, types.Fail("Пигмент не найден"))
-		} else {
-			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка удаления пигмента"))
-		}
+	// Soft delete: связи с анализами сохраняются, чтобы старые анализы
+	// продолжали показывать пигмент
+	if err := h.Pigments.ArchivePigment(c.Request.Context(), id); err != nil {
+		respondPigmentError(c, err, "Ошибка архивирования пигмента")
 		return
 	}
 
-	// TODO: Удаление изображения из Minio (если есть)
-	if pigment.ImageKey != "" {
-		// minio.Delete(pigment.ImageKey)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Пигмент перенесён в архив",
+	})
+}
+
+// POST /api/pigments/:id/restore - восстановление пигмента из архива
+func (h *PigmentHandler) RestorePigment(c *gin.Context) {
+	id, ok := parsePigmentID(c)
+	if !ok {
+		return
 	}
 
-	// Удаляем связи в request_pigments сначала
-	if err := h.Repository.GetDB().Where("pigment_id = ?", id).Delete(&ds.SpectrumAnalysisPigment{}).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка удаления связей пигмента"))
+	pigment, err := h.Pigments.GetPigment(c.Request.Context(), id, true)
+	if err != nil {
+		respondPigmentError(c, err, "Ошибка восстановления пигмента")
+		return
+	}
+
+	if !pigment.DeletedAt.Valid {
+		c.JSON(http.StatusBadRequest, types.Fail("Пигмент не находится в архиве"))
 		return
 	}
 
-	// Удаляем сам пигмент
-	if err := h.Repository.GetDB().Delete(&pigment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка удаления пигмента"))
+	if err := h.Pigments.RestorePigment(c.Request.Context(), id); err != nil {
+		respondPigmentError(c, err, "Ошибка восстановления пигмента")
 		return
 	}
 
+	pigment.DeletedAt.Valid = false
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Пигмент удален",
+		"pigment": newPigmentResponse(*pigment),
 	})
 }
 
-// POST /api/pigments/:id/add-to-cart - добавить пигмент в корзину
+// POST /api/pigments/:id/add-to-sa - добавить пигмент в корзину
 func (h *PigmentHandler) AddToSpectrumAnalysis(c *gin.Context) {
-	idStr := c.Param("id")
-	pigmentID, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, types.Fail("Неверный ID пигмента"))
+	pigmentID, ok := parsePigmentID(c)
+	if !ok {
 		return
 	}
 
@@ -294,66 +302,22 @@ func (h *PigmentHandler) AddToSpectrumAnalysis(c *gin.Context) {
 		return
 	}
 
-	// Проверяем существование пигмента
-	var pigment ds.Pigment
-	if err := h.Repository.GetDB().Unscoped().First(&pigment, pigmentID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, types.Fail("Пигмент не найден"))
-		} else {
-			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка добавления в корзину"))
-		}
+	// Проверяем существование пигмента (архивные в заявку не добавляются)
+	if _, err := h.Pigments.GetPigment(c.Request.Context(), pigmentID, false); err != nil {
+		respondPigmentError(c, err, "Ошибка добавления в корзину")
 		return
 	}
 
-	// Находим или создаем заявку в статусе draft для пользователя
-	var analysis ds.SpectrumAnalysis
-	err = h.Repository.GetDB().Where("creator_id = ? AND status = ?", userID, "draft").First(&analysis).Error
-
-	if err == gorm.ErrRecordNotFound {
-		// Создаем новую заявку
-		analysis = ds.SpectrumAnalysis{
-			Name:      "Новый анализ спектра",
-			Status:    "draft",
-			CreatorID: userID.(uint),
-			Spectrum:  "",
-		}
-		if err := h.Repository.GetDB().Create(&analysis).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка создания заявки"))
-			return
-		}
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка поиска заявки"))
-		return
-	}
-
-	// Проверяем, нет ли уже этого пигмента в заявке
-	var existing ds.SpectrumAnalysisPigment
-	err = h.Repository.GetDB().
-		Where("spectrum_analysis_id = ? AND pigment_id = ?", analysis.ID, pigmentID).
-		First(&existing).Error
-
-	if err == nil {
+	// Находим или создаем черновик пользователя и добавляем в него пигмент
+	analysis, count, err := h.Analyses.AddPigmentToDraft(c.Request.Context(), userID.(uint), pigmentID)
+	if errors.Is(err, repository.ErrAlreadyExists) {
 		c.JSON(http.StatusBadRequest, types.Fail("Пигмент уже в заявке"))
 		return
-	}
-
-	// Добавляем пигмент в заявку
-	spectrumAnalysisPigment := ds.SpectrumAnalysisPigment{
-    SpectrumAnalysisID: analysis.ID,
-    PigmentID: uint(pigmentID),
-    Comment:   "",
-    Percent:   0.0,
-}
-
-	if err := h.Repository.GetDB().Create(&spectrumAnalysisPigment).Error; err != nil {
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка добавления в заявку"))
 		return
 	}
 
-	// Считаем количество пигментов в заявке
-	var count int64
-	h.Repository.GetDB().Model(&ds.SpectrumAnalysisPigment{}).Where("spectrum_analysis_id = ?", analysis.ID).Count(&count)
-
 	c.JSON(http.StatusOK, gin.H{
 		"message":     "Пигмент добавлен в заявку",
 		"analysis_id": analysis.ID,
@@ -363,28 +327,17 @@ func (h *PigmentHandler) AddToSpectrumAnalysis(c *gin.Context) {
 
 // POST /api/pigments/:id/image - загрузка изображения пигмента
 func (h *PigmentHandler) UploadImage(c *gin.Context) {
-	idStr := c.Param("id")
-	pigmentID, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, types.Fail("Неверный ID пигмента"))
+	pigmentID, ok := parsePigmentID(c)
+	if !ok {
 		return
 	}
 
-	// ✅ ИСПОЛЬЗУЕМ Unscoped() чтобы игнорировать soft delete условия
-	var pigment ds.Pigment
-	result := h.Repository.GetDB().Unscoped().Where("id = ?", pigmentID).First(&pigment)
-	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, types.Fail("Пигмент не найден"))
-		} else {
-			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка загрузки изображения"))
-		}
+	pigment, err := h.Pigments.GetPigment(c.Request.Context(), pigmentID, false)
+	if err != nil {
+		respondPigmentError(c, err, "Ошибка загрузки изображения")
 		return
 	}
 
-	fmt.Printf("✅ DEBUG: Found pigment - ID: %d, Name: %s, Current ImageKey: '%s'\n",
-		pigment.ID, pigment.Name, pigment.ImageKey)
-
 	// Получаем файл из формы
 	file, err := c.FormFile("image")
 	if err != nil {
@@ -404,29 +357,58 @@ func (h *PigmentHandler) UploadImage(c *gin.Context) {
 	fileExt := filepath.Ext(file.Filename)
 	newFileName := fmt.Sprintf("pigment_%d_%d%s", pigment.ID, time.Now().Unix(), fileExt)
 
-	fmt.Printf("🔄 DEBUG: Updating pigment ID %d with image_key: %s\n", pigment.ID, newFileName)
-
-	// ✅ ИСПОЛЬЗУЕМ Unscoped() ДЛЯ ОБНОВЛЕНИЯ ТОЖЕ!
-	result = h.Repository.GetDB().Unscoped().Model(&ds.Pigment{}).Where("id = ?", pigment.ID).Update("image_key", newFileName)
-	if result.Error != nil {
-		fmt.Printf("❌ DEBUG: Update error: %v\n", result.Error)
-		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка сохранения информации об изображении: "+result.Error.Error()))
+	pigment.ImageKey = newFileName
+	if err := h.Pigments.UpdatePigment(c.Request.Context(), pigment); err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка сохранения информации об изображении: "+err.Error()))
 		return
 	}
 
-	fmt.Printf("✅ DEBUG: Update successful. Rows affected: %d\n", result.RowsAffected)
-
-	// Проверяем обновилась ли запись
-	var updatedPigment ds.Pigment
-	h.Repository.GetDB().Unscoped().First(&updatedPigment, pigment.ID)
-	fmt.Printf("🔍 DEBUG: After update - ImageKey: '%s'\n", updatedPigment.ImageKey)
-
 	c.JSON(http.StatusOK, gin.H{
 		"message":           "Изображение успешно загружено",
 		"image_key":         newFileName,
 		"pigment_id":        pigment.ID,
 		"pigment_name":      pigment.Name,
-		"rows_affected":     result.RowsAffected,
-		"current_image_key": updatedPigment.ImageKey,
+		"current_image_key": pigment.ImageKey,
 	})
 }
+
+// parsePigmentID разбирает :id из пути, при ошибке сам отвечает 400
+func parsePigmentID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный ID пигмента"))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondPigmentError отвечает 404 для ненайденного пигмента и 500 с message для остального
+func respondPigmentError(c *gin.Context, err error, message string) {
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, types.Fail("Пигмент не найден"))
+	} else {
+		c.JSON(http.StatusInternalServerError, types.Fail(message))
+	}
+}
+
+// newPigmentResponse сериализует пигмент вместе с временными метками и отметкой архива
+func newPigmentResponse(pigment ds.Pigment) types.PigmentResponse {
+	response := types.PigmentResponse{
+		ID:          pigment.ID,
+		Name:        pigment.Name,
+		Brief:       pigment.Brief,
+		Description: pigment.Description,
+		Color:       pigment.Color,
+		Specs:       pigment.Specs,
+		ImageKey:    pigment.ImageKey,
+		CreatedAt:   pigment.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   pigment.UpdatedAt.Format(time.RFC3339),
+
+		PigmentMetadata: newPigmentMetadata(pigment),
+	}
+	if pigment.DeletedAt.Valid {
+		archivedAt := pigment.DeletedAt.Time
+		response.ArchivedAt = &archivedAt
+	}
+	return response
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"net/http"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/spectra"
+
+	"github.com/gin-gonic/gin"
+)
+
+// POST /api/spectra/preview - применить шаги предобработки к спектру без сохранения
+func PreviewSpectrum(c *gin.Context) {
+	var request types.SpectrumPreviewRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Спектр обязателен"))
+		return
+	}
+	spectrum, err := spectra.Parse(request.Spectrum)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный формат спектра: "+err.Error()))
+		return
+	}
+	pipeline := toPipeline(request.Steps)
+	if err := pipeline.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверные шаги предобработки: "+err.Error()))
+		return
+	}
+
+	processed, err := pipeline.Apply(spectrum)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Предобработку не удалось применить: "+err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"processed": newProcessedSpectrum(processed),
+		"spectrum":  processed.String(),
+	})
+}
+
+// applyPreprocessing проверяет и переносит в заявку шаги предобработки; пустой список её отключает
+func applyPreprocessing(analysis *ds.SpectrumAnalysis, steps []types.PreprocessingStep) error {
+	pipeline := toPipeline(steps)
+	if err := pipeline.Validate(); err != nil {
+		return err
+	}
+	analysis.Preprocessing = pipeline.String()
+	return nil
+}
+
+// analysisPipeline - сохранённые шаги предобработки заявки. Они проверяются при
+// сохранении, поэтому ошибка разбора означает испорченную запись и игнорируется
+func analysisPipeline(analysis *ds.SpectrumAnalysis) spectra.Pipeline {
+	pipeline, err := spectra.ParsePipeline(analysis.Preprocessing)
+	if err != nil {
+		return nil
+	}
+	return pipeline
+}
+
+// processAggregate применяет предобработку заявки к усреднённому спектру.
+// Возвращает nil без ошибки, если предобработка не задана или спектра нет
+func processAggregate(analysis *ds.SpectrumAnalysis, aggregate *spectra.Aggregate) (*types.ProcessedSpectrum, error) {
+	pipeline := analysisPipeline(analysis)
+	if len(pipeline) == 0 || aggregate == nil {
+		return nil, nil
+	}
+	processed, err := pipeline.Apply(aggregate.Mean)
+	if err != nil {
+		return nil, err
+	}
+	return newProcessedSpectrum(processed), nil
+}
+
+func toPipeline(steps []types.PreprocessingStep) spectra.Pipeline {
+	pipeline := make(spectra.Pipeline, len(steps))
+	for i, step := range steps {
+		pipeline[i] = spectra.Step{
+			Op:        step.Op,
+			From:      step.From,
+			To:        step.To,
+			Interval:  step.Interval,
+			Window:    step.Window,
+			PolyOrder: step.PolyOrder,
+			Method:    step.Method,
+			Order:     step.Order,
+		}
+	}
+	return pipeline
+}
+
+func newPreprocessingResponse(pipeline spectra.Pipeline) []types.PreprocessingStep {
+	var steps []types.PreprocessingStep
+	for _, step := range pipeline {
+		steps = append(steps, types.PreprocessingStep{
+			Op:        step.Op,
+			From:      step.From,
+			To:        step.To,
+			Interval:  step.Interval,
+			Window:    step.Window,
+			PolyOrder: step.PolyOrder,
+			Method:    step.Method,
+			Order:     step.Order,
+		})
+	}
+	return steps
+}
+
+func newProcessedSpectrum(spectrum spectra.Spectrum) *types.ProcessedSpectrum {
+	return &types.ProcessedSpectrum{
+		Wavelengths: spectrum.Wavelengths(),
+		Values:      spectrum.Values(),
+	}
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/identify"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/spectra"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Откуда взяты полосы пигмента
+const (
+	bandSourceLibrary   = "library"
+	bandSourceReference = "reference"
+)
+
+// ramanResult - пики рамановского спектра заявки и объяснения оценок пигментов
+type ramanResult struct {
+	Readings     int
+	Peaks        []identify.Peak
+	Explanations []identify.RamanExplanation // по убыванию оценки
+	Sources      map[uint]string
+}
+
+// GET /api/spectrum-analysis/:id/raman - найденные пики и полосы пигментов, которые они подтверждают
+// (pigment_id - объяснение только для одного пигмента)
+func (h *SpectrumAnalysisHandler) GetRamanExplanation(c *gin.Context) {
+	var pigmentID uint
+	if raw := c.Query("pigment_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil || id == 0 {
+			c.JSON(http.StatusBadRequest, types.Fail("Неверный ID пигмента"))
+			return
+		}
+		pigmentID = uint(id)
+	}
+
+	analysis, ok := h.loadAnalysis(c, "Ошибка рамановской идентификации")
+	if !ok {
+		return
+	}
+	if analysis.Status == ds.StatusDeleted {
+		c.JSON(http.StatusNotFound, types.Fail("Заявка была удалена"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	readings, err := h.Analyses.ListReadings(ctx, analysis.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка рамановской идентификации"))
+		return
+	}
+	pigments, err := h.Pigments.ListPigments(ctx, repository.PigmentQuery{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка рамановской идентификации"))
+		return
+	}
+	if pigmentID != 0 {
+		pigments = filterPigments(pigments, pigmentID)
+		if len(pigments) == 0 {
+			c.JSON(http.StatusNotFound, types.Fail("Пигмент не найден"))
+			return
+		}
+	}
+
+	result, err := h.explainRaman(ctx, analysis, readings, pigments)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка рамановской идентификации"))
+		return
+	}
+	if result == nil {
+		c.JSON(http.StatusBadRequest, types.Fail("В заявке нет рамановских измерений"))
+		return
+	}
+	explanations, err := h.newRamanExplanations(ctx, analysis, result)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка рамановской идентификации"))
+		return
+	}
+
+	peaks := make([]types.RamanPeak, len(result.Peaks))
+	for i, peak := range result.Peaks {
+		peaks[i] = newRamanPeak(peak)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"readings": result.Readings,
+		"peaks":    peaks,
+		"pigments": explanations,
+	})
+}
+
+// explainRaman сравнивает усреднённый рамановский спектр заявки с полосами пигментов.
+// Возвращает nil без ошибки, если рамановских измерений нет
+func (h *SpectrumAnalysisHandler) explainRaman(ctx context.Context, analysis *ds.SpectrumAnalysis, readings []ds.SpectrumReading, pigments []ds.Pigment) (*ramanResult, error) {
+	aggregate := combineTechnique(analysis, readings, spectra.TechniqueRaman)
+	if aggregate == nil {
+		return nil, nil
+	}
+	sets, sources, err := h.ramanBandSets(ctx, pigments)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ramanResult{
+		Readings: aggregate.Used,
+		Peaks:    identify.DetectPeaks(aggregate.Mean),
+		Sources:  sources,
+	}
+	for _, set := range sets {
+		result.Explanations = append(result.Explanations, identify.ExplainRaman(result.Peaks, set))
+	}
+	sort.SliceStable(result.Explanations, func(i, j int) bool {
+		return result.Explanations[i].Score > result.Explanations[j].Score
+	})
+	return result, nil
+}
+
+// ramanBandSets собирает полосы пигментов: из библиотеки, а если её нет - из пиков
+// рамановских эталонов. Архивные пигменты не сравниваются. Наборы упорядочены по ID пигмента
+func (h *SpectrumAnalysisHandler) ramanBandSets(ctx context.Context, pigments []ds.Pigment) ([]identify.BandSet, map[uint]string, error) {
+	stored, err := h.Pigments.ListRamanBands(ctx, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	library := make(map[uint][]identify.Band)
+	for _, band := range stored {
+		library[band.PigmentID] = append(library[band.PigmentID], identify.Band{
+			Position:   band.Position,
+			Tolerance:  band.Tolerance,
+			Strength:   band.Strength,
+			Assignment: band.Assignment,
+		})
+	}
+	references, err := h.referenceSpectra(ctx, spectra.TechniqueRaman, pigments)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var sets []identify.BandSet
+	sources := make(map[uint]string)
+	for _, pigment := range pigments {
+		if bands, ok := library[pigment.ID]; ok {
+			sets = append(sets, identify.BandSet{PigmentID: pigment.ID, Name: pigment.Name, Bands: bands})
+			sources[pigment.ID] = bandSourceLibrary
+		}
+	}
+	for _, reference := range references {
+		if sources[reference.PigmentID] == bandSourceLibrary {
+			continue
+		}
+		if set := identify.ReferenceBands(reference); len(set.Bands) > 0 {
+			sets = append(sets, set)
+			sources[reference.PigmentID] = bandSourceReference
+		}
+	}
+	sort.SliceStable(sets, func(i, j int) bool { return sets[i].PigmentID < sets[j].PigmentID })
+	return sets, sources, nil
+}
+
+// newRamanExplanations готовит объяснения для ответа. Для пигмента с несколькими
+// эталонами остаётся лучшее объяснение
+func (h *SpectrumAnalysisHandler) newRamanExplanations(ctx context.Context, analysis *ds.SpectrumAnalysis, result *ramanResult) ([]types.RamanExplanation, error) {
+	analysisPigments, err := h.Analyses.ListAnalysisPigments(ctx, analysis.ID)
+	if err != nil {
+		return nil, err
+	}
+	inAnalysis := make(map[uint]bool, len(analysisPigments))
+	for _, ap := range analysisPigments {
+		inAnalysis[ap.Pigment.ID] = true
+	}
+
+	response := []types.RamanExplanation{}
+	seen := make(map[uint]bool)
+	for _, explanation := range result.Explanations {
+		if seen[explanation.PigmentID] {
+			continue
+		}
+		seen[explanation.PigmentID] = true
+		response = append(response, newRamanExplanation(explanation, result.Sources[explanation.PigmentID], inAnalysis[explanation.PigmentID]))
+	}
+	return response, nil
+}
+
+func newRamanExplanation(explanation identify.RamanExplanation, source string, inAnalysis bool) types.RamanExplanation {
+	response := types.RamanExplanation{
+		PigmentID:     explanation.PigmentID,
+		Name:          explanation.Name,
+		Source:        source,
+		Score:         roundScore(explanation.Score),
+		InAnalysis:    inAnalysis,
+		MissingStrong: explanation.MissingStrong(),
+		Matched:       make([]types.RamanBandMatch, len(explanation.Matched)),
+		Missing:       make([]types.RamanBand, len(explanation.Missing)),
+		Unexplained:   make([]types.RamanPeak, len(explanation.Unexplained)),
+	}
+	for i, match := range explanation.Matched {
+		response.Matched[i] = types.RamanBandMatch{
+			Band:  newBand(match.Band),
+			Peak:  newRamanPeak(match.Peak),
+			Shift: roundTo(match.Shift, 2),
+		}
+	}
+	for i, band := range explanation.Missing {
+		response.Missing[i] = newBand(band)
+	}
+	for i, peak := range explanation.Unexplained {
+		response.Unexplained[i] = newRamanPeak(peak)
+	}
+	return response
+}
+
+func newBand(band identify.Band) types.RamanBand {
+	return types.RamanBand{
+		Position:   band.Position,
+		Tolerance:  band.Tolerance,
+		Strength:   band.Strength,
+		Assignment: band.Assignment,
+	}
+}
+
+func newRamanPeak(peak identify.Peak) types.RamanPeak {
+	return types.RamanPeak{
+		Position:  peak.Position,
+		Intensity: roundTo(peak.Intensity, 4),
+		Relative:  roundScore(peak.Relative),
+		Width:     roundTo(peak.Width, 2),
+	}
+}
+
+func roundTo(value float64, digits int) float64 {
+	scale := math.Pow(10, float64(digits))
+	return math.Round(value*scale) / scale
+}
+
+func filterPigments(pigments []ds.Pigment, id uint) []ds.Pigment {
+	for _, pigment := range pigments {
+		if pigment.ID == id {
+			return []ds.Pigment{pigment}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/identify"
+	"colorLex/internal/app/spectra"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Ограничения библиотеки полос одного пигмента
+const (
+	maxRamanBands    = 50
+	maxBandTolerance = 50.0 // см⁻¹
+)
+
+// GET /api/pigments/:id/raman-bands - библиотека характеристических рамановских полос пигмента
+func (h *PigmentHandler) GetRamanBands(c *gin.Context) {
+	id, ok := parsePigmentID(c)
+	if !ok {
+		return
+	}
+	if _, err := h.Pigments.GetPigment(c.Request.Context(), id, true); err != nil {
+		respondPigmentError(c, err, "Ошибка получения полос")
+		return
+	}
+	bands, err := h.Pigments.ListRamanBands(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения полос"))
+		return
+	}
+
+	response := make([]types.RamanBand, len(bands))
+	for i, band := range bands {
+		response[i] = newRamanBand(band)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"bands": response,
+		"count": len(response),
+	})
+}
+
+// PUT /api/pigments/:id/raman-bands - заменить библиотеку полос пигмента
+func (h *PigmentHandler) ReplaceRamanBands(c *gin.Context) {
+	id, ok := parsePigmentID(c)
+	if !ok {
+		return
+	}
+
+	var request types.ReplaceRamanBandsRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный формат списка полос"))
+		return
+	}
+	bands, err := toRamanBands(request.Bands)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail(err.Error()))
+		return
+	}
+
+	if _, err := h.Pigments.GetPigment(c.Request.Context(), id, false); err != nil {
+		respondPigmentError(c, err, "Ошибка сохранения полос")
+		return
+	}
+	if err := h.Pigments.ReplaceRamanBands(c.Request.Context(), id, bands); err != nil {
+		respondPigmentError(c, err, "Ошибка сохранения полос")
+		return
+	}
+
+	response := make([]types.RamanBand, len(bands))
+	for i, band := range bands {
+		response[i] = newRamanBand(band)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"bands": response,
+		"count": len(response),
+	})
+}
+
+// toRamanBands проверяет полосы из запроса и упорядочивает их по положению.
+// Текст ошибки предназначен пользователю
+func toRamanBands(request []types.RamanBand) ([]ds.RamanBand, error) {
+	if len(request) > maxRamanBands {
+		return nil, fmt.Errorf("У пигмента может быть не больше %d полос", maxRamanBands)
+	}
+	axis, _ := spectra.TechniqueAxis(spectra.TechniqueRaman)
+
+	bands := make([]ds.RamanBand, len(request))
+	for i, band := range request {
+		if band.Position < axis.Min || band.Position > axis.Max {
+			return nil, fmt.Errorf("Полоса %g вне диапазона %g-%g см⁻¹", band.Position, axis.Min, axis.Max)
+		}
+		if band.Tolerance < 0 || band.Tolerance > maxBandTolerance {
+			return nil, fmt.Errorf("Допуск полосы %g должен быть от 0 до %g см⁻¹", band.Position, maxBandTolerance)
+		}
+		if !slices.Contains(identify.Strengths, band.Strength) {
+			return nil, fmt.Errorf("Сила полосы %g: ожидается strong, medium или weak", band.Position)
+		}
+		tolerance := band.Tolerance
+		if tolerance == 0 {
+			tolerance = identify.DefaultTolerance
+		}
+		bands[i] = ds.RamanBand{
+			Position:   band.Position,
+			Tolerance:  tolerance,
+			Strength:   band.Strength,
+			Assignment: strings.TrimSpace(band.Assignment),
+		}
+	}
+
+	sort.Slice(bands, func(i, j int) bool { return bands[i].Position < bands[j].Position })
+	for i := 1; i < len(bands); i++ {
+		if bands[i].Position == bands[i-1].Position {
+			return nil, fmt.Errorf("Полоса %g указана дважды", bands[i].Position)
+		}
+	}
+	return bands, nil
+}
+
+func newRamanBand(band ds.RamanBand) types.RamanBand {
+	return types.RamanBand{
+		Position:   band.Position,
+		Tolerance:  band.Tolerance,
+		Strength:   band.Strength,
+		Assignment: band.Assignment,
+	}
+}
@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/spectra"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxReadings - ограничение на число повторных измерений в одной заявке
+const maxReadings = 50
+
+// POST /api/spectrum-analysis/:id/readings - добавить повторное измерение спектра.
+// Измерение прибора исправляется калибровкой, действовавшей в момент измерения
+func (h *SpectrumAnalysisHandler) AddReading(c *gin.Context) {
+	var request types.AddSpectrumReadingRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Спектр обязателен"))
+		return
+	}
+
+	var instrument *ds.Instrument
+	if request.InstrumentID != nil {
+		var err error
+		instrument, err = h.Instruments.GetInstrument(c.Request.Context(), *request.InstrumentID)
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusBadRequest, types.Fail("Прибор не найден"))
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка добавления измерения"))
+			return
+		}
+	}
+
+	technique := request.Technique
+	switch {
+	case technique == "" && instrument != nil:
+		technique = instrument.Technique
+	case technique == "":
+		technique = spectra.TechniqueReflectance
+	}
+	if _, ok := spectra.TechniqueAxis(technique); !ok {
+		c.JSON(http.StatusBadRequest, types.Fail("Неизвестная методика: ожидается reflectance, raman или xrf"))
+		return
+	}
+	if instrument != nil && instrument.Technique != technique {
+		c.JSON(http.StatusBadRequest, types.Fail("Методика измерения не совпадает с методикой прибора"))
+		return
+	}
+
+	reading := ds.SpectrumReading{
+		Technique: technique,
+		Note:      strings.TrimSpace(request.Note),
+	}
+	var spectrum spectra.Spectrum
+	if instrument != nil {
+		var ok bool
+		if spectrum, ok = h.calibrateReading(c, instrument, request, &reading); !ok {
+			return
+		}
+	} else {
+		var err error
+		if spectrum, err = spectra.ParseTechnique(request.Spectrum, technique); err != nil {
+			c.JSON(http.StatusBadRequest, types.Fail("Неверный формат спектра: "+err.Error()))
+			return
+		}
+	}
+	reading.Spectrum = spectrum.String()
+
+	analysis, ok := h.loadEditableDraft(c, "Ошибка добавления измерения")
+	if !ok {
+		return
+	}
+
+	readings, err := h.Analyses.ListReadings(c.Request.Context(), analysis.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка добавления измерения"))
+		return
+	}
+	if len(readings) >= maxReadings {
+		c.JSON(http.StatusBadRequest, types.Fail("Слишком много измерений в одной заявке"))
+		return
+	}
+
+	// Новое измерение должно сводиться с уже добавленными измерениями той же методики.
+	// Неисправленные отсчёты в сводку не входят, их не с чем сравнивать
+	if !uncalibrated(reading) {
+		parsed, err := parseReadings(techniqueReadings(readings, technique))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка добавления измерения"))
+			return
+		}
+		if _, err := spectra.Combine(append(parsed, spectrum), spectra.OutlierRule{Method: spectra.OutlierNone}); errors.Is(err, spectra.ErrNoOverlap) {
+			c.JSON(http.StatusBadRequest, types.Fail("Диапазон длин волн не пересекается с другими измерениями"))
+			return
+		} else if err != nil {
+			c.JSON(http.StatusBadRequest, types.Fail("Неверный формат спектра: "+err.Error()))
+			return
+		}
+	}
+
+	if err := h.Analyses.AddReading(c.Request.Context(), analysis, &reading); err != nil {
+		respondAnalysisWriteError(c, err, "Ошибка добавления измерения")
+		return
+	}
+
+	setAnalysisETag(c, analysis)
+	c.JSON(http.StatusCreated, gin.H{
+		"reading":        newReadingResponse(reading),
+		"readings_count": len(readings) + 1,
+	})
+}
+
+// calibrateReading разбирает сырые отсчёты прибора и исправляет их калибровкой на момент
+// измерения, заполняя поля прибора в reading. Если калибровки нет, возвращаются
+// неисправленные отсчёты. ok = false - ответ с ошибкой уже отправлен
+func (h *SpectrumAnalysisHandler) calibrateReading(c *gin.Context, instrument *ds.Instrument, request types.AddSpectrumReadingRequest, reading *ds.SpectrumReading) (spectra.Spectrum, bool) {
+	raw, err := spectra.Parse(request.Spectrum)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный формат спектра: "+err.Error()))
+		return nil, false
+	}
+	if from, to := raw.Range(); from < instrument.RangeFrom || to > instrument.RangeTo {
+		c.JSON(http.StatusBadRequest, types.Fail(fmt.Sprintf("Спектр выходит за рабочий диапазон прибора %g-%g", instrument.RangeFrom, instrument.RangeTo)))
+		return nil, false
+	}
+
+	measuredAt := time.Now()
+	if request.MeasuredAt != nil {
+		if request.MeasuredAt.After(measuredAt) {
+			c.JSON(http.StatusBadRequest, types.Fail("Время измерения не может быть в будущем"))
+			return nil, false
+		}
+		measuredAt = *request.MeasuredAt
+	}
+	reading.InstrumentID = &instrument.ID
+	reading.MeasuredAt = &measuredAt
+	reading.Raw = raw.String()
+
+	stored, err := h.Instruments.FindCalibration(c.Request.Context(), instrument.ID, measuredAt)
+	if errors.Is(err, repository.ErrNotFound) {
+		return raw, true
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка добавления измерения"))
+		return nil, false
+	}
+
+	calibration, err := toCalibration(*stored)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка добавления измерения"))
+		return nil, false
+	}
+	corrected, err := calibration.Apply(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Калибровка прибора не подходит к спектру: "+err.Error()))
+		return nil, false
+	}
+	spectrum, err := spectra.ParseTechnique(corrected.String(), reading.Technique)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный спектр после калибровки: "+err.Error()))
+		return nil, false
+	}
+	reading.CalibrationID = &stored.ID
+	return spectrum, true
+}
+
+// DELETE /api/spectrum-analysis/:id/readings/:reading_id - удалить измерение
+func (h *SpectrumAnalysisHandler) DeleteReading(c *gin.Context) {
+	readingID, err := strconv.ParseUint(c.Param("reading_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный ID измерения"))
+		return
+	}
+
+	analysis, ok := h.loadEditableDraft(c, "Ошибка удаления измерения")
+	if !ok {
+		return
+	}
+
+	err = h.Analyses.RemoveReading(c.Request.Context(), analysis, uint(readingID))
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, types.Fail("Измерение не найдено"))
+		return
+	} else if err != nil {
+		respondAnalysisWriteError(c, err, "Ошибка удаления измерения")
+		return
+	}
+
+	setAnalysisETag(c, analysis)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Измерение удалено",
+	})
+}
+
+// analysisOutlierRule - правило отбраковки заявки с подстановкой значений по умолчанию
+func analysisOutlierRule(analysis *ds.SpectrumAnalysis) spectra.OutlierRule {
+	rule := spectra.DefaultOutlierRule
+	if analysis.OutlierMethod != "" {
+		rule = spectra.OutlierRule{Method: analysis.OutlierMethod}
+		if analysis.OutlierMethod == spectra.OutlierMAD {
+			rule.Threshold = spectra.DefaultOutlierRule.Threshold
+		}
+	}
+	if analysis.OutlierThreshold != nil {
+		rule.Threshold = *analysis.OutlierThreshold
+	}
+	return rule
+}
+
+// applyOutlierRule проверяет и переносит в заявку правило отбраковки
+func applyOutlierRule(analysis *ds.SpectrumAnalysis, request types.OutlierRule) error {
+	analysis.OutlierMethod = request.Method
+	analysis.OutlierThreshold = nil
+	if request.Threshold != nil {
+		threshold := *request.Threshold
+		analysis.OutlierThreshold = &threshold
+	}
+	if err := analysisOutlierRule(analysis).Validate(); err != nil {
+		return errors.New("Неверное правило отбраковки: ожидается none, mad или rmsd с положительным порогом")
+	}
+	return nil
+}
+
+func parseReadings(readings []ds.SpectrumReading) ([]spectra.Spectrum, error) {
+	result := make([]spectra.Spectrum, len(readings))
+	for i, reading := range readings {
+		spectrum, err := spectra.Parse(reading.Spectrum)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = spectrum
+	}
+	return result, nil
+}
+
+// uncalibrated - измерение прибора, которое не удалось исправить калибровкой
+func uncalibrated(reading ds.SpectrumReading) bool {
+	return reading.InstrumentID != nil && reading.CalibrationID == nil
+}
+
+// uncalibratedReadings возвращает ID неоткалиброванных измерений. С requireCalibration
+// неоткалиброванными считаются и измерения без прибора
+func uncalibratedReadings(readings []ds.SpectrumReading, requireCalibration bool) []uint {
+	var ids []uint
+	for _, reading := range readings {
+		if uncalibrated(reading) || (requireCalibration && reading.CalibrationID == nil) {
+			ids = append(ids, reading.ID)
+		}
+	}
+	return ids
+}
+
+// techniqueReadings отбирает откалиброванные измерения одной методики, сохраняя порядок
+func techniqueReadings(readings []ds.SpectrumReading, technique string) []ds.SpectrumReading {
+	var result []ds.SpectrumReading
+	for _, reading := range readings {
+		if reading.Technique == technique && !uncalibrated(reading) {
+			result = append(result, reading)
+		}
+	}
+	return result
+}
+
+// combineTechnique усредняет измерения заявки одной методики. Для спектров отражения
+// без измерений используется одиночный Spectrum; nil - спектра нет или его не удалось разобрать
+func combineTechnique(analysis *ds.SpectrumAnalysis, readings []ds.SpectrumReading, technique string) *spectra.Aggregate {
+	parsed, err := parseReadings(techniqueReadings(readings, technique))
+	if err != nil {
+		return nil
+	}
+	if len(parsed) == 0 {
+		if technique != spectra.TechniqueReflectance || len(analysis.Spectrum) == 0 {
+			return nil
+		}
+		spectrum, err := spectra.Packed(analysis.Spectrum).Decode()
+		if err != nil {
+			return nil
+		}
+		parsed = []spectra.Spectrum{spectrum}
+	}
+
+	aggregate, err := spectra.Combine(parsed, analysisOutlierRule(analysis))
+	if err != nil {
+		return nil
+	}
+	return &aggregate
+}
+
+// combineAnalysisSpectra усредняет спектры отражения заявки
+func combineAnalysisSpectra(analysis *ds.SpectrumAnalysis, readings []ds.SpectrumReading) *spectra.Aggregate {
+	return combineTechnique(analysis, readings, spectra.TechniqueReflectance)
+}
+
+// combineAllTechniques усредняет измерения каждой методики; методик без спектра в результате нет
+func combineAllTechniques(analysis *ds.SpectrumAnalysis, readings []ds.SpectrumReading) map[string]*spectra.Aggregate {
+	aggregates := make(map[string]*spectra.Aggregate)
+	for _, technique := range spectra.Techniques {
+		if aggregate := combineTechnique(analysis, readings, technique); aggregate != nil {
+			aggregates[technique] = aggregate
+		}
+	}
+	return aggregates
+}
+
+// newReadingsResponse сериализует измерения с их отклонениями от сводки своей методики
+func newReadingsResponse(readings []ds.SpectrumReading, aggregates map[string]*spectra.Aggregate) []types.SpectrumReading {
+	var response []types.SpectrumReading
+	positions := make(map[string]int) // номер измерения внутри своей методики
+	for _, reading := range readings {
+		item := newReadingResponse(reading)
+		if item.Uncalibrated {
+			response = append(response, item)
+			continue
+		}
+		i := positions[reading.Technique]
+		positions[reading.Technique]++
+		if aggregate := aggregates[reading.Technique]; aggregate != nil && i < len(aggregate.Rejected) {
+			item.Deviation = aggregate.Deviations[i]
+			item.Rejected = aggregate.Rejected[i]
+		}
+		response = append(response, item)
+	}
+	return response
+}
+
+func newReadingResponse(reading ds.SpectrumReading) types.SpectrumReading {
+	return types.SpectrumReading{
+		ID:            reading.ID,
+		Technique:     reading.Technique,
+		Spectrum:      reading.Spectrum,
+		Note:          reading.Note,
+		CreatedAt:     reading.CreatedAt,
+		InstrumentID:  reading.InstrumentID,
+		CalibrationID: reading.CalibrationID,
+		MeasuredAt:    reading.MeasuredAt,
+		Raw:           reading.Raw,
+		Uncalibrated:  uncalibrated(reading),
+	}
+}
+
+// newAggregateResponse сериализует сводку измерений методики; nil, если спектра нет
+func newAggregateResponse(analysis *ds.SpectrumAnalysis, technique string, aggregate *spectra.Aggregate) *types.SpectrumAggregate {
+	if aggregate == nil {
+		return nil
+	}
+	axis, _ := spectra.TechniqueAxis(technique)
+	rule := analysisOutlierRule(analysis)
+	response := &types.SpectrumAggregate{
+		Technique:   technique,
+		Unit:        axis.Unit,
+		Wavelengths: aggregate.Mean.Wavelengths(),
+		Mean:        aggregate.Mean.Values(),
+		Std:         aggregate.Std,
+		Readings:    len(aggregate.Rejected),
+		Used:        aggregate.Used,
+		Rule:        types.OutlierRule{Method: rule.Method},
+	}
+	if rule.Method != spectra.OutlierNone {
+		response.Rule.Threshold = &rule.Threshold
+	}
+	return response
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/refindex"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/spectra"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /api/pigments/:id/references - эталонные спектры пигмента (technique - фильтр по методике)
+func (h *PigmentHandler) GetReferenceSpectra(c *gin.Context) {
+	id, ok := parsePigmentID(c)
+	if !ok {
+		return
+	}
+	technique := c.Query("technique")
+	if _, known := spectra.TechniqueAxis(technique); technique != "" && !known {
+		c.JSON(http.StatusBadRequest, types.Fail("Неизвестная методика: ожидается reflectance, raman или xrf"))
+		return
+	}
+
+	if _, err := h.Pigments.GetPigment(c.Request.Context(), id, true); err != nil {
+		respondPigmentError(c, err, "Ошибка получения эталонов")
+		return
+	}
+	references, err := h.Pigments.ListReferenceSpectra(c.Request.Context(), id, technique)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения эталонов"))
+		return
+	}
+
+	response := make([]types.ReferenceSpectrumResponse, len(references))
+	for i, reference := range references {
+		response[i] = newReferenceSpectrumResponse(reference)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"references": response,
+		"count":      len(response),
+	})
+}
+
+// POST /api/pigments/:id/references - добавить эталонный спектр
+func (h *PigmentHandler) AddReferenceSpectrum(c *gin.Context) {
+	id, ok := parsePigmentID(c)
+	if !ok {
+		return
+	}
+
+	var request types.AddReferenceSpectrumRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Методика и спектр обязательны"))
+		return
+	}
+	spectrum, err := spectra.ParseTechnique(request.Spectrum, request.Technique)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный спектр: "+err.Error()))
+		return
+	}
+
+	if _, err := h.Pigments.GetPigment(c.Request.Context(), id, false); err != nil {
+		respondPigmentError(c, err, "Ошибка добавления эталона")
+		return
+	}
+
+	reference := ds.ReferenceSpectrum{
+		PigmentID: id,
+		Technique: request.Technique,
+		Spectrum:  spectrum.String(),
+		Source:    strings.TrimSpace(request.Source),
+		Features:  refindex.ReferenceFeatures(request.Technique, spectrum),
+	}
+	if err := h.Pigments.AddReferenceSpectrum(c.Request.Context(), &reference); err != nil {
+		respondPigmentError(c, err, "Ошибка добавления эталона")
+		return
+	}
+	h.References.Put(reference)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"reference": newReferenceSpectrumResponse(reference),
+	})
+}
+
+// DELETE /api/pigments/:id/references/:reference_id - удалить эталонный спектр
+func (h *PigmentHandler) DeleteReferenceSpectrum(c *gin.Context) {
+	id, ok := parsePigmentID(c)
+	if !ok {
+		return
+	}
+	referenceID, err := strconv.ParseUint(c.Param("reference_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный ID эталона"))
+		return
+	}
+
+	err = h.Pigments.DeleteReferenceSpectrum(c.Request.Context(), id, uint(referenceID))
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, types.Fail("Эталон не найден"))
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка удаления эталона"))
+		return
+	}
+	h.References.Remove(uint(referenceID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Эталон удалён",
+	})
+}
+
+func newReferenceSpectrumResponse(reference ds.ReferenceSpectrum) types.ReferenceSpectrumResponse {
+	axis, _ := spectra.TechniqueAxis(reference.Technique)
+	return types.ReferenceSpectrumResponse{
+		ID:        reference.ID,
+		PigmentID: reference.PigmentID,
+		Technique: reference.Technique,
+		Unit:      axis.Unit,
+		Spectrum:  reference.Spectrum,
+		Source:    reference.Source,
+		CreatedAt: reference.CreatedAt,
+	}
+}
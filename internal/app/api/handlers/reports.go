@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/report"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReportHandler struct {
+	Reports *report.Job
+}
+
+func NewReportHandler(reports *report.Job) *ReportHandler {
+	return &ReportHandler{Reports: reports}
+}
+
+// GET /api/reports/palette - группы похожих палитр и совместная встречаемость пигментов
+// по всем завершённым заявкам. Отчёт считается в фоне; пока его нет, отвечаем 202
+func (h *ReportHandler) GetPaletteReport(c *gin.Context) {
+	palette, err := h.Reports.Latest(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения отчёта"))
+		return
+	}
+	if palette == nil {
+		h.Reports.Refresh()
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "Отчёт ещё считается, повторите запрос позже",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"report": newPaletteReport(palette),
+	})
+}
+
+// POST /api/reports/palette/refresh - пересчитать отчёт, не дожидаясь планового пересчёта
+func (h *ReportHandler) RefreshPaletteReport(c *gin.Context) {
+	h.Reports.Refresh()
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Отчёт будет пересчитан",
+	})
+}
+
+func newPaletteReport(palette *report.Palette) types.PaletteReport {
+	response := types.PaletteReport{
+		ComputedAt:   palette.ComputedAt,
+		Analyses:     palette.Analyses,
+		PeriodYears:  palette.Options.PeriodYears,
+		Pigments:     make([]types.ReportPigment, len(palette.Pigments)),
+		Clusters:     make([]types.PaletteCluster, len(palette.Clusters)),
+		Cooccurrence: newCooccurrence(palette.Overall),
+		Periods:      make([]types.PeriodCooccurrence, len(palette.Periods)),
+	}
+	for i, pigment := range palette.Pigments {
+		response.Pigments[i] = types.ReportPigment{
+			ID:       pigment.ID,
+			Name:     pigment.Name,
+			Color:    pigment.Color,
+			Archived: pigment.Archived,
+		}
+	}
+	for i, cluster := range palette.Clusters {
+		item := types.PaletteCluster{
+			Size:     cluster.Size,
+			Centroid: make([]types.ClusterShare, len(cluster.Centroid)),
+			Spread:   roundTo(cluster.Spread, 4),
+			YearFrom: cluster.YearFrom,
+			YearTo:   cluster.YearTo,
+			Examples: make([]string, len(cluster.Examples)),
+		}
+		for j, share := range cluster.Centroid {
+			item.Centroid[j] = types.ClusterShare{PigmentID: share.PigmentID, Percent: roundTo(share.Percent, 2)}
+		}
+		for j, id := range cluster.Examples {
+			item.Examples[j] = id.String()
+		}
+		response.Clusters[i] = item
+	}
+	for i, period := range palette.Periods {
+		response.Periods[i] = types.PeriodCooccurrence{
+			From:                period.From,
+			To:                  period.To,
+			PigmentCooccurrence: newCooccurrence(period.Cooccurrence),
+		}
+	}
+	if palette.Undated.Analyses > 0 {
+		undated := newCooccurrence(palette.Undated)
+		response.Undated = &undated
+	}
+	return response
+}
+
+func newCooccurrence(stats report.Cooccurrence) types.PigmentCooccurrence {
+	response := types.PigmentCooccurrence{
+		Analyses: stats.Analyses,
+		Pigments: make([]types.PigmentFrequency, len(stats.Pigments)),
+		Pairs:    make([]types.PigmentPair, len(stats.Pairs)),
+	}
+	for i, frequency := range stats.Pigments {
+		response.Pigments[i] = types.PigmentFrequency{
+			PigmentID: frequency.PigmentID,
+			Analyses:  frequency.Analyses,
+			Share:     roundTo(frequency.Share, 4),
+		}
+	}
+	for i, pair := range stats.Pairs {
+		response.Pairs[i] = types.PigmentPair{
+			First:    pair.First,
+			Second:   pair.Second,
+			Analyses: pair.Analyses,
+			Jaccard:  roundTo(pair.Jaccard, 4),
+			Lift:     roundTo(pair.Lift, 4),
+		}
+	}
+	return response
+}
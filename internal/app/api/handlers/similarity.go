@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/composition"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSimilar - ограничение на число похожих заявок в ответе
+const maxSimilar = 50
+
+// GET /api/spectrum-analysis/:id/similar - завершённые заявки с похожим пигментным составом.
+// Сравниваются доли пигментов; в ответ попадают только заявки, которые видит пользователь
+func (h *SpectrumAnalysisHandler) GetSimilarAnalyses(c *gin.Context) {
+	var filter types.SimilarityFilter
+	if err := c.BindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверные параметры поиска"))
+		return
+	}
+	if filter.Metric == "" {
+		filter.Metric = composition.MetricCosine
+	}
+	if !slices.Contains(composition.Metrics, filter.Metric) {
+		c.JSON(http.StatusBadRequest, types.Fail("Неизвестная мера сходства: ожидается "+strings.Join(composition.Metrics, " или ")))
+		return
+	}
+	if filter.Limit <= 0 || filter.Limit > maxSimilar {
+		c.JSON(http.StatusBadRequest, types.Fail(fmt.Sprintf("Число результатов должно быть от 1 до %d", maxSimilar)))
+		return
+	}
+
+	analysis, ok := h.loadAnalysis(c, "Ошибка поиска похожих заявок")
+	if !ok {
+		return
+	}
+	if analysis.Status == ds.StatusDeleted || !analysisVisible(c, analysis) {
+		c.JSON(http.StatusNotFound, types.Fail("Заявка не найдена"))
+		return
+	}
+
+	pigments, err := h.Analyses.ListAnalysisPigments(c.Request.Context(), analysis.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка поиска похожих заявок"))
+		return
+	}
+	query, byID := compositionVector(pigments)
+	if len(query) == 0 {
+		c.JSON(http.StatusBadRequest, types.Fail("В заявке нет долей пигментов: сравнение возможно после завершения"))
+		return
+	}
+
+	stored, err := h.Analyses.ListCompositions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка поиска похожих заявок"))
+		return
+	}
+	var candidates []repository.Composition
+	var vectors []composition.Vector
+	for _, candidate := range stored {
+		if candidate.Analysis.ID == analysis.ID || !analysisVisible(c, &candidate.Analysis) {
+			continue
+		}
+		vector, candidateByID := compositionVector(candidate.Pigments)
+		for id, pigment := range candidateByID {
+			byID[id] = pigment
+		}
+		candidates = append(candidates, candidate)
+		vectors = append(vectors, vector)
+	}
+
+	response := types.SimilarityResponse{
+		AnalysisID:  analysis.ID.String(),
+		Metric:      filter.Metric,
+		Composition: make([]types.CompositionPigment, 0, len(query)),
+		Matches:     []types.SimilarAnalysis{},
+	}
+	// Compare с пустым составом упорядочивает исходный состав по убыванию доли
+	for _, share := range composition.Compare(query, nil).QueryOnly {
+		response.Composition = append(response.Composition, newCompositionPigment(byID[share.PigmentID], share.Query))
+	}
+	for _, match := range composition.Nearest(query, vectors, filter.Metric, filter.Limit) {
+		candidate := candidates[match.Index].Analysis
+		breakdown := composition.Compare(query, vectors[match.Index])
+		item := types.SimilarAnalysis{
+			AnalysisID:  candidate.ID.String(),
+			Name:        candidate.Name,
+			ArtworkID:   candidate.ArtworkID,
+			CompletedAt: candidate.CompletedAt,
+			Similarity:  roundTo(match.Similarity, 4),
+			Shared:      make([]types.SharedPigment, len(breakdown.Shared)),
+		}
+		for i, share := range breakdown.Shared {
+			pigment := byID[share.PigmentID]
+			item.Shared[i] = types.SharedPigment{
+				PigmentID:    pigment.ID,
+				Name:         pigment.Name,
+				Color:        pigment.Color,
+				Percent:      share.Query,
+				MatchPercent: share.Other,
+				Archived:     pigment.DeletedAt.Valid,
+			}
+		}
+		for _, share := range breakdown.QueryOnly {
+			item.OnlyHere = append(item.OnlyHere, newCompositionPigment(byID[share.PigmentID], share.Query))
+		}
+		for _, share := range breakdown.OtherOnly {
+			item.OnlyThere = append(item.OnlyThere, newCompositionPigment(byID[share.PigmentID], share.Other))
+		}
+		response.Matches = append(response.Matches, item)
+	}
+	response.Count = len(response.Matches)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// compositionVector собирает доли пигментов заявки; пигменты без доли не входят в состав
+func compositionVector(pigments []repository.AnalysisPigment) (composition.Vector, map[uint]ds.Pigment) {
+	vector := make(composition.Vector)
+	byID := make(map[uint]ds.Pigment)
+	for _, ap := range pigments {
+		if ap.Link.Percent > 0 {
+			vector[ap.Pigment.ID] = ap.Link.Percent
+			byID[ap.Pigment.ID] = ap.Pigment
+		}
+	}
+	return vector, byID
+}
+
+func newCompositionPigment(pigment ds.Pigment, percent float64) types.CompositionPigment {
+	return types.CompositionPigment{
+		PigmentID: pigment.ID,
+		Name:      pigment.Name,
+		Color:     pigment.Color,
+		Percent:   percent,
+		Archived:  pigment.DeletedAt.Valid,
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"colorLex/internal/app/api/types"
@@ -8,15 +9,15 @@ import (
 	"colorLex/internal/app/repository"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
+	"github.com/google/uuid"
 )
 
 type SpectrumAnalysisPigmentsHandler struct {
-	Repository *repository.Repository
+	Analyses repository.AnalysisStore
 }
 
-func NewSpectrumAnalysisPigmentsHandler(repo *repository.Repository) *SpectrumAnalysisPigmentsHandler {
-	return &SpectrumAnalysisPigmentsHandler{Repository: repo}
+func NewSpectrumAnalysisPigmentsHandler(analyses repository.AnalysisStore) *SpectrumAnalysisPigmentsHandler {
+	return &SpectrumAnalysisPigmentsHandler{Analyses: analyses}
 }
 
 // DELETE /api/spectrumAnalysis-pigments - удаление пигмента из заявки
@@ -34,9 +35,8 @@ func (h *SpectrumAnalysisPigmentsHandler) DeleteSpectrumAnalysisPigment(c *gin.C
 	currentUserID := uint(1)
 
 	// Проверяем права - пользователь должен быть создателем заявки
-	var analysis ds.SpectrumAnalysis
-	if err := h.Repository.GetDB().Unscoped().First(&analysis, "id = ?", request.SpectrumAnalysisID).Error; err != nil {
-		c.JSON(http.StatusNotFound, types.Fail("Заявка не найдена"))
+	analysis, ok := h.loadAnalysis(c, request.SpectrumAnalysisID)
+	if !ok {
 		return
 	}
 
@@ -45,27 +45,24 @@ func (h *SpectrumAnalysisPigmentsHandler) DeleteSpectrumAnalysisPigment(c *gin.C
 		return
 	}
 
-	// Можно удалять только из черновиков
-	if analysis.Status != "draft" {
-		c.JSON(http.StatusBadRequest, types.Fail("Можно удалять пигменты только из черновиков"))
+	if !checkIfMatch(c, analysis) {
 		return
 	}
 
-	// Удаляем связь
-	result := h.Repository.GetDB().
-		Where("spectrum_analysis_id = ? AND pigment_id = ?", request.SpectrumAnalysisID, request.PigmentID).
-		Delete(&ds.SpectrumAnalysisPigment{})
-
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка удаления пигмента из заявки"))
+	// Можно удалять только из черновиков
+	if analysis.Status != ds.StatusDraft {
+		c.JSON(http.StatusBadRequest, types.Fail("Можно удалять пигменты только из черновиков"))
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, types.Fail("Пигмент не найден в заявке"))
+	// Удаляем связь
+	err := h.Analyses.RemoveAnalysisPigment(c.Request.Context(), analysis, request.PigmentID)
+	if err != nil {
+		respondLinkWriteError(c, err, "Ошибка удаления пигмента из заявки")
 		return
 	}
 
+	setAnalysisETag(c, analysis)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Пигмент удален из заявки",
 	})
@@ -88,9 +85,8 @@ func (h *SpectrumAnalysisPigmentsHandler) UpdateSpectrumAnalysisPigment(c *gin.C
 	currentUserID := uint(1)
 
 	// Проверяем права - пользователь должен быть создателем заявки
-	var analysis ds.SpectrumAnalysis
-	if err := h.Repository.GetDB().Unscoped().First(&analysis, "id = ?", request.RequestID).Error; err != nil {
-		c.JSON(http.StatusNotFound, types.Fail("Заявка не найдена"))
+	analysis, ok := h.loadAnalysis(c, request.RequestID)
+	if !ok {
 		return
 	}
 
@@ -99,14 +95,14 @@ func (h *SpectrumAnalysisPigmentsHandler) UpdateSpectrumAnalysisPigment(c *gin.C
 		return
 	}
 
-	// Находим существующую связь
-	var spectrumAnalysisPigment ds.SpectrumAnalysisPigment
-	err := h.Repository.GetDB().
-		Where("spectrum_analysis_id = ? AND pigment_id = ?", request.RequestID, request.PigmentID).
-		First(&spectrumAnalysisPigment).Error
+	if !checkIfMatch(c, analysis) {
+		return
+	}
 
+	// Находим существующую связь
+	spectrumAnalysisPigment, err := h.Analyses.GetAnalysisPigment(c.Request.Context(), analysis.ID, request.PigmentID)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
+		if errors.Is(err, repository.ErrNotFound) {
 			c.JSON(http.StatusNotFound, types.Fail("Пигмент не найден в заявке"))
 		} else {
 			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка обновления связи"))
@@ -115,30 +111,26 @@ func (h *SpectrumAnalysisPigmentsHandler) UpdateSpectrumAnalysisPigment(c *gin.C
 	}
 
 	// Обновляем только переданные поля
-	updates := make(map[string]interface{})
+	if request.Comment == "" && request.Percent <= 0 {
+		c.JSON(http.StatusBadRequest, types.Fail("Нет данных для обновления"))
+		return
+	}
 	if request.Comment != "" {
-		updates["comment"] = request.Comment
+		spectrumAnalysisPigment.Comment = request.Comment
 	}
 	if request.Percent > 0 {
 		if request.Percent > 100.0 {
 			c.JSON(http.StatusBadRequest, types.Fail("Процент не может превышать 100"))
 			return
 		}
-		updates["percent"] = request.Percent
+		spectrumAnalysisPigment.Percent = request.Percent
 	}
 
-	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, types.Fail("Нет данных для обновления"))
+	if err := h.Analyses.UpdateAnalysisPigment(c.Request.Context(), analysis, spectrumAnalysisPigment); err != nil {
+		respondLinkWriteError(c, err, "Ошибка обновления связи")
 		return
 	}
-
-	if err := h.Repository.GetDB().Model(&spectrumAnalysisPigment).Updates(updates).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка обновления связи"))
-		return
-	}
-
-	// Получаем обновленную связь
-	h.Repository.GetDB().First(&spectrumAnalysisPigment)
+	setAnalysisETag(c, analysis)
 
 	response := types.SpectrumAnalysisPigmentResponse{
 		PigmentID: spectrumAnalysisPigment.PigmentID,
@@ -152,3 +144,35 @@ func (h *SpectrumAnalysisPigmentsHandler) UpdateSpectrumAnalysisPigment(c *gin.C
 		"spectrumAnalysis_pigment": response,
 	})
 }
+
+// loadAnalysis находит заявку по ID из тела запроса. При ошибке сам пишет ответ
+func (h *SpectrumAnalysisPigmentsHandler) loadAnalysis(c *gin.Context, rawID string) (*ds.SpectrumAnalysis, bool) {
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, types.Fail("Заявка не найдена"))
+		return nil, false
+	}
+
+	analysis, err := h.Analyses.GetAnalysis(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, types.Fail("Заявка не найдена"))
+		} else {
+			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения заявки"))
+		}
+		return nil, false
+	}
+	return analysis, true
+}
+
+// respondLinkWriteError отвечает 412 на конфликт версий заявки и 404 на отсутствующую связь
+func respondLinkWriteError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, repository.ErrVersionConflict):
+		respondVersionConflict(c)
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, types.Fail("Пигмент не найден в заявке"))
+	default:
+		c.JSON(http.StatusInternalServerError, types.Fail(message))
+	}
+}
@@ -2,23 +2,40 @@ package handlers
 
 import (
 	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/dating"
 	"colorLex/internal/app/ds"
+	"colorLex/internal/app/refindex"
 	"colorLex/internal/app/repository"
+	"colorLex/internal/app/spectra"
+	"colorLex/internal/app/unmix"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand/v2"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
 type SpectrumAnalysisHandler struct {
-	Repository *repository.Repository
+	Analyses    repository.AnalysisStore
+	Artworks    repository.ArtworkStore
+	Pigments    repository.PigmentStore
+	Instruments repository.InstrumentStore
+	References  *refindex.Index // ближайшие эталоны отражения для идентификации
+	// RequireCalibration - завершать заявку только по измерениям откалиброванных приборов:
+	// измерения без прибора и спектр, заданный в самой заявке, тогда не принимаются
+	RequireCalibration bool
 }
 
-func NewSpectrumAnalysisHandler(repo *repository.Repository) *SpectrumAnalysisHandler {
-	return &SpectrumAnalysisHandler{Repository: repo}
+func NewSpectrumAnalysisHandler(analyses repository.AnalysisStore, artworks repository.ArtworkStore, pigments repository.PigmentStore, instruments repository.InstrumentStore, references *refindex.Index) *SpectrumAnalysisHandler {
+	return &SpectrumAnalysisHandler{Analyses: analyses, Artworks: artworks, Pigments: pigments, Instruments: instruments, References: references}
 }
 
 // GetCart godoc
@@ -39,12 +56,9 @@ func (h *SpectrumAnalysisHandler) GetCart(c *gin.Context) {
 		return
 	}
 
-	var analysis ds.SpectrumAnalysis
-	err := h.Repository.GetDB().
-		Where("creator_id = ? AND status = ?", userID, "draft").
-		First(&analysis).Error
+	analysis, err := h.Analyses.FindDraft(c.Request.Context(), userID.(uint))
 
-	if err == gorm.ErrRecordNotFound {
+	if errors.Is(err, repository.ErrNotFound) {
 		// Нет активной заявки-черновика
 		c.JSON(http.StatusOK, gin.H{
 			"analysis_id": nil,
@@ -58,14 +72,30 @@ func (h *SpectrumAnalysisHandler) GetCart(c *gin.Context) {
 	}
 
 	// Есть активная заявка, считаем количество пигментов
-	var count int64
-	h.Repository.GetDB().Model(&ds.SpectrumAnalysisPigment{}).
-		Where("spectrum_analysis_id = ?", analysis.ID).Count(&count)
+	count, err := h.Analyses.CountAnalysisPigments(c.Request.Context(), analysis.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения корзины"))
+		return
+	}
+
+	// Предупреждаем о рискованных сочетаниях ещё до формирования заявки
+	analysisPigments, err := h.Analyses.ListAnalysisPigments(c.Request.Context(), analysis.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения корзины"))
+		return
+	}
+	warnings, products, err := h.interactionWarnings(c.Request.Context(), analysis, analysisPigments)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения корзины"))
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"analysis_id": analysis.ID,
 		"items_count": count,
 		"has_active_cart": true,
+		"warnings": warnings,
+		"degradation_products": products,
 	})
 }
 
@@ -77,6 +107,7 @@ func (h *SpectrumAnalysisHandler) GetCart(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param status query string false "Фильтр по статусу"
+// @Param artwork_id query int false "Фильтр по произведению"
 // @Param date_from query string false "Дата начала (RFC3339)"
 // @Param date_to query string false "Дата окончания (RFC3339)"
 // @Param limit query int false "Лимит записей" default(10)
@@ -99,27 +130,28 @@ func (h *SpectrumAnalysisHandler) GetSpectrumAnalyses(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, types.Fail("Неверные параметры фильтрации"))
 		return
 	}
+	format, ok := parseSpectrumFormat(c, filter.SpectrumFormat)
+	if !ok {
+		return
+	}
 
-	var analyses []ds.SpectrumAnalysis
-	db := h.Repository.GetDB().Unscoped().Where("status != ? AND status != ?", "draft", "deleted")
+	query := repository.AnalysisQuery{
+		Status:     filter.Status,
+		ArtworkID:  filter.ArtworkID,
+		FormedFrom: filter.DateFrom,
+		FormedTo:   filter.DateTo,
+		Limit:      filter.Limit,
+		Offset:     filter.Offset,
+	}
 
 	// Если пользователь не модератор, показываем только его заявки
 	if !isModerator.(bool) {
-		db = db.Where("creator_id = ?", userID)
-	}
-
-	// Применяем фильтры
-	if filter.Status != "" {
-		db = db.Where("status = ?", filter.Status)
-	}
-	if !filter.DateFrom.IsZero() {
-		db = db.Where("formed_at >= ?", filter.DateFrom)
-	}
-	if !filter.DateTo.IsZero() {
-		db = db.Where("formed_at <= ?", filter.DateTo)
+		creatorID := userID.(uint)
+		query.CreatorID = &creatorID
 	}
 
-	if err := db.Limit(filter.Limit).Offset(filter.Offset).Find(&analyses).Error; err != nil {
+	analyses, err := h.Analyses.ListAnalyses(c.Request.Context(), query)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения заявок"))
 		return
 	}
@@ -131,12 +163,13 @@ func (h *SpectrumAnalysisHandler) GetSpectrumAnalyses(c *gin.Context) {
 			ID:          analysis.ID.String(),
 			Name:        analysis.Name,
 			Status:      analysis.Status,
-			Spectrum:    analysis.Spectrum,
 			CreatedAt:   analysis.CreatedAt,
 			FormedAt:    analysis.FormedAt,
 			CompletedAt: analysis.CompletedAt,
 			CreatorID:   analysis.CreatorID,
+			ArtworkID:   analysis.ArtworkID,
 		}
+		setResponseSpectrum(&response[i], &analysis, format)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -147,73 +180,133 @@ func (h *SpectrumAnalysisHandler) GetSpectrumAnalyses(c *gin.Context) {
 
 // GET /api/spectrum-analysis/{id} - детали заявки
 func (h *SpectrumAnalysisHandler) GetSpectrumAnalysis(c *gin.Context) {
-	id := c.Param("id")
-
-	var analysis ds.SpectrumAnalysis
-	if err := h.Repository.GetDB().Unscoped().First(&analysis, "id = ?", id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, types.Fail("Заявка не найдена"))
-		} else {
-			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения заявки"))
-		}
+	format, ok := parseSpectrumFormat(c, c.Query("spectrum_format"))
+	if !ok {
+		return
+	}
+	analysis, ok := h.loadAnalysis(c, "Ошибка получения заявки")
+	if !ok {
 		return
 	}
 
 	// Проверяем статус заявки
-	if analysis.Status == "deleted" {
+	if analysis.Status == ds.StatusDeleted {
 		c.JSON(http.StatusNotFound, types.Fail("Заявка была удалена"))
 		return
 	}
 
-	// Получаем пигменты заявки
-	var pigments []ds.Pigment
-	var spectrumAnalysisPigments []ds.SpectrumAnalysisPigment
-
-	h.Repository.GetDB().
-		Joins("JOIN spectrumAnalysis_pigment ON spectrumAnalysis_pigment.pigment_id = pigments.id").
-		Where("spectrumAnalysis_pigment.spectrum_analysis_id = ?", id).
-		Find(&pigments)
-
-	h.Repository.GetDB().Where("spectrum_analysis_id = ?", id).Find(&spectrumAnalysisPigments)
-
-	// Формируем ответ с пигментами
-	pigmentMap := make(map[uint]types.PigmentInAnalysis)
-	for _, pig := range pigments {
-		pigmentMap[pig.ID] = types.PigmentInAnalysis{
-			PigmentID: pig.ID,
-			Name:      pig.Name,
-			Brief:     pig.Brief,
-			ImageKey:  pig.ImageKey,
-			Comment:   "",
-			Percent:   0.0,
-		}
+	// Получаем пигменты заявки (архивные тоже)
+	analysisPigments, err := h.Analyses.ListAnalysisPigments(c.Request.Context(), analysis.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения заявки"))
+		return
 	}
 
-	for _, rp := range spectrumAnalysisPigments {
-		if pig, exists := pigmentMap[rp.PigmentID]; exists {
-			pig.Comment = rp.Comment
-			pig.Percent = rp.Percent
-			pigmentMap[rp.PigmentID] = pig
+	// Формируем ответ с пигментами
+	pigmentsResponse := make([]types.PigmentInAnalysis, len(analysisPigments))
+	for i, ap := range analysisPigments {
+		pigmentsResponse[i] = types.PigmentInAnalysis{
+			PigmentID: ap.Pigment.ID,
+			Name:      ap.Pigment.Name,
+			Brief:     ap.Pigment.Brief,
+			ImageKey:  ap.Pigment.ImageKey,
+			Comment:   ap.Link.Comment,
+			Percent:   ap.Link.Percent,
+			Archived:  ap.Pigment.DeletedAt.Valid,
+
+			PercentInterval:   newPercentInterval(ap.Link),
+			Indistinguishable: parseIDs(ap.Link.Indistinguishable),
+
+			Verdict:     ap.Link.Verdict,
+			VerdictNote: ap.Link.VerdictNote,
+			RamanScore:  ap.Link.RamanScore,
+
+			AvailableFrom: ap.Pigment.AvailableFrom,
+			AvailableTo:   ap.Pigment.AvailableTo,
 		}
 	}
 
-	pigmentsResponse := make([]types.PigmentInAnalysis, 0, len(pigmentMap))
-	for _, pig := range pigmentMap {
-		pigmentsResponse = append(pigmentsResponse, pig)
-	}
-
 	response := types.SpectrumAnalysisResponse{
 		ID:          analysis.ID.String(),
 		Name:        analysis.Name,
 		Status:      analysis.Status,
-		Spectrum:    analysis.Spectrum,
 		CreatedAt:   analysis.CreatedAt,
 		FormedAt:    analysis.FormedAt,
 		CompletedAt: analysis.CompletedAt,
 		CreatorID:   analysis.CreatorID,
 		Pigments:    pigmentsResponse,
+		ArtworkID:   analysis.ArtworkID,
+		ClaimedYear: analysis.ClaimedYear,
+	}
+	setResponseSpectrum(&response, analysis, format)
+
+	response.Point, err = h.loadMeasurementPoint(c, analysis)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения заявки"))
+		return
+	}
+
+	response.Stratigraphy, err = h.loadStratigraphy(c.Request.Context(), analysis)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения заявки"))
+		return
+	}
+
+	readings, err := h.Analyses.ListReadings(c.Request.Context(), analysis.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения заявки"))
+		return
+	}
+	aggregates := combineAllTechniques(analysis, readings)
+	aggregate := aggregates[spectra.TechniqueReflectance]
+	response.Readings = newReadingsResponse(readings, aggregates)
+	response.Aggregate = newAggregateResponse(analysis, spectra.TechniqueReflectance, aggregate)
+	for _, technique := range []string{spectra.TechniqueRaman, spectra.TechniqueXRF} {
+		if other := newAggregateResponse(analysis, technique, aggregates[technique]); other != nil {
+			response.TechniqueAggregates = append(response.TechniqueAggregates, *other)
+		}
+	}
+
+	// Предобработка считается на лету; если шаги не подходят к спектру, отдаём причину
+	response.Preprocessing = newPreprocessingResponse(analysisPipeline(analysis))
+	if response.Processed, err = processAggregate(analysis, aggregate); err != nil {
+		response.ProcessingError = err.Error()
 	}
 
+	// Датировка: terminus post quem сохраняется при завершении, анахронизмы
+	// считаются на лету, чтобы учитывать исправленные годы доступности пигментов
+	uses := pigmentUses(analysisPigments)
+	if analysis.TerminusPostQuem != nil {
+		response.TerminusPostQuem = &types.TerminusPostQuem{Year: *analysis.TerminusPostQuem}
+		if terminus, ok := dating.TerminusPostQuem(uses); ok && terminus.Year == *analysis.TerminusPostQuem {
+			response.TerminusPostQuem.PigmentID = terminus.PigmentID
+			response.TerminusPostQuem.PigmentName = terminus.Name
+		}
+	}
+	year, err := h.datingYear(c.Request.Context(), analysis)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения заявки"))
+		return
+	}
+	if year != nil {
+		for _, a := range dating.Anachronisms(*year, uses) {
+			response.Anachronisms = append(response.Anachronisms, types.AnachronismEntry{
+				PigmentID:     a.PigmentID,
+				Name:          a.Name,
+				Reason:        a.Reason,
+				AvailableFrom: a.AvailableFrom,
+				AvailableTo:   a.AvailableTo,
+			})
+		}
+	}
+
+	response.Warnings, response.DegradationProducts, err = h.interactionWarnings(c.Request.Context(), analysis, analysisPigments)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения заявки"))
+		return
+	}
+
+	setAnalysisETag(c, analysis)
 	c.JSON(http.StatusOK, gin.H{
 		"analysis": response,
 	})
@@ -221,8 +314,6 @@ func (h *SpectrumAnalysisHandler) GetSpectrumAnalysis(c *gin.Context) {
 
 // PUT /api/spectrum-analysis/:id/form - сформировать заявку
 func (h *SpectrumAnalysisHandler) FormSpectrumAnalysis(c *gin.Context) {
-	id := c.Param("id")
-
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, types.Fail("Пользователь не аутентифицирован"))
@@ -230,15 +321,10 @@ func (h *SpectrumAnalysisHandler) FormSpectrumAnalysis(c *gin.Context) {
 	}
 	currentUserID := userID.(uint)
 
-	fmt.Printf("🔍 DEBUG: FormSpectrumAnalysis called with ID: %s\n", id)
+	fmt.Printf("🔍 DEBUG: FormSpectrumAnalysis called with ID: %s\n", c.Param("id"))
 
-	var analysis ds.SpectrumAnalysis
-	if err := h.Repository.GetDB().Unscoped().First(&analysis, "id = ?", id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, types.Fail("Заявка не найдена"))
-		} else {
-			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка поиска заявки"))
-		}
+	analysis, ok := h.loadAnalysis(c, "Ошибка поиска заявки")
+	if !ok {
 		return
 	}
 
@@ -252,37 +338,44 @@ func (h *SpectrumAnalysisHandler) FormSpectrumAnalysis(c *gin.Context) {
 		return
 	}
 
-	if analysis.Status != "draft" {
-		c.JSON(http.StatusBadRequest, types.Fail("Заявка уже сформирована или имеет неверный статус"))
+	if !checkIfMatch(c, analysis) {
 		return
 	}
 
-	// Проверяем обязательные поля
-	if analysis.Spectrum == "" {
-		c.JSON(http.StatusBadRequest, types.Fail("Спектр обязателен для формирования"))
+	if analysis.Status != ds.StatusDraft {
+		c.JSON(http.StatusBadRequest, types.Fail("Заявка уже сформирована или имеет неверный статус"))
 		return
 	}
 
+	// Проверяем обязательные поля: одиночный спектр или повторные измерения
+	if len(analysis.Spectrum) == 0 {
+		readings, err := h.Analyses.ListReadings(c.Request.Context(), analysis.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка формирования заявки"))
+			return
+		}
+		if len(readings) == 0 {
+			c.JSON(http.StatusBadRequest, types.Fail("Спектр обязателен для формирования"))
+			return
+		}
+	}
+
 	now := time.Now()
 
 	// ✅ ИСПОЛЬЗУЕМ ПРАВИЛЬНЫЙ СТАТУС 'created' вместо 'formed'
-	newStatus := "created"
+	newStatus := ds.StatusCreated
 
 	fmt.Printf("🔄 DEBUG: Updating status from '%s' to '%s'\n", analysis.Status, newStatus)
 
-	result := h.Repository.GetDB().Unscoped().Model(&analysis).Updates(map[string]interface{}{
-		"status":    newStatus,
-		"formed_at": now,
-	})
-
-	if result.Error != nil {
-		fmt.Printf("❌ DEBUG: Update error: %v\n", result.Error)
-		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка формирования заявки: "+result.Error.Error()))
+	analysis.Status = newStatus
+	analysis.FormedAt = &now
+	if err := h.Analyses.UpdateAnalysis(c.Request.Context(), analysis); err != nil {
+		fmt.Printf("❌ DEBUG: Update error: %v\n", err)
+		respondAnalysisWriteError(c, err, "Ошибка формирования заявки: "+err.Error())
 		return
 	}
 
-	fmt.Printf("✅ DEBUG: Update successful. Rows affected: %d\n", result.RowsAffected)
-
+	setAnalysisETag(c, analysis)
 	c.JSON(http.StatusOK, gin.H{
 		"message":   "Заявка успешно сформирована",
 		"formed_at": now,
@@ -292,7 +385,6 @@ func (h *SpectrumAnalysisHandler) FormSpectrumAnalysis(c *gin.Context) {
 
 // PUT /api/spectrum-analysis/:id - обновление полей заявки
 func (h *SpectrumAnalysisHandler) UpdateSpectrumAnalysis(c *gin.Context) {
-	id := c.Param("id")
 	currentUserID := uint(1) // TODO: Заглушка
 
 	var request types.UpdateSpectrumAnalysisRequest
@@ -302,13 +394,8 @@ func (h *SpectrumAnalysisHandler) UpdateSpectrumAnalysis(c *gin.Context) {
 	}
 
 	// Проверяем существование заявки
-	var analysis ds.SpectrumAnalysis
-	if err := h.Repository.GetDB().First(&analysis, "id = ?", id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, types.Fail("Заявка не найдена"))
-		} else {
-			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка обновления заявки"))
-		}
+	analysis, ok := h.loadAnalysis(c, "Ошибка обновления заявки")
+	if !ok {
 		return
 	}
 
@@ -318,42 +405,91 @@ func (h *SpectrumAnalysisHandler) UpdateSpectrumAnalysis(c *gin.Context) {
 		return
 	}
 
+	if !checkIfMatch(c, analysis) {
+		return
+	}
+
 	// Можно менять только черновики
-	if analysis.Status != "draft" {
+	if analysis.Status != ds.StatusDraft {
 		c.JSON(http.StatusBadRequest, types.Fail("Можно изменять только заявки в статусе черновика"))
 		return
 	}
 
 	// Обновляем только переданные поля
-	updates := make(map[string]interface{})
+	if request.Name == "" && request.Spectrum == "" && request.ClaimedYear == nil && request.ArtworkID == nil &&
+		request.OutlierRule == nil && request.Preprocessing == nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Нет данных для обновления"))
+		return
+	}
 	if request.Name != "" {
-		updates["name"] = request.Name
+		analysis.Name = request.Name
 	}
 	if request.Spectrum != "" {
-		updates["spectrum"] = request.Spectrum
+		spectrum, err := spectra.ParseTechnique(request.Spectrum, spectra.TechniqueReflectance)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, types.Fail("Неверный формат спектра: "+err.Error()))
+			return
+		}
+		if analysis.Spectrum, err = spectra.Pack(spectrum); err != nil {
+			c.JSON(http.StatusBadRequest, types.Fail("Неверный формат спектра: "+err.Error()))
+			return
+		}
+		analysis.SpectrumUnparsed = false
 	}
-
-	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, types.Fail("Нет данных для обновления"))
-		return
+	if request.ClaimedYear != nil {
+		if *request.ClaimedYear > time.Now().Year() {
+			c.JSON(http.StatusBadRequest, types.Fail("Заявленный год создания не может быть в будущем"))
+			return
+		}
+		claimedYear := *request.ClaimedYear
+		analysis.ClaimedYear = &claimedYear
+	}
+	if request.ArtworkID != nil {
+		if *request.ArtworkID == 0 {
+			analysis.ArtworkID = nil
+		} else {
+			artwork, err := h.Artworks.GetArtwork(c.Request.Context(), *request.ArtworkID)
+			if errors.Is(err, repository.ErrNotFound) {
+				c.JSON(http.StatusBadRequest, types.Fail("Произведение не найдено"))
+				return
+			} else if err != nil {
+				c.JSON(http.StatusInternalServerError, types.Fail("Ошибка обновления заявки"))
+				return
+			}
+			analysis.ArtworkID = &artwork.ID
+		}
+	}
+	if request.OutlierRule != nil {
+		if err := applyOutlierRule(analysis, *request.OutlierRule); err != nil {
+			c.JSON(http.StatusBadRequest, types.Fail(err.Error()))
+			return
+		}
+	}
+	if request.Preprocessing != nil {
+		if err := applyPreprocessing(analysis, *request.Preprocessing); err != nil {
+			c.JSON(http.StatusBadRequest, types.Fail("Неверные шаги предобработки: "+err.Error()))
+			return
+		}
 	}
 
-	if err := h.Repository.GetDB().Model(&analysis).Updates(updates).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка обновления заявки"))
+	if err := h.Analyses.UpdateAnalysis(c.Request.Context(), analysis); err != nil {
+		respondAnalysisWriteError(c, err, "Ошибка обновления заявки")
 		return
 	}
-
-	// Получаем обновленную заявку
-	h.Repository.GetDB().First(&analysis, "id = ?", id)
+	setAnalysisETag(c, analysis)
 
 	response := types.SpectrumAnalysisResponse{
-		ID:        analysis.ID.String(),
-		Name:      analysis.Name,
-		Status:    analysis.Status,
-		Spectrum:  analysis.Spectrum,
-		CreatedAt: analysis.CreatedAt,
-		CreatorID: analysis.CreatorID,
+		ID:          analysis.ID.String(),
+		Name:        analysis.Name,
+		Status:      analysis.Status,
+		CreatedAt:   analysis.CreatedAt,
+		CreatorID:   analysis.CreatorID,
+		ArtworkID:   analysis.ArtworkID,
+		ClaimedYear: analysis.ClaimedYear,
+
+		Preprocessing: newPreprocessingResponse(analysisPipeline(analysis)),
 	}
+	setResponseSpectrum(&response, analysis, spectrumExpanded)
 
 	c.JSON(http.StatusOK, gin.H{
 		"analysis": response,
@@ -368,7 +504,7 @@ func (h *SpectrumAnalysisHandler) UpdateSpectrumAnalysis(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "ID заявки"
-// @Param request body object{action=string} true "Действие: complete или reject"
+// @Param request body types.CompleteAnalysisRequest true "Действие: complete или reject; решения по пигментам"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} types.ErrorResponse
 // @Failure 401 {object} types.ErrorResponse
@@ -389,12 +525,7 @@ func (h *SpectrumAnalysisHandler) CompleteSpectrumAnalysis(c *gin.Context) {
 		return
 	}
 
-	id := c.Param("id")
-
-	var request struct {
-		Action string `json:"action" binding:"required"` // "complete" или "reject"
-	}
-
+	var request types.CompleteAnalysisRequest
 	if err := c.BindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, types.Fail("Неверный формат данных"))
 		return
@@ -405,73 +536,186 @@ func (h *SpectrumAnalysisHandler) CompleteSpectrumAnalysis(c *gin.Context) {
 		return
 	}
 
-	var analysis ds.SpectrumAnalysis
-	if err := h.Repository.GetDB().Unscoped().First(&analysis, "id = ?", id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, types.Fail("Заявка не найдена"))
-		} else {
-			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка завершения заявки"))
-		}
+	analysis, ok := h.loadAnalysis(c, "Ошибка завершения заявки")
+	if !ok {
+		return
+	}
+
+	if !checkIfMatch(c, analysis) {
 		return
 	}
 
 	// ✅ ПРОВЕРЯЕМ СТАТУС 'created' вместо 'formed'
-	if analysis.Status != "created" {
+	if analysis.Status != ds.StatusCreated {
 		c.JSON(http.StatusBadRequest, types.Fail("Можно завершать только созданные заявки"))
 		return
 	}
 
 	var newStatus string
+	var results map[uint]repository.PigmentResult
+	var accuracy float64
+	var raman []types.RamanExplanation
 	if request.Action == "complete" {
-		newStatus = "completed"
+		newStatus = ds.StatusCompleted
+
+		readings, err := h.Analyses.ListReadings(c.Request.Context(), analysis.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка завершения заявки"))
+			return
+		}
+		// Неисправленные отсчёты прибора несопоставимы с эталонами: их нужно удалить
+		// или загрузить заново после калибровки прибора
+		if ids := uncalibratedReadings(readings, h.RequireCalibration); len(ids) > 0 {
+			c.JSON(http.StatusBadRequest, types.UncalibratedReadingsResponse{
+				ErrorResponse: types.Fail("В заявке есть измерения без калибровки прибора"),
+				Readings:      ids,
+			})
+			return
+		}
+		if h.RequireCalibration && len(analysis.Spectrum) > 0 && len(techniqueReadings(readings, spectra.TechniqueReflectance)) == 0 {
+			c.JSON(http.StatusBadRequest, types.Fail("Спектр заявки получен не с откалиброванного прибора: добавьте измерения отражения с прибором"))
+			return
+		}
+		aggregate := combineAnalysisSpectra(analysis, readings)
+		if aggregate != nil && len(techniqueReadings(readings, spectra.TechniqueReflectance)) > 0 {
+			// Результатом анализа становится усреднённый спектр без отбракованных измерений
+			if analysis.Spectrum, err = spectra.Pack(aggregate.Mean); err != nil {
+				c.JSON(http.StatusInternalServerError, types.Fail("Ошибка завершения заявки"))
+				return
+			}
+		}
 
 		// ВЫЧИСЛЯЕМОЕ ПОЛЕ: расчет точности спектрального анализа
-		accuracy := h.calculateAnalysisAccuracy(analysis.ID)
+		accuracy = h.calculateAnalysisAccuracy(aggregate)
+
+		analysisPigments, err := h.Analyses.ListAnalysisPigments(c.Request.Context(), analysis.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка завершения заявки"))
+			return
+		}
+
+		verdicts, err := parseVerdicts(request.Verdicts, analysisPigments)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, types.Fail(err.Error()))
+			return
+		}
+
+		// Рамановские измерения сверяются с полосами пигментов заявки:
+		// модератор должен принять или отклонить каждый пигмент, который удалось сравнить
+		pigments := make([]ds.Pigment, len(analysisPigments))
+		for i, ap := range analysisPigments {
+			pigments[i] = ap.Pigment
+		}
+		ramanScores := make(map[uint]float64)
+		if result, err := h.explainRaman(c.Request.Context(), analysis, readings, pigments); err != nil {
+			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка завершения заявки"))
+			return
+		} else if result != nil {
+			raman, err = h.newRamanExplanations(c.Request.Context(), analysis, result)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, types.Fail("Ошибка завершения заявки"))
+				return
+			}
+			var undecided []string
+			for _, explanation := range raman {
+				ramanScores[explanation.PigmentID] = explanation.Score
+				if _, ok := verdicts[explanation.PigmentID]; !ok {
+					undecided = append(undecided, explanation.Name)
+				}
+			}
+			if len(undecided) > 0 {
+				c.JSON(http.StatusBadRequest, types.VerdictsRequiredResponse{
+					ErrorResponse: types.Fail("Примите или отклоните рамановскую идентификацию пигментов: " + strings.Join(undecided, ", ")),
+					Raman:         raman,
+				})
+				return
+			}
+		}
+
+		// Отклонённые пигменты не участвуют в распределении процентов и датировке
+		var accepted []repository.AnalysisPigment
+		for _, ap := range analysisPigments {
+			if verdicts[ap.Pigment.ID].Verdict != ds.VerdictRejected {
+				accepted = append(accepted, ap)
+			}
+		}
+
+		// Пересчитываем проценты пигментов на основе вычислений. Если у всех пигментов
+		// есть эталоны отражения, доли оцениваются разложением спектра с интервалами
+		percents := h.calculatePigmentPercentages(accepted, accuracy)
+		shares, err := h.estimateShares(c.Request.Context(), analysis, aggregate, accepted)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка завершения заявки"))
+			return
+		}
 
-		// Обновляем проценты пигментов на основе вычислений
-		h.updatePigmentPercentages(analysis.ID, accuracy)
+		results = make(map[uint]repository.PigmentResult, len(analysisPigments))
+		for i, ap := range analysisPigments {
+			result := verdicts[ap.Pigment.ID]
+			result.Percent = percents[ap.Pigment.ID]
+			if share, ok := shares[ap.Pigment.ID]; ok {
+				low, high := roundTo(100*share.Low, 1), roundTo(100*share.High, 1)
+				result.Percent = roundTo(100*share.Fraction, 1)
+				result.PercentLow, result.PercentHigh = &low, &high
+				result.Indistinguishable = formatIDs(share.Indistinguishable)
+			}
+			if score, ok := ramanScores[ap.Pigment.ID]; ok {
+				result.RamanScore = &score
+			}
+			results[ap.Pigment.ID] = result
+			analysisPigments[i].Link.Percent = result.Percent
+			analysisPigments[i].Link.Verdict = result.Verdict
+		}
+
+		// Нижняя граница датировки по значимым пигментам
+		analysis.TerminusPostQuem = nil
+		if terminus, ok := dating.TerminusPostQuem(pigmentUses(analysisPigments)); ok {
+			analysis.TerminusPostQuem = &terminus.Year
+		}
 
 	} else {
-		newStatus = "rejected"
+		newStatus = ds.StatusRejected
 	}
 
 	now := time.Now()
-	updates := map[string]interface{}{
-		"status":       newStatus,
-		"completed_at": now,
-		"moderator_id": userID,
-	}
+	moderatorID := userID.(uint)
+	analysis.Status = newStatus
+	analysis.CompletedAt = &now
+	analysis.ModeratorID = &moderatorID
 
-	if err := h.Repository.GetDB().Unscoped().Model(&analysis).Updates(updates).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка завершения заявки"))
+	if err := h.Analyses.CompleteAnalysis(c.Request.Context(), analysis, results); err != nil {
+		respondAnalysisWriteError(c, err, "Ошибка завершения заявки")
 		return
 	}
+	setAnalysisETag(c, analysis)
 
 	responseMessage := "Заявка отклонена"
 	if request.Action == "complete" {
 		responseMessage = "Заявка успешно завершена"
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":      responseMessage,
-		"status":       newStatus,
-		"completed_at": now,
-	})
+	response := gin.H{
+		"message":            responseMessage,
+		"status":             newStatus,
+		"completed_at":       now,
+		"terminus_post_quem": analysis.TerminusPostQuem,
+	}
+	if request.Action == "complete" {
+		response["accuracy"] = accuracy
+	}
+	if raman != nil {
+		response["raman"] = raman
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // DELETE /api/spectrum-analysis/:id - удаление заявки
 func (h *SpectrumAnalysisHandler) DeleteAnalysis(c *gin.Context) {
-	id := c.Param("id")
 	currentUserID := uint(1) // TODO: Заглушка
 
 	// Проверяем существование заявки
-	var analysis ds.SpectrumAnalysis
-	if err := h.Repository.GetDB().First(&analysis, "id = ?", id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, types.Fail("Заявка не найдена"))
-		} else {
-			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка удаления заявки"))
-		}
+	analysis, ok := h.loadAnalysis(c, "Ошибка удаления заявки")
+	if !ok {
 		return
 	}
 
@@ -481,22 +725,20 @@ func (h *SpectrumAnalysisHandler) DeleteAnalysis(c *gin.Context) {
 		return
 	}
 
-	// Можно удалять только черновики
-	if analysis.Status != "draft" {
-		c.JSON(http.StatusBadRequest, types.Fail("Можно удалять только заявки в статусе черновика"))
+	if !checkIfMatch(c, analysis) {
 		return
 	}
 
-	// ЛОГИЧЕСКОЕ УДАЛЕНИЕ через SQL (как в методичке)
-	sqlDB, err := h.Repository.GetDB().DB()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка подключения к БД"))
+	// Можно удалять только черновики
+	if analysis.Status != ds.StatusDraft {
+		c.JSON(http.StatusBadRequest, types.Fail("Можно удалять только заявки в статусе черновика"))
 		return
 	}
 
-	_, err = sqlDB.Exec("UPDATE spectrum_analysis SET status = 'deleted' WHERE id = $1", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка удаления заявки"))
+	// ЛОГИЧЕСКОЕ УДАЛЕНИЕ: заявка остаётся в БД со статусом deleted
+	analysis.Status = ds.StatusDeleted
+	if err := h.Analyses.UpdateAnalysis(c.Request.Context(), analysis); err != nil {
+		respondAnalysisWriteError(c, err, "Ошибка удаления заявки")
 		return
 	}
 
@@ -507,31 +749,209 @@ func (h *SpectrumAnalysisHandler) DeleteAnalysis(c *gin.Context) {
 
 // Вспомогательные методы для бизнес-логики
 
-// calculateAnalysisAccuracy - вычисление точности спектрального анализа
-func (h *SpectrumAnalysisHandler) calculateAnalysisAccuracy(analysisID uuid.UUID) float64 {
-	// TODO: Реализовать реальную формулу расчета на основе спектра
-	// Пока возвращаем заглушку
-	return 85.5 // 85.5% точность
+// calculateAnalysisAccuracy - вычисление точности спектрального анализа по разбросу повторных измерений:
+// 100% минус средний коэффициент вариации усреднённого спектра
+func (h *SpectrumAnalysisHandler) calculateAnalysisAccuracy(aggregate *spectra.Aggregate) float64 {
+	// Без повторных измерений разброс оценить нельзя - прежняя оценка по умолчанию
+	if aggregate == nil || aggregate.Used < 2 {
+		return 85.5
+	}
+
+	var sum float64
+	var count int
+	for j, p := range aggregate.Mean {
+		if math.Abs(p.Value) < 1e-9 {
+			continue
+		}
+		sum += aggregate.Std[j] / math.Abs(p.Value)
+		count++
+	}
+	if count == 0 {
+		return 85.5
+	}
+	accuracy := 100 * (1 - sum/float64(count))
+	return math.Round(math.Max(0, math.Min(100, accuracy))*10) / 10
 }
 
-// updatePigmentPercentages - обновление процентов пигментов при завершении анализа
-func (h *SpectrumAnalysisHandler) updatePigmentPercentages(analysisID uuid.UUID, accuracy float64) {
+// calculatePigmentPercentages - расчет процентов пигментов при завершении анализа
+func (h *SpectrumAnalysisHandler) calculatePigmentPercentages(analysisPigments []repository.AnalysisPigment, accuracy float64) map[uint]float64 {
 	// TODO: Реальная логика распределения процентов на основе спектрального анализа
 	// Пока равномерно распределяем с учетом точности
 
-	var requestPigments []ds.SpectrumAnalysisPigment
-	h.Repository.GetDB().Where("spectrum_analysis_id = ?", analysisID).Find(&requestPigments)
-
-	if len(requestPigments) > 0 {
-		basePercent := accuracy / float64(len(requestPigments))
+	percents := make(map[uint]float64, len(analysisPigments))
+	if len(analysisPigments) > 0 {
+		basePercent := accuracy / float64(len(analysisPigments))
 
-		for i := range requestPigments {
+		for i, ap := range analysisPigments {
 			// Немного варьируем проценты для реалистичности
 			variation := float64(i%3) * 2.5
-			finalPercent := basePercent + variation
+			percents[ap.Pigment.ID] = basePercent + variation
+		}
+	}
+	return percents
+}
+
+// estimateShares раскладывает итоговый спектр отражения по эталонам пигментов и
+// оценивает доверительные интервалы долей. Возвращает nil без ошибки, если спектра
+// нет, у какого-то пигмента нет эталона или спектры почти не пересекаются
+func (h *SpectrumAnalysisHandler) estimateShares(ctx context.Context, analysis *ds.SpectrumAnalysis, aggregate *spectra.Aggregate, pigments []repository.AnalysisPigment) (map[uint]unmix.Share, error) {
+	if aggregate == nil || len(pigments) == 0 {
+		return nil, nil
+	}
+	endmembers := make([]unmix.Endmember, 0, len(pigments))
+	for _, ap := range pigments {
+		spectrum, err := reflectanceReference(ctx, h.Pigments, ap.Pigment.ID)
+		if err != nil {
+			return nil, err
+		}
+		if spectrum == nil {
+			return nil, nil
+		}
+		endmembers = append(endmembers, unmix.Endmember{PigmentID: ap.Pigment.ID, Spectrum: spectrum})
+	}
+
+	// Генератор зависит только от заявки: повторное завершение даёт те же интервалы
+	seed := binary.LittleEndian.Uint64(analysis.ID[:8])
+	result, err := unmix.Estimate(aggregate.Mean, endmembers, rand.New(rand.NewPCG(seed, binary.LittleEndian.Uint64(analysis.ID[8:]))))
+	if errors.Is(err, unmix.ErrTooFewPoints) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	shares := make(map[uint]unmix.Share, len(result.Shares))
+	for _, share := range result.Shares {
+		shares[share.PigmentID] = share
+	}
+	return shares, nil
+}
 
-			h.Repository.GetDB().Model(&requestPigments[i]).
-				Update("percent", finalPercent)
+func newPercentInterval(link ds.SpectrumAnalysisPigment) *types.PercentInterval {
+	if link.PercentLow == nil || link.PercentHigh == nil {
+		return nil
+	}
+	return &types.PercentInterval{Low: *link.PercentLow, High: *link.PercentHigh}
+}
+
+// formatIDs и parseIDs переводят список ID в строку через запятую и обратно
+func formatIDs(ids []uint) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+func parseIDs(text string) []uint {
+	var ids []uint
+	for _, part := range strings.Split(text, ",") {
+		if id, err := strconv.ParseUint(part, 10, 32); err == nil {
+			ids = append(ids, uint(id))
 		}
 	}
+	return ids
+}
+
+// parseVerdicts проверяет решения модератора: каждое относится к пигменту заявки и
+// встречается один раз. Текст ошибки предназначен пользователю
+func parseVerdicts(request []types.PigmentVerdict, analysisPigments []repository.AnalysisPigment) (map[uint]repository.PigmentResult, error) {
+	inAnalysis := make(map[uint]bool, len(analysisPigments))
+	for _, ap := range analysisPigments {
+		inAnalysis[ap.Pigment.ID] = true
+	}
+
+	verdicts := make(map[uint]repository.PigmentResult, len(request))
+	for _, verdict := range request {
+		if !inAnalysis[verdict.PigmentID] {
+			return nil, fmt.Errorf("Пигмента %d нет в заявке", verdict.PigmentID)
+		}
+		if _, ok := verdicts[verdict.PigmentID]; ok {
+			return nil, fmt.Errorf("Решение по пигменту %d указано дважды", verdict.PigmentID)
+		}
+		var result repository.PigmentResult
+		switch verdict.Decision {
+		case "accept":
+			result.Verdict = ds.VerdictAccepted
+		case "reject":
+			result.Verdict = ds.VerdictRejected
+		default:
+			return nil, errors.New("Решение по пигменту должно быть 'accept' или 'reject'")
+		}
+		result.VerdictNote = strings.TrimSpace(verdict.Note)
+		verdicts[verdict.PigmentID] = result
+	}
+	return verdicts, nil
+}
+
+// datingYear - год, с которым сверяется доступность пигментов: заявленный в заявке,
+// а если его нет - год привязанного произведения. nil - год неизвестен
+func (h *SpectrumAnalysisHandler) datingYear(ctx context.Context, analysis *ds.SpectrumAnalysis) (*int, error) {
+	if analysis.ClaimedYear != nil || analysis.ArtworkID == nil {
+		return analysis.ClaimedYear, nil
+	}
+	artwork, err := h.Artworks.GetArtwork(ctx, *analysis.ArtworkID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return artwork.Year, nil
+}
+
+// pigmentUses готовит пигменты заявки для датировки. Отклонённые модератором пигменты
+// в датировке не участвуют
+func pigmentUses(analysisPigments []repository.AnalysisPigment) []dating.PigmentUse {
+	uses := make([]dating.PigmentUse, 0, len(analysisPigments))
+	for _, ap := range analysisPigments {
+		if ap.Link.Verdict == ds.VerdictRejected {
+			continue
+		}
+		uses = append(uses, dating.PigmentUse{
+			PigmentID:     ap.Pigment.ID,
+			Name:          ap.Pigment.Name,
+			Percent:       ap.Link.Percent,
+			AvailableFrom: ap.Pigment.AvailableFrom,
+			AvailableTo:   ap.Pigment.AvailableTo,
+		})
+	}
+	return uses
+}
+
+// loadAnalysis находит заявку по :id из пути. При ошибке сам пишет ответ и возвращает false
+func (h *SpectrumAnalysisHandler) loadAnalysis(c *gin.Context, failMessage string) (*ds.SpectrumAnalysis, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Неверный ID заявки"))
+		return nil, false
+	}
+
+	analysis, err := h.Analyses.GetAnalysis(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, types.Fail("Заявка не найдена"))
+		return nil, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail(failMessage))
+		return nil, false
+	}
+	return analysis, true
+}
+
+// analysisVisible - может ли текущий пользователь видеть заявку в общих выборках.
+// Завершённые анализы видны всем, остальные - автору; модератору - всё, кроме чужих черновиков
+func analysisVisible(c *gin.Context, analysis *ds.SpectrumAnalysis) bool {
+	isModerator := c.GetBool("is_moderator")
+	return analysis.Status == ds.StatusCompleted || analysis.CreatorID == c.GetUint("user_id") ||
+		(isModerator && analysis.Status != ds.StatusDraft)
+}
+
+// respondAnalysisWriteError отвечает 412 на конфликт версий, 404 на исчезнувшую заявку и 500 с message для остального
+func respondAnalysisWriteError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, repository.ErrVersionConflict):
+		respondVersionConflict(c)
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, types.Fail("Заявка не найдена"))
+	default:
+		c.JSON(http.StatusInternalServerError, types.Fail(message))
+	}
 }
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/spectra"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Формы спектра заявки в ответах (параметр spectrum_format)
+const (
+	spectrumExpanded = "expanded" // текст "400:0.12,410:0.15"
+	spectrumCompact  = "compact"  // формат хранения spectra.Packed
+	spectrumBoth     = "both"
+)
+
+// parseSpectrumFormat проверяет spectrum_format; по умолчанию спектр отдаётся текстом,
+// компактную форму клиент запрашивает явно. При ошибке сам пишет ответ и возвращает false
+func parseSpectrumFormat(c *gin.Context, format string) (string, bool) {
+	switch format {
+	case "":
+		return spectrumExpanded, true
+	case spectrumExpanded, spectrumCompact, spectrumBoth:
+		return format, true
+	}
+	c.JSON(http.StatusBadRequest, types.Fail("Неверный spectrum_format: ожидается expanded, compact или both"))
+	return "", false
+}
+
+// setResponseSpectrum заполняет спектр заявки в ответе. Спектр распаковывается,
+// только если нужна текстовая форма
+func setResponseSpectrum(response *types.SpectrumAnalysisResponse, analysis *ds.SpectrumAnalysis, format string) {
+	response.SpectrumUnparsed = analysis.SpectrumUnparsed
+	if len(analysis.Spectrum) == 0 {
+		return
+	}
+	packed := spectra.Packed(analysis.Spectrum)
+	if format != spectrumCompact {
+		// Испорченный спектр остаётся в компактной форме: по ней видно, что хранится
+		if spectrum, err := packed.Decode(); err == nil {
+			response.Spectrum = spectrum.String()
+		}
+	}
+	if format != spectrumExpanded {
+		response.SpectrumPacked = newPackedSpectrum(packed)
+	}
+}
+
+func newPackedSpectrum(packed spectra.Packed) *types.PackedSpectrum {
+	response := &types.PackedSpectrum{Data: base64.StdEncoding.EncodeToString(packed)}
+	if header, err := packed.Header(); err == nil {
+		response.Count = header.Count
+		response.Start = header.Start
+		response.Step = header.Step
+		response.Regular = header.Regular
+		response.Checksum = header.Checksum
+	}
+	return response
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxLayers - ограничение на число слоёв в шлифе
+	maxLayers = 20
+	// percentSumTolerance - допустимое отклонение суммы долей пигментов слоя от 100%
+	percentSumTolerance = 0.5
+)
+
+// PUT /api/spectrum-analysis/:id/stratigraphy - заменить слои поперечного шлифа пробы.
+// Слои перечисляются снизу вверх; доли пигментов каждого слоя в сумме дают 100%
+func (h *SpectrumAnalysisHandler) SetStratigraphy(c *gin.Context) {
+	var request types.SetStratigraphyRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, types.Fail("Список слоёв обязателен, у каждого слоя - вид"))
+		return
+	}
+	if len(request.Layers) == 0 || len(request.Layers) > maxLayers {
+		c.JSON(http.StatusBadRequest, types.Fail(fmt.Sprintf("В шлифе должно быть от 1 до %d слоёв", maxLayers)))
+		return
+	}
+
+	layers := make([]repository.AnalysisLayer, len(request.Layers))
+	for i, layer := range request.Layers {
+		if err := validateLayer(layer); err != nil {
+			c.JSON(http.StatusBadRequest, types.Fail(fmt.Sprintf("Слой %d: %s", i+1, err)))
+			return
+		}
+		layers[i].Layer = ds.AnalysisLayer{
+			Kind:      layer.Kind,
+			Name:      strings.TrimSpace(layer.Name),
+			Thickness: layer.Thickness,
+			Binder:    strings.TrimSpace(layer.Binder),
+			Note:      strings.TrimSpace(layer.Note),
+		}
+		for _, pigment := range layer.Pigments {
+			layers[i].Pigments = append(layers[i].Pigments, repository.LayerPigment{
+				Link: ds.LayerPigment{PigmentID: pigment.PigmentID, Percent: pigment.Percent},
+			})
+		}
+	}
+
+	analysis, ok := h.loadEditableDraft(c, "Ошибка сохранения стратиграфии")
+	if !ok {
+		return
+	}
+
+	// Пигменты проверяются после прав на заявку, чтобы чужой не узнал о них по ответу
+	checked := make(map[uint]bool)
+	for _, layer := range layers {
+		for _, pigment := range layer.Pigments {
+			id := pigment.Link.PigmentID
+			if checked[id] {
+				continue
+			}
+			if _, err := h.Pigments.GetPigment(c.Request.Context(), id, false); err != nil {
+				respondPigmentError(c, err, "Ошибка сохранения стратиграфии")
+				return
+			}
+			checked[id] = true
+		}
+	}
+
+	if err := h.Analyses.ReplaceLayers(c.Request.Context(), analysis, layers); err != nil {
+		respondAnalysisWriteError(c, err, "Ошибка сохранения стратиграфии")
+		return
+	}
+
+	stratigraphy, err := h.loadStratigraphy(c.Request.Context(), analysis)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка сохранения стратиграфии"))
+		return
+	}
+	setAnalysisETag(c, analysis)
+	c.JSON(http.StatusOK, gin.H{
+		"stratigraphy": stratigraphy,
+	})
+}
+
+// DELETE /api/spectrum-analysis/:id/stratigraphy - удалить стратиграфию пробы
+func (h *SpectrumAnalysisHandler) DeleteStratigraphy(c *gin.Context) {
+	analysis, ok := h.loadEditableDraft(c, "Ошибка удаления стратиграфии")
+	if !ok {
+		return
+	}
+
+	layers, err := h.Analyses.ListLayers(c.Request.Context(), analysis.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка удаления стратиграфии"))
+		return
+	}
+	if len(layers) == 0 {
+		c.JSON(http.StatusNotFound, types.Fail("Стратиграфия не задана"))
+		return
+	}
+
+	if err := h.Analyses.ReplaceLayers(c.Request.Context(), analysis, nil); err != nil {
+		respondAnalysisWriteError(c, err, "Ошибка удаления стратиграфии")
+		return
+	}
+
+	setAnalysisETag(c, analysis)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Стратиграфия удалена",
+	})
+}
+
+// validateLayer проверяет вид, толщину и доли пигментов слоя. Слой без пигментов
+// (лак, клеевая проклейка) допустим
+func validateLayer(layer types.LayerRequest) error {
+	if !slices.Contains(ds.LayerKinds, layer.Kind) {
+		return fmt.Errorf("неизвестный вид слоя, ожидается один из: %s", strings.Join(ds.LayerKinds, ", "))
+	}
+	if layer.Thickness != nil && (*layer.Thickness <= 0 || math.IsInf(*layer.Thickness, 0)) {
+		return errors.New("толщина слоя должна быть положительной")
+	}
+
+	seen := make(map[uint]bool)
+	var sum float64
+	for _, pigment := range layer.Pigments {
+		if pigment.Percent <= 0 || pigment.Percent > 100 {
+			return errors.New("доля пигмента должна быть от 0 до 100%")
+		}
+		if seen[pigment.PigmentID] {
+			return errors.New("пигмент указан в слое дважды")
+		}
+		seen[pigment.PigmentID] = true
+		sum += pigment.Percent
+	}
+	if len(layer.Pigments) > 0 && math.Abs(sum-100) > percentSumTolerance {
+		return fmt.Errorf("доли пигментов в сумме дают %g%%, а должны 100%%", roundTo(sum, 2))
+	}
+	return nil
+}
+
+// loadStratigraphy загружает слои заявки для ответа; nil - стратиграфия не задана
+func (h *SpectrumAnalysisHandler) loadStratigraphy(ctx context.Context, analysis *ds.SpectrumAnalysis) ([]types.AnalysisLayer, error) {
+	layers, err := h.Analyses.ListLayers(ctx, analysis.ID)
+	if err != nil {
+		return nil, err
+	}
+	var response []types.AnalysisLayer
+	for _, layer := range layers {
+		item := types.AnalysisLayer{
+			Position:  layer.Layer.Position,
+			Kind:      layer.Layer.Kind,
+			Name:      layer.Layer.Name,
+			Thickness: layer.Layer.Thickness,
+			Binder:    layer.Layer.Binder,
+			Note:      layer.Layer.Note,
+		}
+		for _, lp := range layer.Pigments {
+			item.Pigments = append(item.Pigments, types.LayerPigment{
+				PigmentID: lp.Pigment.ID,
+				Name:      lp.Pigment.Name,
+				Color:     lp.Pigment.Color,
+				Percent:   lp.Link.Percent,
+				Archived:  lp.Pigment.DeletedAt.Valid,
+			})
+		}
+		response = append(response, item)
+	}
+	return response, nil
+}
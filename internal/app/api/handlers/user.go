@@ -7,6 +7,7 @@ import (
 	"colorLex/internal/app/ds"
 	"colorLex/internal/app/repository"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -14,18 +15,17 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
 )
 
 type UsersHandler struct {
-	Repository *repository.Repository
+	Users       repository.UserStore
 	AuthMW     *middleware.AuthMiddleware
 	RedisClient *redis.Client
 }
 
-func NewUsersHandler(repo *repository.Repository, authMW *middleware.AuthMiddleware, redisClient *redis.Client) *UsersHandler {
+func NewUsersHandler(users repository.UserStore, authMW *middleware.AuthMiddleware, redisClient *redis.Client) *UsersHandler {
 	return &UsersHandler{
-		Repository:  repo,
+		Users:       users,
 		AuthMW:     authMW,
 		RedisClient: redisClient,
 	}
@@ -50,12 +50,11 @@ func (h *UsersHandler) Register(c *gin.Context) {
 	}
 
 	// Проверяем что логин не занят
-	var existingUser ds.User
-	err := h.Repository.GetDB().Where("login = ?", request.Login).First(&existingUser).Error
+	_, err := h.Users.GetUserByLogin(c.Request.Context(), request.Login)
 	if err == nil {
 		c.JSON(http.StatusBadRequest, types.Fail("Пользователь с таким логином уже существует"))
 		return
-	} else if err != gorm.ErrRecordNotFound {
+	} else if !errors.Is(err, repository.ErrNotFound) {
 		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка проверки пользователя"))
 		return
 	}
@@ -74,8 +73,12 @@ func (h *UsersHandler) Register(c *gin.Context) {
 		IsModerator:  request.IsModerator,
 	}
 
-	if err := h.Repository.GetDB().Create(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка создания пользователя"))
+	if err := h.Users.CreateUser(c.Request.Context(), &user); err != nil {
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			c.JSON(http.StatusBadRequest, types.Fail("Пользователь с таким логином уже существует"))
+		} else {
+			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка создания пользователя"))
+		}
 		return
 	}
 
@@ -150,10 +153,9 @@ func (h *UsersHandler) Login(c *gin.Context) {
 	}
 
 	// Находим пользователя
-	var user ds.User
-	err := h.Repository.GetDB().Where("login = ?", request.Login).First(&user).Error
+	user, err := h.Users.GetUserByLogin(c.Request.Context(), request.Login)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
+		if errors.Is(err, repository.ErrNotFound) {
 			c.JSON(http.StatusUnauthorized, types.Fail("Неверный логин или пароль"))
 		} else {
 			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка аутентификации"))
@@ -169,13 +171,13 @@ func (h *UsersHandler) Login(c *gin.Context) {
 	}
 
 	// Генерируем токены
-	accessToken, err := h.AuthMW.GenerateToken(&user)
+	accessToken, err := h.AuthMW.GenerateToken(user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка генерации токена"))
 		return
 	}
 
-	refreshToken, err := h.AuthMW.GenerateRefreshToken(&user)
+	refreshToken, err := h.AuthMW.GenerateRefreshToken(user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка генерации refresh токена"))
 		return
@@ -292,14 +294,14 @@ func (h *UsersHandler) RefreshToken(c *gin.Context) {
 	}
 
 	// Проверяем что пользователь все еще существует
-	var user ds.User
-	if err := h.Repository.GetDB().First(&user, claims.UserID).Error; err != nil {
+	user, err := h.Users.GetUser(c.Request.Context(), claims.UserID)
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, types.Fail("Пользователь не найден"))
 		return
 	}
 
 	// Генерируем новый access токен
-	accessToken, err := h.AuthMW.GenerateToken(&user)
+	accessToken, err := h.AuthMW.GenerateToken(user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка генерации токена"))
 		return
@@ -340,8 +342,8 @@ func (h *UsersHandler) GetProfile(c *gin.Context) {
 		return
 	}
 
-	var user ds.User
-	if err := h.Repository.GetDB().First(&user, userID).Error; err != nil {
+	user, err := h.Users.GetUser(c.Request.Context(), userID.(uint))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка получения профиля"))
 		return
 	}
@@ -381,26 +383,29 @@ func (h *UsersHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
-	var user ds.User
-	if err := h.Repository.GetDB().First(&user, userID).Error; err != nil {
+	user, err := h.Users.GetUser(c.Request.Context(), userID.(uint))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка обновления профиля"))
 		return
 	}
 
+	if request.Login == "" && request.Password == "" {
+		c.JSON(http.StatusBadRequest, types.Fail("Нет данных для обновления"))
+		return
+	}
+
 	// Обновляем только переданные поля
-	updates := make(map[string]interface{})
 	if request.Login != "" {
 		// Проверяем что новый логин не занят
-		var existingUser ds.User
-		err := h.Repository.GetDB().Where("login = ? AND id != ?", request.Login, userID).First(&existingUser).Error
-		if err == nil {
+		existingUser, err := h.Users.GetUserByLogin(c.Request.Context(), request.Login)
+		if err == nil && existingUser.ID != user.ID {
 			c.JSON(http.StatusBadRequest, types.Fail("Пользователь с таким логином уже существует"))
 			return
-		} else if err != gorm.ErrRecordNotFound {
+		} else if err != nil && !errors.Is(err, repository.ErrNotFound) {
 			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка проверки логина"))
 			return
 		}
-		updates["login"] = request.Login
+		user.Login = request.Login
 	}
 
 	if request.Password != "" {
@@ -409,22 +414,18 @@ func (h *UsersHandler) UpdateProfile(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка обновления пароля"))
 			return
 		}
-		updates["password_hash"] = string(hashedPassword)
-	}
-
-	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, types.Fail("Нет данных для обновления"))
-		return
+		user.PasswordHash = string(hashedPassword)
 	}
 
-	if err := h.Repository.GetDB().Model(&user).Updates(updates).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, types.Fail("Ошибка обновления профиля"))
+	if err := h.Users.UpdateUser(c.Request.Context(), user); err != nil {
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			c.JSON(http.StatusBadRequest, types.Fail("Пользователь с таким логином уже существует"))
+		} else {
+			c.JSON(http.StatusInternalServerError, types.Fail("Ошибка обновления профиля"))
+		}
 		return
 	}
 
-	// Получаем обновленного пользователя
-	h.Repository.GetDB().First(&user, userID)
-
 	response := types.UserProfileResponse{
 		ID:          user.ID,
 		Login:       user.Login,
@@ -0,0 +1,407 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/refindex"
+	"colorLex/internal/app/spectra"
+	"colorLex/internal/app/testenv"
+)
+
+// xrfSpectrum - РФА-спектр 1-20 кэВ на постоянном фоне с гауссовыми линиями {энергия, высота}
+func xrfSpectrum(lines ...[2]float64) string {
+	var s spectra.Spectrum
+	for i := 0; i <= 950; i++ {
+		energy := 1 + float64(i)*0.02
+		value := 5.0
+		for _, line := range lines {
+			d := (energy - line[0]) / 0.06
+			value += line[1] * math.Exp(-d*d/2)
+		}
+		s = append(s, spectra.Point{Wavelength: math.Round(energy*100) / 100, Value: math.Round(value*100) / 100})
+	}
+	return s.String()
+}
+
+// ochreRaman - три из пяти полос гётита со сдвигом на 1 см⁻¹
+const ochreRaman = "200:12,248:50,270:14,300:35,340:14,386:90,430:13,600:12"
+
+func TestReferenceSpectrumRoutes(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "list references",
+			method: http.MethodGet,
+			path:   pigmentPath("/api/pigments/%d/references", ochre),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					References []types.ReferenceSpectrumResponse `json:"references"`
+				}
+				testenv.Decode(t, rec, &response)
+				if len(response.References) != 2 || response.References[1].Technique != "raman" || response.References[1].Unit != "cm-1" {
+					t.Fatalf("unexpected references %+v", response.References)
+				}
+			},
+		},
+		{
+			name:   "filter by technique",
+			method: http.MethodGet,
+			path:   pigmentPath("/api/pigments/%d/references?technique=reflectance", ochre),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					Count int `json:"count"`
+				}
+				testenv.Decode(t, rec, &response)
+				if response.Count != 1 {
+					t.Fatalf("count %d, want 1", response.Count)
+				}
+			},
+		},
+		{
+			name:   "unknown technique filter",
+			method: http.MethodGet,
+			path:   pigmentPath("/api/pigments/%d/references?technique=ftir", ochre),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "add reference",
+			method: http.MethodPost,
+			path:   pigmentPath("/api/pigments/%d/references", ultramarine),
+			as:     asModerator,
+			body:   body(types.AddReferenceSpectrumRequest{Technique: "xrf", Spectrum: "2.0:10,2.31:80,2.6:10", Source: "  собственное измерение "}),
+			status: http.StatusCreated,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				references, err := env.Pigments.ListReferenceSpectra(context.Background(), f.Ultramarine.ID, "xrf")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(references) != 1 || references[0].Source != "собственное измерение" {
+					t.Fatalf("unexpected references %+v", references)
+				}
+			},
+		},
+		{
+			name:   "add reflectance reference to index",
+			method: http.MethodPost,
+			path:   pigmentPath("/api/pigments/%d/references", ultramarine),
+			as:     asModerator,
+			body:   body(types.AddReferenceSpectrumRequest{Technique: "reflectance", Spectrum: "380:0.40,450:0.52,550:0.10,650:0.12,750:0.30"}),
+			status: http.StatusCreated,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					Reference types.ReferenceSpectrumResponse `json:"reference"`
+				}
+				testenv.Decode(t, rec, &response)
+				if env.References.Len() != 3 {
+					t.Fatalf("index has %d references, want 3", env.References.Len())
+				}
+				spectrum, _ := spectra.Parse(response.Reference.Spectrum)
+				features, err := refindex.Features(spectrum)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ids := env.References.Search(features, 1, nil); len(ids) != 1 || ids[0] != response.Reference.ID {
+					t.Fatalf("nearest references %v, want %d", ids, response.Reference.ID)
+				}
+			},
+		},
+		{
+			name:   "add reference with axis of another technique",
+			method: http.MethodPost,
+			path:   pigmentPath("/api/pigments/%d/references", ultramarine),
+			as:     asModerator,
+			body:   body(types.AddReferenceSpectrumRequest{Technique: "xrf", Spectrum: "400:0.1,500:0.2"}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "add reference to archived pigment",
+			method: http.MethodPost,
+			path:   pigmentPath("/api/pigments/%d/references", leadWhite),
+			as:     asModerator,
+			body:   body(types.AddReferenceSpectrumRequest{Technique: "raman", Spectrum: "100:1,1050:9,1100:1"}),
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "add reference as user",
+			method: http.MethodPost,
+			path:   pigmentPath("/api/pigments/%d/references", ultramarine),
+			as:     asCreator,
+			body:   body(types.AddReferenceSpectrumRequest{Technique: "raman", Spectrum: "100:1,548:9,600:1"}),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "delete reference",
+			method: http.MethodDelete,
+			path:   pigmentPath("/api/pigments/%d/references/1", ultramarine),
+			as:     asModerator,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				// эталон 1 - спектр отражения ультрамарина
+				if env.References.Len() != 1 {
+					t.Fatalf("index has %d references, want 1", env.References.Len())
+				}
+			},
+		},
+		{
+			name:   "delete reference of another pigment",
+			method: http.MethodDelete,
+			path:   pigmentPath("/api/pigments/%d/references/1", ochre),
+			as:     asModerator,
+			status: http.StatusNotFound,
+		},
+	})
+}
+
+func TestTechniqueReadings(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "add raman reading next to reflectance",
+			method: http.MethodPost,
+			path:   readingPath(draft),
+			as:     asCreator,
+			setup:  addReadings(repeatReadings[0]),
+			body:   body(types.AddSpectrumReadingRequest{Technique: "raman", Spectrum: ochreRaman}),
+			status: http.StatusCreated,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				rec = env.Do(t, http.MethodGet, analysisPath("", draft)(f), nil, testenv.WithToken(env.Token(t, f.Creator)))
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				analysis := response.Analysis
+				if len(analysis.Readings) != 2 || analysis.Readings[1].Technique != "raman" {
+					t.Fatalf("unexpected readings %+v", analysis.Readings)
+				}
+				if analysis.Aggregate == nil || analysis.Aggregate.Unit != "nm" || analysis.Aggregate.Readings != 1 {
+					t.Fatalf("unexpected reflectance aggregate %+v", analysis.Aggregate)
+				}
+				if len(analysis.TechniqueAggregates) != 1 || analysis.TechniqueAggregates[0].Technique != "raman" ||
+					analysis.TechniqueAggregates[0].Unit != "cm-1" {
+					t.Fatalf("unexpected technique aggregates %+v", analysis.TechniqueAggregates)
+				}
+			},
+		},
+		{
+			name:   "unknown technique",
+			method: http.MethodPost,
+			path:   readingPath(draft),
+			as:     asCreator,
+			body:   body(types.AddSpectrumReadingRequest{Technique: "ftir", Spectrum: "1000:0.1,2000:0.2"}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "nanometres as xrf",
+			method: http.MethodPost,
+			path:   readingPath(draft),
+			as:     asCreator,
+			body:   body(types.AddSpectrumReadingRequest{Technique: "xrf", Spectrum: repeatReadings[0]}),
+			status: http.StatusBadRequest,
+		},
+	})
+}
+
+func TestIdentification(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "combine techniques",
+			method: http.MethodGet,
+			path:   analysisPath("/identification", draft),
+			as:     asCreator,
+			setup: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+				addTechniqueReadings("raman", ochreRaman)(t, env, f)
+				// Железо (Kα и Kβ) и кальций без линии Kβ
+				addTechniqueReadings("xrf", xrfSpectrum([2]float64{6.40, 300}, [2]float64{7.06, 60}, [2]float64{3.69, 80}))(t, env, f)
+			},
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					Techniques []types.TechniqueIdentification `json:"techniques"`
+					Pigments   []types.RankedPigment           `json:"pigments"`
+				}
+				testenv.Decode(t, rec, &response)
+
+				if len(response.Techniques) != 3 {
+					t.Fatalf("unexpected techniques %+v", response.Techniques)
+				}
+				reflectance, raman, xrf := response.Techniques[0], response.Techniques[1], response.Techniques[2]
+				if reflectance.Technique != "reflectance" || len(reflectance.Candidates) != 2 ||
+					reflectance.Candidates[0].PigmentID != f.Ochre.ID || reflectance.Candidates[1].Score != 0 {
+					t.Fatalf("unexpected reflectance %+v", reflectance)
+				}
+				if raman.Technique != "raman" || len(raman.Peaks) != 3 || raman.Candidates[0].PigmentID != f.Ochre.ID ||
+					raman.Candidates[0].Score != 0.778 || !slices.Equal(raman.Candidates[0].Missing, []string{"480", "550"}) {
+					t.Fatalf("unexpected raman %+v", raman)
+				}
+				if xrf.Technique != "xrf" || !slices.Equal(xrf.Elements, []string{"Fe"}) {
+					t.Fatalf("unexpected xrf %+v", xrf)
+				}
+				for _, candidate := range xrf.Candidates {
+					if candidate.PigmentID == f.Ultramarine.ID && (candidate.Score != 0 || !slices.Equal(candidate.Missing, []string{"S"})) {
+						t.Fatalf("ultramarine must miss sulphur: %+v", candidate)
+					}
+				}
+
+				if len(response.Pigments) != 1 {
+					t.Fatalf("unexpected ranking %+v", response.Pigments)
+				}
+				ochre := response.Pigments[0]
+				if ochre.PigmentID != f.Ochre.ID || !ochre.InAnalysis || len(ochre.Scores) != 3 || ochre.Scores["xrf"] != 1 ||
+					ochre.Score < 0.85 || ochre.Score > 0.95 {
+					t.Fatalf("unexpected ochre ranking %+v", ochre)
+				}
+			},
+		},
+		{
+			name:   "archived pigments are not candidates",
+			method: http.MethodGet,
+			path:   analysisPath("/identification", draft),
+			as:     asCreator,
+			setup:  addTechniqueReadings("xrf", xrfSpectrum([2]float64{10.55, 300}, [2]float64{12.61, 200})),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					Pigments []types.RankedPigment `json:"pigments"`
+				}
+				testenv.Decode(t, rec, &response)
+				for _, pigment := range response.Pigments {
+					if pigment.PigmentID == f.LeadWhite.ID {
+						t.Fatalf("archived lead white ranked: %+v", response.Pigments)
+					}
+				}
+			},
+		},
+		{
+			name:   "deleted reference is not matched",
+			method: http.MethodGet,
+			path:   analysisPath("/identification", draft),
+			as:     asCreator,
+			setup: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+				path := fmt.Sprintf("/api/pigments/%d/references/1", f.Ultramarine.ID)
+				rec := env.Do(t, http.MethodDelete, path, nil, testenv.WithToken(env.Token(t, f.Moderator)))
+				if rec.Code != http.StatusOK {
+					t.Fatalf("delete reference: status %d, body %s", rec.Code, rec.Body.String())
+				}
+			},
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					Techniques []types.TechniqueIdentification `json:"techniques"`
+				}
+				testenv.Decode(t, rec, &response)
+				reflectance := response.Techniques[0]
+				if len(reflectance.Candidates) != 1 || reflectance.Candidates[0].PigmentID != f.Ochre.ID {
+					t.Fatalf("unexpected reflectance %+v", reflectance)
+				}
+			},
+		},
+		{
+			name:   "preprocessed sample is matched against nearest references",
+			method: http.MethodGet,
+			path:   analysisPath("/identification", draft),
+			as:     asCreator,
+			setup: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+				ctx := context.Background()
+				f.Draft.Preprocessing = `[{"op":"baseline","method":"linear"}]`
+				if err := env.Analyses.UpdateAnalysis(ctx, f.Draft); err != nil {
+					t.Fatal(err)
+				}
+			},
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					Techniques []types.TechniqueIdentification `json:"techniques"`
+				}
+				testenv.Decode(t, rec, &response)
+				if reflectance := response.Techniques[0]; len(reflectance.Candidates) != 2 {
+					t.Fatalf("unexpected reflectance %+v", reflectance)
+				}
+			},
+		},
+		{
+			name:   "no spectra",
+			method: http.MethodGet,
+			path:   analysisPath("/identification", draft),
+			as:     asCreator,
+			setup: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+				f.Draft.Spectrum = nil
+				if err := env.Analyses.UpdateAnalysis(context.Background(), f.Draft); err != nil {
+					t.Fatal(err)
+				}
+			},
+			status: http.StatusBadRequest,
+		},
+	})
+}
+
+// randomReflectance - кривая отражения 380-1000 нм: подложка, край поглощения и полоса
+func randomReflectance(rng *rand.Rand) spectra.Spectrum {
+	base, edge, height := 0.05+0.3*rng.Float64(), 400+400*rng.Float64(), 0.6*rng.Float64()
+	center, depth := 350+700*rng.Float64(), 0.3*rng.Float64()
+	var s spectra.Spectrum
+	for wavelength := 380.0; wavelength <= 1000; wavelength += 5 {
+		d := (wavelength - center) / 40
+		value := base + height/(1+math.Exp(-(wavelength-edge)/30)) - depth*math.Exp(-d*d/2)
+		s = append(s, spectra.Point{Wavelength: wavelength, Value: math.Round(value*1e4) / 1e4})
+	}
+	return s
+}
+
+// BenchmarkIdentificationLargeLibrary - идентификация черновика при каталоге из 5 тыс.
+// пигментов с 50 тыс. эталонов отражения, как после импорта библиотеки
+func BenchmarkIdentificationLargeLibrary(b *testing.B) {
+	benchmarkIdentification(b, "")
+}
+
+// BenchmarkIdentificationLargeLibraryPreprocessed - то же для заявки с предобработкой
+func BenchmarkIdentificationLargeLibraryPreprocessed(b *testing.B) {
+	benchmarkIdentification(b, `[{"op":"baseline","method":"linear"},{"op":"normalize","method":"max"}]`)
+}
+
+func benchmarkIdentification(b *testing.B, preprocessing string) {
+	env := testenv.New(b)
+	f := env.Seed(b)
+	ctx := context.Background()
+	if preprocessing != "" {
+		f.Draft.Preprocessing = preprocessing
+		if err := env.Analyses.UpdateAnalysis(ctx, f.Draft); err != nil {
+			b.Fatal(err)
+		}
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		pigment := ds.Pigment{Name: fmt.Sprintf("Пигмент %d", i), Color: "grey"}
+		if err := env.Pigments.CreatePigment(ctx, &pigment); err != nil {
+			b.Fatal(err)
+		}
+		for j := 0; j < 10; j++ {
+			spectrum := randomReflectance(rng)
+			reference := ds.ReferenceSpectrum{
+				PigmentID: pigment.ID,
+				Technique: spectra.TechniqueReflectance,
+				Spectrum:  spectrum.String(),
+				Features:  refindex.ReferenceFeatures(spectra.TechniqueReflectance, spectrum),
+			}
+			if err := env.Pigments.AddReferenceSpectrum(ctx, &reference); err != nil {
+				b.Fatal(err)
+			}
+			env.References.Put(reference)
+		}
+	}
+	path := "/api/spectrum-analysis/" + f.Draft.ID.String() + "/identification"
+	token := env.Token(b, f.Creator)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if rec := env.Do(b, http.MethodGet, path, nil, testenv.WithToken(token)); rec.Code != http.StatusOK {
+			b.Fatalf("status %d, body %s", rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/testenv"
+)
+
+var (
+	calibratedFrom = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	calibratedTo   = time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// registerInstrument регистрирует спектрометр отражения 380-780 нм с калибровкой на первое
+// полугодие 2026: темновой сигнал 100, белый эталон 1100, ось сдвинута на +2 нм
+func registerInstrument(t *testing.T, env *testenv.Env) ds.Instrument {
+	t.Helper()
+	ctx := context.Background()
+	instrument := ds.Instrument{Name: "Ocean HR4000", Serial: "HR4C1234", Technique: "reflectance", RangeFrom: 380, RangeTo: 780, Resolution: 1.5}
+	if err := env.Instruments.CreateInstrument(ctx, &instrument); err != nil {
+		t.Fatal(err)
+	}
+	calibration := ds.InstrumentCalibration{
+		InstrumentID: instrument.ID,
+		ValidFrom:    calibratedFrom,
+		ValidTo:      &calibratedTo,
+		Dark:         "380:100,780:100",
+		White:        "380:1100,780:1100",
+		Wavelengths:  "400:402,700:702",
+	}
+	if err := env.Instruments.AddCalibration(ctx, &calibration); err != nil {
+		t.Fatal(err)
+	}
+	return instrument
+}
+
+func withInstrument(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+	registerInstrument(t, env)
+}
+
+func instrumentPath(format string) func(*testenv.Fixtures) string {
+	return func(*testenv.Fixtures) string { return fmt.Sprintf(format, 1) }
+}
+
+// rawReading - сырые отсчёты прибора 1
+func rawReading(spectrum string, measuredAt time.Time) func(*testenv.Fixtures) any {
+	return func(*testenv.Fixtures) any {
+		id := uint(1)
+		return types.AddSpectrumReadingRequest{Spectrum: spectrum, InstrumentID: &id, MeasuredAt: &measuredAt}
+	}
+}
+
+func decodeReading(t *testing.T, rec *httptest.ResponseRecorder) types.SpectrumReading {
+	t.Helper()
+	var response struct {
+		Reading types.SpectrumReading `json:"reading"`
+	}
+	testenv.Decode(t, rec, &response)
+	return response.Reading
+}
+
+func TestInstrumentRoutes(t *testing.T) {
+	validCalibration := types.AddCalibrationRequest{
+		ValidFrom: calibratedTo,
+		Dark:      "380 90\n780 95",
+		White:     "380 1000\n580 1200\n780 1100",
+	}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "register instrument",
+			method: http.MethodPost,
+			path:   path("/api/instruments"),
+			as:     asModerator,
+			body:   body(types.CreateInstrumentRequest{Name: " Renishaw inVia ", Serial: "RN-785", Technique: "raman", RangeFrom: 100, RangeTo: 3200, Resolution: 2}),
+			status: http.StatusCreated,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					Instrument types.InstrumentResponse `json:"instrument"`
+				}
+				testenv.Decode(t, rec, &response)
+				if response.Instrument.Name != "Renishaw inVia" || response.Instrument.Unit != "cm-1" {
+					t.Fatalf("unexpected instrument %+v", response.Instrument)
+				}
+			},
+		},
+		{
+			name:   "creator cannot register",
+			method: http.MethodPost,
+			path:   path("/api/instruments"),
+			as:     asCreator,
+			body:   body(types.CreateInstrumentRequest{Name: "X", Serial: "X1", Technique: "xrf", RangeFrom: 1, RangeTo: 40}),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "serial taken",
+			method: http.MethodPost,
+			path:   path("/api/instruments"),
+			as:     asModerator,
+			setup:  withInstrument,
+			body:   body(types.CreateInstrumentRequest{Name: "Другой", Serial: "HR4C1234", Technique: "reflectance", RangeFrom: 400, RangeTo: 700}),
+			status: http.StatusConflict,
+		},
+		{
+			name:   "range outside technique axis",
+			method: http.MethodPost,
+			path:   path("/api/instruments"),
+			as:     asModerator,
+			body:   body(types.CreateInstrumentRequest{Name: "X", Serial: "X1", Technique: "xrf", RangeFrom: 1, RangeTo: 400}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "update instrument",
+			method: http.MethodPut,
+			path:   instrumentPath("/api/instruments/%d"),
+			as:     asModerator,
+			setup:  withInstrument,
+			body:   body(map[string]any{"resolution": 3, "note": "после ремонта"}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				instrument, err := env.Instruments.GetInstrument(context.Background(), 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if instrument.Resolution != 3 || instrument.Note != "после ремонта" || instrument.Serial != "HR4C1234" {
+					t.Fatalf("unexpected instrument %+v", instrument)
+				}
+			},
+		},
+		{
+			name:   "add calibration",
+			method: http.MethodPost,
+			path:   instrumentPath("/api/instruments/%d/calibrations"),
+			as:     asModerator,
+			setup:  withInstrument,
+			body:   body(validCalibration),
+			status: http.StatusCreated,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				rec = env.Do(t, http.MethodGet, "/api/instruments/1", nil, testenv.WithToken(env.Token(t, f.Creator)))
+				var response struct {
+					Instrument types.InstrumentResponse `json:"instrument"`
+				}
+				testenv.Decode(t, rec, &response)
+				calibrations := response.Instrument.Calibrations
+				if len(calibrations) != 2 || !calibrations[1].ValidFrom.Equal(calibratedTo) || calibrations[1].Dark != "380:90,780:95" {
+					t.Fatalf("unexpected calibrations %+v", calibrations)
+				}
+			},
+		},
+		{
+			name:   "reflectance calibration needs white",
+			method: http.MethodPost,
+			path:   instrumentPath("/api/instruments/%d/calibrations"),
+			as:     asModerator,
+			setup:  withInstrument,
+			body:   body(types.AddCalibrationRequest{ValidFrom: calibratedTo, Dark: "380:90,780:95"}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "white does not cover range",
+			method: http.MethodPost,
+			path:   instrumentPath("/api/instruments/%d/calibrations"),
+			as:     asModerator,
+			setup:  withInstrument,
+			body:   body(types.AddCalibrationRequest{ValidFrom: calibratedTo, White: "400:1000,700:1000"}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "validity ends before start",
+			method: http.MethodPost,
+			path:   instrumentPath("/api/instruments/%d/calibrations"),
+			as:     asModerator,
+			setup:  withInstrument,
+			body:   body(types.AddCalibrationRequest{ValidFrom: calibratedTo, ValidTo: &calibratedFrom, White: "380:1000,780:1000"}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown instrument",
+			method: http.MethodGet,
+			path:   path("/api/instruments/42"),
+			as:     asCreator,
+			status: http.StatusNotFound,
+		},
+	})
+}
+
+func TestCalibratedReadings(t *testing.T) {
+	inside := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "raw counts are corrected",
+			method: http.MethodPost,
+			path:   readingPath(draft),
+			as:     asCreator,
+			setup:  withInstrument,
+			body:   rawReading("400:600,500:700,600:800", inside),
+			status: http.StatusCreated,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				reading := decodeReading(t, rec)
+				if reading.Spectrum != "402:0.5,502:0.6,602:0.7" || reading.Raw != "400:600,500:700,600:800" {
+					t.Fatalf("unexpected correction %q from %q", reading.Spectrum, reading.Raw)
+				}
+				if reading.Technique != "reflectance" || reading.CalibrationID == nil || reading.Uncalibrated {
+					t.Fatalf("unexpected reading %+v", reading)
+				}
+			},
+		},
+		{
+			name:   "no calibration at measurement time",
+			method: http.MethodPost,
+			path:   readingPath(draft),
+			as:     asCreator,
+			setup:  withInstrument,
+			body:   rawReading("400:600,500:700,600:800", calibratedTo.Add(time.Hour)),
+			status: http.StatusCreated,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				reading := decodeReading(t, rec)
+				if !reading.Uncalibrated || reading.CalibrationID != nil || reading.Spectrum != reading.Raw {
+					t.Fatalf("unexpected reading %+v", reading)
+				}
+
+				// Неисправленные отсчёты в сводку не входят: она строится по спектру самой заявки
+				rec = env.Do(t, http.MethodGet, analysisPath("", draft)(f), nil, testenv.WithToken(env.Token(t, f.Creator)))
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				aggregate := response.Analysis.Aggregate
+				if len(response.Analysis.Readings) != 1 || !response.Analysis.Readings[0].Uncalibrated ||
+					aggregate == nil || aggregate.Readings != 1 || aggregate.Mean[0] != 0.15 {
+					t.Fatalf("uncalibrated reading used: %+v", response.Analysis)
+				}
+			},
+		},
+		{
+			name:   "technique differs from instrument",
+			method: http.MethodPost,
+			path:   readingPath(draft),
+			as:     asCreator,
+			setup:  withInstrument,
+			body: func(*testenv.Fixtures) any {
+				id := uint(1)
+				return types.AddSpectrumReadingRequest{Technique: "raman", Spectrum: "400:600,500:700", InstrumentID: &id}
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "outside instrument range",
+			method: http.MethodPost,
+			path:   readingPath(draft),
+			as:     asCreator,
+			setup:  withInstrument,
+			body:   rawReading("360:600,500:700,600:800", inside),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "measured in the future",
+			method: http.MethodPost,
+			path:   readingPath(draft),
+			as:     asCreator,
+			setup:  withInstrument,
+			body:   rawReading("400:600,500:700", time.Now().Add(time.Hour)),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown instrument",
+			method: http.MethodPost,
+			path:   readingPath(draft),
+			as:     asCreator,
+			body:   rawReading("400:600,500:700", inside),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "completion refuses uncalibrated readings",
+			method: http.MethodPut,
+			path:   analysisPath("/complete", draft),
+			as:     asModerator,
+			setup: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+				instrument := registerInstrument(t, env)
+				reading := ds.SpectrumReading{Technique: "reflectance", Spectrum: "400:600,500:700", Raw: "400:600,500:700", InstrumentID: &instrument.ID}
+				if err := env.Analyses.AddReading(context.Background(), f.Draft, &reading); err != nil {
+					t.Fatal(err)
+				}
+				formDraftWith(mixedSpectrum)(t, env, f)
+			},
+			body:   body(types.CompleteAnalysisRequest{Action: "complete"}),
+			status: http.StatusBadRequest,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response types.UncalibratedReadingsResponse
+				testenv.Decode(t, rec, &response)
+				if len(response.Readings) != 1 {
+					t.Fatalf("unexpected response %+v", response)
+				}
+			},
+		},
+		{
+			name:   "required calibration refuses readings without instrument",
+			method: http.MethodPut,
+			path:   analysisPath("/complete", draft),
+			as:     asModerator,
+			setup: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+				env.SpectrumAnalysis.RequireCalibration = true
+				reading := ds.SpectrumReading{Technique: "reflectance", Spectrum: "400:0.2,500:0.3,600:0.4"}
+				if err := env.Analyses.AddReading(context.Background(), f.Draft, &reading); err != nil {
+					t.Fatal(err)
+				}
+				formDraftWith(mixedSpectrum)(t, env, f)
+			},
+			body:   body(types.CompleteAnalysisRequest{Action: "complete"}),
+			status: http.StatusBadRequest,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response types.UncalibratedReadingsResponse
+				testenv.Decode(t, rec, &response)
+				if len(response.Readings) != 1 {
+					t.Fatalf("unexpected response %+v", response)
+				}
+			},
+		},
+		{
+			name:   "required calibration refuses analysis spectrum",
+			method: http.MethodPut,
+			path:   analysisPath("/complete", draft),
+			as:     asModerator,
+			setup: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+				env.SpectrumAnalysis.RequireCalibration = true
+				formDraftWith(mixedSpectrum)(t, env, f)
+			},
+			body:   body(types.CompleteAnalysisRequest{Action: "complete"}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "required calibration accepts calibrated readings",
+			method: http.MethodPut,
+			path:   analysisPath("/complete", draft),
+			as:     asModerator,
+			setup: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+				env.SpectrumAnalysis.RequireCalibration = true
+				instrument := registerInstrument(t, env)
+				calibration := uint(1)
+				reading := ds.SpectrumReading{Technique: "reflectance", Spectrum: mixedSpectrum, Raw: "400:600,500:700", InstrumentID: &instrument.ID, CalibrationID: &calibration}
+				if err := env.Analyses.AddReading(context.Background(), f.Draft, &reading); err != nil {
+					t.Fatal(err)
+				}
+				formDraftWith(mixedSpectrum)(t, env, f)
+			},
+			body:   body(types.CompleteAnalysisRequest{Action: "complete"}),
+			status: http.StatusOK,
+		},
+	})
+}
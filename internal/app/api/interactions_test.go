@@ -0,0 +1,282 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/testenv"
+)
+
+type interactionResponse struct {
+	Interaction types.PigmentInteractionResponse `json:"interaction"`
+}
+
+// addInteraction сохраняет правило для пигментов ids (один - деградация, два - пара)
+func addInteraction(t *testing.T, env *testenv.Env, severity, products string, ids ...uint) {
+	t.Helper()
+	rule := ds.PigmentInteraction{PigmentID: ids[0], Severity: severity, Explanation: "объяснение", Products: products}
+	if len(ids) == 2 {
+		first, second := min(ids[0], ids[1]), max(ids[0], ids[1])
+		rule.PigmentID, rule.OtherPigmentID = first, &second
+	}
+	if err := env.Pigments.CreateInteraction(context.Background(), &rule); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// addInteractions - по правилу на каждую пару фикстурных пигментов в заявках
+// и правила деградации ультрамарина и свинцовых белил
+func addInteractions(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+	t.Helper()
+	addInteraction(t, env, ds.InteractionInfo, "", f.Ultramarine.ID, f.Ochre.ID)
+	addInteraction(t, env, ds.InteractionWarning, "сульфид свинца (PbS)", f.LeadWhite.ID, f.Ochre.ID)
+	addInteraction(t, env, ds.InteractionWarning, "обесцвеченный ультрамарин", f.Ultramarine.ID)
+	addInteraction(t, env, ds.InteractionDanger, "Сульфид свинца (PbS)\nплаттнерит (PbO2)", f.LeadWhite.ID)
+}
+
+func expectInteractionCount(want int) func(*testing.T, *testenv.Env, *testenv.Fixtures, *httptest.ResponseRecorder) {
+	return func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+		var response struct {
+			Interactions []types.PigmentInteractionResponse `json:"interactions"`
+			Count        int                                `json:"count"`
+		}
+		testenv.Decode(t, rec, &response)
+		if response.Count != want || len(response.Interactions) != want {
+			t.Fatalf("got %d interactions, want %d: %+v", response.Count, want, response.Interactions)
+		}
+	}
+}
+
+func TestPigmentInteractionRoutes(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "list is public",
+			method: http.MethodGet,
+			path:   path("/api/pigments/interactions"),
+			setup:  addInteractions,
+			status: http.StatusOK,
+			check:  expectInteractionCount(4),
+		},
+		{
+			name:   "list by pigment",
+			method: http.MethodGet,
+			path:   pigmentPath("/api/pigments/interactions?pigment_id=%d", ochre),
+			setup:  addInteractions,
+			status: http.StatusOK,
+			check:  expectInteractionCount(2),
+		},
+		{
+			name:   "create pair",
+			method: http.MethodPost,
+			path:   path("/api/pigments/interactions"),
+			as:     asModerator,
+			body: func(f *testenv.Fixtures) any {
+				return map[string]any{
+					"pigment_id":       f.Ochre.ID,
+					"other_pigment_id": f.Ultramarine.ID,
+					"severity":         "warning",
+					"explanation":      "  потемнение в присутствии влаги ",
+					"products":         []string{"гидроксид железа", "", "Гидроксид железа"},
+				}
+			},
+			status: http.StatusCreated,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response interactionResponse
+				testenv.Decode(t, rec, &response)
+				rule := response.Interaction
+				// пара хранится с меньшим ID первым
+				if len(rule.Pigments) != 2 || rule.Pigments[0].ID != f.Ultramarine.ID || rule.Pigments[1].Name != f.Ochre.Name {
+					t.Fatalf("unexpected pigments %+v", rule.Pigments)
+				}
+				if rule.Explanation != "потемнение в присутствии влаги" || !reflect.DeepEqual(rule.Products, []string{"гидроксид железа"}) {
+					t.Fatalf("unexpected rule %+v", rule)
+				}
+			},
+		},
+		{
+			name:   "create degradation rule",
+			method: http.MethodPost,
+			path:   path("/api/pigments/interactions"),
+			as:     asModerator,
+			body: func(f *testenv.Fixtures) any {
+				return map[string]any{"pigment_id": f.Ochre.ID, "severity": "info", "explanation": "выцветание"}
+			},
+			status: http.StatusCreated,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response interactionResponse
+				testenv.Decode(t, rec, &response)
+				if len(response.Interaction.Pigments) != 1 || response.Interaction.Products != nil {
+					t.Fatalf("unexpected rule %+v", response.Interaction)
+				}
+			},
+		},
+		{
+			name:   "create duplicate pair",
+			method: http.MethodPost,
+			path:   path("/api/pigments/interactions"),
+			as:     asModerator,
+			setup:  addInteractions,
+			body: func(f *testenv.Fixtures) any {
+				return map[string]any{"pigment_id": f.Ochre.ID, "other_pigment_id": f.Ultramarine.ID, "severity": "danger", "explanation": "повтор"}
+			},
+			status: http.StatusConflict,
+		},
+		{
+			name:   "create pair of one pigment",
+			method: http.MethodPost,
+			path:   path("/api/pigments/interactions"),
+			as:     asModerator,
+			body: func(f *testenv.Fixtures) any {
+				return map[string]any{"pigment_id": f.Ochre.ID, "other_pigment_id": f.Ochre.ID, "severity": "info", "explanation": "x"}
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "create unknown severity",
+			method: http.MethodPost,
+			path:   path("/api/pigments/interactions"),
+			as:     asModerator,
+			body: func(f *testenv.Fixtures) any {
+				return map[string]any{"pigment_id": f.Ochre.ID, "severity": "fatal", "explanation": "x"}
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "create for archived pigment",
+			method: http.MethodPost,
+			path:   path("/api/pigments/interactions"),
+			as:     asModerator,
+			body: func(f *testenv.Fixtures) any {
+				return map[string]any{"pigment_id": f.LeadWhite.ID, "severity": "info", "explanation": "x"}
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "create requires moderator",
+			method: http.MethodPost,
+			path:   path("/api/pigments/interactions"),
+			as:     asCreator,
+			body: func(f *testenv.Fixtures) any {
+				return map[string]any{"pigment_id": f.Ochre.ID, "severity": "info", "explanation": "x"}
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "update",
+			method: http.MethodPut,
+			path:   path("/api/pigments/interactions/4"),
+			as:     asModerator,
+			setup:  addInteractions,
+			body:   body(map[string]any{"severity": "warning", "products": []string{}}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response interactionResponse
+				testenv.Decode(t, rec, &response)
+				rule := response.Interaction
+				if rule.Severity != "warning" || rule.Explanation != "объяснение" || rule.Products != nil {
+					t.Fatalf("unexpected rule %+v", rule)
+				}
+				if len(rule.Pigments) != 1 || !rule.Pigments[0].Archived {
+					t.Fatalf("unexpected pigments %+v", rule.Pigments)
+				}
+			},
+		},
+		{
+			name:   "update unknown",
+			method: http.MethodPut,
+			path:   path("/api/pigments/interactions/99"),
+			as:     asModerator,
+			body:   body(map[string]any{"severity": "info"}),
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "delete",
+			method: http.MethodDelete,
+			path:   path("/api/pigments/interactions/1"),
+			as:     asModerator,
+			setup:  addInteractions,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				rec = env.Do(t, http.MethodGet, "/api/pigments/interactions", nil)
+				expectInteractionCount(3)(t, env, f, rec)
+			},
+		},
+		{
+			name:   "delete unknown",
+			method: http.MethodDelete,
+			path:   path("/api/pigments/interactions/99"),
+			as:     asModerator,
+			status: http.StatusNotFound,
+		},
+	})
+}
+
+func TestInteractionWarnings(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "analysis warnings",
+			method: http.MethodGet,
+			path:   analysisPath("", completed),
+			as:     asCreator,
+			setup:  addInteractions,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				warnings := response.Analysis.Warnings
+				// сначала деградация белил (danger), затем пара охры и белил
+				if len(warnings) != 2 || warnings[0].Severity != "danger" || len(warnings[1].Pigments) != 2 {
+					t.Fatalf("unexpected warnings %+v", warnings)
+				}
+				if warnings[0].Pigments[0].ID != f.LeadWhite.ID || !warnings[0].Pigments[0].Archived {
+					t.Fatalf("unexpected pigments %+v", warnings[0].Pigments)
+				}
+				want := []string{"Сульфид свинца (PbS)", "платтнерит (PbO2)"}
+				if !reflect.DeepEqual(response.Analysis.DegradationProducts, want) {
+					t.Fatalf("degradation products %q, want %q", response.Analysis.DegradationProducts, want)
+				}
+			},
+		},
+		{
+			name:   "no rules no warnings",
+			method: http.MethodGet,
+			path:   analysisPath("", completed),
+			as:     asCreator,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				if response.Analysis.Warnings != nil || response.Analysis.DegradationProducts != nil {
+					t.Fatalf("unexpected warnings %+v", response.Analysis.Warnings)
+				}
+			},
+		},
+		{
+			name:   "cart warnings",
+			method: http.MethodGet,
+			path:   path("/api/spectrum-analysis/cart"),
+			as:     asCreator,
+			setup:  addInteractions,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					Warnings            []types.InteractionWarning `json:"warnings"`
+					DegradationProducts []string                   `json:"degradation_products"`
+				}
+				testenv.Decode(t, rec, &response)
+				// в черновике ультрамарин и охра: деградация ультрамарина и пара
+				if len(response.Warnings) != 2 || response.Warnings[0].Severity != "warning" || response.Warnings[1].Severity != "info" {
+					t.Fatalf("unexpected warnings %+v", response.Warnings)
+				}
+				if !reflect.DeepEqual(response.DegradationProducts, []string{"обесцвеченный ультрамарин"}) {
+					t.Fatalf("unexpected degradation products %q", response.DegradationProducts)
+				}
+			},
+		},
+	})
+}
@@ -0,0 +1,75 @@
+package api_test
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/testenv"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testenv.Main(m))
+}
+
+// routeCase - один запрос к API на свежем окружении с фикстурами
+type routeCase struct {
+	name   string
+	method string
+	path   func(f *testenv.Fixtures) string
+	as     func(f *testenv.Fixtures) *ds.User // nil - анонимный запрос
+	body   func(f *testenv.Fixtures) any
+	header func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) map[string]string
+	// setup готовит данные в хранилище перед запросом
+	setup  func(t *testing.T, env *testenv.Env, f *testenv.Fixtures)
+	status int
+	check  func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder)
+}
+
+func runRouteCases(t *testing.T, cases []routeCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := testenv.New(t)
+			f := env.Seed(t)
+			if tc.setup != nil {
+				tc.setup(t, env, f)
+			}
+
+			var opts []testenv.RequestOption
+			if tc.as != nil {
+				opts = append(opts, testenv.WithToken(env.Token(t, *tc.as(f))))
+			}
+			if tc.header != nil {
+				for key, value := range tc.header(t, env, f) {
+					opts = append(opts, testenv.WithHeader(key, value))
+				}
+			}
+			var body any
+			if tc.body != nil {
+				body = tc.body(f)
+			}
+
+			rec := env.Do(t, tc.method, tc.path(f), body, opts...)
+			if rec.Code != tc.status {
+				t.Fatalf("%s %s: status %d, want %d; body %s", tc.method, tc.path(f), rec.Code, tc.status, rec.Body.String())
+			}
+			if tc.check != nil {
+				tc.check(t, env, f, rec)
+			}
+		})
+	}
+}
+
+func path(p string) func(*testenv.Fixtures) string {
+	return func(*testenv.Fixtures) string { return p }
+}
+
+func body(v any) func(*testenv.Fixtures) any {
+	return func(*testenv.Fixtures) any { return v }
+}
+
+func asCreator(f *testenv.Fixtures) *ds.User   { return &f.Creator }
+func asModerator(f *testenv.Fixtures) *ds.User { return &f.Moderator }
+func asStranger(f *testenv.Fixtures) *ds.User  { return &f.Stranger }
@@ -0,0 +1,140 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/testenv"
+
+	"github.com/google/uuid"
+)
+
+const flatGrey = "400:0.4,500:0.4,600:0.4,700:0.4"
+
+func metamerism(first, second func(f *testenv.Fixtures) types.MetamerismSample, reference string) func(*testenv.Fixtures) any {
+	return func(f *testenv.Fixtures) any {
+		return types.MetamerismRequest{First: first(f), Second: second(f), Reference: reference}
+	}
+}
+
+func spectrumSample(text string) func(*testenv.Fixtures) types.MetamerismSample {
+	return func(*testenv.Fixtures) types.MetamerismSample { return types.MetamerismSample{Spectrum: text} }
+}
+
+func TestCompareMetamerism(t *testing.T) {
+	path := func(*testenv.Fixtures) string { return "/api/spectra/metamerism" }
+	draft := func(f *testenv.Fixtures) types.MetamerismSample {
+		return types.MetamerismSample{AnalysisID: f.Draft.ID.String()}
+	}
+	blend := func(f *testenv.Fixtures) types.MetamerismSample {
+		return types.MetamerismSample{Mixture: []types.MixtureComponent{{PigmentID: f.Ultramarine.ID, Percent: 40}, {PigmentID: f.Ochre.ID, Percent: 60}}}
+	}
+	runRouteCases(t, []routeCase{
+		{
+			name:   "identical spectra",
+			method: http.MethodPost,
+			path:   path,
+			as:     asCreator,
+			body:   metamerism(spectrumSample(flatGrey), spectrumSample(flatGrey), ""),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response types.MetamerismResponse
+				testenv.Decode(t, rec, &response)
+				if response.Reference != "D65" || len(response.Illuminants) != 3 || response.Illuminants[0].Illuminant != "D65" {
+					t.Fatalf("unexpected table %+v", response.Illuminants)
+				}
+				for _, row := range response.Illuminants {
+					if row.DeltaE != 0 || row.MetamerismIndex != 0 {
+						t.Fatalf("identical spectra differ under %s: %+v", row.Illuminant, row)
+					}
+				}
+				if response.MetamerismIndex != 0 || response.FirstColor.Hex != response.SecondColor.Hex {
+					t.Fatalf("unexpected summary %+v", response)
+				}
+			},
+		},
+		{
+			name:   "analysis against mixture under tungsten",
+			method: http.MethodPost,
+			path:   path,
+			as:     asCreator,
+			body:   metamerism(draft, blend, "a"),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response types.MetamerismResponse
+				testenv.Decode(t, rec, &response)
+				if response.Reference != "A" || len(response.Illuminants) != 3 || response.Illuminants[0].Illuminant != "A" {
+					t.Fatalf("unexpected table %+v", response.Illuminants)
+				}
+				// под опорным источником расхождение вычитается целиком
+				if reference := response.Illuminants[0]; reference.DeltaE <= 0 || reference.MetamerismIndex != 0 {
+					t.Fatalf("unexpected reference row %+v", reference)
+				}
+				if response.MetamerismIndex <= 0 || response.Worst == "" || response.Worst == "A" {
+					t.Fatalf("unexpected summary %+v", response)
+				}
+			},
+		},
+		{
+			name:   "unknown illuminant",
+			method: http.MethodPost,
+			path:   path,
+			as:     asCreator,
+			body:   metamerism(spectrumSample(flatGrey), spectrumSample(flatGrey), "D50"),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "led illuminant is not supported",
+			method: http.MethodPost,
+			path:   path,
+			as:     asCreator,
+			body:   metamerism(spectrumSample(flatGrey), spectrumSample(flatGrey), "led"),
+			status: http.StatusBadRequest,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response types.ErrorResponse
+				testenv.Decode(t, rec, &response)
+				if !strings.Contains(response.Message, "LED-B3") {
+					t.Fatalf("unexpected message %q", response.Message)
+				}
+			},
+		},
+		{
+			name:   "sample with two sources",
+			method: http.MethodPost,
+			path:   path,
+			as:     asCreator,
+			body: metamerism(func(f *testenv.Fixtures) types.MetamerismSample {
+				return types.MetamerismSample{Spectrum: flatGrey, AnalysisID: f.Draft.ID.String()}
+			}, spectrumSample(flatGrey), ""),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "infrared spectrum",
+			method: http.MethodPost,
+			path:   path,
+			as:     asCreator,
+			body:   metamerism(spectrumSample(flatGrey), spectrumSample("900:0.5,1000:0.6"), ""),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown analysis",
+			method: http.MethodPost,
+			path:   path,
+			as:     asCreator,
+			body: metamerism(func(*testenv.Fixtures) types.MetamerismSample {
+				return types.MetamerismSample{AnalysisID: uuid.NewString()}
+			}, spectrumSample(flatGrey), ""),
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "anonymous",
+			method: http.MethodPost,
+			path:   path,
+			body:   metamerism(spectrumSample(flatGrey), spectrumSample(flatGrey), ""),
+			status: http.StatusUnauthorized,
+		},
+	})
+}
@@ -13,14 +13,14 @@ import (
 )
 
 type AuthMiddleware struct {
-	Repository *repository.Repository
-	JWTSecret  string
+	Users     repository.UserStore
+	JWTSecret string
 }
 
-func NewAuthMiddleware(repo *repository.Repository, jwtSecret string) *AuthMiddleware {
+func NewAuthMiddleware(users repository.UserStore, jwtSecret string) *AuthMiddleware {
 	return &AuthMiddleware{
-		Repository: repo,
-		JWTSecret:  jwtSecret,
+		Users:     users,
+		JWTSecret: jwtSecret,
 	}
 }
 
@@ -50,8 +50,8 @@ func (a *AuthMiddleware) AuthRequired() gin.HandlerFunc {
 		}
 
 		// Проверяем что пользователь все еще существует
-		var user ds.User
-		if err := a.Repository.GetDB().First(&user, claims.UserID).Error; err != nil {
+		user, err := a.Users.GetUser(c.Request.Context(), claims.UserID)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, types.Fail("Пользователь не найден"))
 			c.Abort()
 			return
@@ -61,7 +61,7 @@ func (a *AuthMiddleware) AuthRequired() gin.HandlerFunc {
 		c.Set("user_id", claims.UserID)
 		c.Set("user_login", claims.Login)
 		c.Set("is_moderator", claims.IsModerator)
-		c.Set("user", user)
+		c.Set("user", *user)
 
 		c.Next()
 	}
@@ -88,12 +88,11 @@ func (a *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 			claims, err := a.ValidateToken(tokenString)
 			if err == nil {
 				// Проверяем что пользователь все еще существует
-				var user ds.User
-				if err := a.Repository.GetDB().First(&user, claims.UserID).Error; err == nil {
+				if user, err := a.Users.GetUser(c.Request.Context(), claims.UserID); err == nil {
 					c.Set("user_id", claims.UserID)
 					c.Set("user_login", claims.Login)
 					c.Set("is_moderator", claims.IsModerator)
-					c.Set("user", user)
+					c.Set("user", *user)
 				}
 			}
 		}
@@ -0,0 +1,134 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/testenv"
+)
+
+type mixtureResponse struct {
+	Predicted     types.ProcessedSpectrum `json:"predicted"`
+	Spectrum      string                  `json:"spectrum"`
+	Color         types.SpectrumColor     `json:"color"`
+	MeasuredColor *types.SpectrumColor    `json:"measured_color"`
+	RMSE          *float64                `json:"rmse"`
+}
+
+func mixture(pick func(f *testenv.Fixtures) []types.MixtureComponent, measured string) func(*testenv.Fixtures) any {
+	return func(f *testenv.Fixtures) any {
+		return types.MixtureRequest{Components: pick(f), Measured: measured}
+	}
+}
+
+func TestPredictMixture(t *testing.T) {
+	path := func(*testenv.Fixtures) string { return "/api/spectra/mixture" }
+	runRouteCases(t, []routeCase{
+		{
+			name:   "single pigment keeps its reference",
+			method: http.MethodPost,
+			path:   path,
+			as:     asCreator,
+			body: mixture(func(f *testenv.Fixtures) []types.MixtureComponent {
+				return []types.MixtureComponent{{PigmentID: f.Ultramarine.ID, Percent: 100}}
+			}, ""),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response mixtureResponse
+				testenv.Decode(t, rec, &response)
+				if len(response.Predicted.Wavelengths) != 31 || response.Predicted.Values[0] != 0.45 || !strings.HasPrefix(response.Spectrum, "400:0.45,410:") {
+					t.Fatalf("unexpected prediction %+v", response.Predicted)
+				}
+				// Ультрамарин синий: b* отрицательный, синий канал преобладает
+				if response.Color.Lab[2] >= 0 || response.Color.Hex[5:7] <= response.Color.Hex[1:3] {
+					t.Fatalf("unexpected color %+v", response.Color)
+				}
+				if response.MeasuredColor != nil || response.RMSE != nil {
+					t.Fatal("nothing to compare with")
+				}
+			},
+		},
+		{
+			name:   "mixture compared with measurement",
+			method: http.MethodPost,
+			path:   path,
+			as:     asCreator,
+			body: mixture(func(f *testenv.Fixtures) []types.MixtureComponent {
+				return []types.MixtureComponent{{PigmentID: f.Ultramarine.ID, Percent: 40}, {PigmentID: f.Ochre.ID, Percent: 60}}
+			}, "400:0.12,500:0.2,600:0.25,700:0.35"),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response mixtureResponse
+				testenv.Decode(t, rec, &response)
+				// K/S смешивается, отражение смеси ниже среднего отражений компонентов
+				at500 := response.Predicted.Values[10]
+				if response.Predicted.Wavelengths[10] != 500 || at500 >= 0.4*0.30+0.6*0.18 || at500 <= 0.18 {
+					t.Fatalf("R(500) = %v", at500)
+				}
+				if response.MeasuredColor == nil || response.RMSE == nil || *response.RMSE <= 0 || *response.RMSE > 0.1 {
+					t.Fatalf("comparison %+v, rmse %v", response.MeasuredColor, response.RMSE)
+				}
+			},
+		},
+		{
+			name:   "archived pigment",
+			method: http.MethodPost,
+			path:   path,
+			as:     asCreator,
+			body: mixture(func(f *testenv.Fixtures) []types.MixtureComponent {
+				return []types.MixtureComponent{{PigmentID: f.LeadWhite.ID, Percent: 100}}
+			}, ""),
+			status: http.StatusBadRequest, // у свинцовых белил нет эталона отражения
+		},
+		{
+			name:   "unknown pigment",
+			method: http.MethodPost,
+			path:   path,
+			as:     asCreator,
+			body: mixture(func(f *testenv.Fixtures) []types.MixtureComponent {
+				return []types.MixtureComponent{{PigmentID: 999, Percent: 100}}
+			}, ""),
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "duplicate pigment",
+			method: http.MethodPost,
+			path:   path,
+			as:     asCreator,
+			body: mixture(func(f *testenv.Fixtures) []types.MixtureComponent {
+				return []types.MixtureComponent{{PigmentID: f.Ochre.ID, Percent: 50}, {PigmentID: f.Ochre.ID, Percent: 50}}
+			}, ""),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "percent out of range",
+			method: http.MethodPost,
+			path:   path,
+			as:     asCreator,
+			body: mixture(func(f *testenv.Fixtures) []types.MixtureComponent {
+				return []types.MixtureComponent{{PigmentID: f.Ochre.ID, Percent: 150}}
+			}, ""),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid measured spectrum",
+			method: http.MethodPost,
+			path:   path,
+			as:     asCreator,
+			body: mixture(func(f *testenv.Fixtures) []types.MixtureComponent {
+				return []types.MixtureComponent{{PigmentID: f.Ochre.ID, Percent: 100}}
+			}, "400:abc"),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "requires token",
+			method: http.MethodPost,
+			path:   path,
+			body:   body(types.MixtureRequest{}),
+			status: http.StatusUnauthorized,
+		},
+	})
+}
@@ -0,0 +1,195 @@
+package api_test
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/spectra"
+	"colorLex/internal/app/testenv"
+)
+
+// draftSpectrum - спектр черновика из фикстур
+const draftSpectrum = "400:0.15,500:0.25,600:0.45"
+
+// expectSpectrumForms проверяет, какие формы спектра черновика есть в ответе
+func expectSpectrumForms(expanded, compact bool) func(*testing.T, *testenv.Env, *testenv.Fixtures, *httptest.ResponseRecorder) {
+	return func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+		var response analysisResponse
+		testenv.Decode(t, rec, &response)
+		checkSpectrumForms(t, response.Analysis, draftSpectrum, expanded, compact)
+	}
+}
+
+// checkSpectrumForms сверяет формы спектра с want - спектром на сетке 400-600 нм с шагом 100
+func checkSpectrumForms(t *testing.T, analysis types.SpectrumAnalysisResponse, want string, expanded, compact bool) {
+	t.Helper()
+	if got := analysis.Spectrum != ""; got != expanded {
+		t.Fatalf("expanded spectrum %q, want present = %v", analysis.Spectrum, expanded)
+	}
+	if expanded && analysis.Spectrum != want {
+		t.Fatalf("unexpected expanded spectrum %q", analysis.Spectrum)
+	}
+	packed := analysis.SpectrumPacked
+	if got := packed != nil; got != compact {
+		t.Fatalf("compact spectrum %+v, want present = %v", packed, compact)
+	}
+	if !compact {
+		return
+	}
+	if packed.Count != 3 || packed.Start != 400 || packed.Step != 100 || !packed.Regular {
+		t.Fatalf("unexpected grid %+v", packed)
+	}
+	data, err := base64.StdEncoding.DecodeString(packed.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spectrum, err := spectra.Packed(data).Decode()
+	if err != nil || spectrum.String() != want {
+		t.Fatalf("compact spectrum decodes to %v, %v", spectrum, err)
+	}
+}
+
+// withUnparsedSpectrum делает черновик таким, каким его оставляет миграция, если текст
+// спектра не разобрался: спектра нет, заявка помечена
+func withUnparsedSpectrum(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+	ctx := context.Background()
+	analysis, err := env.Analyses.GetAnalysis(ctx, f.Draft.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	analysis.Spectrum, analysis.SpectrumUnparsed = nil, true
+	if err := env.Analyses.UpdateAnalysis(ctx, analysis); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpectrumFormatRoutes(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "expanded by default",
+			method: http.MethodGet,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			status: http.StatusOK,
+			check:  expectSpectrumForms(true, false),
+		},
+		{
+			name:   "both forms",
+			method: http.MethodGet,
+			path:   analysisPath("?spectrum_format=both", draft),
+			as:     asCreator,
+			status: http.StatusOK,
+			check:  expectSpectrumForms(true, true),
+		},
+		{
+			name:   "compact only",
+			method: http.MethodGet,
+			path:   analysisPath("?spectrum_format=compact", draft),
+			as:     asCreator,
+			status: http.StatusOK,
+			check:  expectSpectrumForms(false, true),
+		},
+		{
+			name:   "expanded only",
+			method: http.MethodGet,
+			path:   analysisPath("?spectrum_format=expanded", draft),
+			as:     asCreator,
+			status: http.StatusOK,
+			check:  expectSpectrumForms(true, false),
+		},
+		{
+			name:   "unknown format",
+			method: http.MethodGet,
+			path:   analysisPath("?spectrum_format=binary", draft),
+			as:     asCreator,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "list compact",
+			method: http.MethodGet,
+			path:   path("/api/spectrum-analysis?status=created&spectrum_format=compact"),
+			as:     asCreator,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response analysesResponse
+				testenv.Decode(t, rec, &response)
+				if len(response.Analyses) != 1 {
+					t.Fatalf("unexpected analyses %+v", response.Analyses)
+				}
+				checkSpectrumForms(t, response.Analyses[0], "400:0.12,500:0.35,600:0.41", false, true)
+			},
+		},
+		{
+			name:   "list unknown format",
+			method: http.MethodGet,
+			path:   path("/api/spectrum-analysis?spectrum_format=binary"),
+			as:     asCreator,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "update stores packed spectrum",
+			method: http.MethodPut,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			body:   body(types.UpdateSpectrumAnalysisRequest{Spectrum: "400\t0.15\n500\t0.25\n600\t0.45"}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				expectSpectrumForms(true, false)(t, env, f, rec)
+				analysis, err := env.Analyses.GetAnalysis(context.Background(), f.Draft.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if spectrum, err := spectra.Packed(analysis.Spectrum).Decode(); err != nil || spectrum.String() != draftSpectrum {
+					t.Fatalf("stored spectrum decodes to %v, %v", spectrum, err)
+				}
+			},
+		},
+		{
+			name:   "unparsed spectrum is flagged",
+			method: http.MethodGet,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			setup:  withUnparsedSpectrum,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				if !response.Analysis.SpectrumUnparsed || response.Analysis.Spectrum != "" {
+					t.Fatalf("unexpected analysis %+v", response.Analysis)
+				}
+			},
+		},
+		{
+			name:   "new spectrum clears unparsed flag",
+			method: http.MethodPut,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			setup:  withUnparsedSpectrum,
+			body:   body(types.UpdateSpectrumAnalysisRequest{Spectrum: draftSpectrum}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				analysis, err := env.Analyses.GetAnalysis(context.Background(), f.Draft.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if response.Analysis.SpectrumUnparsed || analysis.SpectrumUnparsed {
+					t.Fatal("unparsed flag kept after a new spectrum")
+				}
+			},
+		},
+		{
+			name:   "update rejects unparsable spectrum",
+			method: http.MethodPut,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			body:   body(types.UpdateSpectrumAnalysisRequest{Spectrum: "255,54,17"}),
+			status: http.StatusBadRequest,
+		},
+	})
+}
@@ -0,0 +1,139 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/testenv"
+)
+
+// mixedSpectrum - 40% эталона ультрамарина и 60% эталона охры с небольшим шумом
+const mixedSpectrum = "400:0.2262,450:0.2611,500:0.2271,550:0.2752,600:0.3335,650:0.369,700:0.4487"
+
+// formDraftWith задаёт спектр черновика, добавляет в него пигменты и формирует его
+func formDraftWith(spectrum string, extra ...func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) ds.Pigment) func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+	return func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+		t.Helper()
+		ctx := context.Background()
+		for _, create := range extra {
+			pigment := create(t, env, f)
+			if _, _, err := env.Analyses.AddPigmentToDraft(ctx, f.Creator.ID, pigment.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+		analysis, err := env.Analyses.GetAnalysis(ctx, f.Draft.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		analysis.Spectrum = testenv.PackSpectrum(t, spectrum)
+		if err := env.Analyses.UpdateAnalysis(ctx, analysis); err != nil {
+			t.Fatal(err)
+		}
+		rec := env.Do(t, http.MethodPut, analysisPath("/form", draft)(f), nil, testenv.WithToken(env.Token(t, f.Creator)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("form: status %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+}
+
+// newPigment создаёт пигмент с эталоном отражения; пустой reference - без эталона
+func newPigment(name, reference string) func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) ds.Pigment {
+	return func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) ds.Pigment {
+		t.Helper()
+		ctx := context.Background()
+		pigment := ds.Pigment{Name: name}
+		if err := env.Pigments.CreatePigment(ctx, &pigment); err != nil {
+			t.Fatal(err)
+		}
+		if reference != "" {
+			spectrum := ds.ReferenceSpectrum{PigmentID: pigment.ID, Technique: "reflectance", Spectrum: reference}
+			if err := env.Pigments.AddReferenceSpectrum(ctx, &spectrum); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return pigment
+	}
+}
+
+// completedPigments возвращает пигменты завершённой заявки по ID
+func completedPigments(t *testing.T, env *testenv.Env, f *testenv.Fixtures) map[uint]types.PigmentInAnalysis {
+	t.Helper()
+	rec := env.Do(t, http.MethodGet, analysisPath("", draft)(f), nil, testenv.WithToken(env.Token(t, f.Creator)))
+	var response analysisResponse
+	testenv.Decode(t, rec, &response)
+	pigments := make(map[uint]types.PigmentInAnalysis)
+	for _, pigment := range response.Analysis.Pigments {
+		pigments[pigment.PigmentID] = pigment
+	}
+	return pigments
+}
+
+func TestCompletePercentIntervals(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "intervals from unmixing",
+			method: http.MethodPut,
+			path:   analysisPath("/complete", draft),
+			as:     asModerator,
+			setup:  formDraftWith(mixedSpectrum),
+			body:   body(types.CompleteAnalysisRequest{Action: "complete"}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				pigments := completedPigments(t, env, f)
+				ochre, ultramarine := pigments[f.Ochre.ID], pigments[f.Ultramarine.ID]
+				if ochre.Percent < 57 || ochre.Percent > 63 || ultramarine.Percent < 37 || ultramarine.Percent > 43 {
+					t.Fatalf("percents %v and %v, want about 60 and 40", ochre.Percent, ultramarine.Percent)
+				}
+				for _, pigment := range []types.PigmentInAnalysis{ochre, ultramarine} {
+					interval := pigment.PercentInterval
+					if interval == nil || interval.Low > pigment.Percent || interval.High < pigment.Percent || interval.High-interval.Low > 20 {
+						t.Fatalf("unexpected interval for %s: %+v", pigment.Name, interval)
+					}
+					if len(pigment.Indistinguishable) != 0 {
+						t.Fatalf("%s flagged as indistinguishable", pigment.Name)
+					}
+				}
+			},
+		},
+		{
+			name:   "indistinguishable pigments",
+			method: http.MethodPut,
+			path:   analysisPath("/complete", draft),
+			as:     asModerator,
+			// Эталон сиены - кривая охры, умноженная на 0.6
+			setup:  formDraftWith(mixedSpectrum, newPigment("Сиена натуральная", "400:0.048,450:0.06,500:0.108,550:0.228,600:0.3,650:0.33,700:0.348")),
+			body:   body(types.CompleteAnalysisRequest{Action: "complete"}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				pigments := completedPigments(t, env, f)
+				ochre := pigments[f.Ochre.ID]
+				if len(ochre.Indistinguishable) != 1 || pigments[ochre.Indistinguishable[0]].Name != "Сиена натуральная" {
+					t.Fatalf("ochre must be flagged: %+v", ochre)
+				}
+				if len(pigments[f.Ultramarine.ID].Indistinguishable) != 0 {
+					t.Fatalf("ultramarine flagged: %+v", pigments[f.Ultramarine.ID])
+				}
+			},
+		},
+		{
+			name:   "pigment without reference",
+			method: http.MethodPut,
+			path:   analysisPath("/complete", draft),
+			as:     asModerator,
+			setup:  formDraftWith(mixedSpectrum, newPigment("Марс коричневый", "")),
+			body:   body(types.CompleteAnalysisRequest{Action: "complete"}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				for _, pigment := range completedPigments(t, env, f) {
+					if pigment.PercentInterval != nil || pigment.Percent == 0 {
+						t.Fatalf("without a full set of references percents stay estimated without intervals: %+v", pigment)
+					}
+				}
+			},
+		},
+	})
+}
@@ -0,0 +1,580 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/testenv"
+)
+
+type pigmentsResponse struct {
+	Pigments []types.PigmentResponse `json:"pigments"`
+	Count    int                     `json:"count"`
+}
+
+type pigmentResponse struct {
+	Pigment types.PigmentResponse `json:"pigment"`
+}
+
+func pigmentPath(format string, pick func(f *testenv.Fixtures) uint) func(*testenv.Fixtures) string {
+	return func(f *testenv.Fixtures) string { return fmt.Sprintf(format, pick(f)) }
+}
+
+func floatPtr(v float64) *float64 { return &v }
+
+func strPtr(v string) *string { return &v }
+
+func intPtr(v int) *int { return &v }
+
+func ultramarine(f *testenv.Fixtures) uint { return f.Ultramarine.ID }
+func ochre(f *testenv.Fixtures) uint       { return f.Ochre.ID }
+func leadWhite(f *testenv.Fixtures) uint   { return f.LeadWhite.ID }
+
+func expectPigmentCount(want int) func(*testing.T, *testenv.Env, *testenv.Fixtures, *httptest.ResponseRecorder) {
+	return func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+		var response pigmentsResponse
+		testenv.Decode(t, rec, &response)
+		if response.Count != want || len(response.Pigments) != want {
+			t.Fatalf("got %d pigments, want %d: %+v", response.Count, want, response.Pigments)
+		}
+	}
+}
+
+const imageFormBoundary = "colorlex-test-boundary"
+
+// imageForm собирает multipart-форму с файлом image
+func imageForm(filename string) func(*testenv.Fixtures) any {
+	return func(*testenv.Fixtures) any {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		writer.SetBoundary(imageFormBoundary)
+		part, _ := writer.CreateFormFile("image", filename)
+		part.Write([]byte("fake image"))
+		writer.Close()
+		return &buf
+	}
+}
+
+func multipartHeader(*testing.T, *testenv.Env, *testenv.Fixtures) map[string]string {
+	return map[string]string{"Content-Type": "multipart/form-data; boundary=" + imageFormBoundary}
+}
+
+func TestPigmentCatalogRoutes(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "list hides archived",
+			method: http.MethodGet,
+			path:   path("/api/pigments"),
+			status: http.StatusOK,
+			check:  expectPigmentCount(2),
+		},
+		{
+			name:   "list search",
+			method: http.MethodGet,
+			path:   path("/api/pigments?search=%D1%83%D0%BB%D1%8C%D1%82%D1%80%D0%B0"), // "ультра"
+			status: http.StatusOK,
+			check:  expectPigmentCount(1),
+		},
+		{
+			name:   "list by color",
+			method: http.MethodGet,
+			path:   path("/api/pigments?color=yellow"),
+			status: http.StatusOK,
+			check:  expectPigmentCount(1),
+		},
+		{
+			name:   "list with pagination",
+			method: http.MethodGet,
+			path:   path("/api/pigments?limit=1&offset=1"),
+			status: http.StatusOK,
+			check:  expectPigmentCount(1),
+		},
+		{
+			name:   "list created before date",
+			method: http.MethodGet,
+			path:   path("/api/pigments?date_to=2000-01-01"),
+			status: http.StatusOK,
+			check:  expectPigmentCount(0),
+		},
+		{
+			name:   "list bad date",
+			method: http.MethodGet,
+			path:   path("/api/pigments?date_from=yesterday"),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "archived hidden from anonymous",
+			method: http.MethodGet,
+			path:   path("/api/pigments?include_archived=true"),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "archived hidden from users",
+			method: http.MethodGet,
+			path:   path("/api/pigments?include_archived=true"),
+			as:     asCreator,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "archived visible to moderator",
+			method: http.MethodGet,
+			path:   path("/api/pigments?include_archived=true"),
+			as:     asModerator,
+			status: http.StatusOK,
+			check:  expectPigmentCount(3),
+		},
+		{
+			name:   "list by class",
+			method: http.MethodGet,
+			path:   path("/api/pigments?class=earth"),
+			status: http.StatusOK,
+			check:  expectPigmentCount(1),
+		},
+		{
+			name:   "list by ci name",
+			method: http.MethodGet,
+			path:   path("/api/pigments?ci_name=pb%2029&include_archived=true"),
+			as:     asModerator,
+			status: http.StatusOK,
+			check:  expectPigmentCount(1),
+		},
+		{
+			name:   "list by toxicity",
+			method: http.MethodGet,
+			path:   path("/api/pigments?toxicity=high&include_archived=true"),
+			as:     asModerator,
+			status: http.StatusOK,
+			check:  expectPigmentCount(1),
+		},
+		{
+			name:   "list by formula and lightfastness",
+			method: http.MethodGet,
+			path:   path("/api/pigments?formula=feo&lightfastness_max=2"),
+			status: http.StatusOK,
+			check:  expectPigmentCount(1),
+		},
+		{
+			name:   "list available in year",
+			method: http.MethodGet,
+			path:   path("/api/pigments?available_in=100"),
+			status: http.StatusOK,
+			check:  expectPigmentCount(1),
+		},
+		{
+			name:   "list available in later year",
+			method: http.MethodGet,
+			path:   path("/api/pigments?available_in=1500"),
+			status: http.StatusOK,
+			check:  expectPigmentCount(2),
+		},
+		{
+			name:   "list unknown class",
+			method: http.MethodGet,
+			path:   path("/api/pigments?class=plastic"),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "vocabulary",
+			method: http.MethodGet,
+			path:   path("/api/pigments/vocabulary"),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response types.PigmentVocabularyResponse
+				testenv.Decode(t, rec, &response)
+				if len(response.Classes) != 4 || len(response.Lightfastness) != 5 {
+					t.Fatalf("unexpected vocabulary %+v", response)
+				}
+			},
+		},
+		{
+			name:   "get",
+			method: http.MethodGet,
+			path:   pigmentPath("/api/pigments/%d", ultramarine),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response pigmentResponse
+				testenv.Decode(t, rec, &response)
+				if response.Pigment.Name != f.Ultramarine.Name || response.Pigment.ArchivedAt != nil {
+					t.Fatalf("unexpected pigment %+v", response.Pigment)
+				}
+				if response.Pigment.CIName != "PB29" || response.Pigment.Class != "mineral" || response.Pigment.Lightfastness == nil {
+					t.Fatalf("metadata is missing: %+v", response.Pigment.PigmentMetadata)
+				}
+			},
+		},
+		{
+			name:   "get archived",
+			method: http.MethodGet,
+			path:   pigmentPath("/api/pigments/%d", leadWhite),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response pigmentResponse
+				testenv.Decode(t, rec, &response)
+				if response.Pigment.ArchivedAt == nil {
+					t.Fatal("archived_at is missing")
+				}
+			},
+		},
+		{
+			name:   "get missing",
+			method: http.MethodGet,
+			path:   path("/api/pigments/999"),
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "get bad id",
+			method: http.MethodGet,
+			path:   path("/api/pigments/abc"),
+			status: http.StatusBadRequest,
+		},
+	})
+}
+
+func TestPigmentModerationRoutes(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "create requires moderator",
+			method: http.MethodPost,
+			path:   path("/api/pigments"),
+			as:     asCreator,
+			body:   body(types.CreatePigmentRequest{Name: "Киноварь", Brief: "Сульфид ртути"}),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "create requires token",
+			method: http.MethodPost,
+			path:   path("/api/pigments"),
+			body:   body(types.CreatePigmentRequest{Name: "Киноварь", Brief: "Сульфид ртути"}),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "create",
+			method: http.MethodPost,
+			path:   path("/api/pigments"),
+			as:     asModerator,
+			body:   body(types.CreatePigmentRequest{Name: "Киноварь", Brief: "Сульфид ртути", Color: "red"}),
+			status: http.StatusCreated,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response pigmentResponse
+				testenv.Decode(t, rec, &response)
+				if response.Pigment.ID == 0 || response.Pigment.CreatedAt == "" {
+					t.Fatalf("unexpected pigment %+v", response.Pigment)
+				}
+				expectPigmentCount(3)(t, env, f, env.Do(t, http.MethodGet, "/api/pigments", nil))
+			},
+		},
+		{
+			name:   "create with metadata",
+			method: http.MethodPost,
+			path:   path("/api/pigments"),
+			as:     asModerator,
+			body: body(types.CreatePigmentRequest{
+				Name:  "Киноварь",
+				Brief: "Сульфид ртути",
+				PigmentMetadataRequest: types.PigmentMetadataRequest{
+					CIName:          strPtr("pr 106"),
+					CASNumber:       strPtr("1344-48-5"),
+					Class:           strPtr("mineral"),
+					Toxicity:        strPtr("high"),
+					ParticleSizeMin: floatPtr(1),
+					ParticleSizeMax: floatPtr(20),
+				},
+			}),
+			status: http.StatusCreated,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response pigmentResponse
+				testenv.Decode(t, rec, &response)
+				if response.Pigment.CIName != "PR106" || response.Pigment.CASNumber != "1344-48-5" {
+					t.Fatalf("unexpected metadata %+v", response.Pigment.PigmentMetadata)
+				}
+			},
+		},
+		{
+			name:   "create with bad cas checksum",
+			method: http.MethodPost,
+			path:   path("/api/pigments"),
+			as:     asModerator,
+			body: body(types.CreatePigmentRequest{
+				Name: "Киноварь", Brief: "Сульфид ртути",
+				PigmentMetadataRequest: types.PigmentMetadataRequest{CASNumber: strPtr("1344-48-4")},
+			}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "create with inverted availability",
+			method: http.MethodPost,
+			path:   path("/api/pigments"),
+			as:     asModerator,
+			body: body(types.CreatePigmentRequest{
+				Name:  "Египетская синяя",
+				Brief: "Силикат меди и кальция",
+				PigmentMetadataRequest: types.PigmentMetadataRequest{
+					AvailableFrom: intPtr(800),
+					AvailableTo:   intPtr(-2600),
+				},
+			}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "create with unknown class",
+			method: http.MethodPost,
+			path:   path("/api/pigments"),
+			as:     asModerator,
+			body: body(types.CreatePigmentRequest{
+				Name: "Киноварь", Brief: "Сульфид ртути",
+				PigmentMetadataRequest: types.PigmentMetadataRequest{Class: strPtr("metal")},
+			}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "update particle size below minimum",
+			method: http.MethodPut,
+			path:   pigmentPath("/api/pigments/%d", ochre),
+			as:     asModerator,
+			body: body(types.UpdatePigmentRequest{
+				PigmentMetadataRequest: types.PigmentMetadataRequest{ParticleSizeMin: floatPtr(5), ParticleSizeMax: floatPtr(2)},
+			}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "update clears metadata",
+			method: http.MethodPut,
+			path:   pigmentPath("/api/pigments/%d", ultramarine),
+			as:     asModerator,
+			body: body(map[string]any{
+				"ci_name": "", "ci_number": "", "cas_number": "", "formula": "", "class": "",
+				"lightfastness": 0, "available_from": 0,
+			}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				pigment, err := env.Pigments.GetPigment(context.Background(), f.Ultramarine.ID, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if pigment.CIName != "" || pigment.CINumber != "" || pigment.CASNumber != "" || pigment.Formula != "" ||
+					pigment.Class != "" || pigment.Lightfastness != nil || pigment.AvailableFrom != nil {
+					t.Fatalf("metadata is not cleared: %+v", pigment)
+				}
+				if pigment.Name != f.Ultramarine.Name || pigment.Toxicity != f.Ultramarine.Toxicity {
+					t.Fatalf("fields that were not sent changed: %+v", pigment)
+				}
+			},
+		},
+		{
+			name:   "update clearing keeps validation",
+			method: http.MethodPut,
+			path:   pigmentPath("/api/pigments/%d", ultramarine),
+			as:     asModerator,
+			body:   body(map[string]any{"ci_number": "", "cas_number": "1344-48-4"}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "update lightfastness only",
+			method: http.MethodPut,
+			path:   pigmentPath("/api/pigments/%d", ochre),
+			as:     asModerator,
+			body:   body(map[string]int{"lightfastness": 3}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response pigmentResponse
+				testenv.Decode(t, rec, &response)
+				if response.Pigment.Lightfastness == nil || *response.Pigment.Lightfastness != 3 || response.Pigment.CIName != "PY43" {
+					t.Fatalf("unexpected metadata %+v", response.Pigment.PigmentMetadata)
+				}
+			},
+		},
+		{
+			name:   "create without brief",
+			method: http.MethodPost,
+			path:   path("/api/pigments"),
+			as:     asModerator,
+			body:   body(map[string]string{"name": "Киноварь"}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "update",
+			method: http.MethodPut,
+			path:   pigmentPath("/api/pigments/%d", ochre),
+			as:     asModerator,
+			body:   body(types.UpdatePigmentRequest{Brief: "Гидроксид железа"}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response pigmentResponse
+				testenv.Decode(t, rec, &response)
+				if response.Pigment.Brief != "Гидроксид железа" || response.Pigment.Name != f.Ochre.Name {
+					t.Fatalf("unexpected pigment %+v", response.Pigment)
+				}
+			},
+		},
+		{
+			name:   "update without data",
+			method: http.MethodPut,
+			path:   pigmentPath("/api/pigments/%d", ochre),
+			as:     asModerator,
+			body:   body(types.UpdatePigmentRequest{}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "update archived",
+			method: http.MethodPut,
+			path:   pigmentPath("/api/pigments/%d", leadWhite),
+			as:     asModerator,
+			body:   body(types.UpdatePigmentRequest{Brief: "Карбонат свинца"}),
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "archive",
+			method: http.MethodDelete,
+			path:   pigmentPath("/api/pigments/%d", ultramarine),
+			as:     asModerator,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				expectPigmentCount(1)(t, env, f, env.Do(t, http.MethodGet, "/api/pigments", nil))
+
+				// Черновик продолжает показывать пигмент, отмеченный как архивный
+				rec = env.Do(t, http.MethodGet, "/api/spectrum-analysis/"+f.Draft.ID.String(), nil,
+					testenv.WithToken(env.Token(t, f.Creator)))
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				for _, pigment := range response.Analysis.Pigments {
+					if pigment.PigmentID == f.Ultramarine.ID && !pigment.Archived {
+						t.Fatal("archived pigment is not marked in draft")
+					}
+				}
+			},
+		},
+		{
+			name:   "archive twice",
+			method: http.MethodDelete,
+			path:   pigmentPath("/api/pigments/%d", leadWhite),
+			as:     asModerator,
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "archive requires moderator",
+			method: http.MethodDelete,
+			path:   pigmentPath("/api/pigments/%d", ultramarine),
+			as:     asStranger,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "restore",
+			method: http.MethodPost,
+			path:   pigmentPath("/api/pigments/%d/restore", leadWhite),
+			as:     asModerator,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				expectPigmentCount(3)(t, env, f, env.Do(t, http.MethodGet, "/api/pigments", nil))
+			},
+		},
+		{
+			name:   "restore active",
+			method: http.MethodPost,
+			path:   pigmentPath("/api/pigments/%d/restore", ochre),
+			as:     asModerator,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "upload image",
+			method: http.MethodPost,
+			path:   pigmentPath("/api/pigments/%d/image", ochre),
+			as:     asModerator,
+			body:   imageForm("ochre.PNG"),
+			header: multipartHeader,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					ImageKey string `json:"image_key"`
+				}
+				testenv.Decode(t, rec, &response)
+				rec = env.Do(t, http.MethodGet, fmt.Sprintf("/api/pigments/%d", f.Ochre.ID), nil)
+				var pigment pigmentResponse
+				testenv.Decode(t, rec, &pigment)
+				if response.ImageKey == "" || pigment.Pigment.ImageKey != response.ImageKey {
+					t.Fatalf("image key %q, stored %q", response.ImageKey, pigment.Pigment.ImageKey)
+				}
+			},
+		},
+		{
+			name:   "upload image wrong type",
+			method: http.MethodPost,
+			path:   pigmentPath("/api/pigments/%d/image", ochre),
+			as:     asModerator,
+			body:   imageForm("ochre.gif"),
+			header: multipartHeader,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "upload image without file",
+			method: http.MethodPost,
+			path:   pigmentPath("/api/pigments/%d/image", ochre),
+			as:     asModerator,
+			status: http.StatusBadRequest,
+		},
+	})
+}
+
+func TestAddToSpectrumAnalysis(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "requires token",
+			method: http.MethodPost,
+			path:   pigmentPath("/api/pigments/%d/add-to-sa", ochre),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "creates draft",
+			method: http.MethodPost,
+			path:   pigmentPath("/api/pigments/%d/add-to-sa", ultramarine),
+			as:     asStranger,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					ItemsCount int `json:"items_count"`
+				}
+				testenv.Decode(t, rec, &response)
+				if response.ItemsCount != 1 {
+					t.Fatalf("items_count %d, want 1", response.ItemsCount)
+				}
+			},
+		},
+		{
+			name:   "adds to existing draft",
+			method: http.MethodPost,
+			path:   pigmentPath("/api/pigments/%d/add-to-sa", ochre),
+			as:     asModerator,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				rec = env.Do(t, http.MethodPost, fmt.Sprintf("/api/pigments/%d/add-to-sa", f.Ultramarine.ID), nil,
+					testenv.WithToken(env.Token(t, f.Moderator)))
+				var response struct {
+					ItemsCount int `json:"items_count"`
+				}
+				testenv.Decode(t, rec, &response)
+				if response.ItemsCount != 2 {
+					t.Fatalf("items_count %d, want 2", response.ItemsCount)
+				}
+			},
+		},
+		{
+			name:   "duplicate",
+			method: http.MethodPost,
+			path:   pigmentPath("/api/pigments/%d/add-to-sa", ultramarine),
+			as:     asCreator,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "archived pigment",
+			method: http.MethodPost,
+			path:   pigmentPath("/api/pigments/%d/add-to-sa", leadWhite),
+			as:     asCreator,
+			status: http.StatusNotFound,
+		},
+	})
+}
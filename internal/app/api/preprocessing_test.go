@@ -0,0 +1,167 @@
+package api_test
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/testenv"
+)
+
+func TestSpectrumPreview(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "resample and normalize",
+			method: http.MethodPost,
+			path:   path("/api/spectra/preview"),
+			as:     asCreator,
+			body: body(types.SpectrumPreviewRequest{
+				Spectrum: "400:0.1,500:0.3,600:0.4",
+				Steps: []types.PreprocessingStep{
+					{Op: "resample", Interval: 50},
+					{Op: "normalize", Method: "max"},
+				},
+			}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					Processed types.ProcessedSpectrum `json:"processed"`
+					Spectrum  string                  `json:"spectrum"`
+				}
+				testenv.Decode(t, rec, &response)
+				want := []float64{0.25, 0.5, 0.75, 0.875, 1}
+				if len(response.Processed.Values) != len(want) || len(response.Processed.Wavelengths) != len(want) {
+					t.Fatalf("unexpected curve %+v", response.Processed)
+				}
+				for i, v := range want {
+					if math.Abs(response.Processed.Values[i]-v) > 1e-9 {
+						t.Fatalf("unexpected curve %+v", response.Processed)
+					}
+				}
+				if !strings.HasPrefix(response.Spectrum, "400:0.25,450:0.5,500:") {
+					t.Fatalf("unexpected spectrum %q", response.Spectrum)
+				}
+			},
+		},
+		{
+			name:   "unknown step",
+			method: http.MethodPost,
+			path:   path("/api/spectra/preview"),
+			as:     asCreator,
+			body: body(types.SpectrumPreviewRequest{
+				Spectrum: "400:0.1,500:0.3,600:0.4",
+				Steps:    []types.PreprocessingStep{{Op: "fft"}},
+			}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "step does not fit spectrum",
+			method: http.MethodPost,
+			path:   path("/api/spectra/preview"),
+			as:     asCreator,
+			body: body(types.SpectrumPreviewRequest{
+				Spectrum: "400:0.1,500:0.3,600:0.4",
+				Steps:    []types.PreprocessingStep{{Op: "smooth", Window: 5, PolyOrder: 2}},
+			}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "anonymous",
+			method: http.MethodPost,
+			path:   path("/api/spectra/preview"),
+			body:   body(types.SpectrumPreviewRequest{Spectrum: "400:0.1,500:0.3"}),
+			status: http.StatusUnauthorized,
+		},
+	})
+}
+
+func TestAnalysisPreprocessing(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "set preprocessing",
+			method: http.MethodPut,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			body: body(types.UpdateSpectrumAnalysisRequest{Preprocessing: &[]types.PreprocessingStep{
+				{Op: "baseline", Method: "linear"},
+			}}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				analysis, err := env.Analyses.GetAnalysis(context.Background(), f.Draft.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if analysis.Preprocessing != `[{"op":"baseline","method":"linear"}]` {
+					t.Fatalf("stored preprocessing %q", analysis.Preprocessing)
+				}
+
+				rec = env.Do(t, http.MethodGet, analysisPath("", draft)(f), nil, testenv.WithToken(env.Token(t, f.Creator)))
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				// 400:0.15,500:0.25,600:0.45 минус прямая 0.15-0.45
+				processed := response.Analysis.Processed
+				if len(response.Analysis.Preprocessing) != 1 || processed == nil || len(processed.Values) != 3 ||
+					math.Abs(processed.Values[1]+0.05) > 1e-9 {
+					t.Fatalf("unexpected preprocessing %+v, processed %+v", response.Analysis.Preprocessing, processed)
+				}
+			},
+		},
+		{
+			name:   "preprocessing not applicable",
+			method: http.MethodGet,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			setup: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+				f.Draft.Preprocessing = `[{"op":"smooth","window":5,"poly_order":2}]`
+				if err := env.Analyses.UpdateAnalysis(context.Background(), f.Draft); err != nil {
+					t.Fatal(err)
+				}
+			},
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				if response.Analysis.Processed != nil || response.Analysis.ProcessingError == "" {
+					t.Fatalf("expected processing error, got %+v", response.Analysis)
+				}
+			},
+		},
+		{
+			name:   "clear preprocessing",
+			method: http.MethodPut,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			setup: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+				f.Draft.Preprocessing = `[{"op":"normalize","method":"max"}]`
+				if err := env.Analyses.UpdateAnalysis(context.Background(), f.Draft); err != nil {
+					t.Fatal(err)
+				}
+			},
+			body:   body(types.UpdateSpectrumAnalysisRequest{Preprocessing: &[]types.PreprocessingStep{}}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				analysis, err := env.Analyses.GetAnalysis(context.Background(), f.Draft.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if analysis.Preprocessing != "" {
+					t.Fatalf("preprocessing not cleared: %q", analysis.Preprocessing)
+				}
+			},
+		},
+		{
+			name:   "invalid preprocessing",
+			method: http.MethodPut,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			body: body(types.UpdateSpectrumAnalysisRequest{Preprocessing: &[]types.PreprocessingStep{
+				{Op: "derivative", Order: 3},
+			}}),
+			status: http.StatusBadRequest,
+		},
+	})
+}
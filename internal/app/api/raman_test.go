@@ -0,0 +1,349 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/testenv"
+)
+
+type ramanResponse struct {
+	Readings int                      `json:"readings"`
+	Peaks    []types.RamanPeak        `json:"peaks"`
+	Pigments []types.RamanExplanation `json:"pigments"`
+}
+
+// formWithRaman добавляет в черновик рамановское измерение охры и формирует его
+func formWithRaman(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+	t.Helper()
+	addTechniqueReadings("raman", ochreRaman)(t, env, f)
+	rec := env.Do(t, http.MethodPut, analysisPath("/form", draft)(f), nil, testenv.WithToken(env.Token(t, f.Creator)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("form: status %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func verdicts(pick func(f *testenv.Fixtures) []types.PigmentVerdict) func(*testenv.Fixtures) any {
+	return func(f *testenv.Fixtures) any {
+		return types.CompleteAnalysisRequest{Action: "complete", Verdicts: pick(f)}
+	}
+}
+
+func TestRamanBandRoutes(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "list bands",
+			method: http.MethodGet,
+			path:   pigmentPath("/api/pigments/%d/raman-bands", ochre),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					Bands []types.RamanBand `json:"bands"`
+				}
+				testenv.Decode(t, rec, &response)
+				if len(response.Bands) != 5 || response.Bands[0].Position != 247 || response.Bands[2].Strength != "strong" ||
+					response.Bands[0].Assignment != "Fe-O" {
+					t.Fatalf("unexpected bands %+v", response.Bands)
+				}
+			},
+		},
+		{
+			name:   "pigment without library",
+			method: http.MethodGet,
+			path:   pigmentPath("/api/pigments/%d/raman-bands", ultramarine),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					Count int `json:"count"`
+				}
+				testenv.Decode(t, rec, &response)
+				if response.Count != 0 {
+					t.Fatalf("count %d, want 0", response.Count)
+				}
+			},
+		},
+		{
+			name:   "replace bands",
+			method: http.MethodPut,
+			path:   pigmentPath("/api/pigments/%d/raman-bands", ultramarine),
+			as:     asModerator,
+			body: body(types.ReplaceRamanBandsRequest{Bands: []types.RamanBand{
+				{Position: 1096, Strength: "medium"},
+				{Position: 548, Tolerance: 4, Strength: "strong", Assignment: " S3- "},
+			}}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				bands, err := env.Pigments.ListRamanBands(context.Background(), f.Ultramarine.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(bands) != 2 || bands[0].Position != 548 || bands[0].Assignment != "S3-" || bands[1].Tolerance != 5 {
+					t.Fatalf("unexpected bands %+v", bands)
+				}
+			},
+		},
+		{
+			name:   "clear bands",
+			method: http.MethodPut,
+			path:   pigmentPath("/api/pigments/%d/raman-bands", ochre),
+			as:     asModerator,
+			body:   body(types.ReplaceRamanBandsRequest{}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				bands, err := env.Pigments.ListRamanBands(context.Background(), f.Ochre.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(bands) != 0 {
+					t.Fatalf("bands were not cleared: %+v", bands)
+				}
+			},
+		},
+		{
+			name:   "unknown strength",
+			method: http.MethodPut,
+			path:   pigmentPath("/api/pigments/%d/raman-bands", ochre),
+			as:     asModerator,
+			body:   body(types.ReplaceRamanBandsRequest{Bands: []types.RamanBand{{Position: 385, Strength: "huge"}}}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "tolerance too wide",
+			method: http.MethodPut,
+			path:   pigmentPath("/api/pigments/%d/raman-bands", ochre),
+			as:     asModerator,
+			body:   body(types.ReplaceRamanBandsRequest{Bands: []types.RamanBand{{Position: 385, Tolerance: 60, Strength: "strong"}}}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "position outside raman axis",
+			method: http.MethodPut,
+			path:   pigmentPath("/api/pigments/%d/raman-bands", ochre),
+			as:     asModerator,
+			body:   body(types.ReplaceRamanBandsRequest{Bands: []types.RamanBand{{Position: 5000, Strength: "weak"}}}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "duplicate band",
+			method: http.MethodPut,
+			path:   pigmentPath("/api/pigments/%d/raman-bands", ochre),
+			as:     asModerator,
+			body: body(types.ReplaceRamanBandsRequest{Bands: []types.RamanBand{
+				{Position: 385, Strength: "strong"},
+				{Position: 385, Strength: "weak"},
+			}}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "replace for archived pigment",
+			method: http.MethodPut,
+			path:   pigmentPath("/api/pigments/%d/raman-bands", leadWhite),
+			as:     asModerator,
+			body:   body(types.ReplaceRamanBandsRequest{Bands: []types.RamanBand{{Position: 1050, Strength: "strong"}}}),
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "replace as user",
+			method: http.MethodPut,
+			path:   pigmentPath("/api/pigments/%d/raman-bands", ochre),
+			as:     asCreator,
+			body:   body(types.ReplaceRamanBandsRequest{}),
+			status: http.StatusForbidden,
+		},
+	})
+}
+
+func TestRamanExplanation(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "explain bands",
+			method: http.MethodGet,
+			path:   analysisPath("/raman", draft),
+			as:     asCreator,
+			setup:  addTechniqueReadings("raman", ochreRaman),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response ramanResponse
+				testenv.Decode(t, rec, &response)
+				if response.Readings != 1 || len(response.Peaks) != 3 || response.Peaks[2].Position != 386 || response.Peaks[2].Relative != 1 {
+					t.Fatalf("unexpected peaks %+v", response.Peaks)
+				}
+				if len(response.Pigments) != 2 {
+					t.Fatalf("unexpected pigments %+v", response.Pigments)
+				}
+
+				ochre := response.Pigments[0]
+				if ochre.PigmentID != f.Ochre.ID || ochre.Source != "library" || ochre.Score != 0.778 || !ochre.InAnalysis || ochre.MissingStrong {
+					t.Fatalf("unexpected ochre %+v", ochre)
+				}
+				if len(ochre.Matched) != 3 || ochre.Matched[0].Band.Position != 247 || ochre.Matched[0].Shift != 1 ||
+					len(ochre.Missing) != 2 || ochre.Missing[0].Position != 480 || len(ochre.Unexplained) != 0 {
+					t.Fatalf("unexpected ochre bands %+v", ochre)
+				}
+
+				// У ультрамарина нет библиотеки: полосы берутся из эталонного спектра
+				ultramarine := response.Pigments[1]
+				if ultramarine.PigmentID != f.Ultramarine.ID || ultramarine.Source != "reference" || ultramarine.Score != 0 ||
+					!ultramarine.MissingStrong || len(ultramarine.Unexplained) != 3 {
+					t.Fatalf("unexpected ultramarine %+v", ultramarine)
+				}
+			},
+		},
+		{
+			name:   "single pigment",
+			method: http.MethodGet,
+			path: func(f *testenv.Fixtures) string {
+				return analysisPath("/raman", draft)(f) + "?pigment_id=" + pigmentPath("%d", ultramarine)(f)
+			},
+			as:     asCreator,
+			setup:  addTechniqueReadings("raman", ochreRaman),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response ramanResponse
+				testenv.Decode(t, rec, &response)
+				if len(response.Pigments) != 1 || response.Pigments[0].PigmentID != f.Ultramarine.ID {
+					t.Fatalf("unexpected pigments %+v", response.Pigments)
+				}
+			},
+		},
+		{
+			name:   "unknown pigment",
+			method: http.MethodGet,
+			path: func(f *testenv.Fixtures) string {
+				return analysisPath("/raman", draft)(f) + "?pigment_id=999"
+			},
+			as:     asCreator,
+			setup:  addTechniqueReadings("raman", ochreRaman),
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "invalid pigment id",
+			method: http.MethodGet,
+			path: func(f *testenv.Fixtures) string {
+				return analysisPath("/raman", draft)(f) + "?pigment_id=abc"
+			},
+			as:     asCreator,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "no raman readings",
+			method: http.MethodGet,
+			path:   analysisPath("/raman", draft),
+			as:     asCreator,
+			status: http.StatusBadRequest,
+		},
+	})
+}
+
+func TestCompleteWithVerdicts(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "verdicts required",
+			method: http.MethodPut,
+			path:   analysisPath("/complete", draft),
+			as:     asModerator,
+			setup:  formWithRaman,
+			body: verdicts(func(f *testenv.Fixtures) []types.PigmentVerdict {
+				return []types.PigmentVerdict{{PigmentID: f.Ochre.ID, Decision: "accept"}}
+			}),
+			status: http.StatusBadRequest,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response types.VerdictsRequiredResponse
+				testenv.Decode(t, rec, &response)
+				if len(response.Raman) != 2 || response.Message == "" {
+					t.Fatalf("explanations are missing: %+v", response)
+				}
+				expectStatus(draft, ds.StatusCreated)(t, env, f, rec)
+			},
+		},
+		{
+			name:   "accept and reject",
+			method: http.MethodPut,
+			path:   analysisPath("/complete", draft),
+			as:     asModerator,
+			setup:  formWithRaman,
+			body: verdicts(func(f *testenv.Fixtures) []types.PigmentVerdict {
+				return []types.PigmentVerdict{
+					{PigmentID: f.Ochre.ID, Decision: "accept", Note: " полосы гётита "},
+					{PigmentID: f.Ultramarine.ID, Decision: "reject", Note: "нет полосы 548"},
+				}
+			}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					TerminusPostQuem *int                     `json:"terminus_post_quem"`
+					Raman            []types.RamanExplanation `json:"raman"`
+				}
+				testenv.Decode(t, rec, &response)
+				if len(response.Raman) != 2 {
+					t.Fatalf("unexpected explanations %+v", response.Raman)
+				}
+				// Ультрамарин отклонён и не датирует заявку
+				if response.TerminusPostQuem != nil {
+					t.Fatalf("terminus post quem %v, want none", *response.TerminusPostQuem)
+				}
+
+				ctx := context.Background()
+				ochre, err := env.Analyses.GetAnalysisPigment(ctx, f.Draft.ID, f.Ochre.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ochre.Verdict != ds.VerdictAccepted || ochre.VerdictNote != "полосы гётита" || ochre.Percent == 0 ||
+					ochre.RamanScore == nil || *ochre.RamanScore != 0.778 {
+					t.Fatalf("unexpected ochre link %+v", ochre)
+				}
+				ultramarine, err := env.Analyses.GetAnalysisPigment(ctx, f.Draft.ID, f.Ultramarine.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ultramarine.Verdict != ds.VerdictRejected || ultramarine.Percent != 0 || ultramarine.RamanScore == nil {
+					t.Fatalf("unexpected ultramarine link %+v", ultramarine)
+				}
+			},
+		},
+		{
+			name:   "verdict without raman readings",
+			method: http.MethodPut,
+			path:   analysisPath("/complete", created),
+			as:     asModerator,
+			body: verdicts(func(f *testenv.Fixtures) []types.PigmentVerdict {
+				return []types.PigmentVerdict{{PigmentID: f.Ultramarine.ID, Decision: "accept", Note: "по спектру отражения"}}
+			}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				rec = env.Do(t, http.MethodGet, analysisPath("", created)(f), nil, testenv.WithToken(env.Token(t, f.Creator)))
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				pigments := response.Analysis.Pigments
+				if len(pigments) != 1 || pigments[0].Verdict != "accepted" ||
+					pigments[0].VerdictNote != "по спектру отражения" || pigments[0].RamanScore != nil {
+					t.Fatalf("unexpected pigments %+v", pigments)
+				}
+			},
+		},
+		{
+			name:   "verdict for pigment outside analysis",
+			method: http.MethodPut,
+			path:   analysisPath("/complete", created),
+			as:     asModerator,
+			body: verdicts(func(f *testenv.Fixtures) []types.PigmentVerdict {
+				return []types.PigmentVerdict{{PigmentID: f.Ochre.ID, Decision: "accept"}}
+			}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown decision",
+			method: http.MethodPut,
+			path:   analysisPath("/complete", created),
+			as:     asModerator,
+			body: verdicts(func(f *testenv.Fixtures) []types.PigmentVerdict {
+				return []types.PigmentVerdict{{PigmentID: f.Ultramarine.ID, Decision: "maybe"}}
+			}),
+			status: http.StatusBadRequest,
+		},
+	})
+}
@@ -0,0 +1,229 @@
+package api_test
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/spectra"
+	"colorLex/internal/app/testenv"
+)
+
+// repeatReadings - пять повторных измерений одной точки, последнее - сбой
+var repeatReadings = []string{
+	"400:0.30,450:0.50,500:0.60",
+	"400:0.32,450:0.52,500:0.61",
+	"400:0.31,450:0.49,500:0.59",
+	"400:0.29,450:0.51,500:0.60",
+	"400:0.80,450:0.10,500:0.90",
+}
+
+func addReadings(texts ...string) func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+	return addTechniqueReadings(spectra.TechniqueReflectance, texts...)
+}
+
+func addTechniqueReadings(technique string, texts ...string) func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+	return func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+		t.Helper()
+		for _, text := range texts {
+			reading := ds.SpectrumReading{Technique: technique, Spectrum: text}
+			if err := env.Analyses.AddReading(context.Background(), f.Draft, &reading); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func readingPath(pick func(f *testenv.Fixtures) *ds.SpectrumAnalysis) func(*testenv.Fixtures) string {
+	return func(f *testenv.Fixtures) string {
+		return analysisPath("/readings", pick)(f)
+	}
+}
+
+func TestSpectrumReadingRoutes(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "add reading",
+			method: http.MethodPost,
+			path:   readingPath(draft),
+			as:     asCreator,
+			body:   body(types.AddSpectrumReadingRequest{Spectrum: "400\t0.30\n450\t0.50\n500\t0.60", Note: "повтор 1"}),
+			status: http.StatusCreated,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					Reading types.SpectrumReading `json:"reading"`
+				}
+				testenv.Decode(t, rec, &response)
+				if response.Reading.Spectrum != "400:0.3,450:0.5,500:0.6" || response.Reading.Note != "повтор 1" {
+					t.Fatalf("unexpected reading %+v", response.Reading)
+				}
+			},
+		},
+		{
+			name:   "add unparsable reading",
+			method: http.MethodPost,
+			path:   readingPath(draft),
+			as:     asCreator,
+			body:   body(types.AddSpectrumReadingRequest{Spectrum: "255,54,17"}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "add reading outside range",
+			method: http.MethodPost,
+			path:   readingPath(draft),
+			as:     asCreator,
+			setup:  addReadings(repeatReadings[0]),
+			body:   body(types.AddSpectrumReadingRequest{Spectrum: "700:0.1,800:0.2"}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "add reading to foreign analysis",
+			method: http.MethodPost,
+			path:   readingPath(draft),
+			as:     asStranger,
+			body:   body(types.AddSpectrumReadingRequest{Spectrum: repeatReadings[0]}),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "add reading to formed analysis",
+			method: http.MethodPost,
+			path:   readingPath(created),
+			as:     asCreator,
+			body:   body(types.AddSpectrumReadingRequest{Spectrum: repeatReadings[0]}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "delete reading",
+			method: http.MethodDelete,
+			path: func(f *testenv.Fixtures) string {
+				return readingPath(draft)(f) + "/1"
+			},
+			as:     asCreator,
+			setup:  addReadings(repeatReadings[0]),
+			status: http.StatusOK,
+		},
+		{
+			name:   "delete missing reading",
+			method: http.MethodDelete,
+			path: func(f *testenv.Fixtures) string {
+				return readingPath(draft)(f) + "/999"
+			},
+			as:     asCreator,
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "aggregate rejects outlier",
+			method: http.MethodGet,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			setup:  addReadings(repeatReadings...),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				readings, aggregate := response.Analysis.Readings, response.Analysis.Aggregate
+				if len(readings) != 5 || !readings[4].Rejected || readings[0].Rejected {
+					t.Fatalf("unexpected readings %+v", readings)
+				}
+				if aggregate == nil || aggregate.Used != 4 || aggregate.Rule.Method != "mad" {
+					t.Fatalf("unexpected aggregate %+v", aggregate)
+				}
+				if math.Abs(aggregate.Mean[0]-0.305) > 1e-9 || aggregate.Std[0] == 0 {
+					t.Fatalf("unexpected mean %v std %v", aggregate.Mean, aggregate.Std)
+				}
+			},
+		},
+		{
+			name:   "aggregate without rejection",
+			method: http.MethodPut,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			setup:  addReadings(repeatReadings...),
+			body:   body(types.UpdateSpectrumAnalysisRequest{OutlierRule: &types.OutlierRule{Method: "none"}}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				rec = env.Do(t, http.MethodGet, analysisPath("", draft)(f), nil, testenv.WithToken(env.Token(t, f.Creator)))
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				if aggregate := response.Analysis.Aggregate; aggregate == nil || aggregate.Used != 5 {
+					t.Fatalf("unexpected aggregate %+v", aggregate)
+				}
+			},
+		},
+		{
+			name:   "single spectrum aggregate",
+			method: http.MethodGet,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				if aggregate := response.Analysis.Aggregate; aggregate == nil || aggregate.Used != 1 || len(aggregate.Wavelengths) != 3 {
+					t.Fatalf("unexpected aggregate %+v", aggregate)
+				}
+			},
+		},
+		{
+			name:   "unknown outlier method",
+			method: http.MethodPut,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			body:   body(types.UpdateSpectrumAnalysisRequest{OutlierRule: &types.OutlierRule{Method: "grubbs"}}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "rmsd without threshold",
+			method: http.MethodPut,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			body:   body(types.UpdateSpectrumAnalysisRequest{OutlierRule: &types.OutlierRule{Method: "rmsd"}}),
+			status: http.StatusBadRequest,
+		},
+	})
+}
+
+func TestCompleteUsesAveragedSpectrum(t *testing.T) {
+	env := testenv.New(t)
+	f := env.Seed(t)
+	ctx := context.Background()
+
+	f.Draft.Spectrum = nil
+	if err := env.Analyses.UpdateAnalysis(ctx, f.Draft); err != nil {
+		t.Fatal(err)
+	}
+	addReadings(repeatReadings...)(t, env, f)
+
+	rec := env.Do(t, http.MethodPut, analysisPath("/form", draft)(f), nil, testenv.WithToken(env.Token(t, f.Creator)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("form: status %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = env.Do(t, http.MethodPut, analysisPath("/complete", draft)(f), types.CompleteAnalysisRequest{Action: "complete"},
+		testenv.WithToken(env.Token(t, f.Moderator)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("complete: status %d: %s", rec.Code, rec.Body.String())
+	}
+	var response struct {
+		Accuracy float64 `json:"accuracy"`
+	}
+	testenv.Decode(t, rec, &response)
+	if response.Accuracy <= 90 || response.Accuracy >= 100 {
+		t.Fatalf("accuracy %v, want the spread of four consistent readings", response.Accuracy)
+	}
+
+	analysis, err := env.Analyses.GetAnalysis(ctx, f.Draft.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spectrum, err := spectra.Packed(analysis.Spectrum).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (0.30 + 0.32 + 0.31 + 0.29) / 4; math.Abs(spectrum[0].Value-want) > 1e-6 {
+		t.Fatalf("completed spectrum %q, want the mean of accepted readings", spectrum.String())
+	}
+}
@@ -144,3 +144,20 @@ func (c *Client) IsTokenBlacklisted(ctx context.Context, token string) (bool, er
 	}
 	return val == "true", nil
 }
+
+// SetReport сохраняет посчитанный отчёт
+func (c *Client) SetReport(ctx context.Context, name string, data []byte, expiration time.Duration) error {
+	return c.rdb.Set(ctx, fmt.Sprintf("report:%s", name), data, expiration).Err()
+}
+
+// GetReport получает отчёт; nil без ошибки, если отчёта нет
+func (c *Client) GetReport(ctx context.Context, name string) ([]byte, error) {
+	data, err := c.rdb.Get(ctx, fmt.Sprintf("report:%s", name)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report: %w", err)
+	}
+	return data, nil
+}
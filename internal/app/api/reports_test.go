@@ -0,0 +1,83 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/testenv"
+)
+
+// waitReport опрашивает отчёт о палитрах, пока в нём не окажется analyses заявок
+func waitReport(t *testing.T, env *testenv.Env, f *testenv.Fixtures, analyses int) types.PaletteReport {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		rec := env.Do(t, http.MethodGet, "/api/reports/palette", nil, testenv.WithToken(env.Token(t, f.Stranger)))
+		if rec.Code != http.StatusOK && rec.Code != http.StatusAccepted {
+			t.Fatalf("get report: status %d, body %s", rec.Code, rec.Body.String())
+		}
+		var response struct {
+			Report *types.PaletteReport `json:"report"`
+		}
+		testenv.Decode(t, rec, &response)
+		if response.Report != nil && response.Report.Analyses == analyses {
+			return *response.Report
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("report still has %+v", response.Report)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPaletteReportRoutes(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "refresh and read",
+			method: http.MethodPost,
+			path:   path("/api/reports/palette/refresh"),
+			as:     asModerator,
+			setup:  completeOthers,
+			status: http.StatusAccepted,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				report := waitReport(t, env, f, 3)
+				if len(report.Pigments) != 3 || report.PeriodYears != 100 {
+					t.Fatalf("unexpected report %+v", report)
+				}
+				// три разных палитры - три группы по одной заявке
+				if len(report.Clusters) != 3 || report.Clusters[0].Size != 1 || len(report.Clusters[0].Examples) != 1 {
+					t.Fatalf("unexpected clusters %+v", report.Clusters)
+				}
+
+				stats := report.Cooccurrence
+				if stats.Analyses != 3 || stats.Pigments[0].PigmentID != f.Ochre.ID || stats.Pigments[0].Analyses != 2 {
+					t.Fatalf("unexpected frequencies %+v", stats.Pigments)
+				}
+				if len(stats.Pairs) != 1 || stats.Pairs[0].Analyses != 1 || stats.Pairs[0].Lift != 1.5 {
+					t.Fatalf("unexpected pairs %+v", stats.Pairs)
+				}
+
+				// все заявки привязаны к портрету 1780 года
+				if len(report.Periods) != 1 || report.Periods[0].From != 1700 || report.Periods[0].Analyses != 3 || report.Undated != nil {
+					t.Fatalf("unexpected periods %+v, undated %+v", report.Periods, report.Undated)
+				}
+			},
+		},
+		{
+			name:   "refresh requires moderator",
+			method: http.MethodPost,
+			path:   path("/api/reports/palette/refresh"),
+			as:     asCreator,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "read requires token",
+			method: http.MethodGet,
+			path:   path("/api/reports/palette"),
+			status: http.StatusUnauthorized,
+		},
+	})
+}
@@ -3,12 +3,11 @@ package api
 import (
 	"colorLex/internal/app/api/handlers"
 	"colorLex/internal/app/api/middleware"
-	"colorLex/internal/app/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupAPIRouter(router *gin.Engine, repo *repository.Repository, authMW *middleware.AuthMiddleware, usersHandler *handlers.UsersHandler, pigmentHandler *handlers.PigmentHandler, spectrumAnalysisHandler *handlers.SpectrumAnalysisHandler, spectrumAnalysisPigmentHandler *handlers.SpectrumAnalysisPigmentsHandler) {
+func SetupAPIRouter(router *gin.Engine, authMW *middleware.AuthMiddleware, usersHandler *handlers.UsersHandler, pigmentHandler *handlers.PigmentHandler, spectrumAnalysisHandler *handlers.SpectrumAnalysisHandler, spectrumAnalysisPigmentHandler *handlers.SpectrumAnalysisPigmentsHandler, artworkHandler *handlers.ArtworkHandler, instrumentHandler *handlers.InstrumentHandler, cubeHandler *handlers.CubeHandler, reportHandler *handlers.ReportHandler) {
 	api := router.Group("/api")
 	{
         // Проксирование изображений MinIO через бэкенд
@@ -34,15 +33,59 @@ func SetupAPIRouter(router *gin.Engine, repo *repository.Repository, authMW *mid
 		// Пигменты (публичные для чтения, аутентификация для добавления)
 		pigments := api.Group("/pigments")
 		{
-			pigments.GET("", pigmentHandler.GetPigments)                          // Публичный
+			pigments.GET("", authMW.OptionalAuth(), pigmentHandler.GetPigments)   // Публичный (include_archived - для модератора)
+			pigments.GET("/vocabulary", pigmentHandler.GetPigmentVocabulary)      // Публичный
+			pigments.GET("/interactions", pigmentHandler.GetInteractions)         // Публичный
 			pigments.GET("/:id", pigmentHandler.GetPigment)                       // Публичный
+			pigments.GET("/:id/references", pigmentHandler.GetReferenceSpectra)   // Публичный
+			pigments.GET("/:id/raman-bands", pigmentHandler.GetRamanBands)        // Публичный
 			pigments.POST("/:id/add-to-sa", authMW.AuthRequired(), pigmentHandler.AddToSpectrumAnalysis) // Требует аутентификации
 
 			// Методы модератора
 			pigments.POST("", authMW.AuthRequired(), authMW.ModeratorRequired(), pigmentHandler.CreatePigment)
 			pigments.PUT("/:id", authMW.AuthRequired(), authMW.ModeratorRequired(), pigmentHandler.UpdatePigment)
 			pigments.DELETE("/:id", authMW.AuthRequired(), authMW.ModeratorRequired(), pigmentHandler.DeletePigment)
+			pigments.POST("/:id/restore", authMW.AuthRequired(), authMW.ModeratorRequired(), pigmentHandler.RestorePigment)
 			pigments.POST("/:id/image", authMW.AuthRequired(), authMW.ModeratorRequired(), pigmentHandler.UploadImage)
+			pigments.POST("/:id/references", authMW.AuthRequired(), authMW.ModeratorRequired(), pigmentHandler.AddReferenceSpectrum)
+			pigments.DELETE("/:id/references/:reference_id", authMW.AuthRequired(), authMW.ModeratorRequired(), pigmentHandler.DeleteReferenceSpectrum)
+			pigments.PUT("/:id/raman-bands", authMW.AuthRequired(), authMW.ModeratorRequired(), pigmentHandler.ReplaceRamanBands)
+			pigments.POST("/interactions", authMW.AuthRequired(), authMW.ModeratorRequired(), pigmentHandler.CreateInteraction)
+			pigments.PUT("/interactions/:id", authMW.AuthRequired(), authMW.ModeratorRequired(), pigmentHandler.UpdateInteraction)
+			pigments.DELETE("/interactions/:id", authMW.AuthRequired(), authMW.ModeratorRequired(), pigmentHandler.DeleteInteraction)
+		}
+
+		// Произведения (требуют аутентификации; изменять может автор записи или модератор)
+		artworks := api.Group("/artworks")
+		artworks.Use(authMW.AuthRequired())
+		{
+			artworks.GET("", artworkHandler.GetArtworks)
+			artworks.GET("/:id", artworkHandler.GetArtwork)
+			artworks.GET("/:id/points", artworkHandler.GetArtworkPoints) // карта точек отбора проб
+			artworks.POST("", artworkHandler.CreateArtwork)
+			artworks.PUT("/:id", artworkHandler.UpdateArtwork)
+			artworks.DELETE("/:id", artworkHandler.DeleteArtwork)
+			artworks.POST("/:id/image", artworkHandler.UploadImage)
+		}
+
+		// Приборы и их калибровки (требуют аутентификации, изменяет модератор)
+		instruments := api.Group("/instruments")
+		instruments.Use(authMW.AuthRequired())
+		{
+			instruments.GET("", instrumentHandler.GetInstruments)
+			instruments.GET("/:id", instrumentHandler.GetInstrument) // с историей калибровок
+			instruments.POST("", authMW.ModeratorRequired(), instrumentHandler.CreateInstrument)
+			instruments.PUT("/:id", authMW.ModeratorRequired(), instrumentHandler.UpdateInstrument)
+			instruments.POST("/:id/calibrations", authMW.ModeratorRequired(), instrumentHandler.AddCalibration)
+		}
+
+		// Спектры (требуют аутентификации)
+		spectraGroup := api.Group("/spectra")
+		spectraGroup.Use(authMW.AuthRequired())
+		{
+			spectraGroup.POST("/preview", handlers.PreviewSpectrum) // предобработка без сохранения
+			spectraGroup.POST("/mixture", pigmentHandler.PredictMixture) // прогноз спектра и цвета смеси
+			spectraGroup.POST("/metamerism", spectrumAnalysisHandler.CompareMetamerism) // ΔE под разными источниками
 		}
 
 		// Спектральный анализ (требует аутентификации)
@@ -55,11 +98,31 @@ func SetupAPIRouter(router *gin.Engine, repo *repository.Repository, authMW *mid
 			spectrum.PUT("/:id", spectrumAnalysisHandler.UpdateSpectrumAnalysis)
 			spectrum.PUT("/:id/form", spectrumAnalysisHandler.FormSpectrumAnalysis)
 			spectrum.DELETE("/:id", spectrumAnalysisHandler.DeleteAnalysis)
+			spectrum.PUT("/:id/point", spectrumAnalysisHandler.SetMeasurementPoint)
+			spectrum.DELETE("/:id/point", spectrumAnalysisHandler.DeleteMeasurementPoint)
+			spectrum.PUT("/:id/stratigraphy", spectrumAnalysisHandler.SetStratigraphy) // слои шлифа снизу вверх
+			spectrum.DELETE("/:id/stratigraphy", spectrumAnalysisHandler.DeleteStratigraphy)
+			spectrum.POST("/:id/readings", spectrumAnalysisHandler.AddReading)
+			spectrum.DELETE("/:id/readings/:reading_id", spectrumAnalysisHandler.DeleteReading)
+			spectrum.GET("/:id/identification", spectrumAnalysisHandler.GetIdentification) // рейтинг пигментов по всем методикам
+			spectrum.GET("/:id/raman", spectrumAnalysisHandler.GetRamanExplanation)        // найденные и пропущенные полосы
+			spectrum.GET("/:id/similar", spectrumAnalysisHandler.GetSimilarAnalyses)       // завершённые заявки с похожей палитрой
+			spectrum.POST("/:id/cubes", cubeHandler.UploadCube)                            // гиперспектральный куб ENVI, обрабатывается в фоне
+			spectrum.GET("/:id/cubes", cubeHandler.GetCubes)
+			spectrum.GET("/:id/cubes/:cube_id", cubeHandler.GetCube) // ход обработки и карты долей пигментов
 
 			// Методы модератора
 			spectrum.PUT("/:id/complete", authMW.ModeratorRequired(), spectrumAnalysisHandler.CompleteSpectrumAnalysis)
 		}
 
+		// Сводные отчёты по завершённым заявкам (требуют аутентификации)
+		reports := api.Group("/reports")
+		reports.Use(authMW.AuthRequired())
+		{
+			reports.GET("/palette", reportHandler.GetPaletteReport) // группы палитр и встречаемость пигментов
+			reports.POST("/palette/refresh", authMW.ModeratorRequired(), reportHandler.RefreshPaletteReport)
+		}
+
 		// Связи M2M (требуют аутентификации)
 		spectrumAnalysisPigments := api.Group("/spectrumAnalysis-pigments")
 		spectrumAnalysisPigments.Use(authMW.AuthRequired())
@@ -0,0 +1,117 @@
+package api_test
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/testenv"
+)
+
+// completeOthers завершает заявку Stranger с охрой и заявку Creator с одним ультрамарином
+func completeOthers(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+	t.Helper()
+	completeForArtwork(t, env, f, f.Foreign, map[uint]float64{f.Ochre.ID: 70})
+	completeForArtwork(t, env, f, f.Created, map[uint]float64{f.Ultramarine.ID: 100})
+}
+
+func TestSimilarAnalysesRoutes(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "cosine",
+			method: http.MethodGet,
+			path:   analysisPath("/similar", completed),
+			as:     asStranger,
+			setup:  completeOthers,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response types.SimilarityResponse
+				testenv.Decode(t, rec, &response)
+				if response.Metric != "cosine" || len(response.Composition) != 2 || response.Composition[0].PigmentID != f.Ochre.ID {
+					t.Fatalf("unexpected response %+v", response)
+				}
+				// заявка с одним ультрамарином не имеет общих пигментов и в выдачу не попадает
+				if response.Count != 1 || response.Matches[0].AnalysisID != f.Foreign.ID.String() {
+					t.Fatalf("unexpected matches %+v", response.Matches)
+				}
+				match := response.Matches[0]
+				want := 60 / math.Hypot(60, 25.5)
+				if math.Abs(match.Similarity-want) > 1e-3 {
+					t.Fatalf("similarity %v, want %v", match.Similarity, want)
+				}
+				if len(match.Shared) != 1 || match.Shared[0].Percent != 60 || match.Shared[0].MatchPercent != 70 {
+					t.Fatalf("unexpected shared %+v", match.Shared)
+				}
+				if len(match.OnlyHere) != 1 || match.OnlyHere[0].PigmentID != f.LeadWhite.ID || !match.OnlyHere[0].Archived {
+					t.Fatalf("unexpected only-here %+v", match.OnlyHere)
+				}
+			},
+		},
+		{
+			name:   "bray-curtis",
+			method: http.MethodGet,
+			path:   analysisPath("/similar?metric=braycurtis", completed),
+			as:     asCreator,
+			setup:  completeOthers,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response types.SimilarityResponse
+				testenv.Decode(t, rec, &response)
+				want := 2 * 60 / (60 + 25.5 + 70)
+				if response.Count != 1 || math.Abs(response.Matches[0].Similarity-want) > 1e-3 {
+					t.Fatalf("unexpected matches %+v, want similarity %v", response.Matches, want)
+				}
+			},
+		},
+		{
+			name:   "no candidates",
+			method: http.MethodGet,
+			path:   analysisPath("/similar", completed),
+			as:     asCreator,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response types.SimilarityResponse
+				testenv.Decode(t, rec, &response)
+				if response.Count != 0 || response.Matches == nil {
+					t.Fatalf("unexpected matches %+v", response.Matches)
+				}
+			},
+		},
+		{
+			name:   "analysis without percents",
+			method: http.MethodGet,
+			path:   analysisPath("/similar", draft),
+			as:     asCreator,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "foreign formed analysis",
+			method: http.MethodGet,
+			path:   analysisPath("/similar", created),
+			as:     asStranger,
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "unknown metric",
+			method: http.MethodGet,
+			path:   analysisPath("/similar?metric=euclid", completed),
+			as:     asCreator,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "limit too large",
+			method: http.MethodGet,
+			path:   analysisPath("/similar?limit=500", completed),
+			as:     asCreator,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "requires token",
+			method: http.MethodGet,
+			path:   analysisPath("/similar", completed),
+			status: http.StatusUnauthorized,
+		},
+	})
+}
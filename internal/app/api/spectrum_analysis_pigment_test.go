@@ -0,0 +1,153 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/testenv"
+)
+
+type linkRequest struct {
+	SpectrumAnalysisID string  `json:"spectrum_analysis_id"`
+	PigmentID          uint    `json:"pigment_id"`
+	Comment            string  `json:"comment,omitempty"`
+	Percent            float64 `json:"percent,omitempty"`
+}
+
+func TestSpectrumAnalysisPigmentRoutes(t *testing.T) {
+	const linksPath = "/api/spectrumAnalysis-pigments"
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "update link",
+			method: http.MethodPut,
+			path:   path(linksPath),
+			as:     asCreator,
+			body: func(f *testenv.Fixtures) any {
+				return linkRequest{SpectrumAnalysisID: f.Draft.ID.String(), PigmentID: f.Ochre.ID, Comment: "верхний слой", Percent: 40}
+			},
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				link, err := env.Analyses.GetAnalysisPigment(context.Background(), f.Draft.ID, f.Ochre.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if link.Comment != "верхний слой" || link.Percent != 40 {
+					t.Fatalf("unexpected link %+v", link)
+				}
+			},
+		},
+		{
+			name:   "update link percent over 100",
+			method: http.MethodPut,
+			path:   path(linksPath),
+			as:     asCreator,
+			body: func(f *testenv.Fixtures) any {
+				return linkRequest{SpectrumAnalysisID: f.Draft.ID.String(), PigmentID: f.Ochre.ID, Percent: 120}
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "update link without data",
+			method: http.MethodPut,
+			path:   path(linksPath),
+			as:     asCreator,
+			body: func(f *testenv.Fixtures) any {
+				return linkRequest{SpectrumAnalysisID: f.Draft.ID.String(), PigmentID: f.Ochre.ID}
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "update missing link",
+			method: http.MethodPut,
+			path:   path(linksPath),
+			as:     asCreator,
+			body: func(f *testenv.Fixtures) any {
+				return linkRequest{SpectrumAnalysisID: f.Draft.ID.String(), PigmentID: f.LeadWhite.ID, Comment: "нет"}
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "update foreign link",
+			method: http.MethodPut,
+			path:   path(linksPath),
+			as:     asCreator,
+			body: func(f *testenv.Fixtures) any {
+				return linkRequest{SpectrumAnalysisID: f.Foreign.ID.String(), PigmentID: f.Ochre.ID, Comment: "чужое"}
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "update link with stale etag",
+			method: http.MethodPut,
+			path:   path(linksPath),
+			as:     asCreator,
+			body: func(f *testenv.Fixtures) any {
+				return linkRequest{SpectrumAnalysisID: f.Draft.ID.String(), PigmentID: f.Ochre.ID, Comment: "устарело"}
+			},
+			header: func(*testing.T, *testenv.Env, *testenv.Fixtures) map[string]string {
+				return map[string]string{"If-Match": `"1"`}
+			},
+			status: http.StatusPreconditionFailed,
+		},
+		{
+			name:   "delete link",
+			method: http.MethodDelete,
+			path:   path(linksPath),
+			as:     asCreator,
+			body: func(f *testenv.Fixtures) any {
+				return linkRequest{SpectrumAnalysisID: f.Draft.ID.String(), PigmentID: f.Ultramarine.ID}
+			},
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				_, err := env.Analyses.GetAnalysisPigment(context.Background(), f.Draft.ID, f.Ultramarine.ID)
+				if !errors.Is(err, repository.ErrNotFound) {
+					t.Fatalf("link still exists: %v", err)
+				}
+			},
+		},
+		{
+			name:   "delete link from formed analysis",
+			method: http.MethodDelete,
+			path:   path(linksPath),
+			as:     asCreator,
+			body: func(f *testenv.Fixtures) any {
+				return linkRequest{SpectrumAnalysisID: f.Created.ID.String(), PigmentID: f.Ultramarine.ID}
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "delete missing link",
+			method: http.MethodDelete,
+			path:   path(linksPath),
+			as:     asCreator,
+			body: func(f *testenv.Fixtures) any {
+				return linkRequest{SpectrumAnalysisID: f.Draft.ID.String(), PigmentID: f.LeadWhite.ID}
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "delete link of unknown analysis",
+			method: http.MethodDelete,
+			path:   path(linksPath),
+			as:     asCreator,
+			body: func(f *testenv.Fixtures) any {
+				return linkRequest{SpectrumAnalysisID: "not-a-uuid", PigmentID: f.Ochre.ID}
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "links require token",
+			method: http.MethodDelete,
+			path:   path(linksPath),
+			body: func(f *testenv.Fixtures) any {
+				return linkRequest{SpectrumAnalysisID: f.Draft.ID.String(), PigmentID: f.Ochre.ID}
+			},
+			status: http.StatusUnauthorized,
+		},
+	})
+}
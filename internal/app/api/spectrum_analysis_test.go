@@ -0,0 +1,527 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/testenv"
+)
+
+type analysisResponse struct {
+	Analysis types.SpectrumAnalysisResponse `json:"analysis"`
+}
+
+type analysesResponse struct {
+	Analyses []types.SpectrumAnalysisResponse `json:"analyses"`
+	Count    int                              `json:"count"`
+}
+
+func analysisPath(suffix string, pick func(f *testenv.Fixtures) *ds.SpectrumAnalysis) func(*testenv.Fixtures) string {
+	return func(f *testenv.Fixtures) string {
+		return "/api/spectrum-analysis/" + pick(f).ID.String() + suffix
+	}
+}
+
+func draft(f *testenv.Fixtures) *ds.SpectrumAnalysis     { return f.Draft }
+func created(f *testenv.Fixtures) *ds.SpectrumAnalysis   { return f.Created }
+func completed(f *testenv.Fixtures) *ds.SpectrumAnalysis { return f.Completed }
+func foreign(f *testenv.Fixtures) *ds.SpectrumAnalysis   { return f.Foreign }
+
+// expectStatus перечитывает заявку из хранилища и сверяет статус
+func expectStatus(pick func(f *testenv.Fixtures) *ds.SpectrumAnalysis, want string) func(*testing.T, *testenv.Env, *testenv.Fixtures, *httptest.ResponseRecorder) {
+	return func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+		analysis, err := env.Analyses.GetAnalysis(context.Background(), pick(f).ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if analysis.Status != want {
+			t.Fatalf("status %q, want %q", analysis.Status, want)
+		}
+	}
+}
+
+func expectAnalysisCount(want int) func(*testing.T, *testenv.Env, *testenv.Fixtures, *httptest.ResponseRecorder) {
+	return func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+		var response analysesResponse
+		testenv.Decode(t, rec, &response)
+		if response.Count != want {
+			t.Fatalf("got %d analyses, want %d: %+v", response.Count, want, response.Analyses)
+		}
+	}
+}
+
+func TestSpectrumAnalysisReadRoutes(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "cart",
+			method: http.MethodGet,
+			path:   path("/api/spectrum-analysis/cart"),
+			as:     asCreator,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					AnalysisID    string `json:"analysis_id"`
+					ItemsCount    int    `json:"items_count"`
+					HasActiveCart bool   `json:"has_active_cart"`
+				}
+				testenv.Decode(t, rec, &response)
+				if !response.HasActiveCart || response.ItemsCount != 2 || response.AnalysisID != f.Draft.ID.String() {
+					t.Fatalf("unexpected cart %+v", response)
+				}
+			},
+		},
+		{
+			name:   "empty cart",
+			method: http.MethodGet,
+			path:   path("/api/spectrum-analysis/cart"),
+			as:     asModerator,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response struct {
+					HasActiveCart bool `json:"has_active_cart"`
+				}
+				testenv.Decode(t, rec, &response)
+				if response.HasActiveCart {
+					t.Fatal("moderator has no draft")
+				}
+			},
+		},
+		{
+			name:   "cart requires token",
+			method: http.MethodGet,
+			path:   path("/api/spectrum-analysis/cart"),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "list own",
+			method: http.MethodGet,
+			path:   path("/api/spectrum-analysis"),
+			as:     asCreator,
+			status: http.StatusOK,
+			check:  expectAnalysisCount(2),
+		},
+		{
+			name:   "list all for moderator",
+			method: http.MethodGet,
+			path:   path("/api/spectrum-analysis"),
+			as:     asModerator,
+			status: http.StatusOK,
+			check:  expectAnalysisCount(3),
+		},
+		{
+			name:   "list by status",
+			method: http.MethodGet,
+			path:   path("/api/spectrum-analysis?status=completed"),
+			as:     asModerator,
+			status: http.StatusOK,
+			check:  expectAnalysisCount(1),
+		},
+		{
+			name:   "get",
+			method: http.MethodGet,
+			path:   analysisPath("", completed),
+			as:     asCreator,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				if response.Analysis.Status != ds.StatusCompleted || len(response.Analysis.Pigments) != 2 {
+					t.Fatalf("unexpected analysis %+v", response.Analysis)
+				}
+				for _, pigment := range response.Analysis.Pigments {
+					if pigment.Archived != (pigment.PigmentID == f.LeadWhite.ID) {
+						t.Fatalf("archived flag of %s is %v", pigment.Name, pigment.Archived)
+					}
+					if pigment.Percent == 0 {
+						t.Fatalf("percent of %s is not stored", pigment.Name)
+					}
+				}
+				if rec.Header().Get("ETag") == "" {
+					t.Fatal("ETag header is missing")
+				}
+			},
+		},
+		{
+			name:   "get bad id",
+			method: http.MethodGet,
+			path:   path("/api/spectrum-analysis/not-a-uuid"),
+			as:     asCreator,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "get missing",
+			method: http.MethodGet,
+			path:   path("/api/spectrum-analysis/00000000-0000-0000-0000-000000000000"),
+			as:     asCreator,
+			status: http.StatusNotFound,
+		},
+	})
+}
+
+func TestSpectrumAnalysisWriteRoutes(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "update draft",
+			method: http.MethodPut,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			body:   body(types.UpdateSpectrumAnalysisRequest{Name: "Фрагмент иконы"}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				if response.Analysis.Name != "Фрагмент иконы" || response.Analysis.Spectrum != "400:0.15,500:0.25,600:0.45" {
+					t.Fatalf("unexpected analysis %+v", response.Analysis)
+				}
+			},
+		},
+		{
+			name:   "update without data",
+			method: http.MethodPut,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			body:   body(types.UpdateSpectrumAnalysisRequest{}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "update claimed year flags anachronism",
+			method: http.MethodPut,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			body:   body(types.UpdateSpectrumAnalysisRequest{ClaimedYear: intPtr(450)}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				rec = env.Do(t, http.MethodGet, analysisPath("", draft)(f), nil, testenv.WithToken(env.Token(t, f.Creator)))
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				if response.Analysis.ClaimedYear == nil || *response.Analysis.ClaimedYear != 450 {
+					t.Fatalf("claimed year is missing: %+v", response.Analysis)
+				}
+				anachronisms := response.Analysis.Anachronisms
+				if len(anachronisms) != 1 || anachronisms[0].PigmentID != f.Ultramarine.ID || anachronisms[0].Reason != "not_yet_available" {
+					t.Fatalf("unexpected anachronisms %+v", anachronisms)
+				}
+			},
+		},
+		{
+			name:   "artwork year flags anachronism",
+			method: http.MethodGet,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			setup: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+				ctx := context.Background()
+				f.Artwork.Year = intPtr(450)
+				if err := env.Artworks.UpdateArtwork(ctx, &f.Artwork); err != nil {
+					t.Fatal(err)
+				}
+				f.Draft.ArtworkID = &f.Artwork.ID
+				if err := env.Analyses.UpdateAnalysis(ctx, f.Draft); err != nil {
+					t.Fatal(err)
+				}
+			},
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				// заявленного года нет - сверяемся с годом произведения
+				anachronisms := response.Analysis.Anachronisms
+				if len(anachronisms) != 1 || anachronisms[0].PigmentID != f.Ultramarine.ID {
+					t.Fatalf("unexpected anachronisms %+v", anachronisms)
+				}
+			},
+		},
+		{
+			name:   "rejected pigment is not an anachronism",
+			method: http.MethodGet,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			setup: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+				ctx := context.Background()
+				prussian := ds.Pigment{Name: "Берлинская лазурь", Color: "blue", AvailableFrom: intPtr(1704)}
+				if err := env.Pigments.CreatePigment(ctx, &prussian); err != nil {
+					t.Fatal(err)
+				}
+				analysis, _, err := env.Analyses.AddPigmentToDraft(ctx, f.Creator.ID, prussian.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				analysis.ClaimedYear = intPtr(1650)
+				analysis.Status = ds.StatusCompleted
+				if err := env.Analyses.CompleteAnalysis(ctx, analysis, map[uint]repository.PigmentResult{
+					f.Ultramarine.ID: {Percent: 60},
+					f.Ochre.ID:       {Percent: 40},
+					prussian.ID:      {Verdict: ds.VerdictRejected},
+				}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				if anachronisms := response.Analysis.Anachronisms; len(anachronisms) != 0 {
+					t.Fatalf("unexpected anachronisms %+v", anachronisms)
+				}
+			},
+		},
+		{
+			name:   "update links artwork",
+			method: http.MethodPut,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			body: func(f *testenv.Fixtures) any {
+				return types.UpdateSpectrumAnalysisRequest{ArtworkID: &f.Artwork.ID}
+			},
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				if response.Analysis.ArtworkID == nil || *response.Analysis.ArtworkID != f.Artwork.ID {
+					t.Fatalf("artwork is not linked: %+v", response.Analysis)
+				}
+			},
+		},
+		{
+			name:   "update links missing artwork",
+			method: http.MethodPut,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			body:   body(map[string]any{"artwork_id": 999}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "update claimed year in future",
+			method: http.MethodPut,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			body:   body(types.UpdateSpectrumAnalysisRequest{ClaimedYear: intPtr(3000)}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "update formed",
+			method: http.MethodPut,
+			path:   analysisPath("", created),
+			as:     asCreator,
+			body:   body(types.UpdateSpectrumAnalysisRequest{Name: "Другое имя"}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "update foreign",
+			method: http.MethodPut,
+			path:   analysisPath("", foreign),
+			as:     asCreator,
+			body:   body(types.UpdateSpectrumAnalysisRequest{Name: "Чужая"}),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "form",
+			method: http.MethodPut,
+			path:   analysisPath("/form", draft),
+			as:     asCreator,
+			status: http.StatusOK,
+			check:  expectStatus(draft, ds.StatusCreated),
+		},
+		{
+			name:   "form by moderator",
+			method: http.MethodPut,
+			path:   analysisPath("/form", draft),
+			as:     asModerator,
+			status: http.StatusOK,
+			check:  expectStatus(draft, ds.StatusCreated),
+		},
+		{
+			name:   "form foreign",
+			method: http.MethodPut,
+			path:   analysisPath("/form", draft),
+			as:     asStranger,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "form twice",
+			method: http.MethodPut,
+			path:   analysisPath("/form", created),
+			as:     asCreator,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "delete draft",
+			method: http.MethodDelete,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				expectStatus(draft, ds.StatusDeleted)(t, env, f, rec)
+				rec = env.Do(t, http.MethodGet, "/api/spectrum-analysis/"+f.Draft.ID.String(), nil,
+					testenv.WithToken(env.Token(t, f.Creator)))
+				if rec.Code != http.StatusNotFound {
+					t.Fatalf("deleted analysis: status %d", rec.Code)
+				}
+			},
+		},
+		{
+			name:   "delete formed",
+			method: http.MethodDelete,
+			path:   analysisPath("", created),
+			as:     asCreator,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "complete",
+			method: http.MethodPut,
+			path:   analysisPath("/complete", created),
+			as:     asModerator,
+			body:   body(types.CompleteAnalysisRequest{Action: "complete"}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				expectStatus(created, ds.StatusCompleted)(t, env, f, rec)
+				link, err := env.Analyses.GetAnalysisPigment(context.Background(), f.Created.ID, f.Ultramarine.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if link.Percent == 0 {
+					t.Fatal("percent was not calculated")
+				}
+				analysis, err := env.Analyses.GetAnalysis(context.Background(), f.Created.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if analysis.TerminusPostQuem == nil || *analysis.TerminusPostQuem != 600 {
+					t.Fatalf("terminus post quem %v, want 600", analysis.TerminusPostQuem)
+				}
+			},
+		},
+		{
+			name:   "reject",
+			method: http.MethodPut,
+			path:   analysisPath("/complete", created),
+			as:     asModerator,
+			body:   body(types.CompleteAnalysisRequest{Action: "reject"}),
+			status: http.StatusOK,
+			check:  expectStatus(created, ds.StatusRejected),
+		},
+		{
+			name:   "complete unknown action",
+			method: http.MethodPut,
+			path:   analysisPath("/complete", created),
+			as:     asModerator,
+			body:   body(types.CompleteAnalysisRequest{Action: "approve"}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "complete draft",
+			method: http.MethodPut,
+			path:   analysisPath("/complete", draft),
+			as:     asModerator,
+			body:   body(types.CompleteAnalysisRequest{Action: "complete"}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "complete requires moderator",
+			method: http.MethodPut,
+			path:   analysisPath("/complete", created),
+			as:     asCreator,
+			body:   body(types.CompleteAnalysisRequest{Action: "complete"}),
+			status: http.StatusForbidden,
+		},
+	})
+}
+
+func TestSpectrumAnalysisIfMatch(t *testing.T) {
+	etag := func(pick func(f *testenv.Fixtures) *ds.SpectrumAnalysis) func(*testing.T, *testenv.Env, *testenv.Fixtures) map[string]string {
+		return func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) map[string]string {
+			rec := env.Do(t, http.MethodGet, analysisPath("", pick)(f), nil, testenv.WithToken(env.Token(t, f.Moderator)))
+			return map[string]string{"If-Match": rec.Header().Get("ETag")}
+		}
+	}
+	stale := func(*testing.T, *testenv.Env, *testenv.Fixtures) map[string]string {
+		return map[string]string{"If-Match": `"1"`}
+	}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "update with current etag",
+			method: http.MethodPut,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			body:   body(types.UpdateSpectrumAnalysisRequest{Name: "Новое имя"}),
+			header: etag(draft),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				if got := rec.Header().Get("ETag"); got == "" || got == fmt.Sprintf(`"%d"`, f.Draft.Version) {
+					t.Fatalf("ETag %q did not change", got)
+				}
+			},
+		},
+		{
+			name:   "update with stale etag",
+			method: http.MethodPut,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			body:   body(types.UpdateSpectrumAnalysisRequest{Name: "Новое имя"}),
+			header: stale,
+			status: http.StatusPreconditionFailed,
+		},
+		{
+			name:   "update with wildcard",
+			method: http.MethodPut,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			body:   body(types.UpdateSpectrumAnalysisRequest{Name: "Новое имя"}),
+			header: func(*testing.T, *testenv.Env, *testenv.Fixtures) map[string]string {
+				return map[string]string{"If-Match": "*"}
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "form with stale etag",
+			method: http.MethodPut,
+			path:   analysisPath("/form", draft),
+			as:     asCreator,
+			header: stale,
+			status: http.StatusPreconditionFailed,
+		},
+		{
+			name:   "complete with current etag",
+			method: http.MethodPut,
+			path:   analysisPath("/complete", created),
+			as:     asModerator,
+			body:   body(types.CompleteAnalysisRequest{Action: "reject"}),
+			header: etag(created),
+			status: http.StatusOK,
+		},
+		{
+			name:   "delete with stale etag",
+			method: http.MethodDelete,
+			path:   analysisPath("", draft),
+			as:     asCreator,
+			header: stale,
+			status: http.StatusPreconditionFailed,
+			check:  expectStatus(draft, ds.StatusDraft),
+		},
+	})
+
+	t.Run("store rejects stale version", func(t *testing.T) {
+		env := testenv.New(t)
+		f := env.Seed(t)
+
+		first, _ := env.Analyses.GetAnalysis(context.Background(), f.Draft.ID)
+		second, _ := env.Analyses.GetAnalysis(context.Background(), f.Draft.ID)
+
+		first.Name = "Первая вкладка"
+		if err := env.Analyses.UpdateAnalysis(context.Background(), first); err != nil {
+			t.Fatal(err)
+		}
+		second.Name = "Вторая вкладка"
+		err := env.Analyses.UpdateAnalysis(context.Background(), second)
+		if !errors.Is(err, repository.ErrVersionConflict) {
+			t.Fatalf("stale update: %v, want ErrVersionConflict", err)
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/testenv"
+)
+
+// stratigraphyBody - грунт, красочный слой с двумя пигментами и лак
+func stratigraphyBody(f *testenv.Fixtures) any {
+	return map[string]any{"layers": []any{
+		map[string]any{"kind": "ground", "name": "меловой грунт", "binder": "животный клей", "thickness": 120},
+		map[string]any{"kind": "paint", "thickness": 35.5, "binder": "масло", "pigments": []any{
+			map[string]any{"pigment_id": f.Ochre.ID, "percent": 30},
+			map[string]any{"pigment_id": f.Ultramarine.ID, "percent": 70},
+		}},
+		map[string]any{"kind": "varnish"},
+	}}
+}
+
+// paintLayer - запрос с одним красочным слоем
+func paintLayer(pigments ...map[string]any) func(*testenv.Fixtures) any {
+	return func(*testenv.Fixtures) any {
+		return map[string]any{"layers": []any{map[string]any{"kind": "paint", "pigments": pigments}}}
+	}
+}
+
+func TestStratigraphyRoutes(t *testing.T) {
+	setLayers := func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) {
+		t.Helper()
+		layers := []repository.AnalysisLayer{{Layer: ds.AnalysisLayer{Kind: ds.LayerGround}}}
+		if err := env.Analyses.ReplaceLayers(context.Background(), f.Draft, layers); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runRouteCases(t, []routeCase{
+		{
+			name:   "set stratigraphy",
+			method: http.MethodPut,
+			path:   analysisPath("/stratigraphy", draft),
+			as:     asCreator,
+			setup:  setLayers,
+			body:   stratigraphyBody,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				if rec.Header().Get("ETag") == "" {
+					t.Fatal("no ETag after write")
+				}
+				rec = env.Do(t, http.MethodGet, analysisPath("", draft)(f), nil, testenv.WithToken(env.Token(t, f.Creator)))
+				var response analysisResponse
+				testenv.Decode(t, rec, &response)
+				layers := response.Analysis.Stratigraphy
+				if len(layers) != 3 || layers[0].Kind != "ground" || layers[0].Position != 1 || layers[2].Kind != "varnish" {
+					t.Fatalf("unexpected layers %+v", layers)
+				}
+				paint := layers[1]
+				if paint.Thickness == nil || *paint.Thickness != 35.5 || paint.Binder != "масло" || len(paint.Pigments) != 2 {
+					t.Fatalf("unexpected paint layer %+v", paint)
+				}
+				// пигменты слоя - по убыванию доли
+				if paint.Pigments[0].PigmentID != f.Ultramarine.ID || paint.Pigments[0].Percent != 70 || paint.Pigments[0].Name == "" {
+					t.Fatalf("unexpected pigments %+v", paint.Pigments)
+				}
+			},
+		},
+		{
+			name:   "percents do not sum to 100",
+			method: http.MethodPut,
+			path:   analysisPath("/stratigraphy", draft),
+			as:     asCreator,
+			body: func(f *testenv.Fixtures) any {
+				return paintLayer(
+					map[string]any{"pigment_id": f.Ochre.ID, "percent": 30},
+					map[string]any{"pigment_id": f.Ultramarine.ID, "percent": 60},
+				)(f)
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "percents within tolerance",
+			method: http.MethodPut,
+			path:   analysisPath("/stratigraphy", draft),
+			as:     asCreator,
+			body: func(f *testenv.Fixtures) any {
+				return paintLayer(
+					map[string]any{"pigment_id": f.Ochre.ID, "percent": 33.3},
+					map[string]any{"pigment_id": f.Ultramarine.ID, "percent": 66.6},
+				)(f)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "duplicate pigment in layer",
+			method: http.MethodPut,
+			path:   analysisPath("/stratigraphy", draft),
+			as:     asCreator,
+			body: func(f *testenv.Fixtures) any {
+				return paintLayer(
+					map[string]any{"pigment_id": f.Ochre.ID, "percent": 50},
+					map[string]any{"pigment_id": f.Ochre.ID, "percent": 50},
+				)(f)
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown layer kind",
+			method: http.MethodPut,
+			path:   analysisPath("/stratigraphy", draft),
+			as:     asCreator,
+			body:   body(map[string]any{"layers": []any{map[string]any{"kind": "crust"}}}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "non-positive thickness",
+			method: http.MethodPut,
+			path:   analysisPath("/stratigraphy", draft),
+			as:     asCreator,
+			body:   body(map[string]any{"layers": []any{map[string]any{"kind": "ground", "thickness": 0}}}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "no layers",
+			method: http.MethodPut,
+			path:   analysisPath("/stratigraphy", draft),
+			as:     asCreator,
+			body:   body(map[string]any{"layers": []any{}}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown pigment",
+			method: http.MethodPut,
+			path:   analysisPath("/stratigraphy", draft),
+			as:     asCreator,
+			body:   paintLayer(map[string]any{"pigment_id": 9999, "percent": 100}),
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "archived pigment",
+			method: http.MethodPut,
+			path:   analysisPath("/stratigraphy", draft),
+			as:     asCreator,
+			body: func(f *testenv.Fixtures) any {
+				return paintLayer(map[string]any{"pigment_id": f.LeadWhite.ID, "percent": 100})(f)
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "formed analysis",
+			method: http.MethodPut,
+			path:   analysisPath("/stratigraphy", created),
+			as:     asCreator,
+			body:   stratigraphyBody,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "foreign analysis",
+			method: http.MethodPut,
+			path:   analysisPath("/stratigraphy", draft),
+			as:     asStranger,
+			body:   stratigraphyBody,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "stale version",
+			method: http.MethodPut,
+			path:   analysisPath("/stratigraphy", draft),
+			as:     asCreator,
+			setup:  setLayers,
+			header: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) map[string]string {
+				return map[string]string{"If-Match": `"1"`}
+			},
+			body:   stratigraphyBody,
+			status: http.StatusPreconditionFailed,
+		},
+		{
+			name:   "delete stratigraphy",
+			method: http.MethodDelete,
+			path:   analysisPath("/stratigraphy", draft),
+			as:     asCreator,
+			setup:  setLayers,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				layers, err := env.Analyses.ListLayers(context.Background(), f.Draft.ID)
+				if err != nil || len(layers) != 0 {
+					t.Fatalf("layers left: %+v, %v", layers, err)
+				}
+			},
+		},
+		{
+			name:   "delete missing stratigraphy",
+			method: http.MethodDelete,
+			path:   analysisPath("/stratigraphy", draft),
+			as:     asCreator,
+			status: http.StatusNotFound,
+		},
+	})
+}
+
+// Архивный пигмент остаётся в стратиграфии завершённой заявки
+func TestStratigraphyArchivedPigment(t *testing.T) {
+	env := testenv.New(t)
+	f := env.Seed(t)
+
+	layers := []repository.AnalysisLayer{{
+		Layer:    ds.AnalysisLayer{Kind: ds.LayerGround},
+		Pigments: []repository.LayerPigment{{Link: ds.LayerPigment{PigmentID: f.LeadWhite.ID, Percent: 100}}},
+	}}
+	if err := env.Analyses.ReplaceLayers(context.Background(), f.Completed, layers); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := env.Do(t, http.MethodGet, analysisPath("", completed)(f), nil, testenv.WithToken(env.Token(t, f.Creator)))
+	var response analysisResponse
+	testenv.Decode(t, rec, &response)
+	stratigraphy := response.Analysis.Stratigraphy
+	if len(stratigraphy) != 1 || len(stratigraphy[0].Pigments) != 1 || !stratigraphy[0].Pigments[0].Archived {
+		t.Fatalf("unexpected stratigraphy %+v", stratigraphy)
+	}
+}
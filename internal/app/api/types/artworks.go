@@ -0,0 +1,82 @@
+package types
+
+import "time"
+
+// Запрос на создание произведения
+type CreateArtworkRequest struct {
+    Title           string `json:"title" binding:"required"`
+    Artist          string `json:"artist,omitempty"`
+    Date            string `json:"date,omitempty"` // "ок. 1650", "XVI в."
+    Year            *int   `json:"year,omitempty"`
+    InventoryNumber string `json:"inventory_number,omitempty"`
+    Collection      string `json:"collection,omitempty"`
+}
+
+// Запрос на обновление произведения
+type UpdateArtworkRequest struct {
+    Title           string `json:"title,omitempty"`
+    Artist          string `json:"artist,omitempty"`
+    Date            string `json:"date,omitempty"`
+    Year            *int   `json:"year,omitempty"`
+    InventoryNumber string `json:"inventory_number,omitempty"`
+    Collection      string `json:"collection,omitempty"`
+}
+
+// Ответ с произведением
+type ArtworkResponse struct {
+    ID              uint      `json:"id"`
+    Title           string    `json:"title"`
+    Artist          string    `json:"artist,omitempty"`
+    Date            string    `json:"date,omitempty"`
+    Year            *int      `json:"year,omitempty"`
+    InventoryNumber string    `json:"inventory_number,omitempty"`
+    Collection      string    `json:"collection,omitempty"`
+    ImageKey        string    `json:"image_key,omitempty"`
+    CreatorID       uint      `json:"creator_id"`
+    CreatedAt       time.Time `json:"created_at"`
+    UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Детали произведения со сводкой по завершённым анализам
+type ArtworkDetailResponse struct {
+    ArtworkResponse
+    CompletedAnalyses int64                 `json:"completed_analyses"`
+    Pigments          []ArtworkPigmentStats `json:"pigments"`
+}
+
+// Пигмент, найденный в анализах произведения
+type ArtworkPigmentStats struct {
+    PigmentID   uint    `json:"pigment_id"`
+    Name        string  `json:"name"`
+    CIName      string  `json:"ci_name,omitempty"`
+    Archived    bool    `json:"archived,omitempty"`
+    Analyses    int     `json:"analyses"` // в скольких анализах найден
+    MinPercent  float64 `json:"min_percent"`
+    MaxPercent  float64 `json:"max_percent"`
+    MeanPercent float64 `json:"mean_percent"`
+}
+
+// Точка отбора пробы на карте произведения
+type ArtworkPointResponse struct {
+    AnalysisID   string `json:"analysis_id"`
+    AnalysisName string `json:"analysis_name"`
+    Status       string `json:"status"`
+    MeasurementPoint
+    DominantPigment *DominantPigment `json:"dominant_pigment,omitempty"` // nil, пока проценты не рассчитаны
+}
+
+// Пигмент с наибольшей долей в пробе
+type DominantPigment struct {
+    PigmentID uint    `json:"pigment_id"`
+    Name      string  `json:"name"`
+    Color     string  `json:"color,omitempty"`
+    Percent   float64 `json:"percent"`
+}
+
+// Фильтры для списка произведений
+type ArtworkFilter struct {
+    Search     string `form:"search"` // название или автор
+    Collection string `form:"collection"`
+    Limit      int    `form:"limit,default=20"`
+    Offset     int    `form:"offset,default=0"`
+}
@@ -0,0 +1,29 @@
+package types
+
+import "time"
+
+// Карта доли пигмента по пикселям куба
+type AbundanceMap struct {
+	PigmentID   uint    `json:"pigment_id"`
+	PigmentName string  `json:"pigment_name"`
+	ImageKey    string  `json:"image_key"`
+	ImageURL    string  `json:"image_url"`    // через прокси /api/images
+	MeanPercent float64 `json:"mean_percent"` // средняя доля по пикселям с данными
+	MaxPercent  float64 `json:"max_percent"`
+}
+
+// Гиперспектральный куб заявки и ход его обработки
+type HyperspectralCube struct {
+	ID         uint           `json:"id"`
+	Name       string         `json:"name"`
+	Samples    int            `json:"samples"`
+	Lines      int            `json:"lines"`
+	Bands      int            `json:"bands"`
+	Status     string         `json:"status"`   // queued, running, done, failed
+	Progress   float64        `json:"progress"` // проценты
+	Error      string         `json:"error,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	StartedAt  *time.Time     `json:"started_at,omitempty"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+	Maps       []AbundanceMap `json:"maps,omitempty"`
+}
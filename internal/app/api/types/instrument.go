@@ -0,0 +1,65 @@
+package types
+
+import "time"
+
+// Запрос на регистрацию прибора
+type CreateInstrumentRequest struct {
+    Name       string  `json:"name" binding:"required"`
+    Serial     string  `json:"serial" binding:"required"`
+    Technique  string  `json:"technique" binding:"required"` // reflectance, raman, xrf
+    RangeFrom  float64 `json:"range_from"`                   // рабочий диапазон в единицах оси методики
+    RangeTo    float64 `json:"range_to"`
+    Resolution float64 `json:"resolution,omitempty"`
+    Note       string  `json:"note,omitempty"`
+}
+
+// Запрос на обновление прибора: меняются только переданные поля, методика не меняется
+type UpdateInstrumentRequest struct {
+    Name       string   `json:"name,omitempty"`
+    Serial     string   `json:"serial,omitempty"`
+    RangeFrom  *float64 `json:"range_from,omitempty"`
+    RangeTo    *float64 `json:"range_to,omitempty"`
+    Resolution *float64 `json:"resolution,omitempty"`
+    Note       *string  `json:"note,omitempty"`
+}
+
+// Прибор с калибровками
+type InstrumentResponse struct {
+    ID           uint                  `json:"id"`
+    Name         string                `json:"name"`
+    Serial       string                `json:"serial"`
+    Technique    string                `json:"technique"`
+    Unit         string                `json:"unit"` // единицы range_from, range_to и resolution
+    RangeFrom    float64               `json:"range_from"`
+    RangeTo      float64               `json:"range_to"`
+    Resolution   float64               `json:"resolution,omitempty"`
+    Note         string                `json:"note,omitempty"`
+    CreatedAt    time.Time             `json:"created_at"`
+    UpdatedAt    time.Time             `json:"updated_at"`
+    Calibrations []CalibrationResponse `json:"calibrations,omitempty"`
+}
+
+// Запрос на добавление калибровки. Спектры - на номинальной оси прибора в сырых отсчётах
+type AddCalibrationRequest struct {
+    ValidFrom        time.Time  `json:"valid_from" binding:"required"`
+    ValidTo          *time.Time `json:"valid_to,omitempty"` // не задано - бессрочно
+    Dark             string     `json:"dark,omitempty"`
+    White            string     `json:"white,omitempty"`             // обязателен для спектров отражения
+    WhiteReflectance float64    `json:"white_reflectance,omitempty"` // коэффициент отражения белого эталона, по умолчанию 1
+    Wavelengths      string     `json:"wavelengths,omitempty"`       // "номинальное:истинное" положение эталонных линий
+    Note             string     `json:"note,omitempty"`
+}
+
+// Калибровка прибора
+type CalibrationResponse struct {
+    ID               uint       `json:"id"`
+    InstrumentID     uint       `json:"instrument_id"`
+    ValidFrom        time.Time  `json:"valid_from"`
+    ValidTo          *time.Time `json:"valid_to,omitempty"`
+    Dark             string     `json:"dark,omitempty"`
+    White            string     `json:"white,omitempty"`
+    WhiteReflectance float64    `json:"white_reflectance,omitempty"`
+    Wavelengths      string     `json:"wavelengths,omitempty"`
+    Note             string     `json:"note,omitempty"`
+    CreatedAt        time.Time  `json:"created_at"`
+}
@@ -0,0 +1,36 @@
+package types
+
+// Образец для сравнения цвета: задаётся ровно одним способом
+type MetamerismSample struct {
+	Spectrum   string             `json:"spectrum,omitempty"`    // спектр отражения
+	AnalysisID string             `json:"analysis_id,omitempty"` // усреднённый спектр отражения заявки
+	Mixture    []MixtureComponent `json:"mixture,omitempty"`     // прогноз смеси пигментов
+}
+
+// Запрос на сравнение двух образцов под разными источниками света
+type MetamerismRequest struct {
+	First     MetamerismSample `json:"first"`
+	Second    MetamerismSample `json:"second"`
+	Reference string           `json:"reference,omitempty"` // источник, под которым образцы подбирались; по умолчанию D65
+}
+
+// Сравнение образцов под одним источником
+type IlluminantDifference struct {
+	Illuminant  string     `json:"illuminant"`
+	Description string     `json:"description"`
+	FirstLab    [3]float64 `json:"first_lab"`
+	SecondLab   [3]float64 `json:"second_lab"`
+	DeltaE      float64    `json:"delta_e"` // CIEDE2000
+	// Индекс метамерии: ΔE00 с поправкой на расхождение под опорным источником
+	MetamerismIndex float64 `json:"metamerism_index"`
+}
+
+// Результат сравнения образцов
+type MetamerismResponse struct {
+	Reference       string                 `json:"reference"`
+	Illuminants     []IlluminantDifference `json:"illuminants"`      // опорный источник первым
+	MetamerismIndex float64                `json:"metamerism_index"` // среднее по остальным источникам
+	Worst           string                 `json:"worst_illuminant,omitempty"`
+	FirstColor      SpectrumColor          `json:"first_color"`
+	SecondColor     SpectrumColor          `json:"second_color"`
+}
@@ -0,0 +1,21 @@
+package types
+
+// Пигмент смеси и его доля
+type MixtureComponent struct {
+	PigmentID uint    `json:"pigment_id" binding:"required"`
+	Percent   float64 `json:"percent" binding:"required"` // доли нормируются, сумма может быть любой
+}
+
+// Запрос на прогноз спектра смеси
+type MixtureRequest struct {
+	Components []MixtureComponent `json:"components" binding:"required"`
+	Measured   string             `json:"measured,omitempty"` // измеренный спектр для сравнения с прогнозом
+}
+
+// Цвет спектра отражения под источником D65
+type SpectrumColor struct {
+	Hex        string     `json:"hex"` // sRGB
+	XYZ        [3]float64 `json:"xyz"`
+	Lab        [3]float64 `json:"lab"`
+	OutOfGamut bool       `json:"out_of_gamut,omitempty"` // цвет вне sRGB, hex приближённый
+}
@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 // Запрос на создание пигмента
 type CreatePigmentRequest struct {
     Name        string `json:"name" binding:"required"`
@@ -7,6 +9,7 @@ type CreatePigmentRequest struct {
     Description string `json:"description,omitempty"`
     Color       string `json:"color,omitempty"`
     Specs       string `json:"specs,omitempty"`
+    PigmentMetadataRequest
 }
 
 // Запрос на обновление пигмента
@@ -16,6 +19,40 @@ type UpdatePigmentRequest struct {
     Description string `json:"description,omitempty"`
     Color       string `json:"color,omitempty"`
     Specs       string `json:"specs,omitempty"`
+    PigmentMetadataRequest
+}
+
+// Структурированные технические характеристики пигмента
+type PigmentMetadata struct {
+    CIName          string   `json:"ci_name,omitempty"`       // PB29
+    CINumber        string   `json:"ci_number,omitempty"`     // 77007
+    Formula         string   `json:"formula,omitempty"`
+    CASNumber       string   `json:"cas_number,omitempty"`    // 57455-37-5
+    Class           string   `json:"class,omitempty"`         // earth, mineral, lake, synthetic_organic
+    Lightfastness   *int     `json:"lightfastness,omitempty"` // ASTM 1-5
+    Toxicity        string   `json:"toxicity,omitempty"`      // none, low, moderate, high
+    ParticleSizeMin *float64 `json:"particle_size_min_um,omitempty"`
+    ParticleSizeMax *float64 `json:"particle_size_max_um,omitempty"`
+    ParticleShape   string   `json:"particle_shape,omitempty"`
+    AvailableFrom   *int     `json:"available_from,omitempty"` // год появления
+    AvailableTo     *int     `json:"available_to,omitempty"`   // год выхода из употребления
+}
+
+// Структурированные характеристики в запросе: меняются только переданные поля.
+// Пустая строка очищает текстовое поле, 0 - числовое
+type PigmentMetadataRequest struct {
+    CIName          *string  `json:"ci_name,omitempty"`
+    CINumber        *string  `json:"ci_number,omitempty"`
+    Formula         *string  `json:"formula,omitempty"`
+    CASNumber       *string  `json:"cas_number,omitempty"`
+    Class           *string  `json:"class,omitempty"`
+    Lightfastness   *int     `json:"lightfastness,omitempty"`
+    Toxicity        *string  `json:"toxicity,omitempty"`
+    ParticleSizeMin *float64 `json:"particle_size_min_um,omitempty"`
+    ParticleSizeMax *float64 `json:"particle_size_max_um,omitempty"`
+    ParticleShape   *string  `json:"particle_shape,omitempty"`
+    AvailableFrom   *int     `json:"available_from,omitempty"` // года 0 нет, он очищает поле
+    AvailableTo     *int     `json:"available_to,omitempty"`
 }
 
 // Ответ с пигментом
@@ -28,6 +65,9 @@ type PigmentResponse struct {
     Specs       string `json:"specs,omitempty"`
     ImageKey    string `json:"image_key,omitempty"`
     CreatedAt   string `json:"created_at,omitempty"`
+    UpdatedAt   string `json:"updated_at,omitempty"`
+    ArchivedAt  *time.Time `json:"archived_at,omitempty"` // не nil - пигмент в архиве
+    PigmentMetadata
 }
 
 // Фильтры для списка пигментов
@@ -36,6 +76,88 @@ type PigmentFilter struct {
     Color  string `form:"color"`
     DateFrom string `form:"date_from"`
     DateTo   string `form:"date_to"`
+    IncludeArchived bool `form:"include_archived"` // только для модераторов
+    CIName    string `form:"ci_name"`
+    CASNumber string `form:"cas_number"`
+    Formula   string `form:"formula"`
+    Class     string `form:"class"`
+    Toxicity  string `form:"toxicity"`          // точное значение уровня
+    MaxLightfastness int `form:"lightfastness_max"` // не хуже указанной категории ASTM
+    AvailableIn *int `form:"available_in"` // пигменты, доступные в указанном году
+
     Limit  int    `form:"limit,default=20"`
     Offset int    `form:"offset,default=0"`
 }
+
+// Допустимые значения структурированных полей
+type PigmentVocabularyResponse struct {
+    Classes       []string `json:"classes"`
+    Toxicity      []string `json:"toxicity"`
+    Lightfastness []int    `json:"lightfastness"`
+}
+
+// Эталонный спектр пигмента
+type ReferenceSpectrumResponse struct {
+    ID        uint      `json:"id"`
+    PigmentID uint      `json:"pigment_id"`
+    Technique string    `json:"technique"` // reflectance, raman, xrf
+    Unit      string    `json:"unit"`      // nm, cm-1, keV
+    Spectrum  string    `json:"spectrum"`
+    Source    string    `json:"source,omitempty"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+// Запрос на добавление эталонного спектра
+type AddReferenceSpectrumRequest struct {
+    Technique string `json:"technique" binding:"required"`
+    Spectrum  string `json:"spectrum" binding:"required"`
+    Source    string `json:"source,omitempty"`
+}
+
+// Характеристическая рамановская полоса пигмента
+type RamanBand struct {
+    Position   float64 `json:"position"`            // см⁻¹
+    Tolerance  float64 `json:"tolerance,omitempty"` // допуск положения, см⁻¹; 0 - по умолчанию
+    Strength   string  `json:"strength"`            // strong, medium, weak
+    Assignment string  `json:"assignment,omitempty"`
+}
+
+// Запрос на замену библиотеки полос пигмента; пустой список очищает библиотеку
+type ReplaceRamanBandsRequest struct {
+    Bands []RamanBand `json:"bands"`
+}
+
+// Запрос на добавление правила взаимодействия. Без other_pigment_id правило
+// описывает деградацию одного пигмента
+type CreatePigmentInteractionRequest struct {
+    PigmentID      uint     `json:"pigment_id" binding:"required"`
+    OtherPigmentID *uint    `json:"other_pigment_id,omitempty"`
+    Severity       string   `json:"severity" binding:"required"` // info, warning, danger
+    Explanation    string   `json:"explanation" binding:"required"`
+    Products       []string `json:"products,omitempty"` // продукты деградации, которые стоит поискать
+}
+
+// Запрос на обновление правила: меняются только переданные поля, пигменты не меняются
+type UpdatePigmentInteractionRequest struct {
+    Severity    string    `json:"severity,omitempty"`
+    Explanation string    `json:"explanation,omitempty"`
+    Products    *[]string `json:"products,omitempty"` // пустой список очищает продукты
+}
+
+// Правило взаимодействия пигментов
+type PigmentInteractionResponse struct {
+    ID          uint                 `json:"id"`
+    Pigments    []InteractionPigment `json:"pigments"` // один пигмент - правило деградации
+    Severity    string               `json:"severity"`
+    Explanation string               `json:"explanation"`
+    Products    []string             `json:"products,omitempty"`
+    CreatedAt   time.Time            `json:"created_at"`
+    UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+// Пигмент правила взаимодействия
+type InteractionPigment struct {
+    ID       uint   `json:"id"`
+    Name     string `json:"name"`
+    Archived bool   `json:"archived,omitempty"`
+}
@@ -0,0 +1,70 @@
+package types
+
+import "time"
+
+// Отчёт о палитрах завершённых заявок. Пигменты в группах и парах задаются ID,
+// подписи - в pigments
+type PaletteReport struct {
+	ComputedAt   time.Time            `json:"computed_at"`
+	Analyses     int                  `json:"analyses"`
+	PeriodYears  int                  `json:"period_years"`
+	Pigments     []ReportPigment      `json:"pigments"`
+	Clusters     []PaletteCluster     `json:"clusters"`
+	Cooccurrence PigmentCooccurrence  `json:"cooccurrence"`
+	Periods      []PeriodCooccurrence `json:"periods"`
+	Undated      *PigmentCooccurrence `json:"undated,omitempty"` // произведения без года и заявки без произведения
+}
+
+// Подпись пигмента в отчёте
+type ReportPigment struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	Color    string `json:"color,omitempty"`
+	Archived bool   `json:"archived,omitempty"`
+}
+
+// Группа похожих палитр
+type PaletteCluster struct {
+	Size     int            `json:"size"`
+	Centroid []ClusterShare `json:"centroid"` // средний состав, по убыванию доли
+	Spread   float64        `json:"spread"`   // среднее расстояние до центра, 0..√2
+	YearFrom *int           `json:"year_from,omitempty"`
+	YearTo   *int           `json:"year_to,omitempty"`
+	Examples []string       `json:"examples"` // ID заявок группы
+}
+
+// Доля пигмента в центре группы
+type ClusterShare struct {
+	PigmentID uint    `json:"pigment_id"`
+	Percent   float64 `json:"percent"`
+}
+
+// Совместная встречаемость пигментов
+type PigmentCooccurrence struct {
+	Analyses int                `json:"analyses"`
+	Pigments []PigmentFrequency `json:"pigments"`
+	Pairs    []PigmentPair      `json:"pairs"` // самые частые пары
+}
+
+// Встречаемость пигмента
+type PigmentFrequency struct {
+	PigmentID uint    `json:"pigment_id"`
+	Analyses  int     `json:"analyses"`
+	Share     float64 `json:"share"` // доля заявок
+}
+
+// Пара пигментов в одной заявке
+type PigmentPair struct {
+	First    uint    `json:"first"`
+	Second   uint    `json:"second"`
+	Analyses int     `json:"analyses"`
+	Jaccard  float64 `json:"jaccard"`
+	Lift     float64 `json:"lift"` // > 1 - пару выбирают вместе чаще случайного
+}
+
+// Встречаемость по произведениям с годом в [from, to)
+type PeriodCooccurrence struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+	PigmentCooccurrence
+}
@@ -0,0 +1,49 @@
+package types
+
+import "time"
+
+// Параметры поиска заявок с похожим пигментным составом
+type SimilarityFilter struct {
+	Metric string `form:"metric"` // cosine (по умолчанию) или braycurtis
+	Limit  int    `form:"limit,default=10"`
+}
+
+// Доля пигмента в составе
+type CompositionPigment struct {
+	PigmentID uint    `json:"pigment_id"`
+	Name      string  `json:"name"`
+	Color     string  `json:"color,omitempty"`
+	Percent   float64 `json:"percent"`
+	Archived  bool    `json:"archived,omitempty"`
+}
+
+// Пигмент, найденный в обеих заявках
+type SharedPigment struct {
+	PigmentID    uint    `json:"pigment_id"`
+	Name         string  `json:"name"`
+	Color        string  `json:"color,omitempty"`
+	Percent      float64 `json:"percent"`       // доля в исходной заявке
+	MatchPercent float64 `json:"match_percent"` // доля в похожей заявке
+	Archived     bool    `json:"archived,omitempty"`
+}
+
+// Заявка с похожим составом
+type SimilarAnalysis struct {
+	AnalysisID  string               `json:"analysis_id"`
+	Name        string               `json:"name"`
+	ArtworkID   *uint                `json:"artwork_id,omitempty"`
+	CompletedAt *time.Time           `json:"completed_at,omitempty"`
+	Similarity  float64              `json:"similarity"` // от 0 до 1
+	Shared      []SharedPigment      `json:"shared"`
+	OnlyHere    []CompositionPigment `json:"only_in_analysis,omitempty"` // есть только в исходной заявке
+	OnlyThere   []CompositionPigment `json:"only_in_match,omitempty"`    // есть только в похожей
+}
+
+// Результат поиска похожих составов
+type SimilarityResponse struct {
+	AnalysisID  string               `json:"analysis_id"`
+	Metric      string               `json:"metric"`
+	Composition []CompositionPigment `json:"composition"` // состав исходной заявки
+	Matches     []SimilarAnalysis    `json:"matches"`
+	Count       int                  `json:"count"`
+}
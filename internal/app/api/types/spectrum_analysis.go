@@ -4,11 +4,14 @@ import "time"
 
 // Фильтры для списка заявок
 type SpectrumAnalysisFilter struct {
-	Status   string    `form:"status"`
-	DateFrom time.Time `form:"date_from"`
-	DateTo   time.Time `form:"date_to"`
-	Limit    int       `form:"limit,default=20"`
-	Offset   int       `form:"offset,default=0"`
+	Status    string    `form:"status"`
+	ArtworkID *uint     `form:"artwork_id"`
+	DateFrom  time.Time `form:"date_from"`
+	DateTo    time.Time `form:"date_to"`
+	Limit     int       `form:"limit,default=20"`
+	Offset    int       `form:"offset,default=0"`
+
+	SpectrumFormat string `form:"spectrum_format"` // expanded (по умолчанию), compact или both
 }
 
 // Ответ с заявкой
@@ -16,12 +19,254 @@ type SpectrumAnalysisResponse struct {
 	ID          string              `json:"id"`
 	Name        string              `json:"name"`
 	Status      string              `json:"status"`
-	Spectrum    string              `json:"spectrum,omitempty"`
+	Spectrum    string              `json:"spectrum,omitempty"` // текстовая форма "400:0.12,410:0.15"
 	CreatedAt   time.Time           `json:"created_at"`
 	FormedAt    *time.Time          `json:"formed_at,omitempty"`
 	CompletedAt *time.Time          `json:"completed_at,omitempty"`
 	CreatorID   uint                `json:"creator_id"`
 	Pigments    []PigmentInAnalysis `json:"pigments,omitempty"`
+	ArtworkID   *uint               `json:"artwork_id,omitempty"`
+	Point       *MeasurementPoint   `json:"point,omitempty"`
+	Readings    []SpectrumReading   `json:"readings,omitempty"`
+	Aggregate   *SpectrumAggregate  `json:"aggregate,omitempty"` // усреднённый спектр отражения, nil - спектра нет
+
+	SpectrumPacked *PackedSpectrum `json:"spectrum_packed,omitempty"` // спектр в формате хранения
+	// SpectrumUnparsed - прежний текстовый спектр не удалось разобрать: спектра нет, его нужно загрузить заново
+	SpectrumUnparsed bool `json:"spectrum_unparsed,omitempty"`
+
+	TechniqueAggregates []SpectrumAggregate `json:"technique_aggregates,omitempty"` // сводки рамановских и РФА-измерений
+
+	Stratigraphy []AnalysisLayer `json:"stratigraphy,omitempty"` // слои поперечного шлифа снизу вверх
+
+	Preprocessing   []PreprocessingStep `json:"preprocessing,omitempty"`
+	Processed       *ProcessedSpectrum  `json:"processed,omitempty"`        // усреднённый спектр после предобработки
+	ProcessingError string              `json:"processing_error,omitempty"` // почему предобработку не удалось применить
+
+	ClaimedYear      *int               `json:"claimed_year,omitempty"`
+	TerminusPostQuem *TerminusPostQuem  `json:"terminus_post_quem,omitempty"`
+	Anachronisms     []AnachronismEntry `json:"anachronisms,omitempty"` // только при заданном claimed_year
+
+	Warnings            []InteractionWarning `json:"warnings,omitempty"`             // рискованные сочетания пигментов
+	DegradationProducts []string             `json:"degradation_products,omitempty"` // что стоит поискать в пробе
+}
+
+// Предупреждение о сработавшем правиле взаимодействия пигментов
+type InteractionWarning struct {
+	InteractionID uint                 `json:"interaction_id"`
+	Severity      string               `json:"severity"` // info, warning, danger
+	Pigments      []InteractionPigment `json:"pigments"`
+	Explanation   string               `json:"explanation"`
+	Products      []string             `json:"products,omitempty"`
+}
+
+// Спектр заявки в формате хранения spectra.Packed: заголовок и данные целиком
+type PackedSpectrum struct {
+	Count    int     `json:"count"`
+	Start    float64 `json:"start"`
+	Step     float64 `json:"step"`     // 0 - одна точка или неравномерная сетка
+	Regular  bool    `json:"regular"`  // false - длины волн лежат в data перед значениями
+	Checksum uint32  `json:"checksum"` // CRC-32 распакованных данных
+	Data     string  `json:"data"`     // base64: заголовок и сжатые DEFLATE значения float32
+}
+
+// Нижняя граница датировки и пигмент, который её задаёт
+type TerminusPostQuem struct {
+	Year        int    `json:"year"`
+	PigmentID   uint   `json:"pigment_id,omitempty"`
+	PigmentName string `json:"pigment_name,omitempty"`
+}
+
+// Повторное измерение спектра
+type SpectrumReading struct {
+	ID        uint      `json:"id"`
+	Technique string    `json:"technique"` // reflectance, raman, xrf
+	Spectrum  string    `json:"spectrum"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Deviation float64   `json:"deviation"` // среднеквадратичное отклонение от медианного спектра
+	Rejected  bool      `json:"rejected"`  // отбраковано правилом outlier_rule
+
+	InstrumentID  *uint      `json:"instrument_id,omitempty"`
+	CalibrationID *uint      `json:"calibration_id,omitempty"`
+	MeasuredAt    *time.Time `json:"measured_at,omitempty"`
+	Raw           string     `json:"raw,omitempty"` // исходные отсчёты прибора
+	// Uncalibrated - у прибора не было калибровки на момент измерения: спектр не исправлен
+	// и не участвует в расчётах, заявку с таким измерением нельзя завершить
+	Uncalibrated bool `json:"uncalibrated,omitempty"`
+}
+
+// Усреднённый по измерениям одной методики спектр; массивы параллельны wavelengths
+type SpectrumAggregate struct {
+	Technique   string      `json:"technique"`
+	Unit        string      `json:"unit"`        // единицы wavelengths: nm, cm-1, keV
+	Wavelengths []float64   `json:"wavelengths"` // ось спектра в единицах методики
+	Mean        []float64   `json:"mean"`
+	Std         []float64   `json:"std"`
+	Readings    int         `json:"readings"`
+	Used        int         `json:"used"`
+	Rule        OutlierRule `json:"outlier_rule"`
+}
+
+// Правило отбраковки повторных измерений
+type OutlierRule struct {
+	Method    string   `json:"method"`              // none, mad, rmsd
+	Threshold *float64 `json:"threshold,omitempty"` // mad - робастный z (по умолчанию 3.5), rmsd - в единицах спектра
+}
+
+// Шаг предобработки спектра. Используются только параметры своей операции
+type PreprocessingStep struct {
+	Op        string   `json:"op"`                   // resample, smooth, baseline, normalize, derivative
+	From      *float64 `json:"from,omitempty"`       // resample: начало сетки, по умолчанию - начало спектра
+	To        *float64 `json:"to,omitempty"`         // resample: конец сетки, по умолчанию - конец спектра
+	Interval  float64  `json:"interval,omitempty"`   // resample: шаг сетки
+	Window    int      `json:"window,omitempty"`     // smooth: нечётная ширина окна Савицкого–Голея в точках
+	PolyOrder int      `json:"poly_order,omitempty"` // smooth: степень полинома
+	Method    string   `json:"method,omitempty"`     // baseline: linear, hull, rubberband; normalize: max, area, snv
+	Order     int      `json:"order,omitempty"`      // derivative: 1 или 2
+}
+
+// Спектр после предобработки; массивы параллельны
+type ProcessedSpectrum struct {
+	Wavelengths []float64 `json:"wavelengths"`
+	Values      []float64 `json:"values"`
+}
+
+// Запрос на предпросмотр предобработки
+type SpectrumPreviewRequest struct {
+	Spectrum string              `json:"spectrum" binding:"required"`
+	Steps    []PreprocessingStep `json:"steps"`
+}
+
+// Запрос на добавление повторного измерения
+type AddSpectrumReadingRequest struct {
+	Technique string `json:"technique,omitempty"` // по умолчанию reflectance или методика прибора
+	Spectrum  string `json:"spectrum" binding:"required"`
+	Note      string `json:"note,omitempty"`
+	// С прибором spectrum - сырые отсчёты, они исправляются калибровкой на момент measured_at
+	InstrumentID *uint      `json:"instrument_id,omitempty"`
+	MeasuredAt   *time.Time `json:"measured_at,omitempty"` // по умолчанию - время загрузки
+}
+
+// Оценка пигмента одной методикой
+type IdentificationCandidate struct {
+	PigmentID uint     `json:"pigment_id"`
+	Name      string   `json:"name"`
+	Score     float64  `json:"score"`             // 0..1
+	Matched   []string `json:"matched,omitempty"` // найденные полосы (см⁻¹) или элементы
+	Missing   []string `json:"missing,omitempty"`
+}
+
+// Результат идентификации по измерениям одной методики
+type TechniqueIdentification struct {
+	Technique  string                    `json:"technique"`
+	Unit       string                    `json:"unit"`
+	Readings   int                       `json:"readings"`           // сколько измерений вошло в усреднённый спектр
+	Peaks      []float64                 `json:"peaks,omitempty"`    // raman: найденные полосы
+	Elements   []string                  `json:"elements,omitempty"` // xrf: найденные элементы
+	Candidates []IdentificationCandidate `json:"candidates"`
+}
+
+// Пик рамановского спектра образца после вычитания фона
+type RamanPeak struct {
+	Position  float64 `json:"position"`  // см⁻¹
+	Intensity float64 `json:"intensity"` // высота над фоном
+	Relative  float64 `json:"relative"`  // доля самого высокого пика
+	Width     float64 `json:"width"`     // полная ширина на половине высоты, см⁻¹
+}
+
+// Полоса пигмента и пик образца, который ей соответствует
+type RamanBandMatch struct {
+	Band  RamanBand `json:"band"`
+	Peak  RamanPeak `json:"peak"`
+	Shift float64   `json:"shift"` // положение пика минус положение полосы
+}
+
+// Объяснение рамановской оценки пигмента
+type RamanExplanation struct {
+	PigmentID     uint             `json:"pigment_id"`
+	Name          string           `json:"name"`
+	Source        string           `json:"source"` // library - библиотека полос, reference - пики эталонного спектра
+	Score         float64          `json:"score"`  // доля найденных полос с весами по силе
+	InAnalysis    bool             `json:"in_analysis"`
+	MissingStrong bool             `json:"missing_strong"` // не найдена хотя бы одна сильная полоса
+	Matched       []RamanBandMatch `json:"matched"`
+	Missing       []RamanBand      `json:"missing"`
+	Unexplained   []RamanPeak      `json:"unexplained"` // заметные пики, которые полосы пигмента не объясняют
+}
+
+// Пигмент в общем рейтинге по всем методикам
+type RankedPigment struct {
+	PigmentID  uint               `json:"pigment_id"`
+	Name       string             `json:"name"`
+	Score      float64            `json:"score"`  // среднее по методикам, оценившим пигмент
+	Scores     map[string]float64 `json:"scores"` // оценка каждой методики
+	InAnalysis bool               `json:"in_analysis"`
+}
+
+// Точка отбора пробы на фотографии произведения
+type MeasurementPoint struct {
+	X     float64 `json:"x"` // 0..1 слева направо
+	Y     float64 `json:"y"` // 0..1 сверху вниз
+	Label string  `json:"label,omitempty"`
+	Layer string  `json:"layer,omitempty"` // слой или глубина пробы
+}
+
+// Запрос на установку точки отбора пробы
+type SetMeasurementPointRequest struct {
+	X     *float64 `json:"x" binding:"required"`
+	Y     *float64 `json:"y" binding:"required"`
+	Label string   `json:"label,omitempty"`
+	Layer string   `json:"layer,omitempty"`
+}
+
+// Слой поперечного шлифа пробы
+type AnalysisLayer struct {
+	Position  int            `json:"position"` // 1 - нижний слой
+	Kind      string         `json:"kind"`     // ground, underpaint, paint, glaze, varnish, other
+	Name      string         `json:"name,omitempty"`
+	Thickness *float64       `json:"thickness,omitempty"` // мкм
+	Binder    string         `json:"binder,omitempty"`
+	Note      string         `json:"note,omitempty"`
+	Pigments  []LayerPigment `json:"pigments,omitempty"`
+}
+
+// Пигмент слоя
+type LayerPigment struct {
+	PigmentID uint    `json:"pigment_id"`
+	Name      string  `json:"name"`
+	Color     string  `json:"color,omitempty"`
+	Percent   float64 `json:"percent"`
+	Archived  bool    `json:"archived,omitempty"`
+}
+
+// Запрос на замену стратиграфии; слои перечисляются снизу вверх
+type SetStratigraphyRequest struct {
+	Layers []LayerRequest `json:"layers" binding:"required"`
+}
+
+// Слой в запросе на замену стратиграфии
+type LayerRequest struct {
+	Kind      string                `json:"kind" binding:"required"`
+	Name      string                `json:"name,omitempty"`
+	Thickness *float64              `json:"thickness,omitempty"` // мкм
+	Binder    string                `json:"binder,omitempty"`
+	Note      string                `json:"note,omitempty"`
+	Pigments  []LayerPigmentRequest `json:"pigments,omitempty"` // доли в сумме дают 100%
+}
+
+// Пигмент слоя в запросе
+type LayerPigmentRequest struct {
+	PigmentID uint    `json:"pigment_id" binding:"required"`
+	Percent   float64 `json:"percent"`
+}
+
+// Пигмент, противоречащий заявленной дате создания
+type AnachronismEntry struct {
+	PigmentID     uint   `json:"pigment_id"`
+	Name          string `json:"name"`
+	Reason        string `json:"reason"` // not_yet_available, discontinued
+	AvailableFrom *int   `json:"available_from,omitempty"`
+	AvailableTo   *int   `json:"available_to,omitempty"`
 }
 
 // Пигмент в заявке
@@ -32,17 +277,64 @@ type PigmentInAnalysis struct {
 	ImageKey  string  `json:"image_key"`
 	Comment   string  `json:"comment"`
 	Percent   float64 `json:"percent"`
+	Archived  bool    `json:"archived,omitempty"` // пигмент перенесён в архив после добавления
+
+	// 95% доверительный интервал доли; нет, если долю не удалось оценить по спектру
+	PercentInterval *PercentInterval `json:"percent_interval,omitempty"`
+	// Пигменты заявки, неотличимые от этого по спектру: их доли разделены условно
+	Indistinguishable []uint `json:"indistinguishable,omitempty"`
+
+	// Решение модератора по рамановской идентификации: accepted или rejected
+	Verdict     string   `json:"verdict,omitempty"`
+	VerdictNote string   `json:"verdict_note,omitempty"`
+	RamanScore  *float64 `json:"raman_score,omitempty"`
+
+	AvailableFrom *int `json:"available_from,omitempty"`
+	AvailableTo   *int `json:"available_to,omitempty"`
+}
+
+// Доверительный интервал доли пигмента, %
+type PercentInterval struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
 }
 
 // Запрос на обновление заявки
 type UpdateSpectrumAnalysisRequest struct {
-	Name     string `json:"name,omitempty"`
-	Spectrum string `json:"spectrum,omitempty"`
+	Name        string       `json:"name,omitempty"`
+	Spectrum    string       `json:"spectrum,omitempty"`
+	ClaimedYear *int         `json:"claimed_year,omitempty"`
+	ArtworkID   *uint        `json:"artwork_id,omitempty"` // 0 - отвязать от произведения
+	OutlierRule *OutlierRule `json:"outlier_rule,omitempty"`
+
+	Preprocessing *[]PreprocessingStep `json:"preprocessing,omitempty"` // [] - отключить предобработку
 }
 
 // Запрос на завершение/отклонение заявки
 type CompleteAnalysisRequest struct {
     Action string `json:"action" binding:"required"` // "complete" или "reject"
+    // Решения по пигментам заявки; при рамановских измерениях обязательны для всех
+    // пигментов, которые удалось сравнить с библиотекой полос
+    Verdicts []PigmentVerdict `json:"verdicts,omitempty"`
+}
+
+// Решение модератора по идентификации пигмента
+type PigmentVerdict struct {
+    PigmentID uint   `json:"pigment_id"`
+    Decision  string `json:"decision"` // accept или reject
+    Note      string `json:"note,omitempty"`
+}
+
+// Ответ, когда для завершения не хватает решений по пигментам
+type VerdictsRequiredResponse struct {
+    ErrorResponse
+    Raman []RamanExplanation `json:"raman"`
+}
+
+// Ответ на попытку завершить заявку с неоткалиброванными измерениями
+type UncalibratedReadingsResponse struct {
+    ErrorResponse
+    Readings []uint `json:"uncalibrated_readings"`
 }
 
 // Ответ при завершении заявки
@@ -0,0 +1,125 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"colorLex/internal/app/api/types"
+	"colorLex/internal/app/testenv"
+)
+
+func TestUserRoutes(t *testing.T) {
+	runRouteCases(t, []routeCase{
+		{
+			name:   "profile requires token",
+			method: http.MethodGet,
+			path:   path("/api/users/profile"),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "profile with invalid token",
+			method: http.MethodGet,
+			path:   path("/api/users/profile"),
+			header: func(*testing.T, *testenv.Env, *testenv.Fixtures) map[string]string {
+				return map[string]string{"Authorization": "Bearer garbage"}
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "profile",
+			method: http.MethodGet,
+			path:   path("/api/users/profile"),
+			as:     asStranger,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				var response types.UserProfileResponse
+				testenv.Decode(t, rec, &response)
+				if response.ID != f.Stranger.ID || response.Login != "stranger" {
+					t.Fatalf("unexpected profile %+v", response)
+				}
+			},
+		},
+		{
+			name:   "profile from cookie",
+			method: http.MethodGet,
+			path:   path("/api/users/profile"),
+			header: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures) map[string]string {
+				return map[string]string{"Cookie": "auth_token=" + env.Token(t, f.Moderator)}
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "update login",
+			method: http.MethodPut,
+			path:   path("/api/users/profile"),
+			as:     asCreator,
+			body:   body(types.UpdateProfileRequest{Login: "curator"}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				rec = env.Do(t, http.MethodPost, "/api/auth/login", types.LoginRequest{Login: "curator", Password: testenv.Password})
+				if rec.Code != http.StatusOK {
+					t.Fatalf("login with new name: status %d", rec.Code)
+				}
+			},
+		},
+		{
+			name:   "update password",
+			method: http.MethodPut,
+			path:   path("/api/users/profile"),
+			as:     asCreator,
+			body:   body(types.UpdateProfileRequest{Password: "new-password"}),
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testenv.Env, f *testenv.Fixtures, rec *httptest.ResponseRecorder) {
+				rec = env.Do(t, http.MethodPost, "/api/auth/login", types.LoginRequest{Login: "creator", Password: "new-password"})
+				if rec.Code != http.StatusOK {
+					t.Fatalf("login with new password: status %d", rec.Code)
+				}
+			},
+		},
+		{
+			name:   "update to taken login",
+			method: http.MethodPut,
+			path:   path("/api/users/profile"),
+			as:     asCreator,
+			body:   body(types.UpdateProfileRequest{Login: "moderator"}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "update without data",
+			method: http.MethodPut,
+			path:   path("/api/users/profile"),
+			as:     asCreator,
+			body:   body(types.UpdateProfileRequest{}),
+			status: http.StatusBadRequest,
+		},
+	})
+}
+
+func TestProxyImage(t *testing.T) {
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pigments/pigment_1.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png-bytes"))
+	}))
+	defer storage.Close()
+	t.Setenv("MINIO_PUBLIC_BASE", storage.URL+"/pigments/")
+
+	env := testenv.New(t)
+
+	rec := env.Do(t, http.MethodGet, "/api/images/pigment_1.png", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "png-bytes" {
+		t.Fatalf("status %d body %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "image/png" {
+		t.Fatalf("content type %q", got)
+	}
+
+	rec = env.Do(t, http.MethodGet, "/api/images/missing.png", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("missing image: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,132 @@
+// Package colorimetry - цвет спектра отражения: координаты XYZ и CIELAB под
+// стандартными источниками, отображение в sRGB для показа на экране и
+// метамерия - расхождение цвета двух образцов при смене освещения.
+package colorimetry
+
+import (
+	"fmt"
+	"math"
+
+	"colorLex/internal/app/spectra"
+)
+
+// XYZ - координаты цвета, Y белого образца под источником равен 100
+type XYZ struct {
+	X, Y, Z float64
+}
+
+// Lab - координаты CIELAB
+type Lab struct {
+	L, A, B float64
+}
+
+// Color - цвет образца под D65
+type Color struct {
+	XYZ XYZ
+	Lab Lab
+	Hex string // sRGB, #rrggbb
+	// OutOfGamut - цвет не помещается в sRGB и показан приближённо
+	OutOfGamut bool
+}
+
+// FromReflectance вычисляет цвет спектра отражения (доли 0..1, длины волн в нм) под D65.
+// Вне измеренного диапазона коэффициент отражения считается равным крайнему значению
+func FromReflectance(s spectra.Spectrum) (Color, error) {
+	if err := checkVisible(s); err != nil {
+		return Color{}, err
+	}
+
+	xyz := integrate(s, D65.power)
+	lab := toLab(xyz, D65.white)
+	hex, outOfGamut := toSRGB(xyz)
+	return Color{XYZ: round(xyz), Lab: Lab{L: round2(lab.L), A: round2(lab.A), B: round2(lab.B)}, Hex: hex, OutOfGamut: outOfGamut}, nil
+}
+
+// LabUnder вычисляет CIELAB спектра отражения под источником относительно его белой точки
+func LabUnder(s spectra.Spectrum, illuminant *Illuminant) (Lab, error) {
+	if err := checkVisible(s); err != nil {
+		return Lab{}, err
+	}
+	return toLab(integrate(s, illuminant.power), illuminant.white), nil
+}
+
+func checkVisible(s spectra.Spectrum) error {
+	if len(s) == 0 {
+		return fmt.Errorf("empty spectrum")
+	}
+	if from, to := s.Range(); to < 400 || from > 700 {
+		return fmt.Errorf("spectrum %g-%g nm does not cover the visible range", from, to)
+	}
+	return nil
+}
+
+// integrate суммирует отражение с функциями сложения и источником с шагом таблиц
+func integrate(s spectra.Spectrum, illuminant []float64) XYZ {
+	from, to := s.Range()
+	var xyz XYZ
+	var norm float64
+	for i, cmf := range observer {
+		wavelength := tableStart + tableStep*float64(i)
+		var r float64
+		switch {
+		case wavelength <= from:
+			r = s[0].Value
+		case wavelength >= to:
+			r = s[len(s)-1].Value
+		default:
+			r, _ = s.At(wavelength)
+		}
+		weight := illuminant[i]
+		xyz.X += r * weight * cmf[0]
+		xyz.Y += r * weight * cmf[1]
+		xyz.Z += r * weight * cmf[2]
+		norm += weight * cmf[1]
+	}
+	k := 100 / norm
+	return XYZ{X: xyz.X * k, Y: xyz.Y * k, Z: xyz.Z * k}
+}
+
+func toLab(xyz, white XYZ) Lab {
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(xyz.X/white.X), f(xyz.Y/white.Y), f(xyz.Z/white.Z)
+	return Lab{L: 116*fy - 16, A: 500 * (fx - fy), B: 200 * (fy - fz)}
+}
+
+// toSRGB переводит XYZ (D65) в sRGB. Каналы вне 0..1 обрезаются
+func toSRGB(xyz XYZ) (string, bool) {
+	x, y, z := xyz.X/100, xyz.Y/100, xyz.Z/100
+	linear := [3]float64{
+		3.2404542*x - 1.5371385*y - 0.4985314*z,
+		-0.9692660*x + 1.8760108*y + 0.0415560*z,
+		0.0556434*x - 0.2040259*y + 1.0572252*z,
+	}
+
+	var channels [3]int
+	outOfGamut := false
+	for i, v := range linear {
+		if v < -1e-3 || v > 1+1e-3 {
+			outOfGamut = true
+		}
+		v = math.Max(0, math.Min(1, v))
+		if v <= 0.0031308 {
+			v *= 12.92
+		} else {
+			v = 1.055*math.Pow(v, 1/2.4) - 0.055
+		}
+		channels[i] = int(math.Round(v * 255))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", channels[0], channels[1], channels[2]), outOfGamut
+}
+
+func round(xyz XYZ) XYZ {
+	return XYZ{X: round2(xyz.X), Y: round2(xyz.Y), Z: round2(xyz.Z)}
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
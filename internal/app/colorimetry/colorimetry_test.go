@@ -0,0 +1,118 @@
+package colorimetry
+
+import (
+	"math"
+	"testing"
+
+	"colorLex/internal/app/spectra"
+)
+
+func flat(value float64) spectra.Spectrum {
+	return spectra.Spectrum{{Wavelength: 380, Value: value}, {Wavelength: 780, Value: value}}
+}
+
+func TestFromReflectance(t *testing.T) {
+	// Идеальный белый под D65 совпадает с белой точкой
+	white, err := FromReflectance(flat(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(white.XYZ.X-95.05) > 0.2 || white.XYZ.Y != 100 || math.Abs(white.XYZ.Z-108.88) > 0.3 {
+		t.Fatalf("white %+v", white.XYZ)
+	}
+	if math.Abs(white.Lab.L-100) > 0.01 || math.Abs(white.Lab.A) > 0.3 || math.Abs(white.Lab.B) > 0.3 || white.Hex != "#ffffff" {
+		t.Fatalf("white %+v", white)
+	}
+
+	// Серый 18% - нейтральный с L* около 49.5
+	grey, _ := FromReflectance(flat(0.18))
+	if math.Abs(grey.Lab.L-49.5) > 0.2 || grey.Hex[1:3] != grey.Hex[3:5] {
+		t.Fatalf("grey %+v", grey)
+	}
+
+	// Кривая, отражающая только длинные волны, выглядит красной
+	red, _ := FromReflectance(spectra.Spectrum{{Wavelength: 400, Value: 0.05}, {Wavelength: 580, Value: 0.05}, {Wavelength: 620, Value: 0.8}, {Wavelength: 700, Value: 0.85}})
+	if red.Lab.A < 30 || red.Hex[1:3] < "c0" || red.Hex[5:7] > "40" {
+		t.Fatalf("red %+v", red)
+	}
+
+	if _, err := FromReflectance(spectra.Spectrum{{Wavelength: 900, Value: 0.1}, {Wavelength: 1000, Value: 0.2}}); err == nil {
+		t.Fatal("infrared spectrum must be rejected")
+	}
+}
+
+func TestIlluminantWhitePoints(t *testing.T) {
+	// Координаты цветности белых точек по CIE 15
+	tests := []struct {
+		illuminant *Illuminant
+		x, y       float64
+	}{
+		{D65, 0.3127, 0.3290},
+		{A, 0.4476, 0.4074},
+		{F11, 0.3805, 0.3769},
+	}
+	for _, tt := range tests {
+		white := tt.illuminant.White()
+		sum := white.X + white.Y + white.Z
+		if x, y := white.X/sum, white.Y/sum; math.Abs(x-tt.x) > 0.002 || math.Abs(y-tt.y) > 0.002 {
+			t.Errorf("%s white point x=%.4f y=%.4f, want %.4f %.4f", tt.illuminant.Name, x, y, tt.x, tt.y)
+		}
+	}
+	if illuminant, ok := IlluminantByName("f11"); !ok || illuminant != F11 {
+		t.Error("F11 not found by name")
+	}
+}
+
+func TestDeltaE2000(t *testing.T) {
+	// Пары из проверочных данных Sharma, Wu, Dalal (2005)
+	tests := []struct {
+		first, second Lab
+		want          float64
+	}{
+		{Lab{50, 2.6772, -79.7751}, Lab{50, 0, -82.7485}, 2.0425},
+		{Lab{50, -1.3802, -84.2814}, Lab{50, 0, -82.7485}, 1.0000},
+		{Lab{50, 2.5, 0}, Lab{73, 25, -18}, 27.1492},
+		{Lab{60.2574, -34.0099, 36.2677}, Lab{60.4626, -34.1751, 39.4387}, 1.2644},
+		{Lab{50, 0, 0}, Lab{50, 0, 0}, 0},
+	}
+	for _, tt := range tests {
+		if got := DeltaE2000(tt.first, tt.second); math.Abs(got-tt.want) > 1e-4 {
+			t.Errorf("DeltaE2000(%v, %v) = %.4f, want %.4f", tt.first, tt.second, got, tt.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// Одинаковые спектры не различаются ни под одним источником
+	same, err := Compare(flat(0.4), flat(0.4), D65, Illuminants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(same.Differences) != 3 || same.Index != 0 || same.Differences[0].Illuminant != D65 {
+		t.Fatalf("identical spectra %+v", same)
+	}
+
+	// Серый и метамерная ему пара с узкими пиками: под D65 близки, под A и F11 расходятся
+	grey := flat(0.3)
+	var metamer spectra.Spectrum
+	for wavelength := 380.0; wavelength <= 780; wavelength += 10 {
+		value := 0.3 + 0.12*math.Sin((wavelength-380)/400*6*math.Pi)
+		metamer = append(metamer, spectra.Point{Wavelength: wavelength, Value: value})
+	}
+	result, err := Compare(grey, metamer, D65, Illuminants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Worst == nil || result.Index <= 0 {
+		t.Fatalf("metameric pair %+v", result)
+	}
+	for _, d := range result.Differences[1:] {
+		if d.Index == 0 {
+			t.Errorf("%s: zero metamerism index", d.Illuminant.Name)
+		}
+	}
+
+	if _, err := Compare(grey, spectra.Spectrum{{Wavelength: 900, Value: 0.1}, {Wavelength: 1000, Value: 0.2}}, D65, Illuminants); err == nil {
+		t.Fatal("infrared spectrum must be rejected")
+	}
+}
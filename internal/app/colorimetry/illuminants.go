@@ -0,0 +1,95 @@
+package colorimetry
+
+import (
+	"math"
+	"strings"
+
+	"colorLex/internal/app/spectra"
+)
+
+// Illuminant - источник света: относительное спектральное распределение на сетке
+// таблиц наблюдателя и белая точка, относительно которой считается CIELAB
+type Illuminant struct {
+	Name        string
+	Description string
+	power       []float64
+	white       XYZ
+}
+
+// Стандартные и типовые источники
+var (
+	D65 = newIlluminant("D65", "дневной свет, 6500 K", d65)
+	A   = newIlluminant("A", "лампа накаливания, 2856 K", planck(2848))
+	F11 = newIlluminant("F11", "узкополосная люминесцентная лампа, 4000 K", downsample(f11))
+)
+
+// Illuminants - источники, под которыми сравниваются образцы
+var Illuminants = []*Illuminant{D65, A, F11}
+
+// Unsupported - известные источники, которых нет в Illuminants, с причиной. Для светодиода
+// нужна таблица CIE 15:2018 LED-B3: модельное распределение давало бы индекс метамерии,
+// которому нельзя доверять
+var Unsupported = map[string]string{
+	"LED": "в сборке нет таблицы CIE 15:2018 LED-B3",
+}
+
+// IlluminantByName находит источник без учёта регистра
+func IlluminantByName(name string) (*Illuminant, bool) {
+	for _, illuminant := range Illuminants {
+		if strings.EqualFold(illuminant.Name, name) {
+			return illuminant, true
+		}
+	}
+	return nil, false
+}
+
+// White - координаты идеального белого под источником, Y = 100
+func (i *Illuminant) White() XYZ {
+	return i.white
+}
+
+func newIlluminant(name, description string, power []float64) *Illuminant {
+	illuminant := &Illuminant{Name: name, Description: description, power: power}
+	illuminant.white = integrate(flatWhite, power)
+	return illuminant
+}
+
+// flatWhite - идеальный рассеиватель на всём диапазоне таблиц
+var flatWhite = spectra.Spectrum{
+	{Wavelength: tableStart, Value: 1},
+	{Wavelength: tableStart + tableStep*float64(len(observer)-1), Value: 1},
+}
+
+// planck - излучение чёрного тела по определению источника A в CIE 15 (c2 = 1.435e7 нм·К),
+// нормированное к 100 на 560 нм
+func planck(temperature float64) []float64 {
+	const c2 = 1.435e7
+	radiance := func(wavelength float64) float64 {
+		return math.Pow(wavelength, -5) / (math.Exp(c2/(wavelength*temperature)) - 1)
+	}
+	power := make([]float64, len(observer))
+	for i := range power {
+		power[i] = 100 * radiance(tableStart+tableStep*float64(i)) / radiance(560)
+	}
+	return power
+}
+
+// downsample переводит таблицу с шагом 5 нм на шаг таблиц (10 нм) треугольным
+// усреднением, сохраняя энергию узких линий
+func downsample(fine []float64) []float64 {
+	power := make([]float64, len(observer))
+	for i := range power {
+		j := 2 * i
+		sum, weight := 2*fine[j], 2.0
+		if j > 0 {
+			sum += fine[j-1]
+			weight++
+		}
+		if j+1 < len(fine) {
+			sum += fine[j+1]
+			weight++
+		}
+		power[i] = sum / weight
+	}
+	return power
+}
@@ -0,0 +1,146 @@
+package colorimetry
+
+import (
+	"math"
+
+	"colorLex/internal/app/spectra"
+)
+
+// IlluminantDifference - сравнение двух образцов под одним источником
+type IlluminantDifference struct {
+	Illuminant *Illuminant
+	First      Lab
+	Second     Lab
+	DeltaE     float64 // CIEDE2000
+	// Index - индекс метамерии: ΔE00 после аддитивной поправки на расхождение под
+	// опорным источником (CIE 15). У опорного источника равен 0
+	Index float64
+}
+
+// Metamerism - сравнение образцов под набором источников
+type Metamerism struct {
+	Reference   *Illuminant
+	Differences []IlluminantDifference // опорный источник первым
+	// Index - общий индекс метамерии: среднее индексов по испытательным источникам
+	Index float64
+	// Worst - испытательный источник с наибольшим индексом
+	Worst *Illuminant
+}
+
+// Compare сравнивает спектры отражения под опорным источником и испытательными
+// источниками tests (опорный среди них пропускается)
+func Compare(first, second spectra.Spectrum, reference *Illuminant, tests []*Illuminant) (Metamerism, error) {
+	firstRef, err := LabUnder(first, reference)
+	if err != nil {
+		return Metamerism{}, err
+	}
+	secondRef, err := LabUnder(second, reference)
+	if err != nil {
+		return Metamerism{}, err
+	}
+	result := Metamerism{
+		Reference: reference,
+		Differences: []IlluminantDifference{{
+			Illuminant: reference,
+			First:      firstRef,
+			Second:     secondRef,
+			DeltaE:     DeltaE2000(firstRef, secondRef),
+		}},
+	}
+
+	var sum, worst float64
+	var count int
+	for _, illuminant := range tests {
+		if illuminant == reference {
+			continue
+		}
+		firstLab, _ := LabUnder(first, illuminant)
+		secondLab, _ := LabUnder(second, illuminant)
+		corrected := Lab{
+			L: secondLab.L - (secondRef.L - firstRef.L),
+			A: secondLab.A - (secondRef.A - firstRef.A),
+			B: secondLab.B - (secondRef.B - firstRef.B),
+		}
+		difference := IlluminantDifference{
+			Illuminant: illuminant,
+			First:      firstLab,
+			Second:     secondLab,
+			DeltaE:     DeltaE2000(firstLab, secondLab),
+			Index:      DeltaE2000(firstLab, corrected),
+		}
+		result.Differences = append(result.Differences, difference)
+		if result.Worst == nil || difference.Index > worst {
+			result.Worst, worst = illuminant, difference.Index
+		}
+		sum += difference.Index
+		count++
+	}
+	if count > 0 {
+		result.Index = sum / float64(count)
+	}
+	return result, nil
+}
+
+// DeltaE2000 - цветовое различие CIEDE2000 с kL = kC = kH = 1
+func DeltaE2000(first, second Lab) float64 {
+	const deg = math.Pi / 180
+	c1 := math.Hypot(first.A, first.B)
+	c2 := math.Hypot(second.A, second.B)
+	meanC := (c1 + c2) / 2
+	c7 := math.Pow(meanC, 7)
+	g := 0.5 * (1 - math.Sqrt(c7/(c7+math.Pow(25, 7))))
+
+	a1, a2 := (1+g)*first.A, (1+g)*second.A
+	c1p, c2p := math.Hypot(a1, first.B), math.Hypot(a2, second.B)
+	hue := func(a, b float64) float64 {
+		if a == 0 && b == 0 {
+			return 0
+		}
+		h := math.Atan2(b, a) / deg
+		if h < 0 {
+			h += 360
+		}
+		return h
+	}
+	h1, h2 := hue(a1, first.B), hue(a2, second.B)
+
+	dL := second.L - first.L
+	dC := c2p - c1p
+	var dh float64
+	if c1p*c2p != 0 {
+		dh = h2 - h1
+		switch {
+		case dh > 180:
+			dh -= 360
+		case dh < -180:
+			dh += 360
+		}
+	}
+	dH := 2 * math.Sqrt(c1p*c2p) * math.Sin(dh/2*deg)
+
+	meanL := (first.L + second.L) / 2
+	meanCp := (c1p + c2p) / 2
+	meanH := h1 + h2
+	if c1p*c2p != 0 {
+		switch {
+		case math.Abs(h1-h2) <= 180:
+			meanH /= 2
+		case h1+h2 < 360:
+			meanH = (h1 + h2 + 360) / 2
+		default:
+			meanH = (h1 + h2 - 360) / 2
+		}
+	}
+
+	t := 1 - 0.17*math.Cos((meanH-30)*deg) + 0.24*math.Cos(2*meanH*deg) +
+		0.32*math.Cos((3*meanH+6)*deg) - 0.20*math.Cos((4*meanH-63)*deg)
+	l50 := (meanL - 50) * (meanL - 50)
+	sL := 1 + 0.015*l50/math.Sqrt(20+l50)
+	sC := 1 + 0.045*meanCp
+	sH := 1 + 0.015*meanCp*t
+	cp7 := math.Pow(meanCp, 7)
+	rT := -2 * math.Sqrt(cp7/(cp7+math.Pow(25, 7))) *
+		math.Sin(60*math.Exp(-((meanH-275)/25)*((meanH-275)/25))*deg)
+
+	return math.Sqrt((dL/sL)*(dL/sL) + (dC/sC)*(dC/sC) + (dH/sH)*(dH/sH) + rT*(dC/sC)*(dH/sH))
+}
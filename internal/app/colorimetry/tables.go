@@ -0,0 +1,76 @@
+package colorimetry
+
+// Таблицы CIE с шагом 10 нм от 380 до 780 нм
+
+const (
+	tableStart = 380.0
+	tableStep  = 10.0
+)
+
+// observer - функции сложения цветов стандартного наблюдателя CIE 1931 (2°): x̄, ȳ, z̄
+var observer = [][3]float64{
+	{0.001368, 0.000039, 0.006450}, // 380
+	{0.004243, 0.000120, 0.020050},
+	{0.014310, 0.000396, 0.067850}, // 400
+	{0.043510, 0.001210, 0.207400},
+	{0.134380, 0.004000, 0.645600},
+	{0.283900, 0.011600, 1.385600},
+	{0.348280, 0.023000, 1.747060},
+	{0.336200, 0.038000, 1.772110}, // 450
+	{0.290800, 0.060000, 1.669200},
+	{0.195360, 0.090980, 1.287640},
+	{0.095640, 0.139020, 0.812950},
+	{0.032010, 0.208020, 0.465180},
+	{0.004900, 0.323000, 0.272000}, // 500
+	{0.009300, 0.503000, 0.158200},
+	{0.063270, 0.710000, 0.078250},
+	{0.165500, 0.862000, 0.042160},
+	{0.290400, 0.954000, 0.020300},
+	{0.433450, 0.994950, 0.008750}, // 550
+	{0.594500, 0.995000, 0.003900},
+	{0.762100, 0.952000, 0.002100},
+	{0.916300, 0.870000, 0.001650},
+	{1.026300, 0.757000, 0.001100},
+	{1.062200, 0.631000, 0.000800}, // 600
+	{1.002600, 0.503000, 0.000340},
+	{0.854450, 0.381000, 0.000190},
+	{0.642400, 0.265000, 0.000050},
+	{0.447900, 0.175000, 0.000020},
+	{0.283500, 0.107000, 0.000000}, // 650
+	{0.164900, 0.061000, 0.000000},
+	{0.087400, 0.032000, 0.000000},
+	{0.046770, 0.017000, 0.000000},
+	{0.022700, 0.008210, 0.000000},
+	{0.011359, 0.004102, 0.000000}, // 700
+	{0.005790, 0.002091, 0.000000},
+	{0.002899, 0.001047, 0.000000},
+	{0.001440, 0.000520, 0.000000},
+	{0.000690, 0.000249, 0.000000},
+	{0.000332, 0.000120, 0.000000}, // 750
+	{0.000166, 0.000060, 0.000000},
+	{0.000083, 0.000030, 0.000000},
+	{0.000042, 0.000015, 0.000000}, // 780
+}
+
+// d65 - относительное спектральное распределение стандартного источника D65
+var d65 = []float64{
+	49.9755, 54.6482, 82.7549, 91.4860, 93.4318, 86.6823, 104.865, 117.008, 117.812, 114.861, // 380-470
+	115.923, 108.811, 109.354, 107.802, 104.790, 107.689, 104.405, 104.046, 100.000, 96.3342, // 480-570
+	95.7880, 88.6856, 90.0062, 89.5991, 87.6987, 83.2886, 83.6992, 80.0268, 80.2146, 82.2778, // 580-670
+	78.2842, 69.7213, 71.6091, 74.3490, 61.6040, 69.8856, 75.0870, 63.5927, 46.4182, 66.8054, // 680-770
+	63.3828, // 780
+}
+
+// f11 - люминесцентная лампа CIE F11 (узкополосная трёхкомпонентная, 4000 K) с шагом 5 нм:
+// её линии уже шага таблиц наблюдателя, поэтому она приводится к 10 нм усреднением
+var f11 = []float64{
+	0.91, 0.63, 0.46, 0.37, 1.29, 12.68, 1.59, 1.79, 2.46, 3.33, // 380-425
+	4.49, 33.94, 12.13, 6.95, 7.19, 7.12, 6.72, 6.13, 5.46, 4.79, // 430-475
+	5.66, 14.29, 14.96, 8.97, 4.72, 2.33, 1.47, 1.10, 0.89, 0.83, // 480-525
+	1.18, 4.90, 39.59, 72.84, 32.61, 7.52, 2.83, 1.96, 1.67, 4.43, // 530-575
+	11.28, 14.76, 12.73, 9.74, 7.33, 9.72, 55.27, 42.58, 13.18, 13.16, // 580-625
+	12.26, 5.11, 2.07, 2.34, 3.58, 3.01, 2.48, 2.14, 1.54, 1.33, // 630-675
+	1.46, 1.94, 2.00, 1.20, 1.35, 4.10, 5.58, 2.51, 0.57, 0.27, // 680-725
+	0.23, 0.21, 0.24, 0.24, 0.20, 0.24, 0.32, 0.26, 0.16, 0.12, // 730-775
+	0.09, // 780
+}
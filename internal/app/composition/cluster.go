@@ -0,0 +1,183 @@
+package composition
+
+import (
+	"math"
+	"sort"
+)
+
+// maxIterations - предел итераций k-средних; на реальных палитрах сходится за десяток
+const maxIterations = 100
+
+// Cluster - группа составов вокруг общего центра
+type Cluster struct {
+	// Centroid - средний состав группы в процентах, сумма - 100
+	Centroid Vector
+	Members  []int // номера составов в переданном списке
+	// Spread - среднее расстояние участников до центра в долях (0 - все составы одинаковы)
+	Spread float64
+}
+
+// KMeans делит составы на не больше чем k групп методом k-средних. Составы сравниваются
+// по пропорциям: доли каждого приводятся к сумме 1, расстояние - евклидово. Начальные
+// центры выбираются детерминированно (самый удалённый от уже выбранных), поэтому
+// одинаковый вход даёт одинаковые группы. Пустые составы не входят ни в одну группу.
+// Группы упорядочены по убыванию размера
+func KMeans(vectors []Vector, k int) []Cluster {
+	ids, points, index := densify(vectors)
+	if len(points) == 0 || k <= 0 {
+		return nil
+	}
+
+	centroids := seedCentroids(points, k)
+	assignment := make([]int, len(points))
+	for i := range assignment {
+		assignment[i] = -1
+	}
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		changed := false
+		for p, point := range points {
+			if nearest := nearestCentroid(point, centroids); nearest != assignment[p] {
+				assignment[p] = nearest
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+		// Центр группы без участников остаётся на месте
+		sums := make([][]float64, len(centroids))
+		counts := make([]int, len(centroids))
+		for p, c := range assignment {
+			if sums[c] == nil {
+				sums[c] = make([]float64, len(ids))
+			}
+			for d, value := range points[p] {
+				sums[c][d] += value
+			}
+			counts[c]++
+		}
+		for c := range centroids {
+			if counts[c] == 0 {
+				continue
+			}
+			for d := range centroids[c] {
+				centroids[c][d] = sums[c][d] / float64(counts[c])
+			}
+		}
+	}
+
+	clusters := make([]Cluster, len(centroids))
+	for p, c := range assignment {
+		clusters[c].Members = append(clusters[c].Members, index[p])
+		clusters[c].Spread += math.Sqrt(squaredDistance(points[p], centroids[c]))
+	}
+	var result []Cluster
+	for c, cluster := range clusters {
+		if len(cluster.Members) == 0 {
+			continue
+		}
+		cluster.Spread /= float64(len(cluster.Members))
+		cluster.Centroid = make(Vector)
+		for d, value := range centroids[c] {
+			if value > 0 {
+				cluster.Centroid[ids[d]] = 100 * value
+			}
+		}
+		result = append(result, cluster)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return len(result[i].Members) > len(result[j].Members) })
+	return result
+}
+
+// densify переводит составы в плотные векторы пропорций по общему списку пигментов.
+// index[p] - номер исходного состава для точки p
+func densify(vectors []Vector) (ids []uint, points [][]float64, index []int) {
+	position := make(map[uint]int)
+	for _, vector := range vectors {
+		for id, percent := range vector {
+			if _, ok := position[id]; !ok && percent > 0 {
+				position[id] = 0
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for d, id := range ids {
+		position[id] = d
+	}
+
+	for i, vector := range vectors {
+		point := make([]float64, len(ids))
+		var sum float64
+		for id, percent := range vector {
+			if percent > 0 {
+				point[position[id]] = percent
+				sum += percent
+			}
+		}
+		if sum == 0 {
+			continue
+		}
+		for d := range point {
+			point[d] /= sum
+		}
+		points = append(points, point)
+		index = append(index, i)
+	}
+	return ids, points, index
+}
+
+// seedCentroids выбирает до k различных начальных центров: первый - точка, ближайшая
+// к среднему, каждый следующий - самая удалённая от уже выбранных
+func seedCentroids(points [][]float64, k int) [][]float64 {
+	mean := make([]float64, len(points[0]))
+	for _, point := range points {
+		for d, value := range point {
+			mean[d] += value / float64(len(points))
+		}
+	}
+	first := nearestCentroid(mean, points)
+	centroids := [][]float64{append([]float64(nil), points[first]...)}
+
+	distances := make([]float64, len(points))
+	for p, point := range points {
+		distances[p] = squaredDistance(point, centroids[0])
+	}
+	for len(centroids) < k {
+		farthest := 0
+		for p := range points {
+			if distances[p] > distances[farthest] {
+				farthest = p
+			}
+		}
+		if distances[farthest] == 0 {
+			break // остальные точки совпадают с уже выбранными центрами
+		}
+		centroid := append([]float64(nil), points[farthest]...)
+		centroids = append(centroids, centroid)
+		for p, point := range points {
+			distances[p] = math.Min(distances[p], squaredDistance(point, centroid))
+		}
+	}
+	return centroids
+}
+
+// nearestCentroid - номер ближайшего центра; при равенстве - меньший
+func nearestCentroid(point []float64, centroids [][]float64) int {
+	best, bestDistance := 0, math.Inf(1)
+	for c, centroid := range centroids {
+		if distance := squaredDistance(point, centroid); distance < bestDistance {
+			best, bestDistance = c, distance
+		}
+	}
+	return best
+}
+
+func squaredDistance(a, b []float64) float64 {
+	var sum float64
+	for d := range a {
+		diff := a[d] - b[d]
+		sum += diff * diff
+	}
+	return sum
+}
@@ -0,0 +1,149 @@
+// Package composition - сходство пигментного состава анализов.
+//
+// Состав анализа - доли пигментов в процентах; спектры при сравнении не участвуют.
+// Близкие палитры на разных произведениях указывают на общую мастерскую или
+// традицию, а состав, почти совпадающий с известной работой, - повод проверить
+// произведение на подделку по образцу.
+package composition
+
+import (
+	"math"
+	"sort"
+)
+
+// Меры сходства
+const (
+	// MetricCosine - косинус угла между векторами долей: сравнивает пропорции,
+	// не чувствителен к общему масштабу долей
+	MetricCosine = "cosine"
+	// MetricBrayCurtis - 1 - индекс Брея - Кёртиса: сумма общих долей, отнесённая
+	// к средней сумме долей двух составов. Строже к расхождению в долях
+	MetricBrayCurtis = "braycurtis"
+)
+
+// Metrics - поддерживаемые меры сходства
+var Metrics = []string{MetricCosine, MetricBrayCurtis}
+
+// Vector - доли пигментов в процентах по ID пигмента. Нулевые и отрицательные доли
+// считаются отсутствием пигмента
+type Vector map[uint]float64
+
+// Similarity - сходство составов от 0 (нет общих пигментов) до 1 (одинаковые пропорции).
+// Неизвестная мера и пустой состав дают 0
+func Similarity(a, b Vector, metric string) float64 {
+	switch metric {
+	case MetricCosine:
+		var dot, normA, normB float64
+		for id, x := range a {
+			if x <= 0 {
+				continue
+			}
+			normA += x * x
+			if y := b[id]; y > 0 {
+				dot += x * y
+			}
+		}
+		for _, y := range b {
+			if y > 0 {
+				normB += y * y
+			}
+		}
+		if normA == 0 || normB == 0 {
+			return 0
+		}
+		return math.Min(1, dot/math.Sqrt(normA*normB))
+	case MetricBrayCurtis:
+		var common, sum float64
+		for id, x := range a {
+			if x <= 0 {
+				continue
+			}
+			sum += x
+			if y := b[id]; y > 0 {
+				common += math.Min(x, y)
+			}
+		}
+		for _, y := range b {
+			if y > 0 {
+				sum += y
+			}
+		}
+		if sum == 0 {
+			return 0
+		}
+		return 2 * common / sum
+	}
+	return 0
+}
+
+// Match - кандидат, похожий на исходный состав
+type Match struct {
+	Index      int // номер кандидата в переданном списке
+	Similarity float64
+}
+
+// Nearest возвращает не больше limit кандидатов с ненулевым сходством, от самых похожих.
+// При равном сходстве кандидаты идут в исходном порядке
+func Nearest(query Vector, candidates []Vector, metric string, limit int) []Match {
+	var matches []Match
+	for i, candidate := range candidates {
+		if similarity := Similarity(query, candidate, metric); similarity > 0 {
+			matches = append(matches, Match{Index: i, Similarity: similarity})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Similarity > matches[j].Similarity })
+	if limit >= 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// Breakdown - разбор двух составов по пигментам. Списки упорядочены по убыванию доли,
+// общие пигменты - по меньшей из двух долей
+type Breakdown struct {
+	Shared    []Share
+	QueryOnly []Share // только в исходном составе, Other = 0
+	OtherOnly []Share // только в сравниваемом, Query = 0
+}
+
+// Share - доли пигмента в исходном и сравниваемом составах
+type Share struct {
+	PigmentID uint
+	Query     float64
+	Other     float64
+}
+
+// Compare раскладывает составы на общие и собственные пигменты
+func Compare(query, other Vector) Breakdown {
+	var breakdown Breakdown
+	for id, x := range query {
+		if x <= 0 {
+			continue
+		}
+		if y := other[id]; y > 0 {
+			breakdown.Shared = append(breakdown.Shared, Share{PigmentID: id, Query: x, Other: y})
+		} else {
+			breakdown.QueryOnly = append(breakdown.QueryOnly, Share{PigmentID: id, Query: x})
+		}
+	}
+	for id, y := range other {
+		if y > 0 && query[id] <= 0 {
+			breakdown.OtherOnly = append(breakdown.OtherOnly, Share{PigmentID: id, Other: y})
+		}
+	}
+
+	sortShares(breakdown.Shared, func(s Share) float64 { return math.Min(s.Query, s.Other) })
+	sortShares(breakdown.QueryOnly, func(s Share) float64 { return s.Query })
+	sortShares(breakdown.OtherOnly, func(s Share) float64 { return s.Other })
+	return breakdown
+}
+
+// sortShares упорядочивает по убыванию key, при равенстве - по ID пигмента
+func sortShares(shares []Share, key func(Share) float64) {
+	sort.Slice(shares, func(i, j int) bool {
+		if a, b := key(shares[i]), key(shares[j]); a != b {
+			return a > b
+		}
+		return shares[i].PigmentID < shares[j].PigmentID
+	})
+}
@@ -0,0 +1,165 @@
+package composition
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   Vector
+		metric string
+		want   float64
+	}{
+		{
+			name:   "same proportions, cosine",
+			a:      Vector{1: 60, 2: 40},
+			b:      Vector{1: 30, 2: 20},
+			metric: MetricCosine,
+			want:   1,
+		},
+		{
+			name:   "same proportions, bray-curtis is scale-sensitive",
+			a:      Vector{1: 60, 2: 40},
+			b:      Vector{1: 30, 2: 20},
+			metric: MetricBrayCurtis,
+			want:   2 * 50.0 / 150,
+		},
+		{
+			name:   "no shared pigments",
+			a:      Vector{1: 100},
+			b:      Vector{2: 100},
+			metric: MetricCosine,
+			want:   0,
+		},
+		{
+			name:   "partial overlap, cosine",
+			a:      Vector{1: 50, 2: 50},
+			b:      Vector{1: 100},
+			metric: MetricCosine,
+			want:   1 / math.Sqrt2,
+		},
+		{
+			name:   "partial overlap, bray-curtis",
+			a:      Vector{1: 50, 2: 50},
+			b:      Vector{1: 80, 3: 20},
+			metric: MetricBrayCurtis,
+			want:   0.5,
+		},
+		{
+			name:   "zero percents are absent pigments",
+			a:      Vector{1: 100, 2: 0},
+			b:      Vector{1: 100, 3: 0},
+			metric: MetricBrayCurtis,
+			want:   1,
+		},
+		{
+			name:   "empty composition",
+			a:      Vector{},
+			b:      Vector{1: 100},
+			metric: MetricCosine,
+			want:   0,
+		},
+		{
+			name:   "unknown metric",
+			a:      Vector{1: 100},
+			b:      Vector{1: 100},
+			metric: "euclid",
+			want:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Similarity(tt.a, tt.b, tt.metric)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("Similarity = %v, want %v", got, tt.want)
+			}
+			if back := Similarity(tt.b, tt.a, tt.metric); math.Abs(back-got) > 1e-9 {
+				t.Fatalf("not symmetric: %v and %v", got, back)
+			}
+		})
+	}
+}
+
+func TestNearest(t *testing.T) {
+	query := Vector{1: 70, 2: 30}
+	candidates := []Vector{
+		{3: 100},        // нет общих пигментов
+		{1: 50, 2: 50},  // близко
+		{1: 70, 2: 30},  // совпадает
+		{1: 100},        // только один общий
+		{1: 140, 2: 60}, // те же пропорции, как и у 2
+	}
+
+	matches := Nearest(query, candidates, MetricCosine, 3)
+	if len(matches) != 3 {
+		t.Fatalf("got %d matches, want 3", len(matches))
+	}
+	if matches[0].Index != 2 || matches[1].Index != 4 || matches[2].Index != 1 {
+		t.Fatalf("unexpected order %+v", matches)
+	}
+
+	if all := Nearest(query, candidates, MetricCosine, -1); len(all) != 4 {
+		t.Fatalf("unlimited search returned %d matches, want 4", len(all))
+	}
+}
+
+func TestCompare(t *testing.T) {
+	breakdown := Compare(Vector{1: 50, 2: 30, 3: 20}, Vector{2: 60, 1: 10, 4: 30})
+
+	if len(breakdown.Shared) != 2 || breakdown.Shared[0].PigmentID != 2 || breakdown.Shared[1].PigmentID != 1 {
+		t.Fatalf("unexpected shared %+v", breakdown.Shared)
+	}
+	if s := breakdown.Shared[0]; s.Query != 30 || s.Other != 60 {
+		t.Fatalf("unexpected share %+v", s)
+	}
+	if len(breakdown.QueryOnly) != 1 || breakdown.QueryOnly[0] != (Share{PigmentID: 3, Query: 20}) {
+		t.Fatalf("unexpected query-only %+v", breakdown.QueryOnly)
+	}
+	if len(breakdown.OtherOnly) != 1 || breakdown.OtherOnly[0] != (Share{PigmentID: 4, Other: 30}) {
+		t.Fatalf("unexpected other-only %+v", breakdown.OtherOnly)
+	}
+}
+
+func TestKMeans(t *testing.T) {
+	vectors := []Vector{
+		{1: 70, 2: 30}, // охра с ультрамарином
+		{1: 35, 2: 15}, // те же пропорции в других единицах
+		{1: 65, 2: 35},
+		{3: 80, 4: 20}, // свинцовые белила с киноварью
+		{3: 85, 4: 10, 5: 5},
+		{}, // пустой состав не участвует
+		{3: 75, 4: 25},
+		{3: 80, 4: 20, 1: 0}, // нулевая доля - отсутствие пигмента
+	}
+
+	clusters := KMeans(vectors, 2)
+	if len(clusters) != 2 {
+		t.Fatalf("got %d clusters, want 2", len(clusters))
+	}
+	white, ochre := clusters[0], clusters[1]
+	if len(white.Members) != 4 || len(ochre.Members) != 3 {
+		t.Fatalf("unexpected clusters %+v", clusters)
+	}
+	for _, member := range ochre.Members {
+		if member > 2 {
+			t.Fatalf("composition %d in the ochre cluster", member)
+		}
+	}
+	if math.Abs(ochre.Centroid[1]-(70+70+65)/3.0) > 1e-9 || ochre.Centroid[3] != 0 {
+		t.Fatalf("unexpected centroid %v", ochre.Centroid)
+	}
+	if white.Spread <= 0 || white.Spread > 0.1 {
+		t.Fatalf("unexpected spread %v", white.Spread)
+	}
+
+	// групп не больше, чем различных составов
+	if clusters := KMeans([]Vector{{1: 100}, {1: 50}}, 5); len(clusters) != 1 || clusters[0].Spread != 0 {
+		t.Fatalf("unexpected clusters of equal compositions %+v", clusters)
+	}
+	if clusters := KMeans([]Vector{{}}, 3); clusters != nil {
+		t.Fatalf("empty compositions clustered: %+v", clusters)
+	}
+}
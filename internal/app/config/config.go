@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strconv"
 )
 
 type Config struct {
@@ -10,6 +12,11 @@ type Config struct {
 	RedisPassword string
 	RedisDB      int
 	JWTSecret    string
+	ServiceHost  string
+	ServicePort  int
+	CubeDir      string // данные гиперспектральных кубов, ждущих обработки
+	// RequireCalibration - завершать заявки только по измерениям откалиброванных приборов
+	RequireCalibration bool
 }
 
 func LoadConfig() (*Config, error) {
@@ -19,6 +26,10 @@ func LoadConfig() (*Config, error) {
 		RedisPassword: getEnv("REDIS_PASSWORD", "password"),
 		RedisDB:       0,
 		JWTSecret:     getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+		ServiceHost:   getEnv("SERVICE_HOST", "0.0.0.0"),
+		ServicePort:   getEnvInt("SERVICE_PORT", 8080),
+		CubeDir:       getEnv("CUBE_DIR", filepath.Join(os.TempDir(), "colorlex-cubes")),
+		RequireCalibration: getEnvBool("REQUIRE_CALIBRATION", false),
 	}, nil
 }
 
@@ -28,3 +39,17 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,85 @@
+// Package dating - датировка произведения по найденным пигментам.
+//
+// Пигмент не может оказаться в красочном слое раньше, чем его начали
+// производить, поэтому самый поздний год появления среди найденных пигментов
+// даёт нижнюю границу даты создания (terminus post quem). Следовые количества
+// не учитываются: они чаще говорят о поздних реставрациях или загрязнении.
+package dating
+
+// MinPercent - доля пигмента, начиная с которой он участвует в датировке
+const MinPercent = 1.0
+
+// Причины, по которым пигмент противоречит заявленной дате
+const (
+	// ReasonNotYetAvailable - пигмент появился после заявленной даты
+	ReasonNotYetAvailable = "not_yet_available"
+	// ReasonDiscontinued - пигмент вышел из употребления до заявленной даты.
+	// Слабый признак: художник мог пользоваться старыми запасами
+	ReasonDiscontinued = "discontinued"
+)
+
+// PigmentUse - пигмент в анализе вместе с его долей и годами доступности
+type PigmentUse struct {
+	PigmentID     uint
+	Name          string
+	Percent       float64
+	AvailableFrom *int
+	AvailableTo   *int
+}
+
+// Terminus - вычисленная нижняя граница даты и пигмент, который её задаёт
+type Terminus struct {
+	Year      int
+	PigmentID uint
+	Name      string
+}
+
+// Anachronism - пигмент, несовместимый с заявленной датой
+type Anachronism struct {
+	PigmentID     uint
+	Name          string
+	Reason        string
+	AvailableFrom *int
+	AvailableTo   *int
+}
+
+// TerminusPostQuem возвращает самый поздний год появления среди значимых пигментов.
+// ok = false, если ни у одного значимого пигмента год появления не известен
+func TerminusPostQuem(uses []PigmentUse) (terminus Terminus, ok bool) {
+	for _, use := range uses {
+		if use.AvailableFrom == nil || use.Percent < MinPercent {
+			continue
+		}
+		if !ok || *use.AvailableFrom > terminus.Year {
+			terminus = Terminus{Year: *use.AvailableFrom, PigmentID: use.PigmentID, Name: use.Name}
+			ok = true
+		}
+	}
+	return terminus, ok
+}
+
+// Anachronisms находит пигменты, противоречащие заявленному году создания.
+// Доля пигмента здесь не важна: даже немного пигмента, которого ещё не существовало,
+// требует объяснения (например, поздней записи)
+func Anachronisms(claimedYear int, uses []PigmentUse) []Anachronism {
+	var result []Anachronism
+	for _, use := range uses {
+		reason := ""
+		switch {
+		case use.AvailableFrom != nil && claimedYear < *use.AvailableFrom:
+			reason = ReasonNotYetAvailable
+		case use.AvailableTo != nil && claimedYear > *use.AvailableTo:
+			reason = ReasonDiscontinued
+		default:
+			continue
+		}
+		result = append(result, Anachronism{
+			PigmentID:     use.PigmentID,
+			Name:          use.Name,
+			Reason:        reason,
+			AvailableFrom: use.AvailableFrom,
+			AvailableTo:   use.AvailableTo,
+		})
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package dating
+
+import "testing"
+
+func year(v int) *int { return &v }
+
+func TestTerminusPostQuem(t *testing.T) {
+	tests := []struct {
+		name   string
+		uses   []PigmentUse
+		want   int
+		wantID uint
+		wantOK bool
+	}{
+		{
+			name:   "no dated pigments",
+			uses:   []PigmentUse{{PigmentID: 1, Percent: 50}},
+			wantOK: false,
+		},
+		{
+			name: "latest introduction wins",
+			uses: []PigmentUse{
+				{PigmentID: 1, Percent: 40, AvailableFrom: year(1704)},
+				{PigmentID: 2, Percent: 30, AvailableFrom: year(1921)},
+				{PigmentID: 3, Percent: 30},
+			},
+			want: 1921, wantID: 2, wantOK: true,
+		},
+		{
+			name: "trace amounts are ignored",
+			uses: []PigmentUse{
+				{PigmentID: 1, Percent: 80, AvailableFrom: year(1704)},
+				{PigmentID: 2, Percent: 0.5, AvailableFrom: year(1935)},
+			},
+			want: 1704, wantID: 1, wantOK: true,
+		},
+		{
+			name: "ancient pigments",
+			uses: []PigmentUse{
+				{PigmentID: 1, Percent: 60, AvailableFrom: year(-2500)},
+				{PigmentID: 2, Percent: 40, AvailableFrom: year(-3000)},
+			},
+			want: -2500, wantID: 1, wantOK: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := TerminusPostQuem(tt.uses)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && (got.Year != tt.want || got.PigmentID != tt.wantID) {
+				t.Fatalf("got %+v, want year %d pigment %d", got, tt.want, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestAnachronisms(t *testing.T) {
+	uses := []PigmentUse{
+		{PigmentID: 1, Name: "Берлинская лазурь", Percent: 30, AvailableFrom: year(1704)},
+		{PigmentID: 2, Name: "Титановые белила", Percent: 0.3, AvailableFrom: year(1921)},
+		{PigmentID: 3, Name: "Египетская синяя", Percent: 20, AvailableFrom: year(-2600), AvailableTo: year(800)},
+		{PigmentID: 4, Name: "Охра", Percent: 50},
+	}
+
+	got := Anachronisms(1650, uses)
+	want := map[uint]string{1: ReasonNotYetAvailable, 2: ReasonNotYetAvailable, 3: ReasonDiscontinued}
+	if len(got) != len(want) {
+		t.Fatalf("got %d anachronisms, want %d: %+v", len(got), len(want), got)
+	}
+	for _, a := range got {
+		if want[a.PigmentID] != a.Reason {
+			t.Fatalf("pigment %d: reason %q, want %q", a.PigmentID, a.Reason, want[a.PigmentID])
+		}
+	}
+
+	if got := Anachronisms(1950, uses[:2]); len(got) != 0 {
+		t.Fatalf("1950: unexpected anachronisms %+v", got)
+	}
+	// Граничный год: пигмент уже доступен
+	if got := Anachronisms(1704, uses[:1]); len(got) != 0 {
+		t.Fatalf("1704: unexpected anachronisms %+v", got)
+	}
+}
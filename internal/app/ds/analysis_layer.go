@@ -0,0 +1,36 @@
+package ds
+
+import "github.com/google/uuid"
+
+// AnalysisLayer - слой поперечного шлифа пробы. Position считается от основы:
+// 1 - нижний слой (обычно грунт), последний - верхний (обычно лак)
+type AnalysisLayer struct {
+    ID                 uint      `gorm:"primaryKey;autoIncrement"`
+    SpectrumAnalysisID uuid.UUID `gorm:"type:uuid;index"`
+    Position           int
+    Kind               string   // Layer*
+    Name               string   // подпись слоя: "розовая имприматура"
+    Thickness          *float64 // мкм, nil - не измерялась
+    Binder             string   // связующее: "масло", "животный клей"
+    Note               string
+}
+
+// Виды слоёв
+const (
+    LayerGround     = "ground"
+    LayerUnderpaint = "underpaint"
+    LayerPaint      = "paint"
+    LayerGlaze      = "glaze"
+    LayerVarnish    = "varnish"
+    LayerOther      = "other"
+)
+
+// LayerKinds - допустимые виды слоёв
+var LayerKinds = []string{LayerGround, LayerUnderpaint, LayerPaint, LayerGlaze, LayerVarnish, LayerOther}
+
+// LayerPigment - пигмент слоя и его доля в слое
+type LayerPigment struct {
+    LayerID   uint `gorm:"primaryKey"`
+    PigmentID uint `gorm:"primaryKey"`
+    Percent   float64
+}
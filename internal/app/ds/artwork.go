@@ -0,0 +1,18 @@
+package ds
+
+import "time"
+
+// Artwork - произведение (картина, икона, фрагмент росписи), с которого берутся пробы
+type Artwork struct {
+    ID              uint   `gorm:"primaryKey;autoIncrement"`
+    Title           string
+    Artist          string
+    Date            string // датировка в свободной форме: "ок. 1650", "XVI в."
+    Year            *int   // год для сортировки и датировки; отрицательные - до н. э.
+    InventoryNumber string
+    Collection      string // музей или собрание-владелец
+    ImageKey        string
+    CreatorID       uint
+    CreatedAt       time.Time
+    UpdatedAt       time.Time
+}
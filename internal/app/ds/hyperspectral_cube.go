@@ -0,0 +1,44 @@
+package ds
+
+import (
+    "time"
+
+    "github.com/google/uuid"
+)
+
+// HyperspectralCube - гиперспектральный куб ENVI, загруженный к заявке. Данные куба
+// лежат на диске до конца обработки, результат - карты долей пигментов
+type HyperspectralCube struct {
+    ID                 uint      `gorm:"primaryKey;autoIncrement"`
+    SpectrumAnalysisID uuid.UUID `gorm:"type:uuid;index"`
+    CreatorID          uint
+    Name               string // имя загруженного файла заголовка
+    Header             string // текст заголовка .hdr
+    Samples            int
+    Lines              int
+    Bands              int
+    Status             string  // Cube*
+    Progress           float64 // доля обработанных строк, 0..1
+    Error              string  // причина неудачи для пользователя
+    CreatedAt          time.Time
+    StartedAt          *time.Time
+    FinishedAt         *time.Time
+}
+
+// Статусы обработки куба
+const (
+    CubeQueued  = "queued"
+    CubeRunning = "running"
+    CubeDone    = "done"
+    CubeFailed  = "failed"
+)
+
+// AbundanceMap - карта доли пигмента по пикселям куба (PNG в объектном хранилище)
+type AbundanceMap struct {
+    ID           uint `gorm:"primaryKey;autoIncrement"`
+    CubeID       uint `gorm:"index"`
+    PigmentID    uint
+    ImageKey     string
+    MeanFraction float64 // средняя доля по пикселям с данными
+    MaxFraction  float64
+}
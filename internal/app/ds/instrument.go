@@ -0,0 +1,32 @@
+package ds
+
+import "time"
+
+// Instrument - спектрометр, которым сняты измерения
+type Instrument struct {
+    ID         uint    `gorm:"primaryKey;autoIncrement"`
+    Name       string
+    Serial     string  // серийный номер, уникален
+    Technique  string  // spectra.Technique*
+    RangeFrom  float64 // рабочий диапазон в единицах оси методики
+    RangeTo    float64
+    Resolution float64 // спектральное разрешение в тех же единицах
+    Note       string
+    CreatedAt  time.Time
+    UpdatedAt  time.Time
+}
+
+// InstrumentCalibration - калибровка прибора, действующая с ValidFrom до ValidTo.
+// Спектры хранятся в формате измерений: номинальная ось прибора и сырые отсчёты
+type InstrumentCalibration struct {
+    ID               uint       `gorm:"primaryKey;autoIncrement"`
+    InstrumentID     uint       `gorm:"index"`
+    ValidFrom        time.Time
+    ValidTo          *time.Time // nil - бессрочно
+    Dark             string     // темновой сигнал; пусто - не вычитается
+    White            string     // сигнал белого эталона; пусто - без нормировки
+    WhiteReflectance float64    // коэффициент отражения белого эталона, 0 - считается 1
+    Wavelengths      string     // "номинальное:истинное" положение эталонных линий; пусто - ось не правится
+    Note             string
+    CreatedAt        time.Time
+}
@@ -0,0 +1,20 @@
+package ds
+
+import (
+    "time"
+
+    "github.com/google/uuid"
+)
+
+// MeasurementPoint - место на произведении, где взята проба анализа.
+// Координаты нормированы на размер фотографии произведения: (0, 0) - левый верхний угол, (1, 1) - правый нижний
+type MeasurementPoint struct {
+    SpectrumAnalysisID uuid.UUID `gorm:"type:uuid;primaryKey"`
+    ArtworkID          uint      // произведение, на фотографии которого отмечена точка
+    X                  float64
+    Y                  float64
+    Label              string // подпись на карте: "P3", "небо, левый край"
+    Layer              string // слой или глубина пробы: "лессировка", "грунт", "~50 мкм"
+    CreatedAt          time.Time
+    UpdatedAt          time.Time
+}
@@ -1,6 +1,10 @@
 package ds
 
-import "gorm.io/gorm"
+import (
+    "time"
+
+    "gorm.io/gorm"
+)
 
 type Pigment struct {
     ID          uint   `gorm:"primaryKey;autoIncrement"`
@@ -9,7 +13,43 @@ type Pigment struct {
     Description string
     ImageKey    string
     Color       string
-    Specs       string
-    CreatedAt   gorm.DeletedAt
-    UpdatedAt   gorm.DeletedAt
-}
\ No newline at end of file
+    Specs       string // свободный текст; структурированные данные - в полях ниже
+
+    CIName          string   `gorm:"column:ci_name"`    // Colour Index Generic Name, например PB29
+    CINumber        string   `gorm:"column:ci_number"`  // Colour Index Constitution Number, например 77007
+    Formula         string
+    CASNumber       string   `gorm:"column:cas_number"`
+    Class           string   // PigmentClass*
+    Lightfastness   *int     // ASTM D4303: 1 (I, превосходная) - 5 (V, очень плохая)
+    Toxicity        string   // Toxicity*
+    ParticleSizeMin *float64 `gorm:"column:particle_size_min_um"` // мкм
+    ParticleSizeMax *float64 `gorm:"column:particle_size_max_um"` // мкм
+    ParticleShape   string
+
+    AvailableFrom *int // год появления пигмента; отрицательные - до н. э.
+    AvailableTo   *int // год выхода из употребления, nil - используется до сих пор
+
+    CreatedAt   time.Time
+    UpdatedAt   time.Time
+    DeletedAt   gorm.DeletedAt `gorm:"index"` // архивный пигмент: скрыт из каталога, но виден в анализах
+}
+
+// Классы пигментов
+const (
+    PigmentClassEarth            = "earth"
+    PigmentClassMineral          = "mineral"
+    PigmentClassLake             = "lake"
+    PigmentClassSyntheticOrganic = "synthetic_organic"
+)
+
+// Уровни токсичности
+const (
+    ToxicityNone     = "none"
+    ToxicityLow      = "low"
+    ToxicityModerate = "moderate"
+    ToxicityHigh     = "high"
+)
+
+var PigmentClasses = []string{PigmentClassEarth, PigmentClassMineral, PigmentClassLake, PigmentClassSyntheticOrganic}
+
+var ToxicityLevels = []string{ToxicityNone, ToxicityLow, ToxicityModerate, ToxicityHigh}
@@ -0,0 +1,27 @@
+package ds
+
+import "time"
+
+// PigmentInteraction - правило справочника взаимодействий пигментов. Правило для пары
+// срабатывает, когда в анализе есть оба пигмента (PigmentID < OtherPigmentID); правило
+// без OtherPigmentID описывает деградацию самого пигмента
+type PigmentInteraction struct {
+    ID             uint  `gorm:"primaryKey;autoIncrement"`
+    PigmentID      uint  `gorm:"index"`
+    OtherPigmentID *uint `gorm:"index"`
+    Severity       string // Interaction*
+    Explanation    string // что происходит и при каких условиях
+    Products       string // продукты деградации, по одному на строку
+    CreatedAt      time.Time
+    UpdatedAt      time.Time
+}
+
+// Серьёзность взаимодействия
+const (
+    InteractionInfo    = "info"    // известное изменение, обычно не опасное
+    InteractionWarning = "warning" // возможное потемнение или выцветание
+    InteractionDanger  = "danger"  // разрушение красочного слоя
+)
+
+// InteractionSeverities - допустимые уровни серьёзности по возрастанию
+var InteractionSeverities = []string{InteractionInfo, InteractionWarning, InteractionDanger}
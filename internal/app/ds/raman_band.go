@@ -0,0 +1,11 @@
+package ds
+
+// RamanBand - характеристическая полоса пигмента в рамановском спектре
+type RamanBand struct {
+    ID         uint    `gorm:"primaryKey;autoIncrement"`
+    PigmentID  uint    `gorm:"index"`
+    Position   float64 // см⁻¹
+    Tolerance  float64 // допустимое отклонение положения пика образца, см⁻¹
+    Strength   string  // identify.Strength*
+    Assignment string  // колебание, которому соответствует полоса
+}
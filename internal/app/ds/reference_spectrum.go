@@ -0,0 +1,19 @@
+package ds
+
+import (
+    "time"
+)
+
+// ReferenceSpectrum - эталонный спектр пигмента, с которым сравниваются измерения той же методики
+type ReferenceSpectrum struct {
+    ID        uint   `gorm:"primaryKey;autoIncrement"`
+    PigmentID uint   `gorm:"index"`
+    Technique string // spectra.Technique*
+    Spectrum  string
+    Source    string // откуда взят эталон: база данных, публикация, собственное измерение
+    CreatedAt time.Time
+
+    // Features - признаки для индекса ближайших эталонов (refindex.ReferenceFeatures);
+    // nil - эталон не индексируется
+    Features []byte
+}
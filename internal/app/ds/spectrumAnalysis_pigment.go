@@ -5,11 +5,26 @@ import (
     "github.com/google/uuid"
 )
 
+// Решения модератора по идентификации пигмента
+const (
+    VerdictAccepted = "accepted"
+    VerdictRejected = "rejected"
+)
+
 type SpectrumAnalysisPigment struct {
     SpectrumAnalysisID   uuid.UUID `gorm:"primaryKey"`
     PigmentID   uint      `gorm:"primaryKey"`
     Comment     string
     Percent     float64
+    // Доверительный интервал доли по бутстрепу; nil, если долю не удалось оценить по спектру
+    PercentLow  *float64
+    PercentHigh *float64
+    // Пигменты заявки, которые спектрально неотличимы от этого: ID через запятую
+    Indistinguishable string
+    // Решение модератора по рамановской идентификации пигмента при завершении заявки
+    Verdict     string
+    VerdictNote string
+    RamanScore  *float64
     CreatedAt   time.Time
 }
 
@@ -14,9 +14,26 @@ type SpectrumAnalysis struct {  // БЫЛО: AnalysisRequest
     FormedAt    *time.Time
     CompletedAt *time.Time
     ModeratorID *uint
-    Spectrum    string
+    Spectrum    []byte // одиночный спектр в формате spectra.Packed; если есть SpectrumReading, используются они
+    SpectrumUnparsed bool // текстовый спектр не разобрался при переходе на spectra.Packed: он в spectrum_analysis_unparsed
+    OutlierMethod    string   // правило отбраковки повторных измерений, пусто - по умолчанию
+    OutlierThreshold *float64
+    Preprocessing    string   // шаги предобработки spectra.Pipeline в JSON, пусто - без предобработки
+    ArtworkID   *uint // произведение, с которого взята проба
+    ClaimedYear      *int // заявленный год создания произведения
+    TerminusPostQuem *int // нижняя граница датировки по пигментам, вычисляется при завершении
+    Version     uint `gorm:"not null;default:1"` // растёт при каждом изменении, отдаётся как ETag
 }
 
+// Статусы заявки
+const (
+    StatusDraft     = "draft"
+    StatusCreated   = "created"
+    StatusCompleted = "completed"
+    StatusRejected  = "rejected"
+    StatusDeleted   = "deleted"
+)
+
 // Явно указываем имя таблицы
 func (SpectrumAnalysis) TableName() string {
     return "spectrum_analysis"  // БЫЛО: "analysis_requests"
@@ -0,0 +1,25 @@
+package ds
+
+import (
+    "time"
+
+    "github.com/google/uuid"
+)
+
+// SpectrumReading - одно из повторных измерений спектра в точке отбора пробы
+type SpectrumReading struct {
+    ID                 uint      `gorm:"primaryKey;autoIncrement"`
+    SpectrumAnalysisID uuid.UUID `gorm:"type:uuid;index"`
+    Technique          string    // spectra.Technique*, от неё зависят единицы оси
+    Spectrum           string    // "400:0.12,410:0.15,..."
+    Note               string
+    CreatedAt          time.Time
+
+    // Измерения прибора загружаются сырыми отсчётами (Raw) и исправляются калибровкой,
+    // действовавшей в MeasuredAt. CalibrationID = nil у измерения прибора - подходящей
+    // калибровки не нашлось, Spectrum содержит неисправленные отсчёты
+    InstrumentID  *uint
+    CalibrationID *uint
+    MeasuredAt    *time.Time
+    Raw           string
+}
@@ -6,12 +6,16 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// legacyUserID - HTML-интерфейс работает без аутентификации от имени одного пользователя
+const legacyUserID uint = 1
+
 type Handler struct {
-	Repository *repository.Repository
+	Pigments repository.PigmentStore
+	Analyses repository.AnalysisStore
 }
 
-func NewHandler(r *repository.Repository) *Handler {
-	return &Handler{Repository: r}
+func NewHandler(pigments repository.PigmentStore, analyses repository.AnalysisStore) *Handler {
+	return &Handler{Pigments: pigments, Analyses: analyses}
 }
 
 func (h *Handler) RegisterHandler(router *gin.Engine) {
@@ -2,8 +2,10 @@ package handler
 
 import (
 	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository"
 	"net/http"
 	"os"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,16 +14,10 @@ func (h *Handler) GetPigments(ctx *gin.Context) {
 	minioBase := getMinioBase()
 	q := ctx.Query("search")
 
-	var pigments []ds.Pigment
-	if q == "" {
-		h.Repository.GetDB().Unscoped().Find(&pigments)
-	} else {
-		h.Repository.GetDB().Unscoped().Where("name ILIKE ?", "%"+q+"%").Find(&pigments)
-	}
+	pigments, _ := h.Pigments.ListPigments(ctx.Request.Context(), repository.PigmentQuery{Search: q})
 
 	// Ищем активную заявку-черновик (может не быть)
-	var spectrumAnalysis ds.SpectrumAnalysis
-	err := h.Repository.GetDB().Where("status = ?", "draft").First(&spectrumAnalysis).Error
+	spectrumAnalysis, err := h.Analyses.FindDraft(ctx.Request.Context(), legacyUserID)
 
 	var count int64 = 0
 	var spectrumAnalysisID string
@@ -29,7 +25,7 @@ func (h *Handler) GetPigments(ctx *gin.Context) {
 
 	if err == nil {
 		// Есть активная заявка
-		h.Repository.GetDB().Model(&ds.SpectrumAnalysisPigment{}).Where("spectrum_analysis_id = ?", spectrumAnalysis.ID).Count(&count)
+		count, _ = h.Analyses.CountAnalysisPigments(ctx.Request.Context(), spectrumAnalysis.ID)
 		spectrumAnalysisID = spectrumAnalysis.ID.String()
 		hasActiveCart = true
 	}
@@ -46,10 +42,13 @@ func (h *Handler) GetPigments(ctx *gin.Context) {
 
 func (h *Handler) GetPigment(ctx *gin.Context) {
 	minioBase := getMinioBase()
-	id := ctx.Param("id")
+	id, _ := strconv.ParseUint(ctx.Param("id"), 10, 32)
 
+	// архивный пигмент может открываться из старого анализа
 	var pigment ds.Pigment
-	h.Repository.GetDB().Unscoped().First(&pigment, id) // ✅ Добавляем Unscoped
+	if found, err := h.Pigments.GetPigment(ctx.Request.Context(), uint(id), true); err == nil {
+		pigment = *found
+	}
 
 	ctx.HTML(http.StatusOK, "Pigment.html", gin.H{
 		"Pigment":   pigment,
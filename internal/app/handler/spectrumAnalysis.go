@@ -2,11 +2,14 @@ package handler
 
 import (
 	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type PigmentView struct {
@@ -32,10 +35,8 @@ func (h *Handler) GetSpectrumAnalysis(ctx *gin.Context) {
 		return
 	}
 
-	var spectrumAnalysis ds.SpectrumAnalysis
-	result := h.Repository.GetDB().Unscoped().Where("id = ?", id).First(&spectrumAnalysis)
-
-	if result.Error != nil {
+	analysisID, err := uuid.Parse(id)
+	if err != nil {
 		ctx.HTML(http.StatusOK, "AnalysisRequest.html", gin.H{
 			"MinioBase":      minioBase,
 			"RequestDeleted": true,
@@ -43,7 +44,8 @@ func (h *Handler) GetSpectrumAnalysis(ctx *gin.Context) {
 		return
 	}
 
-	if spectrumAnalysis.Status == "deleted" {
+	spectrumAnalysis, err := h.Analyses.GetAnalysis(ctx.Request.Context(), analysisID)
+	if err != nil || spectrumAnalysis.Status == ds.StatusDeleted {
 		ctx.HTML(http.StatusOK, "AnalysisRequest.html", gin.H{
 			"MinioBase":      minioBase,
 			"RequestDeleted": true,
@@ -51,42 +53,27 @@ func (h *Handler) GetSpectrumAnalysis(ctx *gin.Context) {
 		return
 	}
 
-	var pigments []ds.Pigment
-	result = h.Repository.GetDB().
-		Joins("JOIN spectrumAnalysis_pigment ON spectrumAnalysis_pigment.pigment_id = pigments.id").
-		Where("spectrumAnalysis_pigment.spectrum_analysis_id = ?", id).
-		Find(&pigments)
-
-	if result.Error != nil {
+	// Архивные пигменты продолжают отображаться в анализах
+	analysisPigments, err := h.Analyses.ListAnalysisPigments(ctx.Request.Context(), analysisID)
+	if err != nil {
 		ctx.String(http.StatusInternalServerError, "Ошибка загрузки пигментов")
 		return
 	}
 
-	var spectrumAnalysisPigments []ds.SpectrumAnalysisPigment
-	h.Repository.GetDB().Where("spectrum_analysis_id = ?", id).Find(&spectrumAnalysisPigments)
-
-	pigmentViews := make([]PigmentView, len(pigments))
-	for i, pig := range pigments {
+	pigmentViews := make([]PigmentView, len(analysisPigments))
+	for i, ap := range analysisPigments {
 		pigmentViews[i] = PigmentView{
-			ID:       pig.ID,
-			Name:     pig.Name,
-			Brief:    pig.Brief,
-			ImageKey: pig.ImageKey,
-			Comment:  "",
-			Percent:  0.0,
-		}
-
-		for _, rp := range spectrumAnalysisPigments {
-			if rp.PigmentID == pig.ID {
-				pigmentViews[i].Comment = rp.Comment
-				pigmentViews[i].Percent = rp.Percent
-				break
-			}
+			ID:       ap.Pigment.ID,
+			Name:     ap.Pigment.Name,
+			Brief:    ap.Pigment.Brief,
+			ImageKey: ap.Pigment.ImageKey,
+			Comment:  ap.Link.Comment,
+			Percent:  ap.Link.Percent,
 		}
 	}
 
 	ctx.HTML(http.StatusOK, "AnalysisRequest.html", gin.H{
-		"SpectrumAnalysis": spectrumAnalysis, // ✅ НОВОЕ ИМЯ
+		"SpectrumAnalysis": *spectrumAnalysis, // ✅ НОВОЕ ИМЯ
 		"Pigments":         pigmentViews,
 		"MinioBase":        minioBase,
 		"RequestDeleted":   false,
@@ -99,64 +86,27 @@ func (h *Handler) AddPigmentToSpectrumAnalysis(ctx *gin.Context) {
 
 	fmt.Printf("🔍 DEBUG: AddPigmentToSpectrumAnalysis called with pigment ID: %s\n", pigmentIDStr)
 
-	// Ищем активную заявку-черновик
-	var spectrumAnalysis ds.SpectrumAnalysis
-	result := h.Repository.GetDB().Where("status = ?", "draft").First(&spectrumAnalysis)
-
-	if result.Error != nil {
-		fmt.Printf("❌ DEBUG: No draft spectrum analysis found: %v\n", result.Error)
-		fmt.Printf("🔄 DEBUG: Creating new spectrum analysis...\n")
-
-		// Если нет черновика - создаем новый
-		spectrumAnalysis = ds.SpectrumAnalysis{
-			Name:      "Новый анализ спектра",
-			Status:    "draft",
-			CreatorID: 1,
-			Spectrum:  "",
-		}
-		if err := h.Repository.GetDB().Create(&spectrumAnalysis).Error; err != nil {
-			fmt.Printf("❌ DEBUG: Error creating spectrum analysis: %v\n", err)
-			ctx.Redirect(http.StatusFound, "/pigments")
-			return
-		}
-		fmt.Printf("✅ DEBUG: Created new spectrum analysis: %s\n", spectrumAnalysis.ID.String())
-	} else {
-		fmt.Printf("✅ DEBUG: Found existing spectrum analysis: %s\n", spectrumAnalysis.ID.String())
-	}
-
-	// Проверяем существующую связь
-	var existing ds.SpectrumAnalysisPigment
-	result = h.Repository.GetDB().
-		Where("spectrum_analysis_id = ? AND pigment_id = ?", spectrumAnalysis.ID, pigmentID).
-		First(&existing)
-
-	if result.Error != nil {
-		fmt.Printf("🔄 DEBUG: Creating new spectrum analysis pigment link...\n")
-		// Связи нет - создаем новую
-		spectrumAnalysisPigment := ds.SpectrumAnalysisPigment{
-			SpectrumAnalysisID: spectrumAnalysis.ID,
-			PigmentID:          uint(pigmentID),
-			Comment:            "",
-			Percent:            0.0,
-		}
-		if err := h.Repository.GetDB().Create(&spectrumAnalysisPigment).Error; err != nil {
-			fmt.Printf("❌ DEBUG: Error creating spectrum analysis pigment: %v\n", err)
-		} else {
-			fmt.Printf("✅ DEBUG: Successfully added pigment %d to spectrum analysis %s\n", pigmentID, spectrumAnalysis.ID.String())
-		}
-	} else {
-		fmt.Printf("ℹ️ DEBUG: Pigment %d already in spectrum analysis %s\n", pigmentID, spectrumAnalysis.ID.String())
+	// Черновик создаётся при первом добавлении пигмента
+	spectrumAnalysis, _, err := h.Analyses.AddPigmentToDraft(ctx.Request.Context(), legacyUserID, uint(pigmentID))
+	switch {
+	case errors.Is(err, repository.ErrAlreadyExists):
+		fmt.Printf("ℹ️ DEBUG: Pigment %d already in spectrum analysis\n", pigmentID)
+	case err != nil:
+		fmt.Printf("❌ DEBUG: Error adding pigment to spectrum analysis: %v\n", err)
+	default:
+		fmt.Printf("✅ DEBUG: Successfully added pigment %d to spectrum analysis %s\n", pigmentID, spectrumAnalysis.ID.String())
 	}
 
 	ctx.Redirect(http.StatusFound, "/pigments")
 }
 
 func (h *Handler) DeleteSpectrumAnalysis(ctx *gin.Context) {
-	requestID := ctx.PostForm("id")
-
-	sqlDB, err := h.Repository.GetDB().DB()
+	requestID, err := uuid.Parse(ctx.PostForm("id"))
 	if err == nil {
-		sqlDB.Exec("UPDATE spectrum_analysis SET status = 'deleted' WHERE id = $1", requestID)
+		if spectrumAnalysis, err := h.Analyses.GetAnalysis(ctx.Request.Context(), requestID); err == nil {
+			spectrumAnalysis.Status = ds.StatusDeleted
+			h.Analyses.UpdateAnalysis(ctx.Request.Context(), spectrumAnalysis)
+		}
 	}
 
 	// НЕ создаем новую заявку автоматически - она создастся только при добавлении пигмента
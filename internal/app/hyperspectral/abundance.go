@@ -0,0 +1,185 @@
+package hyperspectral
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"runtime"
+	"sync"
+
+	"colorLex/internal/app/unmix"
+)
+
+// MaxEndmembers - наибольшее число пигментов, по которым раскладывается куб
+const MaxEndmembers = 12
+
+// ErrTooManyEndmembers - пигментов больше MaxEndmembers
+var ErrTooManyEndmembers = errors.New("too many endmembers")
+
+// Abundances - доли пигментов в каждом пикселе куба
+type Abundances struct {
+	Width, Height int
+	PigmentIDs    []uint
+	// Fractions[i][y*Width+x] - доля пигмента PigmentIDs[i] в пикселе (x, y)
+	Fractions [][]float32
+	RMSE      []float32 // остаток подгонки по пикселям
+	// Valid[p] = false - в пикселе нет данных, доли нулевые
+	Valid []bool
+}
+
+// Workers - число потоков разложения по умолчанию: половина ядер, чтобы API оставалось отзывчивым
+func Workers() int {
+	return max(1, runtime.NumCPU()/2)
+}
+
+// Unmix раскладывает каждый пиксель куба по эталонам. Строки делятся между workers
+// потоками; progress вызывается последовательно после каждой готовой строки
+func Unmix(ctx context.Context, cube *Cube, endmembers []unmix.Endmember, workers int, progress func(done, total int)) (*Abundances, error) {
+	if len(endmembers) > MaxEndmembers {
+		return nil, fmt.Errorf("%w: %d, at most %d allowed", ErrTooManyEndmembers, len(endmembers), MaxEndmembers)
+	}
+	model, err := unmix.NewModel(cube.Wavelengths, endmembers)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	pixels := cube.Samples * cube.Lines
+	result := &Abundances{
+		Width:      cube.Samples,
+		Height:     cube.Lines,
+		PigmentIDs: make([]uint, len(endmembers)),
+		Fractions:  make([][]float32, len(endmembers)),
+		RMSE:       make([]float32, pixels),
+		Valid:      make([]bool, pixels),
+	}
+	for i, endmember := range endmembers {
+		result.PigmentIDs[i] = endmember.PigmentID
+		result.Fractions[i] = make([]float32, pixels)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	lines := make(chan int)
+	done := make(chan error)
+	var wg sync.WaitGroup
+	for w := 0; w < max(1, workers); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			line := make([][]float64, cube.Samples)
+			for x := range line {
+				line[x] = make([]float64, cube.Bands)
+			}
+			observed := make([]float64, model.Bands())
+			for y := range lines {
+				err := cube.ReadLine(y, line)
+				if err == nil {
+					result.unmixLine(model, y, line, observed)
+				}
+				select {
+				case done <- err:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(lines)
+		for y := 0; y < cube.Lines; y++ {
+			select {
+			case lines <- y:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var failure error
+	for finished := 0; finished < cube.Lines && failure == nil; finished++ {
+		select {
+		case err := <-done:
+			if err != nil {
+				failure = fmt.Errorf("read line: %w", err)
+			} else if progress != nil {
+				progress(finished+1, cube.Lines)
+			}
+		case <-ctx.Done():
+			failure = ctx.Err()
+		}
+	}
+	cancel()
+	wg.Wait()
+	if failure != nil {
+		return nil, failure
+	}
+	return result, nil
+}
+
+// unmixLine записывает доли пикселей строки y. Потоки пишут в разные строки, блокировка не нужна
+func (a *Abundances) unmixLine(model *unmix.Model, y int, line [][]float64, observed []float64) {
+	for x, values := range line {
+		if !finite(values) {
+			continue
+		}
+		p := y*a.Width + x
+		fractions, rmse := model.Fractions(values, observed)
+		for i, fraction := range fractions {
+			a.Fractions[i][p] = float32(fraction)
+		}
+		a.RMSE[p] = float32(rmse)
+		a.Valid[p] = true
+	}
+}
+
+func finite(values []float64) bool {
+	for _, value := range values {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// Stats - средняя и наибольшая доля пигмента i по пикселям с данными
+func (a *Abundances) Stats(i int) (mean, peak float64) {
+	var count int
+	for p, fraction := range a.Fractions[i] {
+		if !a.Valid[p] {
+			continue
+		}
+		mean += float64(fraction)
+		peak = max(peak, float64(fraction))
+		count++
+	}
+	if count == 0 {
+		return 0, 0
+	}
+	return mean / float64(count), peak
+}
+
+// PNG - карта доли пигмента i в оттенках серого: белый - 100%, чёрный - 0%.
+// Пиксели без данных прозрачны
+func (a *Abundances) PNG(i int) ([]byte, error) {
+	img := image.NewNRGBA(image.Rect(0, 0, a.Width, a.Height))
+	for p, fraction := range a.Fractions[i] {
+		if !a.Valid[p] {
+			continue
+		}
+		level := uint8(math.Round(255 * math.Min(1, math.Max(0, float64(fraction)))))
+		img.SetNRGBA(p%a.Width, p/a.Width, color.NRGBA{R: level, G: level, B: level, A: 255})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
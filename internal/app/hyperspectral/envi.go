@@ -0,0 +1,301 @@
+// Package hyperspectral - гиперспектральные кубы в формате ENVI (заголовок .hdr и
+// двоичные данные) и карты долей пигментов по пикселям.
+//
+// Каждый пиксель куба раскладывается по эталонам отражения пигментов так же, как
+// точечный спектр заявки (пакет unmix). Размер куба и число потоков ограничены,
+// чтобы обработка шла на обычном сервере без GPU.
+package hyperspectral
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Ограничения куба
+const (
+	// MaxPixels - наибольшее число пикселей (samples × lines)
+	MaxPixels = 4_000_000
+	// MaxBands - наибольшее число каналов
+	MaxBands = 512
+	// MaxDataSize - наибольший объём данных куба, байт
+	MaxDataSize = 2 << 30
+)
+
+// Порядок хранения каналов
+const (
+	InterleaveBSQ = "bsq" // канал за каналом
+	InterleaveBIL = "bil" // строка за строкой, внутри строки канал за каналом
+	InterleaveBIP = "bip" // пиксель за пикселем
+)
+
+// ErrHeader - заголовок не описывает поддерживаемый куб
+var ErrHeader = errors.New("invalid ENVI header")
+
+// sampleSizes - размер отсчёта в байтах по коду "data type" ENVI
+var sampleSizes = map[int]int{
+	1:  1, // uint8
+	2:  2, // int16
+	3:  4, // int32
+	4:  4, // float32
+	5:  8, // float64
+	12: 2, // uint16
+	13: 4, // uint32
+}
+
+// Header - поля заголовка ENVI, нужные для чтения куба
+type Header struct {
+	Samples      int // ширина, пикселей
+	Lines        int // высота, пикселей
+	Bands        int
+	HeaderOffset int64 // байт перед данными
+	DataType     int
+	Interleave   string
+	BigEndian    bool
+	Wavelengths  []float64 // нм, по каналам
+	// Scale - делитель отсчётов ("reflectance scale factor"), 1 - отсчёты уже в долях
+	Scale float64
+	// Ignore - значение "data ignore value": пиксель, где оно во всех каналах, пропускается
+	Ignore *float64
+}
+
+// ParseHeader читает заголовок ENVI. Длины волн в микрометрах переводятся в нанометры
+func ParseHeader(r io.Reader) (Header, error) {
+	fields, err := readFields(r)
+	if err != nil {
+		return Header{}, err
+	}
+
+	header := Header{DataType: -1, Interleave: InterleaveBSQ, Scale: 1}
+	for _, field := range []struct {
+		key   string
+		value *int
+	}{{"samples", &header.Samples}, {"lines", &header.Lines}, {"bands", &header.Bands}, {"data type", &header.DataType}} {
+		text, ok := fields[field.key]
+		if !ok {
+			return Header{}, fmt.Errorf("%w: no %q", ErrHeader, field.key)
+		}
+		if *field.value, err = strconv.Atoi(text); err != nil {
+			return Header{}, fmt.Errorf("%w: %s = %q", ErrHeader, field.key, text)
+		}
+	}
+	if text, ok := fields["header offset"]; ok {
+		if header.HeaderOffset, err = strconv.ParseInt(text, 10, 64); err != nil || header.HeaderOffset < 0 {
+			return Header{}, fmt.Errorf("%w: header offset = %q", ErrHeader, text)
+		}
+	}
+	if text, ok := fields["interleave"]; ok {
+		header.Interleave = strings.ToLower(text)
+	}
+	if text, ok := fields["byte order"]; ok {
+		header.BigEndian = text == "1"
+	}
+	if text, ok := fields["reflectance scale factor"]; ok {
+		if header.Scale, err = strconv.ParseFloat(text, 64); err != nil || header.Scale <= 0 {
+			return Header{}, fmt.Errorf("%w: reflectance scale factor = %q", ErrHeader, text)
+		}
+	}
+	if text, ok := fields["data ignore value"]; ok {
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return Header{}, fmt.Errorf("%w: data ignore value = %q", ErrHeader, text)
+		}
+		header.Ignore = &value
+	}
+
+	text, ok := fields["wavelength"]
+	if !ok {
+		return Header{}, fmt.Errorf("%w: no wavelengths", ErrHeader)
+	}
+	for _, item := range strings.Split(text, ",") {
+		wavelength, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+		if err != nil {
+			return Header{}, fmt.Errorf("%w: wavelength %q", ErrHeader, item)
+		}
+		header.Wavelengths = append(header.Wavelengths, wavelength)
+	}
+	if micrometers(fields["wavelength units"], header.Wavelengths) {
+		for i := range header.Wavelengths {
+			header.Wavelengths[i] *= 1000
+		}
+	}
+	return header, header.Validate()
+}
+
+// Validate проверяет, что куб поддерживается и укладывается в ограничения
+func (h Header) Validate() error {
+	switch {
+	case h.Samples <= 0 || h.Lines <= 0 || h.Bands <= 0:
+		return fmt.Errorf("%w: empty cube %d×%d×%d", ErrHeader, h.Samples, h.Lines, h.Bands)
+	case h.Samples*h.Lines > MaxPixels:
+		return fmt.Errorf("%w: %d×%d pixels, at most %d allowed", ErrHeader, h.Samples, h.Lines, MaxPixels)
+	case h.Bands > MaxBands:
+		return fmt.Errorf("%w: %d bands, at most %d allowed", ErrHeader, h.Bands, MaxBands)
+	case sampleSizes[h.DataType] == 0:
+		return fmt.Errorf("%w: unsupported data type %d", ErrHeader, h.DataType)
+	case h.Interleave != InterleaveBSQ && h.Interleave != InterleaveBIL && h.Interleave != InterleaveBIP:
+		return fmt.Errorf("%w: unsupported interleave %q", ErrHeader, h.Interleave)
+	case len(h.Wavelengths) != h.Bands:
+		return fmt.Errorf("%w: %d wavelengths for %d bands", ErrHeader, len(h.Wavelengths), h.Bands)
+	case h.DataSize()-h.HeaderOffset > MaxDataSize:
+		return fmt.Errorf("%w: %d bytes of data, at most %d allowed", ErrHeader, h.DataSize()-h.HeaderOffset, MaxDataSize)
+	}
+	return nil
+}
+
+// DataSize - ожидаемый размер файла данных с учётом смещения
+func (h Header) DataSize() int64 {
+	return h.HeaderOffset + int64(h.Samples)*int64(h.Lines)*int64(h.Bands)*int64(sampleSizes[h.DataType])
+}
+
+// readFields разбирает строки "ключ = значение"; значения в фигурных скобках могут
+// занимать несколько строк. Ключи приводятся к нижнему регистру, скобки снимаются
+func readFields(r io.Reader) (map[string]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "ENVI" {
+		return nil, fmt.Errorf("%w: missing ENVI signature", ErrHeader)
+	}
+
+	fields := make(map[string]string)
+	var key string
+	var value strings.Builder
+	open := false // внутри незакрытой фигурной скобки
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !open {
+			name, rest, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			key = strings.ToLower(strings.Join(strings.Fields(name), " "))
+			value.Reset()
+			line = strings.TrimSpace(rest)
+			if strings.HasPrefix(line, "{") {
+				open = true
+				line = line[1:]
+			}
+		}
+		if open {
+			if before, _, closed := strings.Cut(line, "}"); closed {
+				line, open = before, false
+			}
+			value.WriteString(line)
+			value.WriteByte(' ')
+		} else {
+			value.WriteString(line)
+		}
+		if !open {
+			fields[key] = strings.TrimSpace(value.String())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if open {
+		return nil, fmt.Errorf("%w: unclosed brace in %q", ErrHeader, key)
+	}
+	return fields, nil
+}
+
+// micrometers определяет, что длины волн заданы в микрометрах: по единицам, а без них -
+// по величине (видимый диапазон в нанометрах не бывает меньше 100)
+func micrometers(units string, wavelengths []float64) bool {
+	switch strings.ToLower(units) {
+	case "micrometers", "micrometer", "microns", "um", "µm":
+		return true
+	case "":
+		return len(wavelengths) > 0 && wavelengths[len(wavelengths)-1] < 100
+	}
+	return false
+}
+
+// Cube - куб поверх файла данных. Чтение по строкам, весь куб в память не загружается
+type Cube struct {
+	Header
+	data  io.ReaderAt
+	size  int
+	order binary.ByteOrder
+}
+
+// Open связывает заголовок с данными. size - длина данных, если известна (иначе < 0)
+func Open(header Header, data io.ReaderAt, size int64) (*Cube, error) {
+	if err := header.Validate(); err != nil {
+		return nil, err
+	}
+	if size >= 0 && size < header.DataSize() {
+		return nil, fmt.Errorf("%w: data has %d bytes, header describes %d", ErrHeader, size, header.DataSize())
+	}
+	cube := &Cube{Header: header, data: data, size: sampleSizes[header.DataType], order: binary.LittleEndian}
+	if header.BigEndian {
+		cube.order = binary.BigEndian
+	}
+	return cube, nil
+}
+
+// ReadLine читает строку y: dst[x][b] - отсчёт пикселя x в канале b, делённый на Scale.
+// Пиксели со значением Ignore во всех каналах заполняются NaN
+func (c *Cube) ReadLine(y int, dst [][]float64) error {
+	width := c.Samples * c.size
+	buf := make([]byte, width*c.Bands)
+	switch c.Interleave {
+	case InterleaveBSQ:
+		for b := 0; b < c.Bands; b++ {
+			offset := c.HeaderOffset + (int64(b)*int64(c.Lines)+int64(y))*int64(width)
+			if _, err := c.data.ReadAt(buf[b*width:(b+1)*width], offset); err != nil {
+				return err
+			}
+		}
+	default:
+		if _, err := c.data.ReadAt(buf, c.HeaderOffset+int64(y)*int64(len(buf))); err != nil {
+			return err
+		}
+	}
+
+	for x := 0; x < c.Samples; x++ {
+		ignored := c.Ignore != nil
+		for b := 0; b < c.Bands; b++ {
+			var i int // номер отсчёта в buf
+			if c.Interleave == InterleaveBIP {
+				i = x*c.Bands + b
+			} else {
+				i = b*c.Samples + x
+			}
+			value := c.decode(buf[i*c.size : (i+1)*c.size])
+			if ignored && value != *c.Ignore {
+				ignored = false
+			}
+			dst[x][b] = value / c.Scale
+		}
+		if ignored {
+			for b := range dst[x] {
+				dst[x][b] = math.NaN()
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Cube) decode(raw []byte) float64 {
+	switch c.DataType {
+	case 1:
+		return float64(raw[0])
+	case 2:
+		return float64(int16(c.order.Uint16(raw)))
+	case 3:
+		return float64(int32(c.order.Uint32(raw)))
+	case 4:
+		return float64(math.Float32frombits(c.order.Uint32(raw)))
+	case 5:
+		return math.Float64frombits(c.order.Uint64(raw))
+	case 12:
+		return float64(c.order.Uint16(raw))
+	default: // 13
+		return float64(c.order.Uint32(raw))
+	}
+}
@@ -0,0 +1,306 @@
+package hyperspectral
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image/png"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/repository/memstore"
+	"colorLex/internal/app/spectra"
+	"colorLex/internal/app/unmix"
+)
+
+var (
+	bandWavelengths = []float64{400, 450, 500, 550, 600, 650}
+	red             = []float64{0.1, 0.15, 0.2, 0.6, 0.8, 0.85}
+	blue            = []float64{0.6, 0.55, 0.4, 0.2, 0.1, 0.1}
+)
+
+func curve(values []float64) spectra.Spectrum {
+	s := make(spectra.Spectrum, len(values))
+	for i, value := range values {
+		s[i] = spectra.Point{Wavelength: bandWavelengths[i], Value: value}
+	}
+	return s
+}
+
+// testCube собирает куб width×height, где доля красного растёт слева направо.
+// Пиксель (0, 0) помечен как пустой значением -1
+func testCube(t *testing.T, width, height int, interleave string, bigEndian bool) (Header, []byte) {
+	t.Helper()
+	header := Header{
+		Samples: width, Lines: height, Bands: len(bandWavelengths),
+		DataType: 2, Interleave: interleave, BigEndian: bigEndian,
+		Wavelengths: bandWavelengths, Scale: 10000,
+	}
+	ignore := -1.0
+	header.Ignore = &ignore
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	data := make([]byte, 2*width*height*header.Bands)
+	put := func(x, y, b int, value int16) {
+		var i int
+		switch interleave {
+		case InterleaveBSQ:
+			i = (b*height+y)*width + x
+		case InterleaveBIL:
+			i = (y*header.Bands+b)*width + x
+		default:
+			i = (y*width+x)*header.Bands + b
+		}
+		order.PutUint16(data[2*i:], uint16(value))
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			share := float64(x) / float64(width-1)
+			for b := range bandWavelengths {
+				value := int16(math.Round(10000 * (share*red[b] + (1-share)*blue[b])))
+				if x == 0 && y == 0 {
+					value = -1
+				}
+				put(x, y, b, value)
+			}
+		}
+	}
+	return header, data
+}
+
+func TestParseHeader(t *testing.T) {
+	text := `ENVI
+description = {
+  Scan of panel 3, = signs inside braces are allowed}
+samples = 4
+lines   = 3
+bands   = 3
+header offset = 16
+file type = ENVI Standard
+data type = 12
+interleave = BIL
+byte order = 1
+wavelength units = Micrometers
+reflectance scale factor = 10000
+data ignore value = 0
+wavelength = {
+ 0.45, 0.55,
+ 0.65 }
+`
+	header, err := ParseHeader(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Samples != 4 || header.Lines != 3 || header.Bands != 3 || header.HeaderOffset != 16 ||
+		header.DataType != 12 || header.Interleave != InterleaveBIL || !header.BigEndian ||
+		header.Scale != 10000 || header.Ignore == nil || *header.Ignore != 0 {
+		t.Fatalf("unexpected header %+v", header)
+	}
+	if len(header.Wavelengths) != 3 || math.Abs(header.Wavelengths[2]-650) > 1e-9 {
+		t.Fatalf("wavelengths %v", header.Wavelengths)
+	}
+	if header.DataSize() != 16+4*3*3*2 {
+		t.Fatalf("data size %d", header.DataSize())
+	}
+
+	for name, broken := range map[string]string{
+		"no signature":    strings.TrimPrefix(text, "ENVI\n"),
+		"too many pixels": strings.Replace(text, "samples = 4", "samples = 4000000", 1),
+		"complex data":    strings.Replace(text, "data type = 12", "data type = 6", 1),
+		"wavelengths":     strings.Replace(text, " 0.65 }", "}", 1),
+		"unclosed brace":  strings.Replace(text, " 0.65 }", " 0.65", 1),
+	} {
+		if _, err := ParseHeader(strings.NewReader(broken)); !errors.Is(err, ErrHeader) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+}
+
+func TestUnmix(t *testing.T) {
+	endmembers := []unmix.Endmember{{PigmentID: 7, Spectrum: curve(red)}, {PigmentID: 9, Spectrum: curve(blue)}}
+	for _, interleave := range []string{InterleaveBSQ, InterleaveBIL, InterleaveBIP} {
+		t.Run(interleave, func(t *testing.T) {
+			header, data := testCube(t, 5, 3, interleave, interleave == InterleaveBIL)
+			cube, err := Open(header, bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var calls, last int
+			abundances, err := Unmix(context.Background(), cube, endmembers, 2, func(done, total int) {
+				calls++
+				if done <= last || total != 3 {
+					t.Errorf("progress %d/%d after %d", done, total, last)
+				}
+				last = done
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if calls != 3 || last != 3 {
+				t.Fatalf("progress reported %d times, last %d", calls, last)
+			}
+			if abundances.Valid[0] || abundances.Fractions[0][0] != 0 {
+				t.Fatal("ignored pixel must stay empty")
+			}
+			for y := 0; y < 3; y++ {
+				for x := 0; x < 5; x++ {
+					p := y*5 + x
+					if p == 0 {
+						continue
+					}
+					want := float64(x) / 4
+					if math.Abs(float64(abundances.Fractions[0][p])-want) > 1e-3 || math.Abs(float64(abundances.Fractions[1][p])-(1-want)) > 1e-3 {
+						t.Fatalf("pixel (%d, %d): %v, %v", x, y, abundances.Fractions[0][p], abundances.Fractions[1][p])
+					}
+				}
+			}
+
+			mean, peak := abundances.Stats(0)
+			if math.Abs(peak-1) > 1e-3 || mean <= 0.4 || mean >= 0.6 {
+				t.Fatalf("stats %v, %v", mean, peak)
+			}
+			encoded, err := abundances.PNG(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			img, err := png.Decode(bytes.NewReader(encoded))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+				t.Fatal("ignored pixel must be transparent")
+			}
+			if r, _, _, _ := img.At(4, 1).RGBA(); r>>8 != 255 {
+				t.Fatalf("pure red pixel level %d", r>>8)
+			}
+		})
+	}
+}
+
+func TestUnmixErrors(t *testing.T) {
+	header, data := testCube(t, 5, 3, InterleaveBSQ, false)
+	if _, err := Open(header, bytes.NewReader(data), int64(len(data)-1)); !errors.Is(err, ErrHeader) {
+		t.Fatalf("short data: err = %v", err)
+	}
+
+	// без известной длины обрыв данных обнаруживается при чтении
+	cube, err := Open(header, bytes.NewReader(data[:len(data)/2]), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endmembers := []unmix.Endmember{{PigmentID: 7, Spectrum: curve(red)}}
+	if _, err := Unmix(context.Background(), cube, endmembers, 2, nil); err == nil {
+		t.Fatal("expected read error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cube, _ = Open(header, bytes.NewReader(data), int64(len(data)))
+	if _, err := Unmix(ctx, cube, endmembers, 2, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled: err = %v", err)
+	}
+
+	infrared := []unmix.Endmember{{PigmentID: 7, Spectrum: spectra.Spectrum{{Wavelength: 900, Value: 0.5}, {Wavelength: 1000, Value: 0.5}}}}
+	if _, err := Unmix(context.Background(), cube, infrared, 2, nil); !errors.Is(err, unmix.ErrTooFewPoints) {
+		t.Fatalf("no overlap: err = %v", err)
+	}
+}
+
+// slowQueue отдаёт очередь с задержкой: без блокировки одновременные загрузки успевают
+// увидеть одно и то же число ждущих кубов
+type slowQueue struct {
+	*memstore.Store
+}
+
+func (q slowQueue) ListPendingCubes(ctx context.Context) ([]ds.HyperspectralCube, error) {
+	pending, err := q.Store.ListPendingCubes(ctx)
+	time.Sleep(time.Millisecond)
+	return pending, err
+}
+
+func TestSubmitQueueLimit(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New()
+	pigment := ds.Pigment{Name: "Ультрамарин"}
+	if err := store.CreatePigment(ctx, &pigment); err != nil {
+		t.Fatal(err)
+	}
+	analysis, _, err := store.AddPigmentToDraft(ctx, 1, pigment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// воркер не запущен: все принятые кубы остаются в очереди
+	w := NewWorker(slowQueue{store}, store, store, nil, t.TempDir())
+	var wg sync.WaitGroup
+	errs := make([]error, 3*MaxPending)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cube := ds.HyperspectralCube{SpectrumAnalysisID: analysis.ID}
+			errs[i] = w.Submit(ctx, &cube, strings.NewReader("data"))
+		}()
+	}
+	wg.Wait()
+
+	var accepted int
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			accepted++
+		case !errors.Is(err, ErrQueueFull):
+			t.Fatal(err)
+		}
+	}
+	if accepted != MaxPending {
+		t.Fatalf("accepted %d cubes, queue holds %d", accepted, MaxPending)
+	}
+}
+
+func TestEndmembersSkipRejected(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New()
+	var analysis *ds.SpectrumAnalysis
+	var ids []uint
+	for _, values := range [][]float64{red, blue} {
+		pigment := ds.Pigment{Name: "Пигмент"}
+		if err := store.CreatePigment(ctx, &pigment); err != nil {
+			t.Fatal(err)
+		}
+		reference := ds.ReferenceSpectrum{PigmentID: pigment.ID, Technique: spectra.TechniqueReflectance, Spectrum: curve(values).String()}
+		if err := store.AddReferenceSpectrum(ctx, &reference); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		if analysis, _, err = store.AddPigmentToDraft(ctx, 1, pigment.ID); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, pigment.ID)
+	}
+	analysis.Status = ds.StatusCompleted
+	if err := store.CompleteAnalysis(ctx, analysis, map[uint]repository.PigmentResult{
+		ids[0]: {Percent: 100},
+		ids[1]: {Verdict: ds.VerdictRejected},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWorker(store, store, store, nil, t.TempDir())
+	endmembers, err := w.endmembers(ctx, analysis.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endmembers) != 1 || endmembers[0].PigmentID != ids[0] {
+		t.Fatalf("unexpected endmembers %+v", endmembers)
+	}
+}
@@ -0,0 +1,300 @@
+package hyperspectral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/spectra"
+	"colorLex/internal/app/storage"
+	"colorLex/internal/app/unmix"
+
+	"github.com/google/uuid"
+)
+
+// Ограничения очереди
+const (
+	// MaxPending - сколько кубов может ждать обработки; новые загрузки сверх этого отклоняются
+	MaxPending = 8
+	// MaxDuration - наибольшее время обработки одного куба
+	MaxDuration = 30 * time.Minute
+	// progressStep - шаг, с которым прогресс сохраняется в БД
+	progressStep = 0.05
+)
+
+// ErrQueueFull - в очереди уже MaxPending кубов
+var ErrQueueFull = errors.New("cube queue is full")
+
+// Worker обрабатывает загруженные кубы в фоне по одному: читает данные с диска,
+// раскладывает пиксели по эталонам пигментов заявки и сохраняет карты долей в хранилище.
+// Очередь - кубы в статусе queued в БД, поэтому после перезапуска обработка продолжается
+type Worker struct {
+	Cubes    repository.CubeStore
+	Analyses repository.AnalysisStore
+	Pigments repository.PigmentStore
+	Storage  storage.Storage
+	Dir      string // каталог с данными кубов, ждущих обработки
+	Threads  int    // потоков на один куб
+
+	wake   chan struct{}
+	submit sync.Mutex // проверка места в очереди и создание куба
+}
+
+func NewWorker(cubes repository.CubeStore, analyses repository.AnalysisStore, pigments repository.PigmentStore, store storage.Storage, dir string) *Worker {
+	return &Worker{
+		Cubes:    cubes,
+		Analyses: analyses,
+		Pigments: pigments,
+		Storage:  store,
+		Dir:      dir,
+		Threads:  Workers(),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Submit сохраняет данные куба на диск и ставит его в очередь. cube должен быть
+// заполнен, кроме статуса; data читается до конца
+func (w *Worker) Submit(ctx context.Context, cube *ds.HyperspectralCube, data io.Reader) error {
+	if err := os.MkdirAll(w.Dir, 0o755); err != nil {
+		return err
+	}
+
+	// Данные пишутся во временный файл и переименовываются, когда у куба появится ID
+	file, err := os.CreateTemp(w.Dir, "upload-*.raw")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := io.Copy(file, data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := w.enqueue(ctx, cube); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), w.dataPath(cube.ID)); err != nil {
+		w.fail(ctx, cube, "Не удалось сохранить данные куба")
+		return err
+	}
+	w.notify()
+	return nil
+}
+
+// enqueue создаёт куб в статусе queued, если в очереди есть место. Проверка и вставка
+// идут под одной блокировкой, иначе одновременные загрузки переполнили бы очередь
+func (w *Worker) enqueue(ctx context.Context, cube *ds.HyperspectralCube) error {
+	w.submit.Lock()
+	defer w.submit.Unlock()
+
+	pending, err := w.Cubes.ListPendingCubes(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) >= MaxPending {
+		return ErrQueueFull
+	}
+	cube.Status = ds.CubeQueued
+	return w.Cubes.CreateCube(ctx, cube)
+}
+
+// Run обрабатывает очередь до отмены ctx. Кубы, обработка которых прервалась
+// остановкой сервера, начинаются заново
+func (w *Worker) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		pending, err := w.Cubes.ListPendingCubes(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("hyperspectral: list pending cubes: %v", err)
+		}
+		if len(pending) > 0 {
+			w.process(ctx, &pending[0])
+			continue
+		}
+		select {
+		case <-w.wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *Worker) notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Worker) dataPath(id uint) string {
+	return filepath.Join(w.Dir, fmt.Sprintf("cube_%d.raw", id))
+}
+
+// process обрабатывает куб и записывает итог. Если остановлен сам воркер, куб
+// возвращается в очередь
+func (w *Worker) process(ctx context.Context, cube *ds.HyperspectralCube) {
+	started := time.Now()
+	cube.Status, cube.Progress, cube.Error = ds.CubeRunning, 0, ""
+	cube.StartedAt, cube.FinishedAt = &started, nil
+	if err := w.Cubes.UpdateCube(ctx, cube); err != nil {
+		if ctx.Err() == nil {
+			log.Printf("hyperspectral: start cube %d: %v", cube.ID, err)
+			// не даём битой записи заблокировать очередь
+			w.fail(context.WithoutCancel(ctx), cube, "Не удалось начать обработку")
+		}
+		return
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, MaxDuration)
+	defer cancel()
+	maps, err := w.unmixCube(jobCtx, cube)
+	if ctx.Err() != nil {
+		cube.Status, cube.Progress, cube.StartedAt = ds.CubeQueued, 0, nil
+		if err := w.Cubes.UpdateCube(context.WithoutCancel(ctx), cube); err != nil {
+			log.Printf("hyperspectral: requeue cube %d: %v", cube.ID, err)
+		}
+		return
+	}
+	defer os.Remove(w.dataPath(cube.ID))
+	if err != nil {
+		log.Printf("hyperspectral: cube %d: %v", cube.ID, err)
+		w.fail(ctx, cube, failureMessage(err))
+		return
+	}
+	if err := w.Cubes.ReplaceAbundanceMaps(ctx, cube.ID, maps); err != nil {
+		log.Printf("hyperspectral: save maps of cube %d: %v", cube.ID, err)
+		w.fail(ctx, cube, "Не удалось сохранить карты")
+		return
+	}
+
+	finished := time.Now()
+	cube.Status, cube.Progress, cube.FinishedAt = ds.CubeDone, 1, &finished
+	if err := w.Cubes.UpdateCube(ctx, cube); err != nil {
+		log.Printf("hyperspectral: finish cube %d: %v", cube.ID, err)
+	}
+}
+
+// unmixCube раскладывает куб по эталонам пигментов заявки и выгружает карты
+func (w *Worker) unmixCube(ctx context.Context, cube *ds.HyperspectralCube) ([]ds.AbundanceMap, error) {
+	header, err := ParseHeader(strings.NewReader(cube.Header))
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(w.dataPath(cube.ID))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	data, err := Open(header, file, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	endmembers, err := w.endmembers(ctx, cube.SpectrumAnalysisID)
+	if err != nil {
+		return nil, err
+	}
+	if len(endmembers) == 0 {
+		return nil, errNoEndmembers
+	}
+
+	saved := 0.0
+	abundances, err := Unmix(ctx, data, endmembers, w.Threads, func(done, total int) {
+		progress := float64(done) / float64(total)
+		if progress-saved < progressStep || done == total {
+			return
+		}
+		saved = progress
+		cube.Progress = progress
+		if err := w.Cubes.UpdateCube(ctx, cube); err != nil && ctx.Err() == nil {
+			log.Printf("hyperspectral: progress of cube %d: %v", cube.ID, err)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	maps := make([]ds.AbundanceMap, len(abundances.PigmentIDs))
+	for i, pigmentID := range abundances.PigmentIDs {
+		image, err := abundances.PNG(i)
+		if err != nil {
+			return nil, err
+		}
+		key := fmt.Sprintf("abundance_%d_%d.png", cube.ID, pigmentID)
+		if err := w.Storage.Put(ctx, key, "image/png", image); err != nil {
+			return nil, fmt.Errorf("upload %s: %w", key, err)
+		}
+		mean, peak := abundances.Stats(i)
+		maps[i] = ds.AbundanceMap{PigmentID: pigmentID, ImageKey: key, MeanFraction: mean, MaxFraction: peak}
+	}
+	return maps, nil
+}
+
+var errNoEndmembers = errors.New("no pigment of the analysis has a reflectance reference")
+
+// endmembers - первые читаемые эталоны отражения пигментов заявки; пигменты без эталона
+// и отклонённые модератором пропускаются, как при завершении заявки
+func (w *Worker) endmembers(ctx context.Context, analysisID uuid.UUID) ([]unmix.Endmember, error) {
+	pigments, err := w.Analyses.ListAnalysisPigments(ctx, analysisID)
+	if err != nil {
+		return nil, err
+	}
+	var endmembers []unmix.Endmember
+	for _, ap := range pigments {
+		if ap.Link.Verdict == ds.VerdictRejected {
+			continue
+		}
+		references, err := w.Pigments.ListReferenceSpectra(ctx, ap.Pigment.ID, spectra.TechniqueReflectance)
+		if err != nil {
+			return nil, err
+		}
+		for _, reference := range references {
+			if spectrum, err := spectra.Parse(reference.Spectrum); err == nil {
+				endmembers = append(endmembers, unmix.Endmember{PigmentID: ap.Pigment.ID, Spectrum: spectrum})
+				break
+			}
+		}
+	}
+	return endmembers, nil
+}
+
+func (w *Worker) fail(ctx context.Context, cube *ds.HyperspectralCube, message string) {
+	finished := time.Now()
+	cube.Status, cube.Error, cube.FinishedAt = ds.CubeFailed, message, &finished
+	if err := w.Cubes.UpdateCube(ctx, cube); err != nil {
+		log.Printf("hyperspectral: fail cube %d: %v", cube.ID, err)
+	}
+}
+
+// failureMessage - причина неудачи для пользователя
+func failureMessage(err error) string {
+	switch {
+	case errors.Is(err, errNoEndmembers):
+		return "У пигментов заявки нет эталонов отражения"
+	case errors.Is(err, ErrTooManyEndmembers):
+		return fmt.Sprintf("В заявке больше %d пигментов с эталонами", MaxEndmembers)
+	case errors.Is(err, unmix.ErrTooFewPoints):
+		return "Каналы куба не пересекаются с эталонами пигментов"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "Обработка заняла больше отведённого времени"
+	case errors.Is(err, ErrHeader), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "Данные куба не соответствуют заголовку"
+	default:
+		return "Ошибка обработки куба"
+	}
+}
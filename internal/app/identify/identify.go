@@ -0,0 +1,103 @@
+// Package identify - подбор пигментов по измерениям разных методик.
+//
+// Каждая методика оценивает пигменты по-своему: спектр отражения сравнивается
+// с эталонными кривыми по корреляции, в рамановском спектре ищутся полосы
+// эталона, в рентгенофлуоресцентном - линии элементов из формулы пигмента.
+// Оценки лежат в диапазоне 0..1, поэтому их можно свести в общий рейтинг.
+package identify
+
+import (
+	"sort"
+
+	"colorLex/internal/app/spectra"
+)
+
+// Reference - эталонный спектр пигмента
+type Reference struct {
+	PigmentID uint
+	Name      string
+	Spectrum  spectra.Spectrum
+}
+
+// Candidate - оценка пигмента одной методикой
+type Candidate struct {
+	PigmentID uint
+	Name      string
+	Score     float64
+	// Matched и Missing - найденные и ненайденные признаки пигмента:
+	// рамановские полосы в см⁻¹ или символы элементов
+	Matched []string
+	Missing []string
+}
+
+// Ranked - пигмент в общем рейтинге по всем методикам
+type Ranked struct {
+	PigmentID uint
+	Name      string
+	Score     float64
+	Scores    map[string]float64 // оценка каждой методики, которая смогла оценить пигмент
+}
+
+// Rank сводит оценки методик в общий рейтинг. Итоговая оценка - среднее по
+// методикам, в которых пигмент был оценён: отсутствие эталона для одной
+// методики не штрафует пигмент, а отрицательный результат измерения штрафует.
+// Пигменты с нулевой итоговой оценкой в рейтинг не попадают
+func Rank(results map[string][]Candidate) []Ranked {
+	byPigment := make(map[uint]*Ranked)
+	for technique, candidates := range results {
+		for _, candidate := range candidates {
+			ranked, ok := byPigment[candidate.PigmentID]
+			if !ok {
+				ranked = &Ranked{PigmentID: candidate.PigmentID, Name: candidate.Name, Scores: make(map[string]float64)}
+				byPigment[candidate.PigmentID] = ranked
+			}
+			ranked.Scores[technique] = candidate.Score
+		}
+	}
+
+	var result []Ranked
+	for _, ranked := range byPigment {
+		var sum float64
+		for _, score := range ranked.Scores {
+			sum += score
+		}
+		ranked.Score = sum / float64(len(ranked.Scores))
+		if ranked.Score > 0 {
+			result = append(result, *ranked)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		if len(result[i].Scores) != len(result[j].Scores) {
+			return len(result[i].Scores) > len(result[j].Scores)
+		}
+		return result[i].PigmentID < result[j].PigmentID
+	})
+	return result
+}
+
+// bestPerPigment оставляет для каждого пигмента кандидата с лучшей оценкой
+// (у пигмента может быть несколько эталонов) и сортирует по убыванию оценки
+func bestPerPigment(candidates []Candidate) []Candidate {
+	best := make(map[uint]int)
+	var result []Candidate
+	for _, candidate := range candidates {
+		if i, ok := best[candidate.PigmentID]; ok {
+			if candidate.Score > result[i].Score {
+				result[i] = candidate
+			}
+			continue
+		}
+		best[candidate.PigmentID] = len(result)
+		result = append(result, candidate)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].PigmentID < result[j].PigmentID
+	})
+	return result
+}
@@ -0,0 +1,213 @@
+package identify
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"colorLex/internal/app/spectra"
+)
+
+// synthetic строит спектр на равномерной сетке: фон плюс гауссовы пики {центр, высота}
+func synthetic(from, to, step, background, width float64, peaks ...[2]float64) spectra.Spectrum {
+	var s spectra.Spectrum
+	for x := from; x <= to+step/2; x += step {
+		value := background
+		for _, peak := range peaks {
+			d := (x - peak[0]) / width
+			value += peak[1] * math.Exp(-d*d/2)
+		}
+		s = append(s, spectra.Point{Wavelength: math.Round(x*1000) / 1000, Value: value})
+	}
+	return s
+}
+
+func mustParse(t *testing.T, text string) spectra.Spectrum {
+	t.Helper()
+	s, err := spectra.Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestMatchReflectance(t *testing.T) {
+	blue := mustParse(t, "400:0.6,500:0.4,600:0.1,700:0.1")
+	yellow := mustParse(t, "400:0.1,500:0.3,600:0.6,700:0.7")
+	references := []Reference{
+		{PigmentID: 1, Name: "blue", Spectrum: blue},
+		{PigmentID: 2, Name: "yellow", Spectrum: yellow},
+		{PigmentID: 3, Name: "infrared", Spectrum: mustParse(t, "900:0.1,1000:0.2")},
+	}
+	sample := mustParse(t, "400:0.5,450:0.45,500:0.35,600:0.12,700:0.1")
+
+	got := MatchReflectance(sample, references)
+	if len(got) != 2 || got[0].PigmentID != 1 || got[0].Score < 0.95 || got[1].Score != 0 {
+		t.Fatalf("unexpected candidates %+v", got)
+	}
+}
+
+func TestMatchRaman(t *testing.T) {
+	// Эталоны: три полосы у одного пигмента, две у другого
+	calcite := synthetic(100, 1200, 2, 10, 4, [2]float64{156, 40}, [2]float64{282, 60}, [2]float64{1086, 200})
+	vermilion := synthetic(100, 1200, 2, 10, 4, [2]float64{253, 200}, [2]float64{343, 80})
+	sets := []BandSet{
+		ReferenceBands(Reference{PigmentID: 1, Name: "calcite", Spectrum: calcite}),
+		ReferenceBands(Reference{PigmentID: 2, Name: "vermilion", Spectrum: vermilion}),
+	}
+	// Образец: полосы кальцита со сдвигом в пару см⁻¹ на наклонном фоне, без полосы 156
+	sample := synthetic(100, 1200, 2, 0, 5, [2]float64{284, 50}, [2]float64{1088, 150})
+	for i := range sample {
+		sample[i].Value += 0.02 * sample[i].Wavelength
+	}
+
+	got := MatchRaman(sample, sets)
+	if len(got) != 2 {
+		t.Fatalf("unexpected candidates %+v", got)
+	}
+	// Пропущена только слабая полоса 156: 5 из 6 по весам
+	if got[0].PigmentID != 1 || math.Abs(got[0].Score-5.0/6) > 1e-9 {
+		t.Fatalf("calcite: %+v", got[0])
+	}
+	if !reflect.DeepEqual(got[0].Matched, []string{"282", "1086"}) || !reflect.DeepEqual(got[0].Missing, []string{"156"}) {
+		t.Fatalf("calcite bands: matched %v, missing %v", got[0].Matched, got[0].Missing)
+	}
+	if got[1].PigmentID != 2 || got[1].Score != 0 {
+		t.Fatalf("vermilion: %+v", got[1])
+	}
+}
+
+func TestDetectPeaks(t *testing.T) {
+	// Две гауссовы полосы на наклонном фоне; шум ниже порога выступания отбрасывается
+	sample := synthetic(100, 600, 1, 0, 6, [2]float64{250, 100}, [2]float64{400, 40}, [2]float64{520, 2})
+	for i := range sample {
+		sample[i].Value += 20 + 0.05*sample[i].Wavelength
+	}
+
+	got := DetectPeaks(sample)
+	if len(got) != 2 {
+		t.Fatalf("unexpected peaks %+v", got)
+	}
+	if got[0].Position != 250 || got[1].Position != 400 {
+		t.Fatalf("positions %v, %v", got[0].Position, got[1].Position)
+	}
+	if got[0].Relative != 1 || math.Abs(got[1].Relative-0.4) > 0.01 {
+		t.Fatalf("relative intensities %v, %v", got[0].Relative, got[1].Relative)
+	}
+	// Полная ширина на половине высоты гауссианы - 2.355σ
+	if math.Abs(got[0].Width-2.355*6) > 0.3 {
+		t.Fatalf("width %v", got[0].Width)
+	}
+}
+
+func TestExplainRaman(t *testing.T) {
+	peaks := []Peak{
+		{Position: 252, Relative: 1},
+		{Position: 300, Relative: 0.5},
+		{Position: 700, Relative: 0.3},
+		{Position: 900, Relative: 0.1},
+	}
+	set := BandSet{PigmentID: 1, Name: "vermilion", Bands: []Band{
+		{Position: 343, Tolerance: 5, Strength: StrengthMedium},
+		{Position: 253, Tolerance: 5, Strength: StrengthStrong},
+		{Position: 290, Tolerance: 5, Strength: StrengthWeak},
+	}}
+
+	got := ExplainRaman(peaks, set)
+	if math.Abs(got.Score-0.5) > 1e-9 {
+		t.Fatalf("score %v", got.Score)
+	}
+	if len(got.Matched) != 1 || got.Matched[0].Peak.Position != 252 || got.Matched[0].Shift != -1 {
+		t.Fatalf("matched %+v", got.Matched)
+	}
+	if len(got.Missing) != 2 || got.Missing[0].Position != 290 || got.Missing[1].Position != 343 || got.MissingStrong() {
+		t.Fatalf("missing %+v", got.Missing)
+	}
+	// Пик 900 слишком слабый, чтобы считаться необъяснённым
+	if len(got.Unexplained) != 2 || got.Unexplained[0].Position != 300 || got.Unexplained[1].Position != 700 {
+		t.Fatalf("unexplained %+v", got.Unexplained)
+	}
+
+	// Один пик не может подтвердить две полосы
+	twins := BandSet{Bands: []Band{{Position: 250, Strength: StrengthStrong}, {Position: 254, Strength: StrengthWeak}}}
+	if got := ExplainRaman(peaks[:1], twins); len(got.Matched) != 1 || got.Matched[0].Band.Position != 250 {
+		t.Fatalf("twins %+v", got)
+	}
+}
+
+func TestDetectElements(t *testing.T) {
+	// Медь (Kα и Kβ) и железо только с Kα: без Kβ железо не засчитывается
+	sample := synthetic(1, 20, 0.02, 5, 0.06,
+		[2]float64{8.05, 400}, [2]float64{8.90, 60},
+		[2]float64{6.40, 100},
+	)
+	if got := DetectElements(sample); !reflect.DeepEqual(got, []string{"Cu"}) {
+		t.Fatalf("detected %v", got)
+	}
+
+	// Свинец отличается от мышьяка линией Lβ
+	lead := synthetic(1, 20, 0.02, 5, 0.06, [2]float64{10.55, 300}, [2]float64{12.61, 200})
+	if got := DetectElements(lead); !reflect.DeepEqual(got, []string{"Pb"}) {
+		t.Fatalf("detected %v", got)
+	}
+}
+
+func TestMatchXRF(t *testing.T) {
+	pigments := []PigmentFormula{
+		{PigmentID: 1, Name: "azurite", Formula: "Cu3(CO3)2(OH)2"},
+		{PigmentID: 2, Name: "vermilion", Formula: "HgS"},
+		{PigmentID: 3, Name: "lead white", Formula: "2PbCO3·Pb(OH)2"},
+		{PigmentID: 4, Name: "bone black", Formula: "C + Ca3(PO4)2"},
+		{PigmentID: 5, Name: "indigo", Formula: "C16H10N2O2"},
+	}
+	// Ртуть и медь; спектр начинается с 3 кэВ, поэтому сера не оценивается
+	sample := synthetic(3, 20, 0.02, 5, 0.06,
+		[2]float64{9.99, 300}, [2]float64{11.82, 120},
+		[2]float64{8.05, 200}, [2]float64{8.90, 40},
+	)
+
+	got := MatchXRF(sample, pigments)
+	scores := make(map[uint]float64)
+	for _, candidate := range got {
+		scores[candidate.PigmentID] = candidate.Score
+	}
+	want := map[uint]float64{1: 1, 2: 1, 3: 0, 4: 0}
+	if !reflect.DeepEqual(scores, want) {
+		t.Fatalf("scores %v, want %v", scores, want)
+	}
+}
+
+func TestFormulaElements(t *testing.T) {
+	got := FormulaElements("2PbCO3·Pb(OH)2")
+	if !reflect.DeepEqual(got, []string{"Pb", "C", "O", "H"}) {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestRank(t *testing.T) {
+	got := Rank(map[string][]Candidate{
+		spectra.TechniqueXRF: {
+			{PigmentID: 1, Name: "azurite", Score: 1},
+			{PigmentID: 2, Name: "malachite", Score: 1},
+			{PigmentID: 3, Name: "vermilion", Score: 0},
+		},
+		spectra.TechniqueRaman: {
+			{PigmentID: 1, Name: "azurite", Score: 0.75},
+			{PigmentID: 2, Name: "malachite", Score: 0.25},
+		},
+		spectra.TechniqueReflectance: {
+			{PigmentID: 4, Name: "smalt", Score: 0.9},
+		},
+	})
+
+	var order []uint
+	for _, ranked := range got {
+		order = append(order, ranked.PigmentID)
+	}
+	if !reflect.DeepEqual(order, []uint{4, 1, 2}) {
+		t.Fatalf("order %v (%+v)", order, got)
+	}
+	if got[1].Score != 0.875 || len(got[1].Scores) != 2 {
+		t.Fatalf("azurite %+v", got[1])
+	}
+}
@@ -0,0 +1,98 @@
+package identify
+
+import (
+	"colorLex/internal/app/spectra"
+)
+
+// minProminence - выступание пика в долях самого высокого пика; ниже - шум
+const minProminence = 0.05
+
+// Peak - полоса, найденная в спектре после вычитания фона
+type Peak struct {
+	Position float64 // положение максимума в единицах оси
+	// Intensity - высота над фоном, Relative - она же в долях самого высокого пика
+	Intensity float64
+	Relative  float64
+	// Prominence - насколько пик выступает над более высоким из соседних провалов
+	Prominence float64
+	// Width - полная ширина на половине выступания; у пиков на краю спектра занижена
+	Width float64
+}
+
+// DetectPeaks вычитает фон нижней выпуклой оболочкой (rubberband) и возвращает
+// локальные максимумы, выступающие не меньше чем на 5% самого высокого пика,
+// в порядке возрастания положения
+func DetectPeaks(s spectra.Spectrum) []Peak {
+	if len(s) < 3 {
+		return nil
+	}
+	corrected, err := spectra.Pipeline{{Op: spectra.OpBaseline, Method: spectra.BaselineRubberband}}.Apply(s)
+	if err != nil {
+		return nil
+	}
+
+	var top float64
+	for _, p := range corrected {
+		top = max(top, p.Value)
+	}
+	if top == 0 {
+		return nil
+	}
+
+	var peaks []Peak
+	for i := 1; i < len(corrected)-1; i++ {
+		value := corrected[i].Value
+		if value <= corrected[i-1].Value || value < corrected[i+1].Value {
+			continue
+		}
+
+		// Провалы по сторонам: идём до более высокой точки или края спектра
+		leftMin, rightMin := value, value
+		for j := i - 1; j >= 0 && corrected[j].Value <= value; j-- {
+			leftMin = min(leftMin, corrected[j].Value)
+		}
+		for j := i + 1; j < len(corrected) && corrected[j].Value <= value; j++ {
+			rightMin = min(rightMin, corrected[j].Value)
+		}
+		prominence := value - max(leftMin, rightMin)
+		if prominence < minProminence*top {
+			continue
+		}
+
+		peaks = append(peaks, Peak{
+			Position:   corrected[i].Wavelength,
+			Intensity:  value,
+			Relative:   value / top,
+			Prominence: prominence,
+			Width:      halfWidth(corrected, i, value-prominence/2),
+		})
+	}
+	return peaks
+}
+
+// halfWidth находит по обе стороны от пика i пересечения с уровнем level,
+// интерполируя между точками, и возвращает расстояние между ними
+func halfWidth(s spectra.Spectrum, i int, level float64) float64 {
+	left := s[0].Wavelength
+	for j := i; j > 0; j-- {
+		if s[j-1].Value <= level {
+			left = crossing(s[j-1], s[j], level)
+			break
+		}
+	}
+	right := s[len(s)-1].Wavelength
+	for j := i; j < len(s)-1; j++ {
+		if s[j+1].Value <= level {
+			right = crossing(s[j], s[j+1], level)
+			break
+		}
+	}
+	return right - left
+}
+
+func crossing(a, b spectra.Point, level float64) float64 {
+	if a.Value == b.Value {
+		return a.Wavelength
+	}
+	return a.Wavelength + (level-a.Value)/(b.Value-a.Value)*(b.Wavelength-a.Wavelength)
+}
@@ -0,0 +1,168 @@
+package identify
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"colorLex/internal/app/spectra"
+)
+
+// Сила характеристической полосы в эталонном спектре
+const (
+	StrengthStrong = "strong"
+	StrengthMedium = "medium"
+	StrengthWeak   = "weak"
+)
+
+var Strengths = []string{StrengthStrong, StrengthMedium, StrengthWeak}
+
+// strengthWeight - вклад полосы в оценку: пропуск сильной полосы весит больше пропуска слабой
+var strengthWeight = map[string]float64{StrengthStrong: 3, StrengthMedium: 2, StrengthWeak: 1}
+
+// DefaultTolerance - допуск полосы, если в библиотеке он не задан, см⁻¹
+const DefaultTolerance = 5.0
+
+// minUnexplained - пики ниже этой доли самого высокого не считаются необъяснёнными
+const minUnexplained = 0.2
+
+// Band - характеристическая полоса пигмента
+type Band struct {
+	Position   float64
+	Tolerance  float64 // допустимое отклонение положения, см⁻¹
+	Strength   string
+	Assignment string // колебание, которому соответствует полоса
+}
+
+// BandSet - полосы одного пигмента
+type BandSet struct {
+	PigmentID uint
+	Name      string
+	Bands     []Band
+}
+
+// BandMatch - полоса эталона и пик образца, который ей соответствует
+type BandMatch struct {
+	Band  Band
+	Peak  Peak
+	Shift float64 // положение пика минус положение полосы
+}
+
+// RamanExplanation - почему пигмент получил свою оценку
+type RamanExplanation struct {
+	PigmentID uint
+	Name      string
+	Score     float64 // доля найденных полос с весами по силе
+	Matched   []BandMatch
+	Missing   []Band
+	// Unexplained - заметные пики образца, которые полосы пигмента не объясняют:
+	// признак второго пигмента или ошибочной идентификации
+	Unexplained []Peak
+}
+
+// MissingStrong - не найдена хотя бы одна сильная полоса
+func (e RamanExplanation) MissingStrong() bool {
+	for _, band := range e.Missing {
+		if band.Strength == StrengthStrong {
+			return true
+		}
+	}
+	return false
+}
+
+// ExplainRaman сопоставляет полосы пигмента с пиками образца. Полосы разбираются
+// от сильных к слабым, каждая забирает ближайший свободный пик в пределах допуска
+func ExplainRaman(peaks []Peak, set BandSet) RamanExplanation {
+	explanation := RamanExplanation{PigmentID: set.PigmentID, Name: set.Name}
+
+	bands := append([]Band(nil), set.Bands...)
+	sort.SliceStable(bands, func(i, j int) bool { return strengthWeight[bands[i].Strength] > strengthWeight[bands[j].Strength] })
+
+	used := make([]bool, len(peaks))
+	var matchedWeight, totalWeight float64
+	for _, band := range bands {
+		weight := strengthWeight[band.Strength]
+		totalWeight += weight
+
+		tolerance := band.Tolerance
+		if tolerance <= 0 {
+			tolerance = DefaultTolerance
+		}
+		best := -1
+		for i, peak := range peaks {
+			distance := math.Abs(peak.Position - band.Position)
+			if !used[i] && distance <= tolerance && (best < 0 || distance < math.Abs(peaks[best].Position-band.Position)) {
+				best = i
+			}
+		}
+		if best < 0 {
+			explanation.Missing = append(explanation.Missing, band)
+			continue
+		}
+		used[best] = true
+		matchedWeight += weight
+		explanation.Matched = append(explanation.Matched, BandMatch{
+			Band:  band,
+			Peak:  peaks[best],
+			Shift: peaks[best].Position - band.Position,
+		})
+	}
+	if totalWeight > 0 {
+		explanation.Score = matchedWeight / totalWeight
+	}
+
+	for i, peak := range peaks {
+		if !used[i] && peak.Relative >= minUnexplained {
+			explanation.Unexplained = append(explanation.Unexplained, peak)
+		}
+	}
+	sort.Slice(explanation.Matched, func(i, j int) bool {
+		return explanation.Matched[i].Band.Position < explanation.Matched[j].Band.Position
+	})
+	sort.Slice(explanation.Missing, func(i, j int) bool { return explanation.Missing[i].Position < explanation.Missing[j].Position })
+	return explanation
+}
+
+// ReferenceBands превращает пики эталонного спектра в полосы для пигментов, у которых
+// нет библиотеки полос. Сила определяется по относительной высоте пика
+func ReferenceBands(reference Reference) BandSet {
+	set := BandSet{PigmentID: reference.PigmentID, Name: reference.Name}
+	for _, peak := range DetectPeaks(reference.Spectrum) {
+		strength := StrengthWeak
+		switch {
+		case peak.Relative >= 0.6:
+			strength = StrengthStrong
+		case peak.Relative >= 0.25:
+			strength = StrengthMedium
+		}
+		set.Bands = append(set.Bands, Band{Position: peak.Position, Tolerance: DefaultTolerance, Strength: strength})
+	}
+	return set
+}
+
+// MatchRaman оценивает пигменты по библиотеке полос. У пигмента может быть
+// несколько наборов (например, полученных из разных эталонов) - берётся лучший
+func MatchRaman(sample spectra.Spectrum, sets []BandSet) []Candidate {
+	peaks := DetectPeaks(sample)
+
+	var candidates []Candidate
+	for _, set := range sets {
+		if len(set.Bands) == 0 {
+			continue
+		}
+		explanation := ExplainRaman(peaks, set)
+		candidate := Candidate{PigmentID: set.PigmentID, Name: set.Name, Score: explanation.Score}
+		for _, match := range explanation.Matched {
+			candidate.Matched = append(candidate.Matched, bandLabel(match.Band.Position))
+		}
+		for _, band := range explanation.Missing {
+			candidate.Missing = append(candidate.Missing, bandLabel(band.Position))
+		}
+		candidates = append(candidates, candidate)
+	}
+	return bestPerPigment(candidates)
+}
+
+func bandLabel(position float64) string {
+	return strconv.FormatFloat(position, 'f', -1, 64)
+}
@@ -0,0 +1,64 @@
+package identify
+
+import (
+	"math"
+
+	"colorLex/internal/app/spectra"
+)
+
+// minOverlap - сколько точек образца должно попасть в диапазон эталона для сравнения
+const minOverlap = 3
+
+// MatchReflectance оценивает пигменты по корреляции Пирсона спектра отражения
+// образца с эталоном в общем диапазоне. Отрицательная корреляция даёт 0.
+// Эталоны, с которыми образец почти не пересекается, пропускаются
+func MatchReflectance(sample spectra.Spectrum, references []Reference) []Candidate {
+	var candidates []Candidate
+	for _, reference := range references {
+		r, ok := correlation(sample, reference.Spectrum)
+		if !ok {
+			continue
+		}
+		candidates = append(candidates, Candidate{
+			PigmentID: reference.PigmentID,
+			Name:      reference.Name,
+			Score:     math.Max(0, r),
+		})
+	}
+	return bestPerPigment(candidates)
+}
+
+// correlation считает корреляцию на длинах волн образца, попадающих в диапазон эталона
+func correlation(sample, reference spectra.Spectrum) (float64, bool) {
+	var xs, ys []float64
+	for _, p := range sample {
+		if value, ok := reference.At(p.Wavelength); ok {
+			xs = append(xs, p.Value)
+			ys = append(ys, value)
+		}
+	}
+	if len(xs) < minOverlap {
+		return 0, false
+	}
+
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= float64(len(xs))
+	meanY /= float64(len(ys))
+
+	var cov, varX, varY float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		// Плоский спектр ни с чем не коррелирует
+		return 0, false
+	}
+	return cov / math.Sqrt(varX*varY), true
+}
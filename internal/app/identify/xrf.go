@@ -0,0 +1,165 @@
+package identify
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+
+	"colorLex/internal/app/spectra"
+)
+
+// Lines - аналитические линии элементов, определяемых РФА на воздухе, кэВ.
+// Первая - самая интенсивная (Kα или Lα); вторая нужна, чтобы различать
+// перекрывающиеся линии, например Pb Lα и As Kα
+var Lines = map[string][]float64{
+	"S":  {2.31},
+	"Cl": {2.62},
+	"K":  {3.31},
+	"Ca": {3.69, 4.01},
+	"Ti": {4.51, 4.93},
+	"Cr": {5.41},
+	"Mn": {5.90},
+	"Fe": {6.40, 7.06},
+	"Co": {6.93},
+	"Ni": {7.48},
+	"Cu": {8.05, 8.90},
+	"Zn": {8.64, 9.57},
+	"Au": {9.71, 11.44},
+	"Hg": {9.99, 11.82},
+	"As": {10.54, 11.73},
+	"Pb": {10.55, 12.61},
+	"Se": {11.22},
+	"Sr": {14.16},
+	"Ag": {22.16},
+	"Cd": {23.17},
+	"Sn": {25.27},
+	"Sb": {26.36},
+	"Ba": {32.19},
+}
+
+const (
+	// lineWindow - на сколько кэВ максимум может отстоять от табличной линии
+	lineWindow = 0.1
+	// minLineRatio - во сколько раз пик должен превышать фон рядом с линией
+	minLineRatio = 1.5
+	// minLineHeight - чистая высота пика в долях максимума спектра, отсекает шум
+	minLineHeight = 0.02
+)
+
+var elementPattern = regexp.MustCompile(`[A-Z][a-z]?`)
+
+// FormulaElements возвращает символы элементов химической формулы без повторов
+func FormulaElements(formula string) []string {
+	var elements []string
+	for _, symbol := range elementPattern.FindAllString(formula, -1) {
+		if !slices.Contains(elements, symbol) {
+			elements = append(elements, symbol)
+		}
+	}
+	return elements
+}
+
+// DetectElements возвращает элементы, все линии которых в диапазоне спектра
+// видны как пики над фоном. Элемент, первая линия которого вне диапазона, не ищется
+func DetectElements(s spectra.Spectrum) []string {
+	if len(s) < 3 {
+		return nil
+	}
+	var top float64
+	for _, p := range s {
+		top = max(top, p.Value)
+	}
+
+	var elements []string
+	for element, lines := range Lines {
+		from, to := s.Range()
+		if lines[0] < from || lines[0] > to {
+			continue
+		}
+		detected := true
+		for _, line := range lines {
+			if line >= from && line <= to && !hasLine(s, line, top) {
+				detected = false
+				break
+			}
+		}
+		if detected {
+			elements = append(elements, element)
+		}
+	}
+	sort.Strings(elements)
+	return elements
+}
+
+// hasLine ищет локальный максимум в окне линии и сравнивает его с медианой фона по сторонам окна
+func hasLine(s spectra.Spectrum, line, top float64) bool {
+	peak := -1
+	var background []float64
+	for i, p := range s {
+		distance := p.Wavelength - line
+		if distance < 0 {
+			distance = -distance
+		}
+		switch {
+		case distance <= lineWindow:
+			if peak < 0 || p.Value > s[peak].Value {
+				peak = i
+			}
+		case distance >= 3*lineWindow && distance <= 6*lineWindow:
+			background = append(background, p.Value)
+		}
+	}
+	// Максимум на краю спектра или на краю окна - склон соседней линии, а не пик
+	if peak <= 0 || peak >= len(s)-1 || s[peak].Value <= s[peak-1].Value || s[peak].Value < s[peak+1].Value {
+		return false
+	}
+
+	var level float64
+	if len(background) > 0 {
+		sort.Float64s(background)
+		level = background[len(background)/2]
+	}
+	value := s[peak].Value
+	return value > minLineRatio*level && value-level >= minLineHeight*top
+}
+
+// PigmentFormula - пигмент-кандидат для РФА
+type PigmentFormula struct {
+	PigmentID uint
+	Name      string
+	Formula   string
+}
+
+// MatchXRF оценивает пигменты долей элементов формулы, найденных в образце.
+// Учитываются только элементы, первая линия которых попадает в диапазон спектра;
+// пигменты без таких элементов (органические лаки, сажа) не оцениваются
+func MatchXRF(sample spectra.Spectrum, pigments []PigmentFormula) []Candidate {
+	if len(sample) == 0 {
+		return nil
+	}
+	detected := DetectElements(sample)
+	from, to := sample.Range()
+
+	var candidates []Candidate
+	for _, pigment := range pigments {
+		candidate := Candidate{PigmentID: pigment.PigmentID, Name: pigment.Name}
+		for _, element := range FormulaElements(pigment.Formula) {
+			lines, ok := Lines[element]
+			if !ok || lines[0] < from || lines[0] > to {
+				continue
+			}
+			if slices.Contains(detected, element) {
+				candidate.Matched = append(candidate.Matched, element)
+			} else {
+				candidate.Missing = append(candidate.Missing, element)
+			}
+		}
+		total := len(candidate.Matched) + len(candidate.Missing)
+		if total == 0 {
+			continue
+		}
+		candidate.Score = float64(len(candidate.Matched)) / float64(total)
+		candidates = append(candidates, candidate)
+	}
+	return bestPerPigment(candidates)
+}
@@ -0,0 +1,89 @@
+// Package interactions - предупреждения о рискованных сочетаниях пигментов.
+//
+// Справочник правил ведут модераторы: правило для пары срабатывает, когда в
+// анализе есть оба пигмента (свинцовые белила и сульфидные пигменты, ярь-медянка
+// и аурипигмент), правило для одного пигмента - когда он есть в анализе
+// (потемнение киновари, выцветание смальты). Каждое правило перечисляет продукты
+// деградации, которые стоит поискать в пробе.
+package interactions
+
+import (
+	"sort"
+	"strings"
+
+	"colorLex/internal/app/ds"
+)
+
+// Rank - место уровня серьёзности в ds.InteractionSeverities; -1 - неизвестный уровень
+func Rank(severity string) int {
+	for i, s := range ds.InteractionSeverities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// Match отбирает правила, все пигменты которых есть в present. Результат упорядочен
+// от самых серьёзных, при равенстве - по ID правила
+func Match(rules []ds.PigmentInteraction, present map[uint]bool) []ds.PigmentInteraction {
+	var result []ds.PigmentInteraction
+	for _, rule := range rules {
+		if !present[rule.PigmentID] {
+			continue
+		}
+		if rule.OtherPigmentID != nil && !present[*rule.OtherPigmentID] {
+			continue
+		}
+		result = append(result, rule)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if ri, rj := Rank(result[i].Severity), Rank(result[j].Severity); ri != rj {
+			return ri > rj
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// Products разбирает продукты деградации правила
+func Products(rule ds.PigmentInteraction) []string {
+	var result []string
+	for _, line := range strings.Split(rule.Products, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// JoinProducts готовит продукты деградации к записи в правило: пустые строки
+// и повторы отбрасываются
+func JoinProducts(products []string) string {
+	return strings.Join(unique(products), "\n")
+}
+
+// CheckFor собирает продукты деградации всех правил без повторов в порядке правил
+func CheckFor(rules []ds.PigmentInteraction) []string {
+	var all []string
+	for _, rule := range rules {
+		all = append(all, Products(rule)...)
+	}
+	return unique(all)
+}
+
+// unique убирает пустые строки и повторы без учёта регистра, сохраняя порядок
+func unique(values []string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		key := strings.ToLower(value)
+		if value == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, value)
+	}
+	return result
+}
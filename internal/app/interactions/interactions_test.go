@@ -0,0 +1,63 @@
+package interactions
+
+import (
+	"reflect"
+	"testing"
+
+	"colorLex/internal/app/ds"
+)
+
+func id(v uint) *uint { return &v }
+
+func TestMatch(t *testing.T) {
+	rules := []ds.PigmentInteraction{
+		{ID: 1, PigmentID: 1, OtherPigmentID: id(2), Severity: ds.InteractionWarning},
+		{ID: 2, PigmentID: 3, Severity: ds.InteractionInfo},
+		{ID: 3, PigmentID: 1, OtherPigmentID: id(4), Severity: ds.InteractionDanger},
+		{ID: 4, PigmentID: 2, Severity: ds.InteractionDanger},
+		{ID: 5, PigmentID: 1, Severity: ds.InteractionWarning},
+	}
+
+	tests := []struct {
+		name    string
+		present map[uint]bool
+		want    []uint
+	}{
+		{name: "nothing present", present: map[uint]bool{}, want: nil},
+		{name: "pair needs both pigments", present: map[uint]bool{4: true}, want: nil},
+		{name: "single pigment", present: map[uint]bool{3: true}, want: []uint{2}},
+		{
+			name:    "severe first, then by id",
+			present: map[uint]bool{1: true, 2: true, 3: true, 4: true},
+			want:    []uint{3, 4, 1, 5, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []uint
+			for _, rule := range Match(rules, tt.present) {
+				got = append(got, rule.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProducts(t *testing.T) {
+	joined := JoinProducts([]string{" сульфид свинца (PbS) ", "", "Сульфид свинца (PbS)", "мышьяковистый ангидрид"})
+	if joined != "сульфид свинца (PbS)\nмышьяковистый ангидрид" {
+		t.Fatalf("JoinProducts = %q", joined)
+	}
+
+	rules := []ds.PigmentInteraction{
+		{Products: joined},
+		{Products: "метациннабарит\nсульфид свинца (PbS)"},
+		{},
+	}
+	want := []string{"сульфид свинца (PbS)", "мышьяковистый ангидрид", "метациннабарит"}
+	if got := CheckFor(rules); !reflect.DeepEqual(got, want) {
+		t.Fatalf("CheckFor = %q, want %q", got, want)
+	}
+}
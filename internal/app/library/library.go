@@ -0,0 +1,113 @@
+// Package library - импорт открытых спектральных библиотек в каталог пигментов.
+//
+// Файлы библиотек разбираются в записи (Entry): описание пигмента, эталонные
+// спектры и, если источник их даёт, рамановские полосы. Записи сопоставляются с
+// каталогом по номеру Colour Index и названию (NewPlan), план можно показать как
+// отчёт о различиях (Plan.WriteReport) и затем применить (Apply).
+package library
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/spectra"
+)
+
+// Форматы файлов
+const (
+	FormatAuto     = "auto"
+	FormatRRUFF    = "rruff" // текст RRUFF с заголовками ##KEY=VALUE или двухколоночный CSV
+	FormatUSGS     = "usgs"  // ASCII спектральной библиотеки USGS
+	FormatManifest = "json"  // собственный манифест, см. manifest.go
+)
+
+var Formats = []string{FormatAuto, FormatRRUFF, FormatUSGS, FormatManifest}
+
+// Entry - пигмент из библиотеки
+type Entry struct {
+	// Pigment - описательные поля; ID, даты и изображение не используются
+	Pigment    ds.Pigment
+	References []Reference
+	// Bands - рамановские полосы; nil - источник их не задаёт и библиотека пигмента не меняется
+	Bands []ds.RamanBand
+	// Origin - файл и запись, откуда взят пигмент: для отчёта
+	Origin string
+}
+
+// Reference - эталонный спектр из библиотеки
+type Reference struct {
+	Technique string
+	Spectrum  spectra.Spectrum
+	Source    string
+}
+
+// ReadFile разбирает файл библиотеки. technique задаёт методику для RRUFF и CSV
+// (по умолчанию - рамановская); у USGS это всегда спектр отражения
+func ReadFile(path, format, technique string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" || format == FormatAuto {
+		format = detectFormat(path, string(data))
+	}
+	if technique == "" {
+		technique = spectra.TechniqueRaman
+	}
+
+	var entries []Entry
+	switch format {
+	case FormatRRUFF:
+		var entry Entry
+		entry, err = ParseRRUFF(string(data), nameFromPath(path), technique)
+		entries = []Entry{entry}
+	case FormatUSGS:
+		var entry Entry
+		entry, err = ParseUSGS(string(data))
+		entries = []Entry{entry}
+	case FormatManifest:
+		entries, err = ParseManifest(data, filepath.Dir(path))
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i := range entries {
+		if entries[i].Origin == "" {
+			entries[i].Origin = filepath.Base(path)
+		}
+	}
+	return entries, nil
+}
+
+func detectFormat(path, text string) string {
+	switch {
+	case strings.EqualFold(filepath.Ext(path), ".json"):
+		return FormatManifest
+	case strings.Contains(text, "Record="):
+		return FormatUSGS
+	default:
+		return FormatRRUFF
+	}
+}
+
+// nameFromPath - название пигмента для файлов без заголовка: RRUFF называет
+// файлы вида Goethite__R050142__Raman__....txt
+func nameFromPath(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	name, _, _ = strings.Cut(name, "__")
+	return strings.TrimSpace(strings.ReplaceAll(name, "_", " "))
+}
+
+// checkSpectrum проверяет, что ось спектра подходит методике
+func checkSpectrum(s spectra.Spectrum, technique string) error {
+	if len(s) == 0 {
+		return spectra.ErrEmpty
+	}
+	_, err := spectra.ParseTechnique(s.String(), technique)
+	return err
+}
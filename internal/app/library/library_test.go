@@ -0,0 +1,335 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/repository/memstore"
+	"colorLex/internal/app/spectra"
+)
+
+const rruffGoethite = `##NAMES=Goethite
+##RRUFFID=R050142
+##IDEAL CHEMISTRY=Fe^3+^O(OH)
+##LOCALITY=Unknown
+247, 120
+299, 340
+385, 1000
+480, 210
+550, 150
+##END=
+`
+
+const usgsHematite = `splib07a Record=1234: Hematite GDS27        ASDFRa AREF
+ 0.40  0.05
+ 0.50  0.08
+ 0.60  0.30
+ 0.70  -1.23e34
+ 0.80  0.42
+`
+
+const manifestJSON = `{
+  "source": "Тестовая библиотека",
+  "pigments": [
+    {
+      "name": "Гётит",
+      "ci_name": "PY43",
+      "ci_number": "77492",
+      "class": "earth",
+      "available_from": -40000,
+      "references": [{"technique": "raman", "file": "goethite.txt"}],
+      "raman_bands": [
+        {"position": 385, "strength": "strong", "assignment": "Fe-O"},
+        {"position": 299, "strength": "medium"}
+      ]
+    },
+    {"name": "Гематит", "ci_number": "77491", "class": "mineral"}
+  ]
+}`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadFile(t *testing.T) {
+	dir := t.TempDir()
+
+	entries, err := ReadFile(writeFile(t, dir, "Goethite__R050142__Raman.txt", rruffGoethite), FormatAuto, "")
+	if err != nil {
+		t.Fatalf("rruff: %v", err)
+	}
+	goethite := entries[0]
+	if goethite.Pigment.Name != "Goethite" || goethite.Pigment.Formula != "FeO(OH)" {
+		t.Errorf("rruff pigment = %q %q", goethite.Pigment.Name, goethite.Pigment.Formula)
+	}
+	if len(goethite.References) != 1 || goethite.References[0].Technique != spectra.TechniqueRaman ||
+		len(goethite.References[0].Spectrum) != 5 || goethite.References[0].Source != "RRUFF R050142" {
+		t.Errorf("rruff references = %+v", goethite.References)
+	}
+
+	entries, err = ReadFile(writeFile(t, dir, "hematite.txt", usgsHematite), FormatAuto, "")
+	if err != nil {
+		t.Fatalf("usgs: %v", err)
+	}
+	hematite := entries[0]
+	if hematite.Pigment.Name != "Hematite" {
+		t.Errorf("usgs name = %q", hematite.Pigment.Name)
+	}
+	reference := hematite.References[0]
+	if from, to := reference.Spectrum.Range(); reference.Technique != spectra.TechniqueReflectance ||
+		len(reference.Spectrum) != 4 || from != 400 || to != 800 {
+		t.Errorf("usgs reference = %s %v", reference.Technique, reference.Spectrum)
+	}
+
+	writeFile(t, dir, "goethite.txt", rruffGoethite)
+	entries, err = ReadFile(writeFile(t, dir, "library.json", manifestJSON), FormatAuto, "")
+	if err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("manifest entries = %d, want 2", len(entries))
+	}
+	if bands := entries[0].Bands; len(bands) != 2 || bands[0].Position != 299 || bands[1].Tolerance <= 0 {
+		t.Errorf("manifest bands = %+v", bands)
+	}
+	if entries[1].Bands != nil || len(entries[1].References) != 0 {
+		t.Errorf("pigment without data got bands %v, references %v", entries[1].Bands, entries[1].References)
+	}
+
+	if _, err := ReadFile(writeFile(t, dir, "bad.json", `{"pigments": [{"name": "X", "class": "plastic"}]}`), FormatAuto, ""); err == nil {
+		t.Error("unknown class accepted")
+	}
+}
+
+func seedCatalog(t *testing.T, store *memstore.Store) (ochre, archived ds.Pigment) {
+	t.Helper()
+	ctx := context.Background()
+	ochre = ds.Pigment{Name: "Гётит", CIName: "PY43", CINumber: "77492", Formula: "FeO(OH)"}
+	if err := store.CreatePigment(ctx, &ochre); err != nil {
+		t.Fatal(err)
+	}
+	reference := ds.ReferenceSpectrum{PigmentID: ochre.ID, Technique: spectra.TechniqueRaman, Spectrum: "247:120, 299:340, 385:1000, 480:210, 550:150"}
+	if err := store.AddReferenceSpectrum(ctx, &reference); err != nil {
+		t.Fatal(err)
+	}
+	archived = ds.Pigment{Name: "Hematite"}
+	if err := store.CreatePigment(ctx, &archived); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ArchivePigment(ctx, archived.ID); err != nil {
+		t.Fatal(err)
+	}
+	return ochre, archived
+}
+
+func readAll(t *testing.T) []Entry {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, dir, "goethite.txt", rruffGoethite)
+	var entries []Entry
+	for _, path := range []string{
+		writeFile(t, dir, "library.json", manifestJSON),
+		writeFile(t, dir, "hematite.txt", usgsHematite),
+	} {
+		read, err := ReadFile(path, FormatAuto, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, read...)
+	}
+	return entries
+}
+
+func TestNewPlan(t *testing.T) {
+	store := memstore.New()
+	ochre, archived := seedCatalog(t, store)
+	catalog, err := LoadCatalog(context.Background(), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan := NewPlan(catalog, readAll(t), false)
+	if len(plan.Changes) != 3 {
+		t.Fatalf("changes = %d, want 3: %+v", len(plan.Changes), plan.Changes)
+	}
+
+	goethite := plan.Changes[0]
+	if goethite.Action != ActionUpdate || goethite.Pigment.ID != ochre.ID {
+		t.Errorf("goethite action = %s #%d", goethite.Action, goethite.Pigment.ID)
+	}
+	if goethite.Duplicates != 1 || len(goethite.References) != 0 {
+		t.Errorf("goethite references: %d new, %d duplicates", len(goethite.References), goethite.Duplicates)
+	}
+	if len(goethite.Bands) != 2 {
+		t.Errorf("goethite bands = %v", goethite.Bands)
+	}
+	if goethite.Pigment.Class != ds.PigmentClassEarth || *goethite.Pigment.AvailableFrom != -40000 {
+		t.Errorf("empty fields not filled: %+v", goethite.Pigment)
+	}
+
+	// По номеру CI 77491 гематит из манифеста новый, по названию он совпал бы с архивным
+	if created := plan.Changes[1]; created.Action != ActionCreate || created.Pigment.Name != "Гематит" {
+		t.Errorf("hematite from manifest = %s %s", created.Action, created.Pigment.Name)
+	}
+	if skipped := plan.Changes[2]; skipped.Action != ActionSkip || skipped.Pigment.ID != archived.ID {
+		t.Errorf("usgs hematite = %s #%d, want skip #%d", skipped.Action, skipped.Pigment.ID, archived.ID)
+	}
+
+	var report strings.Builder
+	if err := plan.WriteReport(&report); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"~ update    Гётит #1",
+		"= 1 reference spectra already in catalog",
+		"+ create    Гематит",
+		"- skip      Hematite #2",
+		"1 to create, 1 to update, 0 unchanged, 1 skipped",
+	} {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("report has no %q:\n%s", want, report.String())
+		}
+	}
+}
+
+func TestNewPlanConflicts(t *testing.T) {
+	catalog := Catalog{Pigments: []ds.Pigment{{ID: 7, Name: "Охра", CINumber: "77492", Formula: "FeO(OH)"}}}
+	entries := []Entry{
+		{Pigment: ds.Pigment{Name: "Yellow ochre", CINumber: "77492", Formula: "Fe2O3·H2O"}, Origin: "a"},
+		{Pigment: ds.Pigment{Name: "ОХРА", Brief: "жёлтая земля"}, Origin: "b"},
+	}
+
+	kept := NewPlan(catalog, entries, false).Changes
+	if len(kept) != 1 || kept[0].Pigment.Formula != "FeO(OH)" || kept[0].Pigment.Brief != "жёлтая земля" {
+		t.Fatalf("without overwrite: %+v", kept)
+	}
+	if fields := kept[0].Fields; len(fields) != 2 || !fields[0].Conflict || fields[1].Conflict {
+		t.Errorf("fields = %+v", fields)
+	}
+
+	replaced := NewPlan(catalog, entries, true).Changes[0]
+	if replaced.Pigment.Formula != "Fe2O3·H2O" || replaced.Fields[0].Conflict {
+		t.Errorf("with overwrite: %+v", replaced)
+	}
+
+	unchanged := NewPlan(catalog, []Entry{{Pigment: ds.Pigment{Name: "охра"}}}, false).Changes[0]
+	if unchanged.Action != ActionUnchanged {
+		t.Errorf("same pigment action = %s", unchanged.Action)
+	}
+}
+
+func TestNewPlanLearnsCINumber(t *testing.T) {
+	// Номер CI приходит со второй записью, и третья находит пигмент уже по нему
+	entries := []Entry{
+		{Pigment: ds.Pigment{Name: "Ultramarine"}},
+		{Pigment: ds.Pigment{Name: "ultramarine", CINumber: "77007"}},
+		{Pigment: ds.Pigment{Name: "Ультрамарин", CINumber: "77007"}},
+	}
+	changes := NewPlan(Catalog{}, entries, false).Changes
+	if len(changes) != 1 || changes[0].Pigment.CINumber != "77007" || len(changes[0].Origins) != 3 {
+		t.Fatalf("changes = %+v", changes)
+	}
+}
+
+// BenchmarkNewPlan - библиотека из 20 тыс. записей против каталога из 5 тыс. пигментов
+func BenchmarkNewPlan(b *testing.B) {
+	var catalog Catalog
+	for i := 0; i < 5000; i++ {
+		catalog.Pigments = append(catalog.Pigments, ds.Pigment{ID: uint(i + 1), Name: fmt.Sprintf("Пигмент %d", i), CINumber: strconv.Itoa(10000 + i)})
+	}
+	entries := make([]Entry, 20000)
+	for i := range entries {
+		entries[i] = Entry{Pigment: ds.Pigment{Name: fmt.Sprintf("pigment %d", i%10000), CINumber: strconv.Itoa(10000 + i%8000)}}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewPlan(catalog, entries, false)
+	}
+}
+
+func TestApply(t *testing.T) {
+	store := memstore.New()
+	ochre, _ := seedCatalog(t, store)
+	ctx := context.Background()
+
+	catalog, err := LoadCatalog(ctx, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(ctx, store, NewPlan(catalog, readAll(t), false)); err != nil {
+		t.Fatal(err)
+	}
+
+	pigments, err := store.ListPigments(ctx, repository.PigmentQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pigments) != 2 {
+		t.Fatalf("pigments = %d, want 2", len(pigments))
+	}
+	updated, err := store.GetPigment(ctx, ochre.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Class != ds.PigmentClassEarth || updated.Formula != "FeO(OH)" {
+		t.Errorf("updated pigment = %+v", updated)
+	}
+	bands, err := store.ListRamanBands(ctx, ochre.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bands) != 2 || bands[0].Position != 299 {
+		t.Errorf("bands = %+v", bands)
+	}
+
+	// Повторный импорт ничего не меняет
+	catalog, err = LoadCatalog(ctx, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range NewPlan(catalog, readAll(t), false).Changes {
+		if change.Action == ActionCreate || change.Action == ActionUpdate {
+			t.Errorf("second import: %s %s", change.Action, change.Pigment.Name)
+		}
+	}
+}
+
+func TestApplyRollback(t *testing.T) {
+	store := memstore.New()
+	seedCatalog(t, store)
+	ctx := context.Background()
+
+	plan := Plan{Changes: []Change{
+		{Action: ActionCreate, Pigment: ds.Pigment{Name: "Лазурит"}, Bands: []ds.RamanBand{{Position: 548, Tolerance: 5}}},
+		// пигмент удалили между планированием и записью
+		{Action: ActionUpdate, Pigment: ds.Pigment{ID: 999, Name: "Призрак"}, Fields: []FieldChange{{Field: "formula", New: "X"}}},
+	}}
+	if err := Apply(ctx, store, plan); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("error = %v, want ErrNotFound", err)
+	}
+
+	pigments, err := store.ListPigments(ctx, repository.PigmentQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pigments) != 1 {
+		t.Fatalf("pigments after failed import = %d, want 1", len(pigments))
+	}
+	if bands, _ := store.ListRamanBands(ctx, 0); len(bands) != 0 {
+		t.Errorf("bands after failed import = %+v", bands)
+	}
+}
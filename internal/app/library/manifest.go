@@ -0,0 +1,170 @@
+package library
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/identify"
+	"colorLex/internal/app/spectra"
+)
+
+var ciNumber = regexp.MustCompile(`^\d{5}$`)
+
+// manifest - JSON-описание набора пигментов:
+//
+//	{
+//	  "source": "Kremer Pigmente, каталог 2024",
+//	  "pigments": [{
+//	    "name": "Гётит", "ci_name": "PY43", "ci_number": "77492", "formula": "FeO(OH)",
+//	    "references": [
+//	      {"technique": "reflectance", "spectrum": "400:0.08,500:0.18,600:0.5"},
+//	      {"file": "goethite_raman.txt", "format": "rruff"}
+//	    ],
+//	    "raman_bands": [{"position": 385, "strength": "strong"}]
+//	  }]
+//	}
+//
+// Пути файлов отсчитываются от каталога манифеста
+type manifest struct {
+	Source   string            `json:"source"`
+	Pigments []manifestPigment `json:"pigments"`
+}
+
+type manifestPigment struct {
+	Name          string              `json:"name"`
+	Brief         string              `json:"brief"`
+	Description   string              `json:"description"`
+	Color         string              `json:"color"`
+	CIName        string              `json:"ci_name"`
+	CINumber      string              `json:"ci_number"`
+	Formula       string              `json:"formula"`
+	CASNumber     string              `json:"cas_number"`
+	Class         string              `json:"class"`
+	AvailableFrom *int                `json:"available_from"`
+	AvailableTo   *int                `json:"available_to"`
+	References    []manifestReference `json:"references"`
+	RamanBands    []manifestBand      `json:"raman_bands"`
+}
+
+type manifestReference struct {
+	Technique string `json:"technique"`
+	Spectrum  string `json:"spectrum"`
+	File      string `json:"file"`
+	Format    string `json:"format"`
+	Source    string `json:"source"`
+}
+
+type manifestBand struct {
+	Position   float64 `json:"position"`
+	Tolerance  float64 `json:"tolerance"`
+	Strength   string  `json:"strength"`
+	Assignment string  `json:"assignment"`
+}
+
+// ParseManifest разбирает JSON-манифест; dir - каталог для относительных путей файлов
+func ParseManifest(data []byte, dir string) ([]Entry, error) {
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if len(m.Pigments) == 0 {
+		return nil, errors.New("manifest has no pigments")
+	}
+
+	entries := make([]Entry, 0, len(m.Pigments))
+	for i, item := range m.Pigments {
+		entry, err := manifestEntry(item, m.Source, dir)
+		if err != nil {
+			return nil, fmt.Errorf("pigment %d (%s): %w", i+1, item.Name, err)
+		}
+		entry.Origin = fmt.Sprintf("manifest #%d", i+1)
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func manifestEntry(item manifestPigment, source, dir string) (Entry, error) {
+	name := strings.TrimSpace(item.Name)
+	if name == "" {
+		return Entry{}, errors.New("name is required")
+	}
+	entry := Entry{Pigment: ds.Pigment{
+		Name:          name,
+		Brief:         strings.TrimSpace(item.Brief),
+		Description:   strings.TrimSpace(item.Description),
+		Color:         strings.TrimSpace(item.Color),
+		CIName:        strings.ToUpper(strings.TrimSpace(item.CIName)),
+		CINumber:      strings.TrimSpace(item.CINumber),
+		Formula:       strings.TrimSpace(item.Formula),
+		CASNumber:     strings.TrimSpace(item.CASNumber),
+		Class:         strings.TrimSpace(item.Class),
+		AvailableFrom: item.AvailableFrom,
+		AvailableTo:   item.AvailableTo,
+	}}
+
+	for _, ref := range item.References {
+		referenceSource := ref.Source
+		if referenceSource == "" {
+			referenceSource = source
+		}
+		if ref.File != "" {
+			parsed, err := ReadFile(filepath.Join(dir, ref.File), ref.Format, ref.Technique)
+			if err != nil {
+				return Entry{}, err
+			}
+			for _, p := range parsed {
+				for _, reference := range p.References {
+					if ref.Source != "" {
+						reference.Source = ref.Source
+					}
+					entry.References = append(entry.References, reference)
+				}
+			}
+			continue
+		}
+		spectrum, err := spectra.ParseTechnique(ref.Spectrum, ref.Technique)
+		if err != nil {
+			return Entry{}, fmt.Errorf("%s reference: %w", ref.Technique, err)
+		}
+		entry.References = append(entry.References, Reference{Technique: ref.Technique, Spectrum: spectrum, Source: referenceSource})
+	}
+
+	if entry.Pigment.Class != "" && !slices.Contains(ds.PigmentClasses, entry.Pigment.Class) {
+		return Entry{}, fmt.Errorf("unknown class %q", entry.Pigment.Class)
+	}
+	if entry.Pigment.CINumber != "" && !ciNumber.MatchString(entry.Pigment.CINumber) {
+		return Entry{}, fmt.Errorf("colour index number %q must have five digits", entry.Pigment.CINumber)
+	}
+
+	if item.RamanBands != nil {
+		entry.Bands = make([]ds.RamanBand, len(item.RamanBands))
+		axis, _ := spectra.TechniqueAxis(spectra.TechniqueRaman)
+		for i, band := range item.RamanBands {
+			if band.Position < axis.Min || band.Position > axis.Max {
+				return Entry{}, fmt.Errorf("raman band %g is outside %g-%g cm-1", band.Position, axis.Min, axis.Max)
+			}
+			if !slices.Contains(identify.Strengths, band.Strength) {
+				return Entry{}, fmt.Errorf("raman band %g: unknown strength %q", band.Position, band.Strength)
+			}
+			tolerance := band.Tolerance
+			if tolerance <= 0 {
+				tolerance = identify.DefaultTolerance
+			}
+			entry.Bands[i] = ds.RamanBand{
+				Position:   band.Position,
+				Tolerance:  tolerance,
+				Strength:   band.Strength,
+				Assignment: strings.TrimSpace(band.Assignment),
+			}
+		}
+		sort.Slice(entry.Bands, func(i, j int) bool { return entry.Bands[i].Position < entry.Bands[j].Position })
+	}
+	return entry, nil
+}
@@ -0,0 +1,428 @@
+package library
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/refindex"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/spectra"
+)
+
+// Что импорт сделает с пигментом
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionUnchanged = "unchanged"
+	// ActionSkip - совпавший пигмент в архиве: его данные не меняются
+	ActionSkip = "skip"
+)
+
+// Catalog - текущее состояние каталога, с которым сравнивается библиотека
+type Catalog struct {
+	Pigments   []ds.Pigment // включая архивные
+	References map[uint][]ds.ReferenceSpectrum
+	Bands      map[uint][]ds.RamanBand
+}
+
+// FieldChange - изменение поля пигмента
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+	// Conflict - в каталоге другое непустое значение. Без overwrite оно остаётся
+	Conflict bool
+}
+
+// Change - изменения одного пигмента
+type Change struct {
+	Action  string
+	Pigment ds.Pigment // состояние после импорта; у новых пигментов ID = 0
+	Fields  []FieldChange
+	// References - эталоны, которых ещё нет в каталоге; Duplicates - сколько уже есть
+	References []Reference
+	Duplicates int
+	// Bands - новая библиотека рамановских полос; nil - без изменений
+	Bands   []ds.RamanBand
+	Origins []string
+}
+
+// Plan - изменения каталога в порядке записей библиотеки
+type Plan struct {
+	Changes []Change
+}
+
+// LoadCatalog читает пигменты каталога вместе с эталонами и полосами. Эталоны и полосы
+// читаются разом для всех неархивных пигментов: архивные пигменты импорт пропускает
+func LoadCatalog(ctx context.Context, store repository.PigmentStore) (Catalog, error) {
+	pigments, err := store.ListPigments(ctx, repository.PigmentQuery{IncludeArchived: true})
+	if err != nil {
+		return Catalog{}, err
+	}
+	references, err := store.ListReferenceSpectra(ctx, 0, "")
+	if err != nil {
+		return Catalog{}, err
+	}
+	bands, err := store.ListRamanBands(ctx, 0)
+	if err != nil {
+		return Catalog{}, err
+	}
+
+	catalog := Catalog{
+		Pigments:   pigments,
+		References: make(map[uint][]ds.ReferenceSpectrum, len(pigments)),
+		Bands:      make(map[uint][]ds.RamanBand, len(pigments)),
+	}
+	for _, reference := range references {
+		catalog.References[reference.PigmentID] = append(catalog.References[reference.PigmentID], reference)
+	}
+	for _, band := range bands {
+		catalog.Bands[band.PigmentID] = append(catalog.Bands[band.PigmentID], band)
+	}
+	return catalog, nil
+}
+
+// pigmentField - поле пигмента, которое может прийти из библиотеки
+type pigmentField struct {
+	name string
+	get  func(p *ds.Pigment) string
+	set  func(p *ds.Pigment, value string)
+}
+
+var pigmentFields = []pigmentField{
+	{"brief", func(p *ds.Pigment) string { return p.Brief }, func(p *ds.Pigment, v string) { p.Brief = v }},
+	{"description", func(p *ds.Pigment) string { return p.Description }, func(p *ds.Pigment, v string) { p.Description = v }},
+	{"color", func(p *ds.Pigment) string { return p.Color }, func(p *ds.Pigment, v string) { p.Color = v }},
+	{"ci_name", func(p *ds.Pigment) string { return p.CIName }, func(p *ds.Pigment, v string) { p.CIName = v }},
+	{"ci_number", func(p *ds.Pigment) string { return p.CINumber }, func(p *ds.Pigment, v string) { p.CINumber = v }},
+	{"formula", func(p *ds.Pigment) string { return p.Formula }, func(p *ds.Pigment, v string) { p.Formula = v }},
+	{"cas_number", func(p *ds.Pigment) string { return p.CASNumber }, func(p *ds.Pigment, v string) { p.CASNumber = v }},
+	{"class", func(p *ds.Pigment) string { return p.Class }, func(p *ds.Pigment, v string) { p.Class = v }},
+	{"available_from", func(p *ds.Pigment) string { return formatYear(p.AvailableFrom) }, func(p *ds.Pigment, v string) { p.AvailableFrom = parseYear(v) }},
+	{"available_to", func(p *ds.Pigment) string { return formatYear(p.AvailableTo) }, func(p *ds.Pigment, v string) { p.AvailableTo = parseYear(v) }},
+}
+
+// slot - пигмент, в который сливаются совпавшие записи библиотеки
+type slot struct {
+	change     Change
+	archived   bool
+	references map[string]bool
+	bands      []ds.RamanBand
+}
+
+// NewPlan сопоставляет записи библиотеки с каталогом. Пигменты совпадают по номеру
+// Colour Index, если обозначения CI не противоречат друг другу, иначе - по названию
+// без учёта регистра. Непустые поля каталога меняются только при overwrite
+func NewPlan(catalog Catalog, entries []Entry, overwrite bool) Plan {
+	inCatalog := newPigmentIndex()
+	for i := range catalog.Pigments {
+		inCatalog.add(&catalog.Pigments[i])
+	}
+
+	var slots []*slot
+	merged := newPigmentIndex()
+	for _, entry := range entries {
+		i := merged.find(entry.Pigment)
+		if i < 0 {
+			i = len(slots)
+			slots = append(slots, newSlot(catalog, inCatalog, entry.Pigment))
+			merged.add(&slots[i].change.Pigment)
+		}
+		// Запись может дописать слоту номер CI, и по нему должны находиться следующие записи
+		ciNumber := slots[i].change.Pigment.CINumber
+		slots[i].merge(entry, overwrite)
+		if slots[i].change.Pigment.CINumber != ciNumber {
+			merged.addCI(i)
+		}
+	}
+
+	plan := Plan{Changes: make([]Change, len(slots))}
+	for i, s := range slots {
+		change := s.change
+		if change.Action != ActionCreate {
+			switch {
+			case s.archived:
+				change.Action = ActionSkip
+			case slices.ContainsFunc(change.Fields, func(f FieldChange) bool { return !f.Conflict }) ||
+				len(change.References) > 0 || change.Bands != nil:
+				change.Action = ActionUpdate
+			default:
+				change.Action = ActionUnchanged
+			}
+		}
+		plan.Changes[i] = change
+	}
+	return plan
+}
+
+// newSlot начинает слот с пигмента каталога, совпавшего с записью, или с нового пигмента.
+// inCatalog - индекс catalog.Pigments
+func newSlot(catalog Catalog, inCatalog *pigmentIndex, pigment ds.Pigment) *slot {
+	if i := inCatalog.find(pigment); i >= 0 {
+		existing := catalog.Pigments[i]
+		s := &slot{
+			change:     Change{Action: ActionUnchanged, Pigment: existing},
+			archived:   existing.DeletedAt.Valid,
+			references: make(map[string]bool),
+			bands:      catalog.Bands[existing.ID],
+		}
+		for _, reference := range catalog.References[existing.ID] {
+			s.references[referenceKey(reference.Technique, reference.Spectrum)] = true
+		}
+		return s
+	}
+	return &slot{
+		change:     Change{Action: ActionCreate, Pigment: ds.Pigment{Name: pigment.Name}},
+		references: make(map[string]bool),
+	}
+}
+
+func (s *slot) merge(entry Entry, overwrite bool) {
+	s.change.Origins = append(s.change.Origins, entry.Origin)
+	creating := s.change.Action == ActionCreate
+
+	for _, field := range pigmentFields {
+		value := field.get(&entry.Pigment)
+		current := field.get(&s.change.Pigment)
+		if value == "" || value == current {
+			continue
+		}
+		if current == "" || (overwrite && !creating) {
+			field.set(&s.change.Pigment, value)
+			s.change.Fields = setField(s.change.Fields, FieldChange{Field: field.name, Old: originalValue(s.change.Fields, field.name, current), New: value})
+			continue
+		}
+		// Две записи библиотеки или библиотека и каталог расходятся: остаётся первое значение
+		s.change.Fields = append(s.change.Fields, FieldChange{Field: field.name, Old: current, New: value, Conflict: true})
+	}
+
+	for _, reference := range entry.References {
+		key := referenceKey(reference.Technique, reference.Spectrum.String())
+		if s.references[key] {
+			s.change.Duplicates++
+			continue
+		}
+		s.references[key] = true
+		s.change.References = append(s.change.References, reference)
+	}
+
+	if entry.Bands != nil && !sameBands(s.bands, entry.Bands) {
+		s.bands = entry.Bands
+		s.change.Bands = entry.Bands
+	}
+}
+
+// referenceKey - ключ эталона для поиска дублей: спектр приводится к каноническому виду
+func referenceKey(technique, spectrum string) string {
+	if parsed, err := spectra.ParseTechnique(spectrum, technique); err == nil {
+		spectrum = parsed.String()
+	}
+	return technique + "|" + spectrum
+}
+
+// setField заменяет изменение поля, если оно уже было, иначе добавляет
+func setField(fields []FieldChange, change FieldChange) []FieldChange {
+	for i, f := range fields {
+		if f.Field == change.Field && !f.Conflict {
+			fields[i] = change
+			return fields
+		}
+	}
+	return append(fields, change)
+}
+
+// originalValue - значение поля в каталоге до первой правки в этом плане
+func originalValue(fields []FieldChange, name, current string) string {
+	for _, f := range fields {
+		if f.Field == name && !f.Conflict {
+			return f.Old
+		}
+	}
+	return current
+}
+
+// pigmentIndex находит пигменты, совпадающие с записью библиотеки, по номеру CI или
+// нормализованному названию без перебора. Пигменты нумеруются в порядке добавления;
+// из нескольких совпавших выбирается добавленный первым
+type pigmentIndex struct {
+	pigments []*ds.Pigment
+	byName   map[string][]int
+	byCI     map[string][]int
+}
+
+func newPigmentIndex() *pigmentIndex {
+	return &pigmentIndex{byName: make(map[string][]int), byCI: make(map[string][]int)}
+}
+
+// add добавляет пигмент; название пигмента потом не должно меняться, а о смене
+// номера CI сообщает addCI
+func (x *pigmentIndex) add(pigment *ds.Pigment) {
+	i := len(x.pigments)
+	x.pigments = append(x.pigments, pigment)
+	name := normalizeName(pigment.Name)
+	x.byName[name] = append(x.byName[name], i)
+	x.addCI(i)
+}
+
+// addCI индексирует текущий номер CI пигмента i. Старый номер не удаляется:
+// find всё равно сверяет номер с пигментом
+func (x *pigmentIndex) addCI(i int) {
+	if number := x.pigments[i].CINumber; number != "" {
+		x.byCI[number] = append(x.byCI[number], i)
+	}
+}
+
+// find возвращает номер первого пигмента, совпадающего с pigment, или -1
+func (x *pigmentIndex) find(pigment ds.Pigment) int {
+	found := -1
+	if pigment.CINumber != "" {
+		for _, i := range x.byCI[pigment.CINumber] {
+			if (found < 0 || i < found) && sameCI(*x.pigments[i], pigment) {
+				found = i
+			}
+		}
+	}
+	// номера по названию идут по возрастанию: названия не меняются
+	if named := x.byName[normalizeName(pigment.Name)]; len(named) > 0 && (found < 0 || named[0] < found) {
+		found = named[0]
+	}
+	return found
+}
+
+// sameCI - пигменты совпадают по номеру Colour Index, и обозначения CI не противоречат друг другу
+func sameCI(a, b ds.Pigment) bool {
+	return a.CINumber != "" && a.CINumber == b.CINumber &&
+		(a.CIName == "" || b.CIName == "" || strings.EqualFold(a.CIName, b.CIName))
+}
+
+func normalizeName(name string) string {
+	name = strings.ReplaceAll(strings.ToLower(name), "ё", "е")
+	return strings.Join(strings.Fields(name), " ")
+}
+
+func sameBands(a, b []ds.RamanBand) bool {
+	return slices.EqualFunc(a, b, func(x, y ds.RamanBand) bool {
+		return x.Position == y.Position && x.Tolerance == y.Tolerance && x.Strength == y.Strength && x.Assignment == y.Assignment
+	})
+}
+
+func formatYear(year *int) string {
+	if year == nil {
+		return ""
+	}
+	return strconv.Itoa(*year)
+}
+
+func parseYear(text string) *int {
+	year, err := strconv.Atoi(text)
+	if err != nil {
+		return nil
+	}
+	return &year
+}
+
+// Summary - количество пигментов по действиям и добавляемых эталонов
+func (p Plan) Summary() string {
+	counts := make(map[string]int)
+	references := 0
+	for _, change := range p.Changes {
+		counts[change.Action]++
+		if change.Action == ActionCreate || change.Action == ActionUpdate {
+			references += len(change.References)
+		}
+	}
+	return fmt.Sprintf("%d to create, %d to update, %d unchanged, %d skipped; %d reference spectra to add",
+		counts[ActionCreate], counts[ActionUpdate], counts[ActionUnchanged], counts[ActionSkip], references)
+}
+
+// WriteReport печатает план как отчёт о различиях
+func (p Plan) WriteReport(w io.Writer) error {
+	marks := map[string]string{ActionCreate: "+", ActionUpdate: "~", ActionUnchanged: "=", ActionSkip: "-"}
+	var b strings.Builder
+	for _, change := range p.Changes {
+		name := change.Pigment.Name
+		if change.Pigment.ID != 0 {
+			name = fmt.Sprintf("%s #%d", name, change.Pigment.ID)
+		}
+		fmt.Fprintf(&b, "%s %-9s %s  (%s)\n", marks[change.Action], change.Action, name, strings.Join(change.Origins, ", "))
+		if change.Action == ActionSkip {
+			b.WriteString("      pigment is archived, restore it to import\n")
+			continue
+		}
+		for _, field := range change.Fields {
+			if field.Conflict {
+				fmt.Fprintf(&b, "    ! %s: keeping %q, library has %q\n", field.Field, field.Old, field.New)
+			} else {
+				fmt.Fprintf(&b, "      %s: %q -> %q\n", field.Field, field.Old, field.New)
+			}
+		}
+		for _, reference := range change.References {
+			from, to := reference.Spectrum.Range()
+			fmt.Fprintf(&b, "      + %s reference %g-%g, %d points (%s)\n", reference.Technique, from, to, len(reference.Spectrum), reference.Source)
+		}
+		if change.Duplicates > 0 {
+			fmt.Fprintf(&b, "      = %d reference spectra already in catalog\n", change.Duplicates)
+		}
+		if change.Bands != nil {
+			fmt.Fprintf(&b, "      raman bands replaced: %d bands\n", len(change.Bands))
+		}
+	}
+	fmt.Fprintf(&b, "%s\n", p.Summary())
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Apply записывает план в каталог одной транзакцией: при ошибке каталог остаётся
+// таким, каким был до импорта
+func Apply(ctx context.Context, store repository.PigmentStore, plan Plan) error {
+	return store.InTransaction(ctx, func(store repository.PigmentStore) error {
+		for _, change := range plan.Changes {
+			if err := applyChange(ctx, store, change); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func applyChange(ctx context.Context, store repository.PigmentStore, change Change) error {
+	pigment := change.Pigment
+	switch change.Action {
+	case ActionCreate:
+		if err := store.CreatePigment(ctx, &pigment); err != nil {
+			return fmt.Errorf("create %s: %w", pigment.Name, err)
+		}
+	case ActionUpdate:
+		if slices.ContainsFunc(change.Fields, func(f FieldChange) bool { return !f.Conflict }) {
+			if err := store.UpdatePigment(ctx, &pigment); err != nil {
+				return fmt.Errorf("update %s: %w", pigment.Name, err)
+			}
+		}
+	default:
+		return nil
+	}
+
+	for _, reference := range change.References {
+		stored := ds.ReferenceSpectrum{
+			PigmentID: pigment.ID,
+			Technique: reference.Technique,
+			Spectrum:  reference.Spectrum.String(),
+			Source:    reference.Source,
+			Features:  refindex.ReferenceFeatures(reference.Technique, reference.Spectrum),
+		}
+		if err := store.AddReferenceSpectrum(ctx, &stored); err != nil {
+			return fmt.Errorf("add %s reference to %s: %w", reference.Technique, pigment.Name, err)
+		}
+	}
+	if change.Bands != nil {
+		if err := store.ReplaceRamanBands(ctx, pigment.ID, slices.Clone(change.Bands)); err != nil {
+			return fmt.Errorf("replace raman bands of %s: %w", pigment.Name, err)
+		}
+	}
+	return nil
+}
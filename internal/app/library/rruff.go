@@ -0,0 +1,104 @@
+package library
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"colorLex/internal/app/spectra"
+)
+
+// chargeMarkup - степени окисления в химических формулах RRUFF: Fe^3+^
+var chargeMarkup = regexp.MustCompile(`\^[^^]*\^`)
+
+// ParseRRUFF разбирает спектр RRUFF: заголовки ##NAMES=, ##RRUFFID=, ##IDEAL CHEMISTRY=
+// и точки "x, y" до ##END=. Файл без заголовков - обычный двухколоночный CSV,
+// тогда название берётся из fallbackName
+func ParseRRUFF(text, fallbackName, technique string) (Entry, error) {
+	headers := make(map[string]string)
+	var points spectra.Spectrum
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "##") {
+			key, value, _ := strings.Cut(strings.TrimPrefix(line, "##"), "=")
+			key = strings.ToUpper(strings.TrimSpace(key))
+			if key == "END" {
+				break
+			}
+			headers[key] = strings.TrimSpace(value)
+			continue
+		}
+		point, ok := parsePoint(line)
+		if !ok {
+			// Строка заголовка CSV вроде "wavenumber,intensity"
+			if len(points) == 0 {
+				continue
+			}
+			return Entry{}, errors.New("unexpected line " + strconv.Quote(line))
+		}
+		points = append(points, point)
+	}
+
+	spectrum, err := sortPoints(points)
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := checkSpectrum(spectrum, technique); err != nil {
+		return Entry{}, err
+	}
+
+	entry := Entry{}
+	entry.Pigment.Name = firstName(headers["NAMES"])
+	if entry.Pigment.Name == "" {
+		entry.Pigment.Name = fallbackName
+	}
+	if entry.Pigment.Name == "" {
+		return Entry{}, errors.New("pigment name is missing")
+	}
+	if chemistry := headers["IDEAL CHEMISTRY"]; chemistry != "" {
+		entry.Pigment.Formula = strings.ReplaceAll(chargeMarkup.ReplaceAllString(chemistry, ""), "_", "")
+	}
+	source := "RRUFF"
+	if id := headers["RRUFFID"]; id != "" {
+		source += " " + id
+	}
+	entry.References = []Reference{{Technique: technique, Spectrum: spectrum, Source: source}}
+	return entry, nil
+}
+
+// firstName - первое из перечисленных через запятую названий минерала
+func firstName(names string) string {
+	name, _, _ := strings.Cut(names, ",")
+	return strings.TrimSpace(name)
+}
+
+// parsePoint разбирает строку "x, y" или "x y ..." с двумя и более числами
+func parsePoint(line string) (spectra.Point, bool) {
+	fields := strings.FieldsFunc(line, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t'
+	})
+	if len(fields) < 2 {
+		return spectra.Point{}, false
+	}
+	x, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return spectra.Point{}, false
+	}
+	y, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return spectra.Point{}, false
+	}
+	return spectra.Point{Wavelength: x, Value: y}, true
+}
+
+// sortPoints упорядочивает точки и проверяет их так же, как spectra.Parse
+func sortPoints(points spectra.Spectrum) (spectra.Spectrum, error) {
+	if len(points) == 0 {
+		return nil, spectra.ErrEmpty
+	}
+	return spectra.Parse(points.String())
+}
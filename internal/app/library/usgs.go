@@ -0,0 +1,81 @@
+package library
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"colorLex/internal/app/spectra"
+)
+
+// usgsDeleted - значение, которым USGS помечает выброшенные точки
+const usgsDeleted = -1.0e30
+
+// ParseUSGS разбирает ASCII-файл спектральной библиотеки USGS: заголовок с
+// "Record=NNNN: Название образец ..." и столбцы "длина волны отражение [погрешность]".
+// Длины волн в микрометрах переводятся в нанометры
+func ParseUSGS(text string) (Entry, error) {
+	var title string
+	var points spectra.Spectrum
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if point, ok := parsePoint(line); ok {
+			if point.Value > usgsDeleted {
+				points = append(points, point)
+			}
+			continue
+		}
+		if len(points) > 0 {
+			return Entry{}, errors.New("unexpected line after data: " + line)
+		}
+		if strings.Contains(line, "Record=") && title == "" {
+			title = line
+		}
+	}
+	if title == "" {
+		return Entry{}, errors.New("record title is missing")
+	}
+	if len(points) == 0 {
+		// В одностолбцовых файлах splib длины волн лежат в отдельном файле
+		return Entry{}, errors.New("no two-column data: export wavelength and reflectance columns")
+	}
+
+	// Микрометры: вся ось укладывается в несколько единиц
+	if len(points) > 0 && points[len(points)-1].Wavelength < 30 {
+		for i := range points {
+			points[i].Wavelength *= 1000
+		}
+	}
+	spectrum, err := sortPoints(points)
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := checkSpectrum(spectrum, spectra.TechniqueReflectance); err != nil {
+		return Entry{}, err
+	}
+
+	entry := Entry{}
+	entry.Pigment.Name = usgsName(title)
+	if entry.Pigment.Name == "" {
+		return Entry{}, errors.New("pigment name is missing in " + title)
+	}
+	entry.References = []Reference{{Technique: spectra.TechniqueReflectance, Spectrum: spectrum, Source: "USGS " + title}}
+	return entry, nil
+}
+
+// usgsName - слова названия после "Record=NNNN:" до обозначения образца (первого слова с цифрой)
+func usgsName(title string) string {
+	_, rest, _ := strings.Cut(title, "Record=")
+	_, rest, _ = strings.Cut(rest, ":")
+	var words []string
+	for _, word := range strings.Fields(rest) {
+		if strings.IndexFunc(word, unicode.IsDigit) >= 0 {
+			break
+		}
+		words = append(words, word)
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,153 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"colorLex/internal/app/refindex"
+	"colorLex/internal/app/spectra"
+)
+
+// funcs - версии, которым кроме SQL нужен код на Go
+var funcs = map[int]struct{ Up, Down Func }{
+	18: {Up: packAnalysisSpectra, Down: unpackAnalysisSpectra},
+	20: {Up: computeReferenceFeatures},
+}
+
+// packAnalysisSpectra переводит текстовые спектры заявок в формат spectra.Packed.
+// Текст, который не разбирается, не теряется: он переносится в spectrum_analysis_unparsed,
+// а заявка помечается spectrum_unparsed
+func packAnalysisSpectra(ctx context.Context, tx *sql.Tx) error {
+	type row struct {
+		id       string
+		spectrum string
+	}
+	// Сначала читаем всё: на одном соединении нельзя писать, пока открыт курсор
+	rows, err := tx.QueryContext(ctx, "SELECT id, spectrum FROM spectrum_analysis WHERE btrim(coalesce(spectrum, '')) <> ''")
+	if err != nil {
+		return err
+	}
+	var pending []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.spectrum); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var unparsed []string
+	for _, r := range pending {
+		spectrum, err := spectra.Parse(r.spectrum)
+		var packed spectra.Packed
+		if err == nil {
+			packed, err = spectra.Pack(spectrum)
+		}
+		if err != nil {
+			unparsed = append(unparsed, r.id)
+			if _, err := tx.ExecContext(ctx,
+				"INSERT INTO spectrum_analysis_unparsed (spectrum_analysis_id, spectrum, error) VALUES ($1, $2, $3)",
+				r.id, r.spectrum, err.Error()); err != nil {
+				return fmt.Errorf("analysis %s: %w", r.id, err)
+			}
+			if _, err := tx.ExecContext(ctx, "UPDATE spectrum_analysis SET spectrum_unparsed = true WHERE id = $1", r.id); err != nil {
+				return fmt.Errorf("analysis %s: %w", r.id, err)
+			}
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE spectrum_analysis SET spectrum_packed = $1 WHERE id = $2", []byte(packed), r.id); err != nil {
+			return fmt.Errorf("analysis %s: %w", r.id, err)
+		}
+	}
+	if len(unparsed) > 0 {
+		log.Printf("migrations: %d of %d analysis spectra could not be parsed and are kept in spectrum_analysis_unparsed: %s",
+			len(unparsed), len(pending), strings.Join(unparsed, ", "))
+	}
+	return nil
+}
+
+// unpackAnalysisSpectra возвращает спектрам текстовую форму. Значения остаются
+// с точностью float32, с которой они хранились
+func unpackAnalysisSpectra(ctx context.Context, tx *sql.Tx) error {
+	type row struct {
+		id     string
+		packed []byte
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT id, spectrum_packed FROM spectrum_analysis WHERE spectrum_packed IS NOT NULL")
+	if err != nil {
+		return err
+	}
+	var pending []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.packed); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range pending {
+		spectrum, err := spectra.Packed(r.packed).Decode()
+		if err != nil {
+			return fmt.Errorf("analysis %s: %w", r.id, err)
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE spectrum_analysis SET spectrum = $1 WHERE id = $2", spectrum.String(), r.id); err != nil {
+			return fmt.Errorf("analysis %s: %w", r.id, err)
+		}
+	}
+	return nil
+}
+
+// computeReferenceFeatures считает признаки уже загруженных эталонов отражения.
+// Эталоны, которые не разбираются или не попадают на сетку признаков, остаются без них
+func computeReferenceFeatures(ctx context.Context, tx *sql.Tx) error {
+	type row struct {
+		id       uint
+		spectrum string
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT id, spectrum FROM reference_spectra WHERE technique = $1", spectra.TechniqueReflectance)
+	if err != nil {
+		return err
+	}
+	var pending []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.spectrum); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range pending {
+		spectrum, err := spectra.Parse(r.spectrum)
+		if err != nil {
+			continue
+		}
+		features := refindex.ReferenceFeatures(spectra.TechniqueReflectance, spectrum)
+		if features == nil {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE reference_spectra SET features = $1 WHERE id = $2", features, r.id); err != nil {
+			return fmt.Errorf("reference %d: %w", r.id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// testDB открывает отдельную схему в базе TEST_DATABASE_URL и удаляет её после теста.
+// Без TEST_DATABASE_URL тест пропускается: миграции проверяются только на живом Postgres
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	config, err := pgx.ParseConfig(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := stdlib.OpenDB(*config)
+	t.Cleanup(func() { admin.Close() })
+	schema := fmt.Sprintf("migrations_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	config.RuntimeParams["search_path"] = schema + ",public"
+	db := stdlib.OpenDB(*config)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// upTo применяет миграции до версии version включительно
+func upTo(t *testing.T, db *sql.DB, version int) *Migrator {
+	t.Helper()
+	migrator, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var migrations []Migration
+	for _, migration := range migrator.migrations {
+		if migration.Version <= version {
+			migrations = append(migrations, migration)
+		}
+	}
+	migrator = &Migrator{db: db, migrations: migrations}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return migrator
+}
+
+func TestPigmentTimestampsKeepPigmentsActive(t *testing.T) {
+	db := testDB(t)
+	upTo(t, db, 2)
+
+	// Так базовая схема хранила пигменты, созданные через API: обе метки заполнены
+	created := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	updated := time.Date(2024, 5, 2, 12, 30, 0, 0, time.UTC)
+	if _, err := db.Exec(
+		"INSERT INTO pigments (id, name, created_at, updated_at) VALUES (1, 'Ultramarine', $1, $2), (2, 'Vermilion', NULL, NULL)",
+		created, updated); err != nil {
+		t.Fatal(err)
+	}
+
+	migrator := upTo(t, db, 3)
+
+	var archived int
+	if err := db.QueryRow("SELECT count(*) FROM pigments WHERE deleted_at IS NOT NULL").Scan(&archived); err != nil {
+		t.Fatal(err)
+	}
+	if archived != 0 {
+		t.Fatalf("%d pigments archived by the migration, want 0", archived)
+	}
+	var gotCreated, gotUpdated time.Time
+	if err := db.QueryRow("SELECT created_at, updated_at FROM pigments WHERE id = 1").Scan(&gotCreated, &gotUpdated); err != nil {
+		t.Fatal(err)
+	}
+	if !gotCreated.Equal(created) || !gotUpdated.Equal(updated) {
+		t.Fatalf("timestamps after up: %v, %v; want %v, %v", gotCreated, gotUpdated, created, updated)
+	}
+	var missing int
+	if err := db.QueryRow("SELECT count(*) FROM pigments WHERE created_at IS NULL OR updated_at IS NULL").Scan(&missing); err != nil {
+		t.Fatal(err)
+	}
+	if missing != 0 {
+		t.Fatalf("%d pigments without timestamps after up, want 0", missing)
+	}
+
+	if _, err := migrator.Down(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT created_at, updated_at FROM pigments WHERE id = 1").Scan(&gotCreated, &gotUpdated); err != nil {
+		t.Fatal(err)
+	}
+	if !gotCreated.Equal(created) || !gotUpdated.Equal(updated) {
+		t.Fatalf("timestamps after down: %v, %v; want %v, %v", gotCreated, gotUpdated, created, updated)
+	}
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS spectrumanalysis_pigment;
+DROP TABLE IF EXISTS spectrum_analysis;
+DROP TABLE IF EXISTS pigments;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,42 @@
+-- Базовая схема в том виде, в котором её создавал AutoMigrate.
+-- IF NOT EXISTS позволяет принять под управление уже существующую базу.
+
+CREATE TABLE IF NOT EXISTS users (
+    id            bigserial PRIMARY KEY,
+    login         text CONSTRAINT uni_users_login UNIQUE,
+    password_hash text,
+    is_moderator  boolean
+);
+
+CREATE TABLE IF NOT EXISTS pigments (
+    id          bigserial PRIMARY KEY,
+    name        text,
+    brief       text,
+    description text,
+    image_key   text,
+    color       text,
+    specs       text,
+    created_at  timestamptz,
+    updated_at  timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS spectrum_analysis (
+    id           uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    name         text,
+    status       text,
+    created_at   timestamptz,
+    creator_id   bigint,
+    formed_at    timestamptz,
+    completed_at timestamptz,
+    moderator_id bigint,
+    spectrum     text
+);
+
+CREATE TABLE IF NOT EXISTS spectrumanalysis_pigment (
+    spectrum_analysis_id uuid,
+    pigment_id           bigint,
+    comment              text,
+    percent              decimal,
+    created_at           timestamptz,
+    PRIMARY KEY (spectrum_analysis_id, pigment_id)
+);
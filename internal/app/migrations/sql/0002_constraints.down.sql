@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_spectrumanalysis_pigment_pigment_id;
+DROP INDEX IF EXISTS idx_spectrum_analysis_creator_status;
+
+ALTER TABLE spectrumanalysis_pigment DROP CONSTRAINT IF EXISTS fk_spectrumanalysis_pigment_pigment;
+ALTER TABLE spectrumanalysis_pigment DROP CONSTRAINT IF EXISTS fk_spectrumanalysis_pigment_analysis;
@@ -0,0 +1,13 @@
+-- Связи и индексы, которых не было при AutoMigrate.
+-- NOT VALID не проверяет старые строки, поэтому миграция не падает на исторических данных.
+
+ALTER TABLE spectrumanalysis_pigment
+    ADD CONSTRAINT fk_spectrumanalysis_pigment_analysis
+    FOREIGN KEY (spectrum_analysis_id) REFERENCES spectrum_analysis (id) ON DELETE CASCADE NOT VALID;
+
+ALTER TABLE spectrumanalysis_pigment
+    ADD CONSTRAINT fk_spectrumanalysis_pigment_pigment
+    FOREIGN KEY (pigment_id) REFERENCES pigments (id) NOT VALID;
+
+CREATE INDEX IF NOT EXISTS idx_spectrum_analysis_creator_status ON spectrum_analysis (creator_id, status);
+CREATE INDEX IF NOT EXISTS idx_spectrumanalysis_pigment_pigment_id ON spectrumanalysis_pigment (pigment_id);
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_pigments_created_at;
+DROP INDEX IF EXISTS idx_pigments_deleted_at;
+
+ALTER TABLE pigments
+    ALTER COLUMN updated_at DROP NOT NULL,
+    ALTER COLUMN updated_at DROP DEFAULT,
+    ALTER COLUMN created_at DROP NOT NULL,
+    ALTER COLUMN created_at DROP DEFAULT;
+
+ALTER TABLE pigments
+    DROP COLUMN deleted_at;
@@ -0,0 +1,19 @@
+-- created_at/updated_at раньше были объявлены как gorm.DeletedAt, но GORM всё равно
+-- заполнял их при вставке и обновлении, так что непустая метка означает лишь, что
+-- строка существует. Делаем их обычными временными метками и заводим deleted_at:
+-- существующие пигменты остаются активными.
+
+ALTER TABLE pigments
+    ADD COLUMN deleted_at timestamptz;
+
+UPDATE pigments SET created_at = now() WHERE created_at IS NULL;
+UPDATE pigments SET updated_at = created_at WHERE updated_at IS NULL;
+
+ALTER TABLE pigments
+    ALTER COLUMN created_at SET DEFAULT now(),
+    ALTER COLUMN created_at SET NOT NULL,
+    ALTER COLUMN updated_at SET DEFAULT now(),
+    ALTER COLUMN updated_at SET NOT NULL;
+
+CREATE INDEX idx_pigments_deleted_at ON pigments (deleted_at);
+CREATE INDEX idx_pigments_created_at ON pigments (created_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS uniq_spectrum_analysis_draft_per_creator;
+ALTER TABLE spectrum_analysis DROP COLUMN version;
@@ -0,0 +1,30 @@
+-- Версия заявки для оптимистичной блокировки (ETag / If-Match)
+ALTER TABLE spectrum_analysis ADD COLUMN version bigint NOT NULL DEFAULT 1;
+
+-- У пользователя может быть только один черновик. Если гонка уже успела
+-- создать несколько, переносим пигменты в самый свежий, остальные помечаем удалёнными.
+WITH ranked AS (
+    SELECT id, creator_id,
+           first_value(id) OVER (PARTITION BY creator_id ORDER BY created_at DESC, id DESC) AS keep_id
+    FROM spectrum_analysis
+    WHERE status = 'draft'
+)
+INSERT INTO spectrumanalysis_pigment (spectrum_analysis_id, pigment_id, comment, percent, created_at)
+SELECT ranked.keep_id, link.pigment_id, link.comment, link.percent, link.created_at
+FROM ranked
+JOIN spectrumanalysis_pigment link ON link.spectrum_analysis_id = ranked.id
+WHERE ranked.id <> ranked.keep_id
+ON CONFLICT (spectrum_analysis_id, pigment_id) DO NOTHING;
+
+WITH ranked AS (
+    SELECT id,
+           first_value(id) OVER (PARTITION BY creator_id ORDER BY created_at DESC, id DESC) AS keep_id
+    FROM spectrum_analysis
+    WHERE status = 'draft'
+)
+UPDATE spectrum_analysis SET status = 'deleted'
+FROM ranked
+WHERE spectrum_analysis.id = ranked.id AND ranked.id <> ranked.keep_id;
+
+CREATE UNIQUE INDEX uniq_spectrum_analysis_draft_per_creator
+    ON spectrum_analysis (creator_id) WHERE status = 'draft';
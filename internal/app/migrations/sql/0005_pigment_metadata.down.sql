@@ -0,0 +1,18 @@
+DROP INDEX IF EXISTS idx_pigments_class;
+DROP INDEX IF EXISTS idx_pigments_ci_name;
+
+ALTER TABLE pigments
+    DROP CONSTRAINT IF EXISTS chk_pigments_particle_size,
+    DROP CONSTRAINT IF EXISTS chk_pigments_lightfastness,
+    DROP CONSTRAINT IF EXISTS chk_pigments_toxicity,
+    DROP CONSTRAINT IF EXISTS chk_pigments_class,
+    DROP COLUMN IF EXISTS particle_shape,
+    DROP COLUMN IF EXISTS particle_size_max_um,
+    DROP COLUMN IF EXISTS particle_size_min_um,
+    DROP COLUMN IF EXISTS toxicity,
+    DROP COLUMN IF EXISTS lightfastness,
+    DROP COLUMN IF EXISTS class,
+    DROP COLUMN IF EXISTS cas_number,
+    DROP COLUMN IF EXISTS formula,
+    DROP COLUMN IF EXISTS ci_number,
+    DROP COLUMN IF EXISTS ci_name;
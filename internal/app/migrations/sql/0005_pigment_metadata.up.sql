@@ -0,0 +1,62 @@
+-- Структурированные технические характеристики пигмента вместо свободного текста в specs.
+-- specs остаётся как есть: из него один раз вытаскиваем то, что удаётся распознать.
+
+ALTER TABLE pigments
+    ADD COLUMN ci_name              text NOT NULL DEFAULT '',
+    ADD COLUMN ci_number            text NOT NULL DEFAULT '',
+    ADD COLUMN formula              text NOT NULL DEFAULT '',
+    ADD COLUMN cas_number           text NOT NULL DEFAULT '',
+    ADD COLUMN class                text NOT NULL DEFAULT '',
+    ADD COLUMN lightfastness        smallint,
+    ADD COLUMN toxicity             text NOT NULL DEFAULT '',
+    ADD COLUMN particle_size_min_um double precision,
+    ADD COLUMN particle_size_max_um double precision,
+    ADD COLUMN particle_shape       text NOT NULL DEFAULT '',
+    ADD CONSTRAINT chk_pigments_class
+        CHECK (class IN ('', 'earth', 'mineral', 'lake', 'synthetic_organic')),
+    ADD CONSTRAINT chk_pigments_toxicity
+        CHECK (toxicity IN ('', 'none', 'low', 'moderate', 'high')),
+    ADD CONSTRAINT chk_pigments_lightfastness
+        CHECK (lightfastness BETWEEN 1 AND 5),
+    ADD CONSTRAINT chk_pigments_particle_size
+        CHECK (particle_size_min_um > 0 AND particle_size_min_um <= particle_size_max_um);
+
+-- Colour Index Generic Name: PB29, PY 43, PBr7:1 -> без пробела
+UPDATE pigments
+SET ci_name = regexp_replace(
+        substring(specs FROM '\m(P(?:Bk|Br|B|R|Y|G|O|V|W|M)\s?\d{1,3}(?::\d+)?)\M'),
+        '\s', '', 'g')
+WHERE specs ~ '\mP(?:Bk|Br|B|R|Y|G|O|V|W|M)\s?\d{1,3}(?::\d+)?\M';
+
+-- Colour Index Constitution Number: "C.I. 77007", "CI 77491", "Colour Index No. 77007"
+UPDATE pigments
+SET ci_number = substring(specs FROM '(?:C\.?\s?I\.?|[Cc]olou?r [Ii]ndex)\s*(?:No\.?|№)?\s*(\d{5})\M')
+WHERE specs ~ '(?:C\.?\s?I\.?|[Cc]olou?r [Ii]ndex)\s*(?:No\.?|№)?\s*\d{5}\M';
+
+-- CAS: 2-7 цифр, 2 цифры, контрольная цифра
+UPDATE pigments
+SET cas_number = substring(specs FROM '\m(\d{2,7}-\d{2}-\d)\M')
+WHERE specs ~ '\m\d{2,7}-\d{2}-\d\M';
+
+-- Номер с неверной контрольной цифрой не записываем: так же проверяет validCASNumber.
+-- Цифры до контрольной берутся справа налево с весами 1, 2, 3...
+UPDATE pigments
+SET cas_number = ''
+WHERE cas_number <> ''
+  AND (SELECT sum(substr(d.digits, length(d.digits) - i + 1, 1)::int * i) % 10
+       FROM (SELECT replace(left(cas_number, -2), '-', '') AS digits) d,
+            generate_series(1, length(d.digits)) AS i) <> right(cas_number, 1)::int;
+
+-- Формула: после явной метки ("Химическая формула: HgS") или после "Состав:",
+-- если за ним идёт одна формула без пояснений ("Состав: Al2Si2O5(OH)4")
+UPDATE pigments
+SET formula = btrim(substring(specs FROM '(?i)(?:химическая формула|формула|formula)\s*:\s*([^,;\n]+)'))
+WHERE specs ~* '(?:химическая формула|формула|formula)\s*:';
+
+UPDATE pigments
+SET formula = substring(specs FROM '(?i)состав\s*:\s*([A-Z][A-Za-z0-9()·.-]*)\s*(?:$|[,;\n])')
+WHERE formula = ''
+  AND specs ~* 'состав\s*:\s*[A-Z][A-Za-z0-9()·.-]*\s*(?:$|[,;\n])';
+
+CREATE INDEX idx_pigments_ci_name ON pigments (ci_name);
+CREATE INDEX idx_pigments_class ON pigments (class);
@@ -0,0 +1,8 @@
+ALTER TABLE spectrum_analysis
+    DROP COLUMN IF EXISTS terminus_post_quem,
+    DROP COLUMN IF EXISTS claimed_year;
+
+ALTER TABLE pigments
+    DROP CONSTRAINT IF EXISTS chk_pigments_available_range,
+    DROP COLUMN IF EXISTS available_to,
+    DROP COLUMN IF EXISTS available_from;
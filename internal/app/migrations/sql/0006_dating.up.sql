@@ -0,0 +1,35 @@
+-- Датировка: годы доступности пигмента, заявленная дата создания произведения
+-- и terminus post quem, который вычисляется при завершении анализа.
+
+ALTER TABLE pigments
+    ADD COLUMN available_from integer,
+    ADD COLUMN available_to   integer,
+    ADD CONSTRAINT chk_pigments_available_range
+        CHECK (available_from <= available_to);
+
+ALTER TABLE spectrum_analysis
+    ADD COLUMN claimed_year       integer,
+    ADD COLUMN terminus_post_quem integer;
+
+-- Синтетические пигменты с общепринятой датой появления. Природные пигменты
+-- (охры, свинцовые белила, киноварь) и PB29, под которым идут и лазурит,
+-- и синтетический ультрамарин, не трогаем.
+UPDATE pigments p
+SET available_from = d.year
+FROM (VALUES
+    ('PB27', 1704),  -- берлинская лазурь
+    ('PB28', 1802),  -- кобальтовая синь
+    ('PY34', 1814),  -- хромовая жёлтая
+    ('PW4', 1834),   -- цинковые белила
+    ('PG18', 1838),  -- изумрудная зелень (виридиан)
+    ('PY37', 1846),  -- кадмий жёлтый
+    ('PB35', 1860),  -- церулеум
+    ('PV16', 1868),  -- марганцевый фиолетовый
+    ('PR83', 1868),  -- синтетический ализарин
+    ('PR108', 1910), -- кадмий красный
+    ('PW6', 1921),   -- титановые белила
+    ('PB15', 1935),  -- фталоцианиновый синий
+    ('PG7', 1938)    -- фталоцианиновый зелёный
+) AS d(ci_name, year)
+WHERE p.ci_name = d.ci_name
+  AND p.available_from IS NULL;
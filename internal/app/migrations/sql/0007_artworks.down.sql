@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_spectrum_analysis_artwork_status;
+
+ALTER TABLE spectrum_analysis
+    DROP COLUMN IF EXISTS artwork_id;
+
+DROP TABLE IF EXISTS artworks;
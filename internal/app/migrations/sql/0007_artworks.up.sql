@@ -0,0 +1,28 @@
+-- Произведения, к которым привязываются анализы: одно произведение - много проб.
+
+CREATE TABLE artworks (
+    id               bigserial PRIMARY KEY,
+    title            text NOT NULL,
+    artist           text NOT NULL DEFAULT '',
+    date             text NOT NULL DEFAULT '',
+    year             integer,
+    inventory_number text NOT NULL DEFAULT '',
+    collection       text NOT NULL DEFAULT '',
+    image_key        text NOT NULL DEFAULT '',
+    creator_id       bigint NOT NULL REFERENCES users (id),
+    created_at       timestamptz NOT NULL DEFAULT now(),
+    updated_at       timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT chk_artworks_title CHECK (title <> '')
+);
+
+-- Инвентарный номер уникален в пределах собрания
+CREATE UNIQUE INDEX uniq_artworks_inventory
+    ON artworks (collection, inventory_number)
+    WHERE inventory_number <> '';
+
+-- Удаление произведения не удаляет анализы, а только отвязывает их
+ALTER TABLE spectrum_analysis
+    ADD COLUMN artwork_id bigint
+        CONSTRAINT fk_spectrum_analysis_artwork REFERENCES artworks (id) ON DELETE SET NULL;
+
+CREATE INDEX idx_spectrum_analysis_artwork_status ON spectrum_analysis (artwork_id, status);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS measurement_points;
@@ -0,0 +1,20 @@
+-- Точка отбора пробы на фотографии произведения, по одной на анализ.
+-- artwork_id фиксирует, к какому изображению относятся координаты: при перепривязке
+-- анализа к другому произведению точка перестаёт показываться, пока её не отметят заново.
+
+CREATE TABLE measurement_points (
+    spectrum_analysis_id uuid PRIMARY KEY
+        CONSTRAINT fk_measurement_points_analysis REFERENCES spectrum_analysis (id) ON DELETE CASCADE,
+    artwork_id           bigint NOT NULL
+        CONSTRAINT fk_measurement_points_artwork REFERENCES artworks (id) ON DELETE CASCADE,
+    x                    double precision NOT NULL,
+    y                    double precision NOT NULL,
+    label                text NOT NULL DEFAULT '',
+    layer                text NOT NULL DEFAULT '',
+    created_at           timestamptz NOT NULL DEFAULT now(),
+    updated_at           timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT chk_measurement_points_x CHECK (x BETWEEN 0 AND 1),
+    CONSTRAINT chk_measurement_points_y CHECK (y BETWEEN 0 AND 1)
+);
+
+CREATE INDEX idx_measurement_points_artwork ON measurement_points (artwork_id);
@@ -0,0 +1,6 @@
+ALTER TABLE spectrum_analysis
+    DROP CONSTRAINT IF EXISTS chk_spectrum_analysis_outlier_method,
+    DROP COLUMN IF EXISTS outlier_threshold,
+    DROP COLUMN IF EXISTS outlier_method;
+
+DROP TABLE IF EXISTS spectrum_readings;
@@ -0,0 +1,19 @@
+-- Повторные измерения спектра в одной точке. Одиночный spectrum_analysis.spectrum
+-- остаётся для заявок без повторных измерений.
+
+CREATE TABLE spectrum_readings (
+    id                   bigserial PRIMARY KEY,
+    spectrum_analysis_id uuid NOT NULL
+        CONSTRAINT fk_spectrum_readings_analysis REFERENCES spectrum_analysis (id) ON DELETE CASCADE,
+    spectrum             text NOT NULL,
+    note                 text NOT NULL DEFAULT '',
+    created_at           timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_spectrum_readings_analysis ON spectrum_readings (spectrum_analysis_id, id);
+
+ALTER TABLE spectrum_analysis
+    ADD COLUMN outlier_method    text NOT NULL DEFAULT '',
+    ADD COLUMN outlier_threshold double precision,
+    ADD CONSTRAINT chk_spectrum_analysis_outlier_method
+        CHECK (outlier_method IN ('', 'none', 'mad', 'rmsd'));
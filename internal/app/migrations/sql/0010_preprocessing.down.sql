@@ -0,0 +1,2 @@
+ALTER TABLE spectrum_analysis
+    DROP COLUMN IF EXISTS preprocessing;
@@ -0,0 +1,5 @@
+-- Шаги предобработки спектра, с которыми обсчитывается заявка (JSON, см. spectra.Pipeline).
+-- Хранятся вместе с заявкой, чтобы результат можно было воспроизвести.
+
+ALTER TABLE spectrum_analysis
+    ADD COLUMN preprocessing text NOT NULL DEFAULT '';
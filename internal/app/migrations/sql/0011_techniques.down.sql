@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS reference_spectra;
+
+ALTER TABLE spectrum_readings
+    DROP CONSTRAINT IF EXISTS chk_spectrum_readings_technique,
+    DROP COLUMN IF EXISTS technique;
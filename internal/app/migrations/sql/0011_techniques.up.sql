@@ -0,0 +1,20 @@
+-- Методики измерения: спектры отражения, рамановские и рентгенофлуоресцентные.
+-- Повторные измерения получают методику, пигменты - эталонные спектры по методикам.
+
+ALTER TABLE spectrum_readings
+    ADD COLUMN technique text NOT NULL DEFAULT 'reflectance',
+    ADD CONSTRAINT chk_spectrum_readings_technique
+        CHECK (technique IN ('reflectance', 'raman', 'xrf'));
+
+CREATE TABLE reference_spectra (
+    id         bigserial PRIMARY KEY,
+    pigment_id bigint NOT NULL
+        CONSTRAINT fk_reference_spectra_pigment REFERENCES pigments (id) ON DELETE CASCADE,
+    technique  text NOT NULL
+        CONSTRAINT chk_reference_spectra_technique CHECK (technique IN ('reflectance', 'raman', 'xrf')),
+    spectrum   text NOT NULL,
+    source     text NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_reference_spectra_technique ON reference_spectra (technique, pigment_id);
@@ -0,0 +1,7 @@
+ALTER TABLE spectrumanalysis_pigment
+    DROP CONSTRAINT IF EXISTS chk_spectrumanalysis_pigment_verdict,
+    DROP COLUMN IF EXISTS raman_score,
+    DROP COLUMN IF EXISTS verdict_note,
+    DROP COLUMN IF EXISTS verdict;
+
+DROP TABLE IF EXISTS raman_bands;
@@ -0,0 +1,22 @@
+-- Библиотека характеристических рамановских полос пигментов и решения модератора
+-- по идентификации пигментов заявки.
+
+CREATE TABLE raman_bands (
+    id         bigserial PRIMARY KEY,
+    pigment_id bigint NOT NULL
+        CONSTRAINT fk_raman_bands_pigment REFERENCES pigments (id) ON DELETE CASCADE,
+    position   double precision NOT NULL,
+    tolerance  double precision NOT NULL,
+    strength   text NOT NULL
+        CONSTRAINT chk_raman_bands_strength CHECK (strength IN ('strong', 'medium', 'weak')),
+    assignment text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_raman_bands_pigment ON raman_bands (pigment_id, position);
+
+ALTER TABLE spectrumanalysis_pigment
+    ADD COLUMN verdict text NOT NULL DEFAULT '',
+    ADD COLUMN verdict_note text NOT NULL DEFAULT '',
+    ADD COLUMN raman_score double precision,
+    ADD CONSTRAINT chk_spectrumanalysis_pigment_verdict
+        CHECK (verdict IN ('', 'accepted', 'rejected'));
@@ -0,0 +1,4 @@
+ALTER TABLE spectrumanalysis_pigment
+    DROP COLUMN IF EXISTS indistinguishable,
+    DROP COLUMN IF EXISTS percent_high,
+    DROP COLUMN IF EXISTS percent_low;
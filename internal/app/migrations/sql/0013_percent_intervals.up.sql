@@ -0,0 +1,7 @@
+-- Неопределённость долей пигментов: доверительный интервал по бутстрепу остатков
+-- и список пигментов заявки, спектрально неотличимых от данного.
+
+ALTER TABLE spectrumanalysis_pigment
+    ADD COLUMN percent_low double precision,
+    ADD COLUMN percent_high double precision,
+    ADD COLUMN indistinguishable text NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+ALTER TABLE spectrum_readings
+    DROP COLUMN IF EXISTS raw,
+    DROP COLUMN IF EXISTS measured_at,
+    DROP COLUMN IF EXISTS calibration_id,
+    DROP COLUMN IF EXISTS instrument_id;
+
+DROP TABLE IF EXISTS instrument_calibrations;
+DROP TABLE IF EXISTS instruments;
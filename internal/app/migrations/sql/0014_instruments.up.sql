@@ -0,0 +1,43 @@
+-- Реестр спектрометров и их калибровок. Измерения ссылаются на прибор и калибровку,
+-- которой исправлены сырые отсчёты.
+
+CREATE TABLE instruments (
+    id         bigserial PRIMARY KEY,
+    name       text NOT NULL,
+    serial     text NOT NULL
+        CONSTRAINT uq_instruments_serial UNIQUE,
+    technique  text NOT NULL
+        CONSTRAINT chk_instruments_technique CHECK (technique IN ('reflectance', 'raman', 'xrf')),
+    range_from double precision NOT NULL,
+    range_to   double precision NOT NULL,
+    resolution double precision NOT NULL DEFAULT 0,
+    note       text NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT chk_instruments_range CHECK (range_from < range_to)
+);
+
+CREATE TABLE instrument_calibrations (
+    id                bigserial PRIMARY KEY,
+    instrument_id     bigint NOT NULL
+        CONSTRAINT fk_instrument_calibrations_instrument REFERENCES instruments (id) ON DELETE CASCADE,
+    valid_from        timestamptz NOT NULL,
+    valid_to          timestamptz,
+    dark              text NOT NULL DEFAULT '',
+    white             text NOT NULL DEFAULT '',
+    white_reflectance double precision NOT NULL DEFAULT 0,
+    wavelengths       text NOT NULL DEFAULT '',
+    note              text NOT NULL DEFAULT '',
+    created_at        timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT chk_instrument_calibrations_validity CHECK (valid_to IS NULL OR valid_from < valid_to)
+);
+
+CREATE INDEX idx_instrument_calibrations_instrument ON instrument_calibrations (instrument_id, valid_from);
+
+ALTER TABLE spectrum_readings
+    ADD COLUMN instrument_id bigint
+        CONSTRAINT fk_spectrum_readings_instrument REFERENCES instruments (id) ON DELETE RESTRICT,
+    ADD COLUMN calibration_id bigint
+        CONSTRAINT fk_spectrum_readings_calibration REFERENCES instrument_calibrations (id) ON DELETE RESTRICT,
+    ADD COLUMN measured_at timestamptz,
+    ADD COLUMN raw text NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS abundance_maps;
+DROP TABLE IF EXISTS hyperspectral_cubes;
@@ -0,0 +1,36 @@
+-- Гиперспектральные кубы заявок и карты долей пигментов, рассчитанные по пикселям.
+
+CREATE TABLE hyperspectral_cubes (
+    id                   bigserial PRIMARY KEY,
+    spectrum_analysis_id uuid NOT NULL
+        CONSTRAINT fk_hyperspectral_cubes_analysis REFERENCES spectrum_analysis (id) ON DELETE CASCADE,
+    creator_id           bigint NOT NULL
+        CONSTRAINT fk_hyperspectral_cubes_creator REFERENCES users (id),
+    name                 text NOT NULL DEFAULT '',
+    header               text NOT NULL,
+    samples              integer NOT NULL,
+    lines                integer NOT NULL,
+    bands                integer NOT NULL,
+    status               text NOT NULL DEFAULT 'queued'
+        CONSTRAINT chk_hyperspectral_cubes_status CHECK (status IN ('queued', 'running', 'done', 'failed')),
+    progress             double precision NOT NULL DEFAULT 0,
+    error                text NOT NULL DEFAULT '',
+    created_at           timestamptz NOT NULL DEFAULT now(),
+    started_at           timestamptz,
+    finished_at          timestamptz
+);
+
+CREATE INDEX idx_hyperspectral_cubes_analysis ON hyperspectral_cubes (spectrum_analysis_id);
+CREATE INDEX idx_hyperspectral_cubes_pending ON hyperspectral_cubes (id) WHERE status IN ('queued', 'running');
+
+CREATE TABLE abundance_maps (
+    id            bigserial PRIMARY KEY,
+    cube_id       bigint NOT NULL
+        CONSTRAINT fk_abundance_maps_cube REFERENCES hyperspectral_cubes (id) ON DELETE CASCADE,
+    pigment_id    bigint NOT NULL
+        CONSTRAINT fk_abundance_maps_pigment REFERENCES pigments (id) ON DELETE CASCADE,
+    image_key     text NOT NULL,
+    mean_fraction double precision NOT NULL DEFAULT 0,
+    max_fraction  double precision NOT NULL DEFAULT 0,
+    CONSTRAINT uq_abundance_maps_pigment UNIQUE (cube_id, pigment_id)
+);
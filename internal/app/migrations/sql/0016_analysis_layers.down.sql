@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS layer_pigments;
+DROP TABLE IF EXISTS analysis_layers;
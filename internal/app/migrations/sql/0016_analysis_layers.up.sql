@@ -0,0 +1,26 @@
+-- Стратиграфия пробы: упорядоченные слои шлифа и пигменты каждого слоя с долями.
+
+CREATE TABLE analysis_layers (
+    id                   bigserial PRIMARY KEY,
+    spectrum_analysis_id uuid NOT NULL
+        CONSTRAINT fk_analysis_layers_analysis REFERENCES spectrum_analysis (id) ON DELETE CASCADE,
+    position             integer NOT NULL,
+    kind                 text NOT NULL
+        CONSTRAINT chk_analysis_layers_kind CHECK (kind IN ('ground', 'underpaint', 'paint', 'glaze', 'varnish', 'other')),
+    name                 text NOT NULL DEFAULT '',
+    thickness            double precision
+        CONSTRAINT chk_analysis_layers_thickness CHECK (thickness > 0),
+    binder               text NOT NULL DEFAULT '',
+    note                 text NOT NULL DEFAULT '',
+    CONSTRAINT uq_analysis_layers_position UNIQUE (spectrum_analysis_id, position)
+);
+
+CREATE TABLE layer_pigments (
+    layer_id   bigint NOT NULL
+        CONSTRAINT fk_layer_pigments_layer REFERENCES analysis_layers (id) ON DELETE CASCADE,
+    pigment_id bigint NOT NULL
+        CONSTRAINT fk_layer_pigments_pigment REFERENCES pigments (id),
+    percent    double precision NOT NULL
+        CONSTRAINT chk_layer_pigments_percent CHECK (percent > 0 AND percent <= 100),
+    PRIMARY KEY (layer_id, pigment_id)
+);
@@ -0,0 +1,22 @@
+-- Справочник взаимодействий пигментов: опасные пары и деградация отдельных пигментов
+-- с объяснением и продуктами, которые стоит поискать в пробе.
+
+CREATE TABLE pigment_interactions (
+    id               bigserial PRIMARY KEY,
+    pigment_id       bigint NOT NULL
+        CONSTRAINT fk_pigment_interactions_pigment REFERENCES pigments (id) ON DELETE CASCADE,
+    other_pigment_id bigint
+        CONSTRAINT fk_pigment_interactions_other REFERENCES pigments (id) ON DELETE CASCADE,
+    severity         text NOT NULL
+        CONSTRAINT chk_pigment_interactions_severity CHECK (severity IN ('info', 'warning', 'danger')),
+    explanation      text NOT NULL,
+    products         text NOT NULL DEFAULT '',
+    created_at       timestamptz NOT NULL DEFAULT now(),
+    updated_at       timestamptz NOT NULL DEFAULT now(),
+    -- пара хранится один раз: меньший ID первым
+    CONSTRAINT chk_pigment_interactions_pair CHECK (other_pigment_id IS NULL OR pigment_id < other_pigment_id)
+);
+
+-- Одно правило на пару и одно правило деградации на пигмент
+CREATE UNIQUE INDEX uq_pigment_interactions_pair ON pigment_interactions (pigment_id, COALESCE(other_pigment_id, 0));
+CREATE INDEX idx_pigment_interactions_other ON pigment_interactions (other_pigment_id);
@@ -0,0 +1,11 @@
+-- Разобранные спектры к этому моменту возвращены в текст кодом миграции
+UPDATE spectrum_analysis a
+SET spectrum = u.spectrum
+FROM spectrum_analysis_unparsed u
+WHERE u.spectrum_analysis_id = a.id;
+
+DROP TABLE IF EXISTS spectrum_analysis_unparsed;
+
+ALTER TABLE spectrum_analysis
+    DROP COLUMN IF EXISTS spectrum_packed,
+    DROP COLUMN IF EXISTS spectrum_unparsed;
@@ -0,0 +1,16 @@
+-- Спектр заявки переходит из текста в двоичный формат spectra.Packed: значения float32
+-- на сетке длин волн, сжатые, с контрольной суммой. Текст переводится кодом миграции
+-- (funcs.go); текст, который не удалось разобрать, остаётся в spectrum_analysis_unparsed.
+-- Текстовый столбец удаляется следующей миграцией. У заявок с неразобранным текстом
+-- поднимается spectrum_unparsed: спектра у них нет, и его нужно загрузить заново.
+
+ALTER TABLE spectrum_analysis
+    ADD COLUMN spectrum_packed bytea,
+    ADD COLUMN spectrum_unparsed boolean NOT NULL DEFAULT false;
+
+CREATE TABLE spectrum_analysis_unparsed (
+    spectrum_analysis_id uuid PRIMARY KEY
+        CONSTRAINT fk_spectrum_analysis_unparsed_analysis REFERENCES spectrum_analysis (id) ON DELETE CASCADE,
+    spectrum             text NOT NULL,
+    error                text NOT NULL
+);
@@ -0,0 +1,5 @@
+ALTER TABLE spectrum_analysis
+    RENAME COLUMN spectrum TO spectrum_packed;
+
+ALTER TABLE spectrum_analysis
+    ADD COLUMN spectrum text;
@@ -0,0 +1,7 @@
+-- Текстовый спектр заявки больше не нужен: двоичный занимает его место
+
+ALTER TABLE spectrum_analysis
+    DROP COLUMN spectrum;
+
+ALTER TABLE spectrum_analysis
+    RENAME COLUMN spectrum_packed TO spectrum;
@@ -0,0 +1,2 @@
+ALTER TABLE reference_spectra
+    DROP COLUMN IF EXISTS features;
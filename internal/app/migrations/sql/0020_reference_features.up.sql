@@ -0,0 +1,6 @@
+-- Признаки эталонов отражения для индекса ближайших эталонов (refindex): спектр на общей
+-- сетке длин волн, центрированный и нормированный, как float32. Для уже загруженных
+-- эталонов признаки считает код миграции (funcs.go); NULL - эталон не индексируется.
+
+ALTER TABLE reference_spectra
+    ADD COLUMN features bytea;
@@ -0,0 +1,85 @@
+// Package mixing - прогноз спектра отражения смеси пигментов по одноконстантной
+// модели Кубелки-Мунка.
+//
+// Для каждого пигмента по эталонному спектру находится отношение поглощения к
+// рассеянию K/S = (1-R)²/2R. K/S смеси - сумма K/S компонентов с весами долей,
+// из неё обратно получается отражение R = 1 + K/S - √((K/S)² + 2K/S).
+// Модель предполагает непрозрачный слой и похожее рассеяние у всех пигментов.
+package mixing
+
+import (
+	"errors"
+	"math"
+
+	"colorLex/internal/app/spectra"
+)
+
+// Step - шаг сетки прогноза, нм
+const Step = 10.0
+
+// minReflectance - нижняя граница отражения: при R = 0 поглощение бесконечно
+const minReflectance = 0.001
+
+// ErrNoOverlap - у эталонов компонентов нет общего диапазона
+var ErrNoOverlap = errors.New("component spectra do not overlap")
+
+// Component - пигмент смеси и его доля в любых единицах: доли нормируются
+type Component struct {
+	PigmentID uint
+	Amount    float64
+	Spectrum  spectra.Spectrum // эталонный спектр отражения, доли 0..1
+}
+
+// KS - отношение поглощения к рассеянию по коэффициенту отражения
+func KS(r float64) float64 {
+	r = clamp(r)
+	return (1 - r) * (1 - r) / (2 * r)
+}
+
+// Reflectance - коэффициент отражения непрозрачного слоя по K/S
+func Reflectance(ks float64) float64 {
+	return 1 + ks - math.Sqrt(ks*ks+2*ks)
+}
+
+// Predict рассчитывает спектр отражения смеси на сетке с шагом Step в общем диапазоне эталонов
+func Predict(components []Component) (spectra.Spectrum, error) {
+	if len(components) == 0 {
+		return nil, errors.New("no components")
+	}
+	var total float64
+	from, to := math.Inf(-1), math.Inf(1)
+	for _, component := range components {
+		if component.Amount < 0 {
+			return nil, errors.New("negative amount")
+		}
+		if len(component.Spectrum) == 0 {
+			return nil, errors.New("empty spectrum")
+		}
+		total += component.Amount
+		start, end := component.Spectrum.Range()
+		from, to = math.Max(from, start), math.Min(to, end)
+	}
+	if total == 0 {
+		return nil, errors.New("amounts sum to zero")
+	}
+
+	first := math.Ceil(from/Step) * Step
+	if to-first < Step {
+		return nil, ErrNoOverlap
+	}
+
+	var result spectra.Spectrum
+	for wavelength := first; wavelength <= to+1e-9; wavelength += Step {
+		var ks float64
+		for _, component := range components {
+			r, _ := component.Spectrum.At(wavelength)
+			ks += component.Amount / total * KS(r)
+		}
+		result = append(result, spectra.Point{Wavelength: wavelength, Value: math.Round(Reflectance(ks)*1e4) / 1e4})
+	}
+	return result, nil
+}
+
+func clamp(r float64) float64 {
+	return math.Max(minReflectance, math.Min(1, r))
+}
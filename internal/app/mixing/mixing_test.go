@@ -0,0 +1,59 @@
+package mixing
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"colorLex/internal/app/spectra"
+)
+
+func TestKSRoundTrip(t *testing.T) {
+	for _, r := range []float64{0.02, 0.1, 0.5, 0.9, 1} {
+		if got := Reflectance(KS(r)); math.Abs(got-r) > 1e-9 {
+			t.Fatalf("R=%v: round trip gives %v", r, got)
+		}
+	}
+	if KS(0) <= 0 || math.IsInf(KS(0), 0) {
+		t.Fatalf("zero reflectance must be clamped, got %v", KS(0))
+	}
+}
+
+func TestPredict(t *testing.T) {
+	white := spectra.Spectrum{{Wavelength: 400, Value: 0.9}, {Wavelength: 700, Value: 0.9}}
+	blue := spectra.Spectrum{{Wavelength: 380, Value: 0.4}, {Wavelength: 500, Value: 0.3}, {Wavelength: 600, Value: 0.05}, {Wavelength: 720, Value: 0.1}}
+
+	// Одна составляющая воспроизводит свой эталон
+	got, err := Predict([]Component{{PigmentID: 1, Amount: 3, Spectrum: blue}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Wavelength != 380 || got[len(got)-1].Wavelength != 720 || math.Abs(got[12].Value-0.3) > 1e-4 {
+		t.Fatalf("single component %v", got)
+	}
+
+	// Белила высветляют синий, но смесь темнее, чем среднее отражений
+	got, err = Predict([]Component{{PigmentID: 1, Amount: 50, Spectrum: blue}, {PigmentID: 2, Amount: 50, Spectrum: white}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Wavelength != 400 || got[len(got)-1].Wavelength != 700 {
+		t.Fatalf("grid %v-%v, want the common range", got[0].Wavelength, got[len(got)-1].Wavelength)
+	}
+	at600, _ := got.At(600)
+	if at600 <= 0.05 || at600 >= (0.05+0.9)/2 {
+		t.Fatalf("R(600) = %v", at600)
+	}
+	want := Reflectance(0.5*KS(0.05) + 0.5*KS(0.9))
+	if math.Abs(at600-want) > 1e-4 {
+		t.Fatalf("R(600) = %v, want %v", at600, want)
+	}
+
+	infrared := spectra.Spectrum{{Wavelength: 800, Value: 0.5}, {Wavelength: 900, Value: 0.5}}
+	if _, err := Predict([]Component{{Amount: 1, Spectrum: blue}, {Amount: 1, Spectrum: infrared}}); !errors.Is(err, ErrNoOverlap) {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := Predict([]Component{{Amount: 0, Spectrum: blue}}); err == nil {
+		t.Fatal("zero amounts must be rejected")
+	}
+}
@@ -0,0 +1,109 @@
+package refindex
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"colorLex/internal/app/spectra"
+)
+
+// Сетка признаков: спектр отражения переносится на неё, чтобы эталоны с разными
+// шагами и диапазонами можно было сравнивать как векторы одной длины
+const (
+	FeatureStart = 360.0
+	FeatureEnd   = 1100.0
+	FeatureStep  = 10.0
+
+	// Dims - длина вектора признаков
+	Dims = int((FeatureEnd-FeatureStart)/FeatureStep) + 1
+)
+
+// minCovered - сколько узлов сетки должен покрывать спектр, чтобы у него были признаки.
+// Столько же точек общего диапазона требует identify.MatchReflectance
+const minCovered = 3
+
+// flatTolerance - среднее отклонение от среднего, ниже которого спектр считается плоским
+const flatTolerance = 1e-9
+
+// ErrNoFeatures - спектр почти не попадает на сетку признаков или плоский
+var ErrNoFeatures = errors.New("spectrum has no features on the index grid")
+
+// Features переносит спектр отражения на сетку признаков, центрирует значения в
+// покрытых узлах и нормирует вектор. Непокрытые узлы остаются нулями. Скалярное
+// произведение двух таких векторов приближает корреляцию Пирсона, по которой
+// identify.MatchReflectance ранжирует эталоны
+func Features(s spectra.Spectrum) ([]float32, error) {
+	values := make([]float64, Dims)
+	covered := make([]bool, Dims)
+	var n int
+	var mean float64
+	for i := range values {
+		value, ok := s.At(FeatureStart + float64(i)*FeatureStep)
+		if !ok {
+			continue
+		}
+		values[i], covered[i] = value, true
+		mean += value
+		n++
+	}
+	if n < minCovered {
+		return nil, ErrNoFeatures
+	}
+	mean /= float64(n)
+
+	var norm float64
+	for i := range values {
+		if covered[i] {
+			values[i] -= mean
+			norm += values[i] * values[i]
+		}
+	}
+	norm = math.Sqrt(norm)
+	// Плоский спектр ни с чем не коррелирует; после вычитания среднего от него
+	// остаются только ошибки округления
+	if norm <= flatTolerance*math.Sqrt(float64(n))*math.Max(1, math.Abs(mean)) {
+		return nil, ErrNoFeatures
+	}
+
+	features := make([]float32, Dims)
+	for i, value := range values {
+		features[i] = float32(value / norm)
+	}
+	return features, nil
+}
+
+// ReferenceFeatures считает признаки эталона в виде для ds.ReferenceSpectrum.Features.
+// nil - эталон не индексируется: другая методика или спектр вне сетки признаков
+func ReferenceFeatures(technique string, s spectra.Spectrum) []byte {
+	if technique != spectra.TechniqueReflectance {
+		return nil
+	}
+	features, err := Features(s)
+	if err != nil {
+		return nil
+	}
+	return EncodeFeatures(features)
+}
+
+// EncodeFeatures записывает вектор как float32 little-endian
+func EncodeFeatures(features []float32) []byte {
+	data := make([]byte, 0, 4*len(features))
+	for _, f := range features {
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(f))
+	}
+	return data
+}
+
+// DecodeFeatures читает вектор, записанный EncodeFeatures. ok = false, если длина не
+// совпадает с Dims: признаки посчитаны на другой сетке и их нужно пересчитать
+func DecodeFeatures(data []byte) (features []float32, ok bool) {
+	if len(data) != 4*Dims {
+		return nil, false
+	}
+	features = make([]float32, Dims)
+	for i := range features {
+		features[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return features, true
+}
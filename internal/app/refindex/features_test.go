@@ -0,0 +1,96 @@
+package refindex
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+
+	"colorLex/internal/app/spectra"
+)
+
+func parse(t *testing.T, text string) spectra.Spectrum {
+	t.Helper()
+	s, err := spectra.Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// pearson - корреляция двух спектров на узлах сетки признаков
+func pearson(a, b spectra.Spectrum) float64 {
+	var xs, ys []float64
+	for i := 0; i < Dims; i++ {
+		x, okA := a.At(FeatureStart + float64(i)*FeatureStep)
+		y, okB := b.At(FeatureStart + float64(i)*FeatureStep)
+		if okA && okB {
+			xs, ys = append(xs, x), append(ys, y)
+		}
+	}
+	var mx, my float64
+	for i := range xs {
+		mx, my = mx+xs[i], my+ys[i]
+	}
+	mx, my = mx/float64(len(xs)), my/float64(len(ys))
+	var cov, vx, vy float64
+	for i := range xs {
+		cov += (xs[i] - mx) * (ys[i] - my)
+		vx += (xs[i] - mx) * (xs[i] - mx)
+		vy += (ys[i] - my) * (ys[i] - my)
+	}
+	return cov / math.Sqrt(vx*vy)
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+func TestFeatures(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		a, b := syntheticSpectrum(rng), syntheticSpectrum(rng)
+		fa, err := Features(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fb, _ := Features(b)
+		// при одинаковом покрытии сетки скалярное произведение и есть корреляция
+		if got, want := dot(fa, fb), pearson(a, b); math.Abs(got-want) > 1e-5 {
+			t.Fatalf("dot %g, pearson %g", got, want)
+		}
+	}
+
+	partial, err := Features(parse(t, "400:0.1,500:0.5,600:0.2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partial[0] != 0 || partial[Dims-1] != 0 || math.Abs(dot(partial, partial)-1) > 1e-5 {
+		t.Fatalf("unexpected partial features %v", partial)
+	}
+
+	for name, text := range map[string]string{
+		"outside grid": "1200:0.1,1300:0.5,1400:0.2",
+		"two nodes":    "400:0.1,410:0.5",
+		"flat":         "400:0.3,700:0.3",
+	} {
+		if _, err := Features(parse(t, text)); !errors.Is(err, ErrNoFeatures) {
+			t.Errorf("%s: error = %v, want ErrNoFeatures", name, err)
+		}
+	}
+
+	decoded, ok := DecodeFeatures(EncodeFeatures(partial))
+	if !ok || decoded[10] != partial[10] {
+		t.Fatal("features do not survive encoding")
+	}
+	if _, ok := DecodeFeatures(EncodeFeatures(partial[:10])); ok {
+		t.Fatal("features of another grid must be rejected")
+	}
+	if ReferenceFeatures(spectra.TechniqueRaman, parse(t, "400:1,500:2,600:1")) != nil {
+		t.Fatal("raman references must not be indexed")
+	}
+}
@@ -0,0 +1,295 @@
+package refindex
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Параметры графа по умолчанию. При 50 тыс. эталонов дают полноту выше 0.95 среди
+// десяти ближайших (см. BenchmarkSearch)
+const (
+	DefaultM              = 16
+	DefaultEfConstruction = 100
+	DefaultEfSearch       = 64
+)
+
+// Graph - иерархический граф ближайших соседей (HNSW, Malkov & Yashunin, 2016) по
+// косинусному расстоянию между нормированными векторами признаков.
+//
+// Удалённые узлы остаются в графе как переходы, но не попадают в результаты;
+// граф без них собирается заново через Compact. Graph не потокобезопасен - см. Index
+type Graph struct {
+	M              int // связей узла в верхних слоях, в нулевом - 2M
+	EfConstruction int // ширина поиска соседей при вставке
+	EfSearch       int // ширина поиска при запросе, не меньше k
+
+	nodes    []node
+	byID     map[uint]int32
+	entry    int32 // -1 - граф пуст
+	maxLevel int
+	deleted  int
+	rng      *rand.Rand
+}
+
+type node struct {
+	id      uint
+	vector  []float32
+	friends [][]int32 // соседи по слоям, от нулевого
+	deleted bool
+}
+
+// Neighbor - найденный вектор и его расстояние до запроса (1 - косинус)
+type Neighbor struct {
+	ID       uint
+	Distance float32
+}
+
+// NewGraph создаёт пустой граф с параметрами по умолчанию. seed фиксирует уровни
+// узлов: один и тот же порядок вставок даёт один и тот же граф
+func NewGraph(seed int64) *Graph {
+	return &Graph{
+		M:              DefaultM,
+		EfConstruction: DefaultEfConstruction,
+		EfSearch:       DefaultEfSearch,
+		byID:           make(map[uint]int32),
+		entry:          -1,
+		rng:            rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Len - число неудалённых векторов
+func (g *Graph) Len() int {
+	return len(g.byID)
+}
+
+// Deleted - число удалённых узлов, которые ещё занимают место в графе
+func (g *Graph) Deleted() int {
+	return g.deleted
+}
+
+// Add вставляет вектор длины Dims. Если id уже есть, старый вектор удаляется
+func (g *Graph) Add(id uint, vector []float32) {
+	g.Remove(id)
+
+	level := g.randomLevel()
+	n := int32(len(g.nodes))
+	g.nodes = append(g.nodes, node{id: id, vector: vector, friends: make([][]int32, level+1)})
+	g.byID[id] = n
+	if g.entry < 0 {
+		g.entry, g.maxLevel = n, level
+		return
+	}
+
+	ep := g.entry
+	for l := g.maxLevel; l > level; l-- {
+		ep = g.greedy(vector, ep, l)
+	}
+	for l := min(level, g.maxLevel); l >= 0; l-- {
+		candidates := g.searchLayer(vector, ep, g.EfConstruction, l, nil)
+		g.nodes[n].friends[l] = g.selectNeighbors(candidates, g.M)
+		for _, friend := range g.nodes[n].friends[l] {
+			g.link(friend, n, l)
+		}
+		ep = candidates[0].node
+	}
+	if level > g.maxLevel {
+		g.entry, g.maxLevel = n, level
+	}
+}
+
+// Remove помечает вектор удалённым; false - такого id нет
+func (g *Graph) Remove(id uint) bool {
+	n, ok := g.byID[id]
+	if !ok {
+		return false
+	}
+	delete(g.byID, id)
+	g.nodes[n].deleted = true
+	g.deleted++
+	return true
+}
+
+// Search возвращает до k ближайших векторов, от ближнего к дальнему. accept
+// отбирает векторы по id; отклонённые участвуют в обходе графа, но не в результате
+func (g *Graph) Search(vector []float32, k int, accept func(id uint) bool) []Neighbor {
+	if g.entry < 0 || k <= 0 {
+		return nil
+	}
+	ep := g.entry
+	for l := g.maxLevel; l > 0; l-- {
+		ep = g.greedy(vector, ep, l)
+	}
+	keep := func(n int32) bool {
+		return !g.nodes[n].deleted && (accept == nil || accept(g.nodes[n].id))
+	}
+	found := g.searchLayer(vector, ep, max(g.EfSearch, k), 0, keep)
+
+	result := make([]Neighbor, 0, min(k, len(found)))
+	for _, c := range found[:min(k, len(found))] {
+		result = append(result, Neighbor{ID: g.nodes[c.node].id, Distance: c.dist})
+	}
+	return result
+}
+
+// Compact собирает граф заново без удалённых узлов
+func (g *Graph) Compact() {
+	nodes := g.nodes
+	g.nodes, g.byID, g.entry, g.maxLevel, g.deleted = nil, make(map[uint]int32, len(g.byID)), -1, 0, 0
+	for _, n := range nodes {
+		if !n.deleted {
+			g.Add(n.id, n.vector)
+		}
+	}
+}
+
+func (g *Graph) randomLevel() int {
+	return int(math.Floor(-math.Log(1-g.rng.Float64()) / math.Log(float64(g.M))))
+}
+
+func (g *Graph) distance(a []float32, n int32) float32 {
+	b := g.nodes[n].vector
+	var dot float32
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return 1 - dot
+}
+
+// greedy спускается по слою к ближайшему к vector узлу
+func (g *Graph) greedy(vector []float32, ep int32, level int) int32 {
+	best := g.distance(vector, ep)
+	for changed := true; changed; {
+		changed = false
+		for _, friend := range g.nodes[ep].friends[level] {
+			if d := g.distance(vector, friend); d < best {
+				ep, best, changed = friend, d, true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer ищет ef ближайших к vector узлов слоя, начиная с ep. keep = nil -
+// подходит любой узел. Результат отсортирован по возрастанию расстояния
+func (g *Graph) searchLayer(vector []float32, ep int32, ef, level int, keep func(int32) bool) []candidate {
+	visited := make([]uint64, (len(g.nodes)+63)/64)
+	visited[ep/64] |= 1 << (ep % 64)
+
+	start := candidate{node: ep, dist: g.distance(vector, ep)}
+	candidates := &queue{items: []candidate{start}}
+	results := &queue{max: true}
+	if keep == nil || keep(ep) {
+		results.items = append(results.items, start)
+	}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(candidate)
+		if results.Len() >= ef && c.dist > results.items[0].dist {
+			break
+		}
+		for _, friend := range g.nodes[c.node].friends[level] {
+			if visited[friend/64]&(1<<(friend%64)) != 0 {
+				continue
+			}
+			visited[friend/64] |= 1 << (friend % 64)
+
+			d := g.distance(vector, friend)
+			if results.Len() < ef || d < results.items[0].dist {
+				heap.Push(candidates, candidate{node: friend, dist: d})
+				if keep == nil || keep(friend) {
+					heap.Push(results, candidate{node: friend, dist: d})
+					if results.Len() > ef {
+						heap.Pop(results)
+					}
+				}
+			}
+		}
+	}
+
+	found := results.items
+	sort.Slice(found, func(i, j int) bool { return found[i].dist < found[j].dist })
+	return found
+}
+
+// selectNeighbors выбирает до m соседей эвристикой HNSW: кандидат берётся, если он
+// ближе к узлу, чем к уже выбранным соседям. Так связи расходятся в разные стороны,
+// а не ведут в одно скопление. Оставшиеся места добираются ближайшими из отброшенных
+func (g *Graph) selectNeighbors(candidates []candidate, m int) []int32 {
+	selected := make([]int32, 0, m)
+	var skipped []int32
+	for _, c := range candidates {
+		if len(selected) == m {
+			break
+		}
+		diverse := true
+		for _, s := range selected {
+			if g.distance(g.nodes[c.node].vector, s) < c.dist {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c.node)
+		} else {
+			skipped = append(skipped, c.node)
+		}
+	}
+	for _, n := range skipped {
+		if len(selected) == m {
+			break
+		}
+		selected = append(selected, n)
+	}
+	return selected
+}
+
+// link добавляет связь from -> to и прореживает соседей from, если их стало больше нормы
+func (g *Graph) link(from, to int32, level int) {
+	friends := append(g.nodes[from].friends[level], to)
+	limit := g.M
+	if level == 0 {
+		limit = 2 * g.M
+	}
+	if len(friends) > limit {
+		vector := g.nodes[from].vector
+		candidates := make([]candidate, len(friends))
+		for i, friend := range friends {
+			candidates[i] = candidate{node: friend, dist: g.distance(vector, friend)}
+		}
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].dist < candidates[j].dist })
+		friends = g.selectNeighbors(candidates, limit)
+	}
+	g.nodes[from].friends[level] = friends
+}
+
+type candidate struct {
+	node int32
+	dist float32
+}
+
+// queue - куча кандидатов: ближайший сверху, а при max - дальний
+type queue struct {
+	items []candidate
+	max   bool
+}
+
+func (q *queue) Len() int { return len(q.items) }
+
+func (q *queue) Less(i, j int) bool {
+	if q.max {
+		return q.items[i].dist > q.items[j].dist
+	}
+	return q.items[i].dist < q.items[j].dist
+}
+
+func (q *queue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+
+func (q *queue) Push(x any) { q.items = append(q.items, x.(candidate)) }
+
+func (q *queue) Pop() any {
+	last := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return last
+}
@@ -0,0 +1,196 @@
+package refindex
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+
+	"colorLex/internal/app/spectra"
+)
+
+// syntheticSpectrum - правдоподобная кривая отражения: подложка, ступень края
+// поглощения и пара полос поглощения на случайных длинах волн
+func syntheticSpectrum(rng *rand.Rand) spectra.Spectrum {
+	base := 0.05 + 0.3*rng.Float64()
+	edge, height, width := 400+400*rng.Float64(), 0.6*rng.Float64(), 10+60*rng.Float64()
+	type band struct{ center, depth, width float64 }
+	bands := make([]band, 1+rng.Intn(3))
+	for i := range bands {
+		bands[i] = band{350 + 700*rng.Float64(), 0.3 * rng.Float64(), 15 + 80*rng.Float64()}
+	}
+
+	var s spectra.Spectrum
+	for wavelength := 380.0; wavelength <= 1000; wavelength += 5 {
+		value := base + height/(1+math.Exp(-(wavelength-edge)/width))
+		for _, b := range bands {
+			value -= b.depth * math.Exp(-math.Pow((wavelength-b.center)/b.width, 2)/2)
+		}
+		s = append(s, spectra.Point{Wavelength: wavelength, Value: value + 0.005*rng.NormFloat64()})
+	}
+	return s
+}
+
+func syntheticFeatures(rng *rand.Rand, n int) [][]float32 {
+	vectors := make([][]float32, 0, n)
+	for len(vectors) < n {
+		features, err := Features(syntheticSpectrum(rng))
+		if err != nil {
+			continue
+		}
+		vectors = append(vectors, features)
+	}
+	return vectors
+}
+
+func buildGraph(vectors [][]float32) *Graph {
+	g := NewGraph(1)
+	for i, vector := range vectors {
+		g.Add(uint(i+1), vector)
+	}
+	return g
+}
+
+// exactNearest - k ближайших полным перебором
+func exactNearest(g *Graph, vectors [][]float32, query []float32, k int, accept func(uint) bool) []uint {
+	var all []Neighbor
+	for i := range vectors {
+		id := uint(i + 1)
+		n, ok := g.byID[id]
+		if !ok || (accept != nil && !accept(id)) {
+			continue
+		}
+		all = append(all, Neighbor{ID: id, Distance: g.distance(query, n)})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Distance < all[j].Distance })
+	ids := make([]uint, 0, k)
+	for _, neighbor := range all[:min(k, len(all))] {
+		ids = append(ids, neighbor.ID)
+	}
+	return ids
+}
+
+// recall - доля точных k ближайших, которые нашёл граф, в среднем по запросам
+func recall(g *Graph, vectors, queries [][]float32, k int, accept func(uint) bool) float64 {
+	var hits, total int
+	for _, query := range queries {
+		want := make(map[uint]bool)
+		for _, id := range exactNearest(g, vectors, query, k, accept) {
+			want[id] = true
+		}
+		for _, neighbor := range g.Search(query, k, accept) {
+			if want[neighbor.ID] {
+				hits++
+			}
+		}
+		total += len(want)
+	}
+	return float64(hits) / float64(total)
+}
+
+func TestGraphRecall(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	vectors := syntheticFeatures(rng, 5000)
+	queries := syntheticFeatures(rng, 100)
+	g := buildGraph(vectors)
+
+	if r := recall(g, vectors, queries, 10, nil); r < 0.95 {
+		t.Fatalf("recall@10 = %.3f", r)
+	}
+
+	// фильтр по пигменту: в результат попадают только чётные ID
+	even := func(id uint) bool { return id%2 == 0 }
+	if r := recall(g, vectors, queries, 10, even); r < 0.95 {
+		t.Fatalf("filtered recall@10 = %.3f", r)
+	}
+	for _, neighbor := range g.Search(queries[0], 10, even) {
+		if neighbor.ID%2 != 0 {
+			t.Fatalf("filtered search returned %d", neighbor.ID)
+		}
+	}
+}
+
+func TestGraphRemove(t *testing.T) {
+	rng := rand.New(rand.NewSource(11))
+	vectors := syntheticFeatures(rng, 2000)
+	g := buildGraph(vectors)
+
+	// сам вектор - ближайший к себе
+	if found := g.Search(vectors[41], 1, nil); len(found) != 1 || found[0].ID != 42 || found[0].Distance > 1e-5 {
+		t.Fatalf("self search returned %+v", found)
+	}
+
+	for id := uint(1); id <= 1500; id++ {
+		g.Remove(id)
+	}
+	if g.Len() != 500 || g.Deleted() != 1500 {
+		t.Fatalf("len %d, deleted %d", g.Len(), g.Deleted())
+	}
+	for _, neighbor := range g.Search(vectors[41], 20, nil) {
+		if neighbor.ID <= 1500 {
+			t.Fatalf("removed vector %d found", neighbor.ID)
+		}
+	}
+
+	queries := syntheticFeatures(rng, 50)
+	if r := recall(g, vectors, queries, 10, nil); r < 0.9 {
+		t.Fatalf("recall@10 after removal = %.3f", r)
+	}
+	g.Compact()
+	if g.Len() != 500 || g.Deleted() != 0 || len(g.nodes) != 500 {
+		t.Fatalf("after compact: len %d, deleted %d, nodes %d", g.Len(), g.Deleted(), len(g.nodes))
+	}
+	if r := recall(g, vectors, queries, 10, nil); r < 0.95 {
+		t.Fatalf("recall@10 after compact = %.3f", r)
+	}
+
+	// повторное добавление заменяет вектор
+	g.Add(1600, vectors[0])
+	if found := g.Search(vectors[0], 1, nil); found[0].ID != 1600 || g.Len() != 500 {
+		t.Fatalf("replaced vector: %+v, len %d", found, g.Len())
+	}
+}
+
+// benchmarkLibrary - граф на 50 тыс. эталонов, как у импортированной библиотеки.
+// Собирается один раз на все бенчмарки пакета
+var benchmarkLibrary = sync.OnceValues(func() (*Graph, [][]float32) {
+	rng := rand.New(rand.NewSource(3))
+	vectors := syntheticFeatures(rng, 50000)
+	return buildGraph(vectors), vectors
+})
+
+// BenchmarkSearch измеряет запрос к графу на 50 тыс. эталонов и сообщает полноту
+// среди десяти и ста ближайших (столько эталонов точно сравнивает идентификация)
+func BenchmarkSearch(b *testing.B) {
+	g, vectors := benchmarkLibrary()
+	queries := syntheticFeatures(rand.New(rand.NewSource(5)), 200)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.Search(queries[i%len(queries)], 100, nil)
+	}
+	b.StopTimer()
+	b.ReportMetric(recall(g, vectors, queries[:50], 10, nil), "recall@10")
+	b.ReportMetric(recall(g, vectors, queries[:50], 100, nil), "recall@100")
+}
+
+// BenchmarkExactSearch - полный перебор тех же 50 тыс. векторов для сравнения
+func BenchmarkExactSearch(b *testing.B) {
+	g, vectors := benchmarkLibrary()
+	queries := syntheticFeatures(rand.New(rand.NewSource(5)), 200)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		exactNearest(g, vectors, queries[i%len(queries)], 100, nil)
+	}
+}
+
+func BenchmarkAdd(b *testing.B) {
+	vectors := syntheticFeatures(rand.New(rand.NewSource(9)), b.N)
+	g := NewGraph(1)
+	b.ResetTimer()
+	for i, vector := range vectors {
+		g.Add(uint(i+1), vector)
+	}
+}
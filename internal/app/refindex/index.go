@@ -0,0 +1,183 @@
+// Package refindex - индекс ближайших эталонных спектров отражения.
+//
+// У каждого эталона отражения заранее посчитан вектор признаков (Features) - спектр
+// на общей сетке, центрированный и нормированный. Векторы хранятся в reference_spectra
+// и при запуске сервера собираются в граф HNSW в памяти; добавление и удаление эталонов
+// через API обновляют граф сразу, а изменения из других процессов (импорт библиотеки)
+// индекс подхватывает периодической сверкой с хранилищем (Run). Идентификация берёт из индекса небольшое число ближайших
+// эталонов и уже их точно сравнивает с образцом, а не перебирает весь каталог.
+package refindex
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/spectra"
+)
+
+// compactMin - сколько удалённых узлов терпим, прежде чем пересобрать граф
+const compactMin = 1024
+
+// RefreshInterval - как часто Run сверяет индекс с хранилищем
+const RefreshInterval = time.Minute
+
+// refreshBatch - сколько эталонов Refresh читает из хранилища за один запрос
+const refreshBatch = 1000
+
+// Index - потокобезопасный индекс эталонов отражения
+type Index struct {
+	mu       sync.RWMutex
+	graph    *Graph
+	pigments map[uint]uint // ID эталона -> ID пигмента
+}
+
+func New() *Index {
+	return &Index{graph: NewGraph(1), pigments: make(map[uint]uint)}
+}
+
+// Load собирает индекс заново из хранилища. Эталоны архивных пигментов тоже
+// попадают в индекс: после восстановления пигмента они снова участвуют в поиске
+func (x *Index) Load(ctx context.Context, store repository.PigmentStore) error {
+	references, err := store.ListReferenceFeatures(ctx, spectra.TechniqueReflectance)
+	if err != nil {
+		return err
+	}
+
+	graph := NewGraph(1)
+	pigments := make(map[uint]uint, len(references))
+	for _, reference := range references {
+		features, ok := DecodeFeatures(reference.Features)
+		if !ok {
+			continue
+		}
+		graph.Add(reference.ID, features)
+		pigments[reference.ID] = reference.PigmentID
+	}
+
+	x.mu.Lock()
+	x.graph, x.pigments = graph, pigments
+	x.mu.Unlock()
+	return nil
+}
+
+// Refresh сверяет индекс с хранилищем: добавляет эталоны, которых в индексе нет,
+// и убирает те, что из хранилища пропали. Эталоны с ID больше последнего прочитанного
+// не трогаются - их мог только что добавить Put
+func (x *Index) Refresh(ctx context.Context, store repository.PigmentStore) (added, removed int, err error) {
+	ids, err := store.ListReferenceIDs(ctx, spectra.TechniqueReflectance)
+	if err != nil {
+		return 0, 0, err
+	}
+	listed := make(map[uint]bool, len(ids))
+	var last uint
+	for _, id := range ids {
+		listed[id] = true
+		last = max(last, id)
+	}
+
+	var missing, stale []uint
+	x.mu.RLock()
+	for _, id := range ids {
+		if _, ok := x.pigments[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	for id := range x.pigments {
+		if !listed[id] && id <= last {
+			stale = append(stale, id)
+		}
+	}
+	x.mu.RUnlock()
+
+	for start := 0; start < len(missing); start += refreshBatch {
+		references, err := store.GetReferenceSpectra(ctx, missing[start:min(start+refreshBatch, len(missing))])
+		if err != nil {
+			return added, 0, err
+		}
+		for _, reference := range references {
+			x.Put(reference)
+			added++
+		}
+	}
+	for _, id := range stale {
+		x.Remove(id)
+	}
+	return added, len(stale), nil
+}
+
+// Run раз в interval вызывает Refresh, пока не отменён ctx
+func (x *Index) Run(ctx context.Context, store repository.PigmentStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		added, removed, err := x.Refresh(ctx, store)
+		if err != nil {
+			log.Printf("refindex: refresh: %v", err)
+			continue
+		}
+		if added > 0 || removed > 0 {
+			log.Printf("refindex: %d spectra added, %d removed", added, removed)
+		}
+	}
+}
+
+// Put добавляет эталон или заменяет его признаки. Эталон без признаков убирается из индекса
+func (x *Index) Put(reference ds.ReferenceSpectrum) {
+	features, ok := DecodeFeatures(reference.Features)
+	if !ok {
+		x.Remove(reference.ID)
+		return
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.graph.Add(reference.ID, features)
+	x.pigments[reference.ID] = reference.PigmentID
+}
+
+// Remove убирает эталон из индекса
+func (x *Index) Remove(id uint) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if !x.graph.Remove(id) {
+		return
+	}
+	delete(x.pigments, id)
+	if x.graph.Deleted() >= compactMin && x.graph.Deleted() > x.graph.Len() {
+		x.graph.Compact()
+	}
+}
+
+// Len - число эталонов в индексе
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.graph.Len()
+}
+
+// Search возвращает ID до k эталонов, ближайших к признакам образца, от ближнего к
+// дальнему. accept отбирает эталоны по ID пигмента, nil - подходят все
+func (x *Index) Search(features []float32, k int, accept func(pigmentID uint) bool) []uint {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	var keep func(uint) bool
+	if accept != nil {
+		keep = func(id uint) bool { return accept(x.pigments[id]) }
+	}
+	neighbors := x.graph.Search(features, k, keep)
+	ids := make([]uint, len(neighbors))
+	for i, neighbor := range neighbors {
+		ids[i] = neighbor.ID
+	}
+	return ids
+}
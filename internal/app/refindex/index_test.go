@@ -0,0 +1,78 @@
+package refindex
+
+import (
+	"context"
+	"testing"
+
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository/memstore"
+	"colorLex/internal/app/spectra"
+)
+
+// addReference кладёт эталон прямо в хранилище, минуя индекс, как это делает импорт библиотеки
+func addReference(t *testing.T, store *memstore.Store, pigmentID uint, text string) uint {
+	t.Helper()
+	reference := ds.ReferenceSpectrum{
+		PigmentID: pigmentID,
+		Technique: spectra.TechniqueReflectance,
+		Features:  ReferenceFeatures(spectra.TechniqueReflectance, parse(t, text)),
+	}
+	if err := store.AddReferenceSpectrum(context.Background(), &reference); err != nil {
+		t.Fatal(err)
+	}
+	return reference.ID
+}
+
+func TestRefresh(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New()
+	pigment := ds.Pigment{Name: "Ultramarine"}
+	if err := store.CreatePigment(ctx, &pigment); err != nil {
+		t.Fatal(err)
+	}
+	blue := "400 0.6\n500 0.4\n600 0.1\n700 0.1"
+	red := "400 0.1\n500 0.1\n600 0.5\n700 0.7"
+
+	first := addReference(t, store, pigment.ID, blue)
+	index := New()
+	if err := index.Load(ctx, store); err != nil {
+		t.Fatal(err)
+	}
+
+	second := addReference(t, store, pigment.ID, red)
+	added, removed, err := index.Refresh(ctx, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 1 || removed != 0 || index.Len() != 2 {
+		t.Fatalf("added %d, removed %d, len %d; want 1, 0, 2", added, removed, index.Len())
+	}
+	features, _ := Features(parse(t, red))
+	if ids := index.Search(features, 1, nil); len(ids) != 1 || ids[0] != second {
+		t.Fatalf("nearest to red: %v, want [%d]", ids, second)
+	}
+
+	if err := store.DeleteReferenceSpectrum(ctx, pigment.ID, first); err != nil {
+		t.Fatal(err)
+	}
+	added, removed, err = index.Refresh(ctx, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 0 || removed != 1 || index.Len() != 1 {
+		t.Fatalf("added %d, removed %d, len %d; want 0, 1, 1", added, removed, index.Len())
+	}
+
+	// эталон, добавленный через Put после чтения списка, сверка не убирает
+	index.Put(ds.ReferenceSpectrum{
+		ID:        second + 1,
+		PigmentID: pigment.ID,
+		Features:  ReferenceFeatures(spectra.TechniqueReflectance, parse(t, blue)),
+	})
+	if _, _, err := index.Refresh(ctx, store); err != nil {
+		t.Fatal(err)
+	}
+	if index.Len() != 2 {
+		t.Fatalf("len %d after refresh, want 2", index.Len())
+	}
+}
@@ -0,0 +1,110 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"colorLex/internal/app/repository"
+)
+
+// PaletteName - имя отчёта о палитрах в кэше
+const PaletteName = "palette"
+
+// DefaultInterval - как часто отчёт пересчитывается без запроса
+const DefaultInterval = 6 * time.Hour
+
+// Cache хранит посчитанные отчёты между перезапусками и экземплярами сервера
+type Cache interface {
+	SetReport(ctx context.Context, name string, data []byte, expiration time.Duration) error
+	// GetReport возвращает nil без ошибки, если отчёта нет
+	GetReport(ctx context.Context, name string) ([]byte, error)
+}
+
+// Job пересчитывает отчёт о палитрах раз в Interval и по запросу Refresh
+type Job struct {
+	Analyses repository.AnalysisStore
+	Cache    Cache
+	Options  Options
+	Interval time.Duration
+
+	refresh chan struct{}
+}
+
+func NewJob(analyses repository.AnalysisStore, cache Cache) *Job {
+	return &Job{
+		Analyses: analyses,
+		Cache:    cache,
+		Options:  DefaultOptions,
+		Interval: DefaultInterval,
+		refresh:  make(chan struct{}, 1),
+	}
+}
+
+// Run пересчитывает отчёт до отмены ctx. При запуске отчёт считается, только если
+// в кэше нет достаточно свежего
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	if palette, err := j.Latest(ctx); err != nil || palette == nil || time.Since(palette.ComputedAt) >= j.Interval {
+		j.compute(ctx)
+	}
+	for {
+		select {
+		case <-ticker.C:
+		case <-j.refresh:
+		case <-ctx.Done():
+			return
+		}
+		j.compute(ctx)
+	}
+}
+
+// Refresh просит Run пересчитать отчёт, не дожидаясь Interval
+func (j *Job) Refresh() {
+	select {
+	case j.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Compute считает отчёт по текущим данным и сохраняет его в кэш
+func (j *Job) Compute(ctx context.Context) (*Palette, error) {
+	compositions, err := j.Analyses.ListCompositions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	palette := BuildPalette(compositions, j.Options)
+	palette.ComputedAt = time.Now()
+
+	data, err := json.Marshal(palette)
+	if err != nil {
+		return nil, err
+	}
+	// Отчёт переживает один пропущенный пересчёт, но не висит в кэше бессрочно
+	if err := j.Cache.SetReport(ctx, PaletteName, data, 2*j.Interval); err != nil {
+		return nil, err
+	}
+	return palette, nil
+}
+
+// Latest возвращает последний посчитанный отчёт; nil - отчёт ещё не посчитан
+func (j *Job) Latest(ctx context.Context) (*Palette, error) {
+	data, err := j.Cache.GetReport(ctx, PaletteName)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var palette Palette
+	if err := json.Unmarshal(data, &palette); err != nil {
+		return nil, err
+	}
+	return &palette, nil
+}
+
+func (j *Job) compute(ctx context.Context) {
+	if _, err := j.Compute(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("report: palette: %v", err)
+	}
+}
@@ -0,0 +1,268 @@
+// Package report - сводные отчёты по всем завершённым анализам.
+//
+// Отчёт просматривает каждую связь завершённой заявки с пигментом, поэтому он
+// считается фоновым заданием (Job) и хранится в кэше; API отдаёт последний
+// посчитанный отчёт.
+package report
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"colorLex/internal/app/composition"
+	"colorLex/internal/app/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MinPercent - доля, начиная с которой пигмент считается присутствующим в статистике
+	// совместной встречаемости: следовые количества чаще остаются от реставраций
+	MinPercent = 1.0
+	// MaxPairs - сколько самых частых пар пигментов попадает в статистику
+	MaxPairs = 50
+	// MaxExamples - сколько заявок приводится примерами группы
+	MaxExamples = 10
+)
+
+// Options - параметры отчёта о палитрах
+type Options struct {
+	Clusters    int // число групп k-средних
+	PeriodYears int // ширина периода датировки произведений, лет
+}
+
+// DefaultOptions - шесть групп, периоды по столетиям
+var DefaultOptions = Options{Clusters: 6, PeriodYears: 100}
+
+// Palette - группы палитр и совместная встречаемость пигментов
+type Palette struct {
+	ComputedAt time.Time
+	Options    Options
+	Analyses   int       // завершённых заявок с долями пигментов
+	Pigments   []Pigment // все пигменты отчёта, по ID
+	Clusters   []Cluster // по убыванию размера
+	Overall    Cooccurrence
+	Periods    []Period     // по возрастанию, только периоды с заявками
+	Undated    Cooccurrence // заявки без года произведения
+}
+
+// Pigment - подпись пигмента для графиков
+type Pigment struct {
+	ID       uint
+	Name     string
+	Color    string
+	Archived bool
+}
+
+// Share - доля пигмента в центре группы
+type Share struct {
+	PigmentID uint
+	Percent   float64
+}
+
+// Cluster - группа похожих палитр
+type Cluster struct {
+	Size     int
+	Centroid []Share // средний состав, по убыванию доли
+	Spread   float64 // среднее расстояние палитр до центра в долях
+	// YearFrom, YearTo - годы самого раннего и самого позднего произведения группы
+	YearFrom *int
+	YearTo   *int
+	Examples []uuid.UUID // первые по дате завершения заявки группы
+}
+
+// Cooccurrence - как часто пигменты встречаются в заявках по одному и парами
+type Cooccurrence struct {
+	Analyses int
+	Pigments []Frequency // по убыванию числа заявок
+	Pairs    []Pair      // не больше MaxPairs самых частых
+}
+
+// Frequency - число заявок с пигментом
+type Frequency struct {
+	PigmentID uint
+	Analyses  int
+	Share     float64 // доля заявок выборки
+}
+
+// Pair - два пигмента в одной заявке
+type Pair struct {
+	First, Second uint // First < Second
+	Analyses      int
+	// Jaccard - заявки с обоими пигментами среди заявок хотя бы с одним из них
+	Jaccard float64
+	// Lift - во сколько раз пара встречается чаще, чем при независимом выборе пигментов
+	Lift float64
+}
+
+// Period - статистика по произведениям с годом в [From, To)
+type Period struct {
+	From, To int
+	Cooccurrence
+}
+
+// BuildPalette считает отчёт по составам завершённых заявок. ComputedAt не заполняется
+func BuildPalette(compositions []repository.Composition, options Options) *Palette {
+	palette := &Palette{Options: options}
+
+	vectors := make([]composition.Vector, len(compositions))
+	years := make([]*int, len(compositions))
+	pigments := make(map[uint]Pigment)
+	for i, c := range compositions {
+		vectors[i] = make(composition.Vector)
+		for _, ap := range c.Pigments {
+			if ap.Link.Percent <= 0 {
+				continue
+			}
+			vectors[i][ap.Pigment.ID] = ap.Link.Percent
+			pigments[ap.Pigment.ID] = Pigment{
+				ID:       ap.Pigment.ID,
+				Name:     ap.Pigment.Name,
+				Color:    ap.Pigment.Color,
+				Archived: ap.Pigment.DeletedAt.Valid,
+			}
+		}
+		if len(vectors[i]) > 0 {
+			palette.Analyses++
+		}
+		if c.Artwork != nil {
+			years[i] = c.Artwork.Year
+		}
+	}
+	for _, pigment := range pigments {
+		palette.Pigments = append(palette.Pigments, pigment)
+	}
+	sort.Slice(palette.Pigments, func(i, j int) bool { return palette.Pigments[i].ID < palette.Pigments[j].ID })
+
+	for _, cluster := range composition.KMeans(vectors, options.Clusters) {
+		palette.Clusters = append(palette.Clusters, newCluster(cluster, compositions, years))
+	}
+
+	// Встречаемость: вся выборка, по периодам и без датировки
+	all := make([]int, 0, len(vectors))
+	periods := make(map[int][]int)
+	var undated []int
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			continue
+		}
+		all = append(all, i)
+		if years[i] == nil || options.PeriodYears <= 0 {
+			undated = append(undated, i)
+			continue
+		}
+		from := floorDiv(*years[i], options.PeriodYears) * options.PeriodYears
+		periods[from] = append(periods[from], i)
+	}
+	palette.Overall = cooccurrence(vectors, all)
+	palette.Undated = cooccurrence(vectors, undated)
+	for from, members := range periods {
+		palette.Periods = append(palette.Periods, Period{
+			From:         from,
+			To:           from + options.PeriodYears,
+			Cooccurrence: cooccurrence(vectors, members),
+		})
+	}
+	sort.Slice(palette.Periods, func(i, j int) bool { return palette.Periods[i].From < palette.Periods[j].From })
+	return palette
+}
+
+func newCluster(cluster composition.Cluster, compositions []repository.Composition, years []*int) Cluster {
+	result := Cluster{Size: len(cluster.Members), Spread: cluster.Spread}
+	for id, percent := range cluster.Centroid {
+		result.Centroid = append(result.Centroid, Share{PigmentID: id, Percent: percent})
+	}
+	sort.Slice(result.Centroid, func(i, j int) bool {
+		if result.Centroid[i].Percent != result.Centroid[j].Percent {
+			return result.Centroid[i].Percent > result.Centroid[j].Percent
+		}
+		return result.Centroid[i].PigmentID < result.Centroid[j].PigmentID
+	})
+
+	for _, member := range cluster.Members {
+		if len(result.Examples) < MaxExamples {
+			result.Examples = append(result.Examples, compositions[member].Analysis.ID)
+		}
+		year := years[member]
+		if year == nil {
+			continue
+		}
+		if result.YearFrom == nil || *year < *result.YearFrom {
+			result.YearFrom = year
+		}
+		if result.YearTo == nil || *year > *result.YearTo {
+			result.YearTo = year
+		}
+	}
+	return result
+}
+
+// cooccurrence считает встречаемость пигментов в заявках members
+func cooccurrence(vectors []composition.Vector, members []int) Cooccurrence {
+	result := Cooccurrence{Analyses: len(members)}
+	if len(members) == 0 {
+		return result
+	}
+
+	counts := make(map[uint]int)
+	pairs := make(map[[2]uint]int)
+	for _, member := range members {
+		var present []uint
+		for id, percent := range vectors[member] {
+			if percent >= MinPercent {
+				present = append(present, id)
+			}
+		}
+		sort.Slice(present, func(i, j int) bool { return present[i] < present[j] })
+		for i, first := range present {
+			counts[first]++
+			for _, second := range present[i+1:] {
+				pairs[[2]uint{first, second}]++
+			}
+		}
+	}
+
+	total := float64(len(members))
+	for id, count := range counts {
+		result.Pigments = append(result.Pigments, Frequency{PigmentID: id, Analyses: count, Share: float64(count) / total})
+	}
+	sort.Slice(result.Pigments, func(i, j int) bool {
+		if result.Pigments[i].Analyses != result.Pigments[j].Analyses {
+			return result.Pigments[i].Analyses > result.Pigments[j].Analyses
+		}
+		return result.Pigments[i].PigmentID < result.Pigments[j].PigmentID
+	})
+
+	for key, both := range pairs {
+		first, second := float64(counts[key[0]]), float64(counts[key[1]])
+		result.Pairs = append(result.Pairs, Pair{
+			First:    key[0],
+			Second:   key[1],
+			Analyses: both,
+			Jaccard:  float64(both) / (first + second - float64(both)),
+			Lift:     float64(both) * total / (first * second),
+		})
+	}
+	sort.Slice(result.Pairs, func(i, j int) bool {
+		a, b := result.Pairs[i], result.Pairs[j]
+		switch {
+		case a.Analyses != b.Analyses:
+			return a.Analyses > b.Analyses
+		case a.Jaccard != b.Jaccard:
+			return a.Jaccard > b.Jaccard
+		case a.First != b.First:
+			return a.First < b.First
+		}
+		return a.Second < b.Second
+	})
+	if len(result.Pairs) > MaxPairs {
+		result.Pairs = result.Pairs[:MaxPairs]
+	}
+	return result
+}
+
+// floorDiv - деление с округлением вниз, чтобы годы до н. э. попадали в свой период
+func floorDiv(a, b int) int {
+	return int(math.Floor(float64(a) / float64(b)))
+}
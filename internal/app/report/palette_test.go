@@ -0,0 +1,81 @@
+package report
+
+import (
+	"math"
+	"testing"
+
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository"
+
+	"github.com/google/uuid"
+)
+
+func year(v int) *int { return &v }
+
+// analysis собирает состав заявки; percents - пары ID пигмента и доли
+func analysis(artworkYear *int, percents ...float64) repository.Composition {
+	c := repository.Composition{Analysis: ds.SpectrumAnalysis{ID: uuid.New()}}
+	if artworkYear != nil {
+		c.Artwork = &ds.Artwork{Year: artworkYear}
+	}
+	for i := 0; i+1 < len(percents); i += 2 {
+		id := uint(percents[i])
+		c.Pigments = append(c.Pigments, repository.AnalysisPigment{
+			Link:    ds.SpectrumAnalysisPigment{PigmentID: id, Percent: percents[i+1]},
+			Pigment: ds.Pigment{ID: id, Name: "pigment"},
+		})
+	}
+	return c
+}
+
+func TestBuildPalette(t *testing.T) {
+	compositions := []repository.Composition{
+		analysis(year(1510), 1, 70, 2, 30),
+		analysis(year(1560), 1, 65, 2, 35),
+		analysis(year(1620), 1, 60, 2, 40),
+		analysis(year(1650), 3, 80, 4, 20),
+		analysis(nil, 3, 85, 4, 14.5, 2, 0.5), // следы ультрамарина не считаются
+		analysis(year(-150), 5, 100),
+		analysis(year(1700)), // без долей
+	}
+	palette := BuildPalette(compositions, Options{Clusters: 3, PeriodYears: 100})
+
+	if palette.Analyses != 6 || len(palette.Pigments) != 5 {
+		t.Fatalf("analyses %d, pigments %d", palette.Analyses, len(palette.Pigments))
+	}
+
+	if len(palette.Clusters) != 3 || palette.Clusters[0].Size != 3 {
+		t.Fatalf("unexpected clusters %+v", palette.Clusters)
+	}
+	first := palette.Clusters[0]
+	if first.Centroid[0].PigmentID != 1 || *first.YearFrom != 1510 || *first.YearTo != 1620 || len(first.Examples) != 3 {
+		t.Fatalf("unexpected cluster %+v", first)
+	}
+
+	overall := palette.Overall
+	if overall.Analyses != 6 || len(overall.Pairs) != 2 {
+		t.Fatalf("unexpected overall %+v", overall)
+	}
+	pair := overall.Pairs[0]
+	if pair.First != 1 || pair.Second != 2 || pair.Analyses != 3 || pair.Jaccard != 1 || math.Abs(pair.Lift-2) > 1e-9 {
+		t.Fatalf("unexpected pair %+v", pair)
+	}
+	if overall.Pigments[0].Analyses != 3 || math.Abs(overall.Pigments[0].Share-0.5) > 1e-9 {
+		t.Fatalf("unexpected frequencies %+v", overall.Pigments)
+	}
+
+	// -150 попадает в период [-200, -100), а не [-100, 0)
+	var froms []int
+	for _, period := range palette.Periods {
+		froms = append(froms, period.From)
+	}
+	if len(froms) != 3 || froms[0] != -200 || froms[1] != 1500 || froms[2] != 1600 {
+		t.Fatalf("unexpected periods %v", froms)
+	}
+	if palette.Periods[2].Analyses != 2 || len(palette.Periods[2].Pairs) != 2 {
+		t.Fatalf("unexpected period %+v", palette.Periods[2])
+	}
+	if palette.Undated.Analyses != 1 || len(palette.Undated.Pigments) != 2 {
+		t.Fatalf("unexpected undated %+v", palette.Undated)
+	}
+}
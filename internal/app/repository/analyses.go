@@ -0,0 +1,327 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"colorLex/internal/app/ds"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DraftName - имя, с которым создаётся новый черновик
+const DraftName = "Новый анализ спектра"
+
+func (r *Repository) FindDraft(ctx context.Context, creatorID uint) (*ds.SpectrumAnalysis, error) {
+	var analysis ds.SpectrumAnalysis
+	err := r.db.WithContext(ctx).
+		Where("creator_id = ? AND status = ?", creatorID, ds.StatusDraft).
+		First(&analysis).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &analysis, nil
+}
+
+func (r *Repository) AddPigmentToDraft(ctx context.Context, creatorID, pigmentID uint) (*ds.SpectrumAnalysis, int64, error) {
+	var analysis *ds.SpectrumAnalysis
+	var count int64
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		analysis, err = lockOrCreateDraft(tx, creatorID)
+		if err != nil {
+			return err
+		}
+
+		// Повторное добавление упирается в первичный ключ связи
+		link := ds.SpectrumAnalysisPigment{
+			SpectrumAnalysisID: analysis.ID,
+			PigmentID:          pigmentID,
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&link)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAlreadyExists
+		}
+
+		if err := bumpVersion(tx, analysis); err != nil {
+			return err
+		}
+
+		return tx.Model(&ds.SpectrumAnalysisPigment{}).
+			Where("spectrum_analysis_id = ?", analysis.ID).
+			Count(&count).Error
+	})
+	if err != nil {
+		return nil, 0, translateError(err)
+	}
+	return analysis, count, nil
+}
+
+// lockOrCreateDraft возвращает заблокированный черновик пользователя, создавая его при необходимости.
+// Частичный уникальный индекс uniq_spectrum_analysis_draft_per_creator не даёт
+// двум параллельным запросам создать два черновика.
+func lockOrCreateDraft(tx *gorm.DB, creatorID uint) (*ds.SpectrumAnalysis, error) {
+	var analysis ds.SpectrumAnalysis
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("creator_id = ? AND status = ?", creatorID, ds.StatusDraft).
+		First(&analysis).Error
+	if err == nil {
+		return &analysis, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	analysis = ds.SpectrumAnalysis{
+		Name:      DraftName,
+		Status:    ds.StatusDraft,
+		CreatorID: creatorID,
+	}
+	result := tx.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "creator_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Eq{Column: "status", Value: ds.StatusDraft}}},
+		DoNothing:   true,
+	}).Create(&analysis)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return &analysis, nil
+	}
+
+	// Черновик успел создать параллельный запрос - берём его
+	analysis = ds.SpectrumAnalysis{}
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("creator_id = ? AND status = ?", creatorID, ds.StatusDraft).
+		First(&analysis).Error
+	if err != nil {
+		return nil, err
+	}
+	return &analysis, nil
+}
+
+func (r *Repository) CountAnalysisPigments(ctx context.Context, analysisID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&ds.SpectrumAnalysisPigment{}).
+		Where("spectrum_analysis_id = ?", analysisID).
+		Count(&count).Error
+	return count, translateError(err)
+}
+
+func (r *Repository) ListAnalyses(ctx context.Context, query AnalysisQuery) ([]ds.SpectrumAnalysis, error) {
+	db := r.db.WithContext(ctx).Where("status NOT IN ?", []string{ds.StatusDraft, ds.StatusDeleted})
+
+	if query.CreatorID != nil {
+		db = db.Where("creator_id = ?", *query.CreatorID)
+	}
+	if query.ArtworkID != nil {
+		db = db.Where("artwork_id = ?", *query.ArtworkID)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if !query.FormedFrom.IsZero() {
+		db = db.Where("formed_at >= ?", query.FormedFrom)
+	}
+	if !query.FormedTo.IsZero() {
+		db = db.Where("formed_at <= ?", query.FormedTo)
+	}
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+
+	var analyses []ds.SpectrumAnalysis
+	err := db.Offset(query.Offset).Order("created_at").Find(&analyses).Error
+	return analyses, translateError(err)
+}
+
+func (r *Repository) GetAnalysis(ctx context.Context, id uuid.UUID) (*ds.SpectrumAnalysis, error) {
+	var analysis ds.SpectrumAnalysis
+	if err := r.db.WithContext(ctx).First(&analysis, "id = ?", id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &analysis, nil
+}
+
+func (r *Repository) UpdateAnalysis(ctx context.Context, analysis *ds.SpectrumAnalysis) error {
+	return translateError(saveAnalysis(r.db.WithContext(ctx), analysis))
+}
+
+func (r *Repository) CompleteAnalysis(ctx context.Context, analysis *ds.SpectrumAnalysis, results map[uint]PigmentResult) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockAnalysis(tx, analysis); err != nil {
+			return err
+		}
+		for pigmentID, result := range results {
+			err := tx.Model(&ds.SpectrumAnalysisPigment{}).
+				Where("spectrum_analysis_id = ? AND pigment_id = ?", analysis.ID, pigmentID).
+				Updates(map[string]interface{}{
+					"percent":           result.Percent,
+					"percent_low":       result.PercentLow,
+					"percent_high":      result.PercentHigh,
+					"indistinguishable": result.Indistinguishable,
+					"verdict":           result.Verdict,
+					"verdict_note":      result.VerdictNote,
+					"raman_score":       result.RamanScore,
+				}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return saveAnalysis(tx, analysis)
+	})
+	return translateError(err)
+}
+
+func (r *Repository) UpdateAnalysisPigment(ctx context.Context, analysis *ds.SpectrumAnalysis, link *ds.SpectrumAnalysisPigment) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockAnalysis(tx, analysis); err != nil {
+			return err
+		}
+		result := tx.Model(&ds.SpectrumAnalysisPigment{}).
+			Where("spectrum_analysis_id = ? AND pigment_id = ?", analysis.ID, link.PigmentID).
+			Updates(map[string]interface{}{
+				"comment": link.Comment,
+				"percent": link.Percent,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return bumpVersion(tx, analysis)
+	})
+	return translateError(err)
+}
+
+func (r *Repository) RemoveAnalysisPigment(ctx context.Context, analysis *ds.SpectrumAnalysis, pigmentID uint) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockAnalysis(tx, analysis); err != nil {
+			return err
+		}
+		result := tx.
+			Where("spectrum_analysis_id = ? AND pigment_id = ?", analysis.ID, pigmentID).
+			Delete(&ds.SpectrumAnalysisPigment{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return bumpVersion(tx, analysis)
+	})
+	return translateError(err)
+}
+
+// saveAnalysis записывает все поля заявки, если её версия в БД совпадает с analysis.Version
+func saveAnalysis(db *gorm.DB, analysis *ds.SpectrumAnalysis) error {
+	expected := analysis.Version
+	updated := *analysis
+	updated.Version = expected + 1
+
+	result := db.Select("*").Omit("id", "created_at").
+		Where("version = ?", expected).
+		Updates(&updated)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return versionMismatch(db, analysis.ID)
+	}
+	analysis.Version = updated.Version
+	return nil
+}
+
+// bumpVersion увеличивает версию уже заблокированной заявки
+func bumpVersion(tx *gorm.DB, analysis *ds.SpectrumAnalysis) error {
+	err := tx.Model(&ds.SpectrumAnalysis{}).
+		Where("id = ?", analysis.ID).
+		Update("version", gorm.Expr("version + 1")).Error
+	if err != nil {
+		return err
+	}
+	analysis.Version++
+	return nil
+}
+
+// lockAnalysis берёт блокировку строки заявки и проверяет её версию
+func lockAnalysis(tx *gorm.DB, analysis *ds.SpectrumAnalysis) error {
+	var current ds.SpectrumAnalysis
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "version").
+		First(&current, "id = ?", analysis.ID).Error
+	if err != nil {
+		return err
+	}
+	if current.Version != analysis.Version {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// versionMismatch отличает удалённую заявку от изменённой параллельно
+func versionMismatch(db *gorm.DB, id uuid.UUID) error {
+	var count int64
+	if err := db.Model(&ds.SpectrumAnalysis{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return ErrVersionConflict
+}
+
+func (r *Repository) ListAnalysisPigments(ctx context.Context, analysisID uuid.UUID) ([]AnalysisPigment, error) {
+	var links []ds.SpectrumAnalysisPigment
+	err := r.db.WithContext(ctx).
+		Where("spectrum_analysis_id = ?", analysisID).
+		Order("created_at").
+		Find(&links).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if len(links) == 0 {
+		return nil, nil
+	}
+
+	pigmentIDs := make([]uint, len(links))
+	for i, link := range links {
+		pigmentIDs[i] = link.PigmentID
+	}
+
+	// Unscoped: архивные пигменты продолжают отображаться в анализах
+	var pigments []ds.Pigment
+	if err := r.db.WithContext(ctx).Unscoped().Find(&pigments, pigmentIDs).Error; err != nil {
+		return nil, translateError(err)
+	}
+	byID := make(map[uint]ds.Pigment, len(pigments))
+	for _, pigment := range pigments {
+		byID[pigment.ID] = pigment
+	}
+
+	result := make([]AnalysisPigment, 0, len(links))
+	for _, link := range links {
+		if pigment, ok := byID[link.PigmentID]; ok {
+			result = append(result, AnalysisPigment{Link: link, Pigment: pigment})
+		}
+	}
+	return result, nil
+}
+
+func (r *Repository) GetAnalysisPigment(ctx context.Context, analysisID uuid.UUID, pigmentID uint) (*ds.SpectrumAnalysisPigment, error) {
+	var link ds.SpectrumAnalysisPigment
+	err := r.db.WithContext(ctx).
+		Where("spectrum_analysis_id = ? AND pigment_id = ?", analysisID, pigmentID).
+		First(&link).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &link, nil
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+
+	"colorLex/internal/app/ds"
+)
+
+func (r *Repository) ListArtworks(ctx context.Context, query ArtworkQuery) ([]ds.Artwork, error) {
+	db := r.db.WithContext(ctx)
+
+	if query.Search != "" {
+		db = db.Where("title ILIKE ? OR artist ILIKE ?", "%"+query.Search+"%", "%"+query.Search+"%")
+	}
+	if query.Collection != "" {
+		db = db.Where("collection ILIKE ?", "%"+query.Collection+"%")
+	}
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+
+	var artworks []ds.Artwork
+	err := db.Offset(query.Offset).Order("id").Find(&artworks).Error
+	return artworks, translateError(err)
+}
+
+func (r *Repository) GetArtwork(ctx context.Context, id uint) (*ds.Artwork, error) {
+	var artwork ds.Artwork
+	if err := r.db.WithContext(ctx).First(&artwork, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &artwork, nil
+}
+
+func (r *Repository) CreateArtwork(ctx context.Context, artwork *ds.Artwork) error {
+	return translateError(r.db.WithContext(ctx).Create(artwork).Error)
+}
+
+func (r *Repository) UpdateArtwork(ctx context.Context, artwork *ds.Artwork) error {
+	result := r.db.WithContext(ctx).Select("*").Omit("created_at", "creator_id").Updates(artwork)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repository) DeleteArtwork(ctx context.Context, id uint) error {
+	// artwork_id у анализов обнуляет внешний ключ ON DELETE SET NULL
+	result := r.db.WithContext(ctx).Delete(&ds.Artwork{}, id)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repository) ArtworkPigmentStats(ctx context.Context, artworkID uint) ([]ArtworkPigmentStats, error) {
+	var rows []struct {
+		PigmentID   uint
+		Analyses    int
+		MinPercent  float64
+		MaxPercent  float64
+		MeanPercent float64
+	}
+	err := r.db.WithContext(ctx).
+		Table("spectrumanalysis_pigment l").
+		Select("l.pigment_id, count(*) AS analyses, min(l.percent) AS min_percent, "+
+			"max(l.percent) AS max_percent, avg(l.percent) AS mean_percent").
+		Joins("JOIN spectrum_analysis a ON a.id = l.spectrum_analysis_id").
+		Where("a.artwork_id = ? AND a.status = ?", artworkID, ds.StatusCompleted).
+		Group("l.pigment_id").
+		Order("mean_percent DESC, l.pigment_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	pigmentIDs := make([]uint, len(rows))
+	for i, row := range rows {
+		pigmentIDs[i] = row.PigmentID
+	}
+
+	// Unscoped: архивные пигменты продолжают отображаться в анализах
+	var pigments []ds.Pigment
+	if err := r.db.WithContext(ctx).Unscoped().Find(&pigments, pigmentIDs).Error; err != nil {
+		return nil, translateError(err)
+	}
+	byID := make(map[uint]ds.Pigment, len(pigments))
+	for _, pigment := range pigments {
+		byID[pigment.ID] = pigment
+	}
+
+	result := make([]ArtworkPigmentStats, 0, len(rows))
+	for _, row := range rows {
+		if pigment, ok := byID[row.PigmentID]; ok {
+			result = append(result, ArtworkPigmentStats{
+				Pigment:     pigment,
+				Analyses:    row.Analyses,
+				MinPercent:  row.MinPercent,
+				MaxPercent:  row.MaxPercent,
+				MeanPercent: row.MeanPercent,
+			})
+		}
+	}
+	return result, nil
+}
+
+func (r *Repository) CountArtworkAnalyses(ctx context.Context, artworkID uint, status string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&ds.SpectrumAnalysis{}).
+		Where("artwork_id = ? AND status = ?", artworkID, status).
+		Count(&count).Error
+	return count, translateError(err)
+}
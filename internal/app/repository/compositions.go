@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"colorLex/internal/app/ds"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// compositionRow - пигмент завершённой заявки вместе с полями заявки, произведения и пигмента
+type compositionRow struct {
+	AnalysisID   uuid.UUID
+	Name         string
+	Status       string
+	CreatorID    uint
+	CompletedAt  *time.Time
+	ArtworkID    *uint
+	ArtworkYear  *int
+	PigmentID    uint
+	Percent      float64
+	PigmentName  string
+	PigmentColor string
+	DeletedAt    gorm.DeletedAt
+}
+
+func (r *Repository) ListCompositions(ctx context.Context) ([]Composition, error) {
+	// Один запрос без спектров и списков ID: составы читаются для всех завершённых
+	// заявок сразу. Архивные пигменты продолжают отображаться в анализах
+	var rows []compositionRow
+	err := r.db.WithContext(ctx).
+		Table("spectrumanalysis_pigment l").
+		Select(`l.spectrum_analysis_id AS analysis_id, a.name, a.status, a.creator_id, a.completed_at,
+			a.artwork_id, w.year AS artwork_year, l.pigment_id, l.percent,
+			p.name AS pigment_name, p.color AS pigment_color, p.deleted_at`).
+		Joins("JOIN spectrum_analysis a ON a.id = l.spectrum_analysis_id").
+		Joins("JOIN pigments p ON p.id = l.pigment_id").
+		Joins("LEFT JOIN artworks w ON w.id = a.artwork_id").
+		Where("a.status = ? AND l.percent > 0", ds.StatusCompleted).
+		Order("a.completed_at, a.id, l.percent DESC, l.pigment_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	var result []Composition
+	for _, row := range rows {
+		if len(result) == 0 || result[len(result)-1].Analysis.ID != row.AnalysisID {
+			item := Composition{Analysis: ds.SpectrumAnalysis{
+				ID:          row.AnalysisID,
+				Name:        row.Name,
+				Status:      row.Status,
+				CreatorID:   row.CreatorID,
+				CompletedAt: row.CompletedAt,
+				ArtworkID:   row.ArtworkID,
+			}}
+			if row.ArtworkID != nil {
+				item.Artwork = &ds.Artwork{ID: *row.ArtworkID, Year: row.ArtworkYear}
+			}
+			result = append(result, item)
+		}
+		last := &result[len(result)-1]
+		last.Pigments = append(last.Pigments, AnalysisPigment{
+			Link:    ds.SpectrumAnalysisPigment{SpectrumAnalysisID: row.AnalysisID, PigmentID: row.PigmentID, Percent: row.Percent},
+			Pigment: ds.Pigment{ID: row.PigmentID, Name: row.PigmentName, Color: row.PigmentColor, DeletedAt: row.DeletedAt},
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+
+	"colorLex/internal/app/ds"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func (r *Repository) CreateCube(ctx context.Context, cube *ds.HyperspectralCube) error {
+	return translateError(r.db.WithContext(ctx).Create(cube).Error)
+}
+
+func (r *Repository) GetCube(ctx context.Context, id uint) (*ds.HyperspectralCube, error) {
+	var cube ds.HyperspectralCube
+	if err := r.db.WithContext(ctx).First(&cube, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &cube, nil
+}
+
+func (r *Repository) ListCubes(ctx context.Context, analysisID uuid.UUID) ([]ds.HyperspectralCube, error) {
+	var cubes []ds.HyperspectralCube
+	err := r.db.WithContext(ctx).
+		Where("spectrum_analysis_id = ?", analysisID).
+		Order("id").
+		Find(&cubes).Error
+	return cubes, translateError(err)
+}
+
+func (r *Repository) ListPendingCubes(ctx context.Context) ([]ds.HyperspectralCube, error) {
+	var cubes []ds.HyperspectralCube
+	err := r.db.WithContext(ctx).
+		Where("status IN ?", []string{ds.CubeQueued, ds.CubeRunning}).
+		Order("id").
+		Find(&cubes).Error
+	return cubes, translateError(err)
+}
+
+func (r *Repository) UpdateCube(ctx context.Context, cube *ds.HyperspectralCube) error {
+	result := r.db.WithContext(ctx).Select("*").Omit("created_at").Updates(cube)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repository) ListAbundanceMaps(ctx context.Context, cubeID uint) ([]ds.AbundanceMap, error) {
+	var maps []ds.AbundanceMap
+	err := r.db.WithContext(ctx).Where("cube_id = ?", cubeID).Order("id").Find(&maps).Error
+	return maps, translateError(err)
+}
+
+func (r *Repository) ReplaceAbundanceMaps(ctx context.Context, cubeID uint, maps []ds.AbundanceMap) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("cube_id = ?", cubeID).Delete(&ds.AbundanceMap{}).Error; err != nil {
+			return err
+		}
+		if len(maps) == 0 {
+			return nil
+		}
+		for i := range maps {
+			maps[i].ID = 0
+			maps[i].CubeID = cubeID
+		}
+		return tx.Create(&maps).Error
+	})
+	return translateError(err)
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"colorLex/internal/app/ds"
+)
+
+func (r *Repository) ListInstruments(ctx context.Context) ([]ds.Instrument, error) {
+	var instruments []ds.Instrument
+	err := r.db.WithContext(ctx).Order("id").Find(&instruments).Error
+	return instruments, translateError(err)
+}
+
+func (r *Repository) GetInstrument(ctx context.Context, id uint) (*ds.Instrument, error) {
+	var instrument ds.Instrument
+	if err := r.db.WithContext(ctx).First(&instrument, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &instrument, nil
+}
+
+func (r *Repository) CreateInstrument(ctx context.Context, instrument *ds.Instrument) error {
+	return translateError(r.db.WithContext(ctx).Create(instrument).Error)
+}
+
+func (r *Repository) UpdateInstrument(ctx context.Context, instrument *ds.Instrument) error {
+	result := r.db.WithContext(ctx).Select("*").Omit("created_at").Updates(instrument)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repository) ListCalibrations(ctx context.Context, instrumentID uint) ([]ds.InstrumentCalibration, error) {
+	var calibrations []ds.InstrumentCalibration
+	err := r.db.WithContext(ctx).
+		Where("instrument_id = ?", instrumentID).
+		Order("valid_from, id").
+		Find(&calibrations).Error
+	return calibrations, translateError(err)
+}
+
+func (r *Repository) AddCalibration(ctx context.Context, calibration *ds.InstrumentCalibration) error {
+	return translateError(r.db.WithContext(ctx).Create(calibration).Error)
+}
+
+func (r *Repository) FindCalibration(ctx context.Context, instrumentID uint, at time.Time) (*ds.InstrumentCalibration, error) {
+	var calibration ds.InstrumentCalibration
+	err := r.db.WithContext(ctx).
+		Where("instrument_id = ? AND valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)", instrumentID, at, at).
+		Order("valid_from DESC, id DESC").
+		First(&calibration).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &calibration, nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+
+	"colorLex/internal/app/ds"
+)
+
+func (r *Repository) ListInteractions(ctx context.Context, pigmentID uint) ([]PigmentInteraction, error) {
+	db := r.db.WithContext(ctx)
+	if pigmentID != 0 {
+		db = db.Where("pigment_id = ? OR other_pigment_id = ?", pigmentID, pigmentID)
+	}
+	var rules []ds.PigmentInteraction
+	if err := db.Order("id").Find(&rules).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return r.withInteractionPigments(ctx, rules)
+}
+
+func (r *Repository) GetInteraction(ctx context.Context, id uint) (*PigmentInteraction, error) {
+	var rule ds.PigmentInteraction
+	if err := r.db.WithContext(ctx).First(&rule, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	result, err := r.withInteractionPigments(ctx, []ds.PigmentInteraction{rule})
+	if err != nil {
+		return nil, err
+	}
+	return &result[0], nil
+}
+
+func (r *Repository) CreateInteraction(ctx context.Context, rule *ds.PigmentInteraction) error {
+	return translateError(r.db.WithContext(ctx).Create(rule).Error)
+}
+
+func (r *Repository) UpdateInteraction(ctx context.Context, rule *ds.PigmentInteraction) error {
+	result := r.db.WithContext(ctx).Model(rule).
+		Select("severity", "explanation", "products", "updated_at").
+		Updates(rule)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repository) DeleteInteraction(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&ds.PigmentInteraction{}, id)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// withInteractionPigments подгружает пигменты правил одним запросом
+func (r *Repository) withInteractionPigments(ctx context.Context, rules []ds.PigmentInteraction) ([]PigmentInteraction, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	var pigmentIDs []uint
+	for _, rule := range rules {
+		pigmentIDs = append(pigmentIDs, rule.PigmentID)
+		if rule.OtherPigmentID != nil {
+			pigmentIDs = append(pigmentIDs, *rule.OtherPigmentID)
+		}
+	}
+
+	// Unscoped: архивные пигменты продолжают отображаться в анализах
+	var pigments []ds.Pigment
+	if err := r.db.WithContext(ctx).Unscoped().Find(&pigments, pigmentIDs).Error; err != nil {
+		return nil, translateError(err)
+	}
+	byID := make(map[uint]ds.Pigment, len(pigments))
+	for _, pigment := range pigments {
+		byID[pigment.ID] = pigment
+	}
+
+	result := make([]PigmentInteraction, len(rules))
+	for i, rule := range rules {
+		result[i] = PigmentInteraction{Rule: rule, Pigment: byID[rule.PigmentID]}
+		if rule.OtherPigmentID != nil {
+			other := byID[*rule.OtherPigmentID]
+			result[i].Other = &other
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+
+	"colorLex/internal/app/ds"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func (r *Repository) ListLayers(ctx context.Context, analysisID uuid.UUID) ([]AnalysisLayer, error) {
+	var layers []ds.AnalysisLayer
+	err := r.db.WithContext(ctx).
+		Where("spectrum_analysis_id = ?", analysisID).
+		Order("position").
+		Find(&layers).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if len(layers) == 0 {
+		return nil, nil
+	}
+
+	layerIDs := make([]uint, len(layers))
+	for i, layer := range layers {
+		layerIDs[i] = layer.ID
+	}
+	var links []ds.LayerPigment
+	err = r.db.WithContext(ctx).
+		Where("layer_id IN ?", layerIDs).
+		Order("percent DESC, pigment_id").
+		Find(&links).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	// Unscoped: архивные пигменты продолжают отображаться в анализах
+	pigmentByID := make(map[uint]ds.Pigment)
+	if len(links) > 0 {
+		pigmentIDs := make([]uint, len(links))
+		for i, link := range links {
+			pigmentIDs[i] = link.PigmentID
+		}
+		var pigments []ds.Pigment
+		if err := r.db.WithContext(ctx).Unscoped().Find(&pigments, pigmentIDs).Error; err != nil {
+			return nil, translateError(err)
+		}
+		for _, pigment := range pigments {
+			pigmentByID[pigment.ID] = pigment
+		}
+	}
+
+	result := make([]AnalysisLayer, len(layers))
+	index := make(map[uint]int, len(layers))
+	for i, layer := range layers {
+		result[i] = AnalysisLayer{Layer: layer}
+		index[layer.ID] = i
+	}
+	for _, link := range links {
+		if pigment, ok := pigmentByID[link.PigmentID]; ok {
+			i := index[link.LayerID]
+			result[i].Pigments = append(result[i].Pigments, LayerPigment{Link: link, Pigment: pigment})
+		}
+	}
+	return result, nil
+}
+
+func (r *Repository) ReplaceLayers(ctx context.Context, analysis *ds.SpectrumAnalysis, layers []AnalysisLayer) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockAnalysis(tx, analysis); err != nil {
+			return err
+		}
+		// пигменты слоёв удаляются каскадом
+		if err := tx.Where("spectrum_analysis_id = ?", analysis.ID).Delete(&ds.AnalysisLayer{}).Error; err != nil {
+			return err
+		}
+		for i := range layers {
+			layer := &layers[i].Layer
+			layer.ID = 0
+			layer.SpectrumAnalysisID = analysis.ID
+			layer.Position = i + 1
+			if err := tx.Create(layer).Error; err != nil {
+				return err
+			}
+			if len(layers[i].Pigments) == 0 {
+				continue
+			}
+			links := make([]ds.LayerPigment, len(layers[i].Pigments))
+			for j := range layers[i].Pigments {
+				layers[i].Pigments[j].Link.LayerID = layer.ID
+				links[j] = layers[i].Pigments[j].Link
+			}
+			if err := tx.Create(&links).Error; err != nil {
+				return err
+			}
+		}
+		return bumpVersion(tx, analysis)
+	})
+	return translateError(err)
+}
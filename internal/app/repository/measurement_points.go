@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+
+	"colorLex/internal/app/ds"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (r *Repository) GetMeasurementPoint(ctx context.Context, analysisID uuid.UUID) (*ds.MeasurementPoint, error) {
+	var point ds.MeasurementPoint
+	err := r.db.WithContext(ctx).First(&point, "spectrum_analysis_id = ?", analysisID).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &point, nil
+}
+
+func (r *Repository) SaveMeasurementPoint(ctx context.Context, analysis *ds.SpectrumAnalysis, point *ds.MeasurementPoint) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockAnalysis(tx, analysis); err != nil {
+			return err
+		}
+		point.SpectrumAnalysisID = analysis.ID
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "spectrum_analysis_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"artwork_id", "x", "y", "label", "layer", "updated_at"}),
+		}).Create(point).Error
+		if err != nil {
+			return err
+		}
+		return bumpVersion(tx, analysis)
+	})
+	return translateError(err)
+}
+
+func (r *Repository) DeleteMeasurementPoint(ctx context.Context, analysis *ds.SpectrumAnalysis) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockAnalysis(tx, analysis); err != nil {
+			return err
+		}
+		result := tx.Where("spectrum_analysis_id = ?", analysis.ID).Delete(&ds.MeasurementPoint{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return bumpVersion(tx, analysis)
+	})
+	return translateError(err)
+}
+
+func (r *Repository) ListArtworkPoints(ctx context.Context, artworkID uint) ([]ArtworkPoint, error) {
+	// Точка действительна, пока заявка привязана к тому же произведению
+	var points []ds.MeasurementPoint
+	err := r.db.WithContext(ctx).
+		Joins("JOIN spectrum_analysis a ON a.id = measurement_points.spectrum_analysis_id AND a.artwork_id = measurement_points.artwork_id").
+		Where("measurement_points.artwork_id = ? AND a.status <> ?", artworkID, ds.StatusDeleted).
+		Order("measurement_points.created_at").
+		Find(&points).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if len(points) == 0 {
+		return nil, nil
+	}
+
+	analysisIDs := make([]uuid.UUID, len(points))
+	for i, point := range points {
+		analysisIDs[i] = point.SpectrumAnalysisID
+	}
+
+	var analyses []ds.SpectrumAnalysis
+	if err := r.db.WithContext(ctx).Where("id IN ?", analysisIDs).Find(&analyses).Error; err != nil {
+		return nil, translateError(err)
+	}
+	analysisByID := make(map[uuid.UUID]ds.SpectrumAnalysis, len(analyses))
+	for _, analysis := range analyses {
+		analysisByID[analysis.ID] = analysis
+	}
+
+	// Преобладающий пигмент каждой заявки; при равенстве процентов - с меньшим ID
+	var links []ds.SpectrumAnalysisPigment
+	err = r.db.WithContext(ctx).
+		Raw("SELECT DISTINCT ON (spectrum_analysis_id) * FROM spectrumanalysis_pigment "+
+			"WHERE spectrum_analysis_id IN ? AND percent > 0 "+
+			"ORDER BY spectrum_analysis_id, percent DESC, pigment_id", analysisIDs).
+		Scan(&links).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	dominant := make(map[uuid.UUID]ds.SpectrumAnalysisPigment, len(links))
+	pigmentIDs := make([]uint, 0, len(links))
+	for _, link := range links {
+		dominant[link.SpectrumAnalysisID] = link
+		pigmentIDs = append(pigmentIDs, link.PigmentID)
+	}
+
+	// Unscoped: архивные пигменты продолжают отображаться в анализах
+	pigmentByID := make(map[uint]ds.Pigment, len(pigmentIDs))
+	if len(pigmentIDs) > 0 {
+		var pigments []ds.Pigment
+		if err := r.db.WithContext(ctx).Unscoped().Find(&pigments, pigmentIDs).Error; err != nil {
+			return nil, translateError(err)
+		}
+		for _, pigment := range pigments {
+			pigmentByID[pigment.ID] = pigment
+		}
+	}
+
+	result := make([]ArtworkPoint, 0, len(points))
+	for _, point := range points {
+		item := ArtworkPoint{Point: point, Analysis: analysisByID[point.SpectrumAnalysisID]}
+		if link, ok := dominant[point.SpectrumAnalysisID]; ok {
+			if pigment, ok := pigmentByID[link.PigmentID]; ok {
+				item.Dominant = &AnalysisPigment{Link: link, Pigment: pigment}
+			}
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
@@ -0,0 +1,1394 @@
+// Package memstore - хранилище в памяти с теми же интерфейсами, что и repository.Repository.
+// Используется в тестах обработчиков, которым не нужен Postgres.
+package memstore
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type linkKey struct {
+	analysisID uuid.UUID
+	pigmentID  uint
+}
+
+type Store struct {
+	mu sync.Mutex
+
+	pigments      map[uint]ds.Pigment
+	analyses      map[uuid.UUID]ds.SpectrumAnalysis
+	links         map[linkKey]ds.SpectrumAnalysisPigment
+	points        map[uuid.UUID]ds.MeasurementPoint
+	readings      map[uint]ds.SpectrumReading
+	references    map[uint]ds.ReferenceSpectrum
+	bands         map[uint]ds.RamanBand
+	artworks      map[uint]ds.Artwork
+	users         map[uint]ds.User
+	instruments   map[uint]ds.Instrument
+	calibrations  map[uint]ds.InstrumentCalibration
+	cubes         map[uint]ds.HyperspectralCube
+	maps          map[uint]ds.AbundanceMap
+	layers        map[uint]ds.AnalysisLayer
+	layerPigments map[uint][]ds.LayerPigment // по ID слоя
+	interactions  map[uint]ds.PigmentInteraction
+	nextPigmentID uint
+	nextArtworkID uint
+	nextReadingID uint
+	nextRefID     uint
+	nextBandID    uint
+	nextUserID    uint
+	nextInstrID   uint
+	nextCalibID   uint
+	nextCubeID    uint
+	nextMapID     uint
+	nextLayerID   uint
+	nextInterID   uint
+}
+
+var (
+	_ repository.PigmentStore    = (*Store)(nil)
+	_ repository.AnalysisStore   = (*Store)(nil)
+	_ repository.ArtworkStore    = (*Store)(nil)
+	_ repository.UserStore       = (*Store)(nil)
+	_ repository.InstrumentStore = (*Store)(nil)
+	_ repository.CubeStore       = (*Store)(nil)
+)
+
+func New() *Store {
+	return &Store{
+		pigments:      make(map[uint]ds.Pigment),
+		analyses:      make(map[uuid.UUID]ds.SpectrumAnalysis),
+		links:         make(map[linkKey]ds.SpectrumAnalysisPigment),
+		points:        make(map[uuid.UUID]ds.MeasurementPoint),
+		readings:      make(map[uint]ds.SpectrumReading),
+		references:    make(map[uint]ds.ReferenceSpectrum),
+		bands:         make(map[uint]ds.RamanBand),
+		artworks:      make(map[uint]ds.Artwork),
+		users:         make(map[uint]ds.User),
+		instruments:   make(map[uint]ds.Instrument),
+		calibrations:  make(map[uint]ds.InstrumentCalibration),
+		cubes:         make(map[uint]ds.HyperspectralCube),
+		maps:          make(map[uint]ds.AbundanceMap),
+		layers:        make(map[uint]ds.AnalysisLayer),
+		layerPigments: make(map[uint][]ds.LayerPigment),
+		interactions:  make(map[uint]ds.PigmentInteraction),
+		nextPigmentID: 1,
+		nextArtworkID: 1,
+		nextReadingID: 1,
+		nextRefID:     1,
+		nextBandID:    1,
+		nextUserID:    1,
+		nextInstrID:   1,
+		nextCalibID:   1,
+		nextCubeID:    1,
+		nextMapID:     1,
+		nextLayerID:   1,
+		nextInterID:   1,
+	}
+}
+
+// Pigments
+
+func (s *Store) ListPigments(ctx context.Context, query repository.PigmentQuery) ([]ds.Pigment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids map[uint]bool
+	if query.IDs != nil {
+		ids = make(map[uint]bool, len(query.IDs))
+		for _, id := range query.IDs {
+			ids[id] = true
+		}
+	}
+
+	var result []ds.Pigment
+	for _, pigment := range s.pigments {
+		if pigment.DeletedAt.Valid && !query.IncludeArchived {
+			continue
+		}
+		if ids != nil && !ids[pigment.ID] {
+			continue
+		}
+		if query.Search != "" && !containsFold(pigment.Name, query.Search) {
+			continue
+		}
+		if query.Color != "" && !containsFold(pigment.Color, query.Color) {
+			continue
+		}
+		if query.CIName != "" && !strings.EqualFold(pigment.CIName, query.CIName) {
+			continue
+		}
+		if query.CASNumber != "" && pigment.CASNumber != query.CASNumber {
+			continue
+		}
+		if query.Formula != "" && !containsFold(pigment.Formula, query.Formula) {
+			continue
+		}
+		if query.Class != "" && pigment.Class != query.Class {
+			continue
+		}
+		if query.Toxicity != "" && pigment.Toxicity != query.Toxicity {
+			continue
+		}
+		if query.MaxLightfastness > 0 && (pigment.Lightfastness == nil || *pigment.Lightfastness > query.MaxLightfastness) {
+			continue
+		}
+		if year := query.AvailableIn; year != nil &&
+			((pigment.AvailableFrom != nil && *pigment.AvailableFrom > *year) ||
+				(pigment.AvailableTo != nil && *pigment.AvailableTo < *year)) {
+			continue
+		}
+		if query.CreatedFrom != nil && pigment.CreatedAt.Before(*query.CreatedFrom) {
+			continue
+		}
+		if query.CreatedTo != nil && !pigment.CreatedAt.Before(*query.CreatedTo) {
+			continue
+		}
+		result = append(result, pigment)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	return paginate(result, query.Limit, query.Offset), nil
+}
+
+func (s *Store) GetPigment(ctx context.Context, id uint, withArchived bool) (*ds.Pigment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pigment, ok := s.pigments[id]
+	if !ok || (pigment.DeletedAt.Valid && !withArchived) {
+		return nil, repository.ErrNotFound
+	}
+	return &pigment, nil
+}
+
+func (s *Store) CreatePigment(ctx context.Context, pigment *ds.Pigment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	pigment.ID = s.nextPigmentID
+	s.nextPigmentID++
+	if pigment.CreatedAt.IsZero() {
+		pigment.CreatedAt = now
+	}
+	pigment.UpdatedAt = now
+	s.pigments[pigment.ID] = *pigment
+	return nil
+}
+
+func (s *Store) UpdatePigment(ctx context.Context, pigment *ds.Pigment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.pigments[pigment.ID]
+	if !ok || existing.DeletedAt.Valid {
+		return repository.ErrNotFound
+	}
+	pigment.CreatedAt = existing.CreatedAt
+	pigment.DeletedAt = existing.DeletedAt
+	pigment.UpdatedAt = time.Now()
+	s.pigments[pigment.ID] = *pigment
+	return nil
+}
+
+func (s *Store) ArchivePigment(ctx context.Context, id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pigment, ok := s.pigments[id]
+	if !ok || pigment.DeletedAt.Valid {
+		return repository.ErrNotFound
+	}
+	pigment.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	s.pigments[id] = pigment
+	return nil
+}
+
+func (s *Store) RestorePigment(ctx context.Context, id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pigment, ok := s.pigments[id]
+	if !ok || !pigment.DeletedAt.Valid {
+		return repository.ErrNotFound
+	}
+	pigment.DeletedAt = gorm.DeletedAt{}
+	s.pigments[id] = pigment
+	return nil
+}
+
+func (s *Store) ListReferenceSpectra(ctx context.Context, pigmentID uint, technique string) ([]ds.ReferenceSpectrum, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []ds.ReferenceSpectrum
+	for _, reference := range s.references {
+		if pigmentID != 0 && reference.PigmentID != pigmentID {
+			continue
+		}
+		if pigmentID == 0 && s.pigments[reference.PigmentID].DeletedAt.Valid {
+			continue
+		}
+		if technique != "" && reference.Technique != technique {
+			continue
+		}
+		result = append(result, reference)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (s *Store) ListReferenceFeatures(ctx context.Context, technique string) ([]ds.ReferenceSpectrum, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []ds.ReferenceSpectrum
+	for _, reference := range s.references {
+		if reference.Technique != technique || reference.Features == nil {
+			continue
+		}
+		result = append(result, ds.ReferenceSpectrum{
+			ID:        reference.ID,
+			PigmentID: reference.PigmentID,
+			Technique: reference.Technique,
+			Features:  reference.Features,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (s *Store) ListReferenceIDs(ctx context.Context, technique string) ([]uint, error) {
+	references, err := s.ListReferenceFeatures(ctx, technique)
+	ids := make([]uint, len(references))
+	for i, reference := range references {
+		ids[i] = reference.ID
+	}
+	return ids, err
+}
+
+func (s *Store) GetReferenceSpectra(ctx context.Context, ids []uint) ([]ds.ReferenceSpectrum, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []ds.ReferenceSpectrum
+	for _, id := range ids {
+		if reference, ok := s.references[id]; ok {
+			result = append(result, reference)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (s *Store) AddReferenceSpectrum(ctx context.Context, reference *ds.ReferenceSpectrum) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pigments[reference.PigmentID]; !ok {
+		return repository.ErrNotFound
+	}
+	reference.ID = s.nextRefID
+	s.nextRefID++
+	reference.CreatedAt = time.Now()
+	s.references[reference.ID] = *reference
+	return nil
+}
+
+func (s *Store) DeleteReferenceSpectrum(ctx context.Context, pigmentID, id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reference, ok := s.references[id]
+	if !ok || reference.PigmentID != pigmentID {
+		return repository.ErrNotFound
+	}
+	delete(s.references, id)
+	return nil
+}
+
+func (s *Store) ListRamanBands(ctx context.Context, pigmentID uint) ([]ds.RamanBand, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []ds.RamanBand
+	for _, band := range s.bands {
+		if pigmentID != 0 && band.PigmentID != pigmentID {
+			continue
+		}
+		if pigmentID == 0 && s.pigments[band.PigmentID].DeletedAt.Valid {
+			continue
+		}
+		result = append(result, band)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].PigmentID != result[j].PigmentID {
+			return result[i].PigmentID < result[j].PigmentID
+		}
+		return result[i].Position < result[j].Position
+	})
+	return result, nil
+}
+
+func (s *Store) ReplaceRamanBands(ctx context.Context, pigmentID uint, bands []ds.RamanBand) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pigments[pigmentID]; !ok {
+		return repository.ErrNotFound
+	}
+	for id, band := range s.bands {
+		if band.PigmentID == pigmentID {
+			delete(s.bands, id)
+		}
+	}
+	for i := range bands {
+		bands[i].ID = s.nextBandID
+		s.nextBandID++
+		bands[i].PigmentID = pigmentID
+		s.bands[bands[i].ID] = bands[i]
+	}
+	return nil
+}
+
+// InTransaction откатывает пигменты, эталоны и полосы, если fn вернула ошибку.
+// Изменения других горутин во время fn при откате тоже теряются - для тестов этого хватает
+func (s *Store) InTransaction(ctx context.Context, fn func(store repository.PigmentStore) error) error {
+	s.mu.Lock()
+	pigments, references, bands := maps.Clone(s.pigments), maps.Clone(s.references), maps.Clone(s.bands)
+	nextPigmentID, nextRefID, nextBandID := s.nextPigmentID, s.nextRefID, s.nextBandID
+	s.mu.Unlock()
+
+	err := fn(s)
+	if err != nil {
+		s.mu.Lock()
+		s.pigments, s.references, s.bands = pigments, references, bands
+		s.nextPigmentID, s.nextRefID, s.nextBandID = nextPigmentID, nextRefID, nextBandID
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// Interactions
+
+func (s *Store) ListInteractions(ctx context.Context, pigmentID uint) ([]repository.PigmentInteraction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []repository.PigmentInteraction
+	for _, rule := range s.interactions {
+		if pigmentID != 0 && rule.PigmentID != pigmentID && (rule.OtherPigmentID == nil || *rule.OtherPigmentID != pigmentID) {
+			continue
+		}
+		result = append(result, s.withInteractionPigments(rule))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Rule.ID < result[j].Rule.ID })
+	return result, nil
+}
+
+func (s *Store) GetInteraction(ctx context.Context, id uint) (*repository.PigmentInteraction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule, ok := s.interactions[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	result := s.withInteractionPigments(rule)
+	return &result, nil
+}
+
+func (s *Store) CreateInteraction(ctx context.Context, rule *ds.PigmentInteraction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pigments[rule.PigmentID]; !ok {
+		return repository.ErrNotFound
+	}
+	if rule.OtherPigmentID != nil {
+		if _, ok := s.pigments[*rule.OtherPigmentID]; !ok {
+			return repository.ErrNotFound
+		}
+	}
+	if s.interactionTaken(*rule) {
+		return repository.ErrAlreadyExists
+	}
+	now := time.Now()
+	rule.ID = s.nextInterID
+	s.nextInterID++
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+	s.interactions[rule.ID] = *rule
+	return nil
+}
+
+func (s *Store) UpdateInteraction(ctx context.Context, rule *ds.PigmentInteraction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.interactions[rule.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	existing.Severity = rule.Severity
+	existing.Explanation = rule.Explanation
+	existing.Products = rule.Products
+	existing.UpdatedAt = time.Now()
+	s.interactions[rule.ID] = existing
+	*rule = existing
+	return nil
+}
+
+func (s *Store) DeleteInteraction(ctx context.Context, id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.interactions[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(s.interactions, id)
+	return nil
+}
+
+// interactionTaken повторяет уникальный индекс uq_pigment_interactions_pair
+func (s *Store) interactionTaken(rule ds.PigmentInteraction) bool {
+	for _, other := range s.interactions {
+		if other.PigmentID != rule.PigmentID {
+			continue
+		}
+		if (other.OtherPigmentID == nil) == (rule.OtherPigmentID == nil) &&
+			(other.OtherPigmentID == nil || *other.OtherPigmentID == *rule.OtherPigmentID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) withInteractionPigments(rule ds.PigmentInteraction) repository.PigmentInteraction {
+	result := repository.PigmentInteraction{Rule: rule, Pigment: s.pigments[rule.PigmentID]}
+	if rule.OtherPigmentID != nil {
+		other := s.pigments[*rule.OtherPigmentID]
+		result.Other = &other
+	}
+	return result
+}
+
+// Analyses
+
+func (s *Store) FindDraft(ctx context.Context, creatorID uint) (*ds.SpectrumAnalysis, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.findDraft(creatorID)
+}
+
+func (s *Store) findDraft(creatorID uint) (*ds.SpectrumAnalysis, error) {
+	for _, analysis := range s.analyses {
+		if analysis.CreatorID == creatorID && analysis.Status == ds.StatusDraft {
+			return &analysis, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (s *Store) AddPigmentToDraft(ctx context.Context, creatorID, pigmentID uint) (*ds.SpectrumAnalysis, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	analysis, err := s.findDraft(creatorID)
+	if err != nil {
+		analysis = &ds.SpectrumAnalysis{
+			ID:        uuid.New(),
+			Name:      repository.DraftName,
+			Status:    ds.StatusDraft,
+			CreatedAt: time.Now(),
+			CreatorID: creatorID,
+			Version:   1,
+		}
+		s.analyses[analysis.ID] = *analysis
+	}
+
+	key := linkKey{analysisID: analysis.ID, pigmentID: pigmentID}
+	if _, exists := s.links[key]; exists {
+		return nil, 0, repository.ErrAlreadyExists
+	}
+	s.links[key] = ds.SpectrumAnalysisPigment{
+		SpectrumAnalysisID: analysis.ID,
+		PigmentID:          pigmentID,
+		CreatedAt:          time.Now(),
+	}
+	analysis.Version++
+	s.analyses[analysis.ID] = *analysis
+
+	return analysis, s.countLinks(analysis.ID), nil
+}
+
+func (s *Store) CountAnalysisPigments(ctx context.Context, analysisID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.countLinks(analysisID), nil
+}
+
+func (s *Store) countLinks(analysisID uuid.UUID) int64 {
+	var count int64
+	for key := range s.links {
+		if key.analysisID == analysisID {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Store) ListAnalyses(ctx context.Context, query repository.AnalysisQuery) ([]ds.SpectrumAnalysis, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []ds.SpectrumAnalysis
+	for _, analysis := range s.analyses {
+		if analysis.Status == ds.StatusDraft || analysis.Status == ds.StatusDeleted {
+			continue
+		}
+		if query.CreatorID != nil && analysis.CreatorID != *query.CreatorID {
+			continue
+		}
+		if query.ArtworkID != nil && (analysis.ArtworkID == nil || *analysis.ArtworkID != *query.ArtworkID) {
+			continue
+		}
+		if query.Status != "" && analysis.Status != query.Status {
+			continue
+		}
+		if !query.FormedFrom.IsZero() && (analysis.FormedAt == nil || analysis.FormedAt.Before(query.FormedFrom)) {
+			continue
+		}
+		if !query.FormedTo.IsZero() && (analysis.FormedAt == nil || analysis.FormedAt.After(query.FormedTo)) {
+			continue
+		}
+		result = append(result, analysis)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+
+	return paginate(result, query.Limit, query.Offset), nil
+}
+
+func (s *Store) GetAnalysis(ctx context.Context, id uuid.UUID) (*ds.SpectrumAnalysis, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	analysis, ok := s.analyses[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &analysis, nil
+}
+
+func (s *Store) UpdateAnalysis(ctx context.Context, analysis *ds.SpectrumAnalysis) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updateAnalysis(analysis)
+}
+
+func (s *Store) updateAnalysis(analysis *ds.SpectrumAnalysis) error {
+	existing, err := s.checkVersion(analysis)
+	if err != nil {
+		return err
+	}
+	analysis.CreatedAt = existing.CreatedAt
+	analysis.Version++
+	s.analyses[analysis.ID] = *analysis
+	return nil
+}
+
+// checkVersion сверяет версию заявки с сохранённой, как это делает блокировка строки в Postgres
+func (s *Store) checkVersion(analysis *ds.SpectrumAnalysis) (ds.SpectrumAnalysis, error) {
+	existing, ok := s.analyses[analysis.ID]
+	if !ok {
+		return existing, repository.ErrNotFound
+	}
+	if existing.Version != analysis.Version {
+		return existing, repository.ErrVersionConflict
+	}
+	return existing, nil
+}
+
+// bumpVersion увеличивает версию заявки после изменения её пигментов
+func (s *Store) bumpVersion(analysis *ds.SpectrumAnalysis) {
+	existing := s.analyses[analysis.ID]
+	existing.Version++
+	s.analyses[analysis.ID] = existing
+	analysis.Version = existing.Version
+}
+
+func (s *Store) CompleteAnalysis(ctx context.Context, analysis *ds.SpectrumAnalysis, results map[uint]repository.PigmentResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.checkVersion(analysis); err != nil {
+		return err
+	}
+	for pigmentID, result := range results {
+		key := linkKey{analysisID: analysis.ID, pigmentID: pigmentID}
+		if link, ok := s.links[key]; ok {
+			link.Percent = result.Percent
+			link.PercentLow = result.PercentLow
+			link.PercentHigh = result.PercentHigh
+			link.Indistinguishable = result.Indistinguishable
+			link.Verdict = result.Verdict
+			link.VerdictNote = result.VerdictNote
+			link.RamanScore = result.RamanScore
+			s.links[key] = link
+		}
+	}
+	return s.updateAnalysis(analysis)
+}
+
+func (s *Store) ListAnalysisPigments(ctx context.Context, analysisID uuid.UUID) ([]repository.AnalysisPigment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []repository.AnalysisPigment
+	for key, link := range s.links {
+		if key.analysisID != analysisID {
+			continue
+		}
+		if pigment, ok := s.pigments[key.pigmentID]; ok {
+			result = append(result, repository.AnalysisPigment{Link: link, Pigment: pigment})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Pigment.ID < result[j].Pigment.ID })
+	return result, nil
+}
+
+func (s *Store) ListCompositions(ctx context.Context) ([]repository.Composition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byAnalysis := make(map[uuid.UUID][]repository.AnalysisPigment)
+	for key, link := range s.links {
+		analysis, ok := s.analyses[key.analysisID]
+		if !ok || analysis.Status != ds.StatusCompleted || link.Percent <= 0 {
+			continue
+		}
+		if pigment, ok := s.pigments[key.pigmentID]; ok {
+			byAnalysis[key.analysisID] = append(byAnalysis[key.analysisID], repository.AnalysisPigment{Link: link, Pigment: pigment})
+		}
+	}
+
+	result := make([]repository.Composition, 0, len(byAnalysis))
+	for analysisID, pigments := range byAnalysis {
+		sort.Slice(pigments, func(i, j int) bool {
+			if pigments[i].Link.Percent != pigments[j].Link.Percent {
+				return pigments[i].Link.Percent > pigments[j].Link.Percent
+			}
+			return pigments[i].Pigment.ID < pigments[j].Pigment.ID
+		})
+		item := repository.Composition{Analysis: s.analyses[analysisID], Pigments: pigments}
+		if item.Analysis.ArtworkID != nil {
+			if artwork, ok := s.artworks[*item.Analysis.ArtworkID]; ok {
+				item.Artwork = &artwork
+			}
+		}
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i].Analysis, result[j].Analysis
+		if a.CompletedAt != nil && b.CompletedAt != nil && !a.CompletedAt.Equal(*b.CompletedAt) {
+			return a.CompletedAt.Before(*b.CompletedAt)
+		}
+		return a.ID.String() < b.ID.String()
+	})
+	return result, nil
+}
+
+func (s *Store) GetAnalysisPigment(ctx context.Context, analysisID uuid.UUID, pigmentID uint) (*ds.SpectrumAnalysisPigment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.links[linkKey{analysisID: analysisID, pigmentID: pigmentID}]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &link, nil
+}
+
+func (s *Store) UpdateAnalysisPigment(ctx context.Context, analysis *ds.SpectrumAnalysis, link *ds.SpectrumAnalysisPigment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.checkVersion(analysis); err != nil {
+		return err
+	}
+	key := linkKey{analysisID: analysis.ID, pigmentID: link.PigmentID}
+	existing, ok := s.links[key]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	existing.Comment = link.Comment
+	existing.Percent = link.Percent
+	s.links[key] = existing
+	s.bumpVersion(analysis)
+	return nil
+}
+
+func (s *Store) RemoveAnalysisPigment(ctx context.Context, analysis *ds.SpectrumAnalysis, pigmentID uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.checkVersion(analysis); err != nil {
+		return err
+	}
+	key := linkKey{analysisID: analysis.ID, pigmentID: pigmentID}
+	if _, ok := s.links[key]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(s.links, key)
+	s.bumpVersion(analysis)
+	return nil
+}
+
+func (s *Store) GetMeasurementPoint(ctx context.Context, analysisID uuid.UUID) (*ds.MeasurementPoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	point, ok := s.points[analysisID]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &point, nil
+}
+
+func (s *Store) SaveMeasurementPoint(ctx context.Context, analysis *ds.SpectrumAnalysis, point *ds.MeasurementPoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.checkVersion(analysis); err != nil {
+		return err
+	}
+	now := time.Now()
+	point.SpectrumAnalysisID = analysis.ID
+	point.CreatedAt = now
+	if existing, ok := s.points[analysis.ID]; ok {
+		point.CreatedAt = existing.CreatedAt
+	}
+	point.UpdatedAt = now
+	s.points[analysis.ID] = *point
+	s.bumpVersion(analysis)
+	return nil
+}
+
+func (s *Store) DeleteMeasurementPoint(ctx context.Context, analysis *ds.SpectrumAnalysis) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.checkVersion(analysis); err != nil {
+		return err
+	}
+	if _, ok := s.points[analysis.ID]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(s.points, analysis.ID)
+	s.bumpVersion(analysis)
+	return nil
+}
+
+func (s *Store) ListReadings(ctx context.Context, analysisID uuid.UUID) ([]ds.SpectrumReading, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []ds.SpectrumReading
+	for _, reading := range s.readings {
+		if reading.SpectrumAnalysisID == analysisID {
+			result = append(result, reading)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (s *Store) AddReading(ctx context.Context, analysis *ds.SpectrumAnalysis, reading *ds.SpectrumReading) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.checkVersion(analysis); err != nil {
+		return err
+	}
+	reading.ID = s.nextReadingID
+	s.nextReadingID++
+	reading.SpectrumAnalysisID = analysis.ID
+	reading.CreatedAt = time.Now()
+	s.readings[reading.ID] = *reading
+	s.bumpVersion(analysis)
+	return nil
+}
+
+func (s *Store) RemoveReading(ctx context.Context, analysis *ds.SpectrumAnalysis, readingID uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.checkVersion(analysis); err != nil {
+		return err
+	}
+	reading, ok := s.readings[readingID]
+	if !ok || reading.SpectrumAnalysisID != analysis.ID {
+		return repository.ErrNotFound
+	}
+	delete(s.readings, readingID)
+	s.bumpVersion(analysis)
+	return nil
+}
+
+func (s *Store) ListLayers(ctx context.Context, analysisID uuid.UUID) ([]repository.AnalysisLayer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []repository.AnalysisLayer
+	for _, layer := range s.layers {
+		if layer.SpectrumAnalysisID != analysisID {
+			continue
+		}
+		item := repository.AnalysisLayer{Layer: layer}
+		for _, link := range s.layerPigments[layer.ID] {
+			if pigment, ok := s.pigments[link.PigmentID]; ok {
+				item.Pigments = append(item.Pigments, repository.LayerPigment{Link: link, Pigment: pigment})
+			}
+		}
+		sort.Slice(item.Pigments, func(i, j int) bool {
+			a, b := item.Pigments[i].Link, item.Pigments[j].Link
+			if a.Percent != b.Percent {
+				return a.Percent > b.Percent
+			}
+			return a.PigmentID < b.PigmentID
+		})
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Layer.Position < result[j].Layer.Position })
+	return result, nil
+}
+
+func (s *Store) ReplaceLayers(ctx context.Context, analysis *ds.SpectrumAnalysis, layers []repository.AnalysisLayer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.checkVersion(analysis); err != nil {
+		return err
+	}
+	for id, layer := range s.layers {
+		if layer.SpectrumAnalysisID == analysis.ID {
+			delete(s.layers, id)
+			delete(s.layerPigments, id)
+		}
+	}
+	for i := range layers {
+		layer := &layers[i].Layer
+		layer.ID = s.nextLayerID
+		s.nextLayerID++
+		layer.SpectrumAnalysisID = analysis.ID
+		layer.Position = i + 1
+		s.layers[layer.ID] = *layer
+		links := make([]ds.LayerPigment, len(layers[i].Pigments))
+		for j := range layers[i].Pigments {
+			layers[i].Pigments[j].Link.LayerID = layer.ID
+			links[j] = layers[i].Pigments[j].Link
+		}
+		s.layerPigments[layer.ID] = links
+	}
+	s.bumpVersion(analysis)
+	return nil
+}
+
+// Artworks
+
+func (s *Store) ListArtworks(ctx context.Context, query repository.ArtworkQuery) ([]ds.Artwork, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []ds.Artwork
+	for _, artwork := range s.artworks {
+		if query.Search != "" && !containsFold(artwork.Title, query.Search) && !containsFold(artwork.Artist, query.Search) {
+			continue
+		}
+		if query.Collection != "" && !containsFold(artwork.Collection, query.Collection) {
+			continue
+		}
+		result = append(result, artwork)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	return paginate(result, query.Limit, query.Offset), nil
+}
+
+func (s *Store) GetArtwork(ctx context.Context, id uint) (*ds.Artwork, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	artwork, ok := s.artworks[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &artwork, nil
+}
+
+func (s *Store) CreateArtwork(ctx context.Context, artwork *ds.Artwork) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.inventoryTaken(*artwork) {
+		return repository.ErrAlreadyExists
+	}
+	now := time.Now()
+	artwork.ID = s.nextArtworkID
+	s.nextArtworkID++
+	artwork.CreatedAt = now
+	artwork.UpdatedAt = now
+	s.artworks[artwork.ID] = *artwork
+	return nil
+}
+
+func (s *Store) UpdateArtwork(ctx context.Context, artwork *ds.Artwork) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.artworks[artwork.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if s.inventoryTaken(*artwork) {
+		return repository.ErrAlreadyExists
+	}
+	artwork.CreatorID = existing.CreatorID
+	artwork.CreatedAt = existing.CreatedAt
+	artwork.UpdatedAt = time.Now()
+	s.artworks[artwork.ID] = *artwork
+	return nil
+}
+
+// inventoryTaken повторяет частичный уникальный индекс uniq_artworks_inventory
+func (s *Store) inventoryTaken(artwork ds.Artwork) bool {
+	if artwork.InventoryNumber == "" {
+		return false
+	}
+	for _, other := range s.artworks {
+		if other.ID != artwork.ID && other.Collection == artwork.Collection && other.InventoryNumber == artwork.InventoryNumber {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) DeleteArtwork(ctx context.Context, id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.artworks[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(s.artworks, id)
+	for analysisID, point := range s.points {
+		if point.ArtworkID == id {
+			delete(s.points, analysisID)
+		}
+	}
+	for analysisID, analysis := range s.analyses {
+		if analysis.ArtworkID != nil && *analysis.ArtworkID == id {
+			analysis.ArtworkID = nil
+			s.analyses[analysisID] = analysis
+		}
+	}
+	return nil
+}
+
+func (s *Store) ArtworkPigmentStats(ctx context.Context, artworkID uint) ([]repository.ArtworkPigmentStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byPigment := make(map[uint]*repository.ArtworkPigmentStats)
+	for key, link := range s.links {
+		analysis := s.analyses[key.analysisID]
+		if analysis.ArtworkID == nil || *analysis.ArtworkID != artworkID || analysis.Status != ds.StatusCompleted {
+			continue
+		}
+		pigment, ok := s.pigments[key.pigmentID]
+		if !ok {
+			continue
+		}
+		stats, ok := byPigment[key.pigmentID]
+		if !ok {
+			stats = &repository.ArtworkPigmentStats{Pigment: pigment, MinPercent: link.Percent, MaxPercent: link.Percent}
+			byPigment[key.pigmentID] = stats
+		}
+		stats.Analyses++
+		stats.MinPercent = min(stats.MinPercent, link.Percent)
+		stats.MaxPercent = max(stats.MaxPercent, link.Percent)
+		stats.MeanPercent += link.Percent
+	}
+
+	var result []repository.ArtworkPigmentStats
+	for _, stats := range byPigment {
+		stats.MeanPercent /= float64(stats.Analyses)
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].MeanPercent != result[j].MeanPercent {
+			return result[i].MeanPercent > result[j].MeanPercent
+		}
+		return result[i].Pigment.ID < result[j].Pigment.ID
+	})
+	return result, nil
+}
+
+func (s *Store) CountArtworkAnalyses(ctx context.Context, artworkID uint, status string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for _, analysis := range s.analyses {
+		if analysis.ArtworkID != nil && *analysis.ArtworkID == artworkID && analysis.Status == status {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) ListArtworkPoints(ctx context.Context, artworkID uint) ([]repository.ArtworkPoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []repository.ArtworkPoint
+	for analysisID, point := range s.points {
+		analysis, ok := s.analyses[analysisID]
+		if !ok || point.ArtworkID != artworkID || analysis.Status == ds.StatusDeleted ||
+			analysis.ArtworkID == nil || *analysis.ArtworkID != artworkID {
+			continue
+		}
+		item := repository.ArtworkPoint{Point: point, Analysis: analysis}
+		for key, link := range s.links {
+			if key.analysisID != analysisID || link.Percent <= 0 {
+				continue
+			}
+			if item.Dominant != nil && (link.Percent < item.Dominant.Link.Percent ||
+				(link.Percent == item.Dominant.Link.Percent && link.PigmentID > item.Dominant.Link.PigmentID)) {
+				continue
+			}
+			if pigment, ok := s.pigments[key.pigmentID]; ok {
+				item.Dominant = &repository.AnalysisPigment{Link: link, Pigment: pigment}
+			}
+		}
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Point.CreatedAt.Equal(result[j].Point.CreatedAt) {
+			return result[i].Point.CreatedAt.Before(result[j].Point.CreatedAt)
+		}
+		return result[i].Analysis.ID.String() < result[j].Analysis.ID.String()
+	})
+	return result, nil
+}
+
+// Instruments
+
+func (s *Store) ListInstruments(ctx context.Context) ([]ds.Instrument, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []ds.Instrument
+	for _, instrument := range s.instruments {
+		result = append(result, instrument)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (s *Store) GetInstrument(ctx context.Context, id uint) (*ds.Instrument, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	instrument, ok := s.instruments[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &instrument, nil
+}
+
+func (s *Store) CreateInstrument(ctx context.Context, instrument *ds.Instrument) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.serialTaken(*instrument) {
+		return repository.ErrAlreadyExists
+	}
+	now := time.Now()
+	instrument.ID = s.nextInstrID
+	s.nextInstrID++
+	instrument.CreatedAt = now
+	instrument.UpdatedAt = now
+	s.instruments[instrument.ID] = *instrument
+	return nil
+}
+
+func (s *Store) UpdateInstrument(ctx context.Context, instrument *ds.Instrument) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.instruments[instrument.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if s.serialTaken(*instrument) {
+		return repository.ErrAlreadyExists
+	}
+	instrument.CreatedAt = existing.CreatedAt
+	instrument.UpdatedAt = time.Now()
+	s.instruments[instrument.ID] = *instrument
+	return nil
+}
+
+// serialTaken повторяет ограничение uq_instruments_serial
+func (s *Store) serialTaken(instrument ds.Instrument) bool {
+	for _, other := range s.instruments {
+		if other.ID != instrument.ID && other.Serial == instrument.Serial {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) ListCalibrations(ctx context.Context, instrumentID uint) ([]ds.InstrumentCalibration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []ds.InstrumentCalibration
+	for _, calibration := range s.calibrations {
+		if calibration.InstrumentID == instrumentID {
+			result = append(result, calibration)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].ValidFrom.Equal(result[j].ValidFrom) {
+			return result[i].ValidFrom.Before(result[j].ValidFrom)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+func (s *Store) AddCalibration(ctx context.Context, calibration *ds.InstrumentCalibration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.instruments[calibration.InstrumentID]; !ok {
+		return repository.ErrNotFound
+	}
+	calibration.ID = s.nextCalibID
+	s.nextCalibID++
+	calibration.CreatedAt = time.Now()
+	s.calibrations[calibration.ID] = *calibration
+	return nil
+}
+
+func (s *Store) FindCalibration(ctx context.Context, instrumentID uint, at time.Time) (*ds.InstrumentCalibration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found *ds.InstrumentCalibration
+	for _, calibration := range s.calibrations {
+		if calibration.InstrumentID != instrumentID || calibration.ValidFrom.After(at) ||
+			(calibration.ValidTo != nil && !calibration.ValidTo.After(at)) {
+			continue
+		}
+		if found == nil || calibration.ValidFrom.After(found.ValidFrom) ||
+			(calibration.ValidFrom.Equal(found.ValidFrom) && calibration.ID > found.ID) {
+			found = &calibration
+		}
+	}
+	if found == nil {
+		return nil, repository.ErrNotFound
+	}
+	return found, nil
+}
+
+// Cubes
+
+func (s *Store) CreateCube(ctx context.Context, cube *ds.HyperspectralCube) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.analyses[cube.SpectrumAnalysisID]; !ok {
+		return repository.ErrNotFound
+	}
+	cube.ID = s.nextCubeID
+	s.nextCubeID++
+	cube.CreatedAt = time.Now()
+	s.cubes[cube.ID] = *cube
+	return nil
+}
+
+func (s *Store) GetCube(ctx context.Context, id uint) (*ds.HyperspectralCube, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cube, ok := s.cubes[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &cube, nil
+}
+
+func (s *Store) ListCubes(ctx context.Context, analysisID uuid.UUID) ([]ds.HyperspectralCube, error) {
+	return s.filterCubes(func(cube ds.HyperspectralCube) bool { return cube.SpectrumAnalysisID == analysisID }), nil
+}
+
+func (s *Store) ListPendingCubes(ctx context.Context) ([]ds.HyperspectralCube, error) {
+	return s.filterCubes(func(cube ds.HyperspectralCube) bool {
+		return cube.Status == ds.CubeQueued || cube.Status == ds.CubeRunning
+	}), nil
+}
+
+func (s *Store) filterCubes(keep func(ds.HyperspectralCube) bool) []ds.HyperspectralCube {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []ds.HyperspectralCube
+	for _, cube := range s.cubes {
+		if keep(cube) {
+			result = append(result, cube)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+func (s *Store) UpdateCube(ctx context.Context, cube *ds.HyperspectralCube) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.cubes[cube.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	cube.CreatedAt = existing.CreatedAt
+	s.cubes[cube.ID] = *cube
+	return nil
+}
+
+func (s *Store) ListAbundanceMaps(ctx context.Context, cubeID uint) ([]ds.AbundanceMap, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []ds.AbundanceMap
+	for _, m := range s.maps {
+		if m.CubeID == cubeID {
+			result = append(result, m)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (s *Store) ReplaceAbundanceMaps(ctx context.Context, cubeID uint, maps []ds.AbundanceMap) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.cubes[cubeID]; !ok {
+		return repository.ErrNotFound
+	}
+	for id, m := range s.maps {
+		if m.CubeID == cubeID {
+			delete(s.maps, id)
+		}
+	}
+	for i := range maps {
+		maps[i].ID = s.nextMapID
+		s.nextMapID++
+		maps[i].CubeID = cubeID
+		s.maps[maps[i].ID] = maps[i]
+	}
+	return nil
+}
+
+// Users
+
+func (s *Store) GetUser(ctx context.Context, id uint) (*ds.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &user, nil
+}
+
+func (s *Store) GetUserByLogin(ctx context.Context, login string) (*ds.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Login == login {
+			return &user, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (s *Store) CreateUser(ctx context.Context, user *ds.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.loginTaken(user.Login, 0) {
+		return repository.ErrAlreadyExists
+	}
+	user.ID = s.nextUserID
+	s.nextUserID++
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (s *Store) UpdateUser(ctx context.Context, user *ds.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[user.ID]; !ok {
+		return repository.ErrNotFound
+	}
+	if s.loginTaken(user.Login, user.ID) {
+		return repository.ErrAlreadyExists
+	}
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (s *Store) loginTaken(login string, exceptID uint) bool {
+	for _, user := range s.users {
+		if user.Login == login && user.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(value, substr string) bool {
+	return strings.Contains(strings.ToLower(value), strings.ToLower(substr))
+}
+
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
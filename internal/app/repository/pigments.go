@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+
+	"colorLex/internal/app/ds"
+)
+
+func (r *Repository) ListPigments(ctx context.Context, query PigmentQuery) ([]ds.Pigment, error) {
+	db := r.db.WithContext(ctx)
+
+	if query.IncludeArchived {
+		db = db.Unscoped()
+	}
+	if query.IDs != nil {
+		db = db.Where("id IN ?", query.IDs)
+	}
+	if query.Search != "" {
+		db = db.Where("name ILIKE ?", "%"+query.Search+"%")
+	}
+	if query.Color != "" {
+		db = db.Where("color ILIKE ?", "%"+query.Color+"%")
+	}
+	if query.CIName != "" {
+		db = db.Where("lower(ci_name) = lower(?)", query.CIName)
+	}
+	if query.CASNumber != "" {
+		db = db.Where("cas_number = ?", query.CASNumber)
+	}
+	if query.Formula != "" {
+		db = db.Where("formula ILIKE ?", "%"+query.Formula+"%")
+	}
+	if query.Class != "" {
+		db = db.Where("class = ?", query.Class)
+	}
+	if query.Toxicity != "" {
+		db = db.Where("toxicity = ?", query.Toxicity)
+	}
+	if query.MaxLightfastness > 0 {
+		db = db.Where("lightfastness <= ?", query.MaxLightfastness)
+	}
+	if query.AvailableIn != nil {
+		db = db.Where("(available_from IS NULL OR available_from <= ?) AND (available_to IS NULL OR available_to >= ?)",
+			*query.AvailableIn, *query.AvailableIn)
+	}
+	if query.CreatedFrom != nil {
+		db = db.Where("created_at >= ?", *query.CreatedFrom)
+	}
+	if query.CreatedTo != nil {
+		db = db.Where("created_at < ?", *query.CreatedTo)
+	}
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+
+	var pigments []ds.Pigment
+	err := db.Offset(query.Offset).Order("id").Find(&pigments).Error
+	return pigments, translateError(err)
+}
+
+func (r *Repository) GetPigment(ctx context.Context, id uint, withArchived bool) (*ds.Pigment, error) {
+	db := r.db.WithContext(ctx)
+	if withArchived {
+		db = db.Unscoped()
+	}
+
+	var pigment ds.Pigment
+	if err := db.First(&pigment, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &pigment, nil
+}
+
+func (r *Repository) CreatePigment(ctx context.Context, pigment *ds.Pigment) error {
+	return translateError(r.db.WithContext(ctx).Create(pigment).Error)
+}
+
+func (r *Repository) UpdatePigment(ctx context.Context, pigment *ds.Pigment) error {
+	result := r.db.WithContext(ctx).Select("*").Omit("created_at", "deleted_at").Updates(pigment)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repository) ArchivePigment(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&ds.Pigment{}, id)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repository) RestorePigment(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Unscoped().Model(&ds.Pigment{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+
+	"colorLex/internal/app/ds"
+
+	"gorm.io/gorm"
+)
+
+func (r *Repository) ListRamanBands(ctx context.Context, pigmentID uint) ([]ds.RamanBand, error) {
+	db := r.db.WithContext(ctx)
+	if pigmentID != 0 {
+		db = db.Where("pigment_id = ?", pigmentID)
+	} else {
+		db = db.Where("pigment_id IN (SELECT id FROM pigments WHERE deleted_at IS NULL)")
+	}
+
+	var bands []ds.RamanBand
+	err := db.Order("pigment_id, position").Find(&bands).Error
+	return bands, translateError(err)
+}
+
+func (r *Repository) ReplaceRamanBands(ctx context.Context, pigmentID uint, bands []ds.RamanBand) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("pigment_id = ?", pigmentID).Delete(&ds.RamanBand{}).Error; err != nil {
+			return err
+		}
+		if len(bands) == 0 {
+			return nil
+		}
+		for i := range bands {
+			bands[i].ID = 0
+			bands[i].PigmentID = pigmentID
+		}
+		return tx.Create(&bands).Error
+	})
+	return translateError(err)
+}
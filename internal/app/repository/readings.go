@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+
+	"colorLex/internal/app/ds"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func (r *Repository) ListReadings(ctx context.Context, analysisID uuid.UUID) ([]ds.SpectrumReading, error) {
+	var readings []ds.SpectrumReading
+	err := r.db.WithContext(ctx).
+		Where("spectrum_analysis_id = ?", analysisID).
+		Order("id").
+		Find(&readings).Error
+	return readings, translateError(err)
+}
+
+func (r *Repository) AddReading(ctx context.Context, analysis *ds.SpectrumAnalysis, reading *ds.SpectrumReading) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockAnalysis(tx, analysis); err != nil {
+			return err
+		}
+		reading.SpectrumAnalysisID = analysis.ID
+		if err := tx.Create(reading).Error; err != nil {
+			return err
+		}
+		return bumpVersion(tx, analysis)
+	})
+	return translateError(err)
+}
+
+func (r *Repository) RemoveReading(ctx context.Context, analysis *ds.SpectrumAnalysis, readingID uint) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockAnalysis(tx, analysis); err != nil {
+			return err
+		}
+		result := tx.Where("id = ? AND spectrum_analysis_id = ?", readingID, analysis.ID).Delete(&ds.SpectrumReading{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return bumpVersion(tx, analysis)
+	})
+	return translateError(err)
+}
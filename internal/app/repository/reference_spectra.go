@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+
+	"colorLex/internal/app/ds"
+)
+
+func (r *Repository) ListReferenceSpectra(ctx context.Context, pigmentID uint, technique string) ([]ds.ReferenceSpectrum, error) {
+	db := r.db.WithContext(ctx)
+	if pigmentID != 0 {
+		db = db.Where("pigment_id = ?", pigmentID)
+	} else {
+		db = db.Where("pigment_id IN (SELECT id FROM pigments WHERE deleted_at IS NULL)")
+	}
+	if technique != "" {
+		db = db.Where("technique = ?", technique)
+	}
+
+	var references []ds.ReferenceSpectrum
+	err := db.Order("id").Find(&references).Error
+	return references, translateError(err)
+}
+
+func (r *Repository) ListReferenceFeatures(ctx context.Context, technique string) ([]ds.ReferenceSpectrum, error) {
+	var references []ds.ReferenceSpectrum
+	err := r.db.WithContext(ctx).
+		Select("id", "pigment_id", "technique", "features").
+		Where("technique = ? AND features IS NOT NULL", technique).
+		Order("id").
+		Find(&references).Error
+	return references, translateError(err)
+}
+
+func (r *Repository) ListReferenceIDs(ctx context.Context, technique string) ([]uint, error) {
+	var ids []uint
+	err := r.db.WithContext(ctx).Model(&ds.ReferenceSpectrum{}).
+		Where("technique = ? AND features IS NOT NULL", technique).
+		Order("id").
+		Pluck("id", &ids).Error
+	return ids, translateError(err)
+}
+
+func (r *Repository) GetReferenceSpectra(ctx context.Context, ids []uint) ([]ds.ReferenceSpectrum, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var references []ds.ReferenceSpectrum
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Order("id").Find(&references).Error
+	return references, translateError(err)
+}
+
+func (r *Repository) AddReferenceSpectrum(ctx context.Context, reference *ds.ReferenceSpectrum) error {
+	return translateError(r.db.WithContext(ctx).Create(reference).Error)
+}
+
+func (r *Repository) DeleteReferenceSpectrum(ctx context.Context, pigmentID, id uint) error {
+	result := r.db.WithContext(ctx).Where("id = ? AND pigment_id = ?", id, pigmentID).Delete(&ds.ReferenceSpectrum{})
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
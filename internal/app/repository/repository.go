@@ -1,22 +1,67 @@
 package repository
 
 import (
-    "gorm.io/driver/postgres"
-    "gorm.io/gorm"
+	"context"
+	"errors"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound - запись не найдена
+	ErrNotFound = errors.New("record not found")
+	// ErrAlreadyExists - нарушено ограничение уникальности
+	ErrAlreadyExists = errors.New("record already exists")
+	// ErrVersionConflict - запись изменилась с момента чтения (оптимистичная блокировка)
+	ErrVersionConflict = errors.New("record version conflict")
 )
 
+// Repository - реализация всех хранилищ поверх Postgres
 type Repository struct {
-    db *gorm.DB
+	db *gorm.DB
 }
 
+var (
+	_ PigmentStore  = (*Repository)(nil)
+	_ AnalysisStore = (*Repository)(nil)
+	_ ArtworkStore  = (*Repository)(nil)
+	_ UserStore     = (*Repository)(nil)
+)
+
 func New(dsn string) (*Repository, error) {
-    db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-    if err != nil {
-        return nil, err
-    }
-    return &Repository{db: db}, nil
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, err
+	}
+	return &Repository{db: db}, nil
 }
 
-func (r *Repository) GetDB() *gorm.DB {
-    return r.db
-}
\ No newline at end of file
+// translateError приводит ошибки gorm к ошибкам пакета
+func translateError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrAlreadyExists
+	default:
+		return err
+	}
+}
+
+func (r *Repository) InTransaction(ctx context.Context, fn func(store PigmentStore) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&Repository{db: tx})
+	})
+}
+
+// Close закрывает пул соединений
+func (r *Repository) Close() error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
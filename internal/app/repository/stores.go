@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"colorLex/internal/app/ds"
+
+	"github.com/google/uuid"
+)
+
+// PigmentQuery - параметры выборки каталога пигментов
+type PigmentQuery struct {
+	IDs             []uint // не nil - только пигменты с этими ID
+	Search          string
+	Color           string
+	CreatedFrom     *time.Time
+	CreatedTo       *time.Time // не включительно
+	IncludeArchived bool
+	CIName          string // точное совпадение без учёта регистра
+	CASNumber       string
+	Formula         string // подстрока
+	Class           string
+	Toxicity        string
+	// MaxLightfastness > 0 оставляет пигменты с известной светостойкостью не хуже указанной
+	MaxLightfastness int
+	// AvailableIn оставляет пигменты, доступные в указанном году; неизвестные границы не ограничивают
+	AvailableIn *int
+	Limit       int
+	Offset      int
+}
+
+// AnalysisQuery - параметры выборки сформированных заявок
+type AnalysisQuery struct {
+	CreatorID  *uint // nil - заявки всех пользователей
+	ArtworkID  *uint
+	Status     string
+	FormedFrom time.Time
+	FormedTo   time.Time
+	Limit      int
+	Offset     int
+}
+
+// ArtworkQuery - параметры выборки произведений
+type ArtworkQuery struct {
+	Search     string // подстрока в названии или имени автора
+	Collection string // подстрока
+	Limit      int
+	Offset     int
+}
+
+// ArtworkPigmentStats - сводка по пигменту во всех завершённых анализах произведения.
+// Анализы, где пигмент не найден, в статистику не входят
+type ArtworkPigmentStats struct {
+	Pigment     ds.Pigment
+	Analyses    int // в скольких завершённых анализах найден пигмент
+	MinPercent  float64
+	MaxPercent  float64
+	MeanPercent float64
+}
+
+// ArtworkPoint - точка отбора пробы на произведении с её заявкой и преобладающим пигментом
+type ArtworkPoint struct {
+	Point    ds.MeasurementPoint
+	Analysis ds.SpectrumAnalysis
+	// Dominant - пигмент с наибольшим процентом; nil, пока проценты не рассчитаны
+	Dominant *AnalysisPigment
+}
+
+// AnalysisPigment - пигмент заявки вместе с данными связи
+type AnalysisPigment struct {
+	Link    ds.SpectrumAnalysisPigment
+	Pigment ds.Pigment
+}
+
+// Composition - завершённая заявка с пигментами, доли которых больше нуля
+type Composition struct {
+	Analysis ds.SpectrumAnalysis
+	Artwork  *ds.Artwork // nil - заявка не привязана к произведению
+	Pigments []AnalysisPigment
+}
+
+// LayerPigment - пигмент слоя вместе с его долей
+type LayerPigment struct {
+	Link    ds.LayerPigment
+	Pigment ds.Pigment
+}
+
+// AnalysisLayer - слой стратиграфии заявки с пигментами. При записи Pigment не используется
+type AnalysisLayer struct {
+	Layer    ds.AnalysisLayer
+	Pigments []LayerPigment
+}
+
+// PigmentInteraction - правило взаимодействия вместе с его пигментами
+type PigmentInteraction struct {
+	Rule    ds.PigmentInteraction
+	Pigment ds.Pigment
+	Other   *ds.Pigment // nil - правило деградации одного пигмента
+}
+
+// PigmentResult - то, что завершение заявки записывает в связь с пигментом
+type PigmentResult struct {
+	Percent     float64
+	PercentLow  *float64
+	PercentHigh *float64
+	// Indistinguishable - ID неотличимых пигментов через запятую
+	Indistinguishable string
+	Verdict           string // пусто, если модератор не выносил решения
+	VerdictNote       string
+	RamanScore        *float64
+}
+
+// PigmentStore - каталог пигментов
+type PigmentStore interface {
+	ListPigments(ctx context.Context, query PigmentQuery) ([]ds.Pigment, error)
+	// GetPigment с withArchived=true находит и пигменты из архива
+	GetPigment(ctx context.Context, id uint, withArchived bool) (*ds.Pigment, error)
+	CreatePigment(ctx context.Context, pigment *ds.Pigment) error
+	UpdatePigment(ctx context.Context, pigment *ds.Pigment) error
+	ArchivePigment(ctx context.Context, id uint) error
+	RestorePigment(ctx context.Context, id uint) error
+
+	// ListReferenceSpectra возвращает эталонные спектры по возрастанию ID. pigmentID = 0 -
+	// эталоны всех неархивных пигментов, пустая technique - всех методик
+	ListReferenceSpectra(ctx context.Context, pigmentID uint, technique string) ([]ds.ReferenceSpectrum, error)
+	// ListReferenceFeatures возвращает ID, пигмент и признаки эталонов методики, у которых
+	// признаки посчитаны, по возрастанию ID. Спектр не загружается; архивные пигменты тоже
+	ListReferenceFeatures(ctx context.Context, technique string) ([]ds.ReferenceSpectrum, error)
+	// ListReferenceIDs возвращает по возрастанию ID эталонов методики с посчитанными признаками
+	ListReferenceIDs(ctx context.Context, technique string) ([]uint, error)
+	// GetReferenceSpectra возвращает эталоны с данными ID по возрастанию ID; ненайденные пропускаются
+	GetReferenceSpectra(ctx context.Context, ids []uint) ([]ds.ReferenceSpectrum, error)
+	AddReferenceSpectrum(ctx context.Context, reference *ds.ReferenceSpectrum) error
+	// DeleteReferenceSpectrum возвращает ErrNotFound, если у пигмента нет такого эталона
+	DeleteReferenceSpectrum(ctx context.Context, pigmentID, id uint) error
+
+	// ListRamanBands возвращает рамановские полосы по пигменту и положению. pigmentID = 0 -
+	// полосы всех неархивных пигментов
+	ListRamanBands(ctx context.Context, pigmentID uint) ([]ds.RamanBand, error)
+	// ReplaceRamanBands заменяет библиотеку полос пигмента целиком
+	ReplaceRamanBands(ctx context.Context, pigmentID uint, bands []ds.RamanBand) error
+
+	// ListInteractions возвращает правила взаимодействий по возрастанию ID вместе с пигментами,
+	// архивные тоже. pigmentID = 0 - все правила, иначе - правила с участием пигмента
+	ListInteractions(ctx context.Context, pigmentID uint) ([]PigmentInteraction, error)
+	GetInteraction(ctx context.Context, id uint) (*PigmentInteraction, error)
+	// CreateInteraction возвращает ErrAlreadyExists, если правило для пары
+	// (или деградации пигмента) уже есть
+	CreateInteraction(ctx context.Context, rule *ds.PigmentInteraction) error
+	// UpdateInteraction меняет серьёзность, объяснение и продукты; пигменты правила не меняются
+	UpdateInteraction(ctx context.Context, rule *ds.PigmentInteraction) error
+	DeleteInteraction(ctx context.Context, id uint) error
+
+	// InTransaction выполняет fn в одной транзакции: при ошибке fn ни одно изменение,
+	// сделанное через переданное fn хранилище, не сохраняется
+	InTransaction(ctx context.Context, fn func(store PigmentStore) error) error
+}
+
+// AnalysisStore - заявки на спектральный анализ и их пигменты
+type AnalysisStore interface {
+	FindDraft(ctx context.Context, creatorID uint) (*ds.SpectrumAnalysis, error)
+	// AddPigmentToDraft находит или создаёт черновик пользователя и добавляет в него пигмент.
+	// Возвращает черновик и количество пигментов в нём, ErrAlreadyExists - если пигмент уже там.
+	AddPigmentToDraft(ctx context.Context, creatorID, pigmentID uint) (*ds.SpectrumAnalysis, int64, error)
+	CountAnalysisPigments(ctx context.Context, analysisID uuid.UUID) (int64, error)
+
+	ListAnalyses(ctx context.Context, query AnalysisQuery) ([]ds.SpectrumAnalysis, error)
+	GetAnalysis(ctx context.Context, id uuid.UUID) (*ds.SpectrumAnalysis, error)
+
+	// Все изменяющие заявку методы сверяют analysis.Version с версией в БД:
+	// при расхождении возвращают ErrVersionConflict, при успехе увеличивают analysis.Version.
+
+	UpdateAnalysis(ctx context.Context, analysis *ds.SpectrumAnalysis) error
+	// CompleteAnalysis в одной транзакции сохраняет итоговый статус заявки и результаты по пигментам
+	CompleteAnalysis(ctx context.Context, analysis *ds.SpectrumAnalysis, results map[uint]PigmentResult) error
+	UpdateAnalysisPigment(ctx context.Context, analysis *ds.SpectrumAnalysis, link *ds.SpectrumAnalysisPigment) error
+	RemoveAnalysisPigment(ctx context.Context, analysis *ds.SpectrumAnalysis, pigmentID uint) error
+
+	// ListAnalysisPigments возвращает пигменты заявки, включая архивные
+	ListAnalysisPigments(ctx context.Context, analysisID uuid.UUID) ([]AnalysisPigment, error)
+	GetAnalysisPigment(ctx context.Context, analysisID uuid.UUID, pigmentID uint) (*ds.SpectrumAnalysisPigment, error)
+
+	GetMeasurementPoint(ctx context.Context, analysisID uuid.UUID) (*ds.MeasurementPoint, error)
+	// SaveMeasurementPoint создаёт или заменяет точку отбора пробы заявки
+	SaveMeasurementPoint(ctx context.Context, analysis *ds.SpectrumAnalysis, point *ds.MeasurementPoint) error
+	DeleteMeasurementPoint(ctx context.Context, analysis *ds.SpectrumAnalysis) error
+
+	// ListCompositions возвращает составы всех завершённых заявок; заявки без долей пропускаются.
+	// Архивные пигменты тоже возвращаются. Заполнены только поля, по которым сравниваются
+	// составы: у заявки - ID, название, статус, автор, дата завершения и произведение, у
+	// произведения - ID и год, у пигмента - ID, название, цвет и архивность, у связи - доля
+	ListCompositions(ctx context.Context) ([]Composition, error)
+
+	// ListLayers возвращает слои заявки снизу вверх, пигменты слоя - по убыванию доли.
+	// Архивные пигменты тоже возвращаются
+	ListLayers(ctx context.Context, analysisID uuid.UUID) ([]AnalysisLayer, error)
+	// ReplaceLayers заменяет стратиграфию заявки целиком; порядок layers - снизу вверх,
+	// Position проставляется по нему. Пустой список удаляет стратиграфию
+	ReplaceLayers(ctx context.Context, analysis *ds.SpectrumAnalysis, layers []AnalysisLayer) error
+
+	// ListReadings возвращает повторные измерения спектра в порядке добавления
+	ListReadings(ctx context.Context, analysisID uuid.UUID) ([]ds.SpectrumReading, error)
+	AddReading(ctx context.Context, analysis *ds.SpectrumAnalysis, reading *ds.SpectrumReading) error
+	RemoveReading(ctx context.Context, analysis *ds.SpectrumAnalysis, readingID uint) error
+}
+
+// ArtworkStore - произведения, к которым привязываются анализы
+type ArtworkStore interface {
+	ListArtworks(ctx context.Context, query ArtworkQuery) ([]ds.Artwork, error)
+	GetArtwork(ctx context.Context, id uint) (*ds.Artwork, error)
+	// CreateArtwork и UpdateArtwork возвращают ErrAlreadyExists, если инвентарный номер занят в собрании
+	CreateArtwork(ctx context.Context, artwork *ds.Artwork) error
+	UpdateArtwork(ctx context.Context, artwork *ds.Artwork) error
+	// DeleteArtwork удаляет произведение и отвязывает от него анализы
+	DeleteArtwork(ctx context.Context, id uint) error
+
+	// ArtworkPigmentStats агрегирует проценты пигментов по завершённым анализам произведения,
+	// по убыванию среднего процента
+	ArtworkPigmentStats(ctx context.Context, artworkID uint) ([]ArtworkPigmentStats, error)
+	// CountArtworkAnalyses считает анализы произведения в указанном статусе
+	CountArtworkAnalyses(ctx context.Context, artworkID uint, status string) (int64, error)
+	// ListArtworkPoints возвращает точки отбора проб неудалённых заявок, привязанных к произведению
+	ListArtworkPoints(ctx context.Context, artworkID uint) ([]ArtworkPoint, error)
+}
+
+// InstrumentStore - спектрометры и их калибровки
+type InstrumentStore interface {
+	ListInstruments(ctx context.Context) ([]ds.Instrument, error)
+	GetInstrument(ctx context.Context, id uint) (*ds.Instrument, error)
+	// CreateInstrument и UpdateInstrument возвращают ErrAlreadyExists, если серийный номер занят
+	CreateInstrument(ctx context.Context, instrument *ds.Instrument) error
+	UpdateInstrument(ctx context.Context, instrument *ds.Instrument) error
+
+	// ListCalibrations возвращает калибровки прибора по началу действия
+	ListCalibrations(ctx context.Context, instrumentID uint) ([]ds.InstrumentCalibration, error)
+	AddCalibration(ctx context.Context, calibration *ds.InstrumentCalibration) error
+	// FindCalibration возвращает калибровку, действовавшую в момент at; если их несколько -
+	// начатую последней. ErrNotFound - прибор в этот момент не был откалиброван
+	FindCalibration(ctx context.Context, instrumentID uint, at time.Time) (*ds.InstrumentCalibration, error)
+}
+
+// CubeStore - гиперспектральные кубы заявок и их карты долей пигментов
+type CubeStore interface {
+	CreateCube(ctx context.Context, cube *ds.HyperspectralCube) error
+	GetCube(ctx context.Context, id uint) (*ds.HyperspectralCube, error)
+	// ListCubes возвращает кубы заявки в порядке загрузки
+	ListCubes(ctx context.Context, analysisID uuid.UUID) ([]ds.HyperspectralCube, error)
+	// ListPendingCubes возвращает ждущие и обрабатываемые кубы всех заявок в порядке загрузки
+	ListPendingCubes(ctx context.Context) ([]ds.HyperspectralCube, error)
+	UpdateCube(ctx context.Context, cube *ds.HyperspectralCube) error
+
+	ListAbundanceMaps(ctx context.Context, cubeID uint) ([]ds.AbundanceMap, error)
+	// ReplaceAbundanceMaps заменяет карты куба целиком
+	ReplaceAbundanceMaps(ctx context.Context, cubeID uint, maps []ds.AbundanceMap) error
+}
+
+// UserStore - пользователи
+type UserStore interface {
+	GetUser(ctx context.Context, id uint) (*ds.User, error)
+	GetUserByLogin(ctx context.Context, login string) (*ds.User, error)
+	// CreateUser возвращает ErrAlreadyExists, если логин занят
+	CreateUser(ctx context.Context, user *ds.User) error
+	UpdateUser(ctx context.Context, user *ds.User) error
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+
+	"colorLex/internal/app/ds"
+)
+
+func (r *Repository) GetUser(ctx context.Context, id uint) (*ds.User, error) {
+	var user ds.User
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *Repository) GetUserByLogin(ctx context.Context, login string) (*ds.User, error) {
+	var user ds.User
+	if err := r.db.WithContext(ctx).Where("login = ?", login).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *Repository) CreateUser(ctx context.Context, user *ds.User) error {
+	return translateError(r.db.WithContext(ctx).Create(user).Error)
+}
+
+func (r *Repository) UpdateUser(ctx context.Context, user *ds.User) error {
+	result := r.db.WithContext(ctx).Select("*").Updates(user)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
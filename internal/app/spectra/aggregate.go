@@ -0,0 +1,218 @@
+package spectra
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Методы отбраковки повторных измерений
+const (
+	// OutlierNone - используются все измерения
+	OutlierNone = "none"
+	// OutlierMAD - отбраковка по робастному z-критерию: отклонение измерения от
+	// медианного спектра сравнивается с медианным абсолютным отклонением по всем измерениям
+	OutlierMAD = "mad"
+	// OutlierRMSD - отбраковка измерений, среднеквадратичное отклонение которых
+	// от медианного спектра больше порога (в единицах спектра)
+	OutlierRMSD = "rmsd"
+)
+
+var OutlierMethods = []string{OutlierNone, OutlierMAD, OutlierRMSD}
+
+// OutlierRule - правило отбраковки повторных измерений
+type OutlierRule struct {
+	Method    string
+	Threshold float64
+}
+
+// DefaultOutlierRule - порог 3.5 для робастного z-критерия рекомендуют Iglewicz и Hoaglin
+var DefaultOutlierRule = OutlierRule{Method: OutlierMAD, Threshold: 3.5}
+
+// madScale переводит MAD в оценку стандартного отклонения для нормального распределения
+const madScale = 0.6745
+
+// Validate проверяет метод и порог правила
+func (r OutlierRule) Validate() error {
+	switch r.Method {
+	case OutlierNone:
+		return nil
+	case OutlierMAD, OutlierRMSD:
+		if r.Threshold <= 0 || math.IsNaN(r.Threshold) || math.IsInf(r.Threshold, 0) {
+			return fmt.Errorf("threshold must be positive, got %g", r.Threshold)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown outlier method %q", r.Method)
+	}
+}
+
+// Aggregate - усреднённый спектр повторных измерений
+type Aggregate struct {
+	Mean Spectrum
+	// Std - выборочное стандартное отклонение на каждой длине волны Mean
+	// по оставшимся измерениям; нули, если измерение одно
+	Std []float64
+	// Deviations - среднеквадратичное отклонение каждого измерения от медианного спектра
+	Deviations []float64
+	// Rejected[i] - измерение i отбраковано
+	Rejected []bool
+	Used     int
+}
+
+// Combine переносит измерения на общую сетку, отбраковывает выбросы по правилу
+// и усредняет оставшиеся. Сетка - длины волн первого измерения в пересечении
+// диапазонов всех измерений.
+//
+// Отбраковка не применяется к одному-двум измерениям (нельзя понять, какое из
+// двух ошибочное) и никогда не оставляет меньше половины измерений: если правило
+// отбраковывает больше, возвращаются наименее отклонившиеся из них
+func Combine(readings []Spectrum, rule OutlierRule) (Aggregate, error) {
+	if len(readings) == 0 {
+		return Aggregate{}, ErrEmpty
+	}
+	if err := rule.Validate(); err != nil {
+		return Aggregate{}, err
+	}
+
+	grid, err := commonGrid(readings)
+	if err != nil {
+		return Aggregate{}, err
+	}
+	resampled := make([]Spectrum, len(readings))
+	for i, reading := range readings {
+		if resampled[i], err = reading.Resample(grid); err != nil {
+			return Aggregate{}, err
+		}
+	}
+
+	// Медианный спектр устойчив к выбросам, поэтому отклонения считаем от него
+	median := make([]float64, len(grid))
+	column := make([]float64, len(readings))
+	for j := range grid {
+		for i := range resampled {
+			column[i] = resampled[i][j].Value
+		}
+		median[j] = medianOf(column)
+	}
+	deviations := make([]float64, len(readings))
+	for i, reading := range resampled {
+		var sum float64
+		for j, p := range reading {
+			d := p.Value - median[j]
+			sum += d * d
+		}
+		deviations[i] = math.Sqrt(sum / float64(len(grid)))
+	}
+
+	rejected := rejectOutliers(deviations, rule)
+
+	result := Aggregate{
+		Mean:       make(Spectrum, len(grid)),
+		Std:        make([]float64, len(grid)),
+		Deviations: deviations,
+		Rejected:   rejected,
+	}
+	for _, r := range rejected {
+		if !r {
+			result.Used++
+		}
+	}
+	for j, wavelength := range grid {
+		var sum float64
+		for i := range resampled {
+			if !rejected[i] {
+				sum += resampled[i][j].Value
+			}
+		}
+		mean := sum / float64(result.Used)
+
+		var squares float64
+		for i := range resampled {
+			if !rejected[i] {
+				d := resampled[i][j].Value - mean
+				squares += d * d
+			}
+		}
+		result.Mean[j] = Point{Wavelength: wavelength, Value: mean}
+		if result.Used > 1 {
+			result.Std[j] = math.Sqrt(squares / float64(result.Used-1))
+		}
+	}
+	return result, nil
+}
+
+// commonGrid - длины волн первого измерения в пересечении диапазонов всех измерений
+func commonGrid(readings []Spectrum) ([]float64, error) {
+	from, to := math.Inf(-1), math.Inf(1)
+	for _, reading := range readings {
+		if len(reading) == 0 {
+			return nil, ErrEmpty
+		}
+		lo, hi := reading.Range()
+		from, to = math.Max(from, lo), math.Min(to, hi)
+	}
+
+	var grid []float64
+	for _, p := range readings[0] {
+		if p.Wavelength >= from && p.Wavelength <= to {
+			grid = append(grid, p.Wavelength)
+		}
+	}
+	if len(grid) == 0 {
+		return nil, ErrNoOverlap
+	}
+	return grid, nil
+}
+
+func rejectOutliers(deviations []float64, rule OutlierRule) []bool {
+	rejected := make([]bool, len(deviations))
+	if rule.Method == OutlierNone || len(deviations) < 3 {
+		return rejected
+	}
+
+	switch rule.Method {
+	case OutlierMAD:
+		center := medianOf(deviations)
+		absolute := make([]float64, len(deviations))
+		for i, d := range deviations {
+			absolute[i] = math.Abs(d - center)
+		}
+		mad := medianOf(absolute)
+		if mad == 0 {
+			// Больше половины измерений совпадают - оценить разброс нечем
+			return rejected
+		}
+		for i, d := range deviations {
+			rejected[i] = madScale*(d-center)/mad > rule.Threshold
+		}
+	case OutlierRMSD:
+		for i, d := range deviations {
+			rejected[i] = d > rule.Threshold
+		}
+	}
+
+	// Оставляем не меньше половины измерений, возвращая наименее отклонившиеся
+	order := make([]int, 0, len(deviations))
+	for i := range deviations {
+		if rejected[i] {
+			order = append(order, i)
+		}
+	}
+	sort.Slice(order, func(a, b int) bool { return deviations[order[a]] < deviations[order[b]] })
+	maxRejected := len(deviations) / 2
+	for _, i := range order[:max(0, len(order)-maxRejected)] {
+		rejected[i] = false
+	}
+	return rejected
+}
+
+func medianOf(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,116 @@
+package spectra
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCalibrationRange - спектр выходит за диапазон калибровочных спектров
+var ErrCalibrationRange = errors.New("spectrum is outside the calibration range")
+
+// Calibration - поправки прибора, действующие на момент измерения. Сырые отсчёты
+// переводятся в относительные значения (S - D) / (W - D) · k, затем ось
+// исправляется по линиям эталонной лампы
+type Calibration struct {
+	Dark  Spectrum // темновой сигнал; nil - не вычитается
+	White Spectrum // сигнал белого эталона; nil - отсчёты только очищаются от темнового сигнала
+	// WhiteReflectance - коэффициент отражения белого эталона k; 0 - считается равным 1
+	WhiteReflectance float64
+	// Wavelengths - пары "номинальное положение:истинное" по эталонным линиям;
+	// nil - ось не исправляется. Между линиями сдвиг интерполируется, за крайними не меняется
+	Wavelengths Spectrum
+}
+
+// Apply исправляет сырой спектр прибора. Темновой и белый спектры должны покрывать
+// его диапазон, а сигнал белого эталона - превышать темновой
+func (c Calibration) Apply(raw Spectrum) (Spectrum, error) {
+	if len(raw) == 0 {
+		return nil, ErrEmpty
+	}
+	k := c.WhiteReflectance
+	if k == 0 {
+		k = 1
+	}
+
+	result := make(Spectrum, len(raw))
+	for i, p := range raw {
+		value := p.Value
+		dark := 0.0
+		if c.Dark != nil {
+			var ok bool
+			if dark, ok = c.Dark.At(p.Wavelength); !ok {
+				return nil, fmt.Errorf("dark at %g: %w", p.Wavelength, ErrCalibrationRange)
+			}
+			value -= dark
+		}
+		if c.White != nil {
+			white, ok := c.White.At(p.Wavelength)
+			if !ok {
+				return nil, fmt.Errorf("white at %g: %w", p.Wavelength, ErrCalibrationRange)
+			}
+			if white-dark <= 0 {
+				return nil, fmt.Errorf("white reference does not exceed dark signal at %g", p.Wavelength)
+			}
+			value = value / (white - dark) * k
+		}
+		result[i] = Point{Wavelength: c.correctAxis(p.Wavelength), Value: value}
+	}
+
+	for i := 1; i < len(result); i++ {
+		if result[i].Wavelength <= result[i-1].Wavelength {
+			return nil, fmt.Errorf("wavelength correction reorders points near %g", raw[i].Wavelength)
+		}
+	}
+	return result, nil
+}
+
+// correctAxis переносит номинальное положение на истинное
+func (c Calibration) correctAxis(nominal float64) float64 {
+	if len(c.Wavelengths) == 0 {
+		return nominal
+	}
+	first, last := c.Wavelengths[0], c.Wavelengths[len(c.Wavelengths)-1]
+	switch {
+	case nominal <= first.Wavelength:
+		return nominal + first.Value - first.Wavelength
+	case nominal >= last.Wavelength:
+		return nominal + last.Value - last.Wavelength
+	}
+	for i := 1; i < len(c.Wavelengths); i++ {
+		left, right := c.Wavelengths[i-1], c.Wavelengths[i]
+		if nominal <= right.Wavelength {
+			t := (nominal - left.Wavelength) / (right.Wavelength - left.Wavelength)
+			shift := (left.Value - left.Wavelength) + t*((right.Value-right.Wavelength)-(left.Value-left.Wavelength))
+			return nominal + shift
+		}
+	}
+	return nominal
+}
+
+// Validate проверяет калибровку без измерения: белый спектр должен превышать
+// темновой там, где оба заданы, а поправка оси - сохранять порядок точек
+func (c Calibration) Validate() error {
+	if c.WhiteReflectance < 0 || c.WhiteReflectance > 1 {
+		return fmt.Errorf("white reflectance %g is outside 0-1", c.WhiteReflectance)
+	}
+	if c.White != nil {
+		for _, p := range c.White {
+			dark := 0.0
+			if c.Dark != nil {
+				var ok bool
+				if dark, ok = c.Dark.At(p.Wavelength); !ok {
+					continue
+				}
+			}
+			if p.Value-dark <= 0 {
+				return fmt.Errorf("white reference does not exceed dark signal at %g", p.Wavelength)
+			}
+		}
+	}
+	for i := 1; i < len(c.Wavelengths); i++ {
+		if c.Wavelengths[i].Value <= c.Wavelengths[i-1].Value {
+			return fmt.Errorf("wavelength lines %g and %g are out of order", c.Wavelengths[i-1].Wavelength, c.Wavelengths[i].Wavelength)
+		}
+	}
+	return nil
+}
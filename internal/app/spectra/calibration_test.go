@@ -0,0 +1,65 @@
+package spectra
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestCalibrationApply(t *testing.T) {
+	dark := grid(400, 50, func(float64) float64 { return 100 }, 5)
+	white := grid(400, 50, func(x float64) float64 { return 1100 + x }, 5) // 1500..1700
+	raw := Spectrum{{400, 600}, {500, 900}, {600, 1200}}
+
+	corrected, err := Calibration{Dark: dark, White: white, WhiteReflectance: 0.99}.Apply(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, corrected, []float64{
+		500.0 / 1400 * 0.99,
+		800.0 / 1500 * 0.99,
+		1100.0 / 1600 * 0.99,
+	})
+
+	// Сдвиг оси: +2 нм у 400, -1 нм у 600, между линиями - линейно
+	lines := Spectrum{{400, 402}, {600, 599}}
+	shifted, err := Calibration{Wavelengths: lines}.Apply(Spectrum{{380, 1}, {500, 2}, {650, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []float64{382, 500.5, 649} {
+		if math.Abs(shifted[i].Wavelength-want) > 1e-9 {
+			t.Errorf("point %d at %g, want %g", i, shifted[i].Wavelength, want)
+		}
+	}
+	assertValues(t, shifted, []float64{1, 2, 3})
+
+	if _, err := (Calibration{Dark: dark}).Apply(Spectrum{{350, 1}}); !errors.Is(err, ErrCalibrationRange) {
+		t.Errorf("raw outside dark range: err = %v", err)
+	}
+	if _, err := (Calibration{Dark: white, White: dark}).Apply(raw); err == nil {
+		t.Error("white below dark accepted")
+	}
+}
+
+func TestCalibrationValidate(t *testing.T) {
+	dark := Spectrum{{400, 100}, {700, 100}}
+	tests := []struct {
+		name        string
+		calibration Calibration
+		wantErr     bool
+	}{
+		{"empty", Calibration{}, false},
+		{"white above dark", Calibration{Dark: dark, White: Spectrum{{400, 900}, {700, 1200}}, WhiteReflectance: 0.98}, false},
+		{"white below dark", Calibration{Dark: dark, White: Spectrum{{400, 900}, {700, 50}}}, true},
+		{"reflectance above 1", Calibration{WhiteReflectance: 1.2}, true},
+		{"lines out of order", Calibration{Wavelengths: Spectrum{{400, 405}, {401, 403}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.calibration.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
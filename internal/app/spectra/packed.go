@@ -0,0 +1,198 @@
+package spectra
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// Формат хранения спектра (Packed), все числа little-endian:
+//
+//	0   2 байта  "SP"
+//	2   uint8    версия формата (1)
+//	3   uint8    флаги: packedIrregular - длины волн не лежат на равномерной сетке
+//	4   uint32   число точек
+//	8   float64  первая длина волны
+//	16  float64  шаг сетки; 0 - одна точка или неравномерная сетка
+//	24  uint32   CRC-32 (IEEE) распакованных данных
+//	28  ...      данные, сжатые DEFLATE: для неравномерной сетки сначала длины волн
+//	             float64, затем значения float32
+//
+// Заголовок читается без распаковки, поэтому сетку и число точек можно узнать, не
+// трогая сами значения.
+const (
+	packedMagic      = "SP"
+	packedVersion    = 1
+	packedHeaderSize = 28
+
+	packedIrregular = 1
+
+	// maxPackedPoints защищает Decode от заголовка с огромным числом точек
+	maxPackedPoints = 1 << 24
+)
+
+// gridTolerance - относительное отклонение длины волны от узла сетки, при котором
+// спектр ещё считается снятым на равномерной сетке
+const gridTolerance = 1e-9
+
+// ErrCorrupt - данные не в формате Packed или не сходится контрольная сумма
+var ErrCorrupt = errors.New("packed spectrum is corrupt")
+
+// Packed - спектр в двоичном формате хранения: значения float32 на сетке длин волн,
+// сжатые, с контрольной суммой. Распаковывается только в Decode
+type Packed []byte
+
+// Header - заголовок упакованного спектра
+type Header struct {
+	Count    int
+	Start    float64
+	Step     float64 // 0 - одна точка или неравномерная сетка
+	Regular  bool    // false - длины волн хранятся в данных
+	Checksum uint32
+}
+
+// Pack упаковывает спектр. Значения сохраняются с точностью float32
+func Pack(s Spectrum) (Packed, error) {
+	if len(s) == 0 {
+		return nil, ErrEmpty
+	}
+
+	header := Header{Count: len(s), Start: s[0].Wavelength, Regular: true}
+	if len(s) > 1 {
+		header.Step = (s[len(s)-1].Wavelength - s[0].Wavelength) / float64(len(s)-1)
+		for i, p := range s {
+			node := header.Start + float64(i)*header.Step
+			if math.Abs(p.Wavelength-node) > gridTolerance*math.Max(1, math.Abs(p.Wavelength)) {
+				header.Regular = false
+				header.Step = 0
+				break
+			}
+		}
+	}
+
+	size := 4 * len(s)
+	if !header.Regular {
+		size += 8 * len(s)
+	}
+	data := make([]byte, 0, size)
+	if !header.Regular {
+		for _, p := range s {
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(p.Wavelength))
+		}
+	}
+	for _, p := range s {
+		if math.Abs(p.Value) > math.MaxFloat32 {
+			return nil, fmt.Errorf("value %g at %g does not fit float32", p.Value, p.Wavelength)
+		}
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(p.Value)))
+	}
+	header.Checksum = crc32.ChecksumIEEE(data)
+
+	var b bytes.Buffer
+	b.Grow(packedHeaderSize + size/2)
+	b.WriteString(packedMagic)
+	b.WriteByte(packedVersion)
+	if header.Regular {
+		b.WriteByte(0)
+	} else {
+		b.WriteByte(packedIrregular)
+	}
+	b.Write(binary.LittleEndian.AppendUint32(nil, uint32(header.Count)))
+	b.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(header.Start)))
+	b.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(header.Step)))
+	b.Write(binary.LittleEndian.AppendUint32(nil, header.Checksum))
+
+	w, err := flate.NewWriter(&b, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return Packed(b.Bytes()), nil
+}
+
+// Header читает заголовок без распаковки данных
+func (p Packed) Header() (Header, error) {
+	if len(p) < packedHeaderSize || string(p[:2]) != packedMagic || p[2] != packedVersion || p[3]&^packedIrregular != 0 {
+		return Header{}, ErrCorrupt
+	}
+	header := Header{
+		Count:    int(binary.LittleEndian.Uint32(p[4:8])),
+		Start:    math.Float64frombits(binary.LittleEndian.Uint64(p[8:16])),
+		Step:     math.Float64frombits(binary.LittleEndian.Uint64(p[16:24])),
+		Regular:  p[3]&packedIrregular == 0,
+		Checksum: binary.LittleEndian.Uint32(p[24:28]),
+	}
+	if header.Count == 0 || header.Count > maxPackedPoints {
+		return Header{}, ErrCorrupt
+	}
+	return header, nil
+}
+
+// Decode распаковывает спектр и проверяет контрольную сумму
+func (p Packed) Decode() (Spectrum, error) {
+	header, err := p.Header()
+	if err != nil {
+		return nil, err
+	}
+
+	size := 4 * header.Count
+	if !header.Regular {
+		size += 8 * header.Count
+	}
+	data := make([]byte, size)
+	r := flate.NewReader(bytes.NewReader(p[packedHeaderSize:]))
+	defer r.Close()
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, ErrCorrupt
+	}
+	// После значений поток должен закончиться: лишние или оборванные данные - порча
+	if n, err := r.Read(make([]byte, 1)); n != 0 || err != io.EOF || crc32.ChecksumIEEE(data) != header.Checksum {
+		return nil, ErrCorrupt
+	}
+
+	spectrum := make(Spectrum, header.Count)
+	values := data
+	if header.Regular {
+		for i := range spectrum {
+			spectrum[i].Wavelength = header.Start + float64(i)*header.Step
+		}
+	} else {
+		for i := range spectrum {
+			spectrum[i].Wavelength = math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:]))
+		}
+		values = data[8*header.Count:]
+	}
+	for i := range spectrum {
+		spectrum[i].Value = round32(math.Float32frombits(binary.LittleEndian.Uint32(values[4*i:])))
+	}
+	return spectrum, nil
+}
+
+// round32 оставляет у значения float32 семь значащих цифр - столько, сколько float32
+// хранит надёжно. Так 0.12 после упаковки остаётся 0.12, а не 0.11999999731779099
+func round32(v float32) float64 {
+	x := float64(v)
+	if x == 0 || math.IsInf(x, 0) || math.IsNaN(x) {
+		return x
+	}
+	digits := 6 - int(math.Floor(math.Log10(math.Abs(x))))
+	if digits >= 0 {
+		scale := math.Pow10(digits)
+		if math.IsInf(scale, 0) {
+			return x
+		}
+		return math.Round(x*scale) / scale
+	}
+	scale := math.Pow10(-digits)
+	return math.Round(x/scale) * scale
+}
@@ -0,0 +1,99 @@
+package spectra
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestPackRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		regular bool
+		step    float64
+	}{
+		{name: "regular grid", text: "400:0.12,410:0.15,420:0.2,430:0.35", regular: true, step: 10},
+		{name: "fractional step", text: "1.5:-3,2:7.25,2.5:1e-5", regular: true, step: 0.5},
+		{name: "single point", text: "548:100", regular: true},
+		{name: "irregular grid", text: "247:120,299:340,385:1000,480:210.5", regular: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spectrum, err := Parse(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			packed, err := Pack(spectrum)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			header, err := packed.Header()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header.Count != len(spectrum) || header.Start != spectrum[0].Wavelength || header.Regular != tt.regular || header.Step != tt.step {
+				t.Fatalf("unexpected header %+v", header)
+			}
+
+			decoded, err := packed.Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// точные в float32 значения и короткие десятичные дроби не меняются
+			if decoded.String() != spectrum.String() {
+				t.Fatalf("decoded %q, want %q", decoded.String(), spectrum.String())
+			}
+		})
+	}
+}
+
+func TestPackPrecision(t *testing.T) {
+	spectrum := Spectrum{{400, 0.123456789}, {500, 123456.789}, {600, 3e-12}}
+	packed, err := Pack(spectrum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := packed.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range decoded {
+		if want := spectrum[i].Value; math.Abs(p.Value-want) > 1e-6*math.Abs(want) {
+			t.Fatalf("value %d: got %g, want %g", i, p.Value, want)
+		}
+	}
+
+	if _, err := Pack(Spectrum{{400, 1e39}}); err == nil {
+		t.Fatal("values outside float32 must be rejected")
+	}
+	if _, err := Pack(nil); !errors.Is(err, ErrEmpty) {
+		t.Fatalf("Pack(nil) error = %v", err)
+	}
+}
+
+func TestPackedCorrupt(t *testing.T) {
+	spectrum, _ := Parse("400:0.1,500:0.2,600:0.3")
+	packed, err := Pack(spectrum)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checksum := append(Packed(nil), packed...)
+	checksum[24] ^= 0xff
+	truncated := packed[:len(packed)-2]
+	version := append(Packed(nil), packed...)
+	version[2] = 9
+
+	for name, p := range map[string]Packed{
+		"checksum":  checksum,
+		"truncated": truncated,
+		"version":   version,
+		"text":      Packed("400:0.1,500:0.2"),
+	} {
+		if _, err := p.Decode(); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: Decode error = %v, want ErrCorrupt", name, err)
+		}
+	}
+}
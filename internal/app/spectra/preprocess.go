@@ -0,0 +1,433 @@
+package spectra
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Операции предобработки
+const (
+	// OpResample - перенос на равномерную сетку с шагом Interval
+	OpResample = "resample"
+	// OpSmooth - сглаживание фильтром Савицкого–Голея. Требует равномерной сетки
+	OpSmooth = "smooth"
+	// OpBaseline - удаление базовой линии (Method: linear) или континуума (Method: hull)
+	OpBaseline = "baseline"
+	// OpNormalize - нормировка (Method: max, area, snv)
+	OpNormalize = "normalize"
+	// OpDerivative - первая или вторая производная по длине волны
+	OpDerivative = "derivative"
+)
+
+// Методы шагов baseline и normalize
+const (
+	// BaselineLinear - вычитается прямая через крайние точки спектра
+	BaselineLinear = "linear"
+	// BaselineHull - спектр делится на верхнюю выпуклую оболочку (continuum removal)
+	BaselineHull = "hull"
+	// BaselineRubberband - вычитается нижняя выпуклая оболочка: фон флуоресценции
+	// под рамановскими полосами, полосы остаются пиками над нулём
+	BaselineRubberband = "rubberband"
+
+	NormalizeMax  = "max"
+	NormalizeArea = "area"
+	// NormalizeSNV - standard normal variate: вычитается среднее, делится на стандартное отклонение
+	NormalizeSNV = "snv"
+)
+
+var Ops = []string{OpResample, OpSmooth, OpBaseline, OpNormalize, OpDerivative}
+
+// Ограничения на параметры, чтобы предпросмотр не превращался в тяжёлое вычисление
+const (
+	MaxSteps      = 10
+	MaxGridPoints = 20000
+	MaxWindow     = 101
+	MaxPolyOrder  = 6
+)
+
+// Step - один шаг предобработки. Используются только параметры своей операции.
+// Шаги сохраняются в заявке в JSON, поэтому теги - часть формата хранения
+type Step struct {
+	Op string `json:"op"`
+
+	// resample: сетка From, From+Interval, ... до To; по умолчанию - диапазон спектра
+	From     *float64 `json:"from,omitempty"`
+	To       *float64 `json:"to,omitempty"`
+	Interval float64  `json:"interval,omitempty"`
+
+	// smooth: нечётная ширина окна в точках и степень полинома
+	Window    int `json:"window,omitempty"`
+	PolyOrder int `json:"poly_order,omitempty"`
+
+	// baseline, normalize
+	Method string `json:"method,omitempty"`
+
+	// derivative: 1 или 2
+	Order int `json:"order,omitempty"`
+}
+
+// Pipeline - шаги предобработки в порядке применения
+type Pipeline []Step
+
+var errUneven = errors.New("grid is not evenly spaced, add a resample step first")
+
+// ParsePipeline разбирает шаги в формате хранения. Пустая строка - без предобработки
+func ParsePipeline(text string) (Pipeline, error) {
+	if text == "" {
+		return nil, nil
+	}
+	var pipeline Pipeline
+	if err := json.Unmarshal([]byte(text), &pipeline); err != nil {
+		return nil, err
+	}
+	return pipeline, pipeline.Validate()
+}
+
+// String возвращает шаги в формате хранения; пустая строка для пустого списка
+func (p Pipeline) String() string {
+	if len(p) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(p)
+	return string(data)
+}
+
+// Validate проверяет операции и их параметры, не глядя на сам спектр
+func (p Pipeline) Validate() error {
+	if len(p) > MaxSteps {
+		return fmt.Errorf("at most %d steps are allowed", MaxSteps)
+	}
+	for i, step := range p {
+		if err := step.Validate(); err != nil {
+			return fmt.Errorf("step %d (%s): %w", i+1, step.Op, err)
+		}
+	}
+	return nil
+}
+
+// Validate проверяет параметры шага
+func (s Step) Validate() error {
+	switch s.Op {
+	case OpResample:
+		if !(s.Interval > 0) || math.IsInf(s.Interval, 0) {
+			return fmt.Errorf("interval must be positive, got %g", s.Interval)
+		}
+		if s.From != nil && s.To != nil && *s.From >= *s.To {
+			return fmt.Errorf("from %g must be below to %g", *s.From, *s.To)
+		}
+	case OpSmooth:
+		if s.Window < 3 || s.Window%2 == 0 || s.Window > MaxWindow {
+			return fmt.Errorf("window must be odd and within 3-%d, got %d", MaxWindow, s.Window)
+		}
+		if s.PolyOrder < 0 || s.PolyOrder > MaxPolyOrder || s.PolyOrder >= s.Window {
+			return fmt.Errorf("poly_order must be within 0-%d and below window, got %d", MaxPolyOrder, s.PolyOrder)
+		}
+	case OpBaseline:
+		if s.Method != BaselineLinear && s.Method != BaselineHull && s.Method != BaselineRubberband {
+			return fmt.Errorf("unknown baseline method %q", s.Method)
+		}
+	case OpNormalize:
+		if s.Method != NormalizeMax && s.Method != NormalizeArea && s.Method != NormalizeSNV {
+			return fmt.Errorf("unknown normalize method %q", s.Method)
+		}
+	case OpDerivative:
+		if s.Order != 1 && s.Order != 2 {
+			return fmt.Errorf("order must be 1 or 2, got %d", s.Order)
+		}
+	default:
+		return fmt.Errorf("unknown operation %q", s.Op)
+	}
+	return nil
+}
+
+// Apply применяет шаги по порядку. Исходный спектр не изменяется
+func (p Pipeline) Apply(s Spectrum) (Spectrum, error) {
+	if len(s) == 0 {
+		return nil, ErrEmpty
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	result := append(Spectrum(nil), s...)
+	for i, step := range p {
+		var err error
+		if result, err = step.apply(result); err != nil {
+			return nil, fmt.Errorf("step %d (%s): %w", i+1, step.Op, err)
+		}
+	}
+	return result, nil
+}
+
+func (s Step) apply(spectrum Spectrum) (Spectrum, error) {
+	switch s.Op {
+	case OpResample:
+		return resample(spectrum, s.From, s.To, s.Interval)
+	case OpSmooth:
+		return smooth(spectrum, s.Window, s.PolyOrder)
+	case OpBaseline:
+		switch s.Method {
+		case BaselineHull:
+			return removeContinuum(spectrum)
+		case BaselineRubberband:
+			return removeRubberband(spectrum), nil
+		}
+		return removeLinearBaseline(spectrum), nil
+	case OpNormalize:
+		return normalize(spectrum, s.Method)
+	case OpDerivative:
+		result, err := derivative(spectrum)
+		if err == nil && s.Order == 2 {
+			result, err = derivative(result)
+		}
+		return result, err
+	}
+	return nil, fmt.Errorf("unknown operation %q", s.Op)
+}
+
+func resample(s Spectrum, from, to *float64, interval float64) (Spectrum, error) {
+	lo, hi := s.Range()
+	if from != nil {
+		lo = *from
+	}
+	if to != nil {
+		hi = *to
+	}
+	if lo >= hi {
+		return nil, fmt.Errorf("empty grid %g-%g", lo, hi)
+	}
+	// Допуск, чтобы конец диапазона не терялся из-за ошибок округления
+	count := int(math.Floor((hi-lo)/interval+1e-9)) + 1
+	if count > MaxGridPoints {
+		return nil, fmt.Errorf("grid has %d points, at most %d are allowed", count, MaxGridPoints)
+	}
+	grid := make([]float64, count)
+	for i := range grid {
+		grid[i] = lo + float64(i)*interval
+	}
+	return s.Resample(grid)
+}
+
+// evenlySpaced проверяет, что шаг сетки постоянен с точностью до 0.1%
+func evenlySpaced(s Spectrum) bool {
+	if len(s) < 2 {
+		return true
+	}
+	step := s[1].Wavelength - s[0].Wavelength
+	for i := 2; i < len(s); i++ {
+		if math.Abs(s[i].Wavelength-s[i-1].Wavelength-step) > 1e-3*step {
+			return false
+		}
+	}
+	return true
+}
+
+// smooth - фильтр Савицкого–Голея. У краёв полином подгоняется по крайнему
+// полному окну и вычисляется в смещённой точке, поэтому длина спектра сохраняется
+func smooth(s Spectrum, window, polyOrder int) (Spectrum, error) {
+	if len(s) < window {
+		return nil, fmt.Errorf("spectrum has %d points, window %d needs more", len(s), window)
+	}
+	if !evenlySpaced(s) {
+		return nil, errUneven
+	}
+
+	half := window / 2
+	coefficients := make(map[int][]float64)
+	result := make(Spectrum, len(s))
+	for i := range s {
+		center := min(max(i, half), len(s)-1-half)
+		offset := i - center
+		c, ok := coefficients[offset]
+		if !ok {
+			var err error
+			if c, err = savitzkyGolay(half, polyOrder, offset); err != nil {
+				return nil, err
+			}
+			coefficients[offset] = c
+		}
+		var value float64
+		for k, weight := range c {
+			value += weight * s[center-half+k].Value
+		}
+		result[i] = Point{Wavelength: s[i].Wavelength, Value: value}
+	}
+	return result, nil
+}
+
+// savitzkyGolay возвращает веса окна -half..half, дающие значение полинома
+// степени order, подогнанного методом наименьших квадратов, в точке offset
+func savitzkyGolay(half, order, offset int) ([]float64, error) {
+	size := order + 1
+	// Нормальные уравнения: (AᵀA) z = h, где A[k][j] = x_k^j, h[j] = offset^j
+	normal := make([][]float64, size)
+	for j := range normal {
+		normal[j] = make([]float64, size+1)
+		for l := 0; l < size; l++ {
+			for x := -half; x <= half; x++ {
+				normal[j][l] += math.Pow(float64(x), float64(j+l))
+			}
+		}
+		normal[j][size] = math.Pow(float64(offset), float64(j))
+	}
+	z, err := solve(normal)
+	if err != nil {
+		return nil, err
+	}
+
+	weights := make([]float64, 2*half+1)
+	for k := range weights {
+		x := float64(k - half)
+		for j := 0; j < size; j++ {
+			weights[k] += z[j] * math.Pow(x, float64(j))
+		}
+	}
+	return weights, nil
+}
+
+// solve решает систему, заданную расширенной матрицей, методом Гаусса с выбором главного элемента
+func solve(m [][]float64) ([]float64, error) {
+	n := len(m)
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return nil, errors.New("singular system")
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		for row := col + 1; row < n; row++ {
+			factor := m[row][col] / m[col][col]
+			for k := col; k <= n; k++ {
+				m[row][k] -= factor * m[col][k]
+			}
+		}
+	}
+	x := make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		sum := m[row][n]
+		for k := row + 1; k < n; k++ {
+			sum -= m[row][k] * x[k]
+		}
+		x[row] = sum / m[row][row]
+	}
+	return x, nil
+}
+
+func removeLinearBaseline(s Spectrum) Spectrum {
+	result := make(Spectrum, len(s))
+	first, last := s[0], s[len(s)-1]
+	for i, p := range s {
+		baseline := first.Value
+		if last.Wavelength != first.Wavelength {
+			baseline += (p.Wavelength - first.Wavelength) / (last.Wavelength - first.Wavelength) * (last.Value - first.Value)
+		}
+		result[i] = Point{Wavelength: p.Wavelength, Value: p.Value - baseline}
+	}
+	return result
+}
+
+// convexHull строит верхнюю (upper = true) или нижнюю выпуклую оболочку спектра
+func convexHull(s Spectrum, upper bool) Spectrum {
+	var hull Spectrum
+	for _, p := range s {
+		for len(hull) >= 2 {
+			a, b := hull[len(hull)-2], hull[len(hull)-1]
+			cross := (b.Wavelength-a.Wavelength)*(p.Value-a.Value) - (b.Value-a.Value)*(p.Wavelength-a.Wavelength)
+			// Точка b не снаружи отрезка a-p - она внутри оболочки
+			if upper && cross >= 0 || !upper && cross <= 0 {
+				hull = hull[:len(hull)-1]
+				continue
+			}
+			break
+		}
+		hull = append(hull, p)
+	}
+	return hull
+}
+
+// removeContinuum делит спектр на верхнюю выпуклую оболочку: полосы
+// поглощения становятся провалами ниже 1 независимо от общего наклона спектра
+func removeContinuum(s Spectrum) (Spectrum, error) {
+	hull := convexHull(s, true)
+	result := make(Spectrum, len(s))
+	for i, p := range s {
+		continuum, _ := hull.At(p.Wavelength)
+		if continuum <= 0 {
+			return nil, fmt.Errorf("continuum is not positive at %g", p.Wavelength)
+		}
+		result[i] = Point{Wavelength: p.Wavelength, Value: p.Value / continuum}
+	}
+	return result, nil
+}
+
+// removeRubberband вычитает нижнюю выпуклую оболочку; результат неотрицателен
+func removeRubberband(s Spectrum) Spectrum {
+	hull := convexHull(s, false)
+	result := make(Spectrum, len(s))
+	for i, p := range s {
+		baseline, _ := hull.At(p.Wavelength)
+		result[i] = Point{Wavelength: p.Wavelength, Value: math.Max(0, p.Value-baseline)}
+	}
+	return result
+}
+
+func normalize(s Spectrum, method string) (Spectrum, error) {
+	values := s.Values()
+	var shift, scale float64
+	switch method {
+	case NormalizeMax:
+		for _, v := range values {
+			scale = math.Max(scale, math.Abs(v))
+		}
+	case NormalizeArea:
+		for i := 1; i < len(s); i++ {
+			scale += (s[i].Wavelength - s[i-1].Wavelength) * (s[i].Value + s[i-1].Value) / 2
+		}
+		scale = math.Abs(scale)
+	case NormalizeSNV:
+		if len(values) < 2 {
+			return nil, errors.New("snv needs at least two points")
+		}
+		for _, v := range values {
+			shift += v
+		}
+		shift /= float64(len(values))
+		for _, v := range values {
+			scale += (v - shift) * (v - shift)
+		}
+		scale = math.Sqrt(scale / float64(len(values)-1))
+	}
+	if scale < 1e-12 {
+		return nil, fmt.Errorf("%s normalization of a flat or zero spectrum", method)
+	}
+
+	result := make(Spectrum, len(s))
+	for i, p := range s {
+		result[i] = Point{Wavelength: p.Wavelength, Value: (p.Value - shift) / scale}
+	}
+	return result, nil
+}
+
+// derivative - первая производная конечными разностями: центральными второго
+// порядка точности для неравномерной сетки внутри, односторонними на краях
+func derivative(s Spectrum) (Spectrum, error) {
+	if len(s) < 3 {
+		return nil, fmt.Errorf("derivative needs at least 3 points, got %d", len(s))
+	}
+	n := len(s)
+	result := make(Spectrum, n)
+	for i := 1; i < n-1; i++ {
+		h1 := s[i].Wavelength - s[i-1].Wavelength
+		h2 := s[i+1].Wavelength - s[i].Wavelength
+		value := (h1*h1*s[i+1].Value - h2*h2*s[i-1].Value + (h2*h2-h1*h1)*s[i].Value) / (h1 * h2 * (h1 + h2))
+		result[i] = Point{Wavelength: s[i].Wavelength, Value: value}
+	}
+	result[0] = Point{Wavelength: s[0].Wavelength, Value: (s[1].Value - s[0].Value) / (s[1].Wavelength - s[0].Wavelength)}
+	result[n-1] = Point{Wavelength: s[n-1].Wavelength, Value: (s[n-1].Value - s[n-2].Value) / (s[n-1].Wavelength - s[n-2].Wavelength)}
+	return result, nil
+}
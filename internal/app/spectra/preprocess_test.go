@@ -0,0 +1,190 @@
+package spectra
+
+import (
+	"math"
+	"testing"
+)
+
+func grid(from, step float64, f func(x float64) float64, n int) Spectrum {
+	s := make(Spectrum, n)
+	for i := range s {
+		x := from + float64(i)*step
+		s[i] = Point{Wavelength: x, Value: f(x)}
+	}
+	return s
+}
+
+func assertValues(t *testing.T, got Spectrum, want []float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d points %v, want %v", len(got), got, want)
+	}
+	for i, p := range got {
+		if math.Abs(p.Value-want[i]) > 1e-9 {
+			t.Fatalf("point %d: got %v, want %v (spectrum %v)", i, p.Value, want[i], got)
+		}
+	}
+}
+
+func TestPipelineValidate(t *testing.T) {
+	from, to := 500.0, 400.0
+	tests := []struct {
+		name string
+		step Step
+	}{
+		{name: "unknown op", step: Step{Op: "fft"}},
+		{name: "resample without interval", step: Step{Op: OpResample}},
+		{name: "resample inverted range", step: Step{Op: OpResample, Interval: 10, From: &from, To: &to}},
+		{name: "even window", step: Step{Op: OpSmooth, Window: 4, PolyOrder: 2}},
+		{name: "poly order above window", step: Step{Op: OpSmooth, Window: 3, PolyOrder: 3}},
+		{name: "unknown baseline", step: Step{Op: OpBaseline, Method: "spline"}},
+		{name: "unknown normalization", step: Step{Op: OpNormalize, Method: "l2"}},
+		{name: "third derivative", step: Step{Op: OpDerivative, Order: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (Pipeline{tt.step}).Validate(); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+	if err := make(Pipeline, MaxSteps+1).Validate(); err == nil {
+		t.Fatal("too many steps must fail")
+	}
+}
+
+func TestResampleStep(t *testing.T) {
+	s := Spectrum{{400, 0}, {430, 3}, {500, 10}}
+	got, err := Pipeline{{Op: OpResample, Interval: 25}}.Apply(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, got, []float64{0, 2.5, 5, 7.5, 10})
+	if got[4].Wavelength != 500 {
+		t.Fatalf("grid must reach the end of the range, got %v", got.Wavelengths())
+	}
+
+	from := 350.0
+	if _, err := (Pipeline{{Op: OpResample, Interval: 25, From: &from}}).Apply(s); err == nil {
+		t.Fatal("grid outside the spectrum must fail")
+	}
+}
+
+func TestSmoothPreservesPolynomials(t *testing.T) {
+	// Фильтр степени 2 не искажает параболу, в том числе у краёв
+	parabola := func(x float64) float64 { return 0.001*x*x - 0.5*x + 70 }
+	s := grid(400, 10, parabola, 11)
+	got, err := Pipeline{{Op: OpSmooth, Window: 5, PolyOrder: 2}}.Apply(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, got, s.Values())
+
+	// Степень 0 - скользящее среднее
+	got, err = Pipeline{{Op: OpSmooth, Window: 3, PolyOrder: 0}}.Apply(spectrum(0, 3, 0, 3, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, got, []float64{1, 1, 2, 1, 1})
+
+	if _, err := (Pipeline{{Op: OpSmooth, Window: 3, PolyOrder: 1}}).Apply(Spectrum{{400, 1}, {410, 2}, {430, 3}}); err == nil {
+		t.Fatal("uneven grid must fail")
+	}
+}
+
+func TestBaselineSteps(t *testing.T) {
+	got, err := Pipeline{{Op: OpBaseline, Method: BaselineLinear}}.Apply(spectrum(1, 3, 3, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, got, []float64{0, 1, 0, 0})
+
+	// Полоса поглощения на 500 нм на фоне растущего континуума
+	got, err = Pipeline{{Op: OpBaseline, Method: BaselineHull}}.Apply(spectrum(0.2, 0.3, 0.2, 0.5, 0.6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, got, []float64{1, 1, 0.5, 1, 1})
+
+	// Пик высотой 0.5 над фоном, который натягивается по нижним точкам
+	got, err = Pipeline{{Op: OpBaseline, Method: BaselineRubberband}}.Apply(spectrum(1, 0.5, 1, 0.5, 1.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, got, []float64{0, 0, 0.5, 0, 0})
+
+	if _, err := (Pipeline{{Op: OpBaseline, Method: BaselineHull}}).Apply(spectrum(0, -1, 0)); err == nil {
+		t.Fatal("non-positive continuum must fail")
+	}
+}
+
+func TestNormalizeSteps(t *testing.T) {
+	tests := []struct {
+		method string
+		want   []float64
+	}{
+		{method: NormalizeMax, want: []float64{0.25, 0.5, 1}},
+		{method: NormalizeArea, want: []float64{1.0 / 225, 2.0 / 225, 4.0 / 225}},
+		{method: NormalizeSNV, want: []float64{-1 / math.Sqrt(7.0/3) * 4 / 3, -1 / math.Sqrt(7.0/3) / 3, 1 / math.Sqrt(7.0/3) * 5 / 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			got, err := Pipeline{{Op: OpNormalize, Method: tt.method}}.Apply(spectrum(1, 2, 4))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertValues(t, got, tt.want)
+		})
+	}
+	if _, err := (Pipeline{{Op: OpNormalize, Method: NormalizeSNV}}).Apply(spectrum(2, 2, 2)); err == nil {
+		t.Fatal("flat spectrum must fail snv")
+	}
+}
+
+func TestDerivativeStep(t *testing.T) {
+	// Производные параболы на неравномерной сетке точны внутри диапазона
+	s := Spectrum{{0, 0}, {1, 1}, {3, 9}, {4, 16}, {7, 49}}
+	got, err := Pipeline{{Op: OpDerivative, Order: 1}}.Apply(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{1, 2, 3} {
+		if want := 2 * s[i].Wavelength; math.Abs(got[i].Value-want) > 1e-9 {
+			t.Fatalf("d/dx at %v = %v, want %v", s[i].Wavelength, got[i].Value, want)
+		}
+	}
+
+	got, err = Pipeline{{Op: OpDerivative, Order: 2}}.Apply(grid(0, 1, func(x float64) float64 { return x * x }, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 2; i < 5; i++ {
+		if math.Abs(got[i].Value-2) > 1e-9 {
+			t.Fatalf("second derivative at %v = %v, want 2", got[i].Wavelength, got[i].Value)
+		}
+	}
+}
+
+func TestPipelineRoundTrip(t *testing.T) {
+	pipeline := Pipeline{
+		{Op: OpResample, Interval: 5},
+		{Op: OpSmooth, Window: 7, PolyOrder: 2},
+		{Op: OpNormalize, Method: NormalizeSNV},
+	}
+	parsed, err := ParsePipeline(pipeline.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.String() != pipeline.String() {
+		t.Fatalf("round trip changed pipeline: %s", parsed.String())
+	}
+	if pipeline.String() != `[{"op":"resample","interval":5},{"op":"smooth","window":7,"poly_order":2},{"op":"normalize","method":"snv"}]` {
+		t.Fatalf("unexpected storage format %s", pipeline.String())
+	}
+	if empty, err := ParsePipeline(""); err != nil || empty != nil {
+		t.Fatalf("empty pipeline: %v, %v", empty, err)
+	}
+	if _, err := ParsePipeline(`[{"op":"fft"}]`); err == nil {
+		t.Fatal("invalid stored pipeline must fail")
+	}
+}
@@ -0,0 +1,152 @@
+// Package spectra - разбор спектров и их статистическая обработка.
+//
+// Текстовая форма спектра - строка вида "400:0.12,410:0.15,...": пары
+// "длина волны:значение" через запятую. Parse также принимает двухколоночный
+// текст, который выгружают спектрометры (по строке на точку, разделитель -
+// пробел, табуляция, точка с запятой или запятая). Спектр заявки хранится в
+// двоичном формате Packed (см. packed.go).
+package spectra
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Point - значение спектра на одной длине волны
+type Point struct {
+	Wavelength float64
+	Value      float64
+}
+
+// Spectrum - точки, упорядоченные по возрастанию длины волны без повторов
+type Spectrum []Point
+
+var (
+	ErrEmpty     = errors.New("spectrum has no points")
+	ErrNoOverlap = errors.New("spectra do not share a wavelength range")
+)
+
+// Parse разбирает спектр в одном из поддерживаемых текстовых форматов
+func Parse(text string) (Spectrum, error) {
+	var pairs [][2]string
+	if strings.Contains(text, ":") {
+		for _, field := range strings.FieldsFunc(text, func(r rune) bool {
+			return r == ',' || r == ';' || r == '\n' || r == '\r' || r == ' ' || r == '\t'
+		}) {
+			wavelength, value, ok := strings.Cut(field, ":")
+			if !ok {
+				return nil, fmt.Errorf("point %q: expected wavelength:value", field)
+			}
+			pairs = append(pairs, [2]string{wavelength, value})
+		}
+	} else {
+		for _, line := range strings.Split(text, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			fields := strings.FieldsFunc(line, func(r rune) bool {
+				return r == ',' || r == ';' || r == ' ' || r == '\t'
+			})
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %q: expected two columns", line)
+			}
+			pairs = append(pairs, [2]string{fields[0], fields[1]})
+		}
+	}
+
+	spectrum := make(Spectrum, 0, len(pairs))
+	for _, pair := range pairs {
+		wavelength, err := strconv.ParseFloat(strings.TrimSpace(pair[0]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("wavelength %q: %w", pair[0], err)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(pair[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("value %q: %w", pair[1], err)
+		}
+		if math.IsNaN(wavelength) || math.IsInf(wavelength, 0) || math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, fmt.Errorf("point %s:%s is not finite", pair[0], pair[1])
+		}
+		spectrum = append(spectrum, Point{Wavelength: wavelength, Value: value})
+	}
+	if len(spectrum) == 0 {
+		return nil, ErrEmpty
+	}
+
+	sort.SliceStable(spectrum, func(i, j int) bool { return spectrum[i].Wavelength < spectrum[j].Wavelength })
+	for i := 1; i < len(spectrum); i++ {
+		if spectrum[i].Wavelength == spectrum[i-1].Wavelength {
+			return nil, fmt.Errorf("wavelength %g is repeated", spectrum[i].Wavelength)
+		}
+	}
+	return spectrum, nil
+}
+
+// String возвращает спектр в формате хранения "400:0.12,410:0.15"
+func (s Spectrum) String() string {
+	var b strings.Builder
+	for i, p := range s {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(p.Wavelength, 'g', -1, 64))
+		b.WriteByte(':')
+		b.WriteString(strconv.FormatFloat(p.Value, 'g', -1, 64))
+	}
+	return b.String()
+}
+
+// Wavelengths возвращает длины волн спектра
+func (s Spectrum) Wavelengths() []float64 {
+	result := make([]float64, len(s))
+	for i, p := range s {
+		result[i] = p.Wavelength
+	}
+	return result
+}
+
+// Values возвращает значения спектра
+func (s Spectrum) Values() []float64 {
+	result := make([]float64, len(s))
+	for i, p := range s {
+		result[i] = p.Value
+	}
+	return result
+}
+
+// Range возвращает первую и последнюю длину волны
+func (s Spectrum) Range() (from, to float64) {
+	return s[0].Wavelength, s[len(s)-1].Wavelength
+}
+
+// At линейно интерполирует значение на длине волны. ok = false вне диапазона спектра
+func (s Spectrum) At(wavelength float64) (value float64, ok bool) {
+	if len(s) == 0 || wavelength < s[0].Wavelength || wavelength > s[len(s)-1].Wavelength {
+		return 0, false
+	}
+	i := sort.Search(len(s), func(i int) bool { return s[i].Wavelength >= wavelength })
+	if s[i].Wavelength == wavelength {
+		return s[i].Value, true
+	}
+	left, right := s[i-1], s[i]
+	t := (wavelength - left.Wavelength) / (right.Wavelength - left.Wavelength)
+	return left.Value + t*(right.Value-left.Value), true
+}
+
+// Resample переносит спектр на сетку длин волн. Сетка должна лежать в диапазоне спектра
+func (s Spectrum) Resample(grid []float64) (Spectrum, error) {
+	result := make(Spectrum, len(grid))
+	for i, wavelength := range grid {
+		value, ok := s.At(wavelength)
+		if !ok {
+			return nil, fmt.Errorf("wavelength %g is outside %g-%g", wavelength, s[0].Wavelength, s[len(s)-1].Wavelength)
+		}
+		result[i] = Point{Wavelength: wavelength, Value: value}
+	}
+	return result, nil
+}
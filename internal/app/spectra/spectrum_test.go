@@ -0,0 +1,159 @@
+package spectra
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{name: "pairs", text: "400:0.12,500:0.35, 600:0.41", want: "400:0.12,500:0.35,600:0.41"},
+		{name: "pairs unsorted", text: "600:0.41;400:0.12\n500:0.35", want: "400:0.12,500:0.35,600:0.41"},
+		{name: "two columns", text: "# wavelength reflectance\n400\t0.12\n500 0.35\n600,0.41\n", want: "400:0.12,500:0.35,600:0.41"},
+		{name: "empty", text: " ", wantErr: true},
+		{name: "repeated wavelength", text: "400:0.1,400:0.2", wantErr: true},
+		{name: "bad value", text: "400:abc", wantErr: true},
+		{name: "not finite", text: "400:NaN", wantErr: true},
+		{name: "three columns", text: "400 0.1 0.2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.text)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Fatalf("got %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestAt(t *testing.T) {
+	s := Spectrum{{400, 0.2}, {500, 0.4}}
+	if v, ok := s.At(450); !ok || math.Abs(v-0.3) > 1e-12 {
+		t.Fatalf("At(450) = %v, %v", v, ok)
+	}
+	if _, ok := s.At(399); ok {
+		t.Fatal("At outside the range must fail")
+	}
+}
+
+func spectrum(values ...float64) Spectrum {
+	s := make(Spectrum, len(values))
+	for i, v := range values {
+		s[i] = Point{Wavelength: 400 + float64(i)*50, Value: v}
+	}
+	return s
+}
+
+func TestCombine(t *testing.T) {
+	readings := []Spectrum{
+		spectrum(0.30, 0.50, 0.60),
+		spectrum(0.32, 0.52, 0.61),
+		spectrum(0.31, 0.49, 0.59),
+		spectrum(0.29, 0.51, 0.60),
+		spectrum(0.80, 0.10, 0.90), // сбой: сместился щуп
+	}
+
+	t.Run("mad rejects the outlier", func(t *testing.T) {
+		got, err := Combine(readings, DefaultOutlierRule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Used != 4 || !got.Rejected[4] {
+			t.Fatalf("rejected %v, used %d", got.Rejected, got.Used)
+		}
+		if v := got.Mean[0].Value; math.Abs(v-0.305) > 1e-9 {
+			t.Fatalf("mean at 400 = %v, want 0.305", v)
+		}
+		if got.Std[0] <= 0 || got.Std[0] > 0.02 {
+			t.Fatalf("std at 400 = %v", got.Std[0])
+		}
+	})
+
+	t.Run("none keeps everything", func(t *testing.T) {
+		got, err := Combine(readings, OutlierRule{Method: OutlierNone})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Used != 5 {
+			t.Fatalf("used %d, want 5", got.Used)
+		}
+	})
+
+	t.Run("rmsd keeps at least half", func(t *testing.T) {
+		got, err := Combine(readings, OutlierRule{Method: OutlierRMSD, Threshold: 1e-6})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Used != 3 || !got.Rejected[4] {
+			t.Fatalf("rejected %v, used %d", got.Rejected, got.Used)
+		}
+	})
+
+	t.Run("two readings are never rejected", func(t *testing.T) {
+		got, err := Combine(readings[3:], OutlierRule{Method: OutlierRMSD, Threshold: 1e-6})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Used != 2 {
+			t.Fatalf("used %d, want 2", got.Used)
+		}
+	})
+
+	t.Run("different grids are resampled", func(t *testing.T) {
+		a := Spectrum{{400, 0.2}, {450, 0.3}, {500, 0.4}, {550, 0.5}}
+		b := Spectrum{{425, 0.25}, {525, 0.45}, {600, 0.6}}
+		got, err := Combine([]Spectrum{a, b}, DefaultOutlierRule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Mean.String() != "450:0.3,500:0.4,550:0.5" {
+			t.Fatalf("mean %s", got.Mean)
+		}
+	})
+
+	t.Run("no overlap", func(t *testing.T) {
+		_, err := Combine([]Spectrum{{{400, 1}, {450, 1}}, {{500, 1}, {550, 1}}}, DefaultOutlierRule)
+		if err != ErrNoOverlap {
+			t.Fatalf("err = %v, want ErrNoOverlap", err)
+		}
+	})
+
+	t.Run("bad rule", func(t *testing.T) {
+		if _, err := Combine(readings, OutlierRule{Method: OutlierMAD}); err == nil {
+			t.Fatal("zero threshold must be rejected")
+		}
+	})
+}
+
+func TestParseTechnique(t *testing.T) {
+	if _, err := ParseTechnique("400:0.1,700:0.5", TechniqueReflectance); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseTechnique("6.4:120,8.05:900", TechniqueXRF); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseTechnique("400:0.1,700:0.5", TechniqueXRF); err == nil {
+		t.Fatal("nanometres must not pass as keV")
+	}
+	if _, err := ParseTechnique("100:5,3500:2", TechniqueReflectance); err == nil {
+		t.Fatal("raman shift must not pass as nanometres")
+	}
+	if _, err := ParseTechnique("400:0.1", "ftir"); err == nil {
+		t.Fatal("unknown technique must fail")
+	}
+}
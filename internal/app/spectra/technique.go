@@ -0,0 +1,51 @@
+package spectra
+
+import "fmt"
+
+// Методики измерения. От методики зависят единицы оси и способ идентификации
+const (
+	// TechniqueReflectance - спектр отражения, ось - длина волны в нм
+	TechniqueReflectance = "reflectance"
+	// TechniqueRaman - спектр комбинационного рассеяния, ось - рамановский сдвиг в см⁻¹
+	TechniqueRaman = "raman"
+	// TechniqueXRF - рентгенофлуоресцентный спектр, ось - энергия в кэВ
+	TechniqueXRF = "xrf"
+)
+
+var Techniques = []string{TechniqueReflectance, TechniqueRaman, TechniqueXRF}
+
+// Axis - единицы и допустимый диапазон оси спектра методики
+type Axis struct {
+	Unit string
+	Min  float64
+	Max  float64
+}
+
+var axes = map[string]Axis{
+	TechniqueReflectance: {Unit: "nm", Min: 200, Max: 2500},
+	TechniqueRaman:       {Unit: "cm-1", Min: 10, Max: 4000},
+	TechniqueXRF:         {Unit: "keV", Min: 0, Max: 60},
+}
+
+// TechniqueAxis возвращает ось методики; ok = false для неизвестной методики
+func TechniqueAxis(technique string) (axis Axis, ok bool) {
+	axis, ok = axes[technique]
+	return axis, ok
+}
+
+// ParseTechnique разбирает спектр и проверяет, что ось лежит в диапазоне методики:
+// так спектр в нанометрах не примут за рамановский и наоборот
+func ParseTechnique(text, technique string) (Spectrum, error) {
+	axis, ok := axes[technique]
+	if !ok {
+		return nil, fmt.Errorf("unknown technique %q", technique)
+	}
+	spectrum, err := Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if from, to := spectrum.Range(); from < axis.Min || to > axis.Max {
+		return nil, fmt.Errorf("%s axis %g-%g is outside %g-%g %s", technique, from, to, axis.Min, axis.Max, axis.Unit)
+	}
+	return spectrum, nil
+}
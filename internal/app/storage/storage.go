@@ -0,0 +1,97 @@
+// Package storage - объектное хранилище изображений (MinIO). Ключи объектов те же,
+// что отдаёт прокси GET /api/images/:key.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Storage сохраняет объекты под ключом
+type Storage interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+}
+
+// MinIO - S3-совместимое хранилище
+type MinIO struct {
+	Client *minio.Client
+	Bucket string
+}
+
+// NewMinIO подключается к хранилищу по адресу вида http://host:port. Без ключей
+// запросы не подписываются
+func NewMinIO(endpoint, bucket, region, accessKey, secretKey string) (*MinIO, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("minio endpoint %q: expected http://host:port", endpoint)
+	}
+	client, err := minio.New(u.Host, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: u.Scheme == "https",
+		Region: region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &MinIO{Client: client, Bucket: bucket}, nil
+}
+
+// MinIOFromEnv настраивает хранилище по MINIO_ENDPOINT, MINIO_BUCKET, MINIO_REGION,
+// MINIO_ACCESS_KEY и MINIO_SECRET_KEY. Bucket по умолчанию - тот же, что у прокси изображений
+func MinIOFromEnv() (*MinIO, error) {
+	return NewMinIO(
+		getEnv("MINIO_ENDPOINT", "http://localhost:9000"),
+		getEnv("MINIO_BUCKET", "pigments"),
+		getEnv("MINIO_REGION", "us-east-1"),
+		os.Getenv("MINIO_ACCESS_KEY"),
+		os.Getenv("MINIO_SECRET_KEY"),
+	)
+}
+
+func (m *MinIO) Put(ctx context.Context, key, contentType string, data []byte) error {
+	_, err := m.Client.PutObject(ctx, m.Bucket, key, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return fmt.Errorf("put %s: %w", key, err)
+	}
+	return nil
+}
+
+// Memory хранит объекты в памяти; используется в тестах
+type Memory struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{objects: make(map[string][]byte)}
+}
+
+func (m *Memory) Put(ctx context.Context, key, contentType string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = bytes.Clone(data)
+	return nil
+}
+
+// Get возвращает сохранённый объект
+func (m *Memory) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	return data, ok
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// decodeChunks собирает тело, которое клиент по http шлёт кусками aws-chunked
+// ("<длина в hex>;chunk-signature=...\r\n<данные>\r\n")
+func decodeChunks(data []byte) string {
+	reader := bufio.NewReader(bytes.NewReader(data))
+	var body []byte
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return string(data)
+		}
+		size, err := strconv.ParseInt(strings.SplitN(line, ";", 2)[0], 16, 64)
+		if err != nil {
+			return string(data)
+		}
+		if size == 0 {
+			return string(body)
+		}
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return string(data)
+		}
+		body = append(body, chunk[:size]...)
+	}
+}
+
+func TestMinIOPut(t *testing.T) {
+	var path, body, contentType, authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		path, body = r.URL.EscapedPath(), decodeChunks(data)
+		contentType, authorization = r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+		if strings.Contains(path, "missing") {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	m, err := NewMinIO(server.URL, "pigments", "us-east-1", "key", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Put(context.Background(), "maps/abundance 1.png", "image/png", []byte("png")); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/pigments/maps/abundance%201.png" || body != "png" || contentType != "image/png" ||
+		!strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=key/") {
+		t.Fatalf("unexpected request %s %q %s %s", path, body, contentType, authorization)
+	}
+
+	if err := m.Put(context.Background(), "missing.png", "image/png", nil); err == nil {
+		t.Fatal("expected error on 404")
+	}
+
+	if _, err := NewMinIO("localhost:9000", "pigments", "us-east-1", "", ""); err == nil {
+		t.Fatal("endpoint without scheme accepted")
+	}
+}
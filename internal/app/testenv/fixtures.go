@@ -0,0 +1,246 @@
+package testenv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/refindex"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/spectra"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password - пароль всех пользователей из фикстур
+const Password = "password123"
+
+// Fixtures - типовой набор данных для тестов обработчиков
+type Fixtures struct {
+	// Creator создаётся первым и получает ID 1: обработчики с заглушкой
+	// currentUserID := uint(1) считают его автором заявок
+	Creator   ds.User
+	Moderator ds.User
+	Stranger  ds.User
+
+	Ultramarine ds.Pigment // эталоны: отражение и рамановский спектр
+	Ochre       ds.Pigment // эталоны: отражение и рамановский спектр
+	LeadWhite   ds.Pigment // в архиве, но остаётся в завершённой заявке
+
+	Artwork ds.Artwork // произведение Creator, к нему привязана Completed
+
+	Draft     *ds.SpectrumAnalysis // черновик Creator с Ultramarine и Ochre
+	Created   *ds.SpectrumAnalysis // сформированная заявка Creator с Ultramarine
+	Completed *ds.SpectrumAnalysis // завершённая заявка Creator с Ochre и LeadWhite, точка P1 на Artwork
+	Foreign   *ds.SpectrumAnalysis // сформированная заявка Stranger с Ochre
+}
+
+// Seed заполняет хранилище фикстурами
+func (e *Env) Seed(t testing.TB) *Fixtures {
+	t.Helper()
+	ctx := context.Background()
+	f := &Fixtures{}
+
+	f.Creator = e.createUser(t, "creator", false)
+	f.Moderator = e.createUser(t, "moderator", true)
+	f.Stranger = e.createUser(t, "stranger", false)
+	if f.Creator.ID != 1 {
+		t.Fatalf("creator got ID %d, handlers expect 1", f.Creator.ID)
+	}
+
+	f.Ultramarine = e.createPigment(t, ds.Pigment{
+		Name:  "Ультрамарин",
+		Brief: "Синий пигмент из лазурита",
+		Color: "blue",
+		Specs: "Химическая формула: Na8-10Al6Si6O24S2-4",
+
+		CIName:        "PB29",
+		CINumber:      "77007",
+		Formula:       "Na8-10Al6Si6O24S2-4",
+		CASNumber:     "57455-37-5",
+		Class:         ds.PigmentClassMineral,
+		Lightfastness: intPtr(1),
+		Toxicity:      ds.ToxicityNone,
+		AvailableFrom: intPtr(600),
+	})
+	f.Ochre = e.createPigment(t, ds.Pigment{
+		Name:  "Жёлтая охра",
+		Brief: "Природный пигмент на основе гётита",
+		Color: "yellow",
+		Specs: "Состав: FeO(OH) + глина",
+
+		CIName:        "PY43",
+		CINumber:      "77492",
+		Formula:       "FeO(OH)",
+		CASNumber:     "51274-00-1",
+		Class:         ds.PigmentClassEarth,
+		Lightfastness: intPtr(1),
+		Toxicity:      ds.ToxicityNone,
+	})
+	f.LeadWhite = e.createPigment(t, ds.Pigment{
+		Name:  "Свинцовые белила",
+		Brief: "Основный карбонат свинца",
+		Color: "white",
+		Specs: "Химическая формула: 2PbCO3·Pb(OH)2",
+
+		CIName:        "PW1",
+		CINumber:      "77597",
+		Formula:       "2PbCO3·Pb(OH)2",
+		CASNumber:     "1319-46-6",
+		Class:         ds.PigmentClassMineral,
+		Lightfastness: intPtr(1),
+		Toxicity:      ds.ToxicityHigh,
+		AvailableFrom: intPtr(-400),
+	})
+
+	e.addReference(t, f.Ultramarine, "reflectance", "400:0.45,450:0.50,500:0.30,550:0.12,600:0.08,650:0.10,700:0.25")
+	e.addReference(t, f.Ultramarine, "raman", "200:5,258:30,300:5,500:5,548:100,600:5,1050:5,1096:40,1150:5")
+	e.addReference(t, f.Ochre, "reflectance", "400:0.08,450:0.10,500:0.18,550:0.38,600:0.50,650:0.55,700:0.58")
+	// Гётит: полосы 247, 299, 385, 480 и 550 см⁻¹
+	e.addReference(t, f.Ochre, "raman", "200:10,247:60,270:15,299:40,340:15,385:100,430:15,480:30,515:12,550:25,600:10")
+	// У охры есть библиотека полос, у ультрамарина полосы берутся из эталонного спектра
+	ochreBands := []ds.RamanBand{
+		{Position: 247, Tolerance: 5, Strength: "medium", Assignment: "Fe-O"},
+		{Position: 299, Tolerance: 5, Strength: "medium", Assignment: "Fe-OH"},
+		{Position: 385, Tolerance: 5, Strength: "strong", Assignment: "Fe-O-Fe"},
+		{Position: 480, Tolerance: 5, Strength: "weak"},
+		{Position: 550, Tolerance: 5, Strength: "weak"},
+	}
+	if err := e.Pigments.ReplaceRamanBands(ctx, f.Ochre.ID, ochreBands); err != nil {
+		t.Fatalf("add ochre raman bands: %v", err)
+	}
+
+	f.Artwork = ds.Artwork{
+		Title:           "Портрет дамы в голубом",
+		Artist:          "Неизвестный художник",
+		Date:            "ок. 1780",
+		Year:            intPtr(1780),
+		InventoryNumber: "Ж-1024",
+		Collection:      "Государственный музей",
+		CreatorID:       f.Creator.ID,
+	}
+	if err := e.Artworks.CreateArtwork(ctx, &f.Artwork); err != nil {
+		t.Fatalf("create artwork: %v", err)
+	}
+
+	f.Created = e.formAnalysis(t, f.Creator, "400:0.12,500:0.35,600:0.41", f.Ultramarine)
+
+	f.Completed = e.formAnalysis(t, f.Creator, "400:0.30,500:0.52,600:0.60", f.Ochre, f.LeadWhite)
+	now := time.Now()
+	f.Completed.Status = ds.StatusCompleted
+	f.Completed.CompletedAt = &now
+	f.Completed.ModeratorID = &f.Moderator.ID
+	f.Completed.ArtworkID = &f.Artwork.ID
+	results := map[uint]repository.PigmentResult{f.Ochre.ID: {Percent: 60}, f.LeadWhite.ID: {Percent: 25.5}}
+	if err := e.Analyses.CompleteAnalysis(ctx, f.Completed, results); err != nil {
+		t.Fatalf("complete analysis: %v", err)
+	}
+	point := ds.MeasurementPoint{ArtworkID: f.Artwork.ID, X: 0.25, Y: 0.4, Label: "P1", Layer: "красочный слой"}
+	if err := e.Analyses.SaveMeasurementPoint(ctx, f.Completed, &point); err != nil {
+		t.Fatalf("save measurement point: %v", err)
+	}
+
+	f.Foreign = e.formAnalysis(t, f.Stranger, "400:0.20,500:0.40,600:0.50", f.Ochre)
+
+	f.Draft = e.draft(t, f.Creator, f.Ultramarine, f.Ochre)
+	f.Draft.Spectrum = PackSpectrum(t, "400:0.15,500:0.25,600:0.45")
+	if err := e.Analyses.UpdateAnalysis(ctx, f.Draft); err != nil {
+		t.Fatalf("update draft: %v", err)
+	}
+
+	if err := e.Pigments.ArchivePigment(ctx, f.LeadWhite.ID); err != nil {
+		t.Fatalf("archive pigment: %v", err)
+	}
+	archived, err := e.Pigments.GetPigment(ctx, f.LeadWhite.ID, true)
+	if err != nil {
+		t.Fatalf("get archived pigment: %v", err)
+	}
+	f.LeadWhite = *archived
+
+	return f
+}
+
+func (e *Env) createUser(t testing.TB, login string, moderator bool) ds.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(Password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	user := ds.User{Login: login, PasswordHash: string(hash), IsModerator: moderator}
+	if err := e.Users.CreateUser(context.Background(), &user); err != nil {
+		t.Fatalf("create user %s: %v", login, err)
+	}
+	return user
+}
+
+func (e *Env) createPigment(t testing.TB, pigment ds.Pigment) ds.Pigment {
+	t.Helper()
+	if err := e.Pigments.CreatePigment(context.Background(), &pigment); err != nil {
+		t.Fatalf("create pigment %s: %v", pigment.Name, err)
+	}
+	return pigment
+}
+
+func (e *Env) addReference(t testing.TB, pigment ds.Pigment, technique, spectrum string) {
+	t.Helper()
+	parsed, err := spectra.Parse(spectrum)
+	if err != nil {
+		t.Fatalf("parse %s reference for %s: %v", technique, pigment.Name, err)
+	}
+	reference := ds.ReferenceSpectrum{
+		PigmentID: pigment.ID,
+		Technique: technique,
+		Spectrum:  spectrum,
+		Source:    "fixtures",
+		Features:  refindex.ReferenceFeatures(technique, parsed),
+	}
+	if err := e.Pigments.AddReferenceSpectrum(context.Background(), &reference); err != nil {
+		t.Fatalf("add %s reference for %s: %v", technique, pigment.Name, err)
+	}
+	e.References.Put(reference)
+}
+
+// draft собирает черновик пользователя из пигментов так же, как это делает корзина
+func (e *Env) draft(t testing.TB, user ds.User, pigments ...ds.Pigment) *ds.SpectrumAnalysis {
+	t.Helper()
+	var analysis *ds.SpectrumAnalysis
+	for _, pigment := range pigments {
+		var err error
+		analysis, _, err = e.Analyses.AddPigmentToDraft(context.Background(), user.ID, pigment.ID)
+		if err != nil {
+			t.Fatalf("add pigment %s to draft: %v", pigment.Name, err)
+		}
+	}
+	return analysis
+}
+
+// formAnalysis создаёт черновик и переводит его в статус created
+func (e *Env) formAnalysis(t testing.TB, user ds.User, spectrum string, pigments ...ds.Pigment) *ds.SpectrumAnalysis {
+	t.Helper()
+	analysis := e.draft(t, user, pigments...)
+	now := time.Now()
+	analysis.Spectrum = PackSpectrum(t, spectrum)
+	analysis.Status = ds.StatusCreated
+	analysis.FormedAt = &now
+	if err := e.Analyses.UpdateAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("form analysis: %v", err)
+	}
+	return analysis
+}
+
+// PackSpectrum переводит текстовый спектр в формат хранения заявки
+func PackSpectrum(t testing.TB, text string) []byte {
+	t.Helper()
+	spectrum, err := spectra.Parse(text)
+	if err != nil {
+		t.Fatalf("parse spectrum: %v", err)
+	}
+	packed, err := spectra.Pack(spectrum)
+	if err != nil {
+		t.Fatalf("pack spectrum: %v", err)
+	}
+	return packed
+}
+
+func intPtr(v int) *int { return &v }
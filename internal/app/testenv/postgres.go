@@ -0,0 +1,176 @@
+package testenv
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"colorLex/internal/app/migrations"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+const templateDB = "colorlex_template"
+
+// cluster - временный кластер Postgres, общий для всех тестов пакета.
+// Каждый тест получает свою базу, скопированную из шаблона с применёнными миграциями
+type cluster struct {
+	dir     string
+	binDir  string
+	port    int
+	admin   *sql.DB
+	counter atomic.Int64
+}
+
+var (
+	pgOnce    sync.Once
+	pgCluster *cluster
+	pgErr     error
+)
+
+// errNoPostgres - в системе нет бинарников Postgres, тесты идут на хранилище в памяти
+var errNoPostgres = errors.New("postgres binaries not found")
+
+// sharedCluster запускает кластер при первом обращении
+func sharedCluster() (*cluster, error) {
+	pgOnce.Do(func() {
+		pgCluster, pgErr = startCluster()
+	})
+	return pgCluster, pgErr
+}
+
+// findPostgresBin ищет каталог с initdb и pg_ctl: COLORLEX_TEST_PG_BIN, затем PATH,
+// затем стандартные каталоги пакетов Debian/Ubuntu
+func findPostgresBin() (string, error) {
+	if dir := os.Getenv("COLORLEX_TEST_PG_BIN"); dir != "" {
+		if _, err := os.Stat(filepath.Join(dir, "initdb")); err != nil {
+			return "", fmt.Errorf("COLORLEX_TEST_PG_BIN: %w", err)
+		}
+		return dir, nil
+	}
+	if path, err := exec.LookPath("initdb"); err == nil {
+		return filepath.Dir(path), nil
+	}
+	matches, _ := filepath.Glob("/usr/lib/postgresql/*/bin/initdb")
+	if len(matches) > 0 {
+		return filepath.Dir(matches[len(matches)-1]), nil
+	}
+	return "", errNoPostgres
+}
+
+func startCluster() (*cluster, error) {
+	binDir, err := findPostgresBin()
+	if err != nil {
+		return nil, err
+	}
+	// initdb отказывается работать от root
+	if os.Geteuid() == 0 {
+		return nil, fmt.Errorf("%w: initdb cannot run as root", errNoPostgres)
+	}
+
+	// Короткий путь: в нём лежит unix-сокет, длина которого ограничена
+	dir, err := os.MkdirTemp("", "colorlex-pg")
+	if err != nil {
+		return nil, err
+	}
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	c := &cluster{dir: dir, binDir: binDir, port: port}
+	dataDir := filepath.Join(dir, "data")
+
+	if err := c.run("initdb", "-D", dataDir, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-sync"); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	options := fmt.Sprintf("-p %d -k %s -c listen_addresses='' -c fsync=off -c full_page_writes=off", port, dir)
+	if err := c.run("pg_ctl", "-D", dataDir, "-o", options, "-l", filepath.Join(dir, "postgres.log"), "-w", "start"); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	if err := c.prepareTemplate(); err != nil {
+		c.stop()
+		return nil, err
+	}
+	return c, nil
+}
+
+// prepareTemplate создаёт шаблонную базу и прогоняет на ней миграции
+func (c *cluster) prepareTemplate() error {
+	admin, err := sql.Open("pgx", c.dsn("postgres"))
+	if err != nil {
+		return err
+	}
+	c.admin = admin
+
+	ctx := context.Background()
+	if _, err := admin.ExecContext(ctx, "CREATE DATABASE "+templateDB); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("pgx", c.dsn(templateDB))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		return fmt.Errorf("migrate template: %w", err)
+	}
+	return nil
+}
+
+// createDatabase копирует шаблон в новую базу и возвращает её DSN
+func (c *cluster) createDatabase() (string, error) {
+	name := "colorlex_" + strconv.FormatInt(c.counter.Add(1), 10)
+	_, err := c.admin.Exec("CREATE DATABASE " + name + " TEMPLATE " + templateDB)
+	if err != nil {
+		return "", err
+	}
+	return c.dsn(name), nil
+}
+
+func (c *cluster) dsn(database string) string {
+	return fmt.Sprintf("host=%s port=%d user=postgres dbname=%s sslmode=disable", c.dir, c.port, database)
+}
+
+func (c *cluster) stop() {
+	if c.admin != nil {
+		c.admin.Close()
+	}
+	_ = c.run("pg_ctl", "-D", filepath.Join(c.dir, "data"), "-m", "immediate", "-w", "stop")
+	os.RemoveAll(c.dir)
+}
+
+func (c *cluster) run(name string, args ...string) error {
+	output, err := exec.Command(filepath.Join(c.binDir, name), args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w\n%s", name, err, output)
+	}
+	return nil
+}
+
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,243 @@
+// Package testenv поднимает полный стек API (SetupAPIRouter) для тестов обработчиков.
+// Хранилище - временный Postgres, если в системе есть его бинарники, иначе memstore;
+// Redis заменяется на miniredis. Сеть и docker не нужны.
+//
+// Выбор хранилища задаётся переменной COLORLEX_TEST_BACKEND: "postgres", "memory"
+// или пусто (Postgres при наличии, иначе память).
+package testenv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"colorLex/internal/app/api"
+	"colorLex/internal/app/api/handlers"
+	"colorLex/internal/app/api/middleware"
+	"colorLex/internal/app/api/redis"
+	"colorLex/internal/app/ds"
+	"colorLex/internal/app/hyperspectral"
+	"colorLex/internal/app/refindex"
+	"colorLex/internal/app/report"
+	"colorLex/internal/app/repository"
+	"colorLex/internal/app/repository/memstore"
+	"colorLex/internal/app/storage"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// JWTSecret - секрет, которым подписываются токены в тестах
+const JWTSecret = "test-secret"
+
+const (
+	BackendPostgres = "postgres"
+	BackendMemory   = "memory"
+)
+
+// Env - собранный API поверх выбранного хранилища
+type Env struct {
+	Backend     string
+	Router      *gin.Engine
+	AuthMW      *middleware.AuthMiddleware
+	Pigments    repository.PigmentStore
+	Analyses    repository.AnalysisStore
+	Artworks    repository.ArtworkStore
+	Users       repository.UserStore
+	Instruments repository.InstrumentStore
+	Cubes       repository.CubeStore
+	Storage     *storage.Memory // объектное хранилище карт долей
+	Reports     *report.Job
+	References  *refindex.Index // индекс эталонов отражения; Seed добавляет в него эталоны фикстур
+	// SpectrumAnalysis - обработчик заявок; тест может поменять его настройки до запросов
+	SpectrumAnalysis *handlers.SpectrumAnalysisHandler
+	Redis            *miniredis.Miniredis
+}
+
+// Main запускает тесты пакета и останавливает общий кластер Postgres.
+// Использование: func TestMain(m *testing.M) { os.Exit(testenv.Main(m)) }
+func Main(m *testing.M) int {
+	code := m.Run()
+	if pgCluster != nil {
+		pgCluster.stop()
+	}
+	return code
+}
+
+// New собирает API на чистом хранилище
+func New(t testing.TB) *Env {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	env := &Env{Redis: miniredis.RunT(t)}
+
+	switch backend := os.Getenv("COLORLEX_TEST_BACKEND"); backend {
+	case BackendMemory:
+		env.useMemory()
+	case BackendPostgres:
+		if err := env.usePostgres(t); err != nil {
+			t.Fatalf("postgres backend: %v", err)
+		}
+	case "":
+		err := env.usePostgres(t)
+		if errors.Is(err, errNoPostgres) {
+			env.useMemory()
+		} else if err != nil {
+			t.Fatalf("postgres backend: %v", err)
+		}
+	default:
+		t.Fatalf("unknown COLORLEX_TEST_BACKEND %q", backend)
+	}
+
+	redisClient := redis.NewClient(env.Redis.Addr(), "", 0)
+	t.Cleanup(func() { redisClient.Close() })
+
+	// Воркер кубов работает в фоне, пока идёт тест; останавливается до удаления каталога
+	env.Storage = storage.NewMemory()
+	worker := hyperspectral.NewWorker(env.Cubes, env.Analyses, env.Pigments, env.Storage, t.TempDir())
+	worker.Threads = 2
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		worker.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+
+	// Задание отчётов тоже работает в фоне; Refresh пересчитывает отчёт сразу
+	env.Reports = report.NewJob(env.Analyses, redisClient)
+	reportsStopped := make(chan struct{})
+	go func() {
+		defer close(reportsStopped)
+		env.Reports.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-reportsStopped
+	})
+
+	env.References = refindex.New()
+	if err := env.References.Load(ctx, env.Pigments); err != nil {
+		t.Fatalf("load reference index: %v", err)
+	}
+
+	env.SpectrumAnalysis = handlers.NewSpectrumAnalysisHandler(env.Analyses, env.Artworks, env.Pigments, env.Instruments, env.References)
+	env.AuthMW = middleware.NewAuthMiddleware(env.Users, JWTSecret)
+	env.Router = gin.New()
+	api.SetupAPIRouter(env.Router, env.AuthMW,
+		handlers.NewUsersHandler(env.Users, env.AuthMW, redisClient),
+		handlers.NewPigmentHandler(env.Pigments, env.Analyses, env.References),
+		env.SpectrumAnalysis,
+		handlers.NewSpectrumAnalysisPigmentsHandler(env.Analyses),
+		handlers.NewArtworkHandler(env.Artworks, env.Storage),
+		handlers.NewInstrumentHandler(env.Instruments),
+		handlers.NewCubeHandler(env.Analyses, env.Cubes, worker),
+		handlers.NewReportHandler(env.Reports),
+	)
+	return env
+}
+
+func (e *Env) useMemory() {
+	store := memstore.New()
+	e.Backend = BackendMemory
+	e.Pigments, e.Analyses, e.Artworks, e.Users, e.Instruments, e.Cubes = store, store, store, store, store, store
+}
+
+func (e *Env) usePostgres(t testing.TB) error {
+	c, err := sharedCluster()
+	if err != nil {
+		return err
+	}
+	dsn, err := c.createDatabase()
+	if err != nil {
+		return err
+	}
+	repo, err := repository.New(dsn)
+	if err != nil {
+		return err
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	e.Backend = BackendPostgres
+	e.Pigments, e.Analyses, e.Artworks, e.Users, e.Instruments, e.Cubes = repo, repo, repo, repo, repo, repo
+	return nil
+}
+
+// Token выдаёт access-токен пользователя
+func (e *Env) Token(t testing.TB, user ds.User) string {
+	t.Helper()
+	token, err := e.AuthMW.GenerateToken(&user)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	return token
+}
+
+// RequestOption настраивает запрос перед отправкой
+type RequestOption func(*http.Request)
+
+// WithToken добавляет заголовок Authorization: Bearer
+func WithToken(token string) RequestOption {
+	return func(r *http.Request) {
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+}
+
+// WithHeader устанавливает произвольный заголовок
+func WithHeader(key, value string) RequestOption {
+	return func(r *http.Request) {
+		r.Header.Set(key, value)
+	}
+}
+
+// Do отправляет запрос в роутер. body: nil, io.Reader (отправляется как есть)
+// или любое значение, которое кодируется в JSON
+func (e *Env) Do(t testing.TB, method, path string, body any, opts ...RequestOption) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader io.Reader
+	isJSON := false
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		reader = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatalf("marshal body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+		isJSON = true
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if isJSON {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	rec := httptest.NewRecorder()
+	e.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+// Decode разбирает JSON-ответ в v
+func Decode(t testing.TB, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+}
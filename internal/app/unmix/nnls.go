@@ -0,0 +1,130 @@
+package unmix
+
+import "math"
+
+// nnls решает задачу наименьших квадратов ‖Aw - b‖ → min при w ≥ 0 активными
+// множествами (Лоусон, Хэнсон). columns - столбцы матрицы A
+func nnls(columns [][]float64, b []float64) []float64 {
+	n := len(columns)
+	w := make([]float64, n)
+	passive := make([]bool, n)
+	const tolerance = 1e-12
+
+	for iteration := 0; iteration < 3*n+3; iteration++ {
+		// Градиент: в какую сторону выгодно увеличить неактивные веса
+		residual := subtract(b, combine(columns, w))
+		best, bestGradient := -1, tolerance
+		for j := range columns {
+			if passive[j] {
+				continue
+			}
+			if g := dot(columns[j], residual); g > bestGradient {
+				best, bestGradient = j, g
+			}
+		}
+		if best < 0 {
+			break
+		}
+		passive[best] = true
+
+		for {
+			z := leastSquares(columns, b, passive)
+			feasible, alpha := true, 1.0
+			for j := range columns {
+				if passive[j] && z[j] <= tolerance {
+					feasible = false
+					if step := w[j] - z[j]; step > 0 {
+						alpha = math.Min(alpha, w[j]/step)
+					} else {
+						alpha = 0
+					}
+				}
+			}
+			if feasible {
+				w = z
+				break
+			}
+			// Сдвигаемся к z, пока первый из весов не станет нулём, и выводим его из множества
+			for j := range columns {
+				w[j] += alpha * (z[j] - w[j])
+				if passive[j] && w[j] <= tolerance {
+					passive[j] = false
+					w[j] = 0
+				}
+			}
+		}
+	}
+	return w
+}
+
+// leastSquares решает нормальные уравнения на столбцах из passive, остальные веса - нули.
+// Небольшая регуляризация не даёт системе выродиться на совпадающих эталонах
+func leastSquares(columns [][]float64, b []float64, passive []bool) []float64 {
+	var index []int
+	for j, ok := range passive {
+		if ok {
+			index = append(index, j)
+		}
+	}
+	k := len(index)
+	m := make([][]float64, k)
+	var trace float64
+	for r, i := range index {
+		m[r] = make([]float64, k+1)
+		for c, j := range index {
+			m[r][c] = dot(columns[i], columns[j])
+		}
+		m[r][k] = dot(columns[i], b)
+		trace += m[r][r]
+	}
+	for r := range m {
+		m[r][r] += 1e-10 * (trace + 1)
+	}
+
+	// Метод Гаусса с выбором ведущего элемента
+	for col := 0; col < k; col++ {
+		pivot := col
+		for r := col + 1; r < k; r++ {
+			if math.Abs(m[r][col]) > math.Abs(m[pivot][col]) {
+				pivot = r
+			}
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		for r := col + 1; r < k; r++ {
+			factor := m[r][col] / m[col][col]
+			for c := col; c <= k; c++ {
+				m[r][c] -= factor * m[col][c]
+			}
+		}
+	}
+	solution := make([]float64, k)
+	for r := k - 1; r >= 0; r-- {
+		sum := m[r][k]
+		for c := r + 1; c < k; c++ {
+			sum -= m[r][c] * solution[c]
+		}
+		solution[r] = sum / m[r][r]
+	}
+
+	z := make([]float64, len(columns))
+	for r, j := range index {
+		z[j] = solution[r]
+	}
+	return z
+}
+
+func dot(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func subtract(a, b []float64) []float64 {
+	result := make([]float64, len(a))
+	for i := range a {
+		result[i] = a[i] - b[i]
+	}
+	return result
+}
@@ -0,0 +1,248 @@
+// Package unmix - оценка долей пигментов в смеси по спектру отражения.
+//
+// Спектр образца раскладывается в неотрицательную линейную комбинацию эталонных
+// спектров пигментов (метод Лоусона-Хэнсона). Доли - нормированные веса.
+// Неопределённость оценивается бутстрепом остатков: к подогнанной кривой
+// добавляются перемешанные остатки и разложение повторяется.
+package unmix
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"sort"
+
+	"colorLex/internal/app/spectra"
+)
+
+// Параметры оценки
+const (
+	// Rounds - число бутстреп-повторов
+	Rounds = 200
+	// Confidence - уровень доверительного интервала
+	Confidence = 0.95
+	// SimilarityThreshold - косинусное сходство эталонов, начиная с которого
+	// пигменты неотличимы: линейная модель не может разделить их доли
+	SimilarityThreshold = 0.995
+)
+
+// ErrTooFewPoints - у образца и эталонов слишком мало общих длин волн
+var ErrTooFewPoints = errors.New("not enough common wavelengths to unmix")
+
+// Endmember - эталонный спектр пигмента
+type Endmember struct {
+	PigmentID uint
+	Spectrum  spectra.Spectrum
+}
+
+// Share - оценка доли одного пигмента
+type Share struct {
+	PigmentID uint
+	Fraction  float64 // 0..1, сумма по пигментам - 1
+	// Low и High - границы доверительного интервала доли
+	Low  float64
+	High float64
+	// Indistinguishable - пигменты, спектры которых совпадают с этим с точностью до масштаба
+	Indistinguishable []uint
+}
+
+// Result - разложение образца
+type Result struct {
+	Shares []Share // в порядке эталонов
+	RMSE   float64 // среднеквадратичный остаток подгонки
+}
+
+// Estimate раскладывает образец по эталонам и оценивает доверительные интервалы
+// долей. rng задаёт перемешивание остатков: при одинаковом генераторе результат повторяется
+func Estimate(sample spectra.Spectrum, endmembers []Endmember, rng *rand.Rand) (Result, error) {
+	grid, columns := commonGrid(sample, endmembers)
+	if len(grid) < max(3, len(endmembers)+1) {
+		return Result{}, ErrTooFewPoints
+	}
+	observed := make([]float64, len(grid))
+	for i, wavelength := range grid {
+		observed[i], _ = sample.At(wavelength)
+	}
+
+	weights := nnls(columns, observed)
+	fitted := combine(columns, weights)
+	residuals := make([]float64, len(observed))
+	var squares float64
+	for i := range observed {
+		residuals[i] = observed[i] - fitted[i]
+		squares += residuals[i] * residuals[i]
+	}
+
+	result := Result{
+		Shares: make([]Share, len(endmembers)),
+		RMSE:   math.Sqrt(squares / float64(len(observed))),
+	}
+	fractions := normalize(weights)
+	for i, endmember := range endmembers {
+		result.Shares[i] = Share{PigmentID: endmember.PigmentID, Fraction: fractions[i]}
+	}
+
+	// Бутстреп остатков
+	samples := make([][]float64, len(endmembers))
+	resampled := make([]float64, len(observed))
+	for round := 0; round < Rounds; round++ {
+		for i := range resampled {
+			resampled[i] = fitted[i] + residuals[rng.IntN(len(residuals))]
+		}
+		for i, fraction := range normalize(nnls(columns, resampled)) {
+			samples[i] = append(samples[i], fraction)
+		}
+	}
+	tail := (1 - Confidence) / 2
+	for i := range result.Shares {
+		sort.Float64s(samples[i])
+		result.Shares[i].Low = quantile(samples[i], tail)
+		result.Shares[i].High = quantile(samples[i], 1-tail)
+	}
+
+	for i := range columns {
+		for j := i + 1; j < len(columns); j++ {
+			if cosine(columns[i], columns[j]) >= SimilarityThreshold {
+				result.Shares[i].Indistinguishable = append(result.Shares[i].Indistinguishable, endmembers[j].PigmentID)
+				result.Shares[j].Indistinguishable = append(result.Shares[j].Indistinguishable, endmembers[i].PigmentID)
+			}
+		}
+	}
+	return result, nil
+}
+
+// commonGrid оставляет длины волн образца, попадающие в диапазон всех эталонов,
+// и возвращает значения эталонов на них
+func commonGrid(sample spectra.Spectrum, endmembers []Endmember) ([]float64, [][]float64) {
+	var grid []float64
+	for _, p := range sample {
+		covered := true
+		for _, endmember := range endmembers {
+			if _, ok := endmember.Spectrum.At(p.Wavelength); !ok {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			grid = append(grid, p.Wavelength)
+		}
+	}
+
+	columns := make([][]float64, len(endmembers))
+	for j, endmember := range endmembers {
+		columns[j] = make([]float64, len(grid))
+		for i, wavelength := range grid {
+			columns[j][i], _ = endmember.Spectrum.At(wavelength)
+		}
+	}
+	return grid, columns
+}
+
+func combine(columns [][]float64, weights []float64) []float64 {
+	if len(columns) == 0 {
+		return nil
+	}
+	result := make([]float64, len(columns[0]))
+	for j, column := range columns {
+		for i, value := range column {
+			result[i] += weights[j] * value
+		}
+	}
+	return result
+}
+
+// normalize переводит веса в доли. Если подогнать ничего не удалось, доли нулевые
+func normalize(weights []float64) []float64 {
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	fractions := make([]float64, len(weights))
+	if total == 0 {
+		return fractions
+	}
+	for i, w := range weights {
+		fractions[i] = w / total
+	}
+	return fractions
+}
+
+// quantile - квантиль отсортированной выборки с линейной интерполяцией
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	position := q * float64(len(sorted)-1)
+	i := int(position)
+	if i >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	t := position - float64(i)
+	return sorted[i] + t*(sorted[i+1]-sorted[i])
+}
+
+func cosine(a, b []float64) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// Model раскладывает множество образцов, снятых на одной сетке длин волн, например
+// пиксели гиперспектрального куба. Эталоны интерполируются на сетку один раз
+type Model struct {
+	bands   []int // номера длин волн сетки, покрытых всеми эталонами
+	columns [][]float64
+}
+
+// NewModel готовит разложение по эталонам для образцов на сетке wavelengths
+func NewModel(wavelengths []float64, endmembers []Endmember) (*Model, error) {
+	model := &Model{columns: make([][]float64, len(endmembers))}
+	for i, wavelength := range wavelengths {
+		values := make([]float64, len(endmembers))
+		covered := true
+		for j, endmember := range endmembers {
+			if values[j], covered = endmember.Spectrum.At(wavelength); !covered {
+				break
+			}
+		}
+		if !covered {
+			continue
+		}
+		model.bands = append(model.bands, i)
+		for j, value := range values {
+			model.columns[j] = append(model.columns[j], value)
+		}
+	}
+	if len(model.bands) < max(3, len(endmembers)+1) {
+		return nil, ErrTooFewPoints
+	}
+	return model, nil
+}
+
+// Bands - число длин волн, по которым идёт подгонка
+func (m *Model) Bands() int {
+	return len(m.bands)
+}
+
+// Fractions раскладывает образец, заданный значениями на всей сетке модели, и
+// возвращает доли эталонов и среднеквадратичный остаток. observed - рабочий буфер
+// длины Bands(), чтобы не выделять память на каждый образец
+func (m *Model) Fractions(values, observed []float64) ([]float64, float64) {
+	for i, band := range m.bands {
+		observed[i] = values[band]
+	}
+	weights := nnls(m.columns, observed)
+	fitted := combine(m.columns, weights)
+	var squares float64
+	for i := range observed {
+		squares += (observed[i] - fitted[i]) * (observed[i] - fitted[i])
+	}
+	return normalize(weights), math.Sqrt(squares / float64(len(observed)))
+}
@@ -0,0 +1,136 @@
+package unmix
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"colorLex/internal/app/spectra"
+)
+
+func curve(values ...float64) spectra.Spectrum {
+	s := make(spectra.Spectrum, len(values))
+	for i, v := range values {
+		s[i] = spectra.Point{Wavelength: 400 + 50*float64(i), Value: v}
+	}
+	return s
+}
+
+func mix(weights []float64, curves ...spectra.Spectrum) spectra.Spectrum {
+	result := make(spectra.Spectrum, len(curves[0]))
+	for i := range result {
+		result[i].Wavelength = curves[0][i].Wavelength
+		for j, c := range curves {
+			result[i].Value += weights[j] * c[i].Value
+		}
+	}
+	return result
+}
+
+var (
+	blue   = curve(0.45, 0.50, 0.30, 0.12, 0.08, 0.10, 0.25)
+	yellow = curve(0.08, 0.10, 0.18, 0.38, 0.50, 0.55, 0.58)
+	red    = curve(0.05, 0.05, 0.06, 0.10, 0.45, 0.60, 0.65)
+)
+
+func TestNNLS(t *testing.T) {
+	columns := [][]float64{{1, 0, 1}, {0, 1, 1}}
+	got := nnls(columns, []float64{2, 3, 5})
+	if math.Abs(got[0]-2) > 1e-6 || math.Abs(got[1]-3) > 1e-6 {
+		t.Fatalf("weights %v", got)
+	}
+	// Отрицательный вес без ограничения обнуляется
+	got = nnls(columns, []float64{-1, 3, 2})
+	if got[0] != 0 || got[1] <= 0 {
+		t.Fatalf("weights %v", got)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	sample := mix([]float64{0.3, 0.7}, blue, yellow)
+	// Небольшой шум, чтобы интервалы не схлопнулись в точку
+	noise := []float64{0.004, -0.003, 0.002, -0.004, 0.003, -0.002, 0.001}
+	for i := range sample {
+		sample[i].Value += noise[i]
+	}
+
+	endmembers := []Endmember{{PigmentID: 1, Spectrum: blue}, {PigmentID: 2, Spectrum: yellow}, {PigmentID: 3, Spectrum: red}}
+	result, err := Estimate(sample, endmembers, rand.New(rand.NewPCG(1, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shares := result.Shares
+	if math.Abs(shares[0].Fraction-0.3) > 0.03 || math.Abs(shares[1].Fraction-0.7) > 0.05 || shares[2].Fraction > 0.05 {
+		t.Fatalf("fractions %+v", shares)
+	}
+	for _, share := range shares {
+		if share.Low > share.Fraction || share.High < share.Fraction || share.Low < 0 || share.High > 1 {
+			t.Fatalf("interval does not contain the estimate: %+v", share)
+		}
+		if len(share.Indistinguishable) != 0 {
+			t.Fatalf("distinct pigments flagged: %+v", share)
+		}
+	}
+	if shares[0].High-shares[0].Low == 0 {
+		t.Fatalf("noise must widen the interval: %+v", shares[0])
+	}
+
+	// Повтор с тем же генератором даёт те же интервалы
+	again, _ := Estimate(sample, endmembers, rand.New(rand.NewPCG(1, 2)))
+	if again.Shares[0].Low != shares[0].Low || again.Shares[0].High != shares[0].High {
+		t.Fatal("estimate is not reproducible")
+	}
+}
+
+func TestEstimateIndistinguishable(t *testing.T) {
+	// Сиена - та же кривая, что и охра, только темнее: доли между ними не разделить
+	sienna := mix([]float64{0.6}, yellow)
+	endmembers := []Endmember{{PigmentID: 1, Spectrum: blue}, {PigmentID: 2, Spectrum: yellow}, {PigmentID: 3, Spectrum: sienna}}
+	result, err := Estimate(mix([]float64{0.5, 0.5}, blue, yellow), endmembers, rand.New(rand.NewPCG(1, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Shares[0].Indistinguishable) != 0 ||
+		!slices.Equal(result.Shares[1].Indistinguishable, []uint{3}) || !slices.Equal(result.Shares[2].Indistinguishable, []uint{2}) {
+		t.Fatalf("unexpected flags %+v", result.Shares)
+	}
+}
+
+func TestEstimateTooFewPoints(t *testing.T) {
+	narrow := spectra.Spectrum{{Wavelength: 400, Value: 0.1}, {Wavelength: 450, Value: 0.2}}
+	_, err := Estimate(blue, []Endmember{{PigmentID: 1, Spectrum: narrow}}, rand.New(rand.NewPCG(1, 2)))
+	if !errors.Is(err, ErrTooFewPoints) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestModel(t *testing.T) {
+	red := curve(0.1, 0.2, 0.6, 0.8, 0.8)
+	blue := curve(0.6, 0.5, 0.2, 0.1, 0.1)
+	// сетка шире эталонов: крайние длины волн в подгонку не входят
+	wavelengths := []float64{380, 400, 450, 500, 550, 600, 650}
+	model, err := NewModel(wavelengths, []Endmember{{PigmentID: 1, Spectrum: red}, {PigmentID: 2, Spectrum: blue}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.Bands() != 5 {
+		t.Fatalf("bands = %d", model.Bands())
+	}
+	sample := mix([]float64{0.3, 0.7}, red, blue)
+	values := []float64{0.9}
+	for _, p := range sample {
+		values = append(values, p.Value)
+	}
+	values = append(values, 0.9)
+
+	fractions, rmse := model.Fractions(values, make([]float64, model.Bands()))
+	if math.Abs(fractions[0]-0.3) > 1e-6 || math.Abs(fractions[1]-0.7) > 1e-6 || rmse > 1e-9 {
+		t.Fatalf("fractions %v, rmse %v", fractions, rmse)
+	}
+
+	if _, err := NewModel([]float64{700, 710, 720}, []Endmember{{PigmentID: 1, Spectrum: red}}); err != ErrTooFewPoints {
+		t.Fatalf("err = %v", err)
+	}
+}